@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestChatDispatchesToolCalls(t *testing.T) {
+	a, srv := newTestAgent(t, map[string]string{"main.go": "package main\n"},
+		mock.Calls(
+			mock.Tool("create_file", map[string]any{"path": "hello.txt", "content": "hi\n"}),
+			mock.Tool("read_file", map[string]any{"path": "main.go"}),
+		),
+		mock.Text("done"),
+	)
+	reply, err := a.chat("create hello.txt", phaseTools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "done" {
+		t.Errorf("reply = %q, want %q", reply, "done")
+	}
+	if got := readTestFile(t, a.projectDir, "hello.txt"); got != "hi\n" {
+		t.Errorf("hello.txt = %q", got)
+	}
+	results := toolResults(t, srv, 1)
+	if len(results) != 2 {
+		t.Fatalf("second request has %d tool results, want 2", len(results))
+	}
+	assertContains(t, results[1], "package main")
+	if _, ok := a.changes["hello.txt"]; !ok {
+		t.Error("hello.txt is not recorded as changed")
+	}
+}
+
+func TestToolErrorsAreReturnedToTheModel(t *testing.T) {
+	a, srv := newTestAgent(t, nil,
+		mock.Call("read_file", map[string]any{"path": "missing.go"}),
+		mock.Call("no_such_tool", map[string]any{}),
+		mock.Text("gave up"),
+	)
+	if _, err := a.chat("read missing.go", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, lastToolResult(t, srv, 1), "Error")
+	assertContains(t, lastToolResult(t, srv, 2), `unknown tool "no_such_tool"`)
+}
+
+func TestFileToolsStayInsideTheProject(t *testing.T) {
+	a, srv := newTestAgent(t, nil,
+		mock.Call("create_file", map[string]any{"path": "../escape.txt", "content": "x"}),
+		mock.Call("read_file", map[string]any{"path": "/etc/passwd"}),
+		mock.Text("ok"),
+	)
+	if _, err := a.chat("try to escape", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(a.projectDir), "escape.txt")); err == nil {
+		t.Error("create_file wrote outside the project directory")
+	}
+	assertContains(t, lastToolResult(t, srv, 1), "invalid path")
+	assertContains(t, lastToolResult(t, srv, 2), "invalid path")
+}
+
+func TestSensitivePathsAreBlocked(t *testing.T) {
+	a, srv := newTestAgent(t, map[string]string{
+		configFileName: "sensitive_paths:\n  deny: [\"secrets/**\"]\n",
+		"secrets/key":  "s3cret",
+	},
+		mock.Call("read_file", map[string]any{"path": "secrets/key"}),
+		mock.Text("ok"),
+	)
+	if _, err := a.chat("read the key", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	result := lastToolResult(t, srv, 1)
+	assertContains(t, result, "blocked by policy")
+	if a.status.policyBlocks != 1 {
+		t.Errorf("policyBlocks = %d, want 1", a.status.policyBlocks)
+	}
+}
+
+func TestToolPermissions(t *testing.T) {
+	a, srv := newTestAgent(t, map[string]string{
+		configFileName: "permissions:\n  run_shell: deny\n  remove_dir: ask\n",
+		"old/a.txt":    "a",
+	},
+		mock.Call("run_shell", map[string]any{"command": "rm -rf old"}),
+		mock.Call("remove_dir", map[string]any{"path": "old", "recursive": true}),
+		mock.Text("gave up"),
+	)
+	if _, err := a.chat("remove old", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	for _, tool := range srv.Requests()[0].Tools {
+		if tool.Function.Name == "run_shell" {
+			t.Error("a denied tool was offered to the model")
+		}
+	}
+	assertContains(t, lastToolResult(t, srv, 1), `"decision":"denied"`)
+	// Tests do not run on a terminal, so nobody can approve the call.
+	assertContains(t, lastToolResult(t, srv, 2), `"decision":"rejected"`)
+	if readTestFile(t, a.projectDir, "old/a.txt") != "a" {
+		t.Error("old/a.txt was removed")
+	}
+	if a.status.policyBlocks != 2 {
+		t.Errorf("policyBlocks = %d, want 2", a.status.policyBlocks)
+	}
+}
+
+func TestYesApprovesAndAudits(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		configFileName: "permissions:\n  run_shell: ask\n",
+		"a.txt":        "old\n",
+	},
+		mock.Call("run_shell", map[string]any{"command": "echo hi"}),
+		mock.Call("update_file", map[string]any{"path": "a.txt", "find": "old", "replace": "new"}),
+		mock.Call("read_file", map[string]any{"path": "a.txt"}),
+		mock.Text("done"),
+	)
+	a.autoApprove = true
+	if _, err := a.chat("change a.txt", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	st, err := a.stateDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := st.db.Query(`SELECT tool, decision, decided_by, subject FROM approvals ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var tool, decision, by, subject string
+		rows.Scan(&tool, &decision, &by, &subject)
+		got = append(got, strings.Join([]string{tool, decision, by, subject}, " | "))
+	}
+	if len(got) != 2 {
+		t.Fatalf("approvals = %q, want run_shell and update_file (read_file needs none)", got)
+	}
+	assertContains(t, got[0], "run_shell | approved | --yes |   $ echo hi")
+	assertContains(t, got[1], "update_file | approved | --yes | --- a/a.txt")
+	assertContains(t, got[1], "+new")
+}
+
+func TestFileMutationsAreAudited(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{"a.txt": "old\n", "old/b.txt": "b"},
+		mock.Call("create_file", map[string]any{"path": "new.txt", "content": "hello"}),
+		mock.Call("update_file", map[string]any{"path": "a.txt", "find": "old", "replace": "new"}),
+		mock.Call("remove_dir", map[string]any{"path": "old", "recursive": true}),
+		mock.Text("done"),
+	)
+	if _, err := a.chat("shuffle files", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	st, err := a.stateDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := st.db.Query(`SELECT call_id != '', tool, action, path, before_hash, after_hash, COALESCE(before_size, -1), COALESCE(after_size, -1) FROM file_audit ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var hasCall bool
+		var tool, action, path, before, after string
+		var beforeSize, afterSize int
+		rows.Scan(&hasCall, &tool, &action, &path, &before, &after, &beforeSize, &afterSize)
+		if !hasCall {
+			t.Errorf("%s of %s has no call ID", action, path)
+		}
+		got = append(got, fmt.Sprintf("%s %s %s %d→%d %t→%t", tool, action, path, beforeSize, afterSize, before != "", after != ""))
+	}
+	want := []string{
+		"create_file create new.txt -1→5 false→true",
+		"update_file update a.txt 4→4 true→true",
+		"remove_dir delete old/b.txt 1→-1 true→false",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("file_audit =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if _, err := st.db.Exec(`DELETE FROM file_audit`); err == nil {
+		t.Fatal("deleting from the audit log succeeded, want it to be append-only")
+	}
+}
+
+func TestFeedbackLoopIteratesUntilTestsPass(t *testing.T) {
+	a, srv := newTestAgent(t, map[string]string{configFileName: "test:\n  command: test -f ok.txt\n"},
+		mock.Text("I think it is done"),
+		mock.Call("create_file", map[string]any{"path": "ok.txt", "content": "ok"}),
+		mock.Text("created ok.txt"),
+	)
+	err := a.feedbackLoop("make the tests pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := a.exitCode(err); code != exitVerified {
+		t.Errorf("exit code = %d, want %d", code, exitVerified)
+	}
+	// The second turn must show the model why the tests failed.
+	reqs := srv.Requests()
+	last := reqs[1].Messages[len(reqs[1].Messages)-1]
+	assertContains(t, last.Content, "test failures")
+}
+
+func TestFeedbackLoopWithoutChecksIsUnverified(t *testing.T) {
+	a, _ := newTestAgent(t, nil, mock.Text("nothing to do"))
+	err := a.feedbackLoop("say hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := a.exitCode(err); code != exitUnverified {
+		t.Errorf("exit code = %d, want %d", code, exitUnverified)
+	}
+}
+
+func TestRunSummary(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		configFileName: "test:\n  command: test -f ok.txt\n",
+		"main.txt":     "one\ntwo\n",
+		"old/gone.txt": "gone\n",
+	},
+		mock.Call("create_file", map[string]any{"path": "ok.txt", "content": "ok\n"}),
+		mock.Call("update_file", map[string]any{"path": "main.txt", "find": "two", "replace": "2\n3"}),
+		mock.Call("remove_dir", map[string]any{"path": "old", "recursive": true}),
+		mock.Call("run_shell", map[string]any{"command": "true"}),
+		mock.Text("done"),
+	)
+	if err := a.feedbackLoop("tidy up"); err != nil {
+		t.Fatal(err)
+	}
+	summary := a.runSummary()
+	assertContains(t, summary, "1 created, 1 modified, 1 deleted (+3 −2 lines)")
+	assertContains(t, summary, "~ main.txt (+2 −1)")
+	assertContains(t, summary, "Commands: 1 run")
+	assertContains(t, summary, "Tests:    ✅ passing")
+}
+
+func TestCommitMessage(t *testing.T) {
+	a, srv := newTestAgent(t, nil, mock.Text("```\nfeat(api): add health endpoint\n\nLoad balancers need it.\n```"))
+	msg, err := a.commitMessage("diff --git a/main.go b/main.go\n+func health() {}\n", "add a health check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "feat(api): add health endpoint\n\nLoad balancers need it."; msg != want {
+		t.Errorf("message = %q, want %q", msg, want)
+	}
+	sent := srv.Requests()[0].Messages[1].Content
+	assertContains(t, sent, "add a health check")
+	assertContains(t, sent, "+func health() {}")
+
+	if _, err := a.commitMessage("", ""); err == nil {
+		t.Error("an empty diff must not produce a commit message")
+	}
+}
+
+func TestPRDescriptionAndChangelog(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{configFileName: "test:\n  command: test -f ok.txt\n"},
+		mock.Call("create_file", map[string]any{"path": "ok.txt", "content": "ok\n"}),
+		mock.Text("created ok.txt"),
+		mock.Text(`{"title": "Add ok.txt", "problem": "The tests need ok.txt.", "approach": "Create it.", "testing": "test -f ok.txt passes.", "changelog": {"section": "Added", "entry": "ok.txt marker file"}}`),
+	)
+	a.prBody = filepath.Join(t.TempDir(), "pr.md")
+	a.changelog = true
+	if err := a.feedbackLoop("make the tests pass"); err != nil {
+		t.Fatal(err)
+	}
+	body, err := os.ReadFile(a.prBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, string(body), "# Add ok.txt\n\n## Problem\n\nThe tests need ok.txt.")
+	assertContains(t, string(body), "## Testing\n\ntest -f ok.txt passes.")
+	assertContains(t, string(body), "- `ok.txt` (created, +1 −0)")
+	changelog, err := os.ReadFile(filepath.Join(a.projectDir, changelogFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, string(changelog), "## [Unreleased]\n\n### Added\n\n- ok.txt marker file\n")
+}
+
+func TestReviewDiffReportsFindings(t *testing.T) {
+	a, srv := newTestAgent(t, map[string]string{"main.go": "package main\n\nfunc div(a, b int) int { return a / b }\n"},
+		mock.Call("read_file", map[string]any{"path": "main.go"}),
+		mock.Text("```json\n"+`{"findings": [
+			{"severity": "minor", "file": "main.go", "line": 0, "message": "No tests.", "suggestion": "Add a test."},
+			{"severity": "major", "file": "main.go", "line": 3, "message": "Division by zero panics.", "suggestion": "Check b."}
+		]}`+"\n```"),
+	)
+	findings, err := a.reviewDiff("+func div(a, b int) int { return a / b }\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 || findings[0].Severity != "major" || findings[0].Line != 3 {
+		t.Fatalf("findings = %+v, want the major one first", findings)
+	}
+	for _, tool := range srv.Requests()[0].Tools {
+		if !readOnlyTools[tool.Function.Name] {
+			t.Errorf("review offers %s, which can modify the project", tool.Function.Name)
+		}
+	}
+	assertContains(t, findingsText(findings), "main.go:3 [major] Division by zero panics.\n    ↪ Check b.")
+
+	raw, _ := json.Marshal(sarifLog(findings))
+	assertContains(t, string(raw), `"level":"error"`)
+	assertContains(t, string(raw), `"region":{"startLine":3}`)
+}
+
+func TestExplainTask(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		"server/server.go": "package server\n\ntype Server struct{}\n\nfunc (s *Server) Start() error { return nil }\n",
+		"README.md":        "# demo\n",
+	})
+	task, err := a.explainTask("Start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, task, "the symbol Start, defined at:\nserver/server.go:5 method Server.Start")
+	assertContains(t, task, "server/server.go\n  type Server:3\n  method Server.Start:5\n")
+
+	task, err = a.explainTask("server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, task, "the subsystem in the directory server/")
+
+	if _, err := a.explainTask("Missing"); err == nil {
+		t.Error("explaining an unknown symbol should fail")
+	}
+}
+
+func TestProviderErrorsAreClassified(t *testing.T) {
+	noBackoff(t)
+	var replies []mock.Reply
+	for range maxRecoveries + 1 { // the first failures are retried
+		replies = append(replies, mock.Error(http.StatusInternalServerError, "server_error"))
+	}
+	a, _ := newTestAgent(t, nil, replies...)
+	err := a.feedbackLoop("anything")
+	if !errors.Is(err, errProvider) {
+		t.Fatalf("err = %v, want a provider error", err)
+	}
+	if code := a.exitCode(err); code != exitProvider {
+		t.Errorf("exit code = %d, want %d", code, exitProvider)
+	}
+}
+
+func TestRateLimitedKeysRotate(t *testing.T) {
+	a, srv := newTestAgentWithKeys(t, []string{"sk-first", "sk-second"}, nil,
+		mock.Error(http.StatusTooManyRequests, "rate_limit_exceeded"),
+		mock.Text("hello"),
+		mock.Text("again"),
+	)
+	for _, want := range []string{"hello", "again"} {
+		reply, err := a.chat("hi", phaseTools)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply != want {
+			t.Errorf("reply = %q, want %q", reply, want)
+		}
+	}
+	var keys []string
+	for _, r := range srv.Requests() {
+		keys = append(keys, r.APIKey)
+	}
+	if want := []string{"sk-first", "sk-second", "sk-second"}; len(keys) != 3 || keys[0] != want[0] || keys[1] != want[1] || keys[2] != want[2] {
+		t.Errorf("keys used = %v, want %v", keys, want)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Ask mode (read-only Q&A)
+  ─────────────────────────────*/
+
+// askPrompt replaces the coding system prompt when answering questions about the project.
+func askPrompt() openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: `You are AutonomousGuide, a senior software engineer helping someone understand a codebase. You can only read the project: use 'list_files', 'search_files' and 'read_file' to find the relevant code, file paths are relative to the project root. Never guess: look things up before answering. Answer the question concisely in Markdown and cite the files (and functions) your answer is based on.`,
+	}
+}
+
+/*──────────────────────────────
+  zug ask
+  ─────────────────────────────*/
+
+func askCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	return func() {
+		question := strings.TrimSpace(arg(fs.Args(), 0))
+		if question == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+
+		agent := cf.newAgent(arg(fs.Args(), 1))
+		agent.readOnly = true
+		agent.prompt = askPrompt()
+
+		log.Printf("[agent] ❓ Answering question (read-only): %s\n", question)
+		answer, err := agent.chat(question, phaseAsk)
+		if err != nil {
+			log.Fatalf("❌ Could not answer the question: %v", err)
+		}
+		fmt.Printf("💡 Answer:\n%s\n", strings.TrimSpace(answer))
+	}
+}
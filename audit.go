@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+/*──────────────────────────────
+  Audit log: approval decisions
+  ─────────────────────────────*/
+
+// Decisions about a tool call, and who made them.
+const (
+	approvalGranted  = "approved"
+	approvalRejected = "rejected"
+	approvalDenied   = "denied"
+
+	decidedByUser       = "user"        // answered on the terminal
+	decidedByAlways     = "always"      // the user allowed the tool for the rest of the run
+	decidedByYes        = "--yes"       // approved automatically
+	decidedByPolicy     = "permissions" // deny in zug.yaml
+	decidedByNoTerminal = "no terminal" // ask, but nobody could answer
+)
+
+// approval is one decision about a tool call, kept in the approvals table of
+// .zug/state.db. subject is what was decided on: the shell command, the diff the call
+// produced, or its arguments.
+type approval struct {
+	callID, tool, decision, decidedBy, reason, subject string
+}
+
+// callSnapshot is the file a call is about to change, read before the call so the
+// approved diff can be recorded.
+type callSnapshot struct {
+	rel, before string
+	existed     bool
+}
+
+// snapshotCall reads the file named by the path (or destination) argument of a call.
+func (a *AutonomousCodingAgent) snapshotCall(jsonArgs string) callSnapshot {
+	var p struct {
+		Path string `json:"path"`
+		To   string `json:"to"`
+	}
+	json.Unmarshal([]byte(jsonArgs), &p)
+	s := callSnapshot{rel: p.Path}
+	if p.To != "" {
+		s.rel = p.To
+	}
+	if s.rel == "" {
+		return s
+	}
+	full, err := a.absPath(s.rel)
+	if err != nil {
+		return callSnapshot{}
+	}
+	if raw, err := a.fs.ReadFile(full); err == nil {
+		s.before, s.existed = string(raw), true
+	}
+	return s
+}
+
+// approvedSubject describes what an approved call did: the command for run_shell, the
+// diff of the file it changed, or else its arguments.
+func (a *AutonomousCodingAgent) approvedSubject(tool, jsonArgs string, s callSnapshot) string {
+	if tool == "run_shell" || s.rel == "" {
+		return describeCall(tool, jsonArgs)
+	}
+	full, err := a.absPath(s.rel)
+	if err != nil {
+		return describeCall(tool, jsonArgs)
+	}
+	after, to := "", "/dev/null"
+	if raw, err := a.fs.ReadFile(full); err == nil {
+		after, to = string(raw), "b/"+s.rel
+	}
+	from := "a/" + s.rel
+	if !s.existed {
+		from = "/dev/null"
+	}
+	if diff := unifiedDiff(from, to, s.before, after); diff != "" {
+		return diff
+	}
+	return describeCall(tool, jsonArgs)
+}
+
+// recordApproval appends d to the audit log. Failing to record is logged, not fatal.
+func (a *AutonomousCodingAgent) recordApproval(d *approval) {
+	if d == nil {
+		return
+	}
+	st, err := a.stateDB()
+	if err == nil {
+		_, err = st.db.Exec(`INSERT INTO approvals (run_id, time, call_id, tool, decision, decided_by, reason, subject) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			sql.NullInt64{Int64: a.runID, Valid: a.runID != 0}, stateTime(time.Now()), d.callID, d.tool, d.decision, d.decidedBy, d.reason, d.subject)
+	}
+	if err != nil {
+		log.Printf("[agent] ⚠️ Could not record the approval of %s in the audit log: %v\n", d.tool, err)
+	}
+}
+
+/*──────────────────────────────
+  Audit log: file mutations
+  ─────────────────────────────*/
+
+// File mutations recorded in the file_audit table.
+const (
+	auditCreate = "create"
+	auditUpdate = "update"
+	auditAppend = "append"
+	auditDelete = "delete"
+)
+
+// auditFS records every file written, appended to or deleted through it, with hashes
+// and sizes before and after and the tool call that caused it.
+type auditFS struct {
+	projectFS
+	a *AutonomousCodingAgent
+}
+
+// audited wraps fsys so the agent's file mutations are recorded.
+func (a *AutonomousCodingAgent) audited(fsys projectFS) projectFS {
+	return &auditFS{projectFS: fsys, a: a}
+}
+
+func (f *auditFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	before, existed := f.read(name)
+	if err := f.projectFS.WriteFile(name, data, perm); err != nil {
+		return err
+	}
+	action := auditUpdate
+	if !existed {
+		action = auditCreate
+	}
+	f.a.recordFileAudit(action, name, before, existed, data, true)
+	return nil
+}
+
+func (f *auditFS) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	before, existed := f.read(name)
+	if err := f.projectFS.AppendFile(name, data, perm); err != nil {
+		return err
+	}
+	action := auditAppend
+	if !existed {
+		action = auditCreate
+	}
+	f.a.recordFileAudit(action, name, before, existed, append(before, data...), true)
+	return nil
+}
+
+// RemoveAll records one deletion per file that existed below name.
+func (f *auditFS) RemoveAll(name string) error {
+	type removed struct {
+		path    string
+		content []byte
+	}
+	var files []removed
+	walkDir(f.projectFS, name, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			content, _ := f.read(path)
+			files = append(files, removed{path, content})
+		}
+		return nil
+	})
+	if err := f.projectFS.RemoveAll(name); err != nil {
+		return err
+	}
+	for _, r := range files {
+		f.a.recordFileAudit(auditDelete, r.path, r.content, true, nil, false)
+	}
+	return nil
+}
+
+func (f *auditFS) read(name string) ([]byte, bool) {
+	raw, err := f.projectFS.ReadFile(name)
+	return raw, err == nil
+}
+
+// recordFileAudit appends one mutation of the file at full path name to the audit log,
+// attributed to the tool call in progress. Failing to record is logged, not fatal.
+func (a *AutonomousCodingAgent) recordFileAudit(action, name string, before []byte, existed bool, after []byte, exists bool) {
+	rel, err := filepath.Rel(a.projectDir, name)
+	if err != nil {
+		rel = name
+	}
+	hashAndSize := func(content []byte, ok bool) (string, sql.NullInt64) {
+		if !ok {
+			return "", sql.NullInt64{}
+		}
+		sum := sha256.Sum256(content)
+		return hex.EncodeToString(sum[:]), sql.NullInt64{Int64: int64(len(content)), Valid: true}
+	}
+	beforeHash, beforeSize := hashAndSize(before, existed)
+	afterHash, afterSize := hashAndSize(after, exists)
+	st, err := a.stateDB()
+	if err == nil {
+		_, err = st.db.Exec(`INSERT INTO file_audit (run_id, time, call_id, tool, action, path, before_hash, after_hash, before_size, after_size)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			sql.NullInt64{Int64: a.runID, Valid: a.runID != 0}, stateTime(time.Now()), a.call.id, a.call.tool, action,
+			filepath.ToSlash(rel), beforeHash, afterHash, beforeSize, afterSize)
+	}
+	if err != nil {
+		log.Printf("[agent] ⚠️ Could not record the %s of %s in the audit log: %v\n", action, rel, err)
+	}
+}
+
+// fileState is the content of a file at one point in time, for changes made outside
+// the agent's file system (git apply of a subtask's branch, package managers).
+type fileState struct {
+	content []byte
+	exists  bool
+}
+
+// snapshotFiles reads the project files rel, before they are changed outside a.fs.
+func (a *AutonomousCodingAgent) snapshotFiles(rel []string) map[string]fileState {
+	states := make(map[string]fileState, len(rel))
+	for _, f := range rel {
+		raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, f))
+		states[f] = fileState{raw, err == nil}
+	}
+	return states
+}
+
+// auditApplied records the files that changed since snapshotFiles returned before.
+func (a *AutonomousCodingAgent) auditApplied(rel []string, before map[string]fileState) {
+	for _, f := range rel {
+		full := filepath.Join(a.projectDir, f)
+		raw, err := a.fs.ReadFile(full)
+		after, was := fileState{raw, err == nil}, before[f]
+		switch {
+		case !was.exists && after.exists:
+			a.recordFileAudit(auditCreate, full, nil, false, after.content, true)
+		case was.exists && !after.exists:
+			a.recordFileAudit(auditDelete, full, was.content, true, nil, false)
+		case was.exists && !bytes.Equal(was.content, after.content):
+			a.recordFileAudit(auditUpdate, full, was.content, true, after.content, true)
+		}
+	}
+}
+
+/*──────────────────────────────
+  zug audit
+  ─────────────────────────────*/
+
+func auditCommand(fs *flag.FlagSet) func() {
+	dir := fs.String("dir", "ai_coder_project", "project directory")
+	since := fs.String("since", "", "first day to show: YYYY-MM-DD, today, yesterday or a weekday name")
+	until := fs.String("until", "", "last day to show, same formats as -since")
+	path := fs.String("path", "", "only files whose path contains this text")
+	run := fs.Int64("run", 0, "only this run (see zug history)")
+	approvals := fs.Bool("approvals", false, "show the approval decisions instead of the file mutations")
+	asJSON := fs.Bool("json", false, "print one JSON object per line")
+	return func() {
+		dbDir := filepath.Join(*dir, zugDirName)
+		if !fileExists(filepath.Join(dbDir, stateFileName)) {
+			log.Fatalf("❌ No audit log recorded for %s yet.", *dir)
+		}
+		st, err := openState(dbDir)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		defer st.db.Close()
+
+		var where []string
+		var params []interface{}
+		for _, bound := range []struct {
+			value, op string
+			days      int
+		}{{*since, ">=", 0}, {*until, "<", 1}} {
+			if bound.value == "" {
+				continue
+			}
+			day, err := parseDay(bound.value, time.Now())
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			where = append(where, "time "+bound.op+" ?")
+			params = append(params, stateTime(day.AddDate(0, 0, bound.days)))
+		}
+		if *run != 0 {
+			where = append(where, "run_id = ?")
+			params = append(params, *run)
+		}
+
+		query := `SELECT id, COALESCE(run_id, 0), time, call_id, tool, action, path, before_hash, after_hash, before_size, after_size FROM file_audit`
+		if *approvals {
+			query = `SELECT id, COALESCE(run_id, 0), time, call_id, tool, decision, decided_by, reason, subject FROM approvals`
+			if *path != "" {
+				where = append(where, "instr(subject, ?) > 0")
+				params = append(params, *path)
+			}
+		} else if *path != "" {
+			where = append(where, "instr(path, ?) > 0")
+			params = append(params, *path)
+		}
+		if len(where) > 0 {
+			query += " WHERE " + strings.Join(where, " AND ")
+		}
+		rows, err := st.db.Query(query+" ORDER BY id", params...)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		defer rows.Close()
+
+		enc := json.NewEncoder(os.Stdout)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if !*asJSON {
+			if *approvals {
+				fmt.Fprintln(w, "TIME\tRUN\tCALL\tTOOL\tDECISION\tBY\tSUBJECT")
+			} else {
+				fmt.Fprintln(w, "TIME\tRUN\tCALL\tTOOL\tACTION\tPATH\tSIZE\tHASH")
+			}
+		}
+		n := 0
+		for rows.Next() {
+			var id, runID int64
+			var when, callID, tool string
+			if *approvals {
+				var decision, decidedBy, reason, subject string
+				if err := rows.Scan(&id, &runID, &when, &callID, &tool, &decision, &decidedBy, &reason, &subject); err != nil {
+					log.Fatalf("❌ %v", err)
+				}
+				if *asJSON {
+					enc.Encode(map[string]any{"id": id, "run": runID, "time": when, "call_id": callID, "tool": tool,
+						"decision": decision, "decided_by": decidedBy, "reason": reason, "subject": subject})
+				} else {
+					fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", localTime(when), runID, callID, tool, decision, decidedBy, firstLine(subject))
+				}
+			} else {
+				var action, path, beforeHash, afterHash string
+				var beforeSize, afterSize sql.NullInt64
+				if err := rows.Scan(&id, &runID, &when, &callID, &tool, &action, &path, &beforeHash, &afterHash, &beforeSize, &afterSize); err != nil {
+					log.Fatalf("❌ %v", err)
+				}
+				if *asJSON {
+					record := map[string]any{"id": id, "run": runID, "time": when, "call_id": callID, "tool": tool,
+						"action": action, "path": path, "before_hash": beforeHash, "after_hash": afterHash}
+					if beforeSize.Valid {
+						record["before_size"] = beforeSize.Int64
+					}
+					if afterSize.Valid {
+						record["after_size"] = afterSize.Int64
+					}
+					enc.Encode(record)
+				} else {
+					fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s → %s\t%s → %s\n", localTime(when), runID, callID, tool, action, path,
+						auditSize(beforeSize), auditSize(afterSize), shortHash(beforeHash), shortHash(afterHash))
+				}
+			}
+			n++
+		}
+		if err := rows.Err(); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if n == 0 && !*asJSON {
+			fmt.Println("No matching audit records.")
+			return
+		}
+		w.Flush()
+	}
+}
+
+func localTime(stamp string) string {
+	if t, err := time.Parse(stateTimeFormat, stamp); err == nil {
+		return t.Local().Format("2006-01-02 15:04:05")
+	}
+	return stamp
+}
+
+func auditSize(n sql.NullInt64) string {
+	if !n.Valid {
+		return "-"
+	}
+	return strconv.FormatInt(n.Int64, 10)
+}
+
+func shortHash(h string) string {
+	if h == "" {
+		return "-"
+	}
+	return h[:min(len(h), 12)]
+}
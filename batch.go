@@ -0,0 +1,231 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*──────────────────────────────
+  Batch mode over a task list
+  ─────────────────────────────*/
+
+const (
+	onFailureContinue = "continue"
+	onFailureStop     = "stop"
+)
+
+// batchFile is the schema of tasks.yaml.
+type batchFile struct {
+	Model     string      `yaml:"model"`      // default model for every task
+	OnFailure string      `yaml:"on_failure"` // "continue" (default) or "stop"
+	Tasks     []batchTask `yaml:"tasks"`
+}
+
+type batchTask struct {
+	Name      string `yaml:"name"`
+	Task      string `yaml:"task"`
+	Dir       string `yaml:"dir"`        // project dir, relative to tasks.yaml
+	Branch    string `yaml:"branch"`     // optional: work and commit on this git branch
+	Model     string `yaml:"model"`      // overrides the file-level model
+	Review    bool   `yaml:"review"`     // run the reviewer pass
+	OnFailure string `yaml:"on_failure"` // overrides the file-level on_failure
+}
+
+// batchResult is one row of the consolidated report.
+type batchResult struct {
+	task     batchTask
+	err      error
+	skipped  bool
+	duration time.Duration
+	files    []string
+	summary  string
+}
+
+// loadBatchFile parses and validates a batch file, resolving task dirs relative to it.
+func loadBatchFile(path string) (*batchFile, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read batch file %s: %w", path, err)
+	}
+	var bf batchFile
+	if err := yaml.Unmarshal(raw, &bf); err != nil {
+		return nil, fmt.Errorf("invalid batch file %s: %w", path, err)
+	}
+	if len(bf.Tasks) == 0 {
+		return nil, fmt.Errorf("batch file %s defines no tasks", path)
+	}
+	base := filepath.Dir(path)
+	for i := range bf.Tasks {
+		t := &bf.Tasks[i]
+		if strings.TrimSpace(t.Task) == "" {
+			return nil, fmt.Errorf("task #%d in %s has no 'task' text", i+1, path)
+		}
+		if t.Name == "" {
+			t.Name = fmt.Sprintf("task-%d", i+1)
+		}
+		if t.Dir == "" {
+			t.Dir = "ai_coder_project"
+		}
+		if !filepath.IsAbs(t.Dir) {
+			t.Dir = filepath.Join(base, t.Dir)
+		}
+		if t.Model == "" {
+			t.Model = bf.Model
+		}
+		if t.OnFailure == "" {
+			t.OnFailure = bf.OnFailure
+		}
+		switch t.OnFailure {
+		case "", onFailureContinue, onFailureStop:
+		default:
+			return nil, fmt.Errorf("task %s: on_failure must be %q or %q, got %q", t.Name, onFailureContinue, onFailureStop, t.OnFailure)
+		}
+	}
+	return &bf, nil
+}
+
+// runBatchTask runs a single entry, checking out and committing to its branch when configured.
+func runBatchTask(t batchTask, model string) (res batchResult) {
+	res.task = t
+	start := time.Now()
+	defer func() { res.duration = time.Since(start) }()
+
+	if t.Branch != "" {
+		// An existing branch is continued, never reset: it may hold an earlier run's commits.
+		args := []string{"checkout", "-b", t.Branch}
+		if _, err := gitCmd(t.Dir, "rev-parse", "--verify", "--quiet", "refs/heads/"+t.Branch); err == nil {
+			args = []string{"checkout", t.Branch}
+		}
+		if _, err := gitCmd(t.Dir, args...); err != nil {
+			res.err = fmt.Errorf("cannot check out branch %s: %w", t.Branch, err)
+			return res
+		}
+	}
+
+	cf := commonFlags{dir: t.Dir, model: t.Model}
+	if cf.model == "" {
+		cf.model = model
+	}
+	agent := cf.newAgent("")
+	agent.review = t.Review
+	res.err = agent.feedbackLoop(t.Task)
+	res.summary = strings.TrimSpace(agent.lastReply)
+	for f := range agent.changes {
+		res.files = append(res.files, f)
+	}
+	sort.Strings(res.files)
+
+	if t.Branch != "" && len(res.files) > 0 {
+		if _, err := gitCmd(t.Dir, "add", "-A"); err == nil {
+			msg := agent.autoCommitMessage(t.Dir, t.Task, "zug: "+t.Name)
+			if _, err := gitCmd(t.Dir, "-c", "user.name=zug", "-c", "user.email=zug@localhost", "commit", "-q", "-m", msg); err != nil {
+				log.Printf("[batch] Warning: could not commit %s on %s: %v\n", t.Name, t.Branch, err)
+			}
+		}
+	}
+	return res
+}
+
+// batchReport renders the consolidated Markdown report under the given title.
+func batchReport(title, path string, results []batchResult) string {
+	var sb strings.Builder
+	passed, failed, skipped := 0, 0, 0
+	for _, r := range results {
+		switch {
+		case r.skipped:
+			skipped++
+		case r.err != nil:
+			failed++
+		default:
+			passed++
+		}
+	}
+	fmt.Fprintf(&sb, "# %s\n\n**File:** %s  \n**Finished:** %s  \n**Result:** %d passed, %d failed, %d skipped\n\n",
+		title, path, time.Now().Format(time.RFC3339), passed, failed, skipped)
+	sb.WriteString("| Task | Dir | Branch | Status | Duration | Files changed |\n|---|---|---|---|---|---|\n")
+	for _, r := range results {
+		status := "✅ passed"
+		switch {
+		case r.skipped:
+			status = "⏭️ skipped"
+		case r.err != nil:
+			status = "❌ failed"
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s | %d |\n", r.task.Name, r.task.Dir, r.task.Branch, status, r.duration.Round(time.Second), len(r.files))
+	}
+	for _, r := range results {
+		if r.skipped {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n## %s\n\n", r.task.Name)
+		if r.err != nil {
+			fmt.Fprintf(&sb, "**Error:** %v\n\n", r.err)
+		}
+		if len(r.files) > 0 {
+			fmt.Fprintf(&sb, "**Files:** %s\n\n", strings.Join(r.files, ", "))
+		}
+		if r.summary != "" {
+			fmt.Fprintf(&sb, "%s\n", r.summary)
+		}
+	}
+	return sb.String()
+}
+
+/*──────────────────────────────
+  zug batch
+  ─────────────────────────────*/
+
+func batchCommand(fs *flag.FlagSet) func() {
+	model := fs.String("model", "", "default model for tasks that do not set one")
+	report := fs.String("report", "zug-batch-report.md", "file to write the consolidated report to")
+	return func() {
+		path := arg(fs.Args(), 0)
+		if path == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+		bf, err := loadBatchFile(path)
+		if err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+
+		results := make([]batchResult, 0, len(bf.Tasks))
+		stopped := false
+		for i, t := range bf.Tasks {
+			if stopped {
+				results = append(results, batchResult{task: t, skipped: true})
+				continue
+			}
+			log.Printf("[batch] ▶️ Task %d/%d: %s (dir %s)\n", i+1, len(bf.Tasks), t.Name, t.Dir)
+			r := runBatchTask(t, *model)
+			results = append(results, r)
+			if r.err != nil {
+				log.Printf("[batch] ❌ Task %s failed: %v\n", t.Name, r.err)
+				if t.OnFailure == onFailureStop {
+					log.Println("[batch] Stopping: on_failure is 'stop'.")
+					stopped = true
+				}
+			}
+		}
+
+		out := batchReport("zug batch report", path, results)
+		fmt.Println(out)
+		if err := os.WriteFile(*report, []byte(out), 0o644); err != nil {
+			log.Fatalf("FATAL: Could not write report to %s: %v", *report, err)
+		}
+		log.Printf("[batch] Report written to %s\n", *report)
+		for _, r := range results {
+			if r.err != nil || r.skipped {
+				os.Exit(1)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  LLM response cache
+  ─────────────────────────────*/
+
+// Cache modes, chosen with -cache or ZUG_CACHE. The cache lives in the user's
+// ~/.zug/state.db so it is shared by every project.
+const (
+	cacheOff    = ""
+	cacheOn     = "on"     // replay stored completions, store new ones
+	cacheReplay = "replay" // replay only; a miss is an error (offline tests of zug itself)
+)
+
+func validCacheMode(mode string) error {
+	switch mode {
+	case cacheOff, cacheOn, cacheReplay:
+		return nil
+	}
+	return fmt.Errorf("unknown cache mode %q (want %q or %q)", mode, cacheOn, cacheReplay)
+}
+
+// cacheKey hashes what determines a completion for caching purposes: the model, the
+// messages and the offered tools.
+func cacheKey(req openai.ChatCompletionRequest) (string, error) {
+	raw, err := json.Marshal(struct {
+		Model    string                         `json:"model"`
+		Messages []openai.ChatCompletionMessage `json:"messages"`
+		Tools    []openai.Tool                  `json:"tools"`
+	}{req.Model, req.Messages, req.Tools})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (a *AutonomousCodingAgent) responseCache() (*stateStore, error) {
+	if a.cache != nil {
+		return a.cache, nil
+	}
+	dir, err := userZugDir()
+	if err != nil {
+		return nil, err
+	}
+	if a.cache, err = openState(dir); err != nil {
+		return nil, err
+	}
+	return a.cache, nil
+}
+
+// createChatCompletion sends req to the API, going through the response cache when enabled,
+// and adds the tokens spent to a.usage.
+func (a *AutonomousCodingAgent) createChatCompletion(req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	resp, err := a.cachedCompletion(req)
+	if err != nil {
+		return resp, fmt.Errorf("%w: %w", errProvider, err)
+	}
+	a.usage.PromptTokens += resp.Usage.PromptTokens
+	a.usage.CompletionTokens += resp.Usage.CompletionTokens
+	a.usage.TotalTokens += resp.Usage.TotalTokens
+	return resp, nil
+}
+
+// cachedCompletion replays a stored response when possible. Replayed responses report zero
+// usage since they cost nothing.
+func (a *AutonomousCodingAgent) cachedCompletion(req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if a.cacheMode == cacheOff {
+		return a.createWithKeys(req)
+	}
+	st, err := a.responseCache()
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	key, err := cacheKey(req)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, fmt.Errorf("cannot compute cache key: %w", err)
+	}
+
+	var raw string
+	err = st.db.QueryRow(`SELECT response FROM llm_cache WHERE key = ?`, key).Scan(&raw)
+	switch {
+	case err == nil:
+		var resp openai.ChatCompletionResponse
+		if err := json.Unmarshal([]byte(raw), &resp); err != nil {
+			return resp, fmt.Errorf("corrupt cache entry %s: %w", key[:12], err)
+		}
+		log.Printf("[agent] 💾 Replayed cached completion %s.\n", key[:12])
+		resp.Usage = openai.Usage{}
+		return resp, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return openai.ChatCompletionResponse{}, fmt.Errorf("cannot read the response cache: %w", err)
+	case a.cacheMode == cacheReplay:
+		return openai.ChatCompletionResponse{}, fmt.Errorf("no cached completion %s for this request (cache mode %q never calls the API)", key[:12], cacheReplay)
+	}
+
+	resp, err := a.createWithKeys(req)
+	if err != nil {
+		return resp, err
+	}
+	if stored, err := json.Marshal(resp); err == nil {
+		_, err = st.db.Exec(`INSERT OR REPLACE INTO llm_cache (key, model, response, created) VALUES (?, ?, ?, ?)`,
+			key, req.Model, string(stored), stateTime(time.Now()))
+		if err != nil {
+			log.Printf("[agent] ⚠️ Could not store completion in the cache: %v\n", err)
+		}
+	}
+	return resp, nil
+}
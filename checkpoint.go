@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*──────────────────────────────
+  State directories and checkpoint store
+  ─────────────────────────────*/
+
+const zugDirName = ".zug" // per-project state; ignores itself in git and is hidden from the file tools
+
+// zugDir returns the project's .zug directory, creating it with a catch-all .gitignore.
+func (a *AutonomousCodingAgent) zugDir() (string, error) {
+	dir := filepath.Join(a.projectDir, zugDirName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+	gi := filepath.Join(dir, ".gitignore")
+	if !fileExists(gi) {
+		if err := os.WriteFile(gi, []byte("*\n"), 0o644); err != nil {
+			return "", fmt.Errorf("cannot write %s: %w", gi, err)
+		}
+	}
+	return dir, nil
+}
+
+// checkpointFile saves content, the current version of rel, in the state store and
+// returns a description naming the checkpoint (restore it with zug history -checkpoint).
+func (a *AutonomousCodingAgent) checkpointFile(rel string, content []byte) (string, error) {
+	st, err := a.stateDB()
+	if err != nil {
+		return "", err
+	}
+	res, err := st.db.Exec(`INSERT INTO checkpoints (run_id, path, content, created) VALUES (?, ?, ?, ?)`,
+		sql.NullInt64{Int64: a.runID, Valid: a.runID != 0}, filepath.Clean(rel), content, stateTime(time.Now()))
+	if err != nil {
+		return "", fmt.Errorf("cannot write checkpoint of %s: %w", rel, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return "", fmt.Errorf("cannot write checkpoint of %s: %w", rel, err)
+	}
+	return fmt.Sprintf("checkpoint %d", id), nil
+}
+
+// userZugDir returns the per-user state directory ($ZUG_HOME or ~/.zug), creating it.
+func userZugDir() (string, error) {
+	dir := os.Getenv("ZUG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot determine home directory (set ZUG_HOME): %w", err)
+		}
+		dir = filepath.Join(home, ".zug")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+	return dir, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+/*──────────────────────────────
+  Test runner
+  ─────────────────────────────*/
+
+const maxBuildOutput = 8000 // characters of compiler output fed back to the model
+
+// buildConfig is the `build:` section of zug.yaml.
+type buildConfig struct {
+	Command  string `yaml:"command"`  // overrides autodetection
+	Disabled bool   `yaml:"disabled"` // never run a build check
+}
+
+// buildCommand resolves the configured or detected build/compile check for t, or "" when there is none.
+func (a *AutonomousCodingAgent) buildCommand(t checkTarget) string {
+	switch {
+	case a.cfg.Build.Disabled:
+		return ""
+	case t.root != nil && t.root.Build != "":
+		return t.root.Build
+	case t.root == nil && a.cfg.Build.Command != "":
+		return a.cfg.Build.Command
+	}
+	return toolchainCommand(a.detectToolchains(t.dir), func(tc toolchain) string { return tc.BuildCmd })
+}
+
+// runBuild compiles the project once the agent has edited files, independently of any tests.
+// It reports ok=true when nothing changed, no build command applies, or the build succeeds.
+func (a *AutonomousCodingAgent) runBuild() (output string, ok bool) {
+	if len(a.changes) == 0 {
+		return "", true
+	}
+	var failures []string
+	ran := 0
+	for _, t := range a.checkTargets() {
+		cmd := a.buildCommand(t)
+		if cmd == "" {
+			continue
+		}
+		ran++
+		log.Printf("[agent] 🔨 Running build check: %s%s\n", t.label(), cmd)
+		out, err := a.execShellIn(t, cmd)
+		if err == nil {
+			continue
+		}
+		if len(out) > maxBuildOutput {
+			out = out[:maxBuildOutput] + "\n... (build output truncated)"
+		}
+		fmt.Printf("🔨 %sBuild Output:\n%s\n\n", t.label(), out)
+		failures = append(failures, fmt.Sprintf("$ %s%s\n%s\nERROR: %s", t.label(), cmd, out, err))
+	}
+	a.status.buildPassed = ran > 0 && len(failures) == 0
+	return strings.Join(failures, "\n\n"), len(failures) == 0
+}
+
+// testConfig is the `test:` section of zug.yaml.
+type testConfig struct {
+	Command string `yaml:"command"` // overrides autodetection
+}
+
+// testCommand resolves the configured or detected test command for t. Directories without
+// a manifest keep the historical behaviour of running pytest on a tests/ directory.
+func (a *AutonomousCodingAgent) testCommand(t checkTarget) string {
+	switch {
+	case t.root != nil && t.root.Test != "":
+		return t.root.Test
+	case t.root == nil && a.cfg.Test.Command != "":
+		return a.cfg.Test.Command
+	}
+	if cmd := toolchainCommand(a.detectToolchains(t.dir), func(tc toolchain) string { return tc.TestCmd }); cmd != "" {
+		return cmd
+	}
+	if info, err := a.fs.Stat(filepath.Join(t.dir, "tests")); err == nil && info.IsDir() {
+		return "pytest -q --maxfail=1 --disable-warnings tests/"
+	}
+	return ""
+}
+
+// runTests runs the project's tests. ran is false when no test command applies.
+func (a *AutonomousCodingAgent) runTests() (output string, ran, passed bool) {
+	var outputs []string
+	passed = true
+	for _, t := range a.checkTargets() {
+		cmd := a.testCommand(t)
+		if cmd == "" {
+			continue
+		}
+		ran = true
+		log.Printf("[agent] Running tests: %s%s\n", t.label(), cmd)
+		out, err := a.execShellIn(t, cmd)
+		if err == nil {
+			outputs = append(outputs, t.label()+out)
+			continue
+		}
+		var exitErr *exec.ExitError
+		if strings.Contains(cmd, "pytest") && errors.As(err, &exitErr) && exitErr.ExitCode() == 5 {
+			log.Println("[agent] 🤔 pytest collected no tests. Assuming success for now but please verify.")
+			outputs = append(outputs, t.label()+out)
+			continue
+		}
+		passed = false
+		outputs = append(outputs, fmt.Sprintf("$ %s%s\n%s\nERROR: %s", t.label(), cmd, out, err))
+	}
+	return strings.TrimSpace(strings.Join(outputs, "\n\n")), ran, ran && passed
+}
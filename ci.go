@@ -0,0 +1,404 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*──────────────────────────────
+  zug gen-ci (CI pipeline generation)
+  ─────────────────────────────*/
+
+// ciProviders maps each supported CI provider to the file its configuration goes to.
+var ciProviders = map[string]string{
+	"github-actions": ".github/workflows/ci.yml",
+}
+
+// ciLanguageCommands are the programs that tell which language a check needs, for
+// commands configured without a manifest to detect the toolchain from.
+var ciLanguageCommands = map[string][]string{
+	"go":     {"go", "gofmt", "golangci-lint"},
+	"rust":   {"cargo", "rustc"},
+	"node":   {"node", "npm", "npx", "pnpm", "yarn"},
+	"python": {"python", "python3", "pip", "pytest", "ruff", "flake8", "mypy"},
+}
+
+var (
+	ciPipTools = []string{"pytest", "ruff", "flake8", "mypy"} // installed with pip when a check runs them
+	ciJobID    = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+// ciStep is one step of a GitHub Actions job: an action with its inputs, or a command.
+type ciStep struct {
+	name, uses, run string
+	with            [][2]string
+}
+
+// ciJob checks one directory: the project, or one workspace root.
+type ciJob struct {
+	id, name string
+	dir      string // relative to the project, "." for the project itself
+	steps    []ciStep
+}
+
+// ciJobs builds a job for each place the build, test and lint checks run, with the
+// commands zug itself would run there and the steps that install what they need.
+func (a *AutonomousCodingAgent) ciJobs() []ciJob {
+	var jobs []ciJob
+	for _, t := range a.checkTargets() {
+		var checks []ciStep
+		for _, c := range []ciStep{{name: "Build", run: a.buildCommand(t)}, {name: "Test", run: a.testCommand(t)}, {name: "Lint", run: a.lintCommand(t)}} {
+			if c.run != "" {
+				checks = append(checks, c)
+			}
+		}
+		if len(checks) == 0 {
+			continue
+		}
+		job := ciJob{id: "check", name: "Build, test and lint", dir: "."}
+		if t.root != nil {
+			job.dir = filepath.ToSlash(filepath.Clean(t.root.Path))
+			job.id = "check-" + strings.Trim(ciJobID.ReplaceAllString(job.dir, "-"), "-")
+			job.name = job.dir
+		}
+		job.steps = append([]ciStep{{uses: "actions/checkout@v4"}}, a.ciSetup(t.dir, job.dir, checks)...)
+		job.steps = append(job.steps, checks...)
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// ciSetup lists the steps that install the languages, dependencies and tools the checks
+// of dir need. rel is dir relative to the project, where the setup actions look for files.
+func (a *AutonomousCodingAgent) ciSetup(dir, rel string, checks []ciStep) []ciStep {
+	has := func(name string) bool {
+		_, err := a.fs.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	var words []string
+	for _, c := range checks {
+		words = append(words, strings.FieldsFunc(c.run, func(r rune) bool { return unicode.IsSpace(r) || strings.ContainsRune(";&|()", r) })...)
+	}
+	runs := func(program string) bool { return slices.Contains(words, program) }
+	tcs := a.detectToolchains(dir)
+	needs := map[string]bool{}
+	for _, tc := range tcs {
+		switch tc.Language {
+		case "Go":
+			needs["go"] = true
+		case "Rust":
+			needs["rust"] = true
+		case "JavaScript", "TypeScript":
+			needs["node"] = true
+		case "Python":
+			needs["python"] = true
+		}
+	}
+	for lang, programs := range ciLanguageCommands {
+		if slices.ContainsFunc(programs, runs) {
+			needs[lang] = true
+		}
+	}
+	file := func(name string) string { return path.Join(rel, name) }
+
+	var steps []ciStep
+	if needs["go"] {
+		s := ciStep{uses: "actions/setup-go@v5", with: [][2]string{{"go-version", "stable"}}}
+		if has("go.mod") {
+			s.with = [][2]string{{"go-version-file", file("go.mod")}}
+			if rel != "." && has("go.sum") {
+				s.with = append(s.with, [2]string{"cache-dependency-path", file("go.sum")})
+			}
+		}
+		steps = append(steps, s)
+		if runs("golangci-lint") {
+			steps = append(steps, ciStep{name: "Install golangci-lint", run: "go install github.com/golangci/golangci-lint/v2/cmd/golangci-lint@latest"})
+		}
+	}
+	if needs["rust"] {
+		s := ciStep{uses: "dtolnay/rust-toolchain@stable"}
+		if runs("clippy") {
+			s.with = [][2]string{{"components", "clippy"}}
+		}
+		steps = append(steps, s)
+	}
+	if needs["node"] {
+		manager, install, lock := "npm", "npm install", ""
+		switch {
+		case has("pnpm-lock.yaml"):
+			manager, install, lock = "pnpm", "pnpm install --frozen-lockfile", "pnpm-lock.yaml"
+			steps = append(steps, ciStep{uses: "pnpm/action-setup@v4"})
+		case has("yarn.lock"):
+			manager, install, lock = "yarn", "yarn install --frozen-lockfile", "yarn.lock"
+		case has("package-lock.json"):
+			install, lock = "npm ci", "package-lock.json"
+		}
+		s := ciStep{uses: "actions/setup-node@v4", with: [][2]string{{"node-version", "lts/*"}}}
+		for _, f := range []string{".nvmrc", ".node-version"} {
+			if has(f) {
+				s.with = [][2]string{{"node-version-file", file(f)}}
+				break
+			}
+		}
+		if lock != "" {
+			s.with = append(s.with, [2]string{"cache", manager}, [2]string{"cache-dependency-path", file(lock)})
+		}
+		steps = append(steps, s)
+		if has("package.json") {
+			steps = append(steps, ciStep{name: "Install dependencies", run: install})
+		}
+	}
+	if needs["python"] {
+		s := ciStep{uses: "actions/setup-python@v5", with: [][2]string{{"python-version", "3.x"}}}
+		if has(".python-version") {
+			s.with = [][2]string{{"python-version-file", file(".python-version")}}
+		}
+		steps = append(steps, s)
+		install := []string{"python -m pip install --upgrade pip"}
+		poetry := slices.ContainsFunc(tcs, func(tc toolchain) bool { return tc.BuildTool == "poetry" })
+		if poetry {
+			install = append(install, "pip install poetry", "poetry config virtualenvs.create false", "poetry install")
+		}
+		for _, f := range []string{"requirements.txt", "requirements-dev.txt"} {
+			if has(f) {
+				install = append(install, "pip install -r "+f)
+			}
+		}
+		if !poetry && (has("pyproject.toml") || has("setup.py")) {
+			install = append(install, "pip install -e .")
+		}
+		var tools []string
+		for _, tool := range ciPipTools {
+			if runs(tool) {
+				tools = append(tools, tool)
+			}
+		}
+		if len(tools) > 0 {
+			install = append(install, "pip install "+strings.Join(tools, " "))
+		}
+		steps = append(steps, ciStep{name: "Install dependencies", run: strings.Join(install, "\n")})
+	}
+	return steps
+}
+
+// githubWorkflow renders the jobs as a GitHub Actions workflow run on pushes to branch
+// and on pull requests.
+func githubWorkflow(jobs []ciJob, branch string) string {
+	var sb strings.Builder
+	sb.WriteString("# CI workflow, generated by zug gen-ci from the project's build, test and lint\n# commands. Edit it freely: zug does not overwrite it without -force.\n")
+	fmt.Fprintf(&sb, "name: CI\n\non:\n  push:\n    branches: [%q]\n  pull_request:\n\npermissions:\n  contents: read\n\njobs:\n", branch)
+	for i, j := range jobs {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "  %s:\n    name: %q\n    runs-on: ubuntu-latest\n", j.id, j.name)
+		if j.dir != "." {
+			fmt.Fprintf(&sb, "    defaults:\n      run:\n        working-directory: %q\n", j.dir)
+		}
+		sb.WriteString("    steps:\n")
+		for _, s := range j.steps {
+			prefix := "      - "
+			if s.name != "" {
+				fmt.Fprintf(&sb, "%sname: %q\n", prefix, s.name)
+				prefix = "        "
+			}
+			if s.uses != "" {
+				fmt.Fprintf(&sb, "%suses: %s\n", prefix, s.uses)
+			}
+			if len(s.with) > 0 {
+				sb.WriteString("        with:\n")
+				for _, w := range s.with {
+					fmt.Fprintf(&sb, "          %s: %q\n", w[0], w[1])
+				}
+			}
+			if s.run != "" {
+				if !strings.Contains(s.run, "\n") {
+					fmt.Fprintf(&sb, "%srun: %q\n", prefix, s.run)
+					continue
+				}
+				fmt.Fprintf(&sb, "%srun: |\n", prefix)
+				for _, line := range strings.Split(s.run, "\n") {
+					fmt.Fprintf(&sb, "          %s\n", line)
+				}
+			}
+		}
+	}
+	return sb.String()
+}
+
+// checkWorkflow parses a GitHub Actions workflow and checks the structure every workflow
+// needs: triggers, and jobs with a runner and steps that each use an action or run a
+// command.
+func checkWorkflow(content string) error {
+	var wf struct {
+		On   yaml.Node `yaml:"on"`
+		Jobs map[string]struct {
+			RunsOn yaml.Node                `yaml:"runs-on"`
+			Steps  []map[string]interface{} `yaml:"steps"`
+		} `yaml:"jobs"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &wf); err != nil {
+		return fmt.Errorf("invalid YAML: %w", err)
+	}
+	if wf.On.Kind == 0 {
+		return fmt.Errorf("no `on:` triggers")
+	}
+	if len(wf.Jobs) == 0 {
+		return fmt.Errorf("no jobs")
+	}
+	ids := make([]string, 0, len(wf.Jobs))
+	for id := range wf.Jobs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		j := wf.Jobs[id]
+		if j.RunsOn.Kind == 0 {
+			return fmt.Errorf("job %s has no runs-on", id)
+		}
+		if len(j.Steps) == 0 {
+			return fmt.Errorf("job %s has no steps", id)
+		}
+		for i, s := range j.Steps {
+			_, uses := s["uses"]
+			_, run := s["run"]
+			if uses == run {
+				return fmt.Errorf("step %d of job %s must have exactly one of uses and run", i+1, id)
+			}
+		}
+	}
+	return nil
+}
+
+// ciValidators check a written workflow file more thoroughly than checkWorkflow, when
+// they are installed. {file} becomes the file's path.
+var ciValidators = []struct{ bin, cmd string }{
+	{"actionlint", "actionlint -no-color {file}"},
+	{"act", "act --dryrun -W {file}"},
+}
+
+// validateWorkflow checks the workflow at rel with checkWorkflow and every installed
+// validator, returning the names of the checks that passed.
+func (a *AutonomousCodingAgent) validateWorkflow(rel string) ([]string, error) {
+	raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWorkflow(string(raw)); err != nil {
+		return nil, err
+	}
+	passed := []string{"structure"}
+	for _, v := range ciValidators {
+		if !a.installed(v.bin) {
+			continue
+		}
+		cmd := strings.ReplaceAll(v.cmd, "{file}", shellQuote(rel))
+		log.Printf("[gen-ci] 🔎 Checking the workflow: %s\n", cmd)
+		if out, err := a.execShell(cmd); err != nil {
+			return passed, fmt.Errorf("%s: %w\n%s", v.bin, err, lastLines(out, 40))
+		}
+		passed = append(passed, v.bin)
+	}
+	return passed, nil
+}
+
+// defaultBranch is the branch pushes are checked on: the remote's default branch, else
+// the current one, else main.
+func defaultBranch(dir string) string {
+	if ref, err := gitCmd(dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD"); err == nil {
+		return strings.TrimPrefix(ref, "origin/")
+	}
+	if b, err := gitCmd(dir, "rev-parse", "--abbrev-ref", "HEAD"); err == nil && b != "HEAD" {
+		return b
+	}
+	return "main"
+}
+
+func genCICommand(fs *flag.FlagSet) func() {
+	dir := fs.String("dir", ".", "project directory")
+	provider := fs.String("provider", "github-actions", "CI provider to write the configuration for")
+	out := fs.String("o", "", "file to write, relative to the project (default: .github/workflows/ci.yml); - prints it instead")
+	force := fs.Bool("force", false, "overwrite an existing file")
+	branch := fs.String("branch", "", "branch whose pushes are checked (default: the repository's default branch)")
+	return func() {
+		file, ok := ciProviders[*provider]
+		if !ok {
+			names := make([]string, 0, len(ciProviders))
+			for name := range ciProviders {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			log.Fatalf("❌ Unknown CI provider %q; supported: %s", *provider, strings.Join(names, ", "))
+		}
+		root, err := filepath.Abs(*dir)
+		if err != nil {
+			log.Fatalf("FATAL: Could not resolve project directory %s: %v", *dir, err)
+		}
+		cfg, err := loadConfig(root)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		a := &AutonomousCodingAgent{projectDir: root, cfg: cfg, fs: osFS{}, changes: map[string]*fileChange{}}
+		jobs := a.ciJobs()
+		if len(jobs) == 0 {
+			log.Fatalf("❌ No build, test or lint command found for %s; set them in %s (zug init writes a starter one).", root, configFileName)
+		}
+		if *branch == "" {
+			*branch = defaultBranch(root)
+		}
+		content := githubWorkflow(jobs, *branch)
+
+		if *out == "-" {
+			if err := checkWorkflow(content); err != nil {
+				log.Fatalf("❌ The generated workflow is invalid: %v", err)
+			}
+			fmt.Print(content)
+			return
+		}
+		if *out != "" {
+			file = filepath.ToSlash(filepath.Clean(*out))
+		}
+		full := filepath.Join(root, filepath.FromSlash(file))
+		if fileExists(full) && !*force {
+			log.Fatalf("❌ %s exists; use -force to overwrite it.", file)
+		}
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			log.Fatalf("❌ Could not create %s: %v", filepath.Dir(full), err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			log.Fatalf("❌ Could not write %s: %v", full, err)
+		}
+		for _, j := range jobs {
+			var names []string
+			for _, s := range j.steps {
+				if s.name == "Build" || s.name == "Test" || s.name == "Lint" {
+					names = append(names, fmt.Sprintf("%s `%s`", strings.ToLower(s.name), s.run))
+				}
+			}
+			log.Printf("[gen-ci] 🧱 Job %s: %s\n", j.id, strings.Join(names, ", "))
+		}
+		log.Printf("[gen-ci] 📝 Wrote %s\n", full)
+
+		passed, err := a.validateWorkflow(file)
+		if err != nil {
+			log.Printf("[gen-ci] ❌ The workflow does not pass validation: %v\n", err)
+			os.Exit(1)
+		}
+		if len(passed) == 1 {
+			log.Println("[gen-ci] ✅ The workflow is well-formed (install actionlint or act to check it further).")
+		} else {
+			log.Printf("[gen-ci] ✅ The workflow passes %s.\n", strings.Join(passed[1:], " and "))
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGitHubWorkflowForMonorepo(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		"services/api/go.mod":            "module api\n",
+		"services/api/go.sum":            "",
+		"services/web/package.json":      `{"scripts": {"test": "vitest run", "lint": "eslint ."}}`,
+		"services/web/package-lock.json": "{}",
+		"tools/check.sh":                 "",
+	})
+	a.cfg.Roots = []rootConfig{
+		{Path: "services/api", Lint: "go vet ./..."},
+		{Path: "services/web"},
+		{Path: "tools", Test: "python -m pytest -q"},
+	}
+	jobs := a.ciJobs()
+	if len(jobs) != 3 {
+		t.Fatalf("jobs = %+v", jobs)
+	}
+	wf := githubWorkflow(jobs, "main")
+	if err := checkWorkflow(wf); err != nil {
+		t.Fatalf("generated workflow is invalid: %v\n%s", err, wf)
+	}
+	for _, want := range []string{
+		"  check-services-api:\n",
+		`working-directory: "services/api"`,
+		`go-version-file: "services/api/go.mod"`,
+		`cache-dependency-path: "services/api/go.sum"`,
+		`run: "go test ./..."`,
+		`run: "go vet ./..."`,
+		`cache-dependency-path: "services/web/package-lock.json"`,
+		`run: "npm ci"`,
+		`run: "npm run lint"`,
+		"uses: actions/setup-python@v5",
+		"          pip install pytest\n",
+	} {
+		assertContains(t, wf, want)
+	}
+	if strings.Contains(wf, "golangci-lint") || strings.Contains(wf, "pip install -e") {
+		t.Errorf("unexpected install steps:\n%s", wf)
+	}
+}
+
+func TestCheckWorkflowRejectsBrokenSteps(t *testing.T) {
+	for _, wf := range []string{
+		"jobs:\n  a:\n    runs-on: ubuntu-latest\n    steps:\n      - run: make\n",
+		"on: push\njobs:\n  a:\n    steps:\n      - run: make\n",
+		"on: push\njobs:\n  a:\n    runs-on: ubuntu-latest\n    steps:\n      - name: nothing\n",
+		"on: push\njobs: [\n",
+	} {
+		if err := checkWorkflow(wf); err == nil {
+			t.Errorf("checkWorkflow accepted:\n%s", wf)
+		}
+	}
+}
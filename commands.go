@@ -0,0 +1,372 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*──────────────────────────────
+  Subcommands
+  ─────────────────────────────*/
+
+// command is a single `zug <name>` entry point. setup registers the command's flags and
+// returns the function that runs it once they are parsed, so the completion scripts and
+// man pages can list the flags without running the command.
+type command struct {
+	name       string
+	positional string // usage of the positional arguments
+	summary    string
+	setup      func(fs *flag.FlagSet) func()
+}
+
+// flagSet returns the command's flag set with every flag registered.
+func (c command) flagSet() (*flag.FlagSet, func()) {
+	fs := newFlagSet(c.name, c.positional)
+	return fs, c.setup(fs)
+}
+
+// run parses args and runs the command.
+func (c command) run(args []string) {
+	fs, run := c.flagSet()
+	fs.Parse(args)
+	run()
+}
+
+// findCommand returns the subcommand called name.
+func findCommand(name string) (command, bool) {
+	for _, c := range commands() {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// commands lists every subcommand. Invoking zug without one falls back to "run".
+func commands() []command {
+	return []command{
+		{"run", "\"<describe your coding task>\" [model_name]", "run a coding task (default when no subcommand is given)", runCommand},
+		{"refactor", "\"<refactoring goal>\" [model_name]", "restructure code without changing behavior, held to the test suite as it passes now", refactorCommand},
+		{"plan", "\"<describe your coding task>\" [model_name]", "explore the project read-only and write an implementation plan", planCommand},
+		{"ask", "\"<question about the project>\" [model_name]", "answer a question about the project using read-only tools", askCommand},
+		{"batch", "tasks.yaml", "run every task listed in a YAML file and write a consolidated report", batchCommand},
+		{"compare", "-models a,b \"<describe your coding task>\"", "run one task with several models in separate worktrees and report the results side by side", compareCommand},
+		{"eval", "<fixtures_dir>", "run a suite of task fixtures against models or configs and compare pass rates, turns, tokens and cost", evalCommand},
+		{"resume", "[run]", "continue a run that stopped unexpectedly (crash, killed process, power loss) from its last saved step", resumeCommand},
+		{"fork", "<session>", "branch a saved session to try a different approach from a chosen point", forkCommand},
+		{"dockerize", "[\"<additional requirements>\"] [model_name]", "write a Dockerfile (and docker-compose.yml) for the project, verified by building the image and an optional smoke test", dockerizeCommand},
+		{"document", "<path|package> [model_name]", "add doc comments to the public symbols of a file or package, checked by the doc linter, without changing code", documentCommand},
+		{"explain", "<path|symbol> [model_name]", "explain a file, directory or symbol for someone new to the code, using read-only tools", explainCommand},
+		{"review", "[model_name]", "review the diff against a base branch and report findings as text, JSON or SARIF", reviewCommand},
+		{"commit", "[model_name]", "commit the staged changes with a message written by the model", commitCommand},
+		{"security", "[model_name]", "run the security scanners and list their findings, or have the agent fix them with -fix", securityCommand},
+		{"upgrade-deps", "[model_name]", "upgrade dependencies one group at a time, fixing breakage or rolling back", upgradeDepsCommand},
+		{"history", "", "list earlier runs and the files they changed, or print a checkpoint", historyCommand},
+		{"audit", "", "list every file the agent created, changed or deleted, and the approval decisions", auditCommand},
+		{"telemetry", "[status|on|local|off|show|reset]", "show, turn on (locally or with an endpoint) or off the opt-in anonymous usage telemetry, and summarize the recorded runs", telemetryCommand},
+		{"keys", "", "show per-key request and token usage when several API keys are configured", keysCommand},
+		{"knowledge", "", "list, search, add to and prune the cross-project knowledge base", knowledgeCommand},
+		{"overlay", "[id]", "list the copy-on-write overlays of zug run --overlay, and inspect, export, apply or discard their changes", overlayCommand},
+		{"completion", "bash|zsh|fish|powershell", "print a shell completion script for bash, zsh, fish or powershell", completionCommand},
+		{"man", "", "write man pages for zug and each of its commands", manCommand},
+		{"doctor", "", "check the API key, model, git, sandbox, disk space and test command, with fixes for each problem", doctorCommand},
+		{"env-sync", "", "add the environment variables the code reads to .env.example, without reading any real .env file", envSyncCommand},
+		{"gen-ci", "", "write a CI workflow that runs the project's build, test and lint commands, checked with actionlint or act when installed", genCICommand},
+		{"init", "", "inspect the project and write a starter zug.yaml and ZUG.md with the detected build and test commands", initCommand},
+		{"new", "-template <name> <dir> \"<describe the project>\" [model_name]", "create a project from a scaffolding template and have the agent customize it to a description", newCommand},
+		{"fix", "<git-url> \"<describe your coding task>\"", "clone a git repository, run a task in it and open a pull request with the result", fixCommand},
+		{"daemon", "", "serve a local task queue API that runs tasks in isolated git worktrees", daemonCommand},
+	}
+}
+
+func printUsage() {
+	fmt.Printf("Usage: %s [command] [flags] \"<describe your coding task>\" [model_name]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands() {
+		fmt.Printf("  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Println("\nExample: go run . \"Create a Python script...\"")
+	fmt.Println("Example with model: go run . \"Create a Python script...\" gpt-4-turbo")
+	fmt.Println("Example with review: go run . run --review \"Create a Python script...\"")
+	fmt.Println("You can also set the OPENAI_MODEL environment variable.")
+}
+
+// commonFlags are shared by every subcommand that talks to the model.
+type commonFlags struct {
+	dir     string
+	model   string
+	cache   string
+	notify  bool
+	remote  string
+	sandbox string
+	yes     bool
+
+	toolProtocol  string
+	parallelTools bool
+	stream        bool
+	streamConfirm bool
+
+	sampling samplingFlags
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.dir, "dir", "ai_coder_project", "project directory the agent works in (created if missing)")
+	fs.StringVar(&c.model, "model", "", "model name (the OPENAI_MODEL environment variable takes precedence)")
+	fs.StringVar(&c.cache, "cache", "", `replay identical model requests from ~/.zug/state.db: "on" stores new responses, "replay" never calls the API (default $ZUG_CACHE)`)
+	fs.Var(&c.sampling.temperature, "temperature", "sampling temperature for every phase (default: 0.1 for coding, 0.4 for planning)")
+	fs.Var(&c.sampling.topP, "top-p", "nucleus sampling probability mass (default: the API's)")
+	fs.IntVar(&c.sampling.maxOutputTokens, "max-output-tokens", 0, "output token limit per model reply (default: 4096 for coding and planning, 16000 for reasoning models)")
+	fs.StringVar(&c.remote, "remote", "", "work on a project on another host over ssh, e.g. user@build-box:/srv/app (zug.yaml and state stay in -dir)")
+	fs.StringVar(&c.sandbox, "sandbox", "", `isolate shell commands: "ns" runs them with bubblewrap (Linux only; bwrap must be installed, there is no fallback), with only the project dir writable and no network unless network: in zug.yaml allows it`)
+	fs.BoolVar(&c.yes, "yes", false, "approve every tool call without asking (including permissions set to ask) and record each one in the audit log")
+	fs.StringVar(&c.toolProtocol, "tool-protocol", toolProtocolNative, `how the model calls tools: "native" function calling, or "text" THOUGHT/ACTION/ARGS blocks for local models without it`)
+	fs.BoolVar(&c.parallelTools, "parallel-tool-calls", true, "let the model request several tool calls in one reply; false makes it act one call at a time")
+	fs.BoolVar(&c.stream, "stream", false, "stream model replies, checking each tool call's tool and path while its arguments arrive")
+	fs.BoolVar(&c.streamConfirm, "stream-confirm", false, "with -stream, ask for approval of an \"ask\" tool call as soon as its path is known, while the rest of it arrives")
+	fs.BoolVar(&c.notify, "notify", false, "show a desktop notification when the run finishes, fails or needs approval (other targets: notify in zug.yaml)")
+}
+
+// newAgent resolves the model, API key and project directory and builds the agent.
+// argModel is the legacy positional model argument, used when -model is not set.
+func (c *commonFlags) newAgent(argModel string) *AutonomousCodingAgent {
+	modelName := c.model
+	if modelName == "" {
+		modelName = strings.TrimSpace(argModel)
+	}
+
+	// Prioritize environment variable for model selection
+	envModel := os.Getenv("OPENAI_MODEL")
+	if envModel != "" {
+		modelName = envModel
+		log.Printf("[agent] Using model from OPENAI_MODEL environment variable: %s\n", modelName)
+	} else if modelName != "" {
+		log.Printf("[agent] Using model from command line argument: %s\n", modelName)
+	}
+	// If modelName is still empty here, NewAgent will use the default (e.g., openai.GPT4o)
+
+	keys, err := apiKeys()
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	projectFullPath, err := filepath.Abs(c.dir)
+	if err != nil {
+		log.Fatalf("FATAL: Could not resolve project directory %s: %v", c.dir, err)
+	}
+	log.Printf("[agent] Project directory will be: %s\n", projectFullPath)
+
+	agent := NewAgent(keys, projectFullPath, modelName)
+	if c.remote != "" {
+		t, err := dialRemote(c.remote)
+		if err != nil {
+			log.Fatalf("FATAL: -remote: %v", err)
+		}
+		agent.useRemote(t)
+	}
+	if p := agent.cfg.Network.Policy; p == netDeny || p == netAllowlist {
+		switch {
+		case c.remote != "":
+			log.Fatalf("FATAL: network: %s in %s cannot be enforced for a remote project", p, configFileName)
+		case c.sandbox == sandboxOff:
+			log.Fatalf("FATAL: network: %s in %s is enforced by the sandbox and cannot be combined with --sandbox %s", p, configFileName, sandboxOff)
+		case c.sandbox == "":
+			log.Printf("[agent] network: %s in %s: running shell commands with --sandbox %s.\n", p, configFileName, sandboxNS)
+			c.sandbox = sandboxNS
+		}
+	}
+	if err := validSandbox(c.sandbox); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	if c.remote != "" && c.sandbox == sandboxNS {
+		log.Fatalf("FATAL: --sandbox %s is not available with --remote", sandboxNS)
+	}
+	agent.sandbox = c.sandbox
+	agent.logSandbox()
+	if c.yes {
+		agent.autoApprove = true
+		log.Printf("[agent] ⚠️ --yes: tool calls are approved without asking; every approval is recorded in %s.\n", filepath.Join(zugDirName, stateFileName))
+	}
+	if c.cache != "" {
+		if err := validCacheMode(c.cache); err != nil {
+			log.Fatalf("FATAL: -cache: %v", err)
+		}
+		agent.cacheMode = c.cache
+	}
+	if c.notify {
+		agent.cfg.Notify.Desktop = true
+	}
+	switch c.toolProtocol {
+	case toolProtocolNative, "":
+	case toolProtocolText:
+		agent.textTools = true
+		log.Println("[agent] Tools are called through the text protocol (THOUGHT/ACTION/ARGS).")
+	default:
+		log.Fatalf("FATAL: -tool-protocol: unknown protocol %q (use %s or %s)", c.toolProtocol, toolProtocolNative, toolProtocolText)
+	}
+	if c.streamConfirm && !c.stream {
+		log.Fatalf("FATAL: -stream-confirm needs -stream")
+	}
+	agent.stream, agent.streamConfirm = c.stream, c.streamConfirm
+	agent.serialTools = !c.parallelTools
+	if err := c.sampling.validate(); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	agent.samplingFlags = c.sampling
+	return agent
+}
+
+// newFlagSet builds a flag set whose usage line describes the positional arguments.
+func newFlagSet(name, positional string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage: %s %s [flags] %s\n", os.Args[0], name, positional)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// arg returns the i-th positional argument or "".
+func arg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+/*──────────────────────────────
+  zug run
+  ─────────────────────────────*/
+
+func runCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	review := fs.Bool("review", false, "run a reviewer pass over the final diff and one more fix iteration")
+	planFile := fs.String("plan", "", "follow an approved plan file written by the plan command")
+	var images stringList
+	fs.Var(&images, "image", "attach a screenshot or diagram (file or URL) for vision-capable models; repeatable")
+	session := fs.Int64("session", 0, "continue the conversation of this session (see zug history and zug fork)")
+	prBody := fs.String("pr-body", "", "on success, write a pull request description (problem, approach, testing) to this file")
+	changelog := fs.Bool("changelog", false, "on success, add an entry under Unreleased in the project's "+changelogFileName)
+	timeout := fs.Duration("timeout", 0, "stop the run cleanly after this much wall-clock time, e.g. 30m (model requests and commands in flight are cancelled; exit code 8)")
+	parallel := fs.String("parallel", "", "run the tasks of a batch file (see zug batch) at the same time, each in its own git worktree and branch, and write a merged report")
+	jobs := fs.Int("jobs", 4, "with -parallel: number of tasks run at the same time")
+	maxRequests := fs.Int("max-requests", 0, "with -parallel: model requests in flight at once over all tasks (default: -jobs)")
+	report := fs.String("report", "zug-parallel-report.md", "with -parallel: file to write the merged report to")
+	useOverlay := fs.Bool("overlay", false, "work in a copy-on-write copy of the project and leave the project untouched; see zug overlay to inspect, export, apply or discard the changes")
+	emitPatch := fs.String("emit-patch", "", "work in an overlay (see -overlay) and write the changes, with the test results, as a patch to this file; the project is not changed")
+	repoSpec := fs.String("repo", "", "work on a GitHub or GitLab repository through its API, without a local clone, e.g. github:owner/name@branch or https://gitlab.com/group/project (token in GITHUB_TOKEN or GITLAB_TOKEN); on success the changes are opened as a pull request")
+	security := fs.Bool("security", false, "run the installed security scanners (gosec, bandit, npm audit, trivy) after changes and fix new findings (also security.enabled in zug.yaml)")
+	docker := fs.Bool("docker", false, "once the run is otherwise done, build the project's Dockerfile (docker.file, or docker.compose) and fix the build until it passes (also docker.enabled in zug.yaml)")
+	mutation := fs.Bool("mutation", false, "once the tests pass, run a mutation tester (go-mutesting, mutmut) on the changed code and strengthen the tests against surviving mutants (also mutation.enabled in zug.yaml)")
+	return func() {
+		if *parallel != "" {
+			trapInterrupts()
+			os.Exit(runParallel(cf, *parallel, parallelOptions{jobs: *jobs, maxRequests: *maxRequests, timeout: *timeout, report: *report}))
+		}
+		task := strings.TrimSpace(arg(fs.Args(), 0))
+		if task == "" && *planFile == "" {
+			printUsage()
+			os.Exit(1)
+		}
+		if *planFile != "" {
+			raw, err := os.ReadFile(*planFile)
+			if err != nil {
+				log.Fatalf("FATAL: Could not read plan %s: %v", *planFile, err)
+			}
+			task = planTask(task, string(raw))
+		}
+		imageParts, err := loadImageParts(images)
+		if err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		log.Printf("[agent] Initial task from command line: %s\n", task)
+		var repo *apiRepo
+		if *repoSpec != "" {
+			if cf.remote != "" || *useOverlay || *emitPatch != "" {
+				log.Fatalf("FATAL: --repo cannot be combined with --remote, --overlay or --emit-patch")
+			}
+			if repo, err = openRepo(*repoSpec); err != nil {
+				log.Fatalf("FATAL: --repo: %v", err)
+			}
+			if cf.dir, err = repo.stateDir(); err != nil {
+				log.Fatalf("FATAL: --repo: %v", err)
+			}
+		}
+		var ov *overlay
+		if *useOverlay || *emitPatch != "" {
+			if cf.remote != "" {
+				log.Fatalf("FATAL: --overlay and --emit-patch are not available with --remote")
+			}
+			if ov, err = createOverlay(cf.dir, task); err != nil {
+				log.Fatalf("FATAL: --overlay: %v", err)
+			}
+			log.Printf("[agent] 🗂️ Working in overlay %s (%s of %s); the project is not changed.\n", ov.ID, ov.Method, ov.Project)
+			cf.dir = ov.tree()
+		}
+
+		agent := cf.newAgent(arg(fs.Args(), 1))
+		if repo != nil {
+			if err := agent.useRepo(repo); err != nil {
+				log.Fatalf("FATAL: --repo: %v", err)
+			}
+		}
+		agent.review = *review
+		agent.prBody, agent.changelog = *prBody, *changelog
+		if *security {
+			agent.cfg.Security.Enabled = true
+		}
+		if *mutation {
+			agent.cfg.Mutation.Enabled = true
+		}
+		if *docker {
+			agent.cfg.Docker.Enabled = true
+		}
+		agent.images = imageParts
+		agent.enableSteering()
+		if *session != 0 {
+			if err := agent.resumeSession(*session); err != nil {
+				log.Fatalf("FATAL: %v", err)
+			}
+		}
+
+		if *timeout > 0 {
+			cancel := agent.setTimeout(*timeout)
+			defer cancel()
+		}
+		trapInterrupts()
+		err = agent.feedbackLoop(task)
+		if repo != nil {
+			err = agent.submitRepo(task, err)
+		}
+		if errors.Is(err, errInterrupted) || errors.Is(err, errTimeout) {
+			killChildProcesses()
+			if agent.runID != 0 {
+				log.Printf("[agent] ⏸️ Stopped (%v). Resume with: zug run --dir %s --session %d \"<what to do next>\"\n", err, cf.dir, agent.runID)
+			}
+		} else if err != nil {
+			log.Printf("[agent] ❌ Task did not complete: %v\n", err)
+		}
+		if *emitPatch != "" {
+			if n, perr := agent.writeRunPatch(ov, *emitPatch, task, err); perr != nil {
+				log.Printf("[agent] ❌ --emit-patch: %v; the changes are kept in overlay %s.\n", perr, ov.ID)
+			} else {
+				log.Printf("[agent] 🩹 Wrote %d changed file(s) to %s; apply with git apply %s.\n", n, *emitPatch, *emitPatch)
+				if !*useOverlay {
+					ov.discard()
+				}
+			}
+		}
+		if ov != nil && *useOverlay {
+			log.Printf("[agent] 🗂️ %s\n", overlayHint(ov))
+		}
+		agent.notifyRunEnd(task, err)
+
+		code := agent.exitCode(err)
+		log.Printf("[agent] 🏁 Autonomous Coding Agent finished (exit code %d).\n", code)
+		os.Exit(code)
+	}
+}
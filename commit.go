@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Commit messages
+  ─────────────────────────────*/
+
+const commitDiffLimit = 40000 // bytes of staged diff shown to the model
+
+// commitPrompt asks for a Conventional Commits message and nothing else.
+func commitPrompt() openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleSystem,
+		Content: `You write git commit messages in the Conventional Commits format. You receive the staged diff and, when known, the task the change was made for. Reply with the commit message only, no code fences or commentary:
+- a subject line "type(scope): summary" of at most 72 characters, where type is one of feat, fix, refactor, perf, test, docs, build, ci, chore or style, the scope is optional, and the summary is imperative and lowercase without a trailing period;
+- for anything beyond a trivial change, a blank line and a short body wrapped at 72 characters that explains what changed and why, not how;
+- "BREAKING CHANGE: ..." as the last paragraph only if the diff breaks an interface users rely on.`,
+	}
+}
+
+// conventionalSubject matches a subject line in the Conventional Commits format.
+var conventionalSubject = regexp.MustCompile(`^[a-z]+(\([^)]+\))?!?: \S`)
+
+// commitMessage has the model write a commit message for diff. task, when set, is what
+// the change was made for.
+func (a *AutonomousCodingAgent) commitMessage(diff, task string) (string, error) {
+	if strings.TrimSpace(diff) == "" {
+		return "", fmt.Errorf("nothing to commit")
+	}
+	if len(diff) > commitDiffLimit {
+		diff = fmt.Sprintf("%s\n[... diff truncated, %d more bytes]", diff[:validCut(diff, commitDiffLimit)], len(diff)-commitDiffLimit)
+	}
+	content := "Staged diff:\n" + diff
+	if task = strings.TrimSpace(task); task != "" {
+		content = fmt.Sprintf("Task:\n%s\n\n%s", task, content)
+	}
+	req, err := a.chatRequest([]openai.ChatCompletionMessage{
+		commitPrompt(),
+		{Role: openai.ChatMessageRoleUser, Content: content},
+	}, phaseCommit, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := a.createChatCompletion(req)
+	if err != nil {
+		return "", fmt.Errorf("commit message request failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("received an empty Choices array from OpenAI for the commit message")
+	}
+	msg := stripCodeFence(resp.Choices[0].Message.Content)
+	if msg == "" {
+		return "", fmt.Errorf("the model returned an empty commit message")
+	}
+	if !conventionalSubject.MatchString(msg) {
+		log.Printf("[agent] ⚠️ The generated commit subject is not in the Conventional Commits format: %s\n", firstLine(msg))
+	}
+	return msg, nil
+}
+
+// autoCommitMessage writes the message for a commit zug makes on its own (batch
+// branches, daemon worktrees) from the changes staged in dir. It falls back to fallback
+// when the model cannot be reached, so the commit is never lost.
+func (a *AutonomousCodingAgent) autoCommitMessage(dir, task, fallback string) string {
+	diff, err := gitCmd(dir, "diff", "--cached", "--no-color")
+	if err == nil {
+		var msg string
+		if msg, err = a.commitMessage(diff, task); err == nil {
+			return msg
+		}
+	}
+	log.Printf("[agent] ⚠️ Could not write a commit message, using %q: %v\n", fallback, err)
+	return fallback
+}
+
+/*──────────────────────────────
+  zug commit
+  ─────────────────────────────*/
+
+func commitCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	all := fs.Bool("a", false, "stage all changes to tracked files first, like git commit -a")
+	edit := fs.Bool("edit", false, "open the message in the git editor before committing")
+	dryRun := fs.Bool("dry-run", false, "print the message without committing")
+	return func() {
+		if *all {
+			if _, err := gitCmd(cf.dir, "add", "-u"); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		}
+		diff, err := gitCmd(cf.dir, "diff", "--cached", "--no-color")
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if diff == "" {
+			log.Fatalf("❌ Nothing is staged in %s; stage changes with git add, or pass -a.", cf.dir)
+		}
+
+		agent := cf.newAgent(arg(fs.Args(), 0))
+		msg, err := agent.commitMessage(diff, "")
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if *dryRun {
+			fmt.Println(msg)
+			return
+		}
+
+		gitArgs := []string{"commit", "-m", msg}
+		if *edit {
+			gitArgs = append(gitArgs, "--edit")
+		}
+		c := exec.Command("git", gitArgs...)
+		c.Dir = cf.dir
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := c.Run(); err != nil {
+			log.Fatalf("❌ git commit: %v", err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  zug compare (A/B models)
+  ─────────────────────────────*/
+
+const compareDiffLimit = 40000 // bytes of each model's diff in the report
+
+// compareResult is what one model did with the task in its own worktree.
+type compareResult struct {
+	model    string
+	branch   string // holds the model's commit; "" when it changed nothing
+	err      error
+	exitCode int
+	tests    string
+	turns    int
+	tokens   int
+	cost     float64
+	priced   bool
+	duration time.Duration
+	files    []pathChange
+	diff     string
+}
+
+var unsafeBranchChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// compareModel runs task with model in a fresh worktree of the repository at top and
+// commits the result on a branch of its own. sub is the project dir inside the repository.
+func compareModel(cf commonFlags, top, sub, stamp, task, model string) (res compareResult) {
+	res = compareResult{model: model, exitCode: exitFailed}
+	start := time.Now()
+	defer func() { res.duration = time.Since(start) }()
+
+	wt, err := os.MkdirTemp("", "zug-compare-*")
+	if err != nil {
+		res.err = fmt.Errorf("cannot create worktree dir: %w", err)
+		return res
+	}
+	res.branch = fmt.Sprintf("zug/compare-%s-%s", stamp, strings.Trim(unsafeBranchChars.ReplaceAllString(model, "-"), "-"))
+	if _, err := gitCmd(top, "worktree", "add", "-b", res.branch, wt, "HEAD"); err != nil {
+		os.RemoveAll(wt)
+		res.err, res.branch = err, ""
+		return res
+	}
+	defer func() {
+		if _, err := gitCmd(top, "worktree", "remove", "--force", wt); err != nil {
+			log.Printf("[compare] Warning: could not remove worktree %s: %v\n", wt, err)
+		}
+		if len(res.files) == 0 {
+			gitCmd(top, "branch", "-D", res.branch)
+			res.branch = ""
+		}
+	}()
+
+	cf.dir, cf.model = filepath.Join(wt, sub), model
+	agent := cf.newAgent("")
+	res.err = agent.feedbackLoop(task)
+	res.exitCode = agent.exitCode(res.err)
+	res.turns = agent.status.turn
+	res.tokens = agent.usage.PromptTokens + agent.usage.CompletionTokens
+	if price, ok := priceFor(agent.model); ok {
+		res.cost, res.priced = price.cost(agent.usage.PromptTokens, agent.usage.CompletionTokens), true
+	}
+	switch {
+	case agent.status.testsRan && agent.status.testsPassed:
+		res.tests = "✅ passing"
+	case agent.status.testsRan:
+		res.tests = "❌ failing"
+	case agent.status.buildPassed:
+		res.tests = "none (build passes)"
+	default:
+		res.tests = "not run"
+	}
+	res.files = agent.netChanges()
+	res.diff = agent.changesDiff()
+
+	if len(res.files) > 0 {
+		if _, err := gitCmd(wt, "add", "-A"); err == nil {
+			msg := fmt.Sprintf("zug compare (%s): %s", model, firstLine(task))
+			if _, err := gitCmd(wt, "-c", "user.name=zug", "-c", "user.email=zug@localhost", "commit", "-q", "-m", msg); err != nil {
+				log.Printf("[compare] Warning: could not commit the changes of %s: %v\n", model, err)
+			}
+		}
+	}
+	return res
+}
+
+// compareReport renders the results side by side: one table row per model, then each
+// model's diff.
+func compareReport(task string, results []compareResult) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# zug compare report\n\n**Task:** %s  \n**Finished:** %s\n\n", task, time.Now().Format(time.RFC3339))
+	sb.WriteString("| Model | Outcome | Tests | Files | Lines | Turns | Tokens | Cost | Time | Branch |\n|---|---|---|---|---|---|---|---|---|---|\n")
+	for _, r := range results {
+		outcome := "✅ done"
+		if r.err != nil {
+			outcome = fmt.Sprintf("❌ exit %d", r.exitCode)
+		} else if r.exitCode != exitVerified {
+			outcome = "⚠️ unverified"
+		}
+		var added, removed int
+		for _, f := range r.files {
+			added, removed = added+f.added, removed+f.removed
+		}
+		cost := "n/a"
+		if r.priced {
+			cost = fmt.Sprintf("$%.2f", r.cost)
+		}
+		branch := "—"
+		if r.branch != "" {
+			branch = "`" + r.branch + "`"
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %s | %d | +%d −%d | %d | %s | %s | %s | %s |\n", r.model, outcome, r.tests, len(r.files),
+			added, removed, r.turns, compactCount(r.tokens), cost, r.duration.Round(time.Second), branch)
+	}
+	for _, r := range results {
+		fmt.Fprintf(&sb, "\n## %s\n\n", r.model)
+		if r.err != nil {
+			fmt.Fprintf(&sb, "**Error:** %v\n\n", r.err)
+		}
+		if r.diff == "" {
+			sb.WriteString("No changes.\n")
+			continue
+		}
+		diff := r.diff
+		if len(diff) > compareDiffLimit {
+			diff = diff[:validCut(diff, compareDiffLimit)] + "\n[... diff truncated; see the branch]"
+		}
+		fmt.Fprintf(&sb, "```diff\n%s\n```\n", strings.TrimRight(diff, "\n"))
+	}
+	return sb.String()
+}
+
+func compareCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	models := fs.String("models", "", "comma-separated models to run the task with, e.g. gpt-4o,gpt-4o-mini")
+	report := fs.String("report", "zug-compare-report.md", "file to write the side-by-side report to")
+	return func() {
+		task := strings.TrimSpace(arg(fs.Args(), 0))
+		var names []string
+		for _, m := range strings.Split(*models, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				names = append(names, m)
+			}
+		}
+		if task == "" || len(names) < 2 {
+			fs.Usage()
+			os.Exit(1)
+		}
+		top, err := gitCmd(cf.dir, "rev-parse", "--show-toplevel")
+		if err != nil {
+			log.Fatalf("❌ zug compare runs every model in a git worktree, so %s must be a git repository: %v", cf.dir, err)
+		}
+		resolved, _ := filepath.EvalSymlinks(cf.dir)
+		sub, _ := filepath.Rel(top, resolved)
+		// Each run names its model; OPENAI_MODEL must not override it.
+		os.Unsetenv("OPENAI_MODEL")
+
+		stamp := time.Now().Format("20060102-150405")
+		var results []compareResult
+		for i, m := range names {
+			log.Printf("[compare] ▶️ Model %d/%d: %s\n", i+1, len(names), m)
+			r := compareModel(cf, top, sub, stamp, task, m)
+			log.Printf("[compare] ⏹️ %s finished in %s (exit code %d, %d file(s) changed)\n", m, r.duration.Round(time.Second), r.exitCode, len(r.files))
+			results = append(results, r)
+		}
+
+		out := compareReport(task, results)
+		fmt.Println(out)
+		if err := os.WriteFile(*report, []byte(out), 0o644); err != nil {
+			log.Fatalf("❌ Could not write report to %s: %v", *report, err)
+		}
+		log.Printf("[compare] Report written to %s; check out a branch to keep a result.\n", *report)
+	}
+}
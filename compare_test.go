@@ -0,0 +1,22 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompareReport(t *testing.T) {
+	report := compareReport("add a health endpoint", []compareResult{
+		{model: "gpt-4o", branch: "zug/compare-1-gpt-4o", exitCode: exitVerified, tests: "✅ passing", turns: 2, tokens: 12000, cost: 0.05, priced: true,
+			files: []pathChange{{path: "server.go", action: "modified", added: 9, removed: 1}}, diff: "--- a/server.go\n+++ b/server.go\n"},
+		{model: "local-llama", err: errors.New("model interaction failed"), exitCode: exitFailed, tests: "not run", turns: 1, tokens: 900},
+	})
+	for _, want := range []string{
+		"| gpt-4o | ✅ done | ✅ passing | 1 | +9 −1 | 2 | 12.0k | $0.05 | 0s | `zug/compare-1-gpt-4o` |",
+		"| local-llama | ❌ exit 1 | not run | 0 | +0 −0 | 1 | 900 | n/a | 0s | — |",
+		"## gpt-4o\n\n```diff\n--- a/server.go\n+++ b/server.go\n```",
+		"## local-llama\n\n**Error:** model interaction failed\n\nNo changes.",
+	} {
+		assertContains(t, report, want)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  Flag introspection
+  ─────────────────────────────*/
+
+// commandFlags returns the flag set and positional arguments of c, without running it.
+func commandFlags(c command) (fs *flag.FlagSet, positional string) {
+	fs, _ = c.flagSet()
+	return fs, c.positional
+}
+
+// flagInfo is one flag as the completion scripts and man pages show it.
+type flagInfo struct {
+	name, usage, def string
+	isBool           bool
+}
+
+func listFlags(fs *flag.FlagSet) []flagInfo {
+	var flags []flagInfo
+	fs.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, flagInfo{f.Name, f.Usage, f.DefValue, ok && b.IsBoolFlag()})
+	})
+	return flags
+}
+
+// shortUsage is the start of a flag's usage text, up to the first clause, for the one-line
+// descriptions of completion menus.
+func shortUsage(usage string) string {
+	if i := strings.IndexAny(usage, ";(:"); i > 0 {
+		usage = usage[:i]
+	}
+	usage = strings.TrimSpace(usage)
+	if r := []rune(usage); len(r) > 80 {
+		usage = string(r[:77]) + "..."
+	}
+	return usage
+}
+
+/*──────────────────────────────
+  zug completion
+  ─────────────────────────────*/
+
+func completionCommand(fs *flag.FlagSet) func() {
+	return func() {
+		var script string
+		switch shell := arg(fs.Args(), 0); shell {
+		case "bash":
+			script = bashCompletion(commands())
+		case "zsh":
+			script = zshCompletion(commands())
+		case "fish":
+			script = fishCompletion(commands())
+		case "powershell":
+			script = powershellCompletion(commands())
+		default:
+			fs.Usage()
+			os.Exit(1)
+		}
+		fmt.Print(script)
+	}
+}
+
+func bashCompletion(cmds []command) string {
+	var sb strings.Builder
+	sb.WriteString("# bash completion for zug; generated by zug completion bash\n_zug() {\n")
+	sb.WriteString("  local cur=${COMP_WORDS[COMP_CWORD]} cmd=run\n")
+	var names []string
+	for _, c := range cmds {
+		names = append(names, c.name)
+	}
+	fmt.Fprintf(&sb, "  local commands=%q\n", strings.Join(names, " "))
+	sb.WriteString("  if [[ $COMP_CWORD -gt 1 && \" $commands \" == *\" ${COMP_WORDS[1]} \"* ]]; then\n    cmd=${COMP_WORDS[1]}\n  fi\n")
+	sb.WriteString("  if [[ $cur == -* ]]; then\n    local flags\n    case $cmd in\n")
+	for _, c := range cmds {
+		fs, _ := commandFlags(c)
+		var flags []string
+		for _, f := range listFlags(fs) {
+			flags = append(flags, "--"+f.name)
+		}
+		fmt.Fprintf(&sb, "      %s) flags=%q ;;\n", c.name, strings.Join(flags, " "))
+	}
+	sb.WriteString("    esac\n    COMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+	sb.WriteString("  elif [[ $COMP_CWORD -eq 1 ]]; then\n    COMPREPLY=($(compgen -W \"$commands\" -- \"$cur\"))\n  fi\n}\n")
+	sb.WriteString("complete -o default -F _zug zug\n")
+	return sb.String()
+}
+
+func zshCompletion(cmds []command) string {
+	quote := func(s string) string {
+		s = strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`).Replace(s)
+		return s
+	}
+	var sb strings.Builder
+	sb.WriteString("#compdef zug\n# zsh completion for zug; generated by zug completion zsh\n\n_zug() {\n  local -a commands\n  commands=(\n")
+	for _, c := range cmds {
+		fmt.Fprintf(&sb, "    '%s:%s'\n", c.name, quote(c.summary))
+	}
+	sb.WriteString("  )\n  if (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then\n    _describe 'command' commands\n    return\n  fi\n")
+	sb.WriteString("  local cmd=run\n  if [[ -n ${(M)commands:#$words[2]:*} ]]; then\n    cmd=$words[2]\n    words=($words[1] $words[3,-1])\n    (( CURRENT-- ))\n  fi\n")
+	sb.WriteString("  case $cmd in\n")
+	for _, c := range cmds {
+		fs, _ := commandFlags(c)
+		fmt.Fprintf(&sb, "    %s)\n      _arguments", c.name)
+		for _, f := range listFlags(fs) {
+			spec := fmt.Sprintf("--%s[%s]", f.name, quote(shortUsage(f.usage)))
+			if !f.isBool {
+				spec += ":" + f.name + ":_files"
+			}
+			fmt.Fprintf(&sb, " \\\n        '%s'", spec)
+		}
+		sb.WriteString(" \\\n        '*:argument:_files'\n      ;;\n")
+	}
+	sb.WriteString("  esac\n}\n\n_zug \"$@\"\n")
+	return sb.String()
+}
+
+func fishCompletion(cmds []command) string {
+	quote := func(s string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+	}
+	var sb strings.Builder
+	sb.WriteString("# fish completion for zug; generated by zug completion fish\n")
+	var others []string
+	for _, c := range cmds {
+		if c.name != "run" {
+			others = append(others, c.name)
+		}
+		fmt.Fprintf(&sb, "complete -c zug -n __fish_use_subcommand -f -a %s -d %s\n", c.name, quote(c.summary))
+	}
+	for _, c := range cmds {
+		cond := "__fish_seen_subcommand_from " + c.name
+		if c.name == "run" {
+			// Flags given without a subcommand belong to run.
+			cond = "not __fish_seen_subcommand_from " + strings.Join(others, " ")
+		}
+		fs, _ := commandFlags(c)
+		for _, f := range listFlags(fs) {
+			req := " -r"
+			if f.isBool {
+				req = ""
+			}
+			fmt.Fprintf(&sb, "complete -c zug -n %s -l %s%s -d %s\n", quote(cond), f.name, req, quote(shortUsage(f.usage)))
+		}
+	}
+	return sb.String()
+}
+
+func powershellCompletion(cmds []command) string {
+	quote := func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+	var sb strings.Builder
+	sb.WriteString("# PowerShell completion for zug; generated by zug completion powershell\n$zugCommands = @{\n")
+	for _, c := range cmds {
+		fs, _ := commandFlags(c)
+		var flags []string
+		for _, f := range listFlags(fs) {
+			flags = append(flags, quote("--"+f.name))
+		}
+		fmt.Fprintf(&sb, "    %s = @(%s, @(%s))\n", quote(c.name), quote(c.summary), strings.Join(flags, ", "))
+	}
+	sb.WriteString(`}
+Register-ArgumentCompleter -Native -CommandName zug, zug.exe -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })
+    if ($words.Count -le 2 -and $wordToComplete -notlike '-*') {
+        $zugCommands.Keys | Sort-Object | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+            [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $zugCommands[$_][0])
+        }
+        return
+    }
+    $cmd = 'run'
+    if ($words.Count -gt 1 -and $zugCommands.ContainsKey($words[1])) { $cmd = $words[1] }
+    $zugCommands[$cmd][1] | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $_)
+    }
+}
+`)
+	return sb.String()
+}
+
+/*──────────────────────────────
+  zug man
+  ─────────────────────────────*/
+
+// manEnvironment documents the environment variables in zug(1).
+var manEnvironment = [][2]string{
+	{envAPIKey, "API key, or several separated by commas"},
+	{envCredentialHelper, "command printing the API key, used when " + envAPIKey + " is unset"},
+	{"OPENAI_MODEL", "model to use; takes precedence over -model"},
+	{envBaseURL, "base URL of an OpenAI-compatible API or gateway"},
+	{envCABundle + ", " + envClientCert + ", " + envClientKey, "extra CA certificates and a client certificate for the API connection"},
+	{"ZUG_HOME", "directory of zug's state (default ~/.zug)"},
+	{"ZUG_CACHE", "response cache mode: on or replay"},
+}
+
+// manEscape escapes text for roff.
+func manEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+func manHeader(sb *strings.Builder, title, date string) {
+	fmt.Fprintf(sb, ".\\\" Generated by zug man; do not edit.\n.TH %s 1 %q zug \"zug manual\"\n", strings.ToUpper(title), date)
+}
+
+// commandManPage renders zug-<name>(1).
+func commandManPage(c command, date string) string {
+	fs, positional := commandFlags(c)
+	var sb strings.Builder
+	manHeader(&sb, "zug-"+c.name, date)
+	fmt.Fprintf(&sb, ".SH NAME\nzug\\-%s \\- %s\n", manEscape(c.name), manEscape(c.summary))
+	fmt.Fprintf(&sb, ".SH SYNOPSIS\n.B zug %s\n[\\fIflags\\fR] %s\n", manEscape(c.name), manEscape(positional))
+	fmt.Fprintf(&sb, ".SH DESCRIPTION\n%s.\n", manEscape(strings.ToUpper(c.summary[:1])+c.summary[1:]))
+	if flags := listFlags(fs); len(flags) > 0 {
+		sb.WriteString(".SH OPTIONS\n")
+		for _, f := range flags {
+			fmt.Fprintf(&sb, ".TP\n.B \\-\\-%s", manEscape(f.name))
+			if !f.isBool {
+				sb.WriteString(" \\fIvalue\\fR")
+			}
+			sb.WriteString("\n" + manEscape(f.usage))
+			if f.def != "" && f.def != "false" && f.def != "0" && f.def != "0s" {
+				fmt.Fprintf(&sb, " (default: %s)", manEscape(f.def))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	sb.WriteString(".SH SEE ALSO\n.BR zug (1)\n")
+	return sb.String()
+}
+
+// mainManPage renders zug(1) with the list of commands, the environment and exit codes.
+func mainManPage(cmds []command, date string) string {
+	var sb strings.Builder
+	manHeader(&sb, "zug", date)
+	sb.WriteString(".SH NAME\nzug \\- autonomous coding agent for the terminal\n")
+	sb.WriteString(".SH SYNOPSIS\n.B zug\n[\\fIcommand\\fR] [\\fIflags\\fR] \\fItask\\fR [\\fImodel\\fR]\n")
+	sb.WriteString(".SH DESCRIPTION\nzug plans, writes, builds and tests code from a task described in natural language, using an LLM through an OpenAI\\-compatible API. Without a command, it runs the task like\n.BR zug\\-run (1).\n")
+	sb.WriteString(".SH COMMANDS\n")
+	for _, c := range cmds {
+		fmt.Fprintf(&sb, ".TP\n.BR zug\\-%s (1)\n%s\n", manEscape(c.name), manEscape(c.summary))
+	}
+	sb.WriteString(".SH ENVIRONMENT\n")
+	for _, e := range manEnvironment {
+		fmt.Fprintf(&sb, ".TP\n.B %s\n%s\n", manEscape(e[0]), manEscape(e[1]))
+	}
+	sb.WriteString(".SH EXIT STATUS\n")
+	for _, e := range []struct {
+		code int
+		text string
+	}{
+		{exitVerified, "the task is done and the build or tests passed"},
+		{exitFailed, "any other error"},
+		{2, "invalid flags"},
+		{exitUnverified, "the model finished, but there was no build or test command to check it"},
+		{exitMaxTurns, "the feedback loop ran out of turns"},
+		{exitBudget, "a token budget was exceeded"},
+		{exitPolicy, "the run ended without success after tool calls were blocked by policy"},
+		{exitProvider, "the model API failed"},
+		{exitTimeout, "the \\-\\-timeout limit ran out"},
+		{exitInterrupted, "stopped with Ctrl\\-C"},
+	} {
+		fmt.Fprintf(&sb, ".TP\n.B %d\n%s\n", e.code, e.text)
+	}
+	sb.WriteString(".SH FILES\n.TP\n.I zug.yaml\nproject configuration\n.TP\n.I ZUG.md\nproject guide added to the system prompt\n.TP\n.I ~/.zug\nsessions, checkpoints, usage and caches\n")
+	var refs []string
+	for _, c := range cmds {
+		refs = append(refs, ".BR zug\\-"+manEscape(c.name)+" (1)")
+	}
+	fmt.Fprintf(&sb, ".SH SEE ALSO\n%s\n", strings.Join(refs, ",\n"))
+	return sb.String()
+}
+
+func manCommand(fs *flag.FlagSet) func() {
+	dir := fs.String("dir", "man", "directory to write zug.1 and the zug-<command>.1 pages to")
+	return func() {
+		if err := os.MkdirAll(*dir, 0o755); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		date := time.Now().Format("January 2006")
+		pages := map[string]string{"zug.1": mainManPage(commands(), date)}
+		for _, c := range commands() {
+			pages["zug-"+c.name+".1"] = commandManPage(c, date)
+		}
+		for name, page := range pages {
+			if err := os.WriteFile(filepath.Join(*dir, name), []byte(page), 0o644); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		}
+		fmt.Printf("Wrote %d man pages to %s; view one with: man %s\n", len(pages), *dir, filepath.Join(*dir, "zug.1"))
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCommandFlagsListsFlagsWithoutRunning(t *testing.T) {
+	for _, c := range commands() {
+		fs, _ := commandFlags(c)
+		if c.name == "completion" {
+			continue // takes only the shell name
+		}
+		if len(listFlags(fs)) == 0 {
+			t.Errorf("%s: no flags captured", c.name)
+		}
+	}
+	fix, _ := findCommand("fix")
+	fs, positional := commandFlags(fix)
+	if fs.Lookup("keep") == nil || fs.Lookup("dir") == nil || !strings.Contains(positional, "<git-url>") {
+		t.Errorf("fix: flags or usage %q not captured", positional)
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	cmds := commands()
+	bash := bashCompletion(cmds)
+	assertContains(t, bash, "fix) flags=\"--branch --cache")
+	if sh, err := exec.LookPath("bash"); err == nil {
+		if out, err := exec.Command(sh, "-n", "-c", bash).CombinedOutput(); err != nil {
+			t.Errorf("bash script does not parse: %v\n%s", err, out)
+		}
+	}
+	assertContains(t, zshCompletion(cmds), `'--keep[keep the workspace after the branch is pushed]'`)
+	assertContains(t, fishCompletion(cmds), "complete -c zug -n '__fish_seen_subcommand_from fix' -l branch -r -d 'branch to clone and open the pull request against'")
+	assertContains(t, powershellCompletion(cmds), `'fix' = @('clone a git repository`)
+
+	page := commandManPage(command{"fix", "<git-url> \"<task>\"", "clone and fix", fixCommand}, "October 2026")
+	assertContains(t, page, ".TH ZUG-FIX 1 \"October 2026\"")
+	assertContains(t, page, ".B \\-\\-branch \\fIvalue\\fR\n")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*──────────────────────────────
+  Project configuration (zug.yaml)
+  ─────────────────────────────*/
+
+const configFileName = "zug.yaml"
+
+// config is the optional per-project zug.yaml found in the project root.
+type config struct {
+	Hooks  hooksConfig  `yaml:"hooks"`
+	Lint   lintConfig   `yaml:"lint"`
+	Format formatConfig `yaml:"format"`
+	Syntax syntaxConfig `yaml:"syntax"`
+	Build  buildConfig  `yaml:"build"`
+	Test   testConfig   `yaml:"test"`
+	LSP    lspConfig    `yaml:"lsp"`
+
+	Preflight preflightConfig `yaml:"preflight"`
+	Notify    notifyConfig    `yaml:"notify"`
+	Context   contextConfig   `yaml:"context"`
+	Network   networkConfig   `yaml:"network"`
+	Security  securityConfig  `yaml:"security"`
+	Mutation  mutationConfig  `yaml:"mutation"`
+	Docker    dockerConfig    `yaml:"docker"`
+	Verify    verifyConfig    `yaml:"verify"`
+	Loop      loopConfig      `yaml:"loop"`
+	Knowledge knowledgeConfig `yaml:"knowledge"`
+
+	Permissions permissionsConfig `yaml:"permissions"` // tool name (or "*") -> auto, ask or deny
+
+	Roots          []rootConfig     `yaml:"roots"`  // monorepo: restrict the agent to these dirs
+	Ignore         []string         `yaml:"ignore"` // gitignore-style patterns hidden from the file tools
+	SensitivePaths pathPolicyConfig `yaml:"sensitive_paths"`
+}
+
+// loadConfig reads zug.yaml from projectDir. A missing file yields the zero config.
+func loadConfig(projectDir string) (*config, error) {
+	path := filepath.Join(projectDir, configFileName)
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	var cfg config
+	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if err := cfg.Context.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if err := cfg.Network.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if err := cfg.Security.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if err := cfg.Mutation.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if err := cfg.Docker.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if err := cfg.Verify.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if err := cfg.Permissions.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	cfg.Permissions.warnUnknown()
+	for _, r := range cfg.Roots {
+		if clean := filepath.Clean(r.Path); r.Path == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(os.PathSeparator)) {
+			return nil, fmt.Errorf("invalid %s: root path %q must be a relative directory inside the project", path, r.Path)
+		}
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+/*──────────────────────────────
+  API credentials
+  ─────────────────────────────*/
+
+const (
+	envAPIKey           = "OPENAI_API_KEY"
+	envCredentialHelper = "ZUG_CREDENTIAL_HELPER" // command printing the API key on stdout
+	keychainService     = "zug"
+	keychainAccount     = "openai"
+	credentialTimeout   = time.Minute // helpers may wait for a password manager prompt
+)
+
+var errNoCredential = errors.New("no credential stored")
+
+var sharedKeys struct {
+	once sync.Once
+	ring *apiKeyRing
+	err  error
+}
+
+// apiKeys returns the key ring built from, in order: $OPENAI_API_KEY, the command in
+// $ZUG_CREDENTIAL_HELPER, or the OS keychain (service "zug", account "openai"). Each
+// source may hold several keys separated by commas or newlines. The keys are resolved
+// once per process, so a helper or keychain prompt is not repeated for every task.
+func apiKeys() (*apiKeyRing, error) {
+	sharedKeys.once.Do(func() {
+		var keys []string
+		if keys, sharedKeys.err = resolveAPIKeys(); sharedKeys.err == nil {
+			sharedKeys.ring, sharedKeys.err = newKeyRing(keys)
+		}
+	})
+	return sharedKeys.ring, sharedKeys.err
+}
+
+func resolveAPIKeys() ([]string, error) {
+	if keys := splitKeys(os.Getenv(envAPIKey)); len(keys) > 0 {
+		return keys, nil
+	}
+	if helper := os.Getenv(envCredentialHelper); helper != "" {
+		keys, err := runCredentialHelper(helper)
+		if err != nil {
+			return nil, fmt.Errorf("%s failed: %w", envCredentialHelper, err)
+		}
+		log.Printf("[agent] 🔑 API key from %s.\n", envCredentialHelper)
+		return keys, nil
+	}
+	secret, err := keychainSecret(keychainService, keychainAccount)
+	if keys := splitKeys(secret); err == nil && len(keys) > 0 {
+		log.Println("[agent] 🔑 API key from the OS keychain.")
+		return keys, nil
+	}
+	hint := fmt.Sprintf("set %s, point %s at a command that prints the key, or store it in the OS keychain as service %q, account %q (see README)", envAPIKey, envCredentialHelper, keychainService, keychainAccount)
+	if err != nil && !errors.Is(err, errNoCredential) {
+		return nil, fmt.Errorf("no API key found (keychain: %v); %s", err, hint)
+	}
+	return nil, fmt.Errorf("no API key found; %s", hint)
+}
+
+// runCredentialHelper runs command and returns the keys it prints, one per line. Its stderr
+// and stdin stay attached to the terminal so it can prompt for unlocking.
+func runCredentialHelper(command string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialTimeout)
+	defer cancel()
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, &stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	keys := splitKeys(stdout.String())
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%q printed nothing", command)
+	}
+	return keys, nil
+}
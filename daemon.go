@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+/*──────────────────────────────
+  Daemon task queue
+  ─────────────────────────────*/
+
+const (
+	taskQueued      = "queued"
+	taskRunning     = "running"
+	taskDone        = "done"
+	taskFailed      = "failed"
+	taskInterrupted = "interrupted" // the daemon stopped while the task was running
+)
+
+// daemonTask is one queued unit of work and, once finished, its queryable result.
+type daemonTask struct {
+	ID       string     `json:"id"`
+	Task     string     `json:"task"`
+	Dir      string     `json:"dir"`
+	Model    string     `json:"model,omitempty"`
+	Review   bool       `json:"review,omitempty"`
+	Schedule string     `json:"schedule,omitempty"` // name of the schedule that queued the task
+	Status   string     `json:"status"`
+	Branch   string     `json:"branch,omitempty"` // holds the agent's commit when files changed
+	Files    []string   `json:"files,omitempty"`
+	Summary  string     `json:"summary,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+type daemon struct {
+	mu      sync.Mutex
+	tasks   map[string]*daemonTask
+	queue   chan string
+	pending []string // tasks load found queued, sent to the queue by start
+	state   *stateStore
+	model   string
+	token   string   // required as a bearer token when set
+	hosts   []string // Host headers the API answers to, nil for any
+	notify  notifyConfig
+	seq     atomic.Int64
+}
+
+func newDaemon(dataDir, model, token string) (*daemon, error) {
+	if err := os.MkdirAll(dataDir, 0o700); err != nil {
+		return nil, fmt.Errorf("cannot create %s: %w", dataDir, err)
+	}
+	st, err := openState(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	d := &daemon{tasks: map[string]*daemonTask{}, queue: make(chan string, 1000), state: st, model: model, token: token}
+	return d, d.load()
+}
+
+// load restores earlier results. Queued tasks are queued again by start; tasks that were
+// running when the daemon stopped are marked interrupted.
+func (d *daemon) load() error {
+	rows, err := d.state.db.Query(`SELECT id, data FROM tasks`)
+	if err != nil {
+		return fmt.Errorf("cannot load tasks: %w", err)
+	}
+	defer rows.Close()
+	var requeue, interrupted []*daemonTask
+	for rows.Next() {
+		var id, raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			return fmt.Errorf("cannot load tasks: %w", err)
+		}
+		var t daemonTask
+		if err := json.Unmarshal([]byte(raw), &t); err != nil {
+			log.Printf("[daemon] ⚠️ Skipping unreadable task %s: %v\n", id, err)
+			continue
+		}
+		switch t.Status {
+		case taskRunning:
+			t.Status, t.Error = taskInterrupted, "daemon stopped while the task was running"
+			interrupted = append(interrupted, &t)
+		case taskQueued:
+			requeue = append(requeue, &t)
+		}
+		d.tasks[t.ID] = &t
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("cannot load tasks: %w", err)
+	}
+	rows.Close()
+	for _, t := range interrupted {
+		d.save(t)
+	}
+	sort.Slice(requeue, func(i, j int) bool { return requeue[i].Created.Before(requeue[j].Created) })
+	for _, t := range requeue {
+		d.pending = append(d.pending, t.ID)
+	}
+	if len(d.tasks) > 0 {
+		log.Printf("[daemon] Loaded %d task(s), %d re-queued.\n", len(d.tasks), len(requeue))
+	}
+	return nil
+}
+
+// save persists t. Callers hold d.mu once the daemon is serving.
+func (d *daemon) save(t *daemonTask) {
+	raw, _ := json.Marshal(t)
+	_, err := d.state.db.Exec(`INSERT INTO tasks (id, status, schedule, created, data) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET status = excluded.status, data = excluded.data`,
+		t.ID, t.Status, t.Schedule, stateTime(t.Created), string(raw))
+	if err != nil {
+		log.Printf("[daemon] ⚠️ Could not save task %s: %v\n", t.ID, err)
+	}
+}
+
+// update mutates a task under the lock and persists it.
+func (d *daemon) update(id string, fn func(t *daemonTask)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t := d.tasks[id]
+	fn(t)
+	d.save(t)
+}
+
+// submit validates and enqueues a task.
+func (d *daemon) submit(t daemonTask) (*daemonTask, error) {
+	t.Task = strings.TrimSpace(t.Task)
+	if t.Task == "" {
+		return nil, fmt.Errorf("'task' is required")
+	}
+	if !filepath.IsAbs(t.Dir) {
+		return nil, fmt.Errorf("'dir' must be an absolute path to a git repository on the daemon host")
+	}
+	if _, err := gitCmd(t.Dir, "rev-parse", "HEAD"); err != nil {
+		return nil, fmt.Errorf("'dir' must be a git repository with at least one commit: %w", err)
+	}
+	t.ID = fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), d.seq.Add(1))
+	t.Status, t.Created = taskQueued, time.Now()
+	snapshot := t // returned to the caller; workers mutate the stored copy
+	d.mu.Lock()
+	d.tasks[t.ID] = &t
+	d.save(&t)
+	d.mu.Unlock()
+	select {
+	case d.queue <- t.ID:
+	default:
+		d.update(t.ID, func(t *daemonTask) { t.Status, t.Error = taskFailed, "queue is full" })
+		return nil, fmt.Errorf("queue is full, try again later")
+	}
+	log.Printf("[daemon] 📥 Queued task %s for %s\n", t.ID, t.Dir)
+	return &snapshot, nil
+}
+
+// start runs workers and queues the tasks load restored. The restored tasks are sent as the
+// workers take them, so any number of them fits the queue.
+func (d *daemon) start(workers int) {
+	for i := 0; i < workers; i++ {
+		go d.worker()
+	}
+	pending := d.pending
+	d.pending = nil
+	go func() {
+		for _, id := range pending {
+			d.queue <- id
+		}
+	}()
+}
+
+func (d *daemon) worker() {
+	for id := range d.queue {
+		d.run(id)
+	}
+}
+
+// run executes a task in a fresh git worktree of its repository, so concurrent tasks and
+// the user's own checkout never interfere, and commits the result on a zug/daemon-<id> branch.
+func (d *daemon) run(id string) {
+	var t daemonTask
+	d.update(id, func(task *daemonTask) {
+		now := time.Now()
+		task.Status, task.Started = taskRunning, &now
+		t = *task
+	})
+	log.Printf("[daemon] ▶️ Running task %s: %s\n", id, t.Task)
+
+	files, summary, branch, err := d.execute(t)
+	d.update(id, func(task *daemonTask) {
+		now := time.Now()
+		task.Finished, task.Files, task.Summary, task.Branch = &now, files, summary, branch
+		task.Status = taskDone
+		if err != nil {
+			task.Status, task.Error = taskFailed, err.Error()
+		}
+	})
+	log.Printf("[daemon] ⏹️ Task %s finished (%d file(s) changed, error: %v)\n", id, len(files), err)
+	name := "task " + id
+	if t.Schedule != "" {
+		name = fmt.Sprintf("scheduled task %q", t.Schedule)
+	}
+	if err != nil {
+		d.notify.send(notification{
+			Event:   eventFailed,
+			Title:   fmt.Sprintf("zug: %s failed", name),
+			Message: fmt.Sprintf("%s in %s failed: %v", shortTask(t.Task), t.Dir, err),
+		})
+	} else {
+		d.notify.send(notification{
+			Event:   eventDone,
+			Title:   fmt.Sprintf("zug: %s finished", name),
+			Message: fmt.Sprintf("%s\n%d file(s) changed on branch %s", shortTask(t.Task), len(files), branch),
+		})
+	}
+}
+
+func (d *daemon) execute(t daemonTask) (files []string, summary, branch string, err error) {
+	top, err := gitCmd(t.Dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, "", "", err
+	}
+	resolved, _ := filepath.EvalSymlinks(t.Dir)
+	sub, _ := filepath.Rel(top, resolved)
+	wt, err := os.MkdirTemp("", "zug-daemon-*")
+	if err != nil {
+		return nil, "", "", fmt.Errorf("cannot create worktree dir: %w", err)
+	}
+	branch = "zug/daemon-" + t.ID
+	if _, err := gitCmd(top, "worktree", "add", "-b", branch, wt, "HEAD"); err != nil {
+		os.RemoveAll(wt)
+		return nil, "", "", err
+	}
+	defer func() {
+		if _, rmErr := gitCmd(top, "worktree", "remove", "--force", wt); rmErr != nil {
+			log.Printf("[daemon] Warning: could not remove worktree %s: %v\n", wt, rmErr)
+		}
+		if len(files) == 0 {
+			gitCmd(top, "branch", "-D", branch)
+			branch = ""
+		}
+	}()
+
+	dir := filepath.Join(wt, sub)
+	if _, err := loadConfig(dir); err != nil {
+		return nil, "", branch, err
+	}
+	cf := commonFlags{dir: dir, model: t.Model}
+	if cf.model == "" {
+		cf.model = d.model
+	}
+	agent := cf.newAgent("")
+	agent.review = t.Review
+	runErr := agent.feedbackLoop(t.Task)
+	summary = strings.TrimSpace(agent.lastReply)
+
+	if _, err := gitCmd(wt, "add", "-A"); err != nil {
+		return nil, summary, branch, err
+	}
+	names, err := gitCmd(wt, "diff", "--cached", "--name-only", "HEAD")
+	if err != nil {
+		return nil, summary, branch, err
+	}
+	if names != "" {
+		files = strings.Split(names, "\n")
+		msg := agent.autoCommitMessage(wt, t.Task, "zug: "+firstLine(t.Task))
+		if _, err := gitCmd(wt, "-c", "user.name=zug", "-c", "user.email=zug@localhost", "commit", "-q", "-m", msg); err != nil {
+			return files, summary, branch, err
+		}
+	}
+	return files, summary, branch, runErr
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	if len(line) > 72 {
+		line = line[:72]
+	}
+	return line
+}
+
+/*──────────────────────────────
+  Daemon HTTP API
+  ─────────────────────────────*/
+
+// handler serves the task API. Tasks run agents with shell access, so a web page the user
+// happens to open must not reach it: requests need the bearer token, JSON bodies and no
+// cross-site Origin, and when the daemon listens on a named address, a Host header naming
+// it, against DNS rebinding.
+func (d *daemon) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tasks", func(w http.ResponseWriter, r *http.Request) {
+		if ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || ct != "application/json" {
+			writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "Content-Type must be application/json"})
+			return
+		}
+		var t daemonTask
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&t); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON: " + err.Error()})
+			return
+		}
+		created, err := d.submit(daemonTask{Task: t.Task, Dir: t.Dir, Model: t.Model, Review: t.Review})
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, created)
+	})
+	mux.HandleFunc("GET /tasks", func(w http.ResponseWriter, r *http.Request) {
+		status := r.URL.Query().Get("status")
+		d.mu.Lock()
+		list := make([]daemonTask, 0, len(d.tasks))
+		for _, t := range d.tasks {
+			if status == "" || t.Status == status {
+				list = append(list, *t)
+			}
+		}
+		d.mu.Unlock()
+		sort.Slice(list, func(i, j int) bool { return list[i].Created.After(list[j].Created) })
+		writeJSON(w, http.StatusOK, list)
+	})
+	mux.HandleFunc("GET /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		t, ok := d.tasks[r.PathValue("id")]
+		var snapshot daemonTask
+		if ok {
+			snapshot = *t
+		}
+		d.mu.Unlock()
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such task"})
+			return
+		}
+		writeJSON(w, http.StatusOK, snapshot)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.hosts != nil && !slices.Contains(d.hosts, r.Host) {
+			writeJSON(w, http.StatusMisdirectedRequest, map[string]string{"error": "unexpected Host " + r.Host})
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": "cross-origin requests are not allowed"})
+				return
+			}
+		}
+		if d.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+d.token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or wrong bearer token"})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// allowedHosts lists the Host headers for a daemon listening on addr. Unix sockets and
+// wildcard addresses accept any Host; the token is their only check.
+func allowedHosts(addr string) []string {
+	host, port, err := net.SplitHostPort(addr)
+	if strings.HasPrefix(addr, "unix:") || err != nil {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if host == "" || (ip != nil && ip.IsUnspecified()) {
+		return nil
+	}
+	hosts := []string{net.JoinHostPort(host, port)}
+	if host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		for _, alias := range []string{"localhost", "127.0.0.1", "::1"} {
+			if alias != host {
+				hosts = append(hosts, net.JoinHostPort(alias, port))
+			}
+		}
+	}
+	return hosts
+}
+
+func newDaemonToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// listen accepts "host:port" or "unix:/path/to.sock".
+func listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		os.Remove(path) // stale socket from an earlier run
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+/*──────────────────────────────
+  zug daemon
+  ─────────────────────────────*/
+
+func daemonCommand(fs *flag.FlagSet) func() {
+	addr := fs.String("listen", "127.0.0.1:7777", "address to serve the task API on (host:port or unix:/path/to.sock)")
+	workers := fs.Int("concurrency", 2, "number of tasks run at the same time")
+	model := fs.String("model", "", "default model for tasks that do not set one")
+	data := fs.String("data", "", "directory holding the task database, state.db (default ~/.zug)")
+	cfgPath := fs.String("config", "", "daemon config with schedules and notifications (default ~/.zug/daemon.yaml)")
+	return func() {
+		if _, err := apiKeys(); err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		home, err := userZugDir()
+		if err != nil && (*data == "" || *cfgPath == "") {
+			log.Fatalf("FATAL: %v", err)
+		}
+		if *data == "" {
+			*data = home
+		}
+		explicit := *cfgPath != ""
+		if !explicit {
+			*cfgPath = filepath.Join(home, "daemon.yaml")
+		}
+		cfg, err := loadDaemonConfig(*cfgPath, explicit)
+		if err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		// Only a Unix socket, guarded by its file permissions, may go without a token.
+		token := os.Getenv("ZUG_DAEMON_TOKEN")
+		auth := "bearer token from ZUG_DAEMON_TOKEN"
+		switch {
+		case token == "" && strings.HasPrefix(*addr, "unix:"):
+			auth = "no auth on the Unix socket"
+		case token == "":
+			token = newDaemonToken()
+			auth = "bearer token " + token + ", set ZUG_DAEMON_TOKEN to choose one"
+		}
+		d, err := newDaemon(*data, *model, token)
+		if err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		d.notify = cfg.Notify
+		d.hosts = allowedHosts(*addr)
+		d.start(max(*workers, 1))
+		if len(cfg.Schedules) > 0 {
+			go d.schedule(cfg.Schedules)
+		}
+
+		ln, err := listen(*addr)
+		if err != nil {
+			log.Fatalf("FATAL: cannot listen on %s: %v", *addr, err)
+		}
+		srv := &http.Server{Handler: d.handler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+			<-sig
+			log.Println("[daemon] Shutting down; running tasks will be reported as interrupted.")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			srv.Shutdown(ctx)
+		}()
+		log.Printf("[daemon] 🛰️ Listening on %s (%d worker(s), %s, results in %s)\n", *addr, max(*workers, 1), auth, *data)
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("FATAL: %v", err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"zug/provider/mock"
+)
+
+// newTestRepo returns a git repository with one commit holding files.
+func newTestRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for path, content := range files {
+		writeTestFile(t, dir, path, content)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@localhost", "commit", "-q", "-m", "initial"},
+	} {
+		if _, err := gitCmd(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func daemonRequest(method, path, body, token string) *http.Request {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Host = "127.0.0.1:7777"
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestDaemonRunsQueuedTask(t *testing.T) {
+	_, srv := newTestAgent(t, nil,
+		mock.Call("create_file", map[string]any{"path": "hello.txt", "content": "hi\n"}),
+		mock.Text("Created hello.txt."),
+		mock.Text("feat: add hello.txt"),
+	)
+	t.Setenv(envAPIKey, "sk-test-key")
+	repo := newTestRepo(t, map[string]string{"zug.yaml": "verify:\n  disabled: true\n"})
+
+	d, err := newDaemon(t.TempDir(), "mock-model", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.hosts = allowedHosts("127.0.0.1:7777")
+	h := d.handler()
+	d.start(1)
+
+	body := fmt.Sprintf(`{"task": "create hello.txt", "dir": %q}`, repo)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, daemonRequest("POST", "/tasks", body, "secret"))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST /tasks = %d %s", w.Code, w.Body)
+	}
+	var task daemonTask
+	json.Unmarshal(w.Body.Bytes(), &task)
+
+	deadline := time.Now().Add(30 * time.Second)
+	for task.Status == taskQueued || task.Status == taskRunning {
+		if time.Now().After(deadline) {
+			t.Fatalf("task is still %s", task.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, daemonRequest("GET", "/tasks/"+task.ID, "", "secret"))
+		json.Unmarshal(w.Body.Bytes(), &task)
+	}
+	if task.Status != taskDone || task.Branch != "zug/daemon-"+task.ID || strings.Join(task.Files, ",") != "hello.txt" {
+		t.Fatalf("task = %+v", task)
+	}
+	if msg, _ := gitCmd(repo, "log", "-1", "--format=%s", task.Branch); msg != "feat: add hello.txt" {
+		t.Errorf("commit message = %q", msg)
+	}
+	if status, _ := gitCmd(repo, "status", "--porcelain"); status != "" {
+		t.Errorf("the task touched the checkout: %s", status)
+	}
+	if n := len(srv.Requests()); n != 3 {
+		t.Errorf("%d model requests, want 3", n)
+	}
+}
+
+func TestDaemonRestoresTasksAfterRestart(t *testing.T) {
+	data := t.TempDir()
+	d, err := newDaemon(data, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// More queued tasks than the queue holds must not block the restart.
+	const queued = 1200
+	start := time.Now()
+	for i := 0; i < queued; i++ {
+		d.save(&daemonTask{ID: fmt.Sprintf("q%d", i), Task: "t", Status: taskQueued, Created: start.Add(time.Duration(i) * time.Second)})
+	}
+	d.save(&daemonTask{ID: "r", Task: "t", Status: taskRunning, Created: start})
+	d.state.db.Close()
+
+	loaded := make(chan *daemon)
+	go func() {
+		d, err := newDaemon(data, "", "")
+		if err != nil {
+			t.Error(err)
+		}
+		loaded <- d
+	}()
+	select {
+	case d = <-loaded:
+	case <-time.After(10 * time.Second):
+		t.Fatal("newDaemon blocked on the restored tasks")
+	}
+	defer d.state.db.Close()
+	if d == nil {
+		return
+	}
+	if got := d.tasks["r"]; got.Status != taskInterrupted {
+		t.Errorf("running task restored as %s, want %s", got.Status, taskInterrupted)
+	}
+
+	d.start(0) // no workers: take the tasks off the queue here
+	for i := 0; i < queued; i++ {
+		select {
+		case id := <-d.queue:
+			if want := fmt.Sprintf("q%d", i); id != want {
+				t.Fatalf("task %d off the queue is %s, want %s", i, id, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of %d tasks were queued again", i, queued)
+		}
+	}
+}
+
+func TestDaemonRejectsUnauthorizedRequests(t *testing.T) {
+	t.Setenv("ZUG_HOME", t.TempDir())
+	d, err := newDaemon(t.TempDir(), "", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.state.db.Close()
+	d.hosts = allowedHosts("127.0.0.1:7777")
+	h := d.handler()
+
+	textPlain := daemonRequest("POST", "/tasks", `{"task": "x", "dir": "/tmp"}`, "secret")
+	textPlain.Header.Set("Content-Type", "text/plain")
+	crossSite := daemonRequest("GET", "/tasks", "", "secret")
+	crossSite.Header.Set("Origin", "https://evil.example")
+	rebound := daemonRequest("GET", "/tasks", "", "secret")
+	rebound.Host = "evil.example:7777"
+	viaLocalhost := daemonRequest("GET", "/tasks", "", "secret")
+	viaLocalhost.Host = "localhost:7777"
+	for name, tc := range map[string]struct {
+		r    *http.Request
+		want int
+	}{
+		"no token":      {daemonRequest("GET", "/tasks", "", ""), http.StatusUnauthorized},
+		"wrong token":   {daemonRequest("GET", "/tasks", "", "guess"), http.StatusUnauthorized},
+		"text/plain":    {textPlain, http.StatusUnsupportedMediaType},
+		"cross-site":    {crossSite, http.StatusForbidden},
+		"rebound host":  {rebound, http.StatusMisdirectedRequest},
+		"authorized":    {daemonRequest("GET", "/tasks", "", "secret"), http.StatusOK},
+		"missing task":  {daemonRequest("POST", "/tasks", `{"dir": "/tmp"}`, "secret"), http.StatusBadRequest},
+		"via localhost": {viaLocalhost, http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, tc.r)
+		if w.Code != tc.want {
+			t.Errorf("%s: status %d, want %d (%s)", name, w.Code, tc.want, strings.TrimSpace(w.Body.String()))
+		}
+	}
+
+	if hosts := allowedHosts("unix:/tmp/zug.sock"); hosts != nil {
+		t.Errorf("allowedHosts(unix) = %v, want any", hosts)
+	}
+	if hosts := allowedHosts("0.0.0.0:7777"); hosts != nil {
+		t.Errorf("allowedHosts(0.0.0.0) = %v, want any", hosts)
+	}
+}
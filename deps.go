@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*──────────────────────────────
+  Dependency tools
+  ─────────────────────────────*/
+
+// depManager adds and removes dependencies with the package manager a project uses, so
+// manifests and lockfiles stay consistent instead of being edited by hand.
+type depManager struct {
+	name  string
+	files []string // manifest first, then lockfiles, relative to the package dir
+
+	// add and remove return the shell command to run. edit, when set, instead changes
+	// the manifest in Go and returns its new content; the command then only installs.
+	add    func(name, version string, dev bool) (string, error)
+	remove func(name string, dev bool) (string, error)
+	edit   func(manifest, name, version string, dev, remove bool) (string, error)
+}
+
+var (
+	depNamePattern    = regexp.MustCompile(`^@?[A-Za-z0-9][A-Za-z0-9._~/+-]*$`)
+	depVersionPattern = regexp.MustCompile(`^[A-Za-z0-9.*^~<>=!+,_ -]+$`)
+)
+
+func validDependency(name, version string) error {
+	if !depNamePattern.MatchString(name) {
+		return fmt.Errorf("%q is not a valid dependency name", name)
+	}
+	if version != "" && !depVersionPattern.MatchString(version) {
+		return fmt.Errorf("%q is not a valid version", version)
+	}
+	return nil
+}
+
+// pythonSpec turns a bare version into an exact pin; ranges are kept.
+func pythonSpec(version string) string {
+	if version != "" && version[0] >= '0' && version[0] <= '9' {
+		return "==" + version
+	}
+	return version
+}
+
+// dependencyManager detects the package manager of the package in dir, relative to the
+// project root.
+func (a *AutonomousCodingAgent) dependencyManager(dir string) (*depManager, error) {
+	root, err := a.absPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	has := func(name string) bool {
+		_, err := a.fs.Stat(filepath.Join(root, name))
+		return err == nil
+	}
+	read := func(name string) string {
+		raw, _ := a.fs.ReadFile(filepath.Join(root, name))
+		return string(raw)
+	}
+	noDev := func(eco string) error { return fmt.Errorf("%s has no development dependencies; leave dev unset", eco) }
+
+	switch {
+	case has("go.mod"):
+		return &depManager{
+			name: "go modules", files: []string{"go.mod", "go.sum"},
+			add: func(name, version string, dev bool) (string, error) {
+				if dev {
+					return "", noDev("Go")
+				}
+				if version == "" {
+					version = "latest"
+				}
+				// No go mod tidy: it would drop the module again until code imports it.
+				return "go get " + shellQuote(name+"@"+version), nil
+			},
+			remove: func(name string, _ bool) (string, error) {
+				return "go mod edit -droprequire=" + shellQuote(name) + " && go mod tidy", nil
+			},
+		}, nil
+
+	case has("Cargo.toml"):
+		return &depManager{
+			name: "cargo", files: []string{"Cargo.toml", "Cargo.lock"},
+			add: func(name, version string, dev bool) (string, error) {
+				cmd := "cargo add " + shellQuote(name+versionSuffix("@", version))
+				if dev {
+					cmd += " --dev"
+				}
+				return cmd, nil
+			},
+			remove: func(name string, dev bool) (string, error) {
+				cmd := "cargo remove " + shellQuote(name)
+				if dev {
+					cmd += " --dev"
+				}
+				return cmd, nil
+			},
+		}, nil
+
+	case has("package.json"):
+		add, remove, devFlag, lock := "npm install", "npm uninstall", "--save-dev", "package-lock.json"
+		switch {
+		case has("pnpm-lock.yaml"):
+			add, remove, devFlag, lock = "pnpm add", "pnpm remove", "--save-dev", "pnpm-lock.yaml"
+		case has("yarn.lock"):
+			add, remove, devFlag, lock = "yarn add", "yarn remove", "--dev", "yarn.lock"
+		case has("bun.lock") || has("bun.lockb"):
+			add, remove, devFlag, lock = "bun add", "bun remove", "--dev", "bun.lock"
+		}
+		return &depManager{
+			name: strings.Fields(add)[0], files: []string{"package.json", lock},
+			add: func(name, version string, dev bool) (string, error) {
+				cmd := add + " " + shellQuote(name+versionSuffix("@", version))
+				if dev {
+					cmd += " " + devFlag
+				}
+				return cmd, nil
+			},
+			remove: func(name string, _ bool) (string, error) { return remove + " " + shellQuote(name), nil },
+		}, nil
+
+	case has("pyproject.toml") && (has("uv.lock") || strings.Contains(read("pyproject.toml"), "[tool.uv")):
+		return &depManager{
+			name: "uv", files: []string{"pyproject.toml", "uv.lock"},
+			add: func(name, version string, dev bool) (string, error) {
+				cmd := "uv add " + shellQuote(name+pythonSpec(version))
+				if dev {
+					cmd += " --dev"
+				}
+				return cmd, nil
+			},
+			remove: func(name string, dev bool) (string, error) {
+				cmd := "uv remove " + shellQuote(name)
+				if dev {
+					cmd += " --dev"
+				}
+				return cmd, nil
+			},
+		}, nil
+
+	case has("pyproject.toml") && strings.Contains(read("pyproject.toml"), "[tool.poetry"):
+		return &depManager{
+			name: "poetry", files: []string{"pyproject.toml", "poetry.lock"},
+			add: func(name, version string, dev bool) (string, error) {
+				cmd := "poetry add " + shellQuote(name+versionSuffix("@", version))
+				if dev {
+					cmd += " --group dev"
+				}
+				return cmd, nil
+			},
+			remove: func(name string, dev bool) (string, error) {
+				cmd := "poetry remove " + shellQuote(name)
+				if dev {
+					cmd += " --group dev"
+				}
+				return cmd, nil
+			},
+		}, nil
+
+	case has("requirements.txt"):
+		return &depManager{
+			name: "pip", files: []string{"requirements.txt"},
+			edit: func(manifest, name, version string, dev, remove bool) (string, error) {
+				if dev {
+					return "", fmt.Errorf("requirements.txt has no development dependencies; edit requirements-dev.txt or leave dev unset")
+				}
+				return editRequirements(manifest, name, pythonSpec(version), remove), nil
+			},
+			add: func(string, string, bool) (string, error) {
+				return "python -m pip install -q -r requirements.txt", nil
+			},
+			remove: func(name string, _ bool) (string, error) {
+				return "python -m pip uninstall -q -y " + shellQuote(name), nil
+			},
+		}, nil
+
+	case has("pyproject.toml"):
+		return &depManager{
+			name: "pip", files: []string{"pyproject.toml"},
+			edit: func(manifest, name, version string, dev, remove bool) (string, error) {
+				if dev {
+					return "", noDev("A pyproject.toml without uv or poetry")
+				}
+				return editPyprojectDependencies(manifest, name, pythonSpec(version), remove)
+			},
+			add: func(name, version string, _ bool) (string, error) {
+				return "python -m pip install -q " + shellQuote(name+pythonSpec(version)), nil
+			},
+			remove: func(name string, _ bool) (string, error) {
+				return "python -m pip uninstall -q -y " + shellQuote(name), nil
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("no supported manifest (go.mod, Cargo.toml, package.json, pyproject.toml, requirements.txt) in %s", dir)
+}
+
+func versionSuffix(sep, version string) string {
+	if version == "" {
+		return ""
+	}
+	return sep + version
+}
+
+// pyRequirementName extracts the normalized package name of a requirement line.
+func pyRequirementName(line string) string {
+	end := strings.IndexAny(line, "=<>!~[; @")
+	if end < 0 {
+		end = len(line)
+	}
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(line[:end]), "_", "-"))
+}
+
+// editRequirements adds (or replaces) or removes name in a requirements.txt.
+func editRequirements(content, name, spec string, remove bool) string {
+	want := pyRequirementName(name)
+	var lines []string
+	replaced := false
+	for _, line := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "-") && pyRequirementName(trimmed) == want {
+			if !remove && !replaced {
+				lines = append(lines, name+spec)
+				replaced = true
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if !remove && !replaced {
+		lines = append(lines, name+spec)
+	}
+	return strings.TrimLeft(strings.Join(lines, "\n")+"\n", "\n")
+}
+
+var pyprojectDeps = regexp.MustCompile(`(?ms)^\[project\]\s*$.*?^dependencies\s*=\s*\[(.*?)\]`)
+
+// editPyprojectDependencies adds (or replaces) or removes name in the dependencies array
+// of the [project] table.
+func editPyprojectDependencies(content, name, spec string, remove bool) (string, error) {
+	m := pyprojectDeps.FindStringSubmatchIndex(content)
+	if m == nil {
+		return "", fmt.Errorf("pyproject.toml has no dependencies = [...] in its [project] table")
+	}
+	want := pyRequirementName(name)
+	var items []string
+	for _, item := range regexp.MustCompile(`"[^"]*"|'[^']*'`).FindAllString(content[m[2]:m[3]], -1) {
+		if pyRequirementName(strings.Trim(item, `"'`)) != want {
+			items = append(items, item)
+		}
+	}
+	if !remove {
+		items = append(items, fmt.Sprintf("%q", name+spec))
+	}
+	body := ""
+	if len(items) > 0 {
+		body = "\n    " + strings.Join(items, ",\n    ") + ",\n"
+	}
+	return content[:m[2]] + body + content[m[3]:], nil
+}
+
+// changeDependency adds or removes a dependency of the package in dir and returns what
+// changed in its manifest.
+func (a *AutonomousCodingAgent) changeDependency(dir, name, version string, dev, remove bool) (string, error) {
+	name, version = strings.TrimSpace(name), strings.TrimSpace(version)
+	if err := validDependency(name, version); err != nil {
+		return "", err
+	}
+	m, err := a.dependencyManager(dir)
+	if err != nil {
+		return "", err
+	}
+	root, _ := a.absPath(dir)
+	rel, _ := filepath.Rel(a.projectDir, root)
+	var files []string
+	for _, f := range m.files {
+		files = append(files, filepath.Join(rel, f))
+		a.trackChange(filepath.Join(rel, f), filepath.Join(root, f))
+	}
+	before := a.snapshotFiles(files)
+	defer a.auditApplied(files, before)
+
+	manifest := filepath.Join(root, m.files[0])
+	if m.edit != nil {
+		edited, err := m.edit(string(before[files[0]].content), name, version, dev, remove)
+		if err != nil {
+			return "", err
+		}
+		if err := a.fs.WriteFile(manifest, []byte(edited), 0o644); err != nil {
+			return "", fmt.Errorf("cannot update %s: %w", m.files[0], err)
+		}
+	}
+	cmd, err := m.add(name, version, dev)
+	if remove {
+		cmd, err = m.remove(name, dev)
+	}
+	if err != nil {
+		return "", err
+	}
+	if rel != "." {
+		cmd = "cd " + shellQuote(filepath.ToSlash(rel)) + " && " + cmd
+	}
+	log.Printf("[agent] 📦 %s: %s\n", m.name, cmd)
+	out, runErr := a.execShell(cmd)
+
+	after, _ := a.fs.ReadFile(manifest)
+	diff := unifiedDiff("a/"+files[0], "b/"+files[0], string(before[files[0]].content), string(after))
+	if runErr != nil {
+		return "", fmt.Errorf("%s failed: %w\n%s\nManifest changes so far:\n%s", cmd, runErr, out, diff)
+	}
+	if diff == "" {
+		diff = fmt.Sprintf("(%s unchanged)", m.files[0])
+	}
+	return fmt.Sprintf("$ %s\n%s\n\n%s", cmd, out, diff), nil
+}
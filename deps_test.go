@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestEditRequirements(t *testing.T) {
+	in := "# web\nrequests==2.0\nFlask_Login>=0.5  # auth\n-r base.txt\n"
+	if got, want := editRequirements(in, "requests", "==2.31.0", false), "# web\nrequests==2.31.0\nFlask_Login>=0.5  # auth\n-r base.txt\n"; got != want {
+		t.Errorf("upgrade =\n%s\nwant\n%s", got, want)
+	}
+	if got, want := editRequirements(in, "flask-login", "", true), "# web\nrequests==2.0\n-r base.txt\n"; got != want {
+		t.Errorf("remove =\n%s\nwant\n%s", got, want)
+	}
+	if got, want := editRequirements("", "rich", "", false), "rich\n"; got != want {
+		t.Errorf("add to empty = %q, want %q", got, want)
+	}
+}
+
+func TestEditPyprojectDependencies(t *testing.T) {
+	in := "[project]\nname = \"app\"\ndependencies = [\"requests>=2\", 'click']\n\n[tool.ruff]\nline-length = 100\n"
+	got, err := editPyprojectDependencies(in, "httpx", pythonSpec("0.27.0"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[project]\nname = \"app\"\ndependencies = [\n    \"requests>=2\",\n    'click',\n    \"httpx==0.27.0\",\n]\n\n[tool.ruff]\nline-length = 100\n"
+	if got != want {
+		t.Errorf("add =\n%s\nwant\n%s", got, want)
+	}
+	got, err = editPyprojectDependencies(got, "Requests", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, got, "dependencies = [\n    'click',\n    \"httpx==0.27.0\",\n]")
+	if _, err := editPyprojectDependencies("[tool.poetry]\n", "x", "", false); err == nil {
+		t.Error("want an error without a [project] dependencies array")
+	}
+}
+
+func TestDependencyManager(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		"go.mod":             "module app\n",
+		"web/package.json":   "{}",
+		"web/pnpm-lock.yaml": "",
+	})
+	m, err := a.dependencyManager(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd, _ := m.add("golang.org/x/text", "", false); cmd != "go get 'golang.org/x/text@latest'" {
+		t.Errorf("go add = %s", cmd)
+	}
+	if _, err := m.add("golang.org/x/text", "", true); err == nil {
+		t.Error("want an error for a Go dev dependency")
+	}
+	if m, err = a.dependencyManager("web"); err != nil {
+		t.Fatal(err)
+	}
+	if cmd, _ := m.add("vitest", "^2", true); cmd != "pnpm add 'vitest@^2' --save-dev" {
+		t.Errorf("pnpm add = %s", cmd)
+	}
+	if _, err := a.changeDependency(".", "x; rm -rf /", "", false, false); err == nil {
+		t.Error("want an error for an invalid dependency name")
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  Container build verification
+  ─────────────────────────────*/
+
+const (
+	dockerDefaultTimeout = 15 * time.Minute // one build
+	dockerMaxRounds      = 3                // fix iterations for a failing build or smoke test
+	dockerSmokeWait      = 10 * time.Second // a started container must still run after this long, or have exited 0
+	maxDockerOutput      = 8000             // characters of build output fed back, from the end where the error is
+)
+
+// dockerConfig is the `docker:` section of zug.yaml.
+type dockerConfig struct {
+	Enabled      bool   `yaml:"enabled"`       // verify the image once the run is otherwise done (also --docker)
+	Binary       string `yaml:"binary"`        // docker (default) or podman
+	File         string `yaml:"file"`          // Dockerfile to build (default: Dockerfile)
+	Compose      string `yaml:"compose"`       // compose file to build, and start for the smoke test, instead
+	Context      string `yaml:"context"`       // build context (default: the project root)
+	Smoke        bool   `yaml:"smoke"`         // start the container: it must keep running, or exit 0
+	SmokeCommand string `yaml:"smoke_command"` // must exit 0: run in the image, or with compose on the host once the services are up
+	Timeout      string `yaml:"timeout"`       // per build, e.g. 20m (default 15m)
+}
+
+func (c dockerConfig) validate() error {
+	if c.Timeout != "" {
+		if d, err := time.ParseDuration(c.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("docker.timeout %q must be a positive duration such as 20m", c.Timeout)
+		}
+	}
+	return nil
+}
+
+func (c dockerConfig) binary() string {
+	if c.Binary != "" {
+		return c.Binary
+	}
+	return "docker"
+}
+
+func (c dockerConfig) file() string {
+	if c.File != "" {
+		return c.File
+	}
+	return "Dockerfile"
+}
+
+func (c dockerConfig) timeout() time.Duration {
+	if d, err := time.ParseDuration(c.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return dockerDefaultTimeout
+}
+
+// dockerRun is the state of container verification in a run.
+type dockerRun struct {
+	rounds  int
+	checked bool
+	failure string // why the last verification failed, "" when it passed
+	smoked  bool   // the last verification included a smoke test that passed
+}
+
+var imageNameInvalid = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// dockerImage is the tag the verification builds: one per project, replaced by each build.
+func (a *AutonomousCodingAgent) dockerImage() string {
+	name := strings.Trim(imageNameInvalid.ReplaceAllString(strings.ToLower(filepath.Base(a.projectDir)), "-"), "-._")
+	if name == "" {
+		name = "app"
+	}
+	return "zug-" + name + ":verify"
+}
+
+// dockerTail keeps the end of a command's output, where builds report their error.
+func dockerTail(out string) string {
+	if len(out) <= maxDockerOutput {
+		return out
+	}
+	cut := len(out) - maxDockerOutput
+	for cut < len(out) && !strings.HasPrefix(out[cut:], "\n") {
+		cut++
+	}
+	return "... (earlier output truncated)" + out[cut:]
+}
+
+// verifyDocker builds the image or compose services and runs the smoke test. It returns
+// what failed, with the output, or "" when everything passed.
+func (a *AutonomousCodingAgent) verifyDocker() string {
+	c := a.cfg.Docker
+	bin := shellQuote(c.binary())
+	if c.Compose != "" {
+		if _, err := a.fs.Stat(filepath.Join(a.projectDir, c.Compose)); err != nil {
+			return fmt.Sprintf("There is no compose file at %s. Write it.", c.Compose)
+		}
+		compose := bin + " compose -f " + shellQuote(c.Compose)
+		log.Printf("[agent] 🐳 Building: %s build\n", compose)
+		if out, timedOut, err := a.execShellWithin(compose+" build", c.timeout()); err != nil {
+			return buildFailure("docker compose build", out, timedOut, c.timeout())
+		}
+		if !c.Smoke && c.SmokeCommand == "" {
+			return ""
+		}
+		log.Printf("[agent] 🐳 Smoke test: %s up --wait\n", compose)
+		defer a.execShell(compose + " down -v --remove-orphans")
+		if out, err := a.execShell(compose + " up -d --wait --wait-timeout 60"); err != nil {
+			logs, _ := a.execShell(compose + " logs --no-color --tail 50")
+			return fmt.Sprintf("The services build, but do not all start and become healthy (docker compose up --wait failed):\n%s\n\nService logs:\n%s", dockerTail(out), dockerTail(logs))
+		}
+		if c.SmokeCommand != "" {
+			return a.smokeCommand("", c.SmokeCommand)
+		}
+		return ""
+	}
+
+	if _, err := a.fs.Stat(filepath.Join(a.projectDir, c.file())); err != nil {
+		return fmt.Sprintf("There is no Dockerfile at %s. Write it.", c.file())
+	}
+	buildContext := c.Context
+	if buildContext == "" {
+		buildContext = "."
+	}
+	image := a.dockerImage()
+	build := fmt.Sprintf("%s build -t %s -f %s %s", bin, image, shellQuote(c.file()), shellQuote(buildContext))
+	log.Printf("[agent] 🐳 Building: %s\n", build)
+	if out, timedOut, err := a.execShellWithin(build, c.timeout()); err != nil {
+		return buildFailure("docker build", out, timedOut, c.timeout())
+	}
+	switch {
+	case c.SmokeCommand != "":
+		return a.smokeCommand(bin+" run --rm "+image+" ", c.SmokeCommand)
+	case c.Smoke:
+		return a.smokeContainer(bin, image)
+	}
+	return ""
+}
+
+func buildFailure(what, out string, timedOut bool, limit time.Duration) string {
+	if timedOut {
+		return fmt.Sprintf("%s did not finish within %s. Make the build faster (smaller context with .dockerignore, cached dependency layers) or raise docker.timeout. Output so far:\n%s", what, limit, dockerTail(out))
+	}
+	return fmt.Sprintf("%s failed. Fix the Dockerfile or the project so that the image builds. Output:\n%s", what, dockerTail(out))
+}
+
+// smokeCommand runs the configured smoke command after prefix, which runs it in the
+// image, or on the host when prefix is empty; it must exit 0.
+func (a *AutonomousCodingAgent) smokeCommand(prefix, smoke string) string {
+	log.Printf("[agent] 🐳 Smoke test: %s\n", smoke)
+	out, err := a.execShell(prefix + smoke)
+	if err != nil {
+		return fmt.Sprintf("The image builds, but the smoke test `%s` failed: %v\n%s", smoke, err, dockerTail(out))
+	}
+	return ""
+}
+
+// smokeContainer starts the image with its own command and requires the container to
+// still run after dockerSmokeWait, as a server does, or to have exited 0, as a tool does.
+func (a *AutonomousCodingAgent) smokeContainer(bin, image string) string {
+	log.Printf("[agent] 🐳 Smoke test: starting %s\n", image)
+	out, err := a.execShell(bin + " run -d " + image)
+	if err != nil {
+		return fmt.Sprintf("The image builds, but the container does not start: %v\n%s", err, dockerTail(out))
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	id := shellQuote(strings.TrimSpace(lines[len(lines)-1]))
+	defer a.execShell(bin + " rm -f " + id)
+	select {
+	case <-time.After(dockerSmokeWait):
+	case <-a.context().Done():
+		return ""
+	}
+	state, _ := a.execShell(bin + " inspect -f '{{.State.Running}} {{.State.ExitCode}}' " + id)
+	if state == "true 0" || state == "false 0" {
+		return ""
+	}
+	logs, _ := a.execShell(bin + " logs --tail 50 " + id)
+	return fmt.Sprintf("The image builds, but the container exited with code %s within %s of starting. Make its command start the application and keep it running. Container logs:\n%s",
+		strings.TrimPrefix(state, "false "), dockerSmokeWait, dockerTail(logs))
+}
+
+// dockerFollowUp verifies the container build once the run is otherwise done, and keeps
+// the run going while it fails, for at most dockerMaxRounds iterations. It returns the
+// next instruction and true in that case.
+func (a *AutonomousCodingAgent) dockerFollowUp() (string, bool) {
+	if !a.cfg.Docker.Enabled {
+		return "", false
+	}
+	if a.docker == nil {
+		a.docker = &dockerRun{}
+	}
+	d := a.docker
+	if !a.installed(a.cfg.Docker.binary()) {
+		log.Printf("[agent] ⚠️ Container verification is enabled, but %s is not installed.\n", a.cfg.Docker.binary())
+		d.checked, d.failure = true, a.cfg.Docker.binary()+" is not installed"
+		return "", false
+	}
+	d.checked, d.failure = true, a.verifyDocker()
+	d.smoked = d.failure == "" && (a.cfg.Docker.Smoke || a.cfg.Docker.SmokeCommand != "")
+	if d.failure == "" {
+		log.Println("[agent] 🐳 The container verification passed.")
+		return "", false
+	}
+	if d.rounds >= dockerMaxRounds {
+		log.Printf("[agent] ⚠️ The container verification still fails after %d fix iterations.\n", d.rounds)
+		return "", false
+	}
+	d.rounds++
+	log.Printf("[agent] 🐳 Container verification failed; asking for a fix (round %d of %d).\n", d.rounds, dockerMaxRounds)
+	return d.failure, true
+}
+
+// dockerOK tells whether container verification, when enabled, passed.
+func (a *AutonomousCodingAgent) dockerOK() bool {
+	return !a.cfg.Docker.Enabled || (a.docker != nil && a.docker.checked && a.docker.failure == "")
+}
+
+// dockerStatus is the Docker line of the run summary, or "" when nothing was verified.
+func (a *AutonomousCodingAgent) dockerStatus() string {
+	d := a.docker
+	if d == nil || !d.checked {
+		return ""
+	}
+	switch {
+	case d.failure != "":
+		first, _, _ := strings.Cut(d.failure, "\n")
+		return fmt.Sprintf("   Docker:   ❌ %s\n", strings.TrimSuffix(first, ":"))
+	case d.smoked:
+		return "   Docker:   ✅ image builds, smoke test passes\n"
+	}
+	return "   Docker:   ✅ image builds\n"
+}
+
+/*──────────────────────────────
+  zug dockerize
+  ─────────────────────────────*/
+
+// dockerizeTask asks for a Dockerfile, and a compose file when wanted, that the
+// verification then builds.
+func dockerizeTask(requirements string, compose, exists bool, smoke string) string {
+	var sb strings.Builder
+	sb.WriteString(`Containerize this project. First read its manifests, entry points and configuration to find the language, how it is built, how it starts, which port it listens on and what it needs at run time. Then write a Dockerfile at the project root that builds and runs the application:
+- a multi-stage build: dependencies and compilation in a build stage, only the artifacts copied into a slim runtime image
+- base images pinned to a version, never :latest
+- dependency manifests copied and installed before the source, so that layer stays cached
+- the application running as a non-root user
+- EXPOSE for the port it listens on, and a CMD or ENTRYPOINT that starts it
+Also write a .dockerignore that leaves out version control, build outputs, installed dependencies, and secrets such as .env files.`)
+	if exists {
+		sb.WriteString("\n\nA Dockerfile exists already: fix and improve it rather than starting over.")
+	}
+	if compose {
+		sb.WriteString("\n\nAlso write docker-compose.yml with the application built from the Dockerfile and the services it needs according to its configuration (databases, caches, queues). Take settings and secrets from environment variables with development defaults, not hard-coded values. Give each service a healthcheck so that `docker compose up --wait` can tell when it is ready.")
+	}
+	sb.WriteString("\n\nWhen you are done, the image is built")
+	if smoke != "" {
+		sb.WriteString(" and " + smoke)
+	}
+	sb.WriteString("; the task is only complete when that succeeds, and failures are sent back to you.")
+	if requirements != "" {
+		sb.WriteString("\n\nAdditional requirements: " + requirements)
+	}
+	return sb.String()
+}
+
+func dockerizeCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	compose := fs.Bool("compose", false, "also write docker-compose.yml with the services the application needs, and verify it with docker compose")
+	smoke := fs.Bool("smoke", false, "after the build, start the container: it must keep running for 10s, or exit 0")
+	smokeCmd := fs.String("smoke-cmd", "", "after the build, run this command in the image, e.g. \"myapp --version\"; with -compose, on the host once the services are up, e.g. \"curl -fsS localhost:8080/health\"; it must exit 0")
+	return func() {
+		agent := cf.newAgent(arg(fs.Args(), 1))
+		c := &agent.cfg.Docker
+		c.Enabled = true
+		if *compose && c.Compose == "" {
+			c.Compose = "docker-compose.yml"
+		}
+		c.Smoke = c.Smoke || *smoke
+		if *smokeCmd != "" {
+			c.SmokeCommand = *smokeCmd
+		}
+		if !agent.installed(c.binary()) {
+			log.Fatalf("❌ zug dockerize verifies the image it writes with %s, which is not installed (set docker.binary in %s for podman).", c.binary(), configFileName)
+		}
+		_, err := agent.fs.Stat(filepath.Join(agent.projectDir, c.file()))
+		smokeNote := ""
+		switch {
+		case c.SmokeCommand != "" && c.Compose != "":
+			smokeNote = fmt.Sprintf("the services are started with `docker compose up --wait`, after which `%s` must exit 0", c.SmokeCommand)
+		case c.SmokeCommand != "":
+			smokeNote = fmt.Sprintf("`%s` is run in it, which must exit 0", c.SmokeCommand)
+		case c.Smoke && c.Compose != "":
+			smokeNote = "the services are started with `docker compose up --wait`, which must report them all healthy"
+		case c.Smoke:
+			smokeNote = fmt.Sprintf("a container is started from it, which must still run after %s or exit 0", dockerSmokeWait)
+		}
+		task := dockerizeTask(strings.TrimSpace(arg(fs.Args(), 0)), c.Compose != "", err == nil, smokeNote)
+
+		trapInterrupts()
+		err = agent.feedbackLoop(task)
+		if err != nil {
+			log.Printf("[agent] ❌ Containerization did not complete: %v\n", err)
+		} else if !agent.dockerOK() {
+			log.Printf("[agent] ❌ The container verification failed: %s\n", agent.docker.failure)
+		}
+		agent.notifyRunEnd(task, err)
+		os.Exit(agent.exitCode(err))
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestDockerBuildFailuresFedBack(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "fake-docker")
+	script := "#!/bin/sh\n[ \"$1\" = build ] || exit 2\ngrep -q '^FROM ' Dockerfile || { echo 'ERROR: no build stage in current context'; exit 1; }\necho 'naming to docker.io/library/app'\n"
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	a, srv := newTestAgent(t, map[string]string{
+		configFileName: "docker:\n  enabled: true\n  binary: " + bin + "\n",
+		"main.py":      "print('hi')\n",
+	},
+		mock.Call("create_file", map[string]any{"path": "Dockerfile", "content": "COPY . /app\nCMD [\"python\", \"/app/main.py\"]\n"}),
+		mock.Text("wrote the Dockerfile"),
+		mock.Call("create_file", map[string]any{"path": "Dockerfile", "overwrite": true, "content": "FROM python:3.12-slim\nCOPY . /app\nCMD [\"python\", \"/app/main.py\"]\n"}),
+		mock.Text("added the base image"),
+		mock.Text(verifyApproved),
+	)
+	if err := a.feedbackLoop(dockerizeTask("", false, false, "")); err != nil {
+		t.Fatal(err)
+	}
+	reqs := srv.Requests()
+	if len(reqs) != 5 {
+		t.Fatalf("got %d requests, want 5", len(reqs))
+	}
+	assertContains(t, reqs[0].Messages[len(reqs[0].Messages)-1].Content, "write a Dockerfile at the project root")
+	followUp := reqs[2].Messages[len(reqs[2].Messages)-1].Content
+	assertContains(t, followUp, "docker build failed.")
+	assertContains(t, followUp, "ERROR: no build stage in current context")
+	if !a.dockerOK() {
+		t.Errorf("docker verification failed: %s", a.docker.failure)
+	}
+	assertContains(t, a.runSummary(), "Docker:   ✅ image builds")
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  zug doctor (environment checks)
+  ─────────────────────────────*/
+
+const (
+	doctorTimeout  = 20 * time.Second // per API request
+	diskSpaceWarn  = 1 << 30          // bytes free below which doctor warns
+	diskSpaceFatal = 100 << 20        // bytes free below which runs are likely to fail
+)
+
+// Outcomes of a doctor check.
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// doctorCheck is the result of one environment check, with what to do about a problem.
+type doctorCheck struct {
+	name, status, detail, fix string
+}
+
+func (c doctorCheck) String() string {
+	icon := map[string]string{checkOK: "✅", checkWarn: "⚠️ ", checkFail: "❌"}[c.status]
+	s := fmt.Sprintf("%s %s: %s", icon, c.name, c.detail)
+	if c.fix != "" && c.status != checkOK {
+		s += "\n   → " + c.fix
+	}
+	return s
+}
+
+// checkAPIKeys resolves the API keys and asks the API about model with each, which
+// checks both that the key is accepted and that it can use the model.
+func checkAPIKeys(model string) []doctorCheck {
+	ring, err := apiKeys()
+	if err != nil {
+		return []doctorCheck{{"API key", checkFail, err.Error(), "see the API credentials section of the README"}}
+	}
+	var checks []doctorCheck
+	for _, k := range ring.keys {
+		checks = append(checks, checkModel(k, model))
+	}
+	return checks
+}
+
+// checkModel asks the API for model with key k. Gateways that do not serve the models
+// endpoint only get a warning, since requests may still work through them.
+func checkModel(k *ringKey, model string) doctorCheck {
+	name := "API key " + k.label
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+	_, err := k.client.GetModel(ctx, model)
+	if err == nil {
+		return doctorCheck{name, checkOK, fmt.Sprintf("accepted, model %s available", model), ""}
+	}
+	status := 0
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	}
+	switch status {
+	case http.StatusUnauthorized:
+		return doctorCheck{name, checkFail, "rejected by the API (401)", "the key is wrong, revoked or for another provider; create a new one and update " + envAPIKey}
+	case http.StatusForbidden:
+		return doctorCheck{name, checkFail, "not allowed to use " + model + " (403)", "check the key's project and its model permissions, or pick another model with -model"}
+	case http.StatusNotFound:
+		list, lerr := k.client.ListModels(ctx)
+		if lerr != nil {
+			return doctorCheck{name, checkWarn, "could not check model " + model + ": the API does not list models", "if runs fail with an unknown model, check the model name your gateway expects"}
+		}
+		return doctorCheck{name, checkFail, "model " + model + " is not available to this key", suggestModels(model, list.Models)}
+	case 0:
+		return doctorCheck{name, checkFail, "API not reachable: " + err.Error(), "check the network, " + envBaseURL + " and the proxy settings (HTTPS_PROXY, " + envCABundle + ")"}
+	}
+	return doctorCheck{name, checkWarn, fmt.Sprintf("unexpected answer: %v", err), "try again later; if it persists, check the provider's status page"}
+}
+
+// suggestModels names available models of the same family as model.
+func suggestModels(model string, models []openai.Model) string {
+	family := model
+	if i := strings.IndexAny(model[min(len(model), 1):], "-."); i >= 0 {
+		family = model[:i+1]
+	}
+	var ids []string
+	for _, m := range models {
+		if strings.HasPrefix(m.ID, family) {
+			ids = append(ids, m.ID)
+		}
+	}
+	slices.Sort(ids)
+	if len(ids) == 0 {
+		return "pick a model the key can use with -model or OPENAI_MODEL"
+	}
+	if len(ids) > 8 {
+		ids = ids[:8]
+	}
+	return "use one of: " + strings.Join(ids, ", ")
+}
+
+// checkGit checks that git is installed and whether the project is a repository.
+func checkGit(dir string) doctorCheck {
+	out, err := exec.Command("git", "--version").Output()
+	if err != nil {
+		return doctorCheck{"git", checkFail, "not installed", "install git: checkpoints, undo, zug commit and the worktree features need it"}
+	}
+	version := strings.TrimPrefix(strings.TrimSpace(string(out)), "git version ")
+	if _, err := gitCmd(dir, "rev-parse", "--show-toplevel"); err != nil {
+		return doctorCheck{"git", checkWarn, version + ", but " + dir + " is not a repository", "run git init there so changes can be reviewed and undone with git"}
+	}
+	return doctorCheck{"git", checkOK, version, ""}
+}
+
+// checkSandbox checks the --sandbox ns backend. It is only required when zug.yaml
+// restricts the network, which the sandbox enforces.
+func checkSandbox(policy string) doctorCheck {
+	err := validSandbox(sandboxNS)
+	if err == nil {
+		return doctorCheck{"sandbox", checkOK, "bubblewrap works; --sandbox " + sandboxNS + " is available", ""}
+	}
+	status := checkWarn
+	if policy == netDeny || policy == netAllowlist {
+		status = checkFail
+	}
+	fix := "install bubblewrap (e.g. apt install bubblewrap)"
+	switch {
+	case runtime.GOOS != "linux":
+		fix = "run zug on Linux, or in a Linux container, to isolate commands"
+	case strings.Contains(err.Error(), "namespaces"):
+		fix = "enable unprivileged user namespaces: sysctl -w kernel.unprivileged_userns_clone=1 (Debian/Ubuntu) or kernel.apparmor_restrict_unprivileged_userns=0 (Ubuntu 24.04)"
+	}
+	if status == checkFail {
+		fix += "; network: " + policy + " in " + configFileName + " needs it"
+	}
+	return doctorCheck{"sandbox", status, err.Error(), fix}
+}
+
+// checkDiskSpace checks the free space where the project and zug's state live.
+func checkDiskSpace(label, dir string) doctorCheck {
+	name := "disk space (" + label + ")"
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		return doctorCheck{name, checkWarn, "cannot tell: " + err.Error(), ""}
+	}
+	detail := fmt.Sprintf("%.1f GB free in %s", float64(free)/(1<<30), dir)
+	switch {
+	case free < diskSpaceFatal:
+		return doctorCheck{name, checkFail, detail, "free up space: builds, checkpoints and worktrees will fail"}
+	case free < diskSpaceWarn:
+		return doctorCheck{name, checkWarn, detail, "free up space; worktrees and caches of larger projects need more"}
+	}
+	return doctorCheck{name, checkOK, detail, ""}
+}
+
+// shellBuiltins start command lines without naming a program doctor could look for.
+var shellBuiltins = []string{"cd", "source", ".", "export", "set", "env", "[", "test", "true"}
+
+// commandProgram is the program a shell command line starts, skipping leading variable
+// assignments, or "" when it starts with a shell builtin.
+func commandProgram(cmd string) string {
+	for _, f := range strings.Fields(cmd) {
+		if !strings.Contains(f, "=") {
+			if slices.Contains(shellBuiltins, f) {
+				return ""
+			}
+			return f
+		}
+	}
+	return ""
+}
+
+// programInstalled reports whether prog is on the PATH or, for a path like ./gradlew, is
+// a file in dir.
+func (a *AutonomousCodingAgent) programInstalled(dir, prog string) bool {
+	if strings.ContainsRune(prog, '/') {
+		_, err := a.fs.Stat(filepath.Join(dir, prog))
+		return err == nil
+	}
+	return a.installed(prog)
+}
+
+// checkTestCommands checks that the program of each test command is installed and, with
+// run set, that the tests pass as they are.
+func (a *AutonomousCodingAgent) checkTestCommands(run bool) []doctorCheck {
+	var checks []doctorCheck
+	for _, t := range a.checkTargets() {
+		name := "tests"
+		if t.root != nil {
+			name += " (" + filepath.Clean(t.root.Path) + ")"
+		}
+		cmd := a.testCommand(t)
+		switch prog := commandProgram(cmd); {
+		case cmd == "":
+			checks = append(checks, doctorCheck{name, checkWarn, "no test command detected", "set test.command in " + configFileName + " (zug init writes a starter), so the agent can check its work"})
+			continue
+		case prog != "" && !a.programInstalled(t.dir, prog):
+			checks = append(checks, doctorCheck{name, checkFail, fmt.Sprintf("%q needs %s, which is not installed", cmd, prog), "install " + prog + " or change test.command in " + configFileName})
+			continue
+		case !run:
+			checks = append(checks, doctorCheck{name, checkOK, fmt.Sprintf("%q (not run; use -tests to run it)", cmd), ""})
+			continue
+		}
+		out, err := a.execShellIn(t, cmd)
+		if err != nil {
+			checks = append(checks, doctorCheck{name, checkWarn, fmt.Sprintf("%q fails before any change: %v\n%s", cmd, err, lastLines(out, 10)), "fix the tests or the environment first, or the agent will chase failures it did not cause"})
+			continue
+		}
+		checks = append(checks, doctorCheck{name, checkOK, fmt.Sprintf("%q passes", cmd), ""})
+	}
+	return checks
+}
+
+func doctorCommand(fs *flag.FlagSet) func() {
+	dir := fs.String("dir", ".", "project directory to check")
+	model := fs.String("model", "", "model to check (default: OPENAI_MODEL or "+openai.GPT4o+")")
+	tests := fs.Bool("tests", false, "also run the test command, to check that the tests pass before any change")
+	offline := fs.Bool("offline", false, "skip the checks that call the model API")
+	return func() {
+		root, err := filepath.Abs(*dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		name := cmp.Or(os.Getenv("OPENAI_MODEL"), *model, openai.GPT4o)
+
+		var checks []doctorCheck
+		if !*offline {
+			checks = append(checks, checkAPIKeys(name)...)
+		}
+		checks = append(checks, checkGit(root))
+		cfg, cfgErr := loadConfig(root)
+		if cfgErr != nil {
+			checks = append(checks, doctorCheck{configFileName, checkFail, cfgErr.Error(), "fix the file; every run stops on it"})
+			cfg = &config{}
+		} else if fileExists(filepath.Join(root, configFileName)) {
+			checks = append(checks, doctorCheck{configFileName, checkOK, "valid", ""})
+		}
+		checks = append(checks, checkSandbox(cfg.Network.Policy), checkDiskSpace("project", root))
+		if home, err := userZugDir(); err == nil {
+			checks = append(checks, checkDiskSpace("zug state", home))
+		}
+		// The test commands depend on the configuration, so they are not guessed from a broken one.
+		if cfgErr == nil {
+			a := &AutonomousCodingAgent{projectDir: root, cfg: cfg, fs: osFS{}, changes: map[string]*fileChange{}}
+			checks = append(checks, a.checkTestCommands(*tests)...)
+		}
+
+		failed := 0
+		for _, c := range checks {
+			fmt.Println(c)
+			if c.status == checkFail {
+				failed++
+			}
+		}
+		if failed > 0 {
+			fmt.Printf("\n%d problem(s) found.\n", failed)
+			os.Exit(1)
+		}
+		fmt.Println("\nNo problems found.")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckModelSuggestsAvailableModels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("Authorization") != "Bearer sk-good":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"message": "Incorrect API key provided", "type": "invalid_request_error"}}`))
+		case r.URL.Path == "/v1/models":
+			w.Write([]byte(`{"object": "list", "data": [{"id": "gpt-4.1-mini"}, {"id": "gpt-4o"}, {"id": "o3"}]}`))
+		case r.URL.Path == "/v1/models/gpt-4o":
+			w.Write([]byte(`{"id": "gpt-4o"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"message": "The model does not exist", "type": "invalid_request_error"}}`))
+		}
+	}))
+	defer srv.Close()
+	t.Setenv(envBaseURL, srv.URL+"/v1")
+
+	ring, err := newKeyRing([]string{"sk-good", "sk-bad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := checkModel(ring.keys[0], "gpt-4o"); c.status != checkOK {
+		t.Errorf("gpt-4o: %s", c)
+	}
+	c := checkModel(ring.keys[0], "gpt-4.5-preview")
+	if c.status != checkFail || c.fix != "use one of: gpt-4.1-mini, gpt-4o" {
+		t.Errorf("unknown model: %s", c)
+	}
+	if c := checkModel(ring.keys[1], "gpt-4o"); c.status != checkFail || !strings.Contains(c.detail, "401") {
+		t.Errorf("bad key: %s", c)
+	}
+}
+
+func TestCheckTestCommands(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		"zug.yaml":         "roots:\n  - path: api\n    test: FOO=1 zug-no-such-runner ./...\n  - path: web\n    test: ./run-tests.sh\n  - path: lib\n    test: cd src && go test\n",
+		"web/run-tests.sh": "#!/bin/sh\n",
+	})
+	checks := a.checkTestCommands(false)
+	if len(checks) != 3 {
+		t.Fatalf("checks = %v", checks)
+	}
+	if checks[0].status != checkFail || !strings.Contains(checks[0].detail, "zug-no-such-runner") {
+		t.Errorf("missing runner: %s", checks[0])
+	}
+	if checks[1].status != checkOK || checks[2].status != checkOK {
+		t.Errorf("installed runners: %s / %s", checks[1], checks[2])
+	}
+}
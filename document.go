@@ -0,0 +1,566 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/*──────────────────────────────
+  Documentation mode (zug document)
+  ─────────────────────────────*/
+
+const documentMaxRounds = 3 // follow-up iterations for missing docs or changed code
+
+// missingDoc is a public symbol without a proper doc comment.
+type missingDoc struct {
+	path   string
+	line   int
+	name   string
+	kind   string
+	reason string // what is wrong: no doc comment, or the comment's form
+}
+
+func (m missingDoc) String() string {
+	return fmt.Sprintf("%s:%d: %s %s: %s", m.path, m.line, m.kind, m.name, m.reason)
+}
+
+// documentRun is the state of zug document: the files to document and the fingerprint
+// of their code without comments, which must not change.
+type documentRun struct {
+	files        []string
+	fingerprints map[string]string // by path; files that cannot be fingerprinted are left out
+	rounds       int
+}
+
+// documentable reports whether zug document knows the doc conventions of path's language.
+func documentable(rel string) bool {
+	switch strings.ToLower(filepath.Ext(rel)) {
+	case ".go", ".py", ".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx", ".mts", ".cts":
+		return !isTestFile(rel)
+	}
+	return false
+}
+
+// documentTargets lists the source files of target: the file itself, or the files of a
+// directory (a package), including its subdirectories when recursive.
+func (a *AutonomousCodingAgent) documentTargets(target string, recursive bool) ([]string, error) {
+	full, err := a.absPath(target)
+	if err != nil {
+		return nil, err
+	}
+	info, err := a.fs.Stat(full)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", target, err)
+	}
+	target = filepath.ToSlash(filepath.Clean(target))
+	if !info.IsDir() {
+		if !documentable(target) {
+			return nil, fmt.Errorf("%s is not a Go, Python, JavaScript or TypeScript source file", target)
+		}
+		return []string{target}, nil
+	}
+	all, err := a.projectFiles()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, rel := range all {
+		rel = filepath.ToSlash(rel)
+		inside := path.Dir(rel) == target || target == "." && !strings.Contains(rel, "/")
+		if recursive {
+			inside = target == "." || strings.HasPrefix(rel, target+"/")
+		}
+		if inside && documentable(rel) {
+			files = append(files, rel)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s holds no Go, Python, JavaScript or TypeScript source files", target)
+	}
+	return files, nil
+}
+
+// missingDocs lists the public symbols of the files without a proper doc comment.
+func (a *AutonomousCodingAgent) missingDocs(files []string) []missingDoc {
+	var out []missingDoc
+	for _, rel := range files {
+		raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, filepath.FromSlash(rel)))
+		if err != nil {
+			continue
+		}
+		src := string(raw)
+		switch ext := strings.ToLower(filepath.Ext(rel)); ext {
+		case ".go":
+			out = append(out, goMissingDocs(rel, src)...)
+		case ".py":
+			out = append(out, a.pyMissingDocs(rel, src)...)
+		default:
+			out = append(out, jsMissingDocs(rel, src)...)
+		}
+	}
+	return out
+}
+
+// goMissingDocs follows godoc: exported functions, methods of exported types, types,
+// constants and variables need a comment that starts with their name. A group of
+// constants or variables may share one comment.
+func goMissingDocs(rel, src string) []missingDoc {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, rel, src, parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+	var out []missingDoc
+	check := func(doc *ast.CommentGroup, pos token.Pos, ident, name, kind string, articles bool) {
+		m := missingDoc{path: rel, line: fset.Position(pos).Line, name: name, kind: kind}
+		text := ""
+		if doc != nil {
+			text = doc.Text()
+		}
+		if articles {
+			for _, art := range []string{"A ", "An ", "The "} {
+				text = strings.TrimPrefix(text, art)
+			}
+		}
+		switch {
+		case doc == nil:
+			m.reason = "no doc comment"
+		case !strings.HasPrefix(text, ident+" ") && !strings.HasPrefix(text, ident+"\n") && text != ident:
+			m.reason = fmt.Sprintf("doc comment should start with %q", ident)
+		default:
+			return
+		}
+		out = append(out, m)
+	}
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				check(d.Doc, d.Pos(), d.Name.Name, d.Name.Name, "func", false)
+				continue
+			}
+			if recv := receiverName(d.Recv.List[0].Type); ast.IsExported(recv) {
+				check(d.Doc, d.Pos(), d.Name.Name, recv+"."+d.Name.Name, "method", false)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if !s.Name.IsExported() {
+						continue
+					}
+					doc := s.Doc
+					if doc == nil && !d.Lparen.IsValid() {
+						doc = d.Doc
+					}
+					check(doc, s.Pos(), s.Name.Name, s.Name.Name, "type", true)
+				case *ast.ValueSpec:
+					if s.Doc != nil || d.Doc != nil && d.Lparen.IsValid() {
+						continue // documented itself, or by its group's comment
+					}
+					for _, n := range s.Names {
+						if n.IsExported() {
+							check(d.Doc, s.Pos(), n.Name, n.Name, d.Tok.String(), false)
+							break
+						}
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+// pyDocScript lists the public functions, classes and methods without a docstring, or,
+// with "code", dumps the syntax tree without docstrings.
+const pyDocScript = `import ast, json, sys
+tree = ast.parse(sys.stdin.read())
+if sys.argv[1] == "missing":
+    out = []
+    def visit(body, prefix):
+        for n in body:
+            if isinstance(n, (ast.FunctionDef, ast.AsyncFunctionDef, ast.ClassDef)) and not n.name.startswith("_"):
+                if ast.get_docstring(n) is None:
+                    kind = "class" if isinstance(n, ast.ClassDef) else ("method" if prefix else "function")
+                    out.append([n.lineno, prefix + n.name, kind])
+                if isinstance(n, ast.ClassDef):
+                    visit(n.body, prefix + n.name + ".")
+    visit(tree.body, "")
+    print(json.dumps(out))
+else:
+    for n in ast.walk(tree):
+        if isinstance(n, (ast.Module, ast.FunctionDef, ast.AsyncFunctionDef, ast.ClassDef)) and ast.get_docstring(n, clean=False) is not None:
+            n.body = n.body[1:] or [ast.Pass()]
+    print(ast.dump(tree))`
+
+// pyDoc runs pyDocScript in mode on src with the local python3.
+func (a *AutonomousCodingAgent) pyDoc(mode, src string) (string, error) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		return "", errNoSyntaxChecker
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), syntaxTimeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, "python3", "-c", pyDocScript, mode)
+	c.Stdin = strings.NewReader(src)
+	var out, errOut bytes.Buffer
+	c.Stdout, c.Stderr = &out, &errOut
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(errOut.String()))
+	}
+	return out.String(), nil
+}
+
+// pyMissingDocs follows PEP 257: public functions, classes and methods need a
+// docstring. Without python3 nothing is reported.
+func (a *AutonomousCodingAgent) pyMissingDocs(rel, src string) []missingDoc {
+	out, err := a.pyDoc("missing", src)
+	if err != nil {
+		return nil
+	}
+	var rows [][]any
+	if json.Unmarshal([]byte(out), &rows) != nil {
+		return nil
+	}
+	var missing []missingDoc
+	for _, r := range rows {
+		line, _ := r[0].(float64)
+		name, _ := r[1].(string)
+		kind, _ := r[2].(string)
+		missing = append(missing, missingDoc{path: rel, line: int(line), name: name, kind: kind, reason: "no docstring"})
+	}
+	return missing
+}
+
+var jsExportPattern = regexp.MustCompile(`^export\s+(?:default\s+)?(?:declare\s+)?(?:async\s+)?(?:abstract\s+)?(function\*?|class|interface|type|enum|const|let|var)\s+([A-Za-z_$][\w$]*)`)
+
+// jsMissingDocs follows JSDoc: exported declarations need a /** */ comment right above
+// them, decorators aside.
+func jsMissingDocs(rel, src string) []missingDoc {
+	lines := strings.Split(src, "\n")
+	var out []missingDoc
+	for i, line := range lines {
+		m := jsExportPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		j := i - 1
+		for j >= 0 && strings.HasPrefix(strings.TrimSpace(lines[j]), "@") {
+			j--
+		}
+		documented := false
+		if j >= 0 && strings.HasSuffix(strings.TrimSpace(lines[j]), "*/") {
+			for k := j; k >= 0; k-- {
+				if t := strings.TrimSpace(lines[k]); strings.Contains(t, "/*") {
+					documented = strings.Contains(t, "/**")
+					break
+				}
+			}
+		}
+		if !documented {
+			kind := strings.TrimSuffix(m[1], "*")
+			out = append(out, missingDoc{path: rel, line: i + 1, name: m[2], kind: kind, reason: "no JSDoc comment"})
+		}
+	}
+	return out
+}
+
+// codeFingerprint is the code of a file without its comments and docstrings, so that
+// two versions have the same fingerprint when only documentation differs between them.
+func (a *AutonomousCodingAgent) codeFingerprint(rel, src string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(rel)); ext {
+	case ".go":
+		var s scanner.Scanner
+		fset := token.NewFileSet()
+		var errs scanner.ErrorList
+		s.Init(fset.AddFile(rel, -1, len(src)), []byte(src), func(pos token.Position, msg string) { errs.Add(pos, msg) }, 0)
+		var sb strings.Builder
+		for {
+			_, tok, lit := s.Scan()
+			if tok == token.EOF {
+				break
+			}
+			if tok == token.SEMICOLON {
+				lit = "" // explicit or inserted at a line end
+			}
+			sb.WriteString(tok.String() + " " + lit + "\n")
+		}
+		if len(errs) > 0 {
+			return "", errs.Err()
+		}
+		return sb.String(), nil
+	case ".py":
+		return a.pyDoc("code", src)
+	}
+	return strings.Join(strings.Fields(stripCComments(src)), " "), nil
+}
+
+// stripCComments removes // and /* */ comments outside of string literals. Regular
+// expression literals are not recognized, which at worst makes a fingerprint keep a
+// comment-like part of one, the same before and after.
+func stripCComments(src string) string {
+	var sb strings.Builder
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; {
+		case c == '"' || c == '\'' || c == '`':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			end := min(j+1, len(src))
+			sb.WriteString(src[i:end])
+			i = end - 1
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			sb.WriteByte('\n')
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return sb.String()
+			}
+			i += end + 3
+			sb.WriteByte(' ')
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// startDocument records the files to document and their fingerprints.
+func (a *AutonomousCodingAgent) startDocument(files []string) {
+	d := &documentRun{files: files, fingerprints: map[string]string{}}
+	for _, rel := range files {
+		raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, filepath.FromSlash(rel)))
+		if err != nil {
+			continue
+		}
+		if fp, err := a.codeFingerprint(rel, string(raw)); err == nil {
+			d.fingerprints[rel] = fp
+		}
+	}
+	a.document = d
+}
+
+// docLinters run the doc linter of a language where installed; their findings add to
+// zug's own check.
+var docLinters = []struct {
+	bin, ext, cmd string
+	pattern       *regexp.Regexp // path, line, message
+}{
+	{"revive", ".go", "revive -formatter unix", regexp.MustCompile(`^(.+?):(\d+):\d+: (.*(?:comment|should be of the form).*)$`)},
+	{"ruff", ".py", "ruff check --no-cache --select D1 --output-format concise", regexp.MustCompile(`^(.+?):(\d+):\d+: (D1\d\d .*)$`)},
+}
+
+// lintDocs runs the installed doc linters on the files and returns their findings.
+func (a *AutonomousCodingAgent) lintDocs(files []string) []string {
+	var findings []string
+	for _, l := range docLinters {
+		var targets []string
+		for _, f := range files {
+			if strings.ToLower(filepath.Ext(f)) == l.ext {
+				targets = append(targets, f)
+			}
+		}
+		if len(targets) == 0 || !a.installed(l.bin) {
+			continue
+		}
+		out, _ := a.execShell(l.cmd + " " + shellJoin(targets)) // linters exit non-zero on findings
+		for _, line := range strings.Split(out, "\n") {
+			if m := l.pattern.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+				findings = append(findings, fmt.Sprintf("%s:%s: %s (%s)", filepath.ToSlash(m[1]), m[2], m[3], l.bin))
+			}
+		}
+	}
+	return findings
+}
+
+// documentProblems lists what keeps the documentation from being done: public symbols
+// still undocumented, doc linter findings, and files whose code changed or that are not
+// among the files to document.
+func (a *AutonomousCodingAgent) documentProblems() (missing []missingDoc, lint, changedCode, outside []string) {
+	d := a.document
+	missing = a.missingDocs(d.files)
+	lint = a.lintDocs(d.files)
+	targets := map[string]bool{}
+	for _, f := range d.files {
+		targets[f] = true
+	}
+	for _, c := range a.netChanges() {
+		rel := filepath.ToSlash(c.path)
+		if !targets[rel] {
+			outside = append(outside, rel)
+			continue
+		}
+		before, ok := d.fingerprints[rel]
+		if !ok {
+			continue
+		}
+		raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, filepath.FromSlash(rel)))
+		if err != nil {
+			changedCode = append(changedCode, rel)
+			continue
+		}
+		if after, err := a.codeFingerprint(rel, string(raw)); err != nil || after != before {
+			changedCode = append(changedCode, rel)
+		}
+	}
+	return missing, lint, changedCode, outside
+}
+
+// documentFollowUp keeps a documentation run going while symbols are undocumented or the
+// code itself was changed. It returns the next instruction and true in that case.
+func (a *AutonomousCodingAgent) documentFollowUp() (string, bool) {
+	if a.document == nil {
+		return "", false
+	}
+	missing, lint, changedCode, outside := a.documentProblems()
+	if len(missing)+len(lint)+len(changedCode)+len(outside) == 0 {
+		log.Println("[agent] 📝 Every public symbol is documented, and only comments changed.")
+		return "", false
+	}
+	if a.document.rounds >= documentMaxRounds {
+		log.Printf("[agent] ⚠️ Documentation still incomplete after %d follow-ups.\n", a.document.rounds)
+		return "", false
+	}
+	a.document.rounds++
+	var sb strings.Builder
+	sb.WriteString("The documentation is not done yet.")
+	if len(changedCode) > 0 {
+		fmt.Fprintf(&sb, "\n\nYou changed code, not just comments, in: %s. Restore the code exactly as it was and only add or edit comments and docstrings.", strings.Join(changedCode, ", "))
+	}
+	if len(outside) > 0 {
+		fmt.Fprintf(&sb, "\n\nYou changed files that are not being documented: %s. Restore them to their original content.", strings.Join(outside, ", "))
+	}
+	if len(missing) > 0 {
+		sb.WriteString("\n\nThese public symbols still need a doc comment:")
+		for _, m := range missing {
+			sb.WriteString("\n- " + m.String())
+		}
+	}
+	if len(lint) > 0 {
+		sb.WriteString("\n\nThe doc linter reports:")
+		for _, l := range lint {
+			sb.WriteString("\n- " + l)
+		}
+	}
+	log.Printf("[agent] 📝 %d undocumented symbol(s), %d linter finding(s), %d file(s) with changed code; asking for a fix.\n", len(missing), len(lint), len(changedCode)+len(outside))
+	return sb.String(), true
+}
+
+// documentReport describes the outcome of zug document.
+func (a *AutonomousCodingAgent) documentReport() string {
+	missing, lint, changedCode, outside := a.documentProblems()
+	var sb strings.Builder
+	sb.WriteString("📝 Documentation report\n")
+	fmt.Fprintf(&sb, "   Files:    %d\n", len(a.document.files))
+	switch {
+	case len(changedCode)+len(outside) > 0:
+		fmt.Fprintf(&sb, "   Code:     ❌ changed beyond comments in %s\n", strings.Join(append(changedCode, outside...), ", "))
+	default:
+		sb.WriteString("   Code:     ✅ unchanged; only comments and docstrings were added\n")
+	}
+	if len(missing)+len(lint) == 0 {
+		sb.WriteString("   Docs:     ✅ every public symbol is documented\n")
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "   Docs:     ⚠️ %d problem(s) remain\n", len(missing)+len(lint))
+	for _, m := range missing {
+		fmt.Fprintf(&sb, "     - %s\n", m)
+	}
+	for _, l := range lint {
+		fmt.Fprintf(&sb, "     - %s\n", l)
+	}
+	return sb.String()
+}
+
+// documentTask asks for doc comments on the missing symbols in the conventions of
+// each language.
+func documentTask(files []string, missing []missingDoc) string {
+	byFile := map[string][]missingDoc{}
+	for _, m := range missing {
+		byFile[m.path] = append(byFile[m.path], m)
+	}
+	paths := make([]string, 0, len(byFile))
+	for p := range byFile {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Document the public API of %s.\n\n", strings.Join(files, ", "))
+	sb.WriteString(`Add doc comments to the symbols listed below, in the idiom of each language:
+- Go: a // comment directly above the declaration, in full sentences, starting with the symbol's name ("// ParseConfig reads ...").
+- Python: a """docstring""" as the first statement of the function, class or method (PEP 257): a one-line summary, then arguments, return value and raised exceptions where they are not obvious.
+- JavaScript and TypeScript: a /** JSDoc */ block directly above the declaration, with @param and @returns for functions.
+
+Read each symbol's code first and describe what it does and why a caller would use it, not how it is implemented, and not what its name already says. Only add or edit comments and docstrings: do not change any code, formatting aside, and do not touch other files.
+
+Symbols to document:
+`)
+	for _, p := range paths {
+		for _, m := range byFile[p] {
+			sb.WriteString("- " + m.String() + "\n")
+		}
+	}
+	return sb.String()
+}
+
+/*──────────────────────────────
+  zug document
+  ─────────────────────────────*/
+
+func documentCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	recursive := fs.Bool("r", false, "for a directory, also document the files in its subdirectories")
+	return func() {
+		target := strings.TrimSpace(arg(fs.Args(), 0))
+		if target == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+		agent := cf.newAgent(arg(fs.Args(), 1))
+		files, err := agent.documentTargets(target, *recursive)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		missing := agent.missingDocs(files)
+		if len(missing) == 0 {
+			fmt.Printf("✅ Every public symbol in %s is documented (%d file(s)).\n", target, len(files))
+			os.Exit(exitVerified)
+		}
+		log.Printf("[agent] 📝 %d undocumented public symbol(s) in %d file(s).\n", len(missing), len(files))
+		agent.startDocument(files)
+
+		task := documentTask(files, missing)
+		err = agent.feedbackLoop(task)
+		if err != nil {
+			log.Printf("[agent] ❌ Documentation did not complete: %v\n", err)
+		}
+		fmt.Println(agent.documentReport())
+		agent.notifyRunEnd(task, err)
+		os.Exit(agent.exitCode(err))
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestMissingDocs(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		"calc/calc.go": `package calc
+
+// Add returns the sum of a and b.
+func Add(a, b int) int { return a + b }
+
+// subtracts b from a.
+func Sub(a, b int) int { return a - b }
+
+func helper() {}
+
+type Acc struct{}
+
+func (*Acc) Reset() {}
+
+// Limits of the calculator.
+const (
+	Max = 10
+	Min = 0
+)
+`,
+		"calc/calc_test.go": "package calc\n\nfunc TestAdd() {}\n",
+		"tools.py":          "def public():\n    \"\"\"Documented.\"\"\"\n\ndef bare():\n    pass\n\ndef _private():\n    pass\n\nclass Shape:\n    def area(self):\n        return 0\n",
+		"web/api.ts":        "/** Fetches a user. */\nexport async function getUser() {}\n\n// not JSDoc\nexport class Client {}\n\n@Injectable()\nexport class Service {}\n",
+	})
+	files, err := a.documentTargets(".", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(files, ",") != "calc/calc.go,tools.py,web/api.ts" {
+		t.Fatalf("documentTargets = %q", files)
+	}
+	var got []string
+	for _, m := range a.missingDocs(files) {
+		got = append(got, m.String())
+	}
+	want := []string{
+		`calc/calc.go:7: func Sub: doc comment should start with "Sub"`,
+		"calc/calc.go:11: type Acc: no doc comment",
+		"calc/calc.go:13: method Acc.Reset: no doc comment",
+		"tools.py:4: function bare: no docstring",
+		"tools.py:10: class Shape: no docstring",
+		"tools.py:11: method Shape.area: no docstring",
+		"web/api.ts:5: class Client: no JSDoc comment",
+		"web/api.ts:8: class Service: no JSDoc comment",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("missingDocs =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCodeFingerprintIgnoresComments(t *testing.T) {
+	a, _ := newTestAgent(t, nil)
+	for _, c := range []struct {
+		path, before, documented, changed string
+	}{
+		{"a.go", "package a\n\nconst (\n\tX = 1\n\tY = 2\n)\n\nfunc F() int { return X }\n",
+			"package a\n\nconst (\n\t// X is one.\n\tX = 1\n\n\t// Y is two.\n\tY = 2\n)\n\n// F returns X.\nfunc F() int { return X } // always\n",
+			"package a\n\nconst (\n\tX = 1\n\tY = 2\n)\n\nfunc F() int { return Y }\n"},
+		{"a.py", "def f(x):\n    return x\n", "def f(x):\n    \"\"\"Return x.\"\"\"\n    # unchanged\n    return x\n", "def f(x):\n    return -x\n"},
+		{"a.ts", "export const s = \"// not a comment\";\nexport function f() { return 1 }\n",
+			"/** A string. */\nexport const s = \"// not a comment\";\n/**\n * Returns 1.\n */\nexport function f() { return 1 }\n",
+			"export const s = \"// not a comment\";\nexport function f() { return 2 }\n"},
+	} {
+		before, err := a.codeFingerprint(c.path, c.before)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := a.codeFingerprint(c.path, c.documented); got != before {
+			t.Errorf("%s: adding comments changed the fingerprint:\n%s\n---\n%s", c.path, before, got)
+		}
+		if got, _ := a.codeFingerprint(c.path, c.changed); got == before {
+			t.Errorf("%s: a code change kept the fingerprint", c.path)
+		}
+	}
+}
+
+func TestDocumentMustNotChangeCode(t *testing.T) {
+	a, srv := newTestAgent(t, map[string]string{
+		configFileName: "test:\n  command: \"true\"\n",
+		"calc.go":      "package calc\n\nfunc Add(a, b int) int { return a + b }\n",
+	},
+		mock.Call("create_file", map[string]any{"path": "calc.go", "overwrite": true,
+			"content": "package calc\n\n// Add returns the sum of a and b.\nfunc Add(a, b int) int { return b + a }\n"}),
+		mock.Text("documented"),
+		mock.Call("create_file", map[string]any{"path": "calc.go", "overwrite": true,
+			"content": "package calc\n\n// Add returns the sum of a and b.\nfunc Add(a, b int) int { return a + b }\n"}),
+		mock.Text("restored the code"),
+	)
+	files := []string{"calc.go"}
+	a.startDocument(files)
+	if err := a.feedbackLoop(documentTask(files, a.missingDocs(files))); err != nil {
+		t.Fatal(err)
+	}
+	reqs := srv.Requests()
+	if len(reqs) != 4 {
+		t.Fatalf("%d requests, want a second turn after the code was changed", len(reqs))
+	}
+	assertContains(t, reqs[0].Messages[len(reqs[0].Messages)-1].Content, "- calc.go:3: func Add: no doc comment")
+	assertContains(t, reqs[2].Messages[len(reqs[2].Messages)-1].Content, "You changed code, not just comments, in: calc.go.")
+	report := a.documentReport()
+	assertContains(t, report, "Code:     ✅ unchanged")
+	assertContains(t, report, "Docs:     ✅ every public symbol is documented")
+}
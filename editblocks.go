@@ -0,0 +1,337 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*──────────────────────────────
+  SEARCH/REPLACE block edits (tool)
+  ─────────────────────────────*/
+
+var (
+	searchMarker  = regexp.MustCompile(`^<{5,9} ?SEARCH\s*$`)
+	dividerMarker = regexp.MustCompile(`^={5,9}\s*$`)
+	replaceMarker = regexp.MustCompile(`^>{5,9} ?REPLACE\s*$`)
+)
+
+// editBlock is one SEARCH/REPLACE pair; both sides are whole lines ending in "\n" (or empty).
+type editBlock struct {
+	search, replace string
+}
+
+// parseEditBlocks reads blocks of the form
+//
+//	<<<<<<< SEARCH
+//	exact lines from the file
+//	=======
+//	new lines
+//	>>>>>>> REPLACE
+func parseEditBlocks(text string) ([]editBlock, error) {
+	var blocks []editBlock
+	var cur *editBlock
+	var inReplace bool
+	for i, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		switch {
+		case searchMarker.MatchString(line):
+			if cur != nil {
+				return nil, fmt.Errorf("line %d: new SEARCH marker before the previous block was closed with >>>>>>> REPLACE", i+1)
+			}
+			cur, inReplace = &editBlock{}, false
+		case cur != nil && !inReplace && dividerMarker.MatchString(line):
+			inReplace = true
+		case cur != nil && inReplace && replaceMarker.MatchString(line):
+			blocks = append(blocks, *cur)
+			cur = nil
+		case cur != nil && inReplace:
+			cur.replace += line + "\n"
+		case cur != nil:
+			cur.search += line + "\n"
+		}
+	}
+	if cur != nil {
+		return nil, fmt.Errorf("unterminated block: every <<<<<<< SEARCH needs a ======= and a >>>>>>> REPLACE line")
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no SEARCH/REPLACE blocks found; expected <<<<<<< SEARCH, =======, >>>>>>> REPLACE marker lines")
+	}
+	return blocks, nil
+}
+
+// applyEditBlock replaces the single exact occurrence of b.search in src, falling back to a
+// fuzzy match for whitespace or trivial drift. note describes a fuzzy match, if one was used.
+func applyEditBlock(src string, b editBlock) (dst, note string, err error) {
+	switch n := strings.Count(src, b.search); {
+	case n == 1:
+		return strings.Replace(src, b.search, b.replace, 1), "", nil
+	case n > 1:
+		return "", "", fmt.Errorf("SEARCH text matches %d places; include more surrounding lines so it is unique", n)
+	}
+	if dst, note, ok := fuzzyReplace(src, b.search, b.replace); ok {
+		return dst, note, nil
+	}
+	lines := splitLines(src)
+	start, end, score := closestMatch(lines, splitLines(b.search))
+	if end == 0 {
+		return "", "", fmt.Errorf("SEARCH text not found and the file has nothing similar")
+	}
+	return "", "", fmt.Errorf("SEARCH text not found. The most similar lines are %d-%d (%.0f%% similar):\n```\n%s```\nCopy the exact current text (use read_file) into SEARCH and retry",
+		start+1, end, score*100, strings.Join(lines[start:end], "\n")+"\n")
+}
+
+// editFile applies SEARCH/REPLACE blocks in order. It is all-or-nothing: if any block
+// fails, the file is left untouched. A single block with an empty SEARCH creates a new file.
+func (a *AutonomousCodingAgent) editFile(path, edits string) (string, error) {
+	full, err := a.absPath(path)
+	if err != nil {
+		return "", err
+	}
+	blocks, err := parseEditBlocks(edits)
+	if err != nil {
+		return "", err
+	}
+	raw, err := a.fs.ReadFile(full)
+	if os.IsNotExist(err) && len(blocks) == 1 && blocks[0].search == "" {
+		if err := a.fs.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			return "", fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		raw, err = nil, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s for edit: %w", path, err)
+	}
+
+	src := string(raw)
+	noFinalNewline := src != "" && !strings.HasSuffix(src, "\n")
+	if noFinalNewline {
+		src += "\n"
+	}
+	dst := src
+	var notes []string
+	for i, b := range blocks {
+		if b.search == "" && dst != "" {
+			return "", fmt.Errorf("block %d of %d: empty SEARCH is only allowed to create a new file; %s already has content", i+1, len(blocks), path)
+		}
+		if b.search == "" {
+			dst = b.replace
+			continue
+		}
+		var note string
+		if dst, note, err = applyEditBlock(dst, b); err != nil {
+			return "", fmt.Errorf("block %d of %d in %s (no changes were written): %w", i+1, len(blocks), path, err)
+		}
+		if note != "" {
+			notes = append(notes, fmt.Sprintf("\nblock %d: %s", i+1, note))
+		}
+	}
+	if noFinalNewline {
+		dst = strings.TrimSuffix(dst, "\n")
+	}
+	if err := a.validateWrite(path, full, dst); err != nil {
+		return "", err
+	}
+
+	a.trackChange(path, full)
+	dst, formatter := a.formatSource(path, dst)
+	if err := a.fs.WriteFile(full, []byte(dst), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write updated content to %s: %w", path, err)
+	}
+	return fmt.Sprintf("applied %d edit block(s) to %s%s%s%s", len(blocks), path, strings.Join(notes, ""), formattedNote(formatter), a.diagnosticsNote(path, full)), nil
+}
+
+/*──────────────────────────────
+  Line edits (tool)
+  ─────────────────────────────*/
+
+// numberLines prefixes every line of src with its number, the form read_file returns
+// with line_numbers so replace_lines can address lines.
+func numberLines(src string) string {
+	lines := strings.Split(strings.TrimSuffix(src, "\n"), "\n")
+	width := len(fmt.Sprint(len(lines)))
+	var sb strings.Builder
+	for i, l := range lines {
+		fmt.Fprintf(&sb, "%*d| %s\n", width, i+1, l)
+	}
+	return sb.String()
+}
+
+// replaceLines replaces lines start..end (1-based, inclusive) of path with content. An
+// end of start-1 inserts before start without removing anything.
+func (a *AutonomousCodingAgent) replaceLines(path string, start, end int, content string) (string, error) {
+	full, err := a.absPath(path)
+	if err != nil {
+		return "", err
+	}
+	raw, err := a.fs.ReadFile(full)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s for replace_lines: %w", path, err)
+	}
+	src := string(raw)
+	finalNewline := src == "" || strings.HasSuffix(src, "\n")
+	lines := strings.Split(strings.TrimSuffix(src, "\n"), "\n")
+	if src == "" {
+		lines = nil
+	}
+	if start < 1 || start > len(lines)+1 || end < start-1 || end > len(lines) {
+		return "", fmt.Errorf("lines %d-%d are out of range: %s has %d lines (read it with line_numbers=true)", start, end, path, len(lines))
+	}
+	var repl []string
+	if content != "" {
+		repl = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	out := append(append(append([]string{}, lines[:start-1]...), repl...), lines[end:]...)
+	dst := strings.Join(out, "\n")
+	if finalNewline && len(out) > 0 {
+		dst += "\n"
+	}
+	if err := a.validateWrite(path, full, dst); err != nil {
+		return "", err
+	}
+
+	a.trackChange(path, full)
+	dst, formatter := a.formatSource(path, dst)
+	if err := a.fs.WriteFile(full, []byte(dst), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write updated content to %s: %w", path, err)
+	}
+	return fmt.Sprintf("replaced lines %d-%d of %s with %d line(s)%s%s", start, end, path, len(repl), formattedNote(formatter), a.diagnosticsNote(path, full)), nil
+}
+
+/*──────────────────────────────
+  Similarity helpers
+  ─────────────────────────────*/
+
+const fuzzyThreshold = 0.9 // minimum line similarity for applying an inexact match
+
+// fuzzyReplace replaces the lines of src that match the lines of find once surrounding
+// whitespace is ignored, or failing that, the one window at least fuzzyThreshold similar.
+// ok is false when there is no
+// single confident match.
+func fuzzyReplace(src, find, replace string) (dst, note string, ok bool) {
+	have, want := splitLines(src), splitLines(find)
+	if len(want) == 0 || len(want) > len(have) {
+		return "", "", false
+	}
+	start, end, score := -1, 0, 0.0
+	for s := 0; s+len(want) <= len(have); s++ {
+		same := true
+		for j, w := range want {
+			if strings.TrimSpace(have[s+j]) != strings.TrimSpace(w) {
+				same = false
+				break
+			}
+		}
+		if same {
+			if start >= 0 {
+				return "", "", false // ambiguous even ignoring whitespace
+			}
+			start, end, score = s, s+len(want), 1
+		}
+	}
+	if start < 0 {
+		if start, end, score = closestMatch(have, want); end == 0 || score < fuzzyThreshold {
+			return "", "", false
+		}
+		for s := 0; s+len(want) <= len(have); s++ {
+			if s+len(want) <= start || s >= end {
+				if _, _, other := closestMatch(have[s:s+len(want)], want); other >= fuzzyThreshold {
+					return "", "", false
+				}
+			}
+		}
+	}
+
+	// Shift the replacement only when it shares the search text's (wrong) base indentation.
+	repl := splitLines(replace)
+	from, to := leadingSpace(firstNonBlank(want)), leadingSpace(firstNonBlank(have[start:end]))
+	shift := from != to && leadingSpace(firstNonBlank(repl)) == from
+	var out []string
+	out = append(out, have[:start]...)
+	for _, l := range repl {
+		if shift && strings.HasPrefix(l, from) {
+			l = to + strings.TrimPrefix(l, from)
+		}
+		out = append(out, l)
+	}
+	out = append(out, have[end:]...)
+	dst = strings.Join(out, "\n")
+	if strings.HasSuffix(src, "\n") && len(out) > 0 {
+		dst += "\n"
+	}
+	if score == 1 {
+		note = fmt.Sprintf("matched lines %d-%d ignoring whitespace differences", start+1, end)
+	} else {
+		note = fmt.Sprintf("no exact match; applied to the closest lines %d-%d (%.0f%% similar), check the result", start+1, end, score*100)
+	}
+	return dst, note, true
+}
+
+func firstNonBlank(lines []string) string {
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			return l
+		}
+	}
+	return ""
+}
+
+func leadingSpace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
+
+// closestMatch finds the window of len(want) lines in have that is most similar to want,
+// comparing lines with surrounding whitespace removed. end is 0 when nothing is comparable.
+func closestMatch(have, want []string) (start, end int, score float64) {
+	n := len(want)
+	if n == 0 || len(have) == 0 {
+		return 0, 0, 0
+	}
+	if n > len(have) {
+		n = len(have)
+	}
+	trimmedWant := make([]string, len(want))
+	for i, w := range want {
+		trimmedWant[i] = strings.TrimSpace(w)
+	}
+	best := -1.0
+	for s := 0; s+n <= len(have); s++ {
+		total := 0.0
+		for j := 0; j < n; j++ {
+			total += similarity(strings.TrimSpace(have[s+j]), trimmedWant[j])
+		}
+		if avg := total / float64(len(want)); avg > best {
+			best, start, end = avg, s, s+n
+		}
+	}
+	return start, end, best
+}
+
+// similarity is 1 - normalized Levenshtein distance between a and b.
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/*──────────────────────────────
+  Environment variables and .env.example
+  ─────────────────────────────*/
+
+const (
+	envExampleFile   = ".env.example" // written when the project has no example file yet
+	envUsedInComment = "# used in "   // comment env-sync writes above the variables it adds
+	envRefsShown     = 3              // places listed per variable
+)
+
+// envPattern finds the environment variables read by files with one of exts. The first
+// non-empty group of re is the variable's name.
+type envPattern struct {
+	exts []string
+	re   *regexp.Regexp
+}
+
+var envPatterns = []envPattern{
+	{[]string{".go"}, regexp.MustCompile(`\bos\.(?:Getenv|LookupEnv)\(\s*"([A-Za-z_][A-Za-z0-9_]*)"`)},
+	{[]string{".py"}, regexp.MustCompile(`\b(?:os\.getenv|environ\.get|environ\.setdefault)\(\s*['"]([A-Za-z_][A-Za-z0-9_]*)['"]|\benviron\[\s*['"]([A-Za-z_][A-Za-z0-9_]*)['"]\s*\]`)},
+	{[]string{".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx", ".mts", ".cts", ".vue", ".svelte"},
+		regexp.MustCompile(`\b(?:process\.env|import\.meta\.env)(?:\.([A-Za-z_][A-Za-z0-9_]*)|\[\s*['"]([A-Za-z_][A-Za-z0-9_]*)['"]\s*\])`)},
+	{[]string{".rs"}, regexp.MustCompile(`\b(?:env::var(?:_os)?|env!|option_env!)\(\s*"([A-Za-z_][A-Za-z0-9_]*)"`)},
+	{[]string{".rb"}, regexp.MustCompile(`\bENV(?:\[\s*|\.fetch\(\s*)['"]([A-Za-z_][A-Za-z0-9_]*)['"]`)},
+	{[]string{".java", ".kt", ".scala"}, regexp.MustCompile(`\bSystem\.getenv\(\s*"([A-Za-z_][A-Za-z0-9_]*)"`)},
+	{[]string{".php"}, regexp.MustCompile(`\bgetenv\(\s*['"]([A-Za-z_][A-Za-z0-9_]*)['"]|\$_(?:ENV|SERVER)\[\s*['"]([A-Za-z_][A-Za-z0-9_]*)['"]\s*\]`)},
+}
+
+// envDefined matches a variable in a .env file, also when it is commented out, which is
+// how example files document optional settings.
+var envDefined = regexp.MustCompile(`^\s*(#\s*)?(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)\s*=`)
+
+// systemEnvVars are set by the operating system or shell rather than by the project's
+// configuration, so they do not belong in .env.example.
+var systemEnvVars = map[string]bool{
+	"HOME": true, "PATH": true, "USER": true, "USERNAME": true, "PWD": true, "SHELL": true, "TERM": true,
+	"LANG": true, "TZ": true, "TMPDIR": true, "TMP": true, "TEMP": true, "HOSTNAME": true, "EDITOR": true,
+	"XDG_CONFIG_HOME": true, "XDG_CACHE_HOME": true, "XDG_DATA_HOME": true, "APPDATA": true, "USERPROFILE": true,
+}
+
+// envReference is a place where code reads an environment variable.
+type envReference struct {
+	path string
+	line int
+}
+
+func (r envReference) String() string { return fmt.Sprintf("%s:%d", r.path, r.line) }
+
+// envReferences finds the environment variables read by the source files under dir,
+// which is relative to the project. Tests and sensitive files are skipped: the values
+// in .env files are never read.
+func (a *AutonomousCodingAgent) envReferences(dir string) (map[string][]envReference, error) {
+	list, err := a.projectFiles()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	refs := map[string][]envReference{}
+	for _, rel := range list {
+		rel = filepath.ToSlash(rel)
+		if dir != "." && !strings.HasPrefix(rel, dir+"/") {
+			continue
+		}
+		if _, denied := a.sensitivePath(rel); denied || isTestFile(rel) {
+			continue
+		}
+		ext := strings.ToLower(path.Ext(rel))
+		i := slices.IndexFunc(envPatterns, func(p envPattern) bool { return slices.Contains(p.exts, ext) })
+		if i < 0 {
+			continue
+		}
+		full := filepath.Join(a.projectDir, filepath.FromSlash(rel))
+		if info, err := a.fs.Stat(full); err != nil || info.Size() > symbolFileLimit {
+			continue
+		}
+		raw, err := a.fs.ReadFile(full)
+		if err != nil {
+			continue
+		}
+		for n, line := range strings.Split(string(raw), "\n") {
+			for _, m := range envPatterns[i].re.FindAllStringSubmatch(line, -1) {
+				name := strings.Join(m[1:], "")
+				r := envReference{rel, n + 1}
+				if name == "" || systemEnvVars[name] || slices.Contains(refs[name], r) {
+					continue
+				}
+				refs[name] = append(refs[name], r)
+			}
+		}
+	}
+	return refs, nil
+}
+
+// envExampleVars lists the variables an example .env file defines.
+func envExampleVars(src string) map[string]bool {
+	vars := map[string]bool{}
+	for _, line := range strings.Split(src, "\n") {
+		if m := envDefined.FindStringSubmatch(line); m != nil {
+			vars[m[2]] = true
+		}
+	}
+	return vars
+}
+
+// findEnvExample returns the example .env file of dir (relative to the project): the
+// first of the allowed example names that exists, else .env.example.
+func (a *AutonomousCodingAgent) findEnvExample(dir string) string {
+	for _, name := range defaultAllowedPaths {
+		rel := path.Join(filepath.ToSlash(dir), name)
+		if _, err := a.fs.Stat(filepath.Join(a.projectDir, filepath.FromSlash(rel))); err == nil {
+			return rel
+		}
+	}
+	return path.Join(filepath.ToSlash(dir), envExampleFile)
+}
+
+// envSync compares the variables the code under an example file's directory reads with
+// the ones the file defines.
+type envSync struct {
+	file    string // the example file, relative to the project
+	exists  bool
+	content string
+	refs    map[string][]envReference
+	missing []string // read by the code, not in the file
+	unused  []string // in the file, not read by the code
+}
+
+// compareEnvExample reads the example file rel, which must not be a sensitive file, and
+// the code next to it.
+func (a *AutonomousCodingAgent) compareEnvExample(rel string) (*envSync, error) {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	if pattern, denied := a.sensitivePath(rel); denied {
+		return nil, fmt.Errorf("%s matches the sensitive path %q; zug never reads real .env files, only examples such as %s", rel, pattern, envExampleFile)
+	}
+	s := &envSync{file: rel}
+	raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, filepath.FromSlash(rel)))
+	if err == nil {
+		s.exists, s.content = true, string(raw)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if s.refs, err = a.envReferences(path.Dir(rel)); err != nil {
+		return nil, err
+	}
+	declared := envExampleVars(s.content)
+	for name := range s.refs {
+		if !declared[name] {
+			s.missing = append(s.missing, name)
+		}
+	}
+	for name := range declared {
+		if _, ok := s.refs[name]; !ok {
+			s.unused = append(s.unused, name)
+		}
+	}
+	sort.Strings(s.missing)
+	sort.Strings(s.unused)
+	return s, nil
+}
+
+// usedIn lists the first places name is read, for a comment or a report.
+func (s *envSync) usedIn(name string) string {
+	refs := s.refs[name]
+	shown := make([]string, 0, envRefsShown)
+	for i, r := range refs {
+		if i == envRefsShown {
+			shown = append(shown, fmt.Sprintf("and %d more", len(refs)-i))
+			break
+		}
+		shown = append(shown, r.String())
+	}
+	return strings.Join(shown, ", ")
+}
+
+// synced is the example file with the missing variables added, each with an empty value
+// and where it is used, and, with prune, the unused ones removed.
+func (s *envSync) synced(prune bool) string {
+	lines := strings.Split(strings.TrimRight(s.content, "\n"), "\n")
+	if s.content == "" {
+		lines = nil
+	}
+	if prune {
+		var kept []string
+		for _, line := range lines {
+			if m := envDefined.FindStringSubmatch(line); m != nil && m[1] == "" && slices.Contains(s.unused, m[2]) {
+				// Drop the comment env-sync wrote for it too.
+				if n := len(kept); n > 0 && strings.HasPrefix(kept[n-1], envUsedInComment) {
+					kept = kept[:n-1]
+				}
+				continue
+			}
+			kept = append(kept, line)
+		}
+		lines = kept
+	}
+	for _, name := range s.missing {
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, envUsedInComment+s.usedIn(name), name+"=")
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// envVarsReport describes the environment variables the code reads and how they compare
+// with the project's example .env file. It is the env_vars tool.
+func (a *AutonomousCodingAgent) envVarsReport(dir string) (string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	s, err := a.compareEnvExample(a.findEnvExample(dir))
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(s.refs))
+	for name := range s.refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Environment variables read by the code (%d):", len(names))
+	if len(names) == 0 {
+		sb.WriteString(" none")
+	}
+	for _, name := range names {
+		fmt.Fprintf(&sb, "\n  %s: %s", name, s.usedIn(name))
+	}
+	if !s.exists {
+		fmt.Fprintf(&sb, "\nThere is no %s yet.", s.file)
+	}
+	if len(s.missing) > 0 {
+		fmt.Fprintf(&sb, "\nMissing from %s (%d): %s", s.file, len(s.missing), strings.Join(s.missing, ", "))
+	}
+	if len(s.unused) > 0 {
+		fmt.Fprintf(&sb, "\nIn %s but not read by the code (%d): %s", s.file, len(s.unused), strings.Join(s.unused, ", "))
+	}
+	if s.exists && len(s.missing) == 0 && len(s.unused) == 0 {
+		fmt.Fprintf(&sb, "\n%s is in sync with the code.", s.file)
+	}
+	sb.WriteString("\nWhen you add a variable, add it to the example file with a placeholder value, never a real secret. Real .env files cannot be read.")
+	return sb.String(), nil
+}
+
+func envSyncCommand(fs *flag.FlagSet) func() {
+	dir := fs.String("dir", ".", "project directory")
+	file := fs.String("file", "", "example file to keep in sync, relative to the project; the code under its directory is scanned (default: .env.example, or the existing .env.sample, .env.template or .env.dist)")
+	prune := fs.Bool("prune", false, "also remove variables the code no longer reads")
+	check := fs.Bool("check", false, "only report the differences, and exit 1 when the file is out of sync (for CI)")
+	return func() {
+		root, err := filepath.Abs(*dir)
+		if err != nil {
+			log.Fatalf("FATAL: Could not resolve project directory %s: %v", *dir, err)
+		}
+		cfg, err := loadConfig(root)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		a := &AutonomousCodingAgent{projectDir: root, cfg: cfg, fs: osFS{}, changes: map[string]*fileChange{}}
+		rel := *file
+		if rel == "" {
+			rel = a.findEnvExample(".")
+		}
+		s, err := a.compareEnvExample(rel)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		for _, name := range s.missing {
+			fmt.Printf("+ %s (%s)\n", name, s.usedIn(name))
+		}
+		if *prune {
+			for _, name := range s.unused {
+				fmt.Printf("- %s (not read by the code)\n", name)
+			}
+		}
+		outOfSync := len(s.missing) > 0 || (*prune && len(s.unused) > 0)
+		switch {
+		case !outOfSync:
+			fmt.Printf("%s is in sync with the %d variable(s) the code reads.\n", s.file, len(s.refs))
+			if len(s.unused) > 0 {
+				fmt.Printf("%d variable(s) in it are not read by the code; -prune removes them.\n", len(s.unused))
+			}
+		case *check:
+			fmt.Printf("%s is out of sync; run zug env-sync to update it.\n", s.file)
+			os.Exit(1)
+		default:
+			full := filepath.Join(root, filepath.FromSlash(s.file))
+			if err := os.WriteFile(full, []byte(s.synced(*prune)), 0o644); err != nil {
+				log.Fatalf("❌ Could not write %s: %v", full, err)
+			}
+			fmt.Printf("Updated %s. Fill in placeholder values for the new variables, never real secrets.\n", s.file)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvExampleSync(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		"main.go":         "package main\n\nimport \"os\"\n\nvar addr = os.Getenv(\"LISTEN_ADDR\")\nvar home = os.Getenv(\"HOME\")\n",
+		"app/db.py":       "import os\nurl = os.environ[\"DATABASE_URL\"]\ndebug = os.getenv('DEBUG', '0')\n",
+		"web/client.ts":   "export const api = process.env.API_URL ?? import.meta.env['API_URL'];\n",
+		"app/test_db.py":  "import os\nos.environ.get('TEST_ONLY')\n",
+		".env":            "DATABASE_URL=postgres://admin:hunter2@db/prod\n",
+		".env.example":    "# Where the server listens\nLISTEN_ADDR=:8080\n# OPTIONAL_FLAG=\n" + envUsedInComment + "old.go:1\nOLD_TOKEN=\n",
+		"docs/config.md":  "Set process.env.NOT_CODE.\n",
+		"services/x/a.go": "package x\n",
+	})
+
+	report, err := a.envVarsReport("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, report, "API_URL: web/client.ts:1")
+	assertContains(t, report, "Missing from .env.example (3): API_URL, DATABASE_URL, DEBUG")
+	assertContains(t, report, "not read by the code (2): OLD_TOKEN, OPTIONAL_FLAG")
+	for _, leak := range []string{"hunter2", "HOME", "TEST_ONLY", "NOT_CODE"} {
+		if strings.Contains(report, leak) {
+			t.Errorf("report mentions %s:\n%s", leak, report)
+		}
+	}
+
+	s, err := a.compareEnvExample(".env.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Where the server listens\nLISTEN_ADDR=:8080\n# OPTIONAL_FLAG=\n\n" +
+		envUsedInComment + "web/client.ts:1\nAPI_URL=\n\n" +
+		envUsedInComment + "app/db.py:2\nDATABASE_URL=\n\n" +
+		envUsedInComment + "app/db.py:3\nDEBUG=\n"
+	if got := s.synced(true); got != want {
+		t.Errorf("synced =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := a.compareEnvExample(".env"); err == nil || !strings.Contains(err.Error(), "never reads real .env files") {
+		t.Errorf("reading .env: err = %v", err)
+	}
+}
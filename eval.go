@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"gopkg.in/yaml.v3"
+)
+
+/*──────────────────────────────
+  Evaluation harness
+  ─────────────────────────────*/
+
+const evalFixtureFile = "task.yaml"
+
+// evalFixture is one task of an evaluation suite: a directory with task.yaml and a
+// snapshot of the repository the task runs against.
+type evalFixture struct {
+	Name   string `yaml:"name"`   // default: the directory name
+	Task   string `yaml:"task"`   // what the agent is asked to do
+	Verify string `yaml:"verify"` // shell command that exits 0 when the task is solved
+	Setup  string `yaml:"setup"`  // optional command run in the copy before the agent starts
+	Repo   string `yaml:"repo"`   // snapshot directory, relative to the fixture (default "repo")
+}
+
+// evalVariant is one model and configuration the suite runs against.
+type evalVariant struct {
+	Model  string `json:"model"`
+	Config string `json:"config,omitempty"` // label of the zug.yaml used, "" for the fixture's own
+	path   string // zug.yaml copied into every snapshot
+}
+
+func (v evalVariant) label() string {
+	if v.Config == "" {
+		return v.Model
+	}
+	return v.Model + "+" + v.Config
+}
+
+// evalResult is the outcome of one fixture under one variant.
+type evalResult struct {
+	Fixture  string        `json:"fixture"`
+	Variant  string        `json:"variant"`
+	Passed   bool          `json:"passed"`          // the verify command exited 0
+	Error    string        `json:"error,omitempty"` // why the run or the verification failed
+	ExitCode int           `json:"exit_code"`       // zug's exit code for the run
+	Turns    int           `json:"turns"`
+	Tokens   int           `json:"tokens"`
+	Cost     float64       `json:"cost_usd"` // 0 when the model's price is unknown
+	Duration time.Duration `json:"duration_ns"`
+	Files    int           `json:"files_changed"`
+	Tools    []toolUsage   `json:"tools,omitempty"`
+}
+
+// loadEvalFixtures reads every subdirectory of dir that has a task.yaml, sorted by name.
+func loadEvalFixtures(dir string) ([]evalFixture, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read fixtures: %w", err)
+	}
+	var fixtures []evalFixture
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name(), evalFixtureFile)
+		raw, err := os.ReadFile(path)
+		if !e.IsDir() || os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var fx evalFixture
+		if err := yaml.Unmarshal(raw, &fx); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", path, err)
+		}
+		if strings.TrimSpace(fx.Task) == "" || strings.TrimSpace(fx.Verify) == "" {
+			return nil, fmt.Errorf("%s needs both 'task' and 'verify'", path)
+		}
+		if fx.Name == "" {
+			fx.Name = e.Name()
+		}
+		if fx.Repo == "" {
+			fx.Repo = "repo"
+		}
+		fx.Repo = filepath.Join(dir, e.Name(), fx.Repo)
+		if info, err := os.Stat(fx.Repo); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("%s: the repository snapshot %s is missing", path, fx.Repo)
+		}
+		fixtures = append(fixtures, fx)
+	}
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("no fixtures (subdirectories with %s) in %s", evalFixtureFile, dir)
+	}
+	sort.Slice(fixtures, func(i, j int) bool { return fixtures[i].Name < fixtures[j].Name })
+	return fixtures, nil
+}
+
+// copyTree copies the directory src to dst, keeping file modes (and .git, so fixtures
+// can be git repositories).
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, raw, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+// evalRunner runs fixtures in throwaway copies of their snapshots.
+type evalRunner struct {
+	keys      *apiKeyRing
+	cacheMode string
+	keep      bool // leave the copies on disk for inspection
+}
+
+// run solves fx with v in a fresh copy of its snapshot and verifies the result.
+func (r *evalRunner) run(fx evalFixture, v evalVariant) (res evalResult) {
+	res = evalResult{Fixture: fx.Name, Variant: v.label(), ExitCode: exitFailed}
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start) }()
+
+	dir, err := os.MkdirTemp("", "zug-eval-*")
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	if r.keep {
+		log.Printf("[eval] Keeping the copy of %s for %s in %s\n", fx.Name, v.label(), dir)
+	} else {
+		defer os.RemoveAll(dir)
+	}
+	if err := copyTree(fx.Repo, dir); err != nil {
+		res.Error = fmt.Sprintf("cannot copy the snapshot: %v", err)
+		return res
+	}
+	if v.path != "" {
+		raw, err := os.ReadFile(v.path)
+		if err == nil {
+			err = os.WriteFile(filepath.Join(dir, configFileName), raw, 0o644)
+		}
+		if err != nil {
+			res.Error = fmt.Sprintf("cannot install config %s: %v", v.Config, err)
+			return res
+		}
+	}
+
+	agent := NewAgent(r.keys, dir, v.Model)
+	agent.cacheMode = r.cacheMode
+	if fx.Setup != "" {
+		if out, err := agent.execShell(fx.Setup); err != nil {
+			res.Error = fmt.Sprintf("setup failed: %v\n%s", err, out)
+			return res
+		}
+	}
+	runErr := agent.feedbackLoop(fx.Task)
+	res.ExitCode = agent.exitCode(runErr)
+	res.Turns = agent.status.turn
+	res.Tokens = agent.usage.PromptTokens + agent.usage.CompletionTokens
+	if price, ok := priceFor(agent.model); ok {
+		res.Cost = price.cost(agent.usage.PromptTokens, agent.usage.CompletionTokens)
+	}
+	res.Files = len(agent.netChanges())
+	res.Tools = agent.toolUsage()
+
+	out, err := agent.execShell(fx.Verify)
+	res.Passed = err == nil
+	switch {
+	case err != nil:
+		res.Error = fmt.Sprintf("verification failed: %v\n%s", err, lastLines(out, 20))
+	case runErr != nil:
+		res.Error = fmt.Sprintf("verified, although the run ended with: %v", runErr)
+	}
+	return res
+}
+
+// evalReport renders the comparison of every variant over the suite as Markdown.
+func evalReport(dir string, fixtures []evalFixture, variants []evalVariant, results []evalResult) string {
+	byKey := map[string]evalResult{}
+	for _, r := range results {
+		byKey[r.Fixture+"\x00"+r.Variant] = r
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# zug eval report\n\n**Fixtures:** %s (%d tasks)  \n**Finished:** %s\n\n", dir, len(fixtures), time.Now().Format(time.RFC3339))
+
+	sb.WriteString("| Variant | Passed | Pass rate | Avg turns | Tokens | Cost | Time |\n|---|---|---|---|---|---|---|\n")
+	for _, v := range variants {
+		var passed, turns, tokens int
+		var cost float64
+		var took time.Duration
+		for _, fx := range fixtures {
+			r := byKey[fx.Name+"\x00"+v.label()]
+			if r.Passed {
+				passed++
+			}
+			turns, tokens, cost, took = turns+r.Turns, tokens+r.Tokens, cost+r.Cost, took+r.Duration
+		}
+		fmt.Fprintf(&sb, "| %s | %d/%d | %.0f%% | %.1f | %s | $%.2f | %s |\n", v.label(), passed, len(fixtures),
+			100*float64(passed)/float64(len(fixtures)), float64(turns)/float64(len(fixtures)), compactCount(tokens), cost, took.Round(time.Second))
+	}
+
+	sb.WriteString("\n## Tasks\n\n| Task |")
+	for _, v := range variants {
+		sb.WriteString(" " + v.label() + " |")
+	}
+	sb.WriteString("\n|---|" + strings.Repeat("---|", len(variants)) + "\n")
+	for _, fx := range fixtures {
+		sb.WriteString("| " + fx.Name + " |")
+		for _, v := range variants {
+			r := byKey[fx.Name+"\x00"+v.label()]
+			mark := "❌"
+			if r.Passed {
+				mark = "✅"
+			}
+			fmt.Fprintf(&sb, " %s %d turns · %s |", mark, r.Turns, compactCount(r.Tokens))
+		}
+		sb.WriteString("\n")
+	}
+
+	var failures []string
+	for _, r := range results {
+		if r.Error != "" {
+			failures = append(failures, fmt.Sprintf("### %s · %s\n\n```\n%s\n```\n", r.Fixture, r.Variant, strings.TrimSpace(r.Error)))
+		}
+	}
+	if len(failures) > 0 {
+		sb.WriteString("\n## Failures\n\n" + strings.Join(failures, "\n"))
+	}
+	return sb.String()
+}
+
+/*──────────────────────────────
+  zug eval
+  ─────────────────────────────*/
+
+func evalCommand(fs *flag.FlagSet) func() {
+	models := fs.String("models", "", "comma-separated models to compare (default: -model, $OPENAI_MODEL or the default model)")
+	model := fs.String("model", "", "single model to evaluate")
+	var configs stringList
+	fs.Var(&configs, "config", "compare configurations: label=path/to/zug.yaml, copied into every snapshot; repeatable")
+	report := fs.String("report", "zug-eval-report.md", "file to write the comparison report to")
+	jsonOut := fs.String("json", "", "also write every result as JSON to this file")
+	cache := fs.String("cache", "", `replay identical model requests: "on" or "replay" (see zug run -cache)`)
+	keep := fs.Bool("keep", false, "keep the working copies of the snapshots for inspection")
+	return func() {
+		dir := arg(fs.Args(), 0)
+		if dir == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+		fixtures, err := loadEvalFixtures(dir)
+		if err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		if *cache != "" {
+			if err := validCacheMode(*cache); err != nil {
+				log.Fatalf("FATAL: -cache: %v", err)
+			}
+		}
+		keys, err := apiKeys()
+		if err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+
+		var names []string
+		for _, m := range strings.Split(*models, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				names = append(names, m)
+			}
+		}
+		if len(names) == 0 {
+			switch {
+			case *model != "":
+				names = []string{*model}
+			case os.Getenv("OPENAI_MODEL") != "":
+				names = []string{os.Getenv("OPENAI_MODEL")}
+			default:
+				names = []string{openai.GPT4o} // NewAgent's default, named so the report shows it
+			}
+		}
+		// Each variant names its model; OPENAI_MODEL must not override it.
+		os.Unsetenv("OPENAI_MODEL")
+
+		var variants []evalVariant
+		for _, m := range names {
+			if len(configs) == 0 {
+				variants = append(variants, evalVariant{Model: m})
+			}
+			for _, c := range configs {
+				label, path, ok := strings.Cut(c, "=")
+				if !ok {
+					log.Fatalf("FATAL: -config %q must be label=path", c)
+				}
+				variants = append(variants, evalVariant{Model: m, Config: label, path: path})
+			}
+		}
+
+		runner := &evalRunner{keys: keys, cacheMode: *cache, keep: *keep}
+		var results []evalResult
+		for _, v := range variants {
+			for i, fx := range fixtures {
+				log.Printf("[eval] ▶️ %s: task %d/%d %s\n", v.label(), i+1, len(fixtures), fx.Name)
+				r := runner.run(fx, v)
+				status := "✅ passed"
+				if !r.Passed {
+					status = "❌ failed"
+				}
+				log.Printf("[eval] %s %s with %s in %d turns (%s tokens)\n", status, fx.Name, v.label(), r.Turns, compactCount(r.Tokens))
+				results = append(results, r)
+			}
+		}
+
+		out := evalReport(dir, fixtures, variants, results)
+		fmt.Println(out)
+		if err := os.WriteFile(*report, []byte(out), 0o644); err != nil {
+			log.Fatalf("FATAL: Could not write report to %s: %v", *report, err)
+		}
+		log.Printf("[eval] Report written to %s\n", *report)
+		if *jsonOut != "" {
+			raw, _ := json.MarshalIndent(results, "", "  ")
+			if err := os.WriteFile(*jsonOut, raw, 0o644); err != nil {
+				log.Fatalf("FATAL: Could not write results to %s: %v", *jsonOut, err)
+			}
+		}
+	}
+}
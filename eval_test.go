@@ -0,0 +1,47 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestEvalFixture(t *testing.T) {
+	srv := mock.NewServer(t,
+		mock.Call("update_file", map[string]any{"path": "page.py", "find": "n - 1", "replace": "n"}),
+		mock.Text("fixed the off-by-one"),
+		mock.Text(verifyApproved),
+	)
+	t.Setenv(envBaseURL, srv.URL)
+	t.Setenv("ZUG_HOME", t.TempDir())
+	t.Setenv("ZUG_CACHE", "")
+
+	suite := t.TempDir()
+	writeTestFile(t, suite, "pagination/task.yaml", "task: Fix the off-by-one in page.py\nverify: grep -q 'return n$' page.py\n")
+	writeTestFile(t, suite, "pagination/repo/page.py", "def last(n):\n    return n - 1\n")
+	writeTestFile(t, suite, "notes/README.md", "not a fixture")
+	fixtures, err := loadEvalFixtures(suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) != 1 || fixtures[0].Name != "pagination" || fixtures[0].Repo != filepath.Join(suite, "pagination", "repo") {
+		t.Fatalf("fixtures = %+v", fixtures)
+	}
+
+	keys, err := newKeyRing([]string{"sk-test-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := evalVariant{Model: "mock-model"}
+	r := (&evalRunner{keys: keys}).run(fixtures[0], v)
+	if !r.Passed || r.Turns != 1 || r.Tokens == 0 || r.Files != 1 {
+		t.Fatalf("result = %+v", r)
+	}
+	if got := readTestFile(t, suite, "pagination/repo/page.py"); got != "def last(n):\n    return n - 1\n" {
+		t.Errorf("the run changed the snapshot itself:\n%s", got)
+	}
+	report := evalReport(suite, fixtures, []evalVariant{v}, []evalResult{r})
+	assertContains(t, report, "| mock-model | 1/1 | 100% | 1.0 |")
+	assertContains(t, report, "| pagination | ✅ 1 turns ·")
+}
@@ -0,0 +1,52 @@
+package main
+
+import "errors"
+
+/*──────────────────────────────
+  Exit codes
+  ─────────────────────────────*/
+
+// Exit codes of `zug run`, so CI jobs and wrappers can branch on the outcome. 1 stays the
+// code for any other error and 2 is taken by flag parsing errors.
+const (
+	exitVerified   = 0 // the task is done and the build or tests passed
+	exitFailed     = 1 // any other error
+	exitUnverified = 3 // the model finished, but there was no build or test command to check it
+	exitMaxTurns   = 4 // the feedback loop ran out of turns
+	exitBudget     = 5 // a token budget (preflight.max_tokens) was exceeded
+	exitPolicy     = 6 // the run ended without success after tool calls were blocked by policy
+	exitProvider   = 7 // the model API failed
+	exitTimeout    = 8 // the --timeout limit ran out
+
+	exitInterrupted = 130 // stopped with Ctrl-C, as shells report SIGINT
+)
+
+var (
+	errBudgetExceeded  = errors.New("budget exceeded")
+	errPolicyViolation = errors.New("blocked by policy")
+	errProvider        = errors.New("model provider error")
+)
+
+// exitCode maps the result of feedbackLoop to the process exit code.
+func (a *AutonomousCodingAgent) exitCode(runErr error) int {
+	verified := runErr == nil && a.status.verified
+	switch {
+	case errors.Is(runErr, errInterrupted):
+		return exitInterrupted
+	case errors.Is(runErr, errTimeout):
+		return exitTimeout
+	case errors.Is(runErr, errBudgetExceeded):
+		return exitBudget
+	case errors.Is(runErr, errProvider):
+		return exitProvider
+	case errors.Is(runErr, errPolicyViolation) || (!verified && a.status.policyBlocks > 0):
+		return exitPolicy
+	case errors.Is(runErr, errMaxTurns):
+		return exitMaxTurns
+	case runErr != nil:
+		return exitFailed
+	case !verified:
+		return exitUnverified
+	}
+	return exitVerified
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Repo map
+  ─────────────────────────────*/
+
+const (
+	repoMapLimit     = 20000   // bytes of repo map put in a prompt
+	repoMapMaxSource = 1 << 20 // larger files are listed without their symbols
+)
+
+// repoMap lists the project's files with the definitions in each, one per line
+// ("  func Server.Start:42"), so the model sees the layout of the code before reading
+// any of it. It stops at limit bytes.
+func (a *AutonomousCodingAgent) repoMap(limit int) (string, error) {
+	files, err := a.projectFiles()
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for i, rel := range files {
+		entry := rel + "\n"
+		if hasSymbols(rel) {
+			if raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, rel)); err == nil && len(raw) <= repoMapMaxSource {
+				symbols, _ := fileSymbols(rel, string(raw))
+				for _, s := range symbols {
+					entry += fmt.Sprintf("  %s %s:%d\n", s.kind, s.name, s.line)
+				}
+			}
+		}
+		if sb.Len()+len(entry) > limit {
+			fmt.Fprintf(&sb, "[... %d more files, use list_files]\n", len(files)-i)
+			break
+		}
+		sb.WriteString(entry)
+	}
+	return sb.String(), nil
+}
+
+// findDefinitions returns "path:line kind name" for every definition of symbol ("Name"
+// or "Container.Name") in the project.
+func (a *AutonomousCodingAgent) findDefinitions(symbol string) ([]string, error) {
+	files, err := a.projectFiles()
+	if err != nil {
+		return nil, err
+	}
+	var found []string
+	for _, rel := range files {
+		if !hasSymbols(rel) {
+			continue
+		}
+		raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, rel))
+		if err != nil || len(raw) > repoMapMaxSource {
+			continue
+		}
+		symbols, _ := fileSymbols(rel, string(raw))
+		for _, s := range symbols {
+			if s.name == symbol || (!strings.Contains(symbol, ".") && strings.HasSuffix(s.name, "."+symbol)) {
+				found = append(found, fmt.Sprintf("%s:%d %s %s", rel, s.line, s.kind, s.name))
+			}
+		}
+	}
+	return found, nil
+}
+
+/*──────────────────────────────
+  zug explain
+  ─────────────────────────────*/
+
+// explainPrompt asks for an explanation aimed at someone new to the code.
+func explainPrompt() openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: `You are AutonomousGuide, a senior software engineer onboarding a colleague onto an unfamiliar codebase. You can only read the project: use 'read_file', 'search_files' and 'list_files' (paths relative to the project root), starting from the repo map you are given. Read the code in question and enough of its callers and dependencies to explain it in context; never guess. Reply in Markdown with these sections: "## Purpose" (what it is for, in plain words), "## How it works" (the main flow, step by step, naming functions and files), "## How it fits in" (who calls it, what it depends on, the data it owns), "## Gotchas" (non-obvious behavior, invariants, historical oddities, risky spots), and "## Where to start" (the files and functions to read first to change it safely). Cite files as path:line.`,
+	}
+}
+
+func explainCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	out := fs.String("o", "", "also write the explanation to this file")
+	return func() {
+		target := strings.TrimSpace(arg(fs.Args(), 0))
+		if target == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+		agent := cf.newAgent(arg(fs.Args(), 1))
+		question, err := agent.explainTask(target)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		agent.readOnly = true
+		agent.prompt = explainPrompt()
+
+		log.Printf("[agent] 📖 Explaining %s (read-only)\n", target)
+		answer, err := agent.chat(question, phaseAsk)
+		if err != nil {
+			log.Fatalf("❌ Could not explain %s: %v", target, err)
+		}
+		answer = strings.TrimSpace(answer)
+		fmt.Printf("📖 %s\n\n%s\n", target, answer)
+		if *out != "" {
+			if err := os.WriteFile(*out, []byte(fmt.Sprintf("# %s\n\n%s\n", target, answer)), 0o644); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		}
+	}
+}
+
+// explainTask resolves target to a file, a directory (a subsystem) or the definitions of
+// a symbol, and builds the request with the repo map.
+func (a *AutonomousCodingAgent) explainTask(target string) (string, error) {
+	var what string
+	if full, err := a.absPath(target); err == nil {
+		if info, err := a.fs.Stat(full); err == nil {
+			rel, _ := filepath.Rel(a.projectDir, full)
+			if info.IsDir() {
+				what = fmt.Sprintf("the subsystem in the directory %s/: what its parts do and how they work together", filepath.ToSlash(rel))
+			} else {
+				what = fmt.Sprintf("the file %s", filepath.ToSlash(rel))
+			}
+		}
+	}
+	if what == "" {
+		defs, err := a.findDefinitions(target)
+		if err != nil {
+			return "", err
+		}
+		if len(defs) == 0 {
+			return "", fmt.Errorf("%s is not a file or directory in %s, and no definition of it was found", target, a.projectDir)
+		}
+		what = fmt.Sprintf("the symbol %s, defined at:\n%s", target, strings.Join(defs, "\n"))
+	}
+	repoMap, err := a.repoMap(repoMapLimit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Explain %s\n\nRepo map (files and their definitions with line numbers):\n%s", what, repoMap), nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/http/httpproxy"
+)
+
+/*──────────────────────────────
+  URL fetcher (tool)
+  ─────────────────────────────*/
+
+const (
+	maxFetchDownload = 2 << 20 // bytes read from the response body
+	maxFetchResult   = 20000   // bytes of Markdown returned to the model
+)
+
+// internalIP reports addresses fetch_url does not connect to unless the host is listed
+// in network.allow: loopback, private, link-local (cloud metadata services live at
+// 169.254.169.254) and unspecified ones.
+func internalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// checkFetchTarget applies the network policy to u before fetch_url requests it, and
+// to every redirect it follows.
+func (a *AutonomousCodingAgent) checkFetchTarget(u *url.URL) error {
+	switch a.networkPolicy() {
+	case netDeny:
+		return fmt.Errorf("fetching %s is blocked: the network policy is %s", u.Host, netDeny)
+	case netAllowlist:
+		if !a.cfg.Network.allows(u.Host) {
+			return fmt.Errorf("fetching %s is blocked: the host is not in network.allow", u.Host)
+		}
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && internalIP(ip) && !a.cfg.Network.allows(u.Host) {
+		return fmt.Errorf("fetching %s is blocked: it is an internal address; add it to network.allow in zug.yaml to allow it", u.Host)
+	}
+	return nil
+}
+
+// fetchClient follows the network policy on redirects and refuses to connect to
+// internal addresses, checked after DNS resolution so a public name that resolves to
+// one is caught too. Hosts in network.allow and the configured HTTP proxy are exempt.
+func (a *AutonomousCodingAgent) fetchClient() *http.Client {
+	trusted := map[string]bool{}
+	proxies := httpproxy.FromEnvironment()
+	for _, p := range []string{proxies.HTTPProxy, proxies.HTTPSProxy} {
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "://") {
+			p = "http://" + p
+		}
+		if pu, err := url.Parse(p); err == nil {
+			trusted[pu.Host], trusted[pu.Hostname()] = true, true
+		}
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if trusted[addr] || a.cfg.Network.allows(addr) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		d := *dialer
+		d.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, _ := net.SplitHostPort(address)
+			if ip := net.ParseIP(host); ip != nil && internalIP(ip) {
+				return fmt.Errorf("%s resolves to the internal address %s; add it to network.allow in zug.yaml to allow it", addr, ip)
+			}
+			return nil
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+	return &http.Client{
+		Timeout:   20 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			return a.checkFetchTarget(req.URL)
+		},
+	}
+}
+
+// fetchURL downloads a web page and returns it as size-capped Markdown.
+func (a *AutonomousCodingAgent) fetchURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid URL %q (must be an absolute http or https URL)", rawURL)
+	}
+	if err := a.checkFetchTarget(u); err != nil {
+		return "", err
+	}
+	log.Printf("[agent] fetching %s\n", u)
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "zug (autonomous coding agent)")
+	req.Header.Set("Accept", "text/html,text/plain,text/markdown,application/json;q=0.9,*/*;q=0.5")
+	resp, err := a.fetchClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchDownload))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response from %s: %w", u, err)
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("fetching %s returned HTTP %s", u, resp.Status)
+	}
+
+	text := string(body)
+	if strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		if text, err = htmlToMarkdown(text, u); err != nil {
+			return "", fmt.Errorf("failed to convert %s to Markdown: %w", u, err)
+		}
+	}
+	if len(text) > maxFetchResult {
+		cut := maxFetchResult
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut] + fmt.Sprintf("\n\n... (truncated, %d of %d bytes shown)", cut, len(text))
+	}
+	return fmt.Sprintf("Content of %s:\n\n%s", u, text), nil
+}
+
+/*──────────────────────────────
+  HTML → Markdown
+  ─────────────────────────────*/
+
+// boilerplate elements are dropped entirely.
+var boilerplate = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Nav: true, atom.Header: true,
+	atom.Footer: true, atom.Aside: true, atom.Form: true, atom.Iframe: true, atom.Svg: true,
+	atom.Button: true, atom.Template: true, atom.Head: true,
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// htmlToMarkdown converts the main content of a page to Markdown, dropping boilerplate.
+func htmlToMarkdown(src string, base *url.URL) (string, error) {
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return "", err
+	}
+	root := doc
+	if n := findElement(doc, atom.Main); n != nil {
+		root = n
+	} else if n := findElement(doc, atom.Article); n != nil {
+		root = n
+	}
+	c := &mdConverter{base: base}
+	c.walk(root)
+	out := blankLines.ReplaceAllString(c.sb.String(), "\n\n")
+	return strings.TrimSpace(out), nil
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+type mdConverter struct {
+	sb   strings.Builder
+	base *url.URL
+	pre  int // depth inside <pre>
+	list int // depth inside lists
+}
+
+func (c *mdConverter) block() { c.sb.WriteString("\n\n") }
+
+func (c *mdConverter) walkChildren(n *html.Node) {
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		c.walk(ch)
+	}
+}
+
+func (c *mdConverter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if c.pre > 0 {
+			c.sb.WriteString(n.Data)
+			return
+		}
+		text := strings.Join(strings.Fields(n.Data), " ")
+		if text == "" {
+			return
+		}
+		if strings.HasPrefix(n.Data, " ") || strings.HasPrefix(n.Data, "\n") {
+			text = " " + text
+		}
+		if strings.HasSuffix(n.Data, " ") || strings.HasSuffix(n.Data, "\n") {
+			text += " "
+		}
+		c.sb.WriteString(text)
+		return
+	case html.ElementNode:
+	default:
+		c.walkChildren(n)
+		return
+	}
+	if boilerplate[n.DataAtom] {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		c.block()
+		c.sb.WriteString(strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+		c.walkChildren(n)
+		c.block()
+	case atom.P, atom.Div, atom.Section, atom.Table, atom.Blockquote, atom.Dl:
+		c.block()
+		c.walkChildren(n)
+		c.block()
+	case atom.Br:
+		c.sb.WriteString("\n")
+	case atom.Hr:
+		c.sb.WriteString("\n\n---\n\n")
+	case atom.Pre:
+		c.block()
+		c.sb.WriteString("```\n")
+		c.pre++
+		c.walkChildren(n)
+		c.pre--
+		c.sb.WriteString("\n```")
+		c.block()
+	case atom.Code:
+		if c.pre > 0 {
+			c.walkChildren(n)
+			return
+		}
+		c.sb.WriteString("`")
+		c.walkChildren(n)
+		c.sb.WriteString("`")
+	case atom.Strong, atom.B:
+		c.sb.WriteString("**")
+		c.walkChildren(n)
+		c.sb.WriteString("**")
+	case atom.Em, atom.I:
+		c.sb.WriteString("_")
+		c.walkChildren(n)
+		c.sb.WriteString("_")
+	case atom.Ul, atom.Ol:
+		c.list++
+		c.walkChildren(n)
+		c.list--
+		if c.list == 0 {
+			c.block()
+		}
+	case atom.Li:
+		c.sb.WriteString("\n" + strings.Repeat("  ", max(c.list-1, 0)) + "- ")
+		c.walkChildren(n)
+	case atom.Tr:
+		c.sb.WriteString("\n|")
+		c.walkChildren(n)
+	case atom.Td, atom.Th:
+		c.sb.WriteString(" ")
+		c.walkChildren(n)
+		c.sb.WriteString(" |")
+	case atom.A:
+		href := attr(n, "href")
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+			c.walkChildren(n)
+			return
+		}
+		if ref, err := c.base.Parse(href); err == nil {
+			href = ref.String()
+		}
+		c.sb.WriteString("[")
+		c.walkChildren(n)
+		c.sb.WriteString("](" + href + ")")
+	case atom.Img:
+		if alt := attr(n, "alt"); alt != "" {
+			c.sb.WriteString("[image: " + alt + "]")
+		}
+	default:
+		c.walkChildren(n)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFetchURLBlocksInternalAddresses(t *testing.T) {
+	page := "a" + strings.Repeat("é", maxFetchResult) // the cut falls inside an é
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, page)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	byName := "http://localhost:" + u.Port()
+
+	a, _ := newTestAgent(t, nil)
+	for _, target := range []string{srv.URL, byName, "http://169.254.169.254/latest/meta-data/"} {
+		if _, err := a.fetchURL(target); err == nil || !strings.Contains(err.Error(), "internal address") {
+			t.Errorf("fetch %s: err = %v, want it blocked", target, err)
+		}
+	}
+
+	a.cfg.Network.Allow = []string{u.Host}
+	got, err := a.fetchURL(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utf8.ValidString(got) || !strings.Contains(got, fmt.Sprintf("(truncated, %d of %d bytes shown)", maxFetchResult-1, len(page))) {
+		t.Errorf("truncation: %s", got[len(got)-80:])
+	}
+
+	a.cfg.Network.Policy = netDeny
+	if _, err := a.fetchURL(srv.URL); err == nil || !strings.Contains(err.Error(), "network policy") {
+		t.Errorf("with network: deny, err = %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+/*──────────────────────────────
+  Directory operations (tools)
+  ─────────────────────────────*/
+
+func (a *AutonomousCodingAgent) makeDir(path string) (string, error) {
+	full, err := a.absPath(path)
+	if err != nil {
+		return "", err
+	}
+	if info, err := a.fs.Stat(full); err == nil {
+		if !info.IsDir() {
+			return "", fmt.Errorf("cannot create directory %s: a file with that name exists", path)
+		}
+		return fmt.Sprintf("directory %s already exists", path), nil
+	}
+	if err := a.fs.MkdirAll(full, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", path, err)
+	}
+	return fmt.Sprintf("directory %s created", path), nil
+}
+
+// removeDir deletes a directory. Non-empty directories need recursive=true, and a tree
+// containing sensitive files is never removed.
+func (a *AutonomousCodingAgent) removeDir(path string, recursive bool) (string, error) {
+	full, err := a.absPath(path)
+	if err != nil {
+		return "", err
+	}
+	if full == filepath.Clean(a.projectDir) {
+		return "", fmt.Errorf("refusing to remove the project root")
+	}
+	info, err := a.fs.Stat(full)
+	if err != nil {
+		return "", fmt.Errorf("cannot remove directory %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is a file, not a directory", path)
+	}
+	entries, err := a.fs.ReadDir(full)
+	if err != nil {
+		return "", fmt.Errorf("cannot read directory %s: %w", path, err)
+	}
+	if len(entries) > 0 && !recursive {
+		return "", fmt.Errorf("directory %s is not empty (%d entries); pass recursive=true to delete it with its contents", path, len(entries))
+	}
+
+	var files []string
+	err = walkDir(a.fs, full, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(a.projectDir, p)
+		if pattern, denied := a.sensitivePath(rel); denied {
+			return fmt.Errorf("%w: %s contains %s, which matches the sensitive path pattern %q", errPolicyViolation, path, rel, pattern)
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, rel := range files {
+		a.trackChange(rel, filepath.Join(a.projectDir, rel))
+	}
+	if err := a.fs.RemoveAll(full); err != nil {
+		return "", fmt.Errorf("failed to remove directory %s: %w", path, err)
+	}
+	return fmt.Sprintf("directory %s removed (%d file(s) deleted)", path, len(files)), nil
+}
+
+// setExecutable adds execute permission wherever the file is readable (like chmod +x
+// honoring the current read bits).
+func (a *AutonomousCodingAgent) setExecutable(path string) (string, error) {
+	full, err := a.absPath(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := a.fs.Stat(full)
+	if err != nil {
+		return "", fmt.Errorf("cannot make %s executable: %w", path, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory, not a file", path)
+	}
+	mode := info.Mode().Perm()
+	newMode := mode | (mode&0o444)>>2
+	if newMode == mode {
+		return fmt.Sprintf("%s is already executable (mode %04o)", path, mode), nil
+	}
+	if err := a.fs.Chmod(full, newMode); err != nil {
+		return "", fmt.Errorf("failed to chmod %s: %w", path, err)
+	}
+	return fmt.Sprintf("%s is now executable (mode %04o -> %04o)", path, mode, newMode), nil
+}
+
+// copyFile duplicates a file within the project without routing its content through the
+// model. An existing destination is only replaced with overwrite, after a checkpoint.
+func (a *AutonomousCodingAgent) copyFile(from, to string, overwrite bool) (string, error) {
+	src, err := a.absPath(from)
+	if err != nil {
+		return "", err
+	}
+	dst, err := a.absPath(to)
+	if err != nil {
+		return "", err
+	}
+	info, err := a.fs.Stat(src)
+	if err != nil {
+		return "", fmt.Errorf("cannot copy %s: %w", from, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory; copy_file copies single files", from)
+	}
+	content, err := a.fs.ReadFile(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", from, err)
+	}
+	note := ""
+	if old, err := a.fs.ReadFile(dst); err == nil {
+		if !overwrite {
+			return "", fmt.Errorf("destination %s already exists; pass overwrite=true to replace it", to)
+		}
+		saved, err := a.checkpointFile(to, old)
+		if err != nil {
+			return "", err
+		}
+		note = fmt.Sprintf(" (previous version saved as %s)", saved)
+	}
+	if err := a.fs.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory for %s: %w", to, err)
+	}
+	a.trackChange(to, dst)
+	if err := a.fs.WriteFile(dst, content, info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", to, err)
+	}
+	return fmt.Sprintf("copied %s to %s (%d bytes)%s", from, to, len(content), note), nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  zug fix (clone, run, pull request)
+  ─────────────────────────────*/
+
+const workspacesDirName = "workspaces" // in ~/.zug
+
+// gitAuthEnv passes the host's API token to git as an HTTP header, for cloning private
+// repositories and pushing over https. It is given in the environment rather than on
+// the command line so other users cannot see it in the process list.
+func gitAuthEnv(r *apiRepo, remote string) []string {
+	if r == nil || r.token == "" || !strings.HasPrefix(remote, "https://") {
+		return nil
+	}
+	user := "x-access-token"
+	if r.kind == repoGitLab {
+		user = "oauth2"
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte(user + ":" + r.token))
+	return []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=http.extraHeader", "GIT_CONFIG_VALUE_0=Authorization: Basic " + auth}
+}
+
+// gitWithEnv is gitCmd with extra environment variables.
+func gitWithEnv(dir string, env []string, args ...string) (string, error) {
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	c.Env = append(os.Environ(), env...)
+	out, err := c.CombinedOutput()
+	outputStr := strings.TrimSpace(string(out))
+	if err != nil {
+		return outputStr, fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, outputStr)
+	}
+	return outputStr, nil
+}
+
+// workspaceDir is a fresh directory below ~/.zug/workspaces named after the repository.
+func workspaceDir(project string) (string, error) {
+	dir, err := userZugDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, workspacesDirName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+	return filepath.Join(dir, filepath.Base(project)+"-"+time.Now().Format("20060102-150405")), nil
+}
+
+func fixCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	var opts fixOptions
+	fs.StringVar(&opts.base, "branch", "", "branch to clone and open the pull request against (default: the repository's default branch)")
+	fs.BoolVar(&opts.keep, "keep", false, "keep the workspace after the branch is pushed")
+	fs.BoolVar(&opts.review, "review", false, "run a reviewer pass over the final diff and one more fix iteration")
+	fs.DurationVar(&opts.timeout, "timeout", 0, "stop the run cleanly after this much wall-clock time, e.g. 30m")
+	return func() {
+		remote, task := arg(fs.Args(), 0), strings.TrimSpace(arg(fs.Args(), 1))
+		if remote == "" || task == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+		trapInterrupts()
+		code, err := runFix(cf, remote, task, opts)
+		killChildProcesses()
+		if err != nil {
+			log.Printf("[fix] ❌ %v\n", err)
+		}
+		os.Exit(code)
+	}
+}
+
+// fixOptions are the flags of zug fix besides the common ones.
+type fixOptions struct {
+	base    string
+	keep    bool
+	review  bool
+	timeout time.Duration
+}
+
+// runFix clones remote into a fresh workspace, runs the task there on a new branch, pushes
+// the branch and opens a pull request against the branch it was cloned from. The pull
+// request needs a GitHub or GitLab remote and a token; without them the branch is only
+// pushed. The workspace is kept when the run fails. It returns the exit code.
+func runFix(cf commonFlags, remote, task string, opts fixOptions) (int, error) {
+	repo, err := parseRepoSpec(remote)
+	if err == nil {
+		err = repo.connect()
+	}
+	name := strings.TrimSuffix(filepath.Base(strings.TrimRight(remote, "/")), ".git")
+	if err != nil {
+		log.Printf("[fix] ⚠️ %v; the branch will be pushed, but no pull request opened.\n", err)
+		repo = nil
+	} else {
+		name = filepath.Base(repo.project)
+	}
+	ws, err := workspaceDir(name)
+	if err != nil {
+		return exitFailed, err
+	}
+
+	cloneArgs := []string{"clone", "--depth", "1", "--quiet"}
+	if opts.base != "" {
+		cloneArgs = append(cloneArgs, "--branch", opts.base)
+	}
+	log.Printf("[fix] 📥 Cloning %s into %s\n", remote, ws)
+	if _, err := gitWithEnv(filepath.Dir(ws), gitAuthEnv(repo, remote), append(cloneArgs, remote, ws)...); err != nil {
+		os.RemoveAll(ws)
+		return exitFailed, err
+	}
+	base, err := gitCmd(ws, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return exitFailed, err
+	}
+	branch := "zug/fix-" + time.Now().Format("20060102-150405")
+	if _, err := gitCmd(ws, "checkout", "-q", "-b", branch); err != nil {
+		return exitFailed, err
+	}
+
+	cf.dir = ws
+	agent := cf.newAgent("")
+	agent.review = opts.review
+	if tcs := agent.detectToolchains(ws); len(tcs) > 0 {
+		log.Printf("[fix] 🧰 Detected %s; test command: %q\n", toolchainNames(tcs), tcs[0].TestCmd)
+	} else {
+		log.Println("[fix] 🧰 No toolchain detected; the changes will not be built or tested.")
+	}
+	if opts.timeout > 0 {
+		cancel := agent.setTimeout(opts.timeout)
+		defer cancel()
+	}
+	err = agent.feedbackLoop(task)
+	code := agent.exitCode(err)
+	if err != nil {
+		return code, fmt.Errorf("task did not complete: %w; nothing was pushed, the workspace is kept at %s", err, ws)
+	}
+	if len(agent.netChanges()) == 0 {
+		log.Println("[fix] The task needed no changes; nothing to push.")
+		os.RemoveAll(ws)
+		return code, nil
+	}
+
+	if _, err := gitCmd(ws, "add", "-A"); err != nil {
+		return exitFailed, err
+	}
+	title, body := "zug: "+firstLine(task), "Task:\n\n"+task+"\n"
+	if d, err := agent.describeChanges(task); err != nil {
+		log.Printf("[fix] ⚠️ Could not describe the changes: %v\n", err)
+	} else {
+		title, body = d.Title, d.markdown(agent.netChanges())
+	}
+	msg := agent.autoCommitMessage(ws, task, title)
+	if _, err := gitCmd(ws, "-c", "user.name=zug", "-c", "user.email=zug@localhost", "commit", "-q", "-m", msg); err != nil {
+		return exitFailed, err
+	}
+	log.Printf("[fix] 📤 Pushing %s\n", branch)
+	if _, err := gitWithEnv(ws, gitAuthEnv(repo, remote), "push", "--quiet", "origin", branch); err != nil {
+		return exitFailed, fmt.Errorf("%w; the commit is kept in %s", err, ws)
+	}
+	if repo == nil {
+		log.Printf("[fix] ✅ Pushed %s; open the pull request against %s by hand.\n", branch, base)
+	} else {
+		repo.ref = base
+		url, err := repo.pullRequest(branch, title, body)
+		if err != nil {
+			return exitFailed, fmt.Errorf("pushed %s, but %w; the workspace is kept at %s", branch, err, ws)
+		}
+		log.Printf("[fix] ✅ Opened %s\n", url)
+	}
+	if opts.keep {
+		log.Printf("[fix] Workspace kept at %s\n", ws)
+	} else {
+		os.RemoveAll(ws)
+	}
+	return code, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestGitAuthEnv(t *testing.T) {
+	gh := &apiRepo{kind: repoGitHub, token: "gh-token"}
+	env := gitAuthEnv(gh, "https://github.com/acme/api.git")
+	if len(env) != 3 || env[1] != "GIT_CONFIG_KEY_0=http.extraHeader" {
+		t.Fatalf("env = %q", env)
+	}
+	auth, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(env[2], "GIT_CONFIG_VALUE_0=Authorization: Basic "))
+	if string(auth) != "x-access-token:gh-token" {
+		t.Errorf("GitHub credentials = %q", auth)
+	}
+	gl := &apiRepo{kind: repoGitLab, token: "gl-token"}
+	auth, _ = base64.StdEncoding.DecodeString(strings.TrimPrefix(gitAuthEnv(gl, "https://gitlab.com/g/p.git")[2], "GIT_CONFIG_VALUE_0=Authorization: Basic "))
+	if string(auth) != "oauth2:gl-token" {
+		t.Errorf("GitLab credentials = %q", auth)
+	}
+	// ssh remotes use the user's keys, and the token must not leak to other hosts' remotes.
+	if env := gitAuthEnv(gh, "git@github.com:acme/api.git"); env != nil {
+		t.Errorf("ssh remote got %q", env)
+	}
+	if env := gitAuthEnv(nil, "https://example.com/x.git"); env != nil {
+		t.Errorf("no repository got %q", env)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"go/format"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  Auto-formatting of written files
+  ─────────────────────────────*/
+
+const formatTimeout = 30 * time.Second
+
+// formatConfig is the `format:` section of zug.yaml.
+type formatConfig struct {
+	Disabled bool              `yaml:"disabled"`
+	Commands map[string]string `yaml:"commands"` // extension -> command reading stdin, writing stdout
+}
+
+var prettierExts = map[string]bool{
+	".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".css": true, ".scss": true,
+	".json": true, ".md": true, ".yaml": true, ".yml": true, ".html": true, ".vue": true,
+}
+
+// formatterFor returns the shell command that formats a file with this path via stdin/stdout,
+// or "" if none is configured or installed. Go files fall back to go/format in-process.
+func (a *AutonomousCodingAgent) formatterFor(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if cmd, ok := a.cfg.Format.Commands[ext]; ok {
+		return cmd
+	}
+	installed := func(bin string) bool {
+		_, err := exec.LookPath(bin)
+		return err == nil
+	}
+	switch {
+	case ext == ".go" && installed("goimports"):
+		return "goimports"
+	case ext == ".py" && installed("black"):
+		return "black -q -"
+	case ext == ".py" && installed("ruff"):
+		return "ruff format -"
+	case ext == ".rs" && installed("rustfmt"):
+		return "rustfmt --emit stdout --edition 2021"
+	case prettierExts[ext]:
+		if local := filepath.Join(a.projectDir, "node_modules", ".bin", "prettier"); fileExists(local) {
+			return local + " --stdin-filepath " + shellQuote(path)
+		}
+		if installed("prettier") {
+			return "prettier --stdin-filepath " + shellQuote(path)
+		}
+	}
+	return ""
+}
+
+// formatSource formats content according to the file's extension and names the formatter
+// that changed it. It returns the original content and "" when nothing was reformatted.
+func (a *AutonomousCodingAgent) formatSource(path, content string) (string, string) {
+	if a.cfg.Format.Disabled || content == "" {
+		return content, ""
+	}
+	cmd := a.formatterFor(path)
+	if cmd == "" {
+		if strings.EqualFold(filepath.Ext(path), ".go") {
+			out, err := format.Source([]byte(content))
+			if err != nil {
+				log.Printf("[agent] gofmt skipped for %s: %v\n", path, err)
+				return content, ""
+			}
+			if string(out) == content {
+				return content, ""
+			}
+			return string(out), "gofmt"
+		}
+		return content, ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), formatTimeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, "bash", "-c", cmd)
+	c.Dir = a.projectDir
+	c.Stdin = strings.NewReader(content)
+	var stdout, stderr bytes.Buffer
+	c.Stdout, c.Stderr = &stdout, &stderr
+	if err := c.Run(); err != nil || stdout.Len() == 0 {
+		log.Printf("[agent] formatter %q skipped for %s: %v %s\n", cmd, path, err, strings.TrimSpace(stderr.String()))
+		return content, ""
+	}
+	if stdout.String() == content {
+		return content, ""
+	}
+	return stdout.String(), filepath.Base(strings.Fields(cmd)[0])
+}
+
+// formattedNote tells the model its content was reformatted, so it re-reads before exact edits.
+func formattedNote(formatter string) string {
+	if formatter == "" {
+		return ""
+	}
+	return " (formatted with " + formatter + ")"
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// shellQuote wraps s in single quotes for bash.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newMemAgent returns an agent whose project lives in memory at /project.
+func newMemAgent(t *testing.T, files map[string]string) *AutonomousCodingAgent {
+	t.Helper()
+	a := &AutonomousCodingAgent{
+		projectDir: "/project",
+		fs:         newMemFS("/project"),
+		cfg:        &config{},
+		changes:    map[string]*fileChange{},
+	}
+	for path, content := range files {
+		full, err := a.absPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.fs.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.fs.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a
+}
+
+func TestFileToolsOnMemFS(t *testing.T) {
+	a := newMemAgent(t, map[string]string{"docs/a.txt": "alpha\nbeta\n", "b.txt": "gamma\n"})
+
+	if _, err := a.createFile("new/c.txt", "delta\n", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.appendFile("new/c.txt", "epsilon\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.updateFile("docs/a.txt", "beta", "BETA", nil, false); err != nil {
+		t.Fatal(err)
+	}
+	got, err := a.readFile("new/c.txt")
+	if err != nil || got != "delta\nepsilon\n" {
+		t.Errorf("new/c.txt = %q, %v", got, err)
+	}
+
+	list, err := a.listFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "b.txt\ndocs/a.txt\nnew/c.txt"; list != want {
+		t.Errorf("listFiles = %q, want %q", list, want)
+	}
+	matches, err := a.searchFiles("BETA|epsilon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "docs/a.txt:2: BETA\nnew/c.txt:2: epsilon"; matches != want {
+		t.Errorf("searchFiles = %q, want %q", matches, want)
+	}
+
+	if _, err := a.removeDir("docs", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.fs.Stat("/project/docs/a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("docs/a.txt still exists after removeDir: %v", err)
+	}
+	diff := a.changesDiff()
+	for _, want := range []string{"--- a/docs/a.txt\n+++ /dev/null", "--- /dev/null\n+++ b/new/c.txt"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff does not contain %q:\n%s", want, diff)
+		}
+	}
+}
+
+func TestMemFSBehavesLikeDisk(t *testing.T) {
+	m := newMemFS("/p")
+	if err := m.WriteFile("/p/missing/x", nil, 0o644); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("writing into a missing directory: err = %v, want ErrNotExist", err)
+	}
+	if err := m.WriteFile("/p/f", []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.MkdirAll("/p/f/sub", 0o755); err == nil {
+		t.Error("MkdirAll below a file succeeded")
+	}
+	if _, err := m.ReadDir("/p/f"); err == nil {
+		t.Error("ReadDir of a file succeeded")
+	}
+	if err := m.Chmod("/p/f", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := m.Stat("/p/f"); info.Mode() != 0o755 || info.Size() != 1 {
+		t.Errorf("Stat = %v, %d bytes", info.Mode(), info.Size())
+	}
+}
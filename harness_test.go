@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"zug/provider/mock"
+)
+
+/*──────────────────────────────
+  Test harness
+  ─────────────────────────────*/
+
+// newTestAgent returns an agent working in a fresh project directory against a mock API
+// that serves replies in order. files seeds the project (path → content).
+func newTestAgent(t *testing.T, files map[string]string, replies ...mock.Reply) (*AutonomousCodingAgent, *mock.Server) {
+	t.Helper()
+	return newTestAgentWithKeys(t, []string{"sk-test-key"}, files, replies...)
+}
+
+func newTestAgentWithKeys(t *testing.T, keys []string, files map[string]string, replies ...mock.Reply) (*AutonomousCodingAgent, *mock.Server) {
+	t.Helper()
+	srv := mock.NewServer(t, replies...)
+	t.Setenv(envBaseURL, srv.URL)
+	t.Setenv("ZUG_HOME", t.TempDir())
+	t.Setenv("ZUG_CACHE", "")
+
+	dir := t.TempDir()
+	for path, content := range files {
+		writeTestFile(t, dir, path, content)
+	}
+	ring, err := newKeyRing(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAgent(ring, dir, "mock-model"), srv
+}
+
+func writeTestFile(t *testing.T, dir, path, content string) {
+	t.Helper()
+	full := filepath.Join(dir, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, dir, path string) string {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join(dir, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+// toolResults returns the tool messages of the i-th request sent to srv.
+func toolResults(t *testing.T, srv *mock.Server, i int) []string {
+	t.Helper()
+	reqs := srv.Requests()
+	if i >= len(reqs) {
+		t.Fatalf("only %d request(s) were sent, want at least %d", len(reqs), i+1)
+	}
+	var out []string
+	for _, m := range reqs[i].Messages {
+		if m.Role == "tool" {
+			out = append(out, m.Content)
+		}
+	}
+	return out
+}
+
+func lastToolResult(t *testing.T, srv *mock.Server, i int) string {
+	t.Helper()
+	results := toolResults(t, srv, i)
+	if len(results) == 0 {
+		t.Fatalf("request %d carries no tool results", i)
+	}
+	return results[len(results)-1]
+}
+
+func assertContains(t *testing.T, got, want string) {
+	t.Helper()
+	if !strings.Contains(got, want) {
+		t.Errorf("%q does not contain %q", got, want)
+	}
+}
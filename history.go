@@ -0,0 +1,152 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  zug history
+  ─────────────────────────────*/
+
+func historyCommand(fs *flag.FlagSet) func() {
+	dir := fs.String("dir", "ai_coder_project", "project directory")
+	since := fs.String("since", "", "first day to show: YYYY-MM-DD, today, yesterday or a weekday name (the most recent one)")
+	until := fs.String("until", "", "last day to show, same formats as -since")
+	on := fs.String("on", "", "shorthand for -since X -until X")
+	path := fs.String("path", "", "only runs that changed a file whose path contains this text")
+	checkpoint := fs.Int64("checkpoint", 0, "print the content saved in this checkpoint and exit")
+	return func() {
+		if *on != "" {
+			*since, *until = *on, *on
+		}
+		dbDir := filepath.Join(*dir, zugDirName)
+		if !fileExists(filepath.Join(dbDir, stateFileName)) {
+			log.Fatalf("❌ No history recorded for %s yet.", *dir)
+		}
+		st, err := openState(dbDir)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		defer st.db.Close()
+
+		if *checkpoint != 0 {
+			var content []byte
+			err := st.db.QueryRow(`SELECT content FROM checkpoints WHERE id = ?`, *checkpoint).Scan(&content)
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Fatalf("❌ No checkpoint %d.", *checkpoint)
+			} else if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			os.Stdout.Write(content)
+			return
+		}
+
+		query := `SELECT id, task, model, started, status, outcome, error, prompt_tokens, completion_tokens, parent, fork_at FROM runs WHERE 1=1`
+		var params []interface{}
+		if *since != "" {
+			day, err := parseDay(*since, time.Now())
+			if err != nil {
+				log.Fatalf("❌ -since: %v", err)
+			}
+			query += ` AND started >= ?`
+			params = append(params, stateTime(day))
+		}
+		if *until != "" {
+			day, err := parseDay(*until, time.Now())
+			if err != nil {
+				log.Fatalf("❌ -until: %v", err)
+			}
+			query += ` AND started < ?`
+			params = append(params, stateTime(day.AddDate(0, 0, 1)))
+		}
+		if *path != "" {
+			query += ` AND id IN (SELECT run_id FROM changes WHERE instr(path, ?) > 0)`
+			params = append(params, *path)
+		}
+		rows, err := st.db.Query(query+` ORDER BY id`, params...)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		defer rows.Close()
+
+		n := 0
+		for rows.Next() {
+			var id, promptTokens, completionTokens int64
+			var task, model, started, status, outcome, errText string
+			var parent, forkAt sql.NullInt64
+			if err := rows.Scan(&id, &task, &model, &started, &status, &outcome, &errText, &promptTokens, &completionTokens, &parent, &forkAt); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			when := started
+			if t, err := time.Parse(stateTimeFormat, started); err == nil {
+				when = t.Local().Format("2006-01-02 15:04")
+			}
+			if outcome != "" {
+				status += " (" + outcome + ")"
+			}
+			fmt.Printf("#%d  %s  %s  %s  %d+%d tokens\n    %s\n", id, when, status, model, promptTokens, completionTokens, firstLine(task))
+			if parent.Valid {
+				fmt.Printf("    forked from #%d after message %d\n", parent.Int64, forkAt.Int64)
+			}
+			if errText != "" {
+				fmt.Printf("    error: %s\n", firstLine(errText))
+			}
+			if err := printRunChanges(st, id); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			n++
+		}
+		if err := rows.Err(); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if n == 0 {
+			fmt.Println("No matching runs.")
+		}
+	}
+}
+
+func printRunChanges(st *stateStore, runID int64) error {
+	rows, err := st.db.Query(`SELECT action, path FROM changes WHERE run_id = ? ORDER BY path`, runID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var action, path string
+		if err := rows.Scan(&action, &path); err != nil {
+			return err
+		}
+		fmt.Printf("    %-9s %s\n", action, path)
+	}
+	return rows.Err()
+}
+
+// parseDay resolves a day name relative to now into local midnight.
+func parseDay(s string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	for d := 1; d <= 7; d++ {
+		day := today.AddDate(0, 0, -d)
+		if name := strings.ToLower(day.Weekday().String()); s == name || s == name[:3] {
+			return day, nil
+		}
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date (YYYY-MM-DD), today, yesterday or a weekday name", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  Lifecycle hooks
+  ─────────────────────────────*/
+
+const hookTimeout = 60 * time.Second
+
+const (
+	hookPreTool    = "pre_tool"
+	hookPostTool   = "post_tool"
+	hookPreTurn    = "pre_turn"
+	hookOnComplete = "on_complete"
+)
+
+// hookConfig is one user script. Tools optionally restricts tool hooks to the named tools.
+type hookConfig struct {
+	Run   string   `yaml:"run"`
+	Tools []string `yaml:"tools"`
+}
+
+type hooksConfig struct {
+	PreTool    []hookConfig `yaml:"pre_tool"`
+	PostTool   []hookConfig `yaml:"post_tool"`
+	PreTurn    []hookConfig `yaml:"pre_turn"`
+	OnComplete []hookConfig `yaml:"on_complete"`
+}
+
+// hookEvent is the JSON payload written to a hook's stdin.
+type hookEvent struct {
+	Event       string          `json:"event"`
+	Tool        string          `json:"tool,omitempty"`
+	CallID      string          `json:"call_id,omitempty"`
+	Args        json.RawMessage `json:"args,omitempty"`
+	Result      string          `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Turn        int             `json:"turn,omitempty"`
+	Instruction string          `json:"instruction,omitempty"`
+	Task        string          `json:"task,omitempty"`
+	Success     *bool           `json:"success,omitempty"`
+	Tools       []toolUsage     `json:"tools,omitempty"` // on_complete: per-tool statistics of the run
+}
+
+func (h hooksConfig) forEvent(event string) []hookConfig {
+	switch event {
+	case hookPreTool:
+		return h.PreTool
+	case hookPostTool:
+		return h.PostTool
+	case hookPreTurn:
+		return h.PreTurn
+	case hookOnComplete:
+		return h.OnComplete
+	}
+	return nil
+}
+
+// runHooks runs every hook registered for ev.Event. It stops at the first hook exiting
+// non-zero and returns its output as an error, which vetoes the action for pre_tool.
+func (a *AutonomousCodingAgent) runHooks(ev hookEvent) error {
+	hooks := a.cfg.Hooks.forEvent(ev.Event)
+	if len(hooks) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("cannot encode %s hook payload: %w", ev.Event, err)
+	}
+	for _, h := range hooks {
+		if len(h.Tools) > 0 && !slices.Contains(h.Tools, ev.Tool) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+		c := exec.CommandContext(ctx, "bash", "-c", h.Run)
+		c.Dir = a.projectDir
+		c.Stdin = bytes.NewReader(payload)
+		c.Env = append(os.Environ(), "ZUG_EVENT="+ev.Event, "ZUG_TOOL="+ev.Tool, "ZUG_PROJECT_DIR="+a.projectDir)
+		out, err := c.CombinedOutput()
+		cancel()
+		if err != nil {
+			log.Printf("[agent] %s hook %q failed: %v\n", ev.Event, h.Run, err)
+			return fmt.Errorf("%s hook %q exited with %v: %s", ev.Event, h.Run, err, strings.TrimSpace(string(out)))
+		}
+		log.Printf("[agent] %s hook %q ok\n", ev.Event, h.Run)
+	}
+	return nil
+}
+
+// dispatchTool wraps execTool with the tool permissions and the pre_tool/post_tool hooks.
+// A failing pre_tool hook vetoes the call and its output is returned to the model instead.
+func (a *AutonomousCodingAgent) dispatchTool(callID, name, jsonArgs string) (string, error) {
+	a.call.id, a.call.tool = callID, name
+	defer func() { a.call.id, a.call.tool = "", "" }()
+	decision, err := a.checkPermission(callID, name, jsonArgs)
+	if err != nil {
+		decision.subject = describeCall(name, jsonArgs)
+		a.recordApproval(decision)
+		return "", err
+	}
+	var before callSnapshot
+	if decision != nil {
+		before = a.snapshotCall(jsonArgs)
+	}
+	ev := hookEvent{Event: hookPreTool, Tool: name, CallID: callID}
+	if json.Valid([]byte(jsonArgs)) {
+		ev.Args = json.RawMessage(jsonArgs)
+	}
+	if err := a.runHooks(ev); err != nil {
+		if decision != nil {
+			decision.subject = describeCall(name, jsonArgs)
+			decision.reason = strings.TrimPrefix(decision.reason+"; vetoed by a pre_tool hook", "; ")
+			a.recordApproval(decision)
+		}
+		return "", fmt.Errorf("%w: %w", errPolicyViolation, err)
+	}
+
+	result, toolErr := a.execTool(name, jsonArgs)
+	if decision != nil {
+		decision.subject = a.approvedSubject(name, jsonArgs, before)
+		a.recordApproval(decision)
+	}
+
+	ev.Event, ev.Result = hookPostTool, result
+	if toolErr != nil {
+		ev.Error = toolErr.Error()
+	}
+	if err := a.runHooks(ev); err != nil {
+		result += "\nWARNING: " + err.Error()
+	}
+	return result, toolErr
+}
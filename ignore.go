@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*──────────────────────────────
+  Ignore patterns (zug.yaml ignore + .zugignore)
+  ─────────────────────────────*/
+
+const ignoreFileName = ".zugignore"
+
+// defaultIgnores keep VCS metadata, zug's own state (except the scratch area), dependency
+// trees and caches away from the model.
+var defaultIgnores = []string{
+	".git/", zugDirName + "/*", "!" + scratchDir + "/", "node_modules/", "__pycache__/", "*.pyc", ".venv/", "venv/",
+	".mypy_cache/", ".pytest_cache/", ".ruff_cache/", ".tox/", ".DS_Store",
+}
+
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool // "!pattern" re-includes a path
+	dirOnly bool // "pattern/" only matches directories
+}
+
+// ignoreMatcher reports whether a project-relative path is hidden from the file tools.
+type ignoreMatcher []ignoreRule
+
+// ignoreMatcher compiles the defaults, the `ignore:` list of zug.yaml and .zugignore,
+// in that order; like .gitignore the last matching rule wins.
+func (a *AutonomousCodingAgent) ignoreMatcher() ignoreMatcher {
+	patterns := append(append([]string{}, defaultIgnores...), a.cfg.Ignore...)
+	if raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, ignoreFileName)); err == nil {
+		patterns = append(patterns, strings.Split(string(raw), "\n")...)
+	}
+	var m ignoreMatcher
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+		var r ignoreRule
+		if strings.HasPrefix(p, "!") {
+			r.negate, p = true, p[1:]
+		}
+		r.dirOnly = strings.HasSuffix(p, "/")
+		if r.re = globRegexp(p); r.re != nil {
+			m = append(m, r)
+		}
+	}
+	return m
+}
+
+// ignored reports whether rel (a file, or a directory when isDir) is ignored.
+func (m ignoreMatcher) ignored(rel string, isDir bool) bool {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	ignored := false
+	for _, r := range m {
+		if r.matches(rel, isDir) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if (isDir || !r.dirOnly) && r.re.MatchString(rel) {
+		return true
+	}
+	for p := filepath.ToSlash(filepath.Dir(rel)); p != "." && p != "/"; p = filepath.ToSlash(filepath.Dir(p)) {
+		if r.re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Image attachments
+  ─────────────────────────────*/
+
+const maxImageBytes = 20 << 20 // provider limit for inline images
+
+// stringList is a repeatable string flag (e.g. --image a.png --image b.png).
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// loadImagePart turns a local image file (or an http/https URL) into a multimodal message part.
+func loadImagePart(src string) (openai.ChatMessagePart, error) {
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		return openai.ChatMessagePart{
+			Type:     openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{URL: src, Detail: openai.ImageURLDetailAuto},
+		}, nil
+	}
+	raw, err := os.ReadFile(src)
+	if err != nil {
+		return openai.ChatMessagePart{}, fmt.Errorf("cannot read image %s: %w", src, err)
+	}
+	if len(raw) > maxImageBytes {
+		return openai.ChatMessagePart{}, fmt.Errorf("image %s is %d bytes, larger than the %d byte limit", src, len(raw), maxImageBytes)
+	}
+	mime := http.DetectContentType(raw)
+	switch mime {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+	default:
+		return openai.ChatMessagePart{}, fmt.Errorf("image %s has unsupported type %s (use PNG, JPEG, GIF or WebP)", src, mime)
+	}
+	return openai.ChatMessagePart{
+		Type: openai.ChatMessagePartTypeImageURL,
+		ImageURL: &openai.ChatMessageImageURL{
+			URL:    "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(raw),
+			Detail: openai.ImageURLDetailAuto,
+		},
+	}, nil
+}
+
+// loadImageParts loads every attachment, failing on the first unreadable one.
+func loadImageParts(srcs []string) ([]openai.ChatMessagePart, error) {
+	var parts []openai.ChatMessagePart
+	for _, src := range srcs {
+		part, err := loadImagePart(src)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// userMessage builds a user message, switching to multimodal content when images are attached.
+func userMessage(prompt string, images []openai.ChatMessagePart) openai.ChatCompletionMessage {
+	if len(images) == 0 {
+		return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: prompt}
+	}
+	parts := append([]openai.ChatMessagePart{{Type: openai.ChatMessagePartTypeText, Text: prompt}}, images...)
+	return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, MultiContent: parts}
+}
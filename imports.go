@@ -0,0 +1,324 @@
+package main
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*──────────────────────────────
+  import_graph (tool)
+  ─────────────────────────────*/
+
+const importGraphLimit = 40 // files listed per section
+
+var (
+	pyImportPattern   = regexp.MustCompile(`(?m)^[ \t]*import[ \t]+([\w.]+(?:[ \t]+as[ \t]+\w+)?(?:[ \t]*,[ \t]*[\w.]+(?:[ \t]+as[ \t]+\w+)?)*)`)
+	pyFromPattern     = regexp.MustCompile(`(?m)^[ \t]*from[ \t]+(\.*[\w.]*)[ \t]+import[ \t]+\(?[ \t]*([\w, \t]*)`)
+	jsImportPattern   = regexp.MustCompile(`(?:\bimport|\bexport)\s[^'"]*?\bfrom\s*['"]([^'"]+)['"]|\bimport\s*\(?\s*['"]([^'"]+)['"]|\brequire\(\s*['"]([^'"]+)['"]\s*\)`)
+	cIncludePattern   = regexp.MustCompile(`(?m)^[ \t]*#[ \t]*include[ \t]*"([^"]+)"`)
+	goModulePattern   = regexp.MustCompile(`(?m)^module[ \t]+"?([^\s"]+)"?`)
+	jsResolveSuffixes = []string{"", ".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs", ".mts", ".cts", ".vue", ".svelte",
+		"/index.ts", "/index.tsx", "/index.js", "/index.jsx", "/index.mjs"}
+)
+
+// importEdge is one import of a project file: to a project file, to a Go package
+// directory, or, when external, to a package outside the project.
+type importEdge struct {
+	target   string
+	external bool
+}
+
+// importGraph holds the imports of every source file in the project, by path.
+type importGraph struct {
+	files  map[string]bool
+	edges  map[string][]importEdge
+	goDirs map[string]bool // directories holding Go files, which are imported as a whole
+}
+
+// fileImports lists the import specs of a source file as written in it.
+func fileImports(rel, src string) []string {
+	var specs []string
+	switch ext := strings.ToLower(filepath.Ext(rel)); ext {
+	case ".go":
+		f, err := parser.ParseFile(token.NewFileSet(), rel, src, parser.ImportsOnly)
+		if err != nil {
+			return nil
+		}
+		for _, imp := range f.Imports {
+			if p, err := strconv.Unquote(imp.Path.Value); err == nil {
+				specs = append(specs, p)
+			}
+		}
+	case ".py":
+		for _, m := range pyImportPattern.FindAllStringSubmatch(src, -1) {
+			for _, part := range strings.Split(m[1], ",") {
+				specs = append(specs, strings.Fields(part)[0])
+			}
+		}
+		for _, m := range pyFromPattern.FindAllStringSubmatch(src, -1) {
+			specs = append(specs, m[1])
+			// "from . import a, b" and "from pkg import mod" may name modules too.
+			for _, name := range strings.Split(m[2], ",") {
+				if f := strings.Fields(name); len(f) > 0 && f[0] != "*" {
+					specs = append(specs, strings.TrimSuffix(m[1], ".")+"."+f[0])
+				}
+			}
+		}
+	case ".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx", ".mts", ".cts", ".vue", ".svelte":
+		for _, m := range jsImportPattern.FindAllStringSubmatch(src, -1) {
+			specs = append(specs, m[1]+m[2]+m[3])
+		}
+	case ".c", ".h", ".cc", ".cpp", ".cxx", ".hpp", ".hh":
+		for _, m := range cIncludePattern.FindAllStringSubmatch(src, -1) {
+			specs = append(specs, m[1])
+		}
+	}
+	return specs
+}
+
+// buildImportGraph reads the imports of the project's source files and resolves those
+// that point into the project.
+func (a *AutonomousCodingAgent) buildImportGraph() (*importGraph, error) {
+	list, err := a.projectFiles()
+	if err != nil {
+		return nil, err
+	}
+	g := &importGraph{files: map[string]bool{}, edges: map[string][]importEdge{}, goDirs: map[string]bool{}}
+	modules := map[string]string{} // Go module path -> its directory
+	for _, rel := range list {
+		rel = filepath.ToSlash(rel)
+		g.files[rel] = true
+		if strings.HasSuffix(rel, ".go") {
+			g.goDirs[path.Dir(rel)] = true
+		}
+		if path.Base(rel) == "go.mod" {
+			if raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, filepath.FromSlash(rel))); err == nil {
+				if m := goModulePattern.FindSubmatch(raw); m != nil {
+					modules[string(m[1])] = path.Dir(rel)
+				}
+			}
+		}
+	}
+	for _, rel := range list {
+		rel = filepath.ToSlash(rel)
+		if _, denied := a.sensitivePath(rel); denied {
+			continue
+		}
+		full := filepath.Join(a.projectDir, filepath.FromSlash(rel))
+		if info, err := a.fs.Stat(full); err != nil || info.Size() > symbolFileLimit {
+			continue
+		}
+		specs := []string{}
+		if raw, err := a.fs.ReadFile(full); err == nil {
+			specs = fileImports(rel, string(raw))
+		}
+		seen := map[importEdge]bool{}
+		for _, spec := range specs {
+			e := resolveImport(rel, spec, g.files, g.goDirs, modules)
+			if e.target != "" && e.target != rel && !seen[e] {
+				seen[e] = true
+				g.edges[rel] = append(g.edges[rel], e)
+			}
+		}
+	}
+	return g, nil
+}
+
+// resolveImport maps an import spec of file rel to a project file or Go package
+// directory, or marks it external. Python specs naming a symbol rather than a module
+// resolve to nothing and are dropped.
+func resolveImport(rel, spec string, files, goDirs map[string]bool, modules map[string]string) importEdge {
+	dir := path.Dir(rel)
+	switch ext := strings.ToLower(path.Ext(rel)); {
+	case ext == ".go":
+		for mod, modDir := range modules {
+			if spec == mod || strings.HasPrefix(spec, mod+"/") {
+				if target := path.Join(modDir, strings.TrimPrefix(spec, mod)); goDirs[target] {
+					return importEdge{target: target}
+				}
+			}
+		}
+		return importEdge{target: spec, external: true}
+	case ext == ".py":
+		base := ""
+		name := spec
+		if strings.HasPrefix(spec, ".") {
+			base = dir
+			name = strings.TrimLeft(spec, ".")
+			for i := 1; i < len(spec)-len(name); i++ {
+				base = path.Dir(base)
+			}
+		}
+		roots := []string{base}
+		if base == "" {
+			roots = []string{".", "src", dir}
+		}
+		module := func(name string) string {
+			mod := strings.ReplaceAll(strings.Trim(name, "."), ".", "/")
+			for _, root := range roots {
+				for _, cand := range []string{mod + ".py", mod + "/__init__.py"} {
+					if p := path.Join(root, cand); mod != "" && files[p] {
+						return p
+					}
+				}
+			}
+			return ""
+		}
+		if p := module(name); p != "" {
+			return importEdge{target: p}
+		}
+		top := strings.SplitN(name, ".", 2)[0]
+		if base != "" || module(top) != "" {
+			return importEdge{} // a name imported from a project module, not a module itself
+		}
+		return importEdge{target: top, external: true}
+	case strings.HasPrefix(spec, "."):
+		for _, suffix := range jsResolveSuffixes {
+			if p := path.Join(dir, spec) + suffix; files[p] {
+				return importEdge{target: p}
+			}
+		}
+		for _, p := range []string{path.Join(dir, spec), path.Join(".", spec)} {
+			if files[p] {
+				return importEdge{target: p} // C includes
+			}
+		}
+		return importEdge{}
+	default:
+		for _, p := range []string{path.Join(dir, spec), path.Clean(spec)} {
+			if files[p] {
+				return importEdge{target: p} // C includes, relative to the file or the root
+			}
+		}
+		return importEdge{target: spec, external: true}
+	}
+}
+
+// importGraphOf describes what the file or directory target imports and what imports it,
+// directly and transitively, so the model can see what an edit may affect.
+func (a *AutonomousCodingAgent) importGraphOf(target string) (string, error) {
+	full, err := a.absPath(target)
+	if err != nil {
+		return "", err
+	}
+	info, err := a.fs.Stat(full)
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %w", target, err)
+	}
+	target = filepath.ToSlash(filepath.Clean(target))
+	g, err := a.buildImportGraph()
+	if err != nil {
+		return "", err
+	}
+
+	// The nodes the query stands for: its files, and the Go packages they belong to.
+	members := map[string]bool{}
+	if info.IsDir() {
+		for f := range g.files {
+			if path.Dir(f) == target {
+				members[f] = true
+			}
+		}
+		if g.goDirs[target] {
+			members[target] = true
+		}
+	} else {
+		members[target] = true
+		if strings.HasSuffix(target, ".go") {
+			members[path.Dir(target)] = true
+		}
+	}
+
+	internal, external := map[string]bool{}, map[string]bool{}
+	for f := range members {
+		for _, e := range g.edges[f] {
+			if e.external {
+				external[e.target] = true
+			} else if !members[e.target] {
+				internal[e.target] = true
+			}
+		}
+	}
+
+	direct := g.dependents(members)
+	all := map[string]bool{}
+	frontier := direct
+	for len(frontier) > 0 {
+		next := map[string]bool{}
+		for f := range frontier {
+			if all[f] || members[f] {
+				continue
+			}
+			all[f] = true
+			nodes := map[string]bool{f: true}
+			if strings.HasSuffix(f, ".go") {
+				nodes[path.Dir(f)] = true
+			}
+			for d := range g.dependents(nodes) {
+				next[d] = true
+			}
+		}
+		frontier = next
+	}
+	indirect := map[string]bool{}
+	for f := range all {
+		if !direct[f] {
+			indirect[f] = true
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Import graph of %s", target)
+	if goPackage := strings.HasSuffix(target, ".go") || info.IsDir() && g.goDirs[target]; goPackage {
+		sb.WriteString(" (Go imports whole packages, so its package's importers count as its dependents)")
+	}
+	sb.WriteString(":")
+	writeImportSection(&sb, "Imports from the project", internal)
+	writeImportSection(&sb, "Imports from outside the project", external)
+	writeImportSection(&sb, "Imported directly by", direct)
+	writeImportSection(&sb, "Imported indirectly by", indirect)
+	return sb.String(), nil
+}
+
+// dependents lists the files with an import of any of nodes, other than nodes themselves.
+func (g *importGraph) dependents(nodes map[string]bool) map[string]bool {
+	out := map[string]bool{}
+	for f, edges := range g.edges {
+		if nodes[f] || nodes[path.Dir(f)] && strings.HasSuffix(f, ".go") {
+			continue // the same file, or a file of the same Go package
+		}
+		for _, e := range edges {
+			if !e.external && nodes[e.target] {
+				out[f] = true
+				break
+			}
+		}
+	}
+	return out
+}
+
+func writeImportSection(sb *strings.Builder, title string, set map[string]bool) {
+	list := make([]string, 0, len(set))
+	for p := range set {
+		list = append(list, p)
+	}
+	sort.Strings(list)
+	fmt.Fprintf(sb, "\n%s (%d):", title, len(list))
+	if len(list) == 0 {
+		sb.WriteString(" none")
+	}
+	for i, p := range list {
+		if i == importGraphLimit {
+			fmt.Fprintf(sb, "\n  ... %d more", len(list)-i)
+			break
+		}
+		sb.WriteString("\n  " + p)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImportGraphGo(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		"go.mod":            "module example.com/app\n\ngo 1.22\n",
+		"main.go":           "package main\n\nimport \"example.com/app/handlers\"\n\nfunc main() { handlers.Serve() }\n",
+		"handlers/user.go":  "package handlers\n\nimport (\n\t\"net/http\"\n\n\t\"example.com/app/models\"\n)\n\nvar _ = http.StatusOK\nvar _ models.User\n",
+		"handlers/serve.go": "package handlers\n\nfunc Serve() {}\n",
+		"models/user.go":    "package models\n\ntype User struct{}\n",
+		"cmd/tool/main.go":  "package main\n\nimport _ \"example.com/app/models\"\n",
+	})
+	got, err := a.dispatchTool("call", "import_graph", `{"path": "handlers/user.go"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Imports from the project (1):\n  models\nImports from outside the project (1):\n  net/http\nImported directly by (1):\n  main.go\nImported indirectly by (0): none"
+	assertContains(t, got, want)
+
+	got, err = a.importGraphOf("models")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, got, "Imported directly by (2):\n  cmd/tool/main.go\n  handlers/user.go\nImported indirectly by (1):\n  main.go")
+}
+
+func TestImportGraphScripts(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		"web/app.ts":      "import { api } from './lib/api';\nimport React from 'react';\nconst u = require(\"./util\");\n",
+		"web/lib/api.ts":  "export const api = 1;\n",
+		"web/util.js":     "module.exports = {};\n",
+		"pkg/__init__.py": "",
+		"pkg/core.py":     "import os, json as j\nfrom . import helpers\nfrom .helpers import tidy\n",
+		"pkg/helpers.py":  "def tidy(): pass\n",
+		"scripts/run.py":  "from pkg.core import main\nimport pkg.helpers\n",
+	})
+	got, err := a.importGraphOf("web/app.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, got, "Imports from the project (2):\n  web/lib/api.ts\n  web/util.js\nImports from outside the project (1):\n  react")
+
+	got, err = a.importGraphOf("pkg/helpers.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, got, "Imported directly by (2):\n  pkg/core.py\n  scripts/run.py")
+
+	got, err = a.importGraphOf("pkg/core.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, got, "Imports from the project (1):\n  pkg/helpers.py\nImports from outside the project (2):\n  json\n  os")
+	if strings.Contains(got, "tidy") {
+		t.Errorf("an imported function was taken for a module:\n%s", got)
+	}
+}
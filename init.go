@@ -0,0 +1,251 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+/*──────────────────────────────
+  Project guide (ZUG.md)
+  ─────────────────────────────*/
+
+const (
+	projectGuideFile  = "ZUG.md" // in the project root, written by the maintainers
+	projectGuideLimit = 8000     // characters of the guide shown in the system prompt
+)
+
+// describeGuide is the system prompt section with the project's ZUG.md, cut to
+// projectGuideLimit.
+func (a *AutonomousCodingAgent) describeGuide() string {
+	raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, projectGuideFile))
+	guide := strings.TrimSpace(string(raw))
+	if err != nil || guide == "" {
+		return ""
+	}
+	if len(guide) > projectGuideLimit {
+		guide = guide[:projectGuideLimit] + "\n[... cut; read " + projectGuideFile + " for the rest]"
+	}
+	return fmt.Sprintf("Project guide from the maintainers (%s); follow it:\n%s", projectGuideFile, guide)
+}
+
+/*──────────────────────────────
+  zug init
+  ─────────────────────────────*/
+
+// generatedDirs are build output and vendored code worth hiding from the model when a
+// project has them; dependency trees and caches are hidden already (defaultIgnores).
+var generatedDirs = []string{"dist", "build", "out", "target", "coverage", "vendor", ".next", ".nuxt", "third_party"}
+
+// initGuess is what zug init found out about a project.
+type initGuess struct {
+	toolchains []toolchain   // of the project dir
+	roots      []rootConfig  // sub-projects, when the project dir has no manifest of its own
+	rootTCs    [][]toolchain // toolchains of each root
+	ignore     []string
+	dirs       []string // top-level directories
+	ci         string   // CI configuration found, if any
+	overview   string   // first paragraph of the README
+}
+
+// inspectProject detects the toolchains of dir, or of the sub-projects one or two levels
+// below it, and the generated directories, CI configuration and README.
+func (a *AutonomousCodingAgent) inspectProject() initGuess {
+	dir := a.projectDir
+	g := initGuess{toolchains: a.detectToolchains(dir)}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") || e.Name() == "node_modules" {
+			continue
+		}
+		if slices.Contains(generatedDirs, e.Name()) {
+			g.ignore = append(g.ignore, e.Name()+"/")
+			continue
+		}
+		g.dirs = append(g.dirs, e.Name())
+	}
+	if len(g.toolchains) == 0 {
+		var candidates []string
+		for _, d := range g.dirs {
+			candidates = append(candidates, d)
+			sub, _ := os.ReadDir(filepath.Join(dir, d))
+			for _, e := range sub {
+				if e.IsDir() && !strings.HasPrefix(e.Name(), ".") && !slices.Contains(generatedDirs, e.Name()) && e.Name() != "node_modules" {
+					candidates = append(candidates, d+"/"+e.Name())
+				}
+			}
+		}
+		for _, c := range candidates {
+			// A sub-project inside another one is part of it.
+			if slices.ContainsFunc(g.roots, func(r rootConfig) bool { return strings.HasPrefix(c, r.Path+"/") }) {
+				continue
+			}
+			if tcs := a.detectToolchains(filepath.Join(dir, c)); len(tcs) > 0 {
+				g.roots = append(g.roots, rootConfig{Path: c, Build: tcs[0].BuildCmd, Test: tcs[0].TestCmd, Lint: tcs[0].LintCmd})
+				g.rootTCs = append(g.rootTCs, tcs)
+			}
+		}
+	}
+	for _, ci := range []string{".github/workflows", ".gitlab-ci.yml", ".circleci", "Jenkinsfile", "azure-pipelines.yml", ".buildkite"} {
+		if fileExists(filepath.Join(dir, ci)) {
+			g.ci = ci
+			break
+		}
+	}
+	for _, name := range []string{"README.md", "README.rst", "README.txt", "README"} {
+		if raw, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			g.overview = readmeOverview(string(raw))
+			break
+		}
+	}
+	return g
+}
+
+// readmeOverview is the first paragraph of prose in a README, skipping headings, badges
+// and HTML.
+func readmeOverview(readme string) string {
+	for _, para := range strings.Split(strings.ReplaceAll(readme, "\r\n", "\n"), "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" || strings.ContainsAny(para[:1], "#<![=`-") {
+			continue
+		}
+		if len(para) > 600 {
+			para = para[:600] + "..."
+		}
+		return para
+	}
+	return ""
+}
+
+// configYAML renders the starter zug.yaml. Commands are written out even when they match
+// the autodetected ones, so they are easy to find and change.
+func (g initGuess) configYAML() string {
+	var sb strings.Builder
+	sb.WriteString("# zug configuration, generated by zug init from what the project looks like.\n# The commands are guesses: check them, and see the README of zug for every setting.\n\n")
+	if len(g.toolchains) > 0 {
+		tc := g.toolchains[0]
+		fmt.Fprintf(&sb, "# %s (%s, %s)\n", tc.Language, tc.BuildTool, tc.Manifest)
+		for _, c := range []struct{ key, cmd string }{{"build", tc.BuildCmd}, {"test", tc.TestCmd}, {"lint", tc.LintCmd}} {
+			if c.cmd == "" {
+				fmt.Fprintf(&sb, "# %s: no command detected; add one here.\n", c.key)
+			} else {
+				fmt.Fprintf(&sb, "%s:\n  command: %q\n", c.key, c.cmd)
+			}
+		}
+		for _, other := range g.toolchains[1:] {
+			fmt.Fprintf(&sb, "# Also detected: %s (%s); combine its commands above if both must pass.\n", other.Language, other.Manifest)
+		}
+	}
+	if len(g.roots) > 0 {
+		sb.WriteString("# The project dir has no manifest of its own; each sub-project is a root the\n# checks run in.\nroots:\n")
+		for i, r := range g.roots {
+			fmt.Fprintf(&sb, "  - path: %s # %s\n", r.Path, g.rootTCs[i][0].Language)
+			for _, c := range []struct{ key, cmd string }{{"build", r.Build}, {"test", r.Test}, {"lint", r.Lint}} {
+				if c.cmd != "" {
+					fmt.Fprintf(&sb, "    %s: %q\n", c.key, c.cmd)
+				}
+			}
+		}
+	}
+	if len(g.toolchains) == 0 && len(g.roots) == 0 {
+		sb.WriteString("# No build manifest found; set the commands the agent should check its work with.\n# build:\n#   command: \"make\"\n# test:\n#   command: \"make test\"\n")
+	}
+	if len(g.ignore) > 0 {
+		sb.WriteString("\n# Generated or vendored code, hidden from the file tools.\nignore:\n")
+		for _, p := range g.ignore {
+			fmt.Fprintf(&sb, "  - %s\n", p)
+		}
+	}
+	return sb.String()
+}
+
+// guideMarkdown renders the starter ZUG.md.
+func (g initGuess) guideMarkdown(name string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", name)
+	sb.WriteString("<!-- zug reads this file into every run. It was generated by zug init: replace the\n     guesses and TODOs with what a new contributor needs to know. -->\n\n")
+	sb.WriteString("## Overview\n\n")
+	if g.overview != "" {
+		sb.WriteString(g.overview + "\n\n")
+	} else {
+		sb.WriteString("TODO: what the project does and who uses it.\n\n")
+	}
+	if len(g.dirs) > 0 {
+		sb.WriteString("## Layout\n\n")
+		for _, d := range g.dirs {
+			fmt.Fprintf(&sb, "- `%s/`: TODO\n", d)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("## Build and test\n\n")
+	write := func(prefix string, tc toolchain) {
+		fmt.Fprintf(&sb, "- %s%s, %s (%s)\n", prefix, tc.Language, tc.BuildTool, tc.Manifest)
+		for _, c := range []struct{ label, cmd string }{{"Build", tc.BuildCmd}, {"Test", tc.TestCmd}, {"Lint", tc.LintCmd}} {
+			if c.cmd != "" {
+				fmt.Fprintf(&sb, "  - %s: `%s`\n", c.label, c.cmd)
+			}
+		}
+	}
+	for _, tc := range g.toolchains {
+		write("", tc)
+	}
+	for i, r := range g.roots {
+		write("`"+r.Path+"`: ", g.rootTCs[i][0])
+	}
+	if len(g.toolchains) == 0 && len(g.roots) == 0 {
+		sb.WriteString("- TODO: how to build the project and run its tests.\n")
+	}
+	if g.ci != "" {
+		fmt.Fprintf(&sb, "- CI runs from `%s`; keep these commands in line with it.\n", g.ci)
+	}
+	sb.WriteString("\n## Conventions\n\n- Match the style of the surrounding code.\n- TODO: naming, error handling, test layout, files and directories not to touch.\n")
+	return sb.String()
+}
+
+func initCommand(fs *flag.FlagSet) func() {
+	dir := fs.String("dir", ".", "project directory to inspect")
+	force := fs.Bool("force", false, "overwrite an existing "+configFileName+" and "+projectGuideFile)
+	return func() {
+		root, err := filepath.Abs(*dir)
+		if err != nil {
+			log.Fatalf("FATAL: Could not resolve project directory %s: %v", *dir, err)
+		}
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			log.Fatalf("❌ %s is not a directory", root)
+		}
+		a := &AutonomousCodingAgent{projectDir: root, fs: osFS{}}
+		g := a.inspectProject()
+		switch {
+		case len(g.toolchains) > 0:
+			log.Printf("[init] 🧰 Detected %s\n", toolchainNames(g.toolchains))
+		case len(g.roots) > 0:
+			log.Printf("[init] 🧰 Detected %d sub-project(s)\n", len(g.roots))
+		default:
+			log.Println("[init] 🧰 No build manifest found; fill in the commands by hand.")
+		}
+
+		written := 0
+		for _, f := range []struct{ name, content string }{
+			{configFileName, g.configYAML()},
+			{projectGuideFile, g.guideMarkdown(filepath.Base(root))},
+		} {
+			path := filepath.Join(root, f.name)
+			if fileExists(path) && !*force {
+				log.Printf("[init] %s exists; leaving it alone (use -force to overwrite).\n", f.name)
+				continue
+			}
+			if err := os.WriteFile(path, []byte(f.content), 0o644); err != nil {
+				log.Fatalf("❌ Could not write %s: %v", path, err)
+			}
+			log.Printf("[init] 📝 Wrote %s\n", path)
+			written++
+		}
+		if written > 0 {
+			fmt.Printf("Review %s and %s: the settings are guesses for you to refine.\n", configFileName, projectGuideFile)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestInitGuessesMonorepoConfig(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		"README.md":                 "# Shop\n\n[![ci](badge.svg)](ci)\n\nThe shop backend and storefront.\n",
+		"services/api/go.mod":       "module api\n",
+		"services/web/package.json": `{"scripts": {"test": "vitest run", "lint": "eslint ."}}`,
+		"dist/app.js":               "",
+		".github/workflows/ci.yml":  "on: push\n",
+	})
+	g := a.inspectProject()
+	if len(g.roots) != 2 || g.roots[0].Path != "services/api" || g.roots[1].Test != "npm run test" {
+		t.Fatalf("roots = %+v", g.roots)
+	}
+
+	if err := os.WriteFile(a.projectDir+"/"+configFileName, []byte(g.configYAML()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(a.projectDir)
+	if err != nil {
+		t.Fatalf("generated %s does not load: %v\n%s", configFileName, err, g.configYAML())
+	}
+	if len(cfg.Roots) != 2 || cfg.Roots[0].Test != "go test ./..." || cfg.Roots[1].Lint != "npm run lint" {
+		t.Errorf("roots = %+v", cfg.Roots)
+	}
+	if len(cfg.Ignore) != 1 || cfg.Ignore[0] != "dist/" {
+		t.Errorf("ignore = %v", cfg.Ignore)
+	}
+
+	guide := g.guideMarkdown("shop")
+	assertContains(t, guide, "The shop backend and storefront.")
+	assertContains(t, guide, "`services/api`: Go")
+	assertContains(t, guide, "CI runs from `.github/workflows`")
+	writeTestFile(t, a.projectDir, projectGuideFile, guide)
+	if !strings.Contains(a.systemMessage().Content, "The shop backend and storefront.") {
+		t.Error(projectGuideFile + " is not in the system prompt")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+/*──────────────────────────────
+  Interrupts (Ctrl-C) and child processes
+  ─────────────────────────────*/
+
+var errInterrupted = errors.New("interrupted by the user")
+
+// interrupted is set by the first Ctrl-C: agents stop after the tool call in flight.
+var interrupted atomic.Bool
+
+func interruptRequested() bool {
+	return interrupted.Load()
+}
+
+// trapInterrupts makes the first Ctrl-C (or SIGTERM) stop the run after the tool call in
+// flight, so files are never left half-written and the session can be resumed. A second
+// one kills the shell commands still running and exits at once.
+func trapInterrupts() {
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		interrupted.Store(true)
+		fmt.Println("\n🛑 Stopping after the current step; press Ctrl-C again to quit at once.")
+		<-sig
+		fmt.Println("\n🛑 Quitting.")
+		killChildProcesses()
+		os.Exit(exitInterrupted)
+	}()
+}
+
+// childProcs are the shell commands started by agents of this process, by process
+// group, so commands they left running in the background can be stopped too.
+var childProcs struct {
+	sync.Mutex
+	groups map[int]bool
+}
+
+// runChild runs c in a process group of its own, which a Ctrl-C on the terminal does not
+// reach: the command in flight completes, and is only killed on a second Ctrl-C or when
+// ctx ends (--timeout).
+func runChild(ctx context.Context, c *exec.Cmd) error {
+	setProcessGroup(c)
+	if err := c.Start(); err != nil {
+		return err
+	}
+	pid := c.Process.Pid
+	childProcs.Lock()
+	if childProcs.groups == nil {
+		childProcs.groups = map[int]bool{}
+	}
+	childProcs.groups[pid] = true
+	childProcs.Unlock()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(pid)
+		case <-done:
+		}
+	}()
+	err := c.Wait()
+	if ctx.Err() != nil {
+		return fmt.Errorf("command killed: %w", ctx.Err())
+	}
+	return err
+}
+
+// killChildProcesses stops every process group started by runChild that still has
+// members, such as servers a command started in the background.
+func killChildProcesses() {
+	childProcs.Lock()
+	defer childProcs.Unlock()
+	n := 0
+	for pid := range childProcs.groups {
+		if killProcessGroup(pid) {
+			n++
+		}
+		delete(childProcs.groups, pid)
+	}
+	if n > 0 {
+		log.Printf("[agent] 🧹 Stopped %d leftover child process group(s).\n", n)
+	}
+}
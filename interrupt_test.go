@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"zug/provider/mock"
+)
+
+func TestInterruptedRunStopsAndReports(t *testing.T) {
+	a, _ := newTestAgent(t, nil)
+	interrupted.Store(true)
+	t.Cleanup(func() { interrupted.Store(false) })
+
+	err := a.feedbackLoop("anything")
+	if !errors.Is(err, errInterrupted) {
+		t.Fatalf("err = %v, want errInterrupted", err)
+	}
+	if code := a.exitCode(err); code != exitInterrupted {
+		t.Errorf("exit code = %d, want %d", code, exitInterrupted)
+	}
+	assertContains(t, readTestFile(t, a.projectDir, progressFileName), "Resume the run")
+}
+
+func TestInterruptSkipsRemainingCalls(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs kill -INT")
+	}
+	a, srv := newTestAgent(t, nil,
+		// Ctrl-C while the first call runs.
+		mock.Calls(mock.Tool("run_shell", map[string]any{"command": "kill -INT $PPID; sleep 0.2"}), mock.Tool("list_files", map[string]any{})),
+	)
+	trapInterrupts()
+	t.Cleanup(func() {
+		signal.Reset(os.Interrupt, syscall.SIGTERM)
+		interrupted.Store(false)
+	})
+	_, err := a.chat("look around", phaseTools)
+	if !errors.Is(err, errInterrupted) {
+		t.Fatalf("err = %v, want errInterrupted", err)
+	}
+	if n := len(srv.Requests()); n != 1 {
+		t.Errorf("%d requests after the interrupt, want none", n-1)
+	}
+	if last := a.ctx[len(a.ctx)-1]; last.Content != stoppedCallNote(errInterrupted) {
+		t.Errorf("last message = %q, want the interrupted note", last.Content)
+	}
+}
+
+func TestKillChildProcessesStopsBackgroundCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are Unix only")
+	}
+	c := exec.Command("bash", "-c", "sleep 30 >/dev/null 2>&1 &")
+	if err := runChild(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	pgid := c.Process.Pid
+	if err := syscall.Kill(-pgid, 0); err != nil {
+		t.Fatalf("the background sleep is not running: %v", err)
+	}
+	killChildProcesses()
+	for deadline := time.Now().Add(2 * time.Second); syscall.Kill(-pgid, 0) == nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the background sleep survived")
+		}
+	}
+}
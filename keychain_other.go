@@ -0,0 +1,47 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+/*──────────────────────────────
+  OS keychain (macOS, secret-service)
+  ─────────────────────────────*/
+
+// keychainSecret reads a generic password from the macOS Keychain, or from the
+// secret-service (GNOME Keyring, KWallet) elsewhere, through their command-line tools.
+func keychainSecret(service, account string) (string, error) {
+	var cmd *exec.Cmd
+	ctx, cancel := context.WithTimeout(context.Background(), credentialTimeout)
+	defer cancel()
+	if runtime.GOOS == "darwin" {
+		cmd = exec.CommandContext(ctx, "security", "find-generic-password", "-s", service, "-a", account, "-w")
+	} else {
+		cmd = exec.CommandContext(ctx, "secret-tool", "lookup", "service", service, "account", account)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return "", errNoCredential
+	case errors.As(err, &exitErr):
+		// Both tools exit non-zero when the item does not exist.
+		if msg := strings.TrimSpace(stderr.String()); msg != "" && !strings.Contains(msg, "could not be found") {
+			return "", fmt.Errorf("%s: %s", cmd.Args[0], msg)
+		}
+		return "", errNoCredential
+	case err != nil:
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  API key rotation
+  ─────────────────────────────*/
+
+const (
+	rateLimitCooldown = time.Minute // a key that got 429 is skipped this long
+	quotaCooldown     = time.Hour   // a key whose quota ran out is skipped this long
+)
+
+// apiKeyRing spreads requests over several API keys: a key that is rate limited is put
+// aside for a while and the request is retried with the next one. It is shared by every
+// agent of the process.
+type apiKeyRing struct {
+	mu      sync.Mutex
+	keys    []*ringKey
+	current int
+}
+
+type ringKey struct {
+	id, label string // fingerprint stored in the usage table, and "…abcd" for display
+	client    *openai.Client
+	until     time.Time // skipped until then
+}
+
+// newKeyRing builds one client per key with the shared provider settings.
+func newKeyRing(keys []string) (*apiKeyRing, error) {
+	r := &apiKeyRing{}
+	for _, key := range keys {
+		cfg, err := providerConfig(key)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256([]byte(key))
+		r.keys = append(r.keys, &ringKey{
+			id:     hex.EncodeToString(sum[:6]),
+			label:  "…" + key[max(len(key)-4, 0):],
+			client: openai.NewClientWithConfig(cfg),
+		})
+	}
+	if len(r.keys) > 1 {
+		log.Printf("[agent] 🔑 Rotating over %d API keys on rate limits.\n", len(r.keys))
+	}
+	return r, nil
+}
+
+// pick returns the next key that is not cooling down, or the one that recovers first.
+func (r *apiKeyRing) pick() *ringKey {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	best := r.keys[r.current]
+	for i := range r.keys {
+		k := r.keys[(r.current+i)%len(r.keys)]
+		if !now.Before(k.until) {
+			r.current = (r.current + i) % len(r.keys)
+			return k
+		}
+		if k.until.Before(best.until) {
+			best = k
+		}
+	}
+	return best
+}
+
+// setAside marks k as rate limited and moves on to the next key.
+func (r *apiKeyRing) setAside(k *ringKey, cooldown time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k.until = time.Now().Add(cooldown)
+	if r.keys[r.current] == k {
+		r.current = (r.current + 1) % len(r.keys)
+	}
+}
+
+// rateLimited reports whether err is a 429 and how long to leave the key alone.
+func rateLimited(err error) (time.Duration, bool) {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode == http.StatusTooManyRequests {
+		if apiErr.Code == "insufficient_quota" || apiErr.Type == "insufficient_quota" {
+			return quotaCooldown, true
+		}
+		return rateLimitCooldown, true
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) && reqErr.HTTPStatusCode == http.StatusTooManyRequests {
+		return rateLimitCooldown, true
+	}
+	return 0, false
+}
+
+// createWithKeys sends req with the current key and, while it is rate limited, with
+// each other key once. The usage of every key is recorded when there is more than one.
+func (a *AutonomousCodingAgent) createWithKeys(req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	release, err := apiGate.acquire(a.context())
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	defer release()
+	r := a.keys
+	var resp openai.ChatCompletionResponse
+	for attempt := 0; attempt < len(r.keys); attempt++ {
+		k := r.pick()
+		if a.stream {
+			resp, err = a.streamCompletion(k.client, req)
+		} else {
+			resp, err = k.client.CreateChatCompletion(a.context(), req)
+		}
+		cooldown, limited := rateLimited(err)
+		if len(r.keys) > 1 {
+			a.recordKeyUsage(k, resp.Usage, limited)
+		}
+		if !limited || len(r.keys) == 1 {
+			if limited {
+				apiGate.pause(rateLimitPause)
+			}
+			return resp, err
+		}
+		log.Printf("[agent] 🔑 Key %s is rate limited; trying the next key.\n", k.label)
+		r.setAside(k, cooldown)
+	}
+	apiGate.pause(rateLimitPause)
+	return resp, fmt.Errorf("all %d API keys are rate limited: %w", len(r.keys), err)
+}
+
+// recordKeyUsage adds one request to today's row of k in ~/.zug/state.db.
+func (a *AutonomousCodingAgent) recordKeyUsage(k *ringKey, usage openai.Usage, limited bool) {
+	st, err := a.responseCache()
+	if err != nil {
+		return
+	}
+	limitedCount := 0
+	if limited {
+		limitedCount = 1
+	}
+	_, err = st.db.Exec(`INSERT INTO key_usage (key_id, label, day, requests, prompt_tokens, completion_tokens, rate_limited)
+		VALUES (?, ?, ?, 1, ?, ?, ?)
+		ON CONFLICT (key_id, day) DO UPDATE SET
+			requests = requests + 1,
+			prompt_tokens = prompt_tokens + excluded.prompt_tokens,
+			completion_tokens = completion_tokens + excluded.completion_tokens,
+			rate_limited = rate_limited + excluded.rate_limited`,
+		k.id, k.label, time.Now().Format("2006-01-02"), usage.PromptTokens, usage.CompletionTokens, limitedCount)
+	if err != nil {
+		log.Printf("[agent] ⚠️ Could not record key usage: %v\n", err)
+	}
+}
+
+// splitKeys accepts several keys separated by commas, whitespace or newlines.
+func splitKeys(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+}
+
+/*──────────────────────────────
+  zug keys
+  ─────────────────────────────*/
+
+func keysCommand(fs *flag.FlagSet) func() {
+	days := fs.Int("days", 30, "show usage of the last N days")
+	return func() {
+		dir, err := userZugDir()
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		st, err := openState(dir)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		defer st.db.Close()
+		since := time.Now().AddDate(0, 0, -*days+1).Format("2006-01-02")
+		rows, err := st.db.Query(`SELECT label, key_id, SUM(requests), SUM(prompt_tokens), SUM(completion_tokens), SUM(rate_limited), MAX(day)
+			FROM key_usage WHERE day >= ? GROUP BY key_id ORDER BY SUM(requests) DESC`, since)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		defer rows.Close()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tID\tREQUESTS\tPROMPT\tCOMPLETION\tRATE LIMITED\tLAST USED")
+		n := 0
+		for rows.Next() {
+			var label, id, last string
+			var requests, prompt, completion, limited int
+			if err := rows.Scan(&label, &id, &requests, &prompt, &completion, &limited, &last); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n", label, id, requests, prompt, completion, limited, last)
+			n++
+		}
+		if err := rows.Err(); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if n == 0 {
+			fmt.Printf("No key usage recorded in the last %d days (it is tracked when more than one API key is configured).\n", *days)
+			return
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Cross-project knowledge base
+  ─────────────────────────────*/
+
+// Kinds of knowledge entries.
+const (
+	knowledgeOutcome    = "outcome"    // what a past run was asked and how it ended
+	knowledgeConvention = "convention" // a convention that holds beyond one project
+)
+
+const (
+	defaultEmbeddingModel = string(openai.SmallEmbedding3)
+	defaultKnowledgeTop   = 5
+	defaultKnowledgeScore = 0.35 // minimum cosine similarity of an entry to the task
+	knowledgeTextLimit    = 2000 // characters of an entry
+)
+
+// knowledgeConfig is the `knowledge:` section of zug.yaml. ZUG_KNOWLEDGE=on turns the
+// knowledge base on for every project instead.
+type knowledgeConfig struct {
+	Enabled        bool    `yaml:"enabled"`
+	EmbeddingModel string  `yaml:"embedding_model"` // default text-embedding-3-small
+	Top            int     `yaml:"top"`             // entries shown per task (default 5)
+	MinScore       float64 `yaml:"min_score"`       // minimum similarity to the task (default 0.35)
+}
+
+func (c knowledgeConfig) enabled() bool {
+	return c.Enabled || os.Getenv("ZUG_KNOWLEDGE") == "on"
+}
+
+func (c knowledgeConfig) model() string {
+	if c.EmbeddingModel != "" {
+		return c.EmbeddingModel
+	}
+	return defaultEmbeddingModel
+}
+
+// knowledgeEntry is one row of the knowledge table in ~/.zug/state.db.
+type knowledgeEntry struct {
+	id      int64
+	kind    string
+	project string
+	text    string
+	created string
+	score   float64 // similarity to the query, when searched
+}
+
+// embed returns the embedding of text, sent with the next usable API key.
+func embed(keys *apiKeyRing, model, text string) ([]float32, int, error) {
+	resp, err := keys.pick().client.CreateEmbeddings(context.Background(), openai.EmbeddingRequest{
+		Input: []string{text},
+		Model: openai.EmbeddingModel(model),
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: embedding failed: %w", errProvider, err)
+	}
+	if len(resp.Data) == 0 {
+		return nil, 0, fmt.Errorf("%w: empty embedding response", errProvider)
+	}
+	return resp.Data[0].Embedding, resp.Usage.PromptTokens, nil
+}
+
+func encodeVector(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+	}
+	return b
+}
+
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// addKnowledge embeds text and stores it.
+func addKnowledge(st *stateStore, keys *apiKeyRing, model, kind, project, text string) (int, error) {
+	if len(text) > knowledgeTextLimit {
+		text = text[:validCut(text, knowledgeTextLimit)]
+	}
+	vec, tokens, err := embed(keys, model, text)
+	if err != nil {
+		return 0, err
+	}
+	_, err = st.db.Exec(`INSERT INTO knowledge (kind, project, text, model, embedding, created) VALUES (?, ?, ?, ?, ?, ?)`,
+		kind, project, text, model, encodeVector(vec), stateTime(time.Now()))
+	if err != nil {
+		return tokens, fmt.Errorf("cannot store knowledge: %w", err)
+	}
+	return tokens, nil
+}
+
+// searchKnowledge returns the top entries most similar to query with at least minScore.
+// Entries embedded with another model are not comparable and are skipped.
+func searchKnowledge(st *stateStore, keys *apiKeyRing, model, query string, top int, minScore float64) ([]knowledgeEntry, int, error) {
+	vec, tokens, err := embed(keys, model, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := st.db.Query(`SELECT id, kind, project, text, created, embedding FROM knowledge WHERE model = ?`, model)
+	if err != nil {
+		return nil, tokens, fmt.Errorf("cannot read the knowledge base: %w", err)
+	}
+	defer rows.Close()
+	var found []knowledgeEntry
+	for rows.Next() {
+		var e knowledgeEntry
+		var raw []byte
+		if err := rows.Scan(&e.id, &e.kind, &e.project, &e.text, &e.created, &raw); err != nil {
+			return nil, tokens, err
+		}
+		if e.score = cosine(vec, decodeVector(raw)); e.score >= minScore {
+			found = append(found, e)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].score > found[j].score })
+	if len(found) > top {
+		found = found[:top]
+	}
+	return found, tokens, rows.Err()
+}
+
+// knowledgeStore opens the user's state store when the knowledge base is on.
+func (a *AutonomousCodingAgent) knowledgeStore() (*stateStore, bool) {
+	if a.child || !a.cfg.Knowledge.enabled() {
+		return nil, false
+	}
+	st, err := a.responseCache()
+	if err != nil {
+		log.Printf("[agent] ⚠️ Knowledge base unavailable: %v\n", err)
+		return nil, false
+	}
+	return st, true
+}
+
+// consultKnowledge looks up past outcomes and conventions related to task and keeps them
+// for the system prompt. Failures only cost the hints.
+func (a *AutonomousCodingAgent) consultKnowledge(task string) {
+	a.knowledge = ""
+	st, ok := a.knowledgeStore()
+	if !ok {
+		return
+	}
+	top := a.cfg.Knowledge.Top
+	if top <= 0 {
+		top = defaultKnowledgeTop
+	}
+	minScore := a.cfg.Knowledge.MinScore
+	if minScore <= 0 {
+		minScore = defaultKnowledgeScore
+	}
+	found, tokens, err := searchKnowledge(st, a.keys, a.cfg.Knowledge.model(), task, top, minScore)
+	a.usage.PromptTokens += tokens
+	a.usage.TotalTokens += tokens
+	if err != nil {
+		log.Printf("[agent] ⚠️ Could not consult the knowledge base: %v\n", err)
+		return
+	}
+	if len(found) == 0 {
+		return
+	}
+	log.Printf("[agent] 📚 %d related entries from the knowledge base.\n", len(found))
+	var sb strings.Builder
+	sb.WriteString("Knowledge from earlier work, possibly in other projects (follow conventions unless this project clearly does otherwise; outcomes are for reference):")
+	for _, e := range found {
+		source := e.kind
+		if e.project != "" {
+			source += ", " + e.project
+		}
+		fmt.Fprintf(&sb, "\n- [%s] %s", source, strings.ReplaceAll(e.text, "\n", " "))
+	}
+	a.knowledge = sb.String()
+}
+
+// recordKnowledge stores what the run was asked and how it ended.
+func (a *AutonomousCodingAgent) recordKnowledge(task string, runErr error) {
+	st, ok := a.knowledgeStore()
+	if !ok {
+		return
+	}
+	result := "done"
+	switch {
+	case runErr != nil:
+		result = fmt.Sprintf("not finished (%v)", runErr)
+	case !a.status.verified:
+		result = "done, unverified"
+	}
+	summary := firstLine(a.lastReply)
+	if a.outcome != nil {
+		summary = a.outcome.Summary
+	}
+	text := fmt.Sprintf("Task: %s\nResult: %s. %s", firstLine(task), result, summary)
+	tokens, err := addKnowledge(st, a.keys, a.cfg.Knowledge.model(), knowledgeOutcome, a.projectDir, text)
+	a.usage.PromptTokens += tokens
+	a.usage.TotalTokens += tokens
+	if err != nil {
+		log.Printf("[agent] ⚠️ Could not record the run in the knowledge base: %v\n", err)
+	}
+}
+
+// learnConvention stores a convention that holds beyond this project.
+func (a *AutonomousCodingAgent) learnConvention(note string) (string, error) {
+	st, ok := a.knowledgeStore()
+	if !ok {
+		return "", fmt.Errorf("the cross-project knowledge base is off (knowledge.enabled in zug.yaml or ZUG_KNOWLEDGE=on); remember the note for this project without 'global'")
+	}
+	tokens, err := addKnowledge(st, a.keys, a.cfg.Knowledge.model(), knowledgeConvention, a.projectDir, note)
+	a.usage.PromptTokens += tokens
+	a.usage.TotalTokens += tokens
+	if err != nil {
+		return "", err
+	}
+	return "saved to the cross-project knowledge base: " + note, nil
+}
+
+/*──────────────────────────────
+  zug knowledge
+  ─────────────────────────────*/
+
+func knowledgeCommand(fs *flag.FlagSet) func() {
+	search := fs.String("search", "", "list the entries most similar to this text (calls the embeddings API)")
+	add := fs.String("add", "", "add a convention, e.g. \"this org always uses zap for logging\"")
+	forget := fs.String("forget", "", "delete the entries with these comma-separated IDs")
+	prune := fs.Bool("prune", false, "delete every entry matching -kind, -project and -before")
+	kind := fs.String("kind", "", "only entries of this kind: outcome or convention")
+	project := fs.String("project", "", "only entries from this project directory")
+	before := fs.String("before", "", "only entries created before this date (YYYY-MM-DD)")
+	model := fs.String("embedding-model", defaultEmbeddingModel, "embedding model for -search and -add")
+	top := fs.Int("top", 10, "entries listed by -search")
+	return func() {
+		dir, err := userZugDir()
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		st, err := openState(dir)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		defer st.db.Close()
+
+		where, params := []string{"1 = 1"}, []any{}
+		if *kind != "" {
+			where, params = append(where, "kind = ?"), append(params, *kind)
+		}
+		if *project != "" {
+			where, params = append(where, "project = ?"), append(params, *project)
+		}
+		if *before != "" {
+			t, err := time.Parse("2006-01-02", *before)
+			if err != nil {
+				log.Fatalf("❌ -before: %v", err)
+			}
+			where, params = append(where, "created < ?"), append(params, stateTime(t))
+		}
+
+		switch {
+		case *add != "":
+			keys, err := apiKeys()
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			if _, err := addKnowledge(st, keys, *model, knowledgeConvention, "", strings.TrimSpace(*add)); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			fmt.Println("Added.")
+		case *forget != "":
+			n := 0
+			for _, id := range strings.Split(*forget, ",") {
+				v, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+				if err != nil {
+					log.Fatalf("❌ invalid ID %q", id)
+				}
+				res, err := st.db.Exec(`DELETE FROM knowledge WHERE id = ?`, v)
+				if err != nil {
+					log.Fatalf("❌ %v", err)
+				}
+				c, _ := res.RowsAffected()
+				n += int(c)
+			}
+			fmt.Printf("Deleted %d entr(ies).\n", n)
+		case *prune:
+			if len(where) == 1 {
+				log.Fatalf("❌ -prune needs at least one of -kind, -project or -before")
+			}
+			res, err := st.db.Exec(`DELETE FROM knowledge WHERE `+strings.Join(where, " AND "), params...)
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			n, _ := res.RowsAffected()
+			fmt.Printf("Deleted %d entr(ies).\n", n)
+		case *search != "":
+			keys, err := apiKeys()
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			found, _, err := searchKnowledge(st, keys, *model, *search, *top, 0)
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			printKnowledge(found, true)
+		default:
+			rows, err := st.db.Query(`SELECT id, kind, project, text, created FROM knowledge WHERE `+strings.Join(where, " AND ")+` ORDER BY id`, params...)
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			defer rows.Close()
+			var all []knowledgeEntry
+			for rows.Next() {
+				var e knowledgeEntry
+				if err := rows.Scan(&e.id, &e.kind, &e.project, &e.text, &e.created); err != nil {
+					log.Fatalf("❌ %v", err)
+				}
+				all = append(all, e)
+			}
+			if err := rows.Err(); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			printKnowledge(all, false)
+		}
+	}
+}
+
+func printKnowledge(entries []knowledgeEntry, scored bool) {
+	if len(entries) == 0 {
+		fmt.Println("The knowledge base has no matching entries.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := "ID\tKIND\tCREATED\tPROJECT\tTEXT"
+	if scored {
+		header = "ID\tSCORE\tKIND\tCREATED\tPROJECT\tTEXT"
+	}
+	fmt.Fprintln(w, header)
+	for _, e := range entries {
+		project := e.project
+		if project == "" {
+			project = "—"
+		}
+		text := strings.ReplaceAll(e.text, "\n", " · ")
+		if len(text) > 100 {
+			text = text[:validCut(text, 100)] + "…"
+		}
+		if scored {
+			fmt.Fprintf(w, "%d\t%.2f\t%s\t%s\t%s\t%s\n", e.id, e.score, e.kind, e.created[:10], project, text)
+		} else {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", e.id, e.kind, e.created[:10], project, text)
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestKnowledgeFromEarlierRunsIsConsulted(t *testing.T) {
+	files := map[string]string{configFileName: "knowledge:\n  enabled: true\n  min_score: 0.1\n"}
+	a, _ := newTestAgent(t, files,
+		mock.Call("remember", map[string]any{"note": "This org always uses zap for logging", "global": true}),
+		mock.Text("Added structured logging to the server with zap."),
+	)
+	home := os.Getenv("ZUG_HOME")
+	if err := a.feedbackLoop("add logging to the server"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, srv := newTestAgent(t, files, mock.Text("ok"))
+	t.Setenv("ZUG_HOME", home)
+	if err := b.feedbackLoop("add logging to the worker"); err != nil {
+		t.Fatal(err)
+	}
+	system := srv.Requests()[0].Messages[0].Content
+	assertContains(t, system, "[convention, "+a.projectDir+"] This org always uses zap for logging")
+	assertContains(t, system, "Task: add logging to the server")
+}
+
+func TestKnowledgeIsOffByDefault(t *testing.T) {
+	a, _ := newTestAgent(t, nil)
+	if _, err := a.learnConvention("use zap"); err == nil || !strings.Contains(err.Error(), "knowledge base is off") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestCosine(t *testing.T) {
+	if got := cosine([]float32{1, 0}, []float32{1, 0}); got < 0.999 {
+		t.Errorf("identical vectors: %f", got)
+	}
+	if got := cosine([]float32{1, 0}, []float32{0, 1}); got != 0 {
+		t.Errorf("orthogonal vectors: %f", got)
+	}
+	if v := decodeVector(encodeVector([]float32{0.5, -2})); v[0] != 0.5 || v[1] != -2 {
+		t.Errorf("round trip = %v", v)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+/*──────────────────────────────
+  Linter feedback
+  ─────────────────────────────*/
+
+const maxLintOutput = 8000 // characters of linter output fed back to the model
+
+// lintConfig is the `lint:` section of zug.yaml.
+type lintConfig struct {
+	Command  string `yaml:"command"`  // overrides autodetection
+	Disabled bool   `yaml:"disabled"` // never run a linter
+}
+
+// lintCommand resolves the configured or detected linter for t, or "" when linting is off.
+func (a *AutonomousCodingAgent) lintCommand(t checkTarget) string {
+	switch {
+	case a.cfg.Lint.Disabled:
+		return ""
+	case t.root != nil && t.root.Lint != "":
+		return t.root.Lint
+	case t.root == nil && a.cfg.Lint.Command != "":
+		return a.cfg.Lint.Command
+	}
+	return toolchainCommand(a.detectToolchains(t.dir), func(tc toolchain) string { return tc.LintCmd })
+}
+
+// runLinter lints the project once the agent has edited files. It reports ok=true when
+// there is nothing to lint, no linter is available, or the linter exits cleanly.
+func (a *AutonomousCodingAgent) runLinter() (output string, ok bool) {
+	if len(a.changes) == 0 {
+		return "", true
+	}
+	var failures []string
+	for _, t := range a.checkTargets() {
+		cmd := a.lintCommand(t)
+		if cmd == "" {
+			continue
+		}
+		log.Printf("[agent] 🧹 Running linter: %s%s\n", t.label(), cmd)
+		out, err := a.execShellIn(t, cmd)
+		if err == nil {
+			log.Println("[agent] 🧹 Linter reported no violations.")
+			continue
+		}
+		if len(out) > maxLintOutput {
+			out = out[:maxLintOutput] + "\n... (linter output truncated)"
+		}
+		fmt.Printf("🧹 %sLinter Output:\n%s\n\n", t.label(), out)
+		failures = append(failures, fmt.Sprintf("$ %s%s\n%s\nERROR: %s", t.label(), cmd, out, err))
+	}
+	return strings.Join(failures, "\n\n"), len(failures) == 0
+}
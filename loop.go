@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+/*──────────────────────────────
+  Repeated-action detection
+  ─────────────────────────────*/
+
+const (
+	defaultMaxRepeats = 3 // identical consecutive tool calls before zug steps in
+	loopAbortAfter    = 2 // further identical calls, after the warning, before the run is aborted
+)
+
+var errRepeatedAction = errors.New("stuck repeating the same action")
+
+// loopConfig is the `loop:` section of zug.yaml.
+type loopConfig struct {
+	MaxRepeats int  `yaml:"max_repeats"` // identical calls in a row before the model is told to change course (default 3)
+	Disabled   bool `yaml:"disabled"`    // never intervene
+}
+
+// loopDetector notices when the model makes the same tool call with the same arguments
+// again and again: re-reading a file, re-running failing tests without changing
+// anything in between.
+type loopDetector struct {
+	last    string // tool and canonical arguments of the previous call
+	repeats int    // consecutive calls equal to last, including it
+}
+
+// callSignature identifies a call independently of JSON key order and whitespace.
+func callSignature(tool, jsonArgs string) string {
+	var args any
+	if err := json.Unmarshal([]byte(jsonArgs), &args); err != nil {
+		return tool + " " + jsonArgs
+	}
+	canonical, _ := json.Marshal(args) // map keys are sorted
+	return tool + " " + string(canonical)
+}
+
+// checkRepeat records a tool call. Once the same call has come maxRepeats times in a
+// row it is not run again: checkRepeat returns the corrective instruction to send back
+// instead, and errRepeatedAction when the model keeps repeating it regardless.
+func (a *AutonomousCodingAgent) checkRepeat(tool, jsonArgs string) (string, error) {
+	if a.cfg.Loop.Disabled {
+		return "", nil
+	}
+	limit := a.cfg.Loop.MaxRepeats
+	if limit <= 0 {
+		limit = defaultMaxRepeats
+	}
+	sig := callSignature(tool, jsonArgs)
+	if sig == a.loop.last {
+		a.loop.repeats++
+	} else {
+		a.loop.last, a.loop.repeats = sig, 1
+	}
+	switch {
+	case a.loop.repeats < limit:
+		return "", nil
+	case a.loop.repeats >= limit+loopAbortAfter:
+		log.Printf("[agent] 🔁 %s was called %d times in a row with the same arguments; aborting.\n", tool, a.loop.repeats)
+		return "", fmt.Errorf("%w: %s was called %d times in a row with the same arguments %s, even after being told to change course", errRepeatedAction, tool, a.loop.repeats, jsonArgs)
+	}
+	log.Printf("[agent] 🔁 %s was called %d times in a row with the same arguments; asking the model to change course.\n", tool, a.loop.repeats)
+	return fmt.Sprintf("NOT RUN: this is call number %d in a row of %s with exactly the same arguments. Nothing has changed since the last one, so it would return the same result. You are going in circles. Step back and do something different: if you were re-reading a file, use what you already read; if a command keeps failing, change the code (or the command) before running it again; if you are stuck, explain what is blocking you and finish. Repeating this call again will abort the task.", a.loop.repeats, tool), nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestRepeatedCallsAreNotRunAgain(t *testing.T) {
+	read := mock.Call("read_file", map[string]any{"path": "a.txt"})
+	a, srv := newTestAgent(t, map[string]string{"a.txt": "hello"},
+		read, read, read,
+		mock.Text("done"),
+	)
+	if _, err := a.chat("read a.txt", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	msgs := srv.Requests()[3].Messages
+	last := msgs[len(msgs)-1]
+	assertContains(t, last.Content, "NOT RUN: this is call number 3 in a row of read_file")
+	if strings.Contains(last.Content, "hello") {
+		t.Errorf("the repeated call ran: %s", last.Content)
+	}
+}
+
+func TestRepeatedCallsAbort(t *testing.T) {
+	cmd := mock.Call("run_shell", map[string]any{"command": "false"})
+	a, _ := newTestAgent(t, nil, cmd, cmd, cmd, cmd, cmd)
+	_, err := a.chat("make it pass", phaseTools)
+	if !errors.Is(err, errRepeatedAction) {
+		t.Fatalf("err = %v, want errRepeatedAction", err)
+	}
+}
+
+func TestCallSignatureIgnoresKeyOrder(t *testing.T) {
+	if callSignature("t", `{"a":1, "b":"x"}`) != callSignature("t", `{"b":"x","a":1}`) {
+		t.Error("signatures differ by key order")
+	}
+}
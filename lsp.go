@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*──────────────────────────────
+  Language server diagnostics
+  ─────────────────────────────*/
+
+const (
+	lspStartTimeout = 30 * time.Second       // initialize handshake
+	lspDiagTimeout  = 8 * time.Second        // wait for diagnostics after an edit
+	lspSettle       = 400 * time.Millisecond // servers often publish twice in quick succession
+	maxDiagnostics  = 40
+)
+
+// lspConfig is the `lsp:` section of zug.yaml.
+type lspConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	Servers map[string]string `yaml:"servers"` // extension -> server command speaking LSP on stdio
+}
+
+var (
+	defaultLanguageServers = map[string]string{
+		".go": "gopls", ".py": "pyright-langserver --stdio",
+		".ts": "typescript-language-server --stdio", ".tsx": "typescript-language-server --stdio",
+		".js": "typescript-language-server --stdio", ".jsx": "typescript-language-server --stdio",
+	}
+	lspLanguageIDs = map[string]string{
+		".go": "go", ".py": "python", ".ts": "typescript", ".tsx": "typescriptreact",
+		".js": "javascript", ".jsx": "javascriptreact",
+	}
+)
+
+type lspDiagnostic struct {
+	Range struct {
+		Start struct {
+			Line      int `json:"line"`
+			Character int `json:"character"`
+		} `json:"start"`
+	} `json:"range"`
+	Severity int    `json:"severity"` // 1 error, 2 warning, 3 information, 4 hint
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+type lspMessage struct {
+	ID     *json.RawMessage `json:"id,omitempty"`
+	Method string           `json:"method,omitempty"`
+	Params json.RawMessage  `json:"params,omitempty"`
+	Result json.RawMessage  `json:"result,omitempty"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// lspClient is a minimal JSON-RPC client for one language server process.
+type lspClient struct {
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	mu       sync.Mutex
+	nextID   int
+	pending  map[int]chan lspMessage
+	diags    map[string][]lspDiagnostic // by document URI
+	versions map[string]int
+	updated  chan string // URIs with freshly published diagnostics
+}
+
+// startLanguageServer launches command in dir and performs the initialize handshake.
+func startLanguageServer(command, dir string) (*lspClient, error) {
+	cmd := exec.Command("bash", "-c", "exec "+command)
+	cmd.Dir = dir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cannot start language server %q: %w", command, err)
+	}
+	c := &lspClient{cmd: cmd, stdin: stdin, pending: map[int]chan lspMessage{},
+		diags: map[string][]lspDiagnostic{}, versions: map[string]int{}, updated: make(chan string, 64)}
+	go c.readLoop(bufio.NewReader(stdout))
+
+	root := fileURI(dir)
+	params := map[string]interface{}{
+		"processId": os.Getpid(),
+		"rootUri":   root,
+		"capabilities": map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"publishDiagnostics": map[string]interface{}{},
+				"references":         map[string]interface{}{},
+				"rename":             map[string]interface{}{},
+			},
+		},
+		"workspaceFolders": []map[string]string{{"uri": root, "name": filepath.Base(dir)}},
+	}
+	if _, err := c.call("initialize", params, lspStartTimeout); err != nil {
+		c.close()
+		return nil, fmt.Errorf("language server %q failed to initialize: %w", command, err)
+	}
+	if err := c.notify("initialized", map[string]interface{}{}); err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *lspClient) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = fmt.Fprintf(c.stdin, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (c *lspClient) notify(method string, params interface{}) error {
+	return c.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (c *lspClient) call(method string, params interface{}, timeout time.Duration) (json.RawMessage, error) {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	ch := make(chan lspMessage, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	if err := c.write(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}); err != nil {
+		return nil, err
+	}
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, fmt.Errorf("language server exited")
+		}
+		if resp.Error != nil {
+			return nil, fmt.Errorf("%s: %s", method, resp.Error.Message)
+		}
+		return resp.Result, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("%s timed out after %s", method, timeout)
+	}
+}
+
+// readLoop decodes framed messages: responses go to their caller, diagnostics are stored,
+// and server-to-client requests get an empty answer so the server does not block.
+func (c *lspClient) readLoop(r *bufio.Reader) {
+	defer func() {
+		c.mu.Lock()
+		for id, ch := range c.pending {
+			close(ch)
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+	}()
+	for {
+		length := -1
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			if v, ok := strings.CutPrefix(line, "Content-Length:"); ok {
+				length, _ = strconv.Atoi(strings.TrimSpace(v))
+			}
+		}
+		if length < 0 {
+			continue
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+		var msg lspMessage
+		if json.Unmarshal(body, &msg) != nil {
+			continue
+		}
+		switch {
+		case msg.Method == "textDocument/publishDiagnostics":
+			var p struct {
+				URI         string          `json:"uri"`
+				Diagnostics []lspDiagnostic `json:"diagnostics"`
+			}
+			if json.Unmarshal(msg.Params, &p) == nil {
+				c.mu.Lock()
+				c.diags[p.URI] = p.Diagnostics
+				c.mu.Unlock()
+				select {
+				case c.updated <- p.URI:
+				default:
+				}
+			}
+		case msg.Method != "" && msg.ID != nil:
+			var result interface{}
+			if msg.Method == "workspace/configuration" {
+				var p struct {
+					Items []json.RawMessage `json:"items"`
+				}
+				json.Unmarshal(msg.Params, &p)
+				result = make([]interface{}, len(p.Items))
+			}
+			c.write(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+		case msg.ID != nil:
+			id, err := strconv.Atoi(string(*msg.ID))
+			if err != nil {
+				continue
+			}
+			c.mu.Lock()
+			ch := c.pending[id]
+			delete(c.pending, id)
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+		}
+	}
+}
+
+// sync sends the current content of a document: it is opened on first use and changed
+// after that.
+func (c *lspClient) sync(full, languageID, content string) error {
+	uri := fileURI(full)
+	c.mu.Lock()
+	c.versions[uri]++
+	version := c.versions[uri]
+	c.mu.Unlock()
+	if version == 1 {
+		return c.notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "languageId": languageID, "version": version, "text": content},
+		})
+	}
+	return c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": version},
+		"contentChanges": []map[string]string{{"text": content}},
+	})
+}
+
+// diagnose sends the new content of a document and waits for the server's diagnostics on it.
+func (c *lspClient) diagnose(full, languageID, content string) ([]lspDiagnostic, error) {
+	uri := fileURI(full)
+	for drained := false; !drained; { // drop stale notifications from earlier edits
+		select {
+		case <-c.updated:
+		default:
+			drained = true
+		}
+	}
+	if err := c.sync(full, languageID, content); err != nil {
+		return nil, err
+	}
+
+	deadline := time.After(lspDiagTimeout)
+	var settle <-chan time.Time
+	for {
+		select {
+		case u := <-c.updated:
+			if u == uri {
+				settle = time.After(lspSettle)
+			}
+		case <-settle:
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.diags[uri], nil
+		case <-deadline:
+			if settle != nil {
+				c.mu.Lock()
+				defer c.mu.Unlock()
+				return c.diags[uri], nil
+			}
+			return nil, fmt.Errorf("no diagnostics published within %s", lspDiagTimeout)
+		}
+	}
+}
+
+func (c *lspClient) close() {
+	done := make(chan struct{})
+	go func() {
+		c.call("shutdown", nil, 2*time.Second)
+		c.notify("exit", nil)
+		c.cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		c.cmd.Process.Kill()
+	}
+	c.stdin.Close()
+}
+
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+/*──────────────────────────────
+  Agent integration
+  ─────────────────────────────*/
+
+// languageServer returns the running server for path's extension, starting it on first use.
+// Servers that are not installed or fail to start are remembered as nil and not retried.
+func (a *AutonomousCodingAgent) languageServer(path string) *lspClient {
+	ext := strings.ToLower(filepath.Ext(path))
+	command, ok := a.cfg.LSP.Servers[ext]
+	if !ok {
+		command = defaultLanguageServers[ext]
+	}
+	if command == "" {
+		return nil
+	}
+	if c, started := a.lsps[command]; started {
+		return c
+	}
+	if a.lsps == nil {
+		a.lsps = map[string]*lspClient{}
+	}
+	a.lsps[command] = nil
+	if _, err := exec.LookPath(strings.Fields(command)[0]); err != nil {
+		log.Printf("[agent] Language server %q not installed; %s files get no diagnostics or symbol lookups from it.\n", command, ext)
+		return nil
+	}
+	log.Printf("[agent] 🩺 Starting language server: %s\n", command)
+	c, err := startLanguageServer(command, a.projectDir)
+	if err != nil {
+		log.Printf("[agent] ⚠️ %v\n", err)
+		return nil
+	}
+	a.lsps[command] = c
+	return c
+}
+
+// diagnosticsNote returns the language server's errors and warnings for a file just written,
+// formatted for a tool result, or "" when LSP is disabled or the file is clean.
+func (a *AutonomousCodingAgent) diagnosticsNote(path, full string) string {
+	if !a.cfg.LSP.Enabled || a.remote != nil {
+		return ""
+	}
+	c := a.languageServer(path)
+	if c == nil {
+		return ""
+	}
+	content, err := a.fs.ReadFile(full)
+	if err != nil {
+		return ""
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	langID := lspLanguageIDs[ext]
+	if langID == "" {
+		langID = strings.TrimPrefix(ext, ".")
+	}
+	diags, err := c.diagnose(full, langID, string(content))
+	if err != nil {
+		log.Printf("[agent] ⚠️ Diagnostics for %s: %v\n", path, err)
+		return ""
+	}
+	var lines []string
+	sort.SliceStable(diags, func(i, j int) bool { return diags[i].Range.Start.Line < diags[j].Range.Start.Line })
+	for _, d := range diags {
+		severity := "error"
+		switch d.Severity {
+		case 2:
+			severity = "warning"
+		case 3, 4:
+			continue
+		}
+		msg := fmt.Sprintf("%s:%d:%d: %s: %s", path, d.Range.Start.Line+1, d.Range.Start.Character+1, severity, d.Message)
+		if d.Source != "" {
+			msg += " (" + d.Source + ")"
+		}
+		lines = append(lines, msg)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	if len(lines) > maxDiagnostics {
+		lines = append(lines[:maxDiagnostics], fmt.Sprintf("... and %d more", len(lines)-maxDiagnostics))
+	}
+	log.Printf("[agent] 🩺 %d diagnostic(s) in %s.\n", len(lines), path)
+	return "\nLanguage server diagnostics after this edit (fix these before running tests):\n" + strings.Join(lines, "\n")
+}
+
+// closeLanguageServers shuts down every server started during the run.
+func (a *AutonomousCodingAgent) closeLanguageServers() {
+	for command, c := range a.lsps {
+		if c != nil {
+			c.close()
+		}
+		delete(a.lsps, command)
+	}
+}
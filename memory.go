@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*──────────────────────────────
+  Project memory (tools)
+  ─────────────────────────────*/
+
+var memoryFile = zugDirName + "/memory.md" // relative to the project root
+
+const (
+	memoryHeader    = "# zug memory\n\nFacts about this project learned in earlier runs, one per line. Edit or delete lines freely.\n\n"
+	memoryNoteLimit = 500  // characters of one note
+	memoryLimit     = 8000 // characters of notes shown in the system prompt
+)
+
+// memoryNotes returns the notes saved with remember, one per element.
+func (a *AutonomousCodingAgent) memoryNotes() []string {
+	raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, memoryFile))
+	if err != nil {
+		return nil
+	}
+	var notes []string
+	for _, line := range strings.Split(string(raw), "\n") {
+		if note, ok := strings.CutPrefix(strings.TrimSpace(line), "- "); ok && strings.TrimSpace(note) != "" {
+			notes = append(notes, strings.TrimSpace(note))
+		}
+	}
+	return notes
+}
+
+// remember adds note to .zug/memory.md, which later runs read, so facts about the
+// project (required environment, folders not to touch) are learned once.
+func (a *AutonomousCodingAgent) remember(note string) (string, error) {
+	note = strings.Join(strings.Fields(note), " ")
+	switch {
+	case note == "":
+		return "", fmt.Errorf("'note' is empty")
+	case len(note) > memoryNoteLimit:
+		return "", fmt.Errorf("the note has %d characters; keep it to one fact of at most %d", len(note), memoryNoteLimit)
+	}
+	notes := a.memoryNotes()
+	for _, n := range notes {
+		if strings.EqualFold(n, note) {
+			return "already remembered: " + note, nil
+		}
+	}
+	if _, err := a.zugDir(); err != nil {
+		return "", err
+	}
+	full := filepath.Join(a.projectDir, memoryFile)
+	raw, err := a.fs.ReadFile(full)
+	if os.IsNotExist(err) {
+		raw, err = []byte(memoryHeader), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", memoryFile, err)
+	}
+	content := string(raw)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if err := a.fs.WriteFile(full, []byte(content+"- "+note+"\n"), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", memoryFile, err)
+	}
+	return fmt.Sprintf("remembered in %s (%d note(s)): %s", memoryFile, len(notes)+1, note), nil
+}
+
+// recall lists the saved notes.
+func (a *AutonomousCodingAgent) recall() (string, error) {
+	notes := a.memoryNotes()
+	if len(notes) == 0 {
+		return "no notes yet; save lasting facts about the project with remember", nil
+	}
+	return "- " + strings.Join(notes, "\n- "), nil
+}
+
+// describeMemory is the system prompt section with the saved notes, newest last, cut
+// to memoryLimit by dropping the oldest.
+func (a *AutonomousCodingAgent) describeMemory() string {
+	notes := a.memoryNotes()
+	if len(notes) == 0 {
+		return ""
+	}
+	size, first := 0, len(notes)
+	for first > 0 && size+len(notes[first-1]) <= memoryLimit {
+		first--
+		size += len(notes[first])
+	}
+	dropped := ""
+	if first > 0 {
+		dropped = fmt.Sprintf(" (%d older note(s) not shown; see recall)", first)
+	}
+	return fmt.Sprintf("Notes about this project from earlier runs (%s)%s:\n- %s", memoryFile, dropped, strings.Join(notes[first:], "\n- "))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestRememberedNotesReachLaterRuns(t *testing.T) {
+	a, _ := newTestAgent(t, nil,
+		mock.Call("remember", map[string]any{"note": "tests need\nPOSTGRES_URL"}),
+		mock.Call("remember", map[string]any{"note": "Tests need POSTGRES_URL"}),
+		mock.Text("noted"),
+	)
+	if _, err := a.chat("find out how to run the tests", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(readTestFile(t, a.projectDir, memoryFile), "POSTGRES_URL"); got != 1 {
+		t.Errorf("note saved %d times, want once", got)
+	}
+
+	// A later run in the same project sees the note in its system prompt.
+	b, srv := newTestAgent(t, map[string]string{memoryFile: readTestFile(t, a.projectDir, memoryFile)}, mock.Text("ok"))
+	if _, err := b.chat("run the tests", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, srv.Requests()[0].Messages[0].Content, "- tests need POSTGRES_URL")
+	out, _ := b.recall()
+	assertContains(t, out, "tests need POSTGRES_URL")
+}
+
+func TestDescribeMemoryDropsOldestNotes(t *testing.T) {
+	a, _ := newTestAgent(t, nil)
+	long := strings.Repeat("x", memoryNoteLimit-10)
+	for i := range memoryLimit/len(long) + 1 {
+		if _, err := a.remember(fmt.Sprintf("note %d %s", i, long)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := a.remember("newest"); err != nil {
+		t.Fatal(err)
+	}
+	desc := a.describeMemory()
+	assertContains(t, desc, "1 older note(s) not shown")
+	assertContains(t, desc, "newest")
+	if strings.Contains(desc, "note 0 ") {
+		t.Error("the oldest note was kept")
+	}
+}
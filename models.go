@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Model capabilities
+  ─────────────────────────────*/
+
+// reasoningTokenBudget replaces the usual completion limit for reasoning models, whose
+// hidden reasoning tokens count against max_completion_tokens.
+const reasoningTokenBudget = 16000
+
+// modelCaps describes how requests must be shaped for a model family.
+type modelCaps struct {
+	reasoning     bool // rejects temperature and max_tokens; needs max_completion_tokens
+	noTools       bool // no function calling
+	noSystemRoles bool // neither system nor developer messages are accepted
+
+	structuredOutputs bool // supports response_format with a strict JSON schema
+}
+
+// capabilitiesFor matches model names by family prefix; dated snapshots such as
+// o3-mini-2025-01-31 share their family's capabilities. Unknown models are treated as
+// regular chat models.
+func capabilitiesFor(model string) modelCaps {
+	m := strings.ToLower(model)
+	switch {
+	case strings.HasPrefix(m, "o1-mini"), strings.HasPrefix(m, "o1-preview"):
+		return modelCaps{reasoning: true, noTools: true, noSystemRoles: true}
+	case strings.HasPrefix(m, "o1"), strings.HasPrefix(m, "o3"), strings.HasPrefix(m, "o4"),
+		strings.HasPrefix(m, "gpt-5") && !strings.HasPrefix(m, "gpt-5-chat"):
+		return modelCaps{reasoning: true, structuredOutputs: true}
+	case strings.HasPrefix(m, "gpt-4o"), strings.HasPrefix(m, "gpt-4.1"), strings.HasPrefix(m, "gpt-5"):
+		return modelCaps{structuredOutputs: true}
+	}
+	return modelCaps{}
+}
+
+// chatRequest builds a completion request for the agent's model with the sampling
+// parameters of phase; tools may be nil.
+func (a *AutonomousCodingAgent) chatRequest(messages []openai.ChatCompletionMessage, phase string, tools []openai.Tool) (openai.ChatCompletionRequest, error) {
+	caps := capabilitiesFor(a.model)
+	req := openai.ChatCompletionRequest{Model: a.model, Messages: messages}
+	if len(tools) > 0 {
+		if a.usesTextTools() {
+			messages = textToolMessages(messages, tools)
+			req.Messages = messages
+		} else {
+			req.Tools, req.ToolChoice = tools, "auto"
+			if a.serialTools {
+				req.ParallelToolCalls = false
+			}
+		}
+	}
+	if a.responseFormat != nil && caps.structuredOutputs {
+		req.ResponseFormat = a.responseFormat
+	}
+	if caps.reasoning {
+		req.MaxCompletionTokens = reasoningTokenBudget
+		if a.samplingFlags.maxOutputTokens > 0 {
+			req.MaxCompletionTokens = a.samplingFlags.maxOutputTokens
+		}
+	} else {
+		p := a.sampling(phase)
+		req.Temperature, req.TopP, req.MaxTokens = p.temperature, p.topP, p.maxTokens
+		if req.Temperature == 0 {
+			// go-openai omits a zero temperature, which the API reads as 1.
+			req.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+	if caps.noSystemRoles {
+		req.Messages = make([]openai.ChatCompletionMessage, len(messages))
+		for i, m := range messages {
+			if m.Role == openai.ChatMessageRoleSystem {
+				m.Role, m.Content = openai.ChatMessageRoleUser, "Instructions:\n"+m.Content
+			}
+			req.Messages[i] = m
+		}
+	}
+	return req, nil
+}
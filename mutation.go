@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  Mutation testing after success
+  ─────────────────────────────*/
+
+const (
+	mutationDefaultBudget = 10 * time.Minute // wall-clock time of one mutation run
+	mutationDefaultRounds = 2                // test-strengthening iterations
+	maxMutationOutput     = 8000             // characters of surviving mutants fed back to the model
+)
+
+// mutationConfig is the `mutation:` section of zug.yaml.
+type mutationConfig struct {
+	Enabled bool   `yaml:"enabled"` // run after the tests pass (also --mutation)
+	Command string `yaml:"command"` // custom tester; {files} becomes the changed source files. It exits non-zero and prints the survivors when mutants survive
+	Budget  string `yaml:"budget"`  // wall-clock time of each run, e.g. 5m (default 10m)
+	Rounds  int    `yaml:"rounds"`  // test-strengthening iterations (default 2)
+}
+
+func (c mutationConfig) validate() error {
+	if c.Budget != "" {
+		if d, err := time.ParseDuration(c.Budget); err != nil || d <= 0 {
+			return fmt.Errorf("mutation.budget %q must be a positive duration such as 5m", c.Budget)
+		}
+	}
+	if c.Rounds < 0 {
+		return fmt.Errorf("mutation.rounds must not be negative")
+	}
+	return nil
+}
+
+func (c mutationConfig) budget() time.Duration {
+	if d, err := time.ParseDuration(c.Budget); err == nil && d > 0 {
+		return d
+	}
+	return mutationDefaultBudget
+}
+
+func (c mutationConfig) rounds() int {
+	if c.Rounds > 0 {
+		return c.Rounds
+	}
+	return mutationDefaultRounds
+}
+
+// mutationTester runs one mutation testing tool on source files and parses its report
+// into the surviving mutants, each with the diff that the tests did not catch.
+type mutationTester struct {
+	name, bin string
+	exts      []string // source files it mutates
+	cmd       func(files []string) string
+	parse     func(out string) (survivors []string, total int)
+}
+
+var (
+	goMutestingStatus = regexp.MustCompile(`^(PASS|FAIL|SKIP|DUPLICATE) "(.*)" with checksum`)
+	goMutestingScore  = regexp.MustCompile(`\(\d+ passed, \d+ failed, \d+ duplicated, \d+ skipped, total is (\d+)\)`)
+	mutmutSection     = regexp.MustCompile(`^\S.*\(\d+\)$`)
+)
+
+var mutationTesters = []mutationTester{
+	{
+		name: "go-mutesting", bin: "go-mutesting", exts: []string{".go"},
+		cmd: func(files []string) string { return "go-mutesting " + shellJoin(files) },
+		parse: func(out string) ([]string, int) {
+			// Each mutant's diff comes before its status line; FAIL means the tests
+			// still passed, so the mutant survived.
+			var survivors, block []string
+			for _, line := range strings.Split(out, "\n") {
+				m := goMutestingStatus.FindStringSubmatch(line)
+				if m == nil {
+					block = append(block, line)
+					continue
+				}
+				if m[1] == "FAIL" {
+					survivors = append(survivors, strings.TrimSpace(strings.Join(block, "\n"))+"\n(survived: "+m[2]+")")
+				}
+				block = nil
+			}
+			total := len(survivors)
+			if m := goMutestingScore.FindStringSubmatch(out); m != nil {
+				fmt.Sscan(m[1], &total)
+			}
+			return survivors, total
+		},
+	},
+	{
+		name: "mutmut", bin: "mutmut", exts: []string{".py"},
+		cmd: func(files []string) string {
+			return "mutmut run --paths-to-mutate " + shellQuote(strings.Join(files, ",")) + " >/dev/null 2>&1; mutmut show all"
+		},
+		parse: func(out string) ([]string, int) {
+			// `mutmut show all` lists the mutants under one "<Status> (n)" heading per
+			// status, each starting with "# mutant <id>".
+			var survivors []string
+			section, total := "", 0
+			for _, line := range strings.Split(out, "\n") {
+				switch {
+				case mutmutSection.MatchString(line) && !strings.HasPrefix(line, "---"):
+					section = line
+				case strings.HasPrefix(line, "# mutant "):
+					total++
+					if strings.HasPrefix(section, "Survived") {
+						survivors = append(survivors, line)
+					}
+				case strings.HasPrefix(section, "Survived") && len(survivors) > 0 && !strings.HasPrefix(line, "---- "):
+					survivors[len(survivors)-1] += "\n" + line
+				}
+			}
+			for i := range survivors {
+				survivors[i] = strings.TrimSpace(survivors[i])
+			}
+			return survivors, total
+		},
+	},
+}
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, s := range args {
+		quoted[i] = shellQuote(s)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// mutationRun is the state of mutation testing in a run.
+type mutationRun struct {
+	rounds    int
+	tester    string
+	survivors []string
+	total     int  // mutants tried in the last run, 0 when unknown
+	timedOut  bool // the last run used up its budget, so its results are partial
+}
+
+// mutationTargets lists the changed source files a tester mutates: not deleted and not
+// tests themselves.
+func (a *AutonomousCodingAgent) mutationTargets(exts []string) []string {
+	var files []string
+	for _, c := range a.netChanges() {
+		ext := strings.ToLower(filepath.Ext(c.path))
+		if c.action == "deleted" || isTestFile(c.path) || (exts != nil && !slices.Contains(exts, ext)) {
+			continue
+		}
+		files = append(files, filepath.ToSlash(c.path))
+	}
+	return files
+}
+
+// runMutation runs the configured tester, or the first installed one for the changed
+// files, within the time budget, and records the results in m. It returns false when
+// there was nothing to run.
+func (a *AutonomousCodingAgent) runMutation(m *mutationRun) (bool, error) {
+	var cmd string
+	var parse func(string) ([]string, int)
+	tester := "mutation.command"
+	if custom := a.cfg.Mutation.Command; custom != "" {
+		if files := a.mutationTargets(nil); len(files) > 0 {
+			cmd = strings.ReplaceAll(custom, "{files}", shellJoin(files))
+		}
+	} else {
+		for _, t := range mutationTesters {
+			if files := a.mutationTargets(t.exts); len(files) > 0 && a.installed(t.bin) {
+				tester, cmd, parse = t.name, t.cmd(files), t.parse
+				break
+			}
+		}
+	}
+	if cmd == "" {
+		return false, nil
+	}
+
+	budget := a.cfg.Mutation.budget()
+	log.Printf("[agent] 🧬 Running mutation testing (%s, budget %s): %s\n", tester, budget, cmd)
+	out, timedOut, err := a.execShellWithin(cmd, budget)
+	if a.remote == nil {
+		out = strings.ReplaceAll(out, filepath.Clean(a.projectDir)+string(filepath.Separator), "")
+	}
+
+	m.tester, m.survivors, m.total, m.timedOut = tester, nil, 0, timedOut
+	switch {
+	case parse == nil:
+		// A custom tester reports survivors through its exit code.
+		if err != nil && !timedOut {
+			m.survivors = []string{out}
+		}
+	default:
+		m.survivors, m.total = parse(out)
+		if m.total == 0 && len(m.survivors) == 0 && err != nil && !timedOut {
+			m.tester = ""
+			return true, fmt.Errorf("%s failed: %w\n%s", tester, err, out)
+		}
+	}
+	return true, nil
+}
+
+// mutationFollowUp runs mutation testing on the changed code once the tests pass, and
+// keeps the run going while mutants survive, for at most mutation.rounds iterations. It
+// returns the next instruction and true in that case.
+func (a *AutonomousCodingAgent) mutationFollowUp() (string, bool) {
+	if !a.cfg.Mutation.Enabled || len(a.changes) == 0 {
+		return "", false
+	}
+	if a.mutation == nil {
+		a.mutation = &mutationRun{}
+	}
+	m := a.mutation
+	ran, err := a.runMutation(m)
+	if err != nil {
+		log.Printf("[agent] ⚠️ Mutation testing failed: %v\n", err)
+		return "", false
+	}
+	if !ran {
+		log.Println("[agent] 🧬 Mutation testing is enabled, but no mutation tester applies to the changed files (install go-mutesting or mutmut, or set mutation.command).")
+		return "", false
+	}
+	if m.timedOut {
+		log.Printf("[agent] ⚠️ Mutation testing used up its budget of %s; the results are partial.\n", a.cfg.Mutation.budget())
+	}
+	if len(m.survivors) == 0 {
+		log.Println("[agent] 🧬 No surviving mutants: the tests catch every mutation of the changed code that was tried.")
+		return "", false
+	}
+	if m.rounds >= a.cfg.Mutation.rounds() {
+		log.Printf("[agent] ⚠️ %d mutant(s) still survive after %d round(s) of stronger tests.\n", len(m.survivors), m.rounds)
+		return "", false
+	}
+	m.rounds++
+	log.Printf("[agent] 🧬 %d mutant(s) survived; asking for stronger tests (round %d of %d).\n", len(m.survivors), m.rounds, a.cfg.Mutation.rounds())
+	list := strings.Join(m.survivors, "\n\n")
+	if len(list) > maxMutationOutput {
+		list = list[:validCut(list, maxMutationOutput)] + "\n... (mutants truncated)"
+	}
+	count := fmt.Sprintf("%d mutant(s)", len(m.survivors))
+	if m.total > 0 {
+		count = fmt.Sprintf("%d of %d mutants", len(m.survivors), m.total)
+	}
+	return fmt.Sprintf("The tests pass, but mutation testing (%s) found that %s of your changed code survive: each change below was made to the code and every test still passed. Strengthen the tests so that each of these changes makes a test fail, by asserting on the behavior the mutated code is responsible for. Change only tests, not the code under test. A mutant that cannot change observable behavior may be left alone; say so. Surviving mutants:\n%s", m.tester, count, list), true
+}
+
+// mutationStatus is the Mutation line of the run summary, or "" when nothing was run.
+func (a *AutonomousCodingAgent) mutationStatus() string {
+	m := a.mutation
+	if m == nil || m.tester == "" {
+		return ""
+	}
+	partial := ""
+	if m.timedOut {
+		partial = " (partial: budget used up)"
+	}
+	if n := len(m.survivors); n > 0 {
+		return fmt.Sprintf("   Mutation: ⚠️ %d surviving mutant(s) after %d round(s)%s\n", n, m.rounds, partial)
+	}
+	return fmt.Sprintf("   Mutation: ✅ no surviving mutants%s\n", partial)
+}
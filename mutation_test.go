@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestParseMutationReports(t *testing.T) {
+	goOut := `--- Original
++++ New
+@@ -3 +3 @@
+-	return a + b
++	return a - b
+FAIL "/tmp/go-mutesting-1/calc.go.0" with checksum 1a2b
+PASS "/tmp/go-mutesting-1/calc.go.1" with checksum 3c4d
+The mutation score is 0.500000 (1 passed, 1 failed, 0 duplicated, 0 skipped, total is 2)`
+	survivors, total := mutationTesters[0].parse(goOut)
+	if total != 2 || len(survivors) != 1 {
+		t.Fatalf("go-mutesting: %d survivors of %d, want 1 of 2: %q", len(survivors), total, survivors)
+	}
+	assertContains(t, survivors[0], "+\treturn a - b\n(survived: /tmp/go-mutesting-1/calc.go.0)")
+
+	pyOut := `To apply a mutant on disk:
+    mutmut apply <id>
+
+Killed 🎉 (1)
+
+---- calc.py (1) ----
+
+# mutant 1
+--- calc.py
++++ calc.py
+-    return a * b
++    return a / b
+
+Survived 🙁 (1)
+
+---- calc.py (1) ----
+
+# mutant 2
+--- calc.py
++++ calc.py
+-    return a + b
++    return a - b
+`
+	survivors, total = mutationTesters[1].parse(pyOut)
+	if total != 2 || len(survivors) != 1 {
+		t.Fatalf("mutmut: %d survivors of %d, want 1 of 2: %q", len(survivors), total, survivors)
+	}
+	assertContains(t, survivors[0], "# mutant 2\n--- calc.py\n+++ calc.py\n-    return a + b\n+    return a - b")
+}
+
+func TestMutationSurvivorsFedBack(t *testing.T) {
+	a, srv := newTestAgent(t, map[string]string{
+		configFileName: "test:\n  command: \"true\"\nmutation:\n  enabled: true\n  command: \"grep -q edge test_calc.py 2>/dev/null || { echo 'survived: a + b -> a - b in' {files}; exit 1; }\"\n",
+	},
+		mock.Call("create_file", map[string]any{"path": "calc.py", "content": "def add(a, b):\n    return a + b\n"}),
+		mock.Text("added add"),
+		mock.Call("create_file", map[string]any{"path": "test_calc.py", "content": "from calc import add\n\ndef test_edge():\n    assert add(2, 3) == 5\n"}),
+		mock.Text("strengthened the tests"),
+	)
+	if err := a.feedbackLoop("add an add function"); err != nil {
+		t.Fatal(err)
+	}
+	reqs := srv.Requests()
+	if len(reqs) != 4 {
+		t.Fatalf("got %d requests, want 4", len(reqs))
+	}
+	followUp := reqs[2].Messages[len(reqs[2].Messages)-1].Content
+	assertContains(t, followUp, "mutation testing (mutation.command)")
+	assertContains(t, followUp, "survived: a + b -> a - b in calc.py")
+	if strings.Contains(followUp, "test_calc.py") {
+		t.Errorf("test files were mutated:\n%s", followUp)
+	}
+	assertContains(t, a.runSummary(), "Mutation: ✅ no surviving mutants")
+}
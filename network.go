@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*──────────────────────────────
+  Network policy for shell commands
+  ─────────────────────────────*/
+
+// Network policies for the commands the agent runs. They are enforced by the sandbox:
+// deny gives the command a network namespace of its own with only loopback, and
+// allowlist additionally lets it reach zug's filtering proxy through a Unix socket.
+const (
+	netAllow     = "allow"
+	netDeny      = "deny"
+	netAllowlist = "allowlist"
+)
+
+// networkConfig is the `network:` section of zug.yaml. The short form `network: deny`
+// sets only the policy.
+type networkConfig struct {
+	Policy string   `yaml:"policy"` // allow, deny or allowlist (default: deny in the sandbox, allow without)
+	Allow  []string `yaml:"allow"`  // allowlist: host, *.domain or host:port entries
+}
+
+func (c *networkConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&c.Policy)
+	}
+	type plain networkConfig
+	return node.Decode((*plain)(c))
+}
+
+func (c networkConfig) validate() error {
+	switch c.Policy {
+	case "", netAllow, netDeny:
+		return nil
+	case netAllowlist:
+		if len(c.Allow) == 0 {
+			return fmt.Errorf("network policy %s needs at least one host in network.allow", netAllowlist)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown network policy %q (use %s, %s or %s)", c.Policy, netAllow, netDeny, netAllowlist)
+}
+
+// allows reports whether hostport ("host:port" or a bare host) matches the allowlist.
+func (c networkConfig) allows(hostport string) bool {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host, port = hostport, ""
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, entry := range c.Allow {
+		pattern, wantPort, err := net.SplitHostPort(entry)
+		if err != nil {
+			pattern, wantPort = entry, ""
+		}
+		if wantPort != "" && wantPort != port {
+			continue
+		}
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// networkPolicy is the policy in effect for shell commands.
+func (a *AutonomousCodingAgent) networkPolicy() string {
+	if p := a.cfg.Network.Policy; p != "" {
+		return p
+	}
+	if a.sandbox == sandboxNS {
+		return netDeny
+	}
+	return netAllow
+}
+
+/*──────────────────────────────
+  Allowlist proxy
+  ─────────────────────────────*/
+
+// netProxy is an HTTP proxy on a Unix socket that only forwards to allowlisted hosts.
+// Inside the sandbox, the network bridge (zug __netbridge) exposes it on loopback and
+// points the proxy environment variables at it.
+type netProxy struct {
+	dir    string // temporary directory holding the socket, bound into the sandbox
+	socket string
+	cfg    networkConfig
+	ln     net.Listener
+}
+
+// allowlistProxy starts the proxy the first time a sandboxed command needs it.
+func (a *AutonomousCodingAgent) allowlistProxy() (*netProxy, error) {
+	if a.netProxy != nil {
+		return a.netProxy, nil
+	}
+	dir, err := os.MkdirTemp("", "zug-net-")
+	if err != nil {
+		return nil, fmt.Errorf("cannot start the network proxy: %w", err)
+	}
+	p := &netProxy{dir: dir, socket: filepath.Join(dir, "proxy.sock"), cfg: a.cfg.Network}
+	ln, err := net.Listen("unix", p.socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("cannot start the network proxy: %w", err)
+	}
+	p.ln = ln
+	go http.Serve(ln, p)
+	log.Printf("[agent] 🌐 Network allowlist proxy: %s\n", strings.Join(p.cfg.Allow, ", "))
+	a.netProxy = p
+	return p, nil
+}
+
+// closeNetProxy stops the allowlist proxy, if one was started during the run.
+func (a *AutonomousCodingAgent) closeNetProxy() {
+	if p := a.netProxy; p != nil {
+		p.ln.Close()
+		os.RemoveAll(p.dir)
+		a.netProxy = nil
+	}
+}
+
+func (p *netProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.Host
+	if r.Method != http.MethodConnect && r.URL.Host != "" {
+		target = r.URL.Host
+	}
+	if !p.cfg.allows(target) {
+		log.Printf("[agent] 🚫 Blocked network access to %s (not in network.allow).\n", target)
+		http.Error(w, fmt.Sprintf("zug network policy: %s is not in network.allow of zug.yaml", target), http.StatusForbidden)
+		return
+	}
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, target)
+		return
+	}
+	if r.URL.Host == "" {
+		http.Error(w, "zug network proxy: only proxy requests are accepted", http.StatusBadRequest)
+		return
+	}
+	r.RequestURI = ""
+	r.Header.Del("Proxy-Connection")
+	r.Header.Del("Proxy-Authorization")
+	resp, err := proxyTransport.RoundTrip(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// proxyTransport forwards plain HTTP requests directly, not through the host's proxy.
+var proxyTransport = &http.Transport{DialContext: (&net.Dialer{Timeout: 30 * time.Second}).DialContext}
+
+// tunnel handles CONNECT, which HTTPS clients use through a proxy.
+func (p *netProxy) tunnel(w http.ResponseWriter, target string) {
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, "443")
+	}
+	upstream, err := net.DialTimeout("tcp", target, 30*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "zug network proxy: cannot tunnel", http.StatusInternalServerError)
+		return
+	}
+	client, buf, err := hj.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	if n := buf.Reader.Buffered(); n > 0 {
+		peek, _ := buf.Reader.Peek(n)
+		upstream.Write(peek)
+	}
+	pipeConns(client, upstream)
+}
+
+// pipeConns copies between a and b until either side is done, then closes both.
+func pipeConns(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(a, b); done <- struct{}{} }()
+	go func() { io.Copy(b, a); done <- struct{}{} }()
+	<-done
+	a.Close()
+	b.Close()
+}
+
+/*──────────────────────────────
+  Network bridge (inside the sandbox)
+  ─────────────────────────────*/
+
+const netBridgeCommand = "__netbridge" // internal: zug __netbridge <socket> <command>
+
+// runNetBridge runs inside the sandbox's network namespace: it listens on loopback,
+// forwards every connection to the proxy socket, and runs the command with the proxy
+// environment variables pointing at it.
+func runNetBridge(args []string) {
+	if len(args) != 2 {
+		log.Fatalf("usage: %s %s <socket> <command>", os.Args[0], netBridgeCommand)
+	}
+	socket, command := args[0], args[1]
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatalf("network bridge: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				upstream, err := net.Dial("unix", socket)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				pipeConns(conn, upstream)
+			}()
+		}
+	}()
+
+	proxy := "http://" + ln.Addr().String()
+	c := exec.Command("bash", "-c", command)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	c.Env = os.Environ()
+	for _, k := range []string{"HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY", "http_proxy", "https_proxy", "all_proxy"} {
+		c.Env = append(c.Env, k+"="+proxy)
+	}
+	c.Env = append(c.Env, "NO_PROXY=", "no_proxy=")
+	err = c.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		os.Exit(exitErr.ExitCode())
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "network bridge: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestNetworkConfig(t *testing.T) {
+	var short struct {
+		Network networkConfig `yaml:"network"`
+	}
+	if err := yaml.Unmarshal([]byte("network: deny\n"), &short); err != nil || short.Network.Policy != netDeny {
+		t.Errorf("short form = %+v, %v", short.Network, err)
+	}
+	if err := (networkConfig{Policy: netAllowlist}).validate(); err == nil {
+		t.Error("an allowlist without hosts is valid")
+	}
+
+	c := networkConfig{Policy: netAllowlist, Allow: []string{"proxy.golang.org", "*.npmjs.org", "github.com:443"}}
+	for hostport, want := range map[string]bool{
+		"proxy.golang.org:443":  true,
+		"PROXY.golang.org":      true,
+		"registry.npmjs.org:80": true,
+		"npmjs.org:443":         false,
+		"github.com:443":        true,
+		"github.com:22":         false,
+		"evil.example:443":      false,
+		"golang.org.evil:443":   false,
+	} {
+		if got := c.allows(hostport); got != want {
+			t.Errorf("allows(%q) = %v, want %v", hostport, got, want)
+		}
+	}
+}
+
+func TestAllowlistProxy(t *testing.T) {
+	hello := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "hello") })
+	upstream := httptest.NewServer(hello)
+	defer upstream.Close()
+	tlsUpstream := httptest.NewTLSServer(hello)
+	defer tlsUpstream.Close()
+
+	a := &AutonomousCodingAgent{cfg: &config{Network: networkConfig{Policy: netAllowlist, Allow: []string{upstream.Listener.Addr().String(), tlsUpstream.Listener.Addr().String()}}}}
+	p, err := a.allowlistProxy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.closeNetProxy()
+
+	// A client in the sandbox reaches the proxy through the bridge; here it dials the socket.
+	client := &http.Client{Transport: &http.Transport{
+		Proxy: http.ProxyURL(&url.URL{Scheme: "http", Host: "zug-proxy"}),
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", filepath.Join(p.dir, "proxy.sock"))
+		},
+		TLSClientConfig: tlsUpstream.Client().Transport.(*http.Transport).TLSClientConfig,
+	}}
+	for _, u := range []string{upstream.URL, tlsUpstream.URL} { // plain request and CONNECT
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+			t.Errorf("allowed host %s: %d %q", u, resp.StatusCode, body)
+		}
+	}
+
+	resp, err := client.Get("http://blocked.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("blocked host: status %d, want 403", resp.StatusCode)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  Notifications
+  ─────────────────────────────*/
+
+const notifyTimeout = 15 * time.Second
+
+// Notification events, also sent to webhooks as "event".
+const (
+	eventDone     = "done"
+	eventFailed   = "failed"
+	eventApproval = "approval" // a human has to look at something before work can go on
+)
+
+// notifyConfig names where notifications go. Every target is optional; the same section is
+// read from zug.yaml for local runs and from daemon.yaml for daemon tasks.
+type notifyConfig struct {
+	Desktop bool   `yaml:"desktop"` // notify-send on Linux, osascript on macOS
+	Webhook string `yaml:"webhook"` // receives a JSON POST {"event", "title", "message"}
+	Slack   string `yaml:"slack"`   // Slack incoming-webhook URL
+	Ntfy    string `yaml:"ntfy"`    // ntfy topic URL, e.g. https://ntfy.sh/my-zug-runs
+	Command string `yaml:"command"` // run with ZUG_NOTIFY_EVENT, ZUG_NOTIFY_TITLE and ZUG_NOTIFY_MESSAGE set
+}
+
+// notification is the payload delivered to every target.
+type notification struct {
+	Event   string `json:"event"`
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
+// enabled reports whether any target is configured.
+func (c notifyConfig) enabled() bool {
+	return c.Desktop || c.Webhook != "" || c.Slack != "" || c.Ntfy != "" || c.Command != ""
+}
+
+// send delivers n to every configured target. Failures are logged, never returned: a
+// broken notifier must not fail the work it reports on.
+func (c notifyConfig) send(n notification) {
+	if c.Desktop {
+		if err := desktopNotify(n); err != nil {
+			log.Printf("[notify] ⚠️ Desktop notification failed: %v\n", err)
+		}
+	}
+	if c.Webhook != "" {
+		if err := postWebhook(c.Webhook, n); err != nil {
+			log.Printf("[notify] ⚠️ Webhook failed: %v\n", err)
+		}
+	}
+	if c.Slack != "" {
+		text := fmt.Sprintf("%s *%s*\n%s", eventEmoji(n.Event), n.Title, n.Message)
+		if err := postWebhook(c.Slack, map[string]string{"text": text}); err != nil {
+			log.Printf("[notify] ⚠️ Slack failed: %v\n", err)
+		}
+	}
+	if c.Ntfy != "" {
+		if err := postNtfy(c.Ntfy, n); err != nil {
+			log.Printf("[notify] ⚠️ ntfy failed: %v\n", err)
+		}
+	}
+	if c.Command != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "bash", "-c", c.Command)
+		cmd.Env = append(os.Environ(), "ZUG_NOTIFY_EVENT="+n.Event, "ZUG_NOTIFY_TITLE="+n.Title, "ZUG_NOTIFY_MESSAGE="+n.Message)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			log.Printf("[notify] ⚠️ Command failed: %v: %s\n", err, bytes.TrimSpace(out))
+		}
+	}
+}
+
+func eventEmoji(event string) string {
+	switch event {
+	case eventDone:
+		return "✅"
+	case eventFailed:
+		return "❌"
+	case eventApproval:
+		return "✋"
+	}
+	return "🔔"
+}
+
+// desktopNotify shows n with the platform's notification tool.
+func desktopNotify(n notification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(n.Message), appleScriptString(n.Title))
+		cmd = exec.CommandContext(ctx, "osascript", "-e", script)
+	case "linux", "freebsd", "openbsd":
+		urgency := "normal"
+		if n.Event != eventDone {
+			urgency = "critical"
+		}
+		cmd = exec.CommandContext(ctx, "notify-send", "-a", "zug", "-u", urgency, n.Title, n.Message)
+	default:
+		return fmt.Errorf("no desktop notifier for %s; use a webhook or command target", runtime.GOOS)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", cmd.Path, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// appleScriptString quotes s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// postNtfy publishes n to an ntfy topic: the message is the body, the rest goes in headers.
+func postNtfy(url string, n notification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(n.Message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", n.Title)
+	switch n.Event {
+	case eventDone:
+		req.Header.Set("Tags", "white_check_mark")
+	case eventFailed:
+		req.Header.Set("Tags", "x")
+		req.Header.Set("Priority", "high")
+	case eventApproval:
+		req.Header.Set("Tags", "raised_hand")
+		req.Header.Set("Priority", "high")
+	}
+	if token := os.Getenv("ZUG_NTFY_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return doNotifyRequest(req)
+}
+
+func postWebhook(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doNotifyRequest(req)
+}
+
+func doNotifyRequest(req *http.Request) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", req.URL.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+/*──────────────────────────────
+  Run notifications
+  ─────────────────────────────*/
+
+// notify sends n to the targets in zug.yaml (plus the desktop with run -notify). Subtask
+// agents never notify; their parent reports for them.
+func (a *AutonomousCodingAgent) notify(event, title, message string) {
+	if a.child || !a.cfg.Notify.enabled() {
+		return
+	}
+	a.cfg.Notify.send(notification{Event: event, Title: title, Message: message})
+}
+
+// notifyRunEnd reports how a run ended: its outcome when there is one, else its error.
+func (a *AutonomousCodingAgent) notifyRunEnd(task string, runErr error) {
+	project := filepath.Base(a.projectDir)
+	if runErr != nil {
+		a.notify(eventFailed, fmt.Sprintf("zug: run in %s failed", project), fmt.Sprintf("%s\n%v", shortTask(task), runErr))
+		return
+	}
+	msg := fmt.Sprintf("%s\n%d file(s) changed", shortTask(task), len(a.changes))
+	if a.outcome != nil {
+		msg = fmt.Sprintf("%s\n%s: %s", shortTask(task), a.outcome.Status, a.outcome.Summary)
+	}
+	a.notify(eventDone, fmt.Sprintf("zug: run in %s finished", project), msg)
+}
+
+// shortTask is the first line of task, cut to fit a notification.
+func shortTask(task string) string {
+	task, _, _ = strings.Cut(strings.TrimSpace(task), "\n")
+	if len(task) > 120 {
+		task = task[:validCut(task, 120)] + "…"
+	}
+	return task
+}
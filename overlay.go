@@ -0,0 +1,588 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+/*──────────────────────────────
+  Copy-on-write workspace overlays
+  ─────────────────────────────*/
+
+const (
+	overlaysDirName  = "overlays" // in ~/.zug
+	overlayMetaFile  = "overlay.json"
+	overlayTreeDir   = "tree"
+	overlayReflink   = "reflink" // the copy shares blocks with the project until written
+	overlayFullCopy  = "copy"
+	overlayDiffLimit = 1 << 20 // bytes of a file diffed line by line; larger ones get a binary patch
+)
+
+// overlay is a copy of a project that a run works in instead of the project itself: its
+// delta against the project, recorded by the hashes in base, can be inspected, exported
+// as a patch, applied, or discarded as a whole.
+type overlay struct {
+	ID      string            `json:"id"`
+	Project string            `json:"project"`
+	Task    string            `json:"task"`
+	Method  string            `json:"method"`
+	Created time.Time         `json:"created"`
+	Base    map[string]string `json:"base"` // sha256 of every project file when the copy was made
+
+	dir string
+}
+
+// tree is the copy the agent works in.
+func (o *overlay) tree() string { return filepath.Join(o.dir, overlayTreeDir) }
+
+func overlaysDir() (string, error) {
+	dir, err := userZugDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, overlaysDirName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// createOverlay copies project into a new overlay, with reflinks where the file system
+// supports them (Btrfs, XFS, APFS), so even large projects are copied at once.
+func createOverlay(project, task string) (*overlay, error) {
+	project, err := filepath.Abs(project)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create project directory %s: %w", project, err)
+	}
+	root, err := overlaysDir()
+	if err != nil {
+		return nil, err
+	}
+	o := &overlay{ID: time.Now().Format("20060102-150405.000"), Project: project, Task: task, Created: time.Now()}
+	o.ID = strings.ReplaceAll(o.ID, ".", "-")
+	o.dir = filepath.Join(root, o.ID)
+	if err := os.Mkdir(o.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("cannot create overlay: %w", err)
+	}
+	if o.Base, err = hashTree(project); err != nil {
+		os.RemoveAll(o.dir)
+		return nil, err
+	}
+	if o.Method, err = cloneTree(project, o.tree()); err != nil {
+		os.RemoveAll(o.dir)
+		return nil, fmt.Errorf("cannot copy %s: %w", project, err)
+	}
+	if err := o.save(); err != nil {
+		os.RemoveAll(o.dir)
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *overlay) save() error {
+	raw, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(o.dir, overlayMetaFile), raw, 0o600)
+}
+
+func loadOverlay(id string) (*overlay, error) {
+	root, err := overlaysDir()
+	if err != nil {
+		return nil, err
+	}
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return nil, fmt.Errorf("invalid overlay ID %q", id)
+	}
+	o := &overlay{dir: filepath.Join(root, id)}
+	raw, err := os.ReadFile(filepath.Join(o.dir, overlayMetaFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no overlay %s (see zug overlay)", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, o); err != nil {
+		return nil, fmt.Errorf("overlay %s: %w", id, err)
+	}
+	return o, nil
+}
+
+func listOverlays() ([]*overlay, error) {
+	root, err := overlaysDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var out []*overlay
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if o, err := loadOverlay(e.Name()); err == nil {
+			out = append(out, o)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	return out, nil
+}
+
+// cloneTree copies src to dst with reflinks when cp supports them, and file by file
+// otherwise.
+func cloneTree(src, dst string) (string, error) {
+	var c *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		c = exec.Command("cp", "-a", "--reflink=always", src+"/.", dst)
+	case "darwin":
+		c = exec.Command("cp", "-Rpc", src+"/", dst)
+	}
+	if c != nil {
+		if err := c.Run(); err == nil {
+			return overlayReflink, nil
+		}
+		os.RemoveAll(dst)
+	}
+	return overlayFullCopy, copyTree(src, dst)
+}
+
+// copyFile copies the content of src to dst, created with perm.
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// overlaySkipped reports whether rel is left out of the delta: version control data and
+// zug's own state differ between the copy and the project without being changes.
+func overlaySkipped(rel string) bool {
+	top, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+	return top == ".git" || top == zugDirName
+}
+
+// hashTree returns the sha256 of every regular file under root, by slash-separated path.
+func hashTree(root string) (map[string]string, error) {
+	sums := map[string]string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		if rel != "." && overlaySkipped(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		sums[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", root, err)
+	}
+	return sums, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// delta lists the files the overlay created, modified or deleted since it was made,
+// sorted by path.
+func (o *overlay) delta() ([]pathChange, error) {
+	now, err := hashTree(o.tree())
+	if err != nil {
+		return nil, err
+	}
+	var out []pathChange
+	for p, sum := range now {
+		switch base, ok := o.Base[p]; {
+		case !ok:
+			out = append(out, pathChange{path: p, action: "created"})
+		case base != sum:
+			out = append(out, pathChange{path: p, action: "modified"})
+		}
+	}
+	for p := range o.Base {
+		if _, ok := now[p]; !ok {
+			out = append(out, pathChange{path: p, action: "deleted"})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].path < out[j].path })
+	for i := range out {
+		before, after, binary := o.contents(out[i].path)
+		if binary {
+			continue
+		}
+		for _, op := range diffLines(splitLines(before), splitLines(after)) {
+			switch op.kind {
+			case '+':
+				out[i].added++
+			case '-':
+				out[i].removed++
+			}
+		}
+	}
+	return out, nil
+}
+
+// contents returns a changed file in the project and in the overlay, "" where it does
+// not exist; binary reports files that are not diffed as text.
+func (o *overlay) contents(rel string) (before, after string, binary bool) {
+	read := func(root string) string {
+		raw, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			return ""
+		}
+		if len(raw) > overlayDiffLimit || bytes.IndexByte(raw, 0) >= 0 {
+			binary = true
+		}
+		return string(raw)
+	}
+	before, after = read(o.Project), read(o.tree())
+	return before, after, binary
+}
+
+// patch renders the delta as a unified diff that git apply and patch -p1 accept. Binary
+// files are written as git binary patches, which only git apply understands.
+func (o *overlay) patch(changes []pathChange) string {
+	var sb strings.Builder
+	for _, c := range changes {
+		from, to := "a/"+c.path, "b/"+c.path
+		switch c.action {
+		case "created":
+			from = "/dev/null"
+		case "deleted":
+			to = "/dev/null"
+		}
+		before, after, binary := o.contents(c.path)
+		if binary {
+			sb.WriteString(binaryDiff(c, before, after, o.mode(c.path)))
+			continue
+		}
+		sb.WriteString(unifiedDiff(from, to, before, after))
+	}
+	return sb.String()
+}
+
+// mode is the git file mode of a file in the overlay, or in the project when the overlay
+// deleted it.
+func (o *overlay) mode(rel string) string {
+	info, err := os.Stat(filepath.Join(o.tree(), filepath.FromSlash(rel)))
+	if err != nil {
+		info, err = os.Stat(filepath.Join(o.Project, filepath.FromSlash(rel)))
+	}
+	if err == nil && info.Mode()&0o111 != 0 {
+		return "100755"
+	}
+	return "100644"
+}
+
+// binaryDiff is the git binary patch of one file: both contents as zlib-compressed,
+// base85-encoded literals. git apply needs the full blob ids in the index line to apply it.
+func binaryDiff(c pathChange, before, after, mode string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "diff --git a/%s b/%s\n", c.path, c.path)
+	oldID, newID := gitBlobID(before), gitBlobID(after)
+	switch c.action {
+	case "created":
+		fmt.Fprintf(&sb, "new file mode %s\n", mode)
+		oldID = strings.Repeat("0", len(oldID))
+	case "deleted":
+		fmt.Fprintf(&sb, "deleted file mode %s\n", mode)
+		newID = strings.Repeat("0", len(newID))
+	}
+	fmt.Fprintf(&sb, "index %s..%s\nGIT binary patch\n", oldID, newID)
+	writeBinaryLiteral(&sb, after)
+	sb.WriteString("\n")
+	writeBinaryLiteral(&sb, before) // the reverse hunk, for git apply -R
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+func gitBlobID(content string) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	io.WriteString(h, content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+const base85Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz!#$%&()*+-;<=>?@^_`{|}~"
+
+// writeBinaryLiteral writes a "literal" hunk: the deflated content in lines of up to 52
+// bytes, each prefixed with its length (A-Z for 1-26, a-z for 27-52) and encoded in git's
+// base85.
+func writeBinaryLiteral(sb *strings.Builder, content string) {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	io.WriteString(zw, content)
+	zw.Close()
+	fmt.Fprintf(sb, "literal %d\n", len(content))
+	data := z.Bytes()
+	for len(data) > 0 {
+		n := min(len(data), 52)
+		if n <= 26 {
+			sb.WriteByte(byte('A' + n - 1))
+		} else {
+			sb.WriteByte(byte('a' + n - 27))
+		}
+		for i := 0; i < n; i += 4 {
+			var group uint32
+			for j := 0; j < 4; j++ {
+				group <<= 8
+				if i+j < n {
+					group |= uint32(data[i+j])
+				}
+			}
+			var enc [5]byte
+			for k := 4; k >= 0; k-- {
+				enc[k] = base85Alphabet[group%85]
+				group /= 85
+			}
+			sb.Write(enc[:])
+		}
+		sb.WriteByte('\n')
+		data = data[n:]
+	}
+}
+
+// conflicts lists the changed files that were also changed in the project since the
+// overlay was made; applying would overwrite those edits.
+func (o *overlay) conflicts(changes []pathChange) []string {
+	var out []string
+	for _, c := range changes {
+		sum, err := hashFile(filepath.Join(o.Project, filepath.FromSlash(c.path)))
+		base, existed := o.Base[c.path]
+		switch {
+		case err != nil && existed, err == nil && !existed, err == nil && sum != base:
+			out = append(out, c.path)
+		}
+	}
+	return out
+}
+
+// apply copies the delta into the project.
+func (o *overlay) apply(changes []pathChange) error {
+	for _, c := range changes {
+		src := filepath.Join(o.tree(), filepath.FromSlash(c.path))
+		dst := filepath.Join(o.Project, filepath.FromSlash(c.path))
+		if c.action == "deleted" {
+			if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		info, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if err := copyFile(src, dst, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("cannot write %s: %w", c.path, err)
+		}
+	}
+	return nil
+}
+
+// discard removes the overlay: it is renamed away first, so it disappears at once even
+// when deleting a large copy takes a while or is interrupted.
+func (o *overlay) discard() error {
+	trash := filepath.Join(filepath.Dir(o.dir), ".discarded-"+o.ID)
+	if err := os.Rename(o.dir, trash); err != nil {
+		return fmt.Errorf("cannot discard overlay %s: %w", o.ID, err)
+	}
+	return os.RemoveAll(trash)
+}
+
+// overlayHint tells the user what to do with the overlay a run left behind.
+func overlayHint(o *overlay) string {
+	changes, err := o.delta()
+	if err != nil {
+		return fmt.Sprintf("Could not read overlay %s: %v", o.ID, err)
+	}
+	if len(changes) == 0 {
+		o.discard()
+		return fmt.Sprintf("The run changed nothing; overlay %s was discarded.", o.ID)
+	}
+	return fmt.Sprintf("%d file(s) changed in overlay %s, the project is untouched. Inspect with zug overlay %s, export with zug overlay -export out.patch %s, "+
+		"apply with zug overlay -apply %s, or throw away with zug overlay -discard %s.", len(changes), o.ID, o.ID, o.ID, o.ID, o.ID)
+}
+
+/*──────────────────────────────
+  zug overlay
+  ─────────────────────────────*/
+
+func overlayCommand(fs *flag.FlagSet) func() {
+	diff := fs.Bool("diff", false, "print the overlay's changes as a unified diff")
+	export := fs.String("export", "", "write the overlay's changes as a patch to this file")
+	apply := fs.Bool("apply", false, "copy the overlay's changes into the project, then remove the overlay")
+	force := fs.Bool("force", false, "with -apply: overwrite files that were also changed in the project since the overlay was made")
+	discard := fs.Bool("discard", false, "remove the overlay and all its changes")
+	return func() {
+		id := arg(fs.Args(), 0)
+		if id == "" {
+			listOverlaysCommand()
+			return
+		}
+		o, err := loadOverlay(id)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if *discard {
+			if err := o.discard(); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			fmt.Printf("Discarded overlay %s.\n", o.ID)
+			return
+		}
+		changes, err := o.delta()
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		switch {
+		case *export != "":
+			if err := os.WriteFile(*export, []byte(o.patch(changes)), 0o644); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			fmt.Printf("Wrote %d changed file(s) to %s; apply with git apply %s.\n", len(changes), *export, *export)
+		case *diff:
+			fmt.Print(o.patch(changes))
+		case *apply:
+			if conflicts := o.conflicts(changes); len(conflicts) > 0 && !*force {
+				log.Fatalf("❌ These files were also changed in %s since the overlay was made: %s. Export a patch and merge by hand, or use -force to overwrite them.",
+					o.Project, strings.Join(conflicts, ", "))
+			}
+			if err := o.apply(changes); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			if err := o.discard(); err != nil {
+				log.Printf("⚠️ %v\n", err)
+			}
+			fmt.Printf("Applied %d changed file(s) to %s.\n", len(changes), o.Project)
+		default:
+			fmt.Printf("Overlay %s of %s (%s, created %s)\nTask: %s\nFiles: %s\n\n", o.ID, o.Project, o.Method, o.Created.Format(time.RFC3339), firstLine(o.Task), o.tree())
+			if len(changes) == 0 {
+				fmt.Println("No changes.")
+				return
+			}
+			for _, c := range changes {
+				fmt.Printf("  %-8s %s (+%d −%d)\n", c.action, c.path, c.added, c.removed)
+			}
+		}
+	}
+}
+
+func listOverlaysCommand() {
+	list, err := listOverlays()
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if len(list) == 0 {
+		fmt.Println("No overlays. Start a run in one with zug run --overlay.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tMETHOD\tPROJECT\tTASK")
+	for _, o := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", o.ID, o.Created.Format("2006-01-02 15:04"), o.Method, o.Project, shortTask(o.Task))
+	}
+	w.Flush()
+}
+
+/*──────────────────────────────
+  Patch-only output (zug run --emit-patch)
+  ─────────────────────────────*/
+
+const emitPatchTestLines = 40 // lines of the last test run quoted in the patch header
+
+// writeRunPatch writes the changes of a run in overlay o to path as a unified diff. A
+// header of "# " lines, which git apply and patch skip, gives the task, the outcome and
+// the end of the last test run as evidence for the reviewer.
+func (a *AutonomousCodingAgent) writeRunPatch(o *overlay, path, task string, runErr error) (int, error) {
+	changes, err := o.delta()
+	if err != nil {
+		return 0, err
+	}
+	var head strings.Builder
+	fmt.Fprintf(&head, "Task: %s\nProject: %s\nCreated by zug run --emit-patch on %s; the project was not changed.\n", firstLine(task), o.Project, time.Now().Format(time.RFC3339))
+	if runErr != nil {
+		fmt.Fprintf(&head, "Outcome: incomplete (%v)\n", runErr)
+	} else {
+		head.WriteString("Outcome: done\n")
+	}
+	switch {
+	case a.status.testsRan:
+		result := "pass"
+		if !a.status.testsPassed {
+			result = "FAIL"
+		}
+		fmt.Fprintf(&head, "Tests: %s. End of the last test run:\n\n%s\n", result, lastLines(a.status.testOutput, emitPatchTestLines))
+	case a.status.buildPassed:
+		head.WriteString("Tests: none ran; the build passes.\n")
+	default:
+		head.WriteString("Tests: not run.\n")
+	}
+	var sb strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(head.String(), "\n"), "\n") {
+		sb.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+	}
+	sb.WriteString("\n")
+	sb.WriteString(o.patch(changes))
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		return 0, fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	return len(changes), nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestOverlayCapturesChangesUntilApplied(t *testing.T) {
+	t.Setenv("ZUG_HOME", t.TempDir())
+	project := t.TempDir()
+	writeTestFile(t, project, "main.go", "package main\n")
+	writeTestFile(t, project, "old.txt", "old\n")
+	writeTestFile(t, project, "notes.txt", "mine\n")
+
+	o, err := createOverlay(project, "change things")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, o.tree(), "main.go", "package main\n\nfunc main() {}\n")
+	writeTestFile(t, o.tree(), "pkg/new.go", "package pkg\n")
+	writeTestFile(t, o.tree(), ".zug/state.db", "ignored")
+	if err := os.Remove(filepath.Join(o.tree(), "old.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, project, "main.go"); got != "package main\n" {
+		t.Fatalf("the project changed: %q", got)
+	}
+
+	o, err = loadOverlay(o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := o.delta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.action+" "+c.path)
+	}
+	if want := []string{"modified main.go", "deleted old.txt", "created pkg/new.go"}; len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("delta = %v, want %v", got, want)
+	}
+	patch := o.patch(changes)
+	for _, want := range []string{"--- a/main.go\n+++ b/main.go\n", "+func main() {}", "--- a/old.txt\n+++ /dev/null\n", "--- /dev/null\n+++ b/pkg/new.go\n"} {
+		assertContains(t, patch, want)
+	}
+
+	writeTestFile(t, project, "main.go", "package main // edited meanwhile\n")
+	if c := o.conflicts(changes); len(c) != 1 || c[0] != "main.go" {
+		t.Errorf("conflicts = %v, want main.go", c)
+	}
+	if err := o.apply(changes); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, readTestFile(t, project, "main.go"), "func main() {}")
+	assertContains(t, readTestFile(t, project, "pkg/new.go"), "package pkg")
+	if fileExists(filepath.Join(project, "old.txt")) {
+		t.Error("old.txt was not deleted")
+	}
+
+	if err := o.discard(); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := listOverlays(); len(list) != 0 {
+		t.Errorf("%d overlays left after discard", len(list))
+	}
+}
+
+func TestEmitPatchHasTestEvidence(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{"calc.py": "def add(a, b):\n    return a - b\n"})
+	o, err := createOverlay(a.projectDir, "fix add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, o.tree(), "calc.py", "def add(a, b):\n    return a + b\n")
+	a.status.testsRan, a.status.testsPassed, a.status.testOutput = true, true, "collected 1 item\n--- 1 passed\n"
+
+	out := filepath.Join(t.TempDir(), "fix.patch")
+	n, err := a.writeRunPatch(o, out, "fix add", nil)
+	if err != nil || n != 1 {
+		t.Fatalf("writeRunPatch = %d, %v", n, err)
+	}
+	patch := readTestFile(t, filepath.Dir(out), filepath.Base(out))
+	for _, want := range []string{"# Task: fix add\n", "# Outcome: done\n", "# Tests: pass.", "# --- 1 passed\n", "--- a/calc.py\n+++ b/calc.py\n", "+    return a + b\n"} {
+		assertContains(t, patch, want)
+	}
+	assertContains(t, readTestFile(t, a.projectDir, "calc.py"), "a - b")
+}
+
+func TestOverlayPatchAppliesBinaryChanges(t *testing.T) {
+	t.Setenv("ZUG_HOME", t.TempDir())
+	project := t.TempDir()
+	image := make([]byte, 300)
+	for i := range image {
+		image[i] = byte(i * 7)
+	}
+	writeTestFile(t, project, "logo.png", string(image))
+	writeTestFile(t, project, "old.bin", "\x00\x01gone")
+	writeTestFile(t, project, "README.md", "# app\n")
+
+	o, err := createOverlay(project, "update assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	image[10], image[200] = 0, 0xff
+	writeTestFile(t, o.tree(), "logo.png", string(image))
+	writeTestFile(t, o.tree(), "tool.bin", "\x7fELF\x00\x00")
+	os.Chmod(filepath.Join(o.tree(), "tool.bin"), 0o755)
+	os.Remove(filepath.Join(o.tree(), "old.bin"))
+	writeTestFile(t, o.tree(), "README.md", "# app\n\nWith a logo.\n")
+
+	changes, err := o.delta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch := filepath.Join(t.TempDir(), "assets.patch")
+	if err := os.WriteFile(patch, []byte(o.patch(changes)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "apply", patch)
+	cmd.Dir = project
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git apply: %v\n%s\n%s", err, out, readTestFile(t, filepath.Dir(patch), "assets.patch"))
+	}
+	for _, name := range []string{"logo.png", "tool.bin", "README.md"} {
+		if got, want := readTestFile(t, project, name), readTestFile(t, o.tree(), name); got != want {
+			t.Errorf("%s after git apply differs from the overlay", name)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(project, "tool.bin")); err != nil || info.Mode()&0o111 == 0 {
+		t.Errorf("tool.bin is not executable: %v", err)
+	}
+	if fileExists(filepath.Join(project, "old.bin")) {
+		t.Error("old.bin was not deleted")
+	}
+}
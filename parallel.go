@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*──────────────────────────────
+  Shared pacing of model requests
+  ─────────────────────────────*/
+
+const rateLimitPause = 10 * time.Second // every agent holds back this long after a 429
+
+// requestGate paces the model requests of all the agents of the process, so tasks run
+// side by side do not trip the provider's rate limits: at most a given number of requests
+// are in flight, and after a 429 none are sent for a while.
+type requestGate struct {
+	mu    sync.Mutex
+	slots chan struct{} // nil: no limit, and no pause after a 429
+	until time.Time
+}
+
+var apiGate requestGate
+
+// limit allows n requests in flight at once; n <= 0 lifts the limit.
+func (g *requestGate) limit(n int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.slots = nil
+	if n > 0 {
+		g.slots = make(chan struct{}, n)
+	}
+}
+
+// acquire waits for a free slot and the end of any pause, and returns the function that
+// frees the slot again.
+func (g *requestGate) acquire(ctx context.Context) (release func(), err error) {
+	g.mu.Lock()
+	slots := g.slots
+	g.mu.Unlock()
+	if slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release = func() { <-slots }
+	for {
+		g.mu.Lock()
+		wait := time.Until(g.until)
+		g.mu.Unlock()
+		if wait <= 0 {
+			return release, nil
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// pause holds back every request for d, when requests are limited.
+func (g *requestGate) pause(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.slots == nil {
+		return
+	}
+	if until := time.Now().Add(d); until.After(g.until) {
+		g.until = until
+	}
+}
+
+/*──────────────────────────────
+  zug run --parallel
+  ─────────────────────────────*/
+
+// parallelOptions are the flags of zug run that apply to every task of the file.
+type parallelOptions struct {
+	jobs        int           // tasks run at once
+	maxRequests int           // model requests in flight at once, over all tasks
+	timeout     time.Duration // per task; 0 for none
+	report      string
+}
+
+// runParallel runs the tasks of a batch file at the same time, each in a fresh git
+// worktree of its repository and on a branch of its own, and writes the merged report.
+// It returns the exit code: 0 when every task succeeded.
+func runParallel(cf commonFlags, path string, opts parallelOptions) int {
+	bf, err := loadBatchFile(path)
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	jobs := opts.jobs
+	if jobs <= 0 || jobs > len(bf.Tasks) {
+		jobs = len(bf.Tasks)
+	}
+	maxRequests := opts.maxRequests
+	if maxRequests <= 0 {
+		maxRequests = jobs
+	}
+	apiGate.limit(maxRequests)
+	defer apiGate.limit(0)
+	log.Printf("[parallel] ▶️ Running %d task(s), %d at a time, with at most %d model request(s) in flight.\n", len(bf.Tasks), jobs, maxRequests)
+
+	stamp := time.Now().Format("20060102-150405")
+	results := make([]batchResult, len(bf.Tasks))
+	sem := make(chan struct{}, jobs)
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		stopped bool
+	)
+	for i, t := range bf.Tasks {
+		wg.Add(1)
+		go func(i int, t batchTask) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			mu.Lock()
+			skip := stopped || interruptRequested()
+			mu.Unlock()
+			if skip {
+				results[i] = batchResult{task: t, skipped: true}
+				return
+			}
+			log.Printf("[parallel] ▶️ Task %s started (dir %s)\n", t.Name, t.Dir)
+			r := runParallelTask(cf, t, stamp, opts.timeout)
+			log.Printf("[parallel] ⏹️ Task %s finished in %s (%d file(s) changed, error: %v)\n", t.Name, r.duration.Round(time.Second), len(r.files), r.err)
+			results[i] = r
+			if r.err != nil && t.OnFailure == onFailureStop {
+				log.Printf("[parallel] Not starting further tasks: on_failure of %s is 'stop'.\n", t.Name)
+				mu.Lock()
+				stopped = true
+				mu.Unlock()
+			}
+		}(i, t)
+	}
+	wg.Wait()
+	killChildProcesses()
+
+	out := batchReport("zug parallel report", path, results)
+	fmt.Println(out)
+	if err := os.WriteFile(opts.report, []byte(out), 0o644); err != nil {
+		log.Fatalf("FATAL: Could not write report to %s: %v", opts.report, err)
+	}
+	log.Printf("[parallel] Report written to %s; check out a task's branch to keep its result.\n", opts.report)
+	for _, r := range results {
+		if r.err != nil || r.skipped {
+			return exitFailed
+		}
+	}
+	return exitVerified
+}
+
+// runParallelTask runs one entry in a fresh worktree of the repository holding its dir,
+// and commits the result on its branch, which must not exist yet. The default branch,
+// zug/parallel-<stamp>-<name>, is deleted again when the task changed nothing.
+func runParallelTask(cf commonFlags, t batchTask, stamp string, timeout time.Duration) (res batchResult) {
+	res.task = t
+	start := time.Now()
+	defer func() { res.duration = time.Since(start) }()
+
+	top, err := gitCmd(t.Dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		res.err = fmt.Errorf("each parallel task runs in a git worktree, so %s must be a git repository: %w", t.Dir, err)
+		return res
+	}
+	resolved, _ := filepath.EvalSymlinks(t.Dir)
+	sub, _ := filepath.Rel(top, resolved)
+	generated := t.Branch == ""
+	if generated {
+		t.Branch = fmt.Sprintf("zug/parallel-%s-%s", stamp, strings.Trim(unsafeBranchChars.ReplaceAllString(t.Name, "-"), "-"))
+	}
+	res.task.Branch = t.Branch
+
+	wt, err := os.MkdirTemp("", "zug-parallel-*")
+	if err != nil {
+		res.err = fmt.Errorf("cannot create worktree dir: %w", err)
+		return res
+	}
+	if _, err := gitCmd(top, "worktree", "add", "-b", t.Branch, wt, "HEAD"); err != nil {
+		os.RemoveAll(wt)
+		res.err, res.task.Branch = err, ""
+		return res
+	}
+	defer func() {
+		if _, err := gitCmd(top, "worktree", "remove", "--force", wt); err != nil {
+			log.Printf("[parallel] Warning: could not remove worktree %s: %v\n", wt, err)
+		}
+		if len(res.files) == 0 && generated {
+			gitCmd(top, "branch", "-D", t.Branch)
+			res.task.Branch = ""
+		}
+	}()
+
+	cf.dir = filepath.Join(wt, sub)
+	if t.Model != "" {
+		cf.model = t.Model
+	}
+	agent := cf.newAgent("")
+	agent.review = t.Review
+	if timeout > 0 {
+		cancel := agent.setTimeout(timeout)
+		defer cancel()
+	}
+	res.err = agent.feedbackLoop(t.Task)
+	res.summary = strings.TrimSpace(agent.lastReply)
+	for _, c := range agent.netChanges() {
+		res.files = append(res.files, c.path)
+	}
+
+	if len(res.files) > 0 {
+		if _, err := gitCmd(wt, "add", "-A"); err == nil {
+			msg := agent.autoCommitMessage(wt, t.Task, "zug: "+t.Name)
+			if _, err := gitCmd(wt, "-c", "user.name=zug", "-c", "user.email=zug@localhost", "commit", "-q", "-m", msg); err != nil {
+				log.Printf("[parallel] Warning: could not commit %s on %s: %v\n", t.Name, t.Branch, err)
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestGateLimitsRequestsInFlight(t *testing.T) {
+	var g requestGate
+	g.limit(2)
+	var inFlight, most atomic.Int32
+	done := make(chan struct{})
+	for i := 0; i < 6; i++ {
+		go func() {
+			release, err := g.acquire(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			n := inFlight.Add(1)
+			for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+			}
+			time.Sleep(20 * time.Millisecond)
+			inFlight.Add(-1)
+			release()
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 6; i++ {
+		<-done
+	}
+	if most.Load() != 2 {
+		t.Errorf("%d requests in flight at most, want 2", most.Load())
+	}
+}
+
+func TestRequestGatePausesAfterRateLimit(t *testing.T) {
+	var g requestGate
+	g.limit(1)
+	g.pause(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := g.acquire(ctx); err == nil {
+		t.Fatal("request sent during the pause")
+	}
+	// The slot given up by the cancelled request is free again.
+	g.until = time.Time{}
+	release, err := g.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	var unlimited requestGate
+	unlimited.pause(time.Hour)
+	if _, err := unlimited.acquire(ctx); err != nil {
+		t.Errorf("a single run was paused: %v", err)
+	}
+}
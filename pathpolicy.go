@@ -0,0 +1,99 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*──────────────────────────────
+  Sensitive-path policy
+  ─────────────────────────────*/
+
+// pathPolicyConfig is the `sensitive_paths:` section of zug.yaml. Allow wins over deny,
+// so a project can re-enable one of the defaults (e.g. allow: ["*.key"]).
+type pathPolicyConfig struct {
+	Deny  []string `yaml:"deny"`  // added to the defaults
+	Allow []string `yaml:"allow"` // exceptions to both lists
+}
+
+var (
+	defaultSensitivePaths = []string{
+		".env", ".env.*", "*.pem", "*.key", "*.p12", "*.pfx", "id_rsa*", "id_ed25519*", "id_ecdsa*",
+		".netrc", ".npmrc", ".pypirc", ".git/config", "**/secrets/**", ".aws/**", ".ssh/**",
+	}
+	defaultAllowedPaths = []string{".env.example", ".env.sample", ".env.template", ".env.dist"}
+)
+
+// sensitivePath reports whether the model may not touch rel and which pattern blocked it.
+func (a *AutonomousCodingAgent) sensitivePath(rel string) (string, bool) {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	for _, lists := range [][]string{defaultAllowedPaths, a.cfg.SensitivePaths.Allow} {
+		for _, p := range lists {
+			if globMatch(p, rel) {
+				return "", false
+			}
+		}
+	}
+	for _, lists := range [][]string{defaultSensitivePaths, a.cfg.SensitivePaths.Deny} {
+		for _, p := range lists {
+			if globMatch(p, rel) {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+// globMatch matches a slash-separated relative path against a gitignore-style pattern:
+// `*` and `?` stay within one path segment, `**` spans directories, a pattern without a
+// slash matches a name at any depth, and a pattern matching a directory covers its contents.
+func globMatch(pattern, rel string) bool {
+	re := globRegexp(pattern)
+	if re == nil {
+		return false
+	}
+	for p := rel; p != "." && p != "/" && p != ""; p = filepath.ToSlash(filepath.Dir(p)) {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func globRegexp(pattern string) *regexp.Regexp {
+	pattern = strings.TrimSuffix(strings.TrimSpace(pattern), "/")
+	if pattern == "" {
+		return nil
+	}
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	var sb strings.Builder
+	if anchored {
+		sb.WriteString("^")
+	} else {
+		sb.WriteString("(?:^|/)")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil
+	}
+	return re
+}
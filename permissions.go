@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-isatty"
+)
+
+/*──────────────────────────────
+  Per-tool permissions
+  ─────────────────────────────*/
+
+// Permission levels of a tool, set per tool under `permissions:` in zug.yaml.
+const (
+	permAuto = "auto" // the agent calls the tool freely (default)
+	permAsk  = "ask"  // every call needs a yes on the terminal
+	permDeny = "deny" // the tool is not offered and calls are refused
+)
+
+// permissionsConfig maps tool names to a permission level; "*" sets the default for the
+// tools not listed.
+type permissionsConfig map[string]string
+
+func (p permissionsConfig) validate() error {
+	for tool, level := range p {
+		switch level {
+		case permAuto, permAsk, permDeny:
+		default:
+			return fmt.Errorf("unknown permission %q for tool %s (use %s, %s or %s)", level, tool, permAuto, permAsk, permDeny)
+		}
+	}
+	return nil
+}
+
+// permission returns the level that applies to tool.
+func (a *AutonomousCodingAgent) permission(tool string) string {
+	if level, ok := a.cfg.Permissions[tool]; ok {
+		return level
+	}
+	if level, ok := a.cfg.Permissions["*"]; ok {
+		return level
+	}
+	return permAuto
+}
+
+// warnUnknown logs permissions for tools that do not exist, which are most likely typos.
+func (p permissionsConfig) warnUnknown() {
+	known := map[string]bool{"*": true}
+	for _, t := range allToolDefs() {
+		known[t.Function.Name] = true
+	}
+	var unknown []string
+	for tool := range p {
+		if !known[tool] {
+			unknown = append(unknown, tool)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		log.Printf("[agent] ⚠️ permissions in %s name unknown tools, which are ignored: %s\n", configFileName, strings.Join(unknown, ", "))
+	}
+}
+
+// permissionVerdict is what the model receives when a call is refused, as JSON so it can
+// tell a policy decision apart from a failing tool.
+type permissionVerdict struct {
+	Policy     string `json:"policy"`
+	Tool       string `json:"tool"`
+	Permission string `json:"permission"`
+	Decision   string `json:"decision"`
+	Reason     string `json:"reason"`
+	Hint       string `json:"hint"`
+}
+
+func (v permissionVerdict) error() error {
+	raw, _ := json.Marshal(v)
+	return fmt.Errorf("%w: %s", errPolicyViolation, raw)
+}
+
+// checkPermission enforces the tool's permission before a call runs. It returns the
+// decision to record in the audit log (nil when none was needed) and an error wrapping
+// errPolicyViolation when the call must not run.
+func (a *AutonomousCodingAgent) checkPermission(callID, tool, jsonArgs string) (*approval, error) {
+	level := a.permission(tool)
+	verdict := permissionVerdict{Policy: "tool_permissions", Tool: tool, Permission: level}
+	decision := &approval{callID: callID, tool: tool, decision: approvalGranted}
+	switch {
+	case level == permDeny:
+		verdict.Decision = "denied"
+		verdict.Reason = fmt.Sprintf("the project's %s does not allow %s", configFileName, tool)
+		verdict.Hint = "Do not call this tool again and do not work around it with other tools; reach the goal another way or explain what is blocked."
+		log.Printf("[agent] 🚫 %s is denied by the permissions in %s.\n", tool, configFileName)
+		decision.decision, decision.decidedBy, decision.reason = approvalDenied, decidedByPolicy, verdict.Reason
+		return decision, verdict.error()
+	case level == permAsk && a.approvedTools[tool]:
+		decision.decidedBy = decidedByAlways
+	case a.autoApprove && (level == permAsk || !readOnlyTools[tool]):
+		decision.decidedBy = decidedByYes
+	case level == permAsk:
+		// With --stream-confirm the user may have answered while the call was streaming.
+		early, asked := a.takeEarlyAnswer(callID)
+		ok, by, reason := early.ok, early.decidedBy, early.note
+		if !asked {
+			ok, by, reason = a.askApproval(tool, jsonArgs)
+		}
+		decision.decidedBy, decision.reason = by, reason
+		if !ok {
+			verdict.Decision = "rejected"
+			verdict.Reason = reason
+			verdict.Hint = "The user did not approve this call. Take the reason into account and try a different approach."
+			decision.decision = approvalRejected
+			return decision, verdict.error()
+		}
+	default:
+		return nil, nil
+	}
+	return decision, nil
+}
+
+var approvalMu sync.Mutex // one question on the terminal at a time, also across child agents
+
+// askApproval shows the call on the terminal and waits for the user's answer: yes, no,
+// always (for this tool until the run ends) or any other text, which rejects the call and
+// is passed to the model as the reason. It also returns who decided, for the audit log.
+func (a *AutonomousCodingAgent) askApproval(tool, jsonArgs string) (ok bool, decidedBy, reason string) {
+	approvalMu.Lock()
+	defer approvalMu.Unlock()
+	if !stdinIsTerminal() {
+		log.Printf("[agent] 🚫 %s needs approval, but there is no terminal to ask on; run with --yes to approve automatically.\n", tool)
+		return false, decidedByNoTerminal, fmt.Sprintf("%s needs interactive approval (permission %q in %s), but zug is not running on a terminal", tool, permAsk, configFileName)
+	}
+	a.notify(eventApproval, fmt.Sprintf("zug: approval needed in %s", filepath.Base(a.projectDir)), fmt.Sprintf("The agent wants to call %s.", tool))
+	fmt.Printf("\n❓ The agent wants to call %s:\n%s\n", tool, describeCall(tool, jsonArgs))
+	fmt.Print("Allow? [y]es / [n]o / [a]lways for this tool, or type a reason to reject: ")
+	line, ok := terminalInputs().readLine()
+	answer := strings.TrimSpace(line)
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, decidedByUser, ""
+	case "a", "always":
+		if a.approvedTools == nil {
+			a.approvedTools = map[string]bool{}
+		}
+		a.approvedTools[tool] = true
+		return true, decidedByUser, "allowed for the rest of the run"
+	case "", "n", "no":
+		if !ok {
+			return false, decidedByNoTerminal, "no answer on the terminal"
+		}
+		return false, decidedByUser, "the user rejected the call"
+	}
+	return false, decidedByUser, "the user rejected the call: " + answer
+}
+
+// describeCall renders a tool call for the approval prompt: the command of run_shell,
+// otherwise the arguments with long values shortened.
+func describeCall(tool, jsonArgs string) string {
+	var args map[string]any
+	if err := json.Unmarshal([]byte(jsonArgs), &args); err != nil {
+		return "  " + jsonArgs
+	}
+	if cmd, ok := args["command"].(string); ok && tool == "run_shell" {
+		return "  $ " + cmd
+	}
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		v := fmt.Sprint(args[k])
+		if s, ok := args[k].(string); ok {
+			v = s
+			if lines := strings.Count(s, "\n"); len(s) > 300 {
+				v = fmt.Sprintf("%s… (%d bytes, %d lines)", s[:validCut(s, 300)], len(s), lines+1)
+			}
+		}
+		fmt.Fprintf(&sb, "  %s: %s\n", k, strings.ReplaceAll(v, "\n", "\n    "))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// stdinIsTerminal reports whether someone can answer questions on standard input.
+func stdinIsTerminal() bool {
+	return isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd())
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Crash-safe run progress
+  ─────────────────────────────*/
+
+// A run saves its conversation after every model reply and tool call, not only when it
+// ends, together with where the feedback loop stands. A run killed by a panic, the OOM
+// killer or a power cut keeps status running, and zug resume continues it from the last
+// saved step. SQLite commits in WAL mode are durable, so a saved step survives too.
+
+const crashedCallNote = "This tool call was cut off when zug stopped unexpectedly, before it returned. It may have taken effect partly or fully: check the state it changes (e.g. read the file, list the directory) before repeating it."
+
+// runProgress is where a run stands, saved in the resume column of its runs row.
+type runProgress struct {
+	Turn        int    `json:"turn"`                // feedback loop turn, from 1
+	Instruction string `json:"instruction"`         // what the turn asked of the model
+	InFlight    string `json:"in_flight,omitempty"` // ID of the tool call being executed
+	PID         int    `json:"pid"`
+	Host        string `json:"host"`
+}
+
+// savedChange is a fileChange in the run_changes column.
+type savedChange struct {
+	Existed bool   `json:"existed"`
+	Before  string `json:"before"`
+}
+
+// saveProgress stores the conversation and progress of the current run. inFlight is
+// the tool call about to be executed, if any. The files' original contents are only
+// written again when the agent touched a new file.
+func (a *AutonomousCodingAgent) saveProgress(inFlight string) {
+	if a.runID == 0 || a.state == nil {
+		return
+	}
+	a.progress.InFlight = inFlight
+	p := a.progress
+	p.PID = os.Getpid()
+	p.Host, _ = os.Hostname()
+	messages, err := json.Marshal(a.ctx)
+	if err != nil {
+		a.warnProgress(err)
+		return
+	}
+	progress, err := json.Marshal(p)
+	if err != nil {
+		a.warnProgress(err)
+		return
+	}
+	if len(a.changes) == a.savedChanges {
+		_, err = a.state.db.Exec(`UPDATE runs SET messages = ?, resume = ? WHERE id = ?`, string(messages), string(progress), a.runID)
+		a.warnProgress(err)
+		return
+	}
+	changes := map[string]savedChange{}
+	for path, c := range a.changes {
+		changes[path] = savedChange{c.existed, c.before}
+	}
+	raw, err := json.Marshal(changes)
+	if err != nil {
+		a.warnProgress(err)
+		return
+	}
+	_, err = a.state.db.Exec(`UPDATE runs SET messages = ?, resume = ?, run_changes = ? WHERE id = ?`, string(messages), string(progress), string(raw), a.runID)
+	if err == nil {
+		a.savedChanges = len(a.changes)
+	}
+	a.warnProgress(err)
+}
+
+// warnProgress reports the first failure to save the run's progress; the run goes on.
+func (a *AutonomousCodingAgent) warnProgress(err error) {
+	if err != nil && !a.progressWarned {
+		a.progressWarned = true
+		fmt.Printf("⚠️ The run's progress cannot be saved, so it cannot be resumed after a crash: %v\n", err)
+	}
+}
+
+// crashed saves the progress of a run that is panicking, says how to resume it, and
+// panics on.
+func (a *AutonomousCodingAgent) crashed(p interface{}) {
+	a.saveProgress(a.progress.InFlight)
+	if a.runID != 0 && !a.child {
+		log.Printf("[agent] 💥 zug crashed: %v\nResume the run with: zug resume --dir %s %d\n", p, a.projectDir, a.runID)
+	}
+	panic(p)
+}
+
+// resumeRun loads run id, or the latest run that stopped unexpectedly when id is 0, so
+// feedbackLoop continues it where it stopped. It returns the run's task and model.
+func (a *AutonomousCodingAgent) resumeRun(id int64) (task, model string, err error) {
+	st, err := a.stateDB()
+	if err != nil {
+		return "", "", err
+	}
+	if id == 0 {
+		err = st.db.QueryRow(`SELECT id FROM runs WHERE status = ? AND resume != '' ORDER BY id DESC LIMIT 1`, runRunning).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", fmt.Errorf("no run of %s stopped unexpectedly (see zug history)", a.projectDir)
+		} else if err != nil {
+			return "", "", err
+		}
+	}
+	var status, messages, progress, changes string
+	err = st.db.QueryRow(`SELECT task, model, status, messages, resume, run_changes FROM runs WHERE id = ?`, id).
+		Scan(&task, &model, &status, &messages, &progress, &changes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", fmt.Errorf("no run #%d (see zug history)", id)
+	} else if err != nil {
+		return "", "", err
+	}
+	if status != runRunning || progress == "" {
+		return "", "", fmt.Errorf("run #%d ended (%s) and cannot be resumed; continue its conversation with zug run --session %d", id, status, id)
+	}
+	var p runProgress
+	var msgs []openai.ChatCompletionMessage
+	saved := map[string]savedChange{}
+	if err := json.Unmarshal([]byte(progress), &p); err != nil {
+		return "", "", fmt.Errorf("run #%d has unreadable progress: %w", id, err)
+	}
+	if err := json.Unmarshal([]byte(messages), &msgs); err != nil {
+		return "", "", fmt.Errorf("run #%d has an unreadable conversation: %w", id, err)
+	}
+	if changes != "" {
+		if err := json.Unmarshal([]byte(changes), &saved); err != nil {
+			return "", "", fmt.Errorf("run #%d has unreadable file changes: %w", id, err)
+		}
+	}
+	if host, _ := os.Hostname(); host == p.Host && p.PID != os.Getpid() && processAlive(p.PID) {
+		return "", "", fmt.Errorf("run #%d is still going (process %d); stop it first", id, p.PID)
+	}
+
+	a.ctx, a.runID, a.resumeFrom = msgs, id, &p
+	for path, c := range saved {
+		a.changes[path] = &fileChange{existed: c.Existed, before: c.Before}
+	}
+	a.savedChanges = len(a.changes)
+	log.Printf("[agent] ⏯️ Resuming run #%d at turn %d with %d saved message(s) and %d changed file(s).\n", id, p.Turn, len(msgs), len(saved))
+	return task, model, nil
+}
+
+// finishPendingCalls completes the turn a resumed run stopped in: the tool calls of the
+// last reply that have no result yet are executed, except the one that was running when
+// the run stopped, which gets crashedCallNote instead. done is set, with the reply, when
+// the turn had already ended with a reply.
+func (a *AutonomousCodingAgent) finishPendingCalls(inFlight string) (reply string, done bool, err error) {
+	last := len(a.ctx) - 1
+	answered := map[string]bool{}
+	for ; last >= 0 && a.ctx[last].Role == openai.ChatMessageRoleTool; last-- {
+		answered[a.ctx[last].ToolCallID] = true
+	}
+	if last < 0 || a.ctx[last].Role != openai.ChatMessageRoleAssistant {
+		return "", false, nil
+	}
+	if msg := a.ctx[last]; len(msg.ToolCalls) == 0 {
+		return msg.Content, msg.Content != "", nil
+	}
+	for _, call := range a.ctx[last].ToolCalls {
+		if answered[call.ID] {
+			continue
+		}
+		result := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleTool, ToolCallID: call.ID, Name: call.Function.Name, Content: crashedCallNote}
+		if call.ID != inFlight {
+			if result, err = a.answerToolCall(call); err != nil {
+				return "", false, err
+			}
+		} else {
+			log.Printf("[agent] 💥 Tool call %s(%s) was cut off by the crash; the model is told to check its effects.\n", call.Function.Name, call.Function.Arguments)
+		}
+		a.ctx = append(a.ctx, result)
+		a.saveProgress("")
+	}
+	return "", false, nil
+}
+
+/*──────────────────────────────
+  zug resume
+  ─────────────────────────────*/
+
+func resumeCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	return func() {
+		var id int64
+		if s := strings.TrimPrefix(arg(fs.Args(), 0), "#"); s != "" {
+			var err error
+			if id, err = strconv.ParseInt(s, 10, 64); err != nil {
+				fs.Usage()
+				os.Exit(1)
+			}
+		}
+		agent := cf.newAgent("")
+		task, model, err := agent.resumeRun(id)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if cf.model == "" && os.Getenv("OPENAI_MODEL") == "" {
+			agent.model = model
+			log.Printf("[agent] Using the run's model: %s\n", model)
+		}
+		agent.enableSteering()
+		trapInterrupts()
+		err = agent.feedbackLoop(task)
+		if errors.Is(err, errInterrupted) || errors.Is(err, errTimeout) {
+			killChildProcesses()
+		} else if err != nil {
+			log.Printf("[agent] ❌ Task did not complete: %v\n", err)
+		}
+		agent.notifyRunEnd(task, err)
+		os.Exit(agent.exitCode(err))
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"zug/provider/mock"
+)
+
+func TestResumeAfterCrashMidTurn(t *testing.T) {
+	a, srv := newTestAgent(t, nil, mock.Text("All three files are there."), mock.Text(verifyApproved))
+	task := "create a.txt, b.txt and c.txt"
+
+	// The run gets as far as executing the second of three tool calls, then dies.
+	a.beginRun(task)
+	a.progress = runProgress{Turn: 1, Instruction: task}
+	a.ctx = []openai.ChatCompletionMessage{userMessage(task, nil)}
+	reply := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant}
+	for _, name := range []string{"a", "b", "c"} {
+		reply.ToolCalls = append(reply.ToolCalls, openai.ToolCall{ID: "call-" + name, Type: openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: "create_file", Arguments: fmt.Sprintf(`{"path": %q, "content": %q}`, name+".txt", name)}})
+	}
+	a.ctx = append(a.ctx, reply)
+	a.saveProgress("")
+	result, err := a.answerToolCall(reply.ToolCalls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.ctx = append(a.ctx, result)
+	a.saveProgress("")
+	a.saveProgress("call-b")
+
+	b := NewAgent(a.keys, a.projectDir, "mock-model")
+	resumed, _, err := b.resumeRun(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed != task || b.runID != a.runID || b.changes["a.txt"] == nil || b.changes["a.txt"].existed {
+		t.Fatalf("resumed task %q, run %d, changes %v", resumed, b.runID, b.changes)
+	}
+	if err := b.feedbackLoop(resumed); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the call that never started is executed; the cut-off one is left to the model.
+	if _, err := os.Stat(filepath.Join(a.projectDir, "b.txt")); err == nil {
+		t.Error("the call cut off by the crash was executed again")
+	}
+	readTestFile(t, a.projectDir, "c.txt")
+	msgs := srv.Requests()[0].Messages
+	if n := len(msgs); n != 6 || msgs[n-2].Content != crashedCallNote || !strings.Contains(msgs[n-1].Content, "c.txt") {
+		t.Errorf("resumed request = %+v", msgs)
+	}
+	if _, _, err := b.resumeRun(b.runID); err == nil || !strings.Contains(err.Error(), "cannot be resumed") {
+		t.Errorf("resuming a finished run: err = %v", err)
+	}
+}
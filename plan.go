@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Architect / plan-only mode
+  ─────────────────────────────*/
+
+// planPrompt replaces the coding system prompt while planning; only read-only tools are offered.
+func planPrompt() openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: `You are AutonomousArchitect, a senior software engineer planning a change. You can only read the project: use 'list_files' and 'read_file' to explore it, file paths are relative to the project root. Do not attempt to modify anything. When you understand the code well enough, reply with a detailed implementation plan in Markdown with these sections: "## Summary", "## Files to change" (one bullet per file with what changes and why), "## Steps" (numbered, in order), "## Risks", and "## Test strategy". Be specific about function names and file paths.`,
+	}
+}
+
+// planTask turns an approved plan into the instruction handed to the coding agent.
+func planTask(extra, plan string) string {
+	task := "Implement the following approved plan step by step. Do not deviate from it unless a step turns out to be impossible; if so, explain why.\n\n" + plan
+	if extra != "" {
+		task += "\n\nAdditional instructions:\n" + extra
+	}
+	return task
+}
+
+/*──────────────────────────────
+  zug plan
+  ─────────────────────────────*/
+
+func planCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	out := fs.String("o", "plan.md", "file to write the plan to")
+	return func() {
+		task := strings.TrimSpace(arg(fs.Args(), 0))
+		if task == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+
+		agent := cf.newAgent(arg(fs.Args(), 1))
+		agent.readOnly = true
+		agent.prompt = planPrompt()
+		agent.consultKnowledge(task)
+		structured := capabilitiesFor(agent.model).structuredOutputs
+		if structured {
+			format, err := jsonSchemaFormat("implementation_plan", structuredPlan{})
+			if err != nil {
+				log.Fatalf("FATAL: %v", err)
+			}
+			agent.responseFormat = format
+			agent.prompt.Content += " Your final reply is the plan as JSON matching the response schema; the sections map to its fields."
+		}
+
+		log.Printf("[agent] 🗺️ Planning task (read-only): %s\n", task)
+		plan, err := agent.chat(fmt.Sprintf("Task:\n%s\n\nExplore the project and write the implementation plan.", task), phasePlan)
+		if err != nil {
+			agent.notify(eventFailed, "zug: planning failed", fmt.Sprintf("%s\n%v", shortTask(task), err))
+			log.Fatalf("❌ Planning failed: %v", err)
+		}
+		if structured {
+			var sp structuredPlan
+			if err := json.Unmarshal([]byte(plan), &sp); err != nil {
+				log.Printf("[agent] ⚠️ Plan is not valid JSON (%v); keeping it as text.\n", err)
+			} else {
+				plan = sp.markdown()
+			}
+		}
+
+		content := fmt.Sprintf("# Plan\n\n**Task:** %s\n\n%s\n", task, strings.TrimSpace(plan))
+		if err := os.WriteFile(*out, []byte(content), 0o644); err != nil {
+			log.Fatalf("FATAL: Could not write plan to %s: %v", *out, err)
+		}
+		fmt.Printf("🗺️ Implementation Plan:\n%s\n", content)
+		fmt.Printf("Plan written to %s. Review it, then run: %s run --dir %s --plan %s\n", *out, os.Args[0], cf.dir, *out)
+		agent.notify(eventApproval, "zug: plan ready for review", fmt.Sprintf("%s\nWritten to %s; approve it with run --plan.", shortTask(task), *out))
+	}
+}
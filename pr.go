@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Pull request description and changelog
+  ─────────────────────────────*/
+
+const (
+	changelogFileName = "CHANGELOG.md"
+	prDiffLimit       = 30000 // bytes of the run's diff shown to the model
+	prTestLimit       = 4000  // bytes of the last test output shown to the model
+)
+
+// prDescription is what the model reports about a finished run for reviewers.
+type prDescription struct {
+	Title     string         `json:"title" description:"Pull request title, imperative, at most 72 characters"`
+	Problem   string         `json:"problem" description:"What was wrong or missing and why it matters"`
+	Approach  string         `json:"approach" description:"How the change solves it and the decisions worth a reviewer's attention"`
+	Testing   string         `json:"testing" description:"How the change was verified, citing the commands run and their results from the conversation; say so when it was not verified"`
+	Changelog changelogEntry `json:"changelog"`
+}
+
+type changelogEntry struct {
+	Section string `json:"section" enum:"Added,Changed,Deprecated,Removed,Fixed,Security" description:"Keep a Changelog section"`
+	Entry   string `json:"entry" description:"One line for users of the project, not for its developers"`
+}
+
+// markdown renders the pull request body.
+func (d prDescription) markdown(files []pathChange) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## Problem\n\n%s\n\n## Approach\n\n%s\n\n## Testing\n\n%s\n", d.Problem, d.Approach, d.Testing)
+	if len(files) > 0 {
+		sb.WriteString("\n## Files\n\n")
+		for _, f := range files {
+			fmt.Fprintf(&sb, "- `%s` (%s, +%d −%d)\n", f.path, f.action, f.added, f.removed)
+		}
+	}
+	return sb.String()
+}
+
+// writePRDescription writes the pull request description requested with --pr-body and
+// the entry requested with --changelog. Failures are logged; the run's result stands.
+func (a *AutonomousCodingAgent) writePRDescription(task string) {
+	if a.prBody == "" && !a.changelog {
+		return
+	}
+	if len(a.changes) == 0 {
+		log.Println("[agent] No file changes, so no pull request description or changelog entry.")
+		return
+	}
+	d, err := a.describeChanges(task)
+	if err != nil {
+		log.Printf("[agent] ⚠️ Could not describe the changes: %v\n", err)
+		return
+	}
+	if a.prBody != "" {
+		body := fmt.Sprintf("# %s\n\n%s", d.Title, d.markdown(a.netChanges()))
+		if err := os.WriteFile(a.prBody, []byte(body), 0o644); err != nil {
+			log.Printf("[agent] ⚠️ Could not write the pull request description: %v\n", err)
+		} else {
+			log.Printf("[agent] 📝 Pull request description written to %s.\n", a.prBody)
+		}
+	}
+	if a.changelog {
+		if err := a.addChangelogEntry(d.Changelog); err != nil {
+			log.Printf("[agent] ⚠️ Could not update %s: %v\n", changelogFileName, err)
+		} else {
+			log.Printf("[agent] 📝 Added to %s under %s: %s\n", changelogFileName, d.Changelog.Section, d.Changelog.Entry)
+		}
+	}
+}
+
+// describeChanges asks the model for a pull request description of the run, based on the
+// conversation, the diff and the last test output.
+func (a *AutonomousCodingAgent) describeChanges(task string) (*prDescription, error) {
+	diff := a.changesDiff()
+	if len(diff) > prDiffLimit {
+		diff = diff[:validCut(diff, prDiffLimit)] + "\n[... diff truncated]"
+	}
+	evidence := "The tests were not run."
+	if a.status.testsRan {
+		out := a.status.testOutput
+		if len(out) > prTestLimit {
+			out = "[... earlier output truncated]\n" + out[len(out)-prTestLimit:]
+		}
+		evidence = fmt.Sprintf("Output of the last test run (passed: %t):\n%s", a.status.testsPassed, out)
+	} else if a.status.buildPassed {
+		evidence = "There are no tests; the build passed."
+	}
+	prompt := fmt.Sprintf(`The task is done. Describe the change for a pull request, based on the conversation above. Be factual: the testing section may only claim what the conversation and the test output below show.
+
+Original task:
+%s
+
+%s
+
+Diff of the run:
+%s`, task, evidence, diff)
+
+	messages := append([]openai.ChatCompletionMessage{a.systemMessage()}, a.ctx...)
+	messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: prompt})
+	structured := capabilitiesFor(a.model).structuredOutputs
+	if !structured {
+		messages[len(messages)-1].Content += `
+
+Reply with a JSON object only: {"title": "...", "problem": "...", "approach": "...", "testing": "...", "changelog": {"section": "Added|Changed|Deprecated|Removed|Fixed|Security", "entry": "..."}}`
+	}
+	req, err := a.chatRequest(messages, phaseSummary, nil)
+	if err != nil {
+		return nil, err
+	}
+	if structured {
+		if req.ResponseFormat, err = jsonSchemaFormat("pull_request", prDescription{}); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := a.createChatCompletion(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("empty Choices array")
+	}
+	var d prDescription
+	if err := json.Unmarshal([]byte(stripCodeFence(resp.Choices[0].Message.Content)), &d); err != nil {
+		return nil, fmt.Errorf("the description is not valid JSON: %w", err)
+	}
+	if d.Title == "" {
+		d.Title = firstLine(task)
+	}
+	return &d, nil
+}
+
+// stripCodeFence removes a Markdown code fence around s, which models without
+// structured outputs often add to JSON.
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s[strings.IndexByte(s+"\n", '\n'):], "\n")
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+	}
+	return strings.TrimSpace(s)
+}
+
+var unreleasedHeading = regexp.MustCompile(`(?i)^## \[?unreleased\]?`)
+
+// addChangelogEntry adds e under the Unreleased heading of CHANGELOG.md in the Keep a
+// Changelog layout, creating the file, the heading or the section as needed.
+func (a *AutonomousCodingAgent) addChangelogEntry(e changelogEntry) error {
+	entry := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(e.Entry), "- "))
+	if entry == "" {
+		return fmt.Errorf("the model wrote no changelog entry")
+	}
+	section := e.Section
+	if section == "" {
+		section = "Changed"
+	}
+	full := filepath.Join(a.projectDir, changelogFileName)
+	a.trackChange(changelogFileName, full)
+	raw, err := a.fs.ReadFile(full)
+	if err != nil {
+		raw = []byte("# Changelog\n\nAll notable changes to this project are documented in this file.\n")
+	}
+	return a.fs.WriteFile(full, []byte(insertChangelogEntry(string(raw), section, entry)), 0o644)
+}
+
+// insertChangelogEntry returns changelog with "- entry" appended to section under the
+// Unreleased heading.
+func insertChangelogEntry(changelog, section, entry string) string {
+	lines := strings.Split(strings.TrimRight(changelog, "\n"), "\n")
+	insert := func(at int, add ...string) {
+		lines = append(lines[:at], append(add, lines[at:]...)...)
+	}
+	bullet := "- " + entry
+
+	unreleased, end := -1, len(lines)
+	for i, l := range lines {
+		if unreleased < 0 && unreleasedHeading.MatchString(l) {
+			unreleased, end = i, len(lines)
+		} else if strings.HasPrefix(l, "## ") {
+			if unreleased >= 0 {
+				end = i
+				break
+			}
+			if end == len(lines) {
+				end = i // first release, where a new Unreleased heading goes
+			}
+		}
+	}
+	if unreleased < 0 {
+		block := []string{"## [Unreleased]", "", "### " + section, "", bullet, ""}
+		if end == len(lines) {
+			block = append([]string{""}, block[:len(block)-1]...)
+		}
+		insert(end, block...)
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	for i := unreleased + 1; i < end; i++ {
+		if strings.TrimSpace(lines[i]) != "### "+section {
+			continue
+		}
+		last := i
+		for j := i + 1; j < end && !strings.HasPrefix(lines[j], "#"); j++ {
+			if strings.TrimSpace(lines[j]) != "" {
+				last = j
+			}
+		}
+		if last == i {
+			insert(last+1, "", bullet)
+		} else {
+			insert(last+1, bullet)
+		}
+		return strings.Join(lines, "\n") + "\n"
+	}
+	block := []string{"", "### " + section, "", bullet}
+	if unreleased+1 == end && end < len(lines) {
+		block = append(block, "")
+	}
+	insert(unreleased+1, block...)
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestInsertChangelogEntry(t *testing.T) {
+	for _, tc := range []struct {
+		name, changelog, want string
+	}{
+		{
+			name:      "new unreleased heading above the first release",
+			changelog: "# Changelog\n\n## [1.0.0] - 2026-01-01\n\n### Added\n\n- First release\n",
+			want:      "# Changelog\n\n## [Unreleased]\n\n### Fixed\n\n- Fix the crash\n\n## [1.0.0] - 2026-01-01\n\n### Added\n\n- First release\n",
+		},
+		{
+			name:      "no releases yet",
+			changelog: "# Changelog\n",
+			want:      "# Changelog\n\n## [Unreleased]\n\n### Fixed\n\n- Fix the crash\n",
+		},
+		{
+			name:      "existing section",
+			changelog: "# Changelog\n\n## [Unreleased]\n\n### Fixed\n\n- Fix the leak\n\n### Added\n\n- Dark mode\n\n## [1.0.0]\n",
+			want:      "# Changelog\n\n## [Unreleased]\n\n### Fixed\n\n- Fix the leak\n- Fix the crash\n\n### Added\n\n- Dark mode\n\n## [1.0.0]\n",
+		},
+		{
+			name:      "new section under an existing heading",
+			changelog: "# Changelog\n\n## Unreleased\n\n### Added\n\n- Dark mode\n\n## [1.0.0]\n",
+			want:      "# Changelog\n\n## Unreleased\n\n### Fixed\n\n- Fix the crash\n\n### Added\n\n- Dark mode\n\n## [1.0.0]\n",
+		},
+		{
+			name:      "empty unreleased heading",
+			changelog: "# Changelog\n\n## [Unreleased]\n## [1.0.0]\n",
+			want:      "# Changelog\n\n## [Unreleased]\n\n### Fixed\n\n- Fix the crash\n\n## [1.0.0]\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := insertChangelogEntry(tc.changelog, "Fixed", "Fix the crash"); got != tc.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
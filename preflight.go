@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+/*──────────────────────────────
+  Pricing
+  ─────────────────────────────*/
+
+// modelPrice is the list price in USD per million tokens.
+type modelPrice struct {
+	input, output float64
+}
+
+// modelPrices is matched by longest prefix, so dated snapshots share their family's price.
+var modelPrices = map[string]modelPrice{
+	"gpt-4o":       {2.50, 10},
+	"gpt-4o-mini":  {0.15, 0.60},
+	"gpt-4.1":      {2, 8},
+	"gpt-4.1-mini": {0.40, 1.60},
+	"gpt-4.1-nano": {0.10, 0.40},
+	"gpt-4-turbo":  {10, 30},
+	"gpt-5":        {1.25, 10},
+	"gpt-5-mini":   {0.25, 2},
+	"gpt-5-nano":   {0.05, 0.40},
+	"o1":           {15, 60},
+	"o1-mini":      {1.10, 4.40},
+	"o3":           {2, 8},
+	"o3-mini":      {1.10, 4.40},
+	"o4-mini":      {1.10, 4.40},
+}
+
+// priceFor returns the price of model, or ok=false for models zug has no price for.
+func priceFor(model string) (price modelPrice, ok bool) {
+	best := ""
+	for prefix, p := range modelPrices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best, price, ok = prefix, p, true
+		}
+	}
+	return price, ok
+}
+
+// cost is the USD price of the given token counts.
+func (p modelPrice) cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.input + float64(completionTokens)*p.output) / 1e6
+}
+
+/*──────────────────────────────
+  Preflight estimate
+  ─────────────────────────────*/
+
+const (
+	defaultPreflightWarn = 20000 // prompt tokens of the first request
+	imageTokenEstimate   = 800   // a high-detail image is roughly 765-1105 tokens
+)
+
+// preflightConfig is the `preflight:` section of zug.yaml.
+type preflightConfig struct {
+	WarnTokens int `yaml:"warn_tokens"` // warn above this many prompt tokens (default 20000)
+	MaxTokens  int `yaml:"max_tokens"`  // refuse to start above this many (0 = no limit)
+}
+
+// estimateTokens approximates the token count of text at four characters per token,
+// which is close for English prose and code with OpenAI tokenizers.
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// preflight estimates the prompt size of the first request for task before anything is
+// sent, prints it, and refuses to start when it is over preflight.max_tokens.
+func (a *AutonomousCodingAgent) preflight(task string) error {
+	system := estimateTokens(a.systemMessage().Content)
+	rawTools, _ := json.Marshal(a.toolDefs())
+	tools := estimateTokens(string(rawTools))
+	history := 0
+	for _, m := range a.ctx {
+		history += messageTokens(m)
+	}
+	prompt := estimateTokens(task) + len(a.images)*imageTokenEstimate
+	total := system + tools + history + prompt
+
+	parts := fmt.Sprintf("system prompt %d, tools %d, task %d", system, tools, prompt)
+	if history > 0 {
+		parts += fmt.Sprintf(", earlier conversation %d", history)
+	}
+	msg := fmt.Sprintf("≈%d prompt tokens for the first request (%s)", total, parts)
+	if price, ok := priceFor(a.model); ok {
+		msg += fmt.Sprintf(", ≈$%.3f per model call with %s; a run usually makes 5-30 calls with a growing context", price.cost(total, 0), a.model)
+	}
+	log.Printf("[agent] 📐 Preflight: %s.\n", msg)
+
+	limit := a.cfg.Preflight.MaxTokens
+	if limit > 0 && total > limit {
+		return fmt.Errorf("%w: the first request would be ≈%d prompt tokens, over preflight.max_tokens (%d) in %s; shorten the task or raise the limit", errBudgetExceeded, total, limit, configFileName)
+	}
+	warn := a.cfg.Preflight.WarnTokens
+	if warn == 0 {
+		warn = defaultPreflightWarn
+	}
+	if total > warn {
+		fmt.Printf("⚠️ The first request alone is ≈%d prompt tokens (warning threshold %d); every model call resends it.\n", total, warn)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+/*──────────────────────────────
+  Process groups (Unix)
+  ─────────────────────────────*/
+
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup sends SIGTERM to the group led by pid and reports whether it still
+// had members.
+func killProcessGroup(pid int) bool {
+	return syscall.Kill(-pid, syscall.SIGTERM) == nil
+}
+
+// processAlive reports whether a process with this pid exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,32 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+/*──────────────────────────────
+  Process groups (Windows)
+  ─────────────────────────────*/
+
+// setProcessGroup is a no-op: Windows does not deliver Ctrl-C to child consoles the way
+// Unix terminals signal the foreground process group.
+func setProcessGroup(c *exec.Cmd) {}
+
+// killProcessGroup kills the process pid; its children are not tracked on Windows.
+func killProcessGroup(pid int) bool {
+	p, err := os.FindProcess(pid)
+	return err == nil && p.Kill() == nil
+}
+
+// processAlive reports whether a process with this pid exists; on Windows, finding a
+// process opens it, which fails once it has exited.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err == nil {
+		p.Release()
+	}
+	return err == nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*──────────────────────────────
+  Profiling (tool)
+  ─────────────────────────────*/
+
+var profileDir = zugDirName + "/profile" // relative to the project root
+
+const profileTopN = 20 // functions listed per table of a profile report
+
+// profileRequest is what the profile tool runs: a Go test package, or a command for
+// other languages.
+type profileRequest struct {
+	Command string `json:"command"` // Python: the program or tests to run
+	Package string `json:"package"` // Go: the package whose tests or benchmarks run
+	Run     string `json:"run"`     // Go: -run pattern
+	Bench   string `json:"bench"`   // Go: -bench pattern
+}
+
+// profile runs p under the language's CPU profiler and returns a report of the hot
+// functions, so optimizations start from measurements.
+func (a *AutonomousCodingAgent) profile(p profileRequest) (string, error) {
+	if _, err := a.zugDir(); err != nil {
+		return "", err
+	}
+	if err := a.fs.MkdirAll(filepath.Join(a.projectDir, profileDir), 0o755); err != nil {
+		return "", fmt.Errorf("cannot create %s: %w", profileDir, err)
+	}
+	_, err := a.fs.Stat(filepath.Join(a.projectDir, "go.mod"))
+	switch {
+	case p.Command == "" && err == nil:
+		return a.profileGo(p)
+	case p.Command == "":
+		return "", fmt.Errorf("'command' is required outside Go modules, e.g. \"python bench.py\" or \"pytest tests/test_parser.py\"")
+	case pythonCommand.MatchString(p.Command):
+		return a.profilePython(p.Command)
+	}
+	return "", fmt.Errorf("cannot profile %q: supported are Go tests and benchmarks (leave 'command' empty) and Python commands (python, pytest)", p.Command)
+}
+
+func (a *AutonomousCodingAgent) profileGo(p profileRequest) (string, error) {
+	pkg := p.Package
+	if pkg == "" {
+		pkg = "."
+	}
+	out := profileDir + "/cpu.out"
+	cmd := fmt.Sprintf("go test -count=1 -o %s -cpuprofile %s", shellQuote(profileDir+"/pkg.test"), shellQuote(out))
+	if p.Run != "" {
+		cmd += " -run " + shellQuote(p.Run)
+	}
+	if p.Bench != "" {
+		cmd += " -run " + shellQuote("^$") + " -bench " + shellQuote(p.Bench)
+		if p.Run != "" {
+			return "", fmt.Errorf("set either 'run' or 'bench', not both")
+		}
+	}
+	cmd += " " + shellQuote(pkg)
+	log.Printf("[agent] ⏱️ Profiling: %s\n", cmd)
+	testOut, err := a.execShell(cmd)
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w\n%s", cmd, err, testOut)
+	}
+	flat, err := a.execShell(fmt.Sprintf("go tool pprof -top -nodecount=%d %s", profileTopN, shellQuote(out)))
+	if err != nil {
+		return "", fmt.Errorf("go tool pprof failed: %w\n%s", err, flat)
+	}
+	cum, _ := a.execShell(fmt.Sprintf("go tool pprof -top -cum -nodecount=%d %s", profileTopN, shellQuote(out)))
+	return fmt.Sprintf("$ %s\n%s\n\nHottest functions by own time (flat):\n%s\n\nBy time including callees (cum):\n%s\n\nProfile: %s (inspect further with run_shell: go tool pprof -list <func> %s)",
+		cmd, lastLines(testOut, 15), pprofTable(flat), pprofTable(cum), out, out), nil
+}
+
+// pprofTable drops the preamble of `go tool pprof -top` output before the table.
+func pprofTable(out string) string {
+	if i := strings.Index(out, "Showing nodes"); i >= 0 {
+		return out[i:]
+	}
+	return out
+}
+
+var pythonCommand = regexp.MustCompile(`^(python3?|pytest)(\s|$)`)
+
+// profilePython samples command with py-spy, or runs it under cProfile when py-spy is
+// not installed.
+func (a *AutonomousCodingAgent) profilePython(command string) (string, error) {
+	if a.installed("py-spy") {
+		out := profileDir + "/py-spy.txt"
+		cmd := fmt.Sprintf("py-spy record --format raw --rate 250 -o %s -- %s", shellQuote(out), command)
+		log.Printf("[agent] ⏱️ Profiling: %s\n", cmd)
+		runOut, err := a.execShell(cmd)
+		if err != nil {
+			return "", fmt.Errorf("%s failed: %w\n%s", cmd, err, runOut)
+		}
+		raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, out))
+		if err != nil {
+			return "", fmt.Errorf("py-spy wrote no profile: %w\n%s", err, runOut)
+		}
+		return fmt.Sprintf("$ %s\n%s\n\n%s\nProfile (folded stacks): %s", cmd, lastLines(runOut, 15), summarizeFolded(string(raw), profileTopN), out), nil
+	}
+
+	// cProfile: "python x.py" -> "python -m cProfile -s tottime x.py", "pytest ..." -> "python -m cProfile -s tottime -m pytest ...".
+	fields := strings.Fields(command)
+	python, rest := fields[0], strings.Join(fields[1:], " ")
+	if python == "pytest" {
+		python, rest = "python", "-m pytest "+rest
+	}
+	cmd := fmt.Sprintf("%s -m cProfile -s tottime %s", python, rest)
+	log.Printf("[agent] ⏱️ Profiling (py-spy is not installed): %s\n", cmd)
+	out, err := a.execShell(cmd)
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w\n%s", cmd, err, lastLines(out, 40))
+	}
+	i := strings.Index(out, "Ordered by:")
+	if i < 0 {
+		return fmt.Sprintf("$ %s\n%s", cmd, lastLines(out, 60)), nil
+	}
+	table := strings.Split(out[i:], "\n")
+	if len(table) > profileTopN+4 {
+		table = table[:profileTopN+4]
+	}
+	return fmt.Sprintf("$ %s\n%s\n\nHottest functions by own time (tottime), from cProfile:\n%s", cmd, lastLines(out[:i], 15), strings.Join(table, "\n")), nil
+}
+
+// summarizeFolded reports the functions with the most samples in folded stacks ("a;b;c
+// 42" per line): by own samples (the frame is on top of the stack), by samples
+// including callees, and the heaviest stack.
+func summarizeFolded(folded string, n int) string {
+	self, total := map[string]int{}, map[string]int{}
+	all, heaviest, heaviestCount := 0, "", 0
+	for _, line := range strings.Split(folded, "\n") {
+		sp := strings.LastIndexByte(line, ' ')
+		if sp < 0 {
+			continue
+		}
+		count, err := strconv.Atoi(line[sp+1:])
+		if err != nil {
+			continue
+		}
+		frames := strings.Split(line[:sp], ";")
+		all += count
+		self[frames[len(frames)-1]] += count
+		seen := map[string]bool{}
+		for _, f := range frames {
+			if !seen[f] {
+				seen[f] = true
+				total[f] += count
+			}
+		}
+		if count > heaviestCount {
+			heaviest, heaviestCount = line[:sp], count
+		}
+	}
+	if all == 0 {
+		return "The profile has no samples (the program may have finished too quickly; profile a longer run)."
+	}
+	table := func(title string, m map[string]int) string {
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if m[names[i]] != m[names[j]] {
+				return m[names[i]] > m[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		if len(names) > n {
+			names = names[:n]
+		}
+		var sb strings.Builder
+		sb.WriteString(title + ":\n")
+		for _, name := range names {
+			fmt.Fprintf(&sb, "  %5.1f%%  %s\n", 100*float64(m[name])/float64(all), name)
+		}
+		return sb.String()
+	}
+	return fmt.Sprintf("%d samples.\n\n%s\n%s\nHeaviest stack (%.1f%%):\n  %s\n", all,
+		table("Hottest functions by own samples", self),
+		table("By samples including callees", total),
+		100*float64(heaviestCount)/float64(all), strings.ReplaceAll(heaviest, ";", "\n  → "))
+}
+
+// lastLines returns the last n lines of s.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = append([]string{"[...]"}, lines[len(lines)-n:]...)
+	}
+	return strings.Join(lines, "\n")
+}
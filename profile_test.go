@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSummarizeFolded(t *testing.T) {
+	report := summarizeFolded("main;run;parse 60\nmain;run;render 30\nmain;parse 10\n", 2)
+	for _, want := range []string{
+		"100 samples.",
+		"Hottest functions by own samples:\n   70.0%  parse\n   30.0%  render\n",
+		"By samples including callees:\n  100.0%  main\n   90.0%  run\n",
+		"Heaviest stack (60.0%):\n  main\n  → run\n  → parse",
+	} {
+		assertContains(t, report, want)
+	}
+}
+
+func TestProfileGoTests(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test")
+	}
+	a, _ := newTestAgent(t, map[string]string{
+		"go.mod": "module prof\n\ngo 1.21\n",
+		"fib_test.go": `package prof
+
+import (
+	"testing"
+	"time"
+)
+
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+
+// Long enough for the profiler to take plenty of samples on a busy machine.
+func TestFib(t *testing.T) {
+	for start := time.Now(); time.Since(start) < 500*time.Millisecond; {
+		if fib(30) == 0 {
+			t.Fatal("fib")
+		}
+	}
+}
+`,
+	})
+	report, err := a.profile(profileRequest{Run: "TestFib"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, report, "Hottest functions by own time (flat):\nShowing nodes")
+	if !strings.Contains(report, "prof.fib") {
+		t.Errorf("the report does not name the hot function:\n%s", report)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Progress report (unfinished runs)
+  ─────────────────────────────*/
+
+const (
+	progressFileName  = "ZUG_PROGRESS.md"
+	progressTestLines = 30 // lines of failing test output quoted in a synthesized report
+)
+
+// writeProgressReport saves what an unsuccessful run got done, what remains and how to
+// go on as ZUG_PROGRESS.md in the project, so the work can be picked up by hand or by
+// another run. The model writes the report when it can still be asked; otherwise it is
+// put together from what zug saw. Failures are logged; the run's result stands.
+func (a *AutonomousCodingAgent) writeProgressReport(task string, runErr error) {
+	var body string
+	switch {
+	case a.outcome != nil:
+		body = a.outcome.progressMarkdown()
+	case errors.Is(runErr, errBudgetExceeded), errors.Is(runErr, errProvider), errors.Is(runErr, errInterrupted),
+		errors.Is(runErr, errTimeout):
+		// Asking the model again would exceed the budget, fail the same way, keep the user
+		// waiting or overrun the time limit.
+	default:
+		var err error
+		if body, err = a.askProgress(task, runErr); err != nil {
+			log.Printf("[agent] ⚠️ Could not ask the model for a progress report: %v\n", err)
+		}
+	}
+	if body == "" {
+		body = a.synthesizeProgress(runErr)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# zug progress report\n\n**Task:** %s  \n**Stopped:** %s: %v\n\n%s\n",
+		task, time.Now().Format(time.RFC3339), runErr, strings.TrimSpace(body))
+	if files := a.netChanges(); len(files) > 0 {
+		sb.WriteString("\n## Files changed\n\n")
+		for _, f := range files {
+			fmt.Fprintf(&sb, "- `%s` (%s, +%d −%d)\n", f.path, f.action, f.added, f.removed)
+		}
+	}
+	if a.runID != 0 {
+		fmt.Fprintf(&sb, "\n## Continue\n\n```bash\nzug run --session %d \"Continue with the remaining work in %s\"\n```\n", a.runID, progressFileName)
+	}
+
+	path := filepath.Join(a.projectDir, progressFileName)
+	if err := a.fs.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		log.Printf("[agent] ⚠️ Could not write %s: %v\n", progressFileName, err)
+		return
+	}
+	log.Printf("[agent] 📝 Progress so far written to %s.\n", progressFileName)
+}
+
+// askProgress asks the model for the report, based on the conversation.
+func (a *AutonomousCodingAgent) askProgress(task string, runErr error) (string, error) {
+	prompt := fmt.Sprintf(`The work on the task was stopped before it was finished: %v.
+
+Write a progress report for whoever continues it, based on the conversation above. Use exactly these Markdown sections:
+## Accomplished
+## Remaining
+## Next steps
+
+Be factual: only claim what the conversation shows, say which files were changed and whether the build and tests pass. Next steps are concrete actions in order.
+
+Original task:
+%s`, runErr, task)
+	messages := append([]openai.ChatCompletionMessage{a.systemMessage()}, a.ctx...)
+	messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: prompt})
+	req, err := a.chatRequest(messages, phaseSummary, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := a.createChatCompletion(req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("empty reply")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// progressMarkdown renders the structured outcome of a run in the report's sections.
+func (o runOutcome) progressMarkdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## Accomplished\n\n%s\n", o.Summary)
+	for _, f := range o.ChangedFiles {
+		fmt.Fprintf(&sb, "- `%s`: %s\n", f.Path, f.Description)
+	}
+	sb.WriteString("\n## Remaining\n\n")
+	if len(o.FollowUps) == 0 {
+		sb.WriteString("Nothing was named; check the changes against the task.\n")
+	}
+	for _, f := range o.FollowUps {
+		fmt.Fprintf(&sb, "- %s\n", f)
+	}
+	return sb.String()
+}
+
+// synthesizeProgress puts the report together from the run's state when the model cannot
+// write it.
+func (a *AutonomousCodingAgent) synthesizeProgress(runErr error) string {
+	var sb strings.Builder
+	sb.WriteString("## Accomplished\n\n")
+	files := a.netChanges()
+	if len(files) == 0 {
+		sb.WriteString("No files were changed.\n")
+	} else {
+		fmt.Fprintf(&sb, "%d file(s) changed (listed below).\n", len(files))
+	}
+	if a.lastReply != "" {
+		fmt.Fprintf(&sb, "\nThe model's last summary:\n\n> %s\n", strings.ReplaceAll(strings.TrimSpace(a.lastReply), "\n", "\n> "))
+	}
+
+	sb.WriteString("\n## Remaining\n\n")
+	fmt.Fprintf(&sb, "The task is not finished: %v.\n", runErr)
+	switch {
+	case a.status.testsRan && !a.status.testsPassed:
+		fmt.Fprintf(&sb, "\nThe tests fail. End of the last test run:\n\n```\n%s\n```\n", lastLines(a.status.testOutput, progressTestLines))
+	case a.status.testsRan:
+		sb.WriteString("\nThe tests passed on the last run.\n")
+	case a.status.buildPassed:
+		sb.WriteString("\nThe build passed; there are no tests.\n")
+	}
+
+	sb.WriteString("\n## Next steps\n\n")
+	if len(files) > 0 {
+		sb.WriteString("1. Review the changes so far (`git diff`).\n")
+	}
+	switch {
+	case errors.Is(runErr, errBudgetExceeded):
+		sb.WriteString("1. Raise the token budget (`preflight.max_tokens` in zug.yaml) or narrow the task.\n")
+	case errors.Is(runErr, errProvider):
+		sb.WriteString("1. Check the model provider (API key, quota, status) and run again.\n")
+	case errors.Is(runErr, errInterrupted), errors.Is(runErr, errTimeout):
+		sb.WriteString("1. Resume the run where it was stopped (see below), or finish the remaining work by hand.\n")
+	default:
+		sb.WriteString("1. Continue the run, or finish the remaining work by hand.\n")
+	}
+	return sb.String()
+}
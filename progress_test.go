@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestProgressReportFromModel(t *testing.T) {
+	a, _ := newTestAgent(t, nil, mock.Text("## Accomplished\n\nParsed the config.\n\n## Remaining\n\nThe CLI flag.\n\n## Next steps\n\n1. Add the flag."))
+	a.writeProgressReport("add a --verbose flag", errors.New("stopped by hand"))
+	got := readTestFile(t, a.projectDir, progressFileName)
+	assertContains(t, got, "**Task:** add a --verbose flag")
+	assertContains(t, got, "stopped by hand")
+	assertContains(t, got, "Parsed the config.")
+}
+
+func TestProgressReportWithoutModel(t *testing.T) {
+	a, _ := newTestAgent(t, nil, mock.Error(http.StatusUnauthorized, "invalid_api_key"))
+	err := a.feedbackLoop("anything")
+	if !errors.Is(err, errProvider) {
+		t.Fatalf("err = %v, want a provider error", err)
+	}
+	got := readTestFile(t, a.projectDir, progressFileName)
+	assertContains(t, got, "No files were changed.")
+	assertContains(t, got, "Check the model provider")
+}
@@ -0,0 +1,297 @@
+// Package mock is a scripted stand-in for the OpenAI chat completions API. It serves the
+// replies it was given, in order, and records every request, so the agent's tool dispatch
+// and feedback loop can be tested offline. Point the agent at it with OPENAI_BASE_URL.
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Scripted replies
+  ─────────────────────────────*/
+
+// Reply is one scripted response: a message with content and/or tool calls, or an API
+// error when Status is set.
+type Reply struct {
+	Content      string
+	ToolCalls    []ToolCall
+	FinishReason openai.FinishReason
+
+	Status    int    // HTTP error status, e.g. 429
+	ErrorCode string // error code in the error body, e.g. "insufficient_quota"
+}
+
+// ToolCall is a function call the scripted model requests.
+type ToolCall struct {
+	Name string
+	Args string // JSON arguments
+}
+
+// Text is a final answer without tool calls.
+func Text(content string) Reply {
+	return Reply{Content: content}
+}
+
+// Call is a reply requesting a single tool call; args is marshalled to JSON.
+func Call(name string, args any) Reply {
+	return Reply{ToolCalls: []ToolCall{{Name: name, Args: mustJSON(args)}}}
+}
+
+// Calls is a reply requesting several tool calls at once.
+func Calls(calls ...ToolCall) Reply {
+	return Reply{ToolCalls: calls}
+}
+
+// Tool builds a ToolCall for Calls; args is marshalled to JSON.
+func Tool(name string, args any) ToolCall {
+	return ToolCall{Name: name, Args: mustJSON(args)}
+}
+
+// Error is an API error reply with the given HTTP status.
+func Error(status int, code string) Reply {
+	return Reply{Status: status, ErrorCode: code}
+}
+
+func mustJSON(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("mock: cannot marshal tool arguments: %v", err))
+	}
+	return string(raw)
+}
+
+/*──────────────────────────────
+  Server
+  ─────────────────────────────*/
+
+// Server serves the scripted replies over HTTP.
+type Server struct {
+	URL string // base URL for OPENAI_BASE_URL, ending in /v1
+
+	t        testing.TB
+	srv      *httptest.Server
+	mu       sync.Mutex
+	replies  []Reply
+	requests []Request
+	calls    int
+}
+
+// Request is one recorded request with the API key it was sent with.
+type Request struct {
+	openai.ChatCompletionRequest
+	APIKey string
+}
+
+// NewServer starts a server that answers with replies in order. It is closed when the test
+// ends, which fails the test if scripted replies were left unused.
+func NewServer(t testing.TB, replies ...Reply) *Server {
+	t.Helper()
+	s := &Server{t: t, replies: replies}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", s.chatCompletions)
+	mux.HandleFunc("POST /v1/embeddings", embeddings)
+	s.srv = httptest.NewServer(mux)
+	s.URL = s.srv.URL + "/v1"
+	t.Cleanup(func() {
+		s.srv.Close()
+		if n := s.Remaining(); n > 0 {
+			t.Errorf("mock: %d scripted replies were never requested", n)
+		}
+	})
+	return s
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Remaining is the number of scripted replies not served yet.
+func (s *Server) Remaining() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.replies)
+}
+
+func (s *Server) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req openai.ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("mock: invalid request body: %v", err))
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{ChatCompletionRequest: req, APIKey: bearer(r)})
+	if len(s.replies) == 0 {
+		s.mu.Unlock()
+		s.t.Errorf("mock: unexpected request %d: the script has no replies left", len(s.Requests()))
+		writeError(w, http.StatusInternalServerError, "server_error", "mock: no scripted replies left")
+		return
+	}
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	s.calls++
+	n := s.calls
+	s.mu.Unlock()
+
+	if reply.Status != 0 {
+		writeError(w, reply.Status, reply.ErrorCode, fmt.Sprintf("mock: scripted %d error", reply.Status))
+		return
+	}
+	msg := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply.Content}
+	for i, tc := range reply.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, openai.ToolCall{
+			ID:       fmt.Sprintf("call_%d_%d", n, i+1),
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: tc.Name, Arguments: tc.Args},
+		})
+	}
+	finish := reply.FinishReason
+	if finish == "" {
+		finish = openai.FinishReasonStop
+		if len(msg.ToolCalls) > 0 {
+			finish = openai.FinishReasonToolCalls
+		}
+	}
+	raw, _ := json.Marshal(req.Messages)
+	prompt, completion := len(raw)/4, (len(reply.Content)+len(mustJSON(reply.ToolCalls)))/4
+	usage := openai.Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+	if req.Stream {
+		writeStream(w, fmt.Sprintf("chatcmpl-mock-%d", n), req, msg, finish, usage)
+		return
+	}
+	writeJSON(w, http.StatusOK, openai.ChatCompletionResponse{
+		ID:      fmt.Sprintf("chatcmpl-mock-%d", n),
+		Object:  "chat.completion",
+		Model:   req.Model,
+		Choices: []openai.ChatCompletionChoice{{Index: 0, Message: msg, FinishReason: finish}},
+		Usage:   usage,
+	})
+}
+
+// StreamChunkSize is how many bytes of content or tool call arguments each chunk of a
+// streamed reply carries, so tests see arguments arrive in pieces.
+const StreamChunkSize = 16
+
+// writeStream sends msg as server-sent events the way the API streams a reply: the role,
+// the content in pieces, each tool call's ID and name followed by its arguments in
+// pieces, the finish reason and, when requested, the usage.
+func writeStream(w http.ResponseWriter, id string, req openai.ChatCompletionRequest, msg openai.ChatCompletionMessage, finish openai.FinishReason, usage openai.Usage) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	send := func(delta openai.ChatCompletionStreamChoiceDelta, finish openai.FinishReason, usage *openai.Usage) {
+		chunk := openai.ChatCompletionStreamResponse{ID: id, Object: "chat.completion.chunk", Model: req.Model, Usage: usage}
+		if usage == nil {
+			chunk.Choices = []openai.ChatCompletionStreamChoice{{Index: 0, Delta: delta, FinishReason: finish}}
+		}
+		raw, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", raw)
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	send(openai.ChatCompletionStreamChoiceDelta{Role: openai.ChatMessageRoleAssistant}, "", nil)
+	for _, piece := range pieces(msg.Content) {
+		send(openai.ChatCompletionStreamChoiceDelta{Content: piece}, "", nil)
+	}
+	for i, tc := range msg.ToolCalls {
+		index := i
+		send(openai.ChatCompletionStreamChoiceDelta{ToolCalls: []openai.ToolCall{{
+			Index: &index, ID: tc.ID, Type: tc.Type, Function: openai.FunctionCall{Name: tc.Function.Name},
+		}}}, "", nil)
+		for _, piece := range pieces(tc.Function.Arguments) {
+			send(openai.ChatCompletionStreamChoiceDelta{ToolCalls: []openai.ToolCall{{
+				Index: &index, Function: openai.FunctionCall{Arguments: piece},
+			}}}, "", nil)
+		}
+	}
+	send(openai.ChatCompletionStreamChoiceDelta{}, finish, nil)
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		send(openai.ChatCompletionStreamChoiceDelta{}, "", &usage)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// pieces splits s into pieces of about StreamChunkSize bytes, never inside a character.
+func pieces(s string) []string {
+	var out []string
+	for len(s) > StreamChunkSize {
+		n := StreamChunkSize
+		for n > 1 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		out = append(out, s[:n])
+		s = s[n:]
+	}
+	if s != "" {
+		out = append(out, s)
+	}
+	return out
+}
+
+// EmbeddingDims is the length of the mock's embeddings.
+const EmbeddingDims = 64
+
+// embeddings answers every input with a bag-of-words vector: each lowercased word adds
+// one to a dimension picked by its hash. Texts sharing words are similar, so retrieval
+// can be tested without scripting the vectors. Embedding requests do not use up replies.
+func embeddings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Input []string `json:"input"`
+		Model string   `json:"model"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("mock: invalid request body: %v", err))
+		return
+	}
+	resp := openai.EmbeddingResponse{Object: "list", Model: openai.EmbeddingModel(req.Model)}
+	for i, text := range req.Input {
+		vec := make([]float32, EmbeddingDims)
+		for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			vec[h.Sum32()%EmbeddingDims]++
+		}
+		resp.Data = append(resp.Data, openai.Embedding{Object: "embedding", Index: i, Embedding: vec})
+		resp.Usage.PromptTokens += len(text) / 4
+	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func bearer(r *http.Request) string {
+	const prefix = "Bearer "
+	if h := r.Header.Get("Authorization"); len(h) > len(prefix) {
+		return h[len(prefix):]
+	}
+	return ""
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	body := map[string]any{"error": map[string]any{"message": message, "type": code, "code": code}}
+	writeJSON(w, status, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
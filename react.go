@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Text tool protocol (ReAct)
+  ─────────────────────────────*/
+
+// Values of --tool-protocol.
+const (
+	toolProtocolNative = "native" // the API's function calling (default)
+	toolProtocolText   = "text"   // THOUGHT/ACTION/ARGS blocks in the reply, for models without function calling
+)
+
+// textProtocolIntro explains the protocol at the end of the system prompt, followed by
+// the tool list.
+const textProtocolIntro = `# Calling tools
+
+Function calling is not available, so call tools by writing these lines in your reply:
+
+THOUGHT: what you will do next and why
+ACTION: the tool name
+ARGS: the arguments, as one JSON object
+
+You may write several ACTION/ARGS pairs in one reply; they run in order. Then end your reply: the results come back in the next message as OBSERVATION blocks. Never write OBSERVATION yourself. When the task is done, reply with your final answer and no ACTION line.
+
+Available tools:`
+
+// textProtocolMarker finds the start of each block of a reply.
+var textProtocolMarker = regexp.MustCompile(`(?m)^[ \t*#]*(THOUGHT|ACTION|ARGS|OBSERVATION)[ \t*]*:[ \t]*`)
+
+// usesTextTools reports whether tools are offered through the text protocol, either by
+// choice or because the model has no function calling.
+func (a *AutonomousCodingAgent) usesTextTools() bool {
+	return a.textTools || capabilitiesFor(a.model).noTools
+}
+
+// describeTextTools renders the tool definitions for the system prompt.
+func describeTextTools(tools []openai.Tool) string {
+	var sb strings.Builder
+	sb.WriteString(textProtocolIntro)
+	for _, t := range tools {
+		if t.Function == nil {
+			continue
+		}
+		params, _ := json.Marshal(t.Function.Parameters)
+		fmt.Fprintf(&sb, "\n\n## %s\n%s\nARGS schema: %s", t.Function.Name, t.Function.Description, params)
+	}
+	return sb.String()
+}
+
+// textToolMessages rewrites a conversation for a model without function calling: the
+// tools are described in the system prompt, the tool calls of earlier replies stay as
+// the text they were written in, and tool results become user messages.
+func textToolMessages(messages []openai.ChatCompletionMessage, tools []openai.Tool) []openai.ChatCompletionMessage {
+	out := make([]openai.ChatCompletionMessage, 0, len(messages))
+	for _, m := range messages {
+		switch {
+		case m.Role == openai.ChatMessageRoleSystem && len(out) == 0:
+			m.Content += "\n\n" + describeTextTools(tools)
+		case m.Role == openai.ChatMessageRoleAssistant:
+			m.ToolCalls = nil
+		case m.Role == openai.ChatMessageRoleTool:
+			obs := fmt.Sprintf("OBSERVATION (%s):\n%s", m.Name, m.Content)
+			// The results of one reply's calls go back in a single message.
+			if last := len(out) - 1; last >= 0 && out[last].Role == openai.ChatMessageRoleUser && strings.HasPrefix(out[last].Content, "OBSERVATION (") {
+				out[last].Content += "\n\n" + obs
+				continue
+			}
+			m = openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: obs}
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// parseTextToolCalls turns the ACTION/ARGS blocks of a reply into tool calls, so they
+// run through the same dispatcher as native ones. Anything from an OBSERVATION the model
+// wrote itself onwards is dropped. idPrefix makes the call IDs unique in the conversation.
+func parseTextToolCalls(msg openai.ChatCompletionMessage, idPrefix string) openai.ChatCompletionMessage {
+	content := msg.Content
+	marks := textProtocolMarker.FindAllStringSubmatchIndex(content, -1)
+	var calls []openai.ToolCall
+blocks:
+	for i, m := range marks {
+		label := content[m[2]:m[3]]
+		end := len(content)
+		if i+1 < len(marks) {
+			end = marks[i+1][0]
+		}
+		body := strings.TrimSpace(content[m[1]:end])
+		switch label {
+		case "OBSERVATION":
+			content = strings.TrimSpace(content[:m[0]])
+			break blocks
+		case "ACTION":
+			if name := strings.Trim(firstLine(body), "`*\"' "); name != "" {
+				calls = append(calls, openai.ToolCall{
+					ID:       fmt.Sprintf("%s-%d", idPrefix, len(calls)+1),
+					Type:     openai.ToolTypeFunction,
+					Function: openai.FunctionCall{Name: name, Arguments: "{}"},
+				})
+			}
+		case "ARGS":
+			if n := len(calls); n > 0 && calls[n-1].Function.Arguments == "{}" {
+				calls[n-1].Function.Arguments = textToolArgs(body)
+			}
+		}
+	}
+	msg.Content, msg.ToolCalls = content, calls
+	return msg
+}
+
+// textToolArgs extracts the JSON object of an ARGS block, which may be fenced or followed
+// by more text. Text that is not JSON is passed on, so the tool reports the error.
+func textToolArgs(body string) string {
+	body = strings.TrimSpace(body)
+	if strings.HasPrefix(body, "```") {
+		body = strings.TrimPrefix(body[3:], "json")
+		if i := strings.Index(body, "```"); i >= 0 {
+			body = body[:i]
+		}
+		body = strings.TrimSpace(body)
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(strings.NewReader(body)).Decode(&raw); err != nil {
+		return body
+	}
+	var buf bytes.Buffer
+	if json.Compact(&buf, raw) != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
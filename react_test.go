@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"zug/provider/mock"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestTextProtocolDrivesTools(t *testing.T) {
+	a, srv := newTestAgent(t, map[string]string{"main.go": "package main\n"},
+		mock.Text("THOUGHT: I need a greeting file and a look at main.go.\n"+
+			"ACTION: create_file\nARGS: ```json\n{\"path\": \"hello.txt\",\n \"content\": \"hi\\n\"}\n```\n"+
+			"ACTION: read_file\nARGS: {\"path\": \"main.go\"}\n"+
+			"OBSERVATION: made up by the model"),
+		mock.Text("Created hello.txt."),
+	)
+	a.textTools = true
+	reply, err := a.chat("create hello.txt", phaseTools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "Created hello.txt." {
+		t.Errorf("reply = %q", reply)
+	}
+	if got := readTestFile(t, a.projectDir, "hello.txt"); got != "hi\n" {
+		t.Errorf("hello.txt = %q", got)
+	}
+
+	reqs := srv.Requests()
+	for _, r := range reqs {
+		if len(r.Tools) > 0 {
+			t.Fatal("tools were sent to a model using the text protocol")
+		}
+		for _, m := range r.Messages {
+			if m.Role == openai.ChatMessageRoleTool || len(m.ToolCalls) > 0 {
+				t.Fatalf("native tool message sent: %+v", m)
+			}
+		}
+	}
+	assertContains(t, reqs[0].Messages[0].Content, "## create_file")
+	msgs := reqs[1].Messages
+	if last := msgs[len(msgs)-1]; last.Role != openai.ChatMessageRoleUser || !strings.HasPrefix(last.Content, "OBSERVATION (create_file):") {
+		t.Fatalf("last message = %+v, want the observations", last)
+	}
+	assertContains(t, msgs[len(msgs)-1].Content, "OBSERVATION (read_file):\npackage main")
+	if assistant := msgs[len(msgs)-2].Content; strings.Contains(assistant, "made up") {
+		t.Errorf("the model's own OBSERVATION was kept: %q", assistant)
+	}
+}
+
+func TestParseTextToolCallsWithoutArgs(t *testing.T) {
+	msg := parseTextToolCalls(openai.ChatCompletionMessage{Content: "**ACTION:** `list_files`\n\nACTION: run_shell\nARGS: not json"}, "t")
+	if len(msg.ToolCalls) != 2 || msg.ToolCalls[0].Function.Name != "list_files" || msg.ToolCalls[0].Function.Arguments != "{}" {
+		t.Fatalf("calls = %+v", msg.ToolCalls)
+	}
+	if msg.ToolCalls[1].Function.Arguments != "not json" || msg.ToolCalls[1].ID != "t-2" {
+		t.Errorf("second call = %+v", msg.ToolCalls[1])
+	}
+	if msg := parseTextToolCalls(openai.ChatCompletionMessage{Content: "All done."}, "t"); len(msg.ToolCalls) != 0 {
+		t.Errorf("final answer parsed as %+v", msg.ToolCalls)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*──────────────────────────────
+  read_files (tool)
+  ─────────────────────────────*/
+
+const (
+	readFilesBudget = maxToolResult - 1000 // characters of file content in one result, leaving room for the notes
+	readFilesMax    = 50                   // files a pattern may select
+)
+
+// readFiles returns several files in one result, each under a header line with its path.
+// Files are taken in order while they fit into readFilesBudget; the ones left out are
+// listed with their size, so the model can read them separately.
+func (a *AutonomousCodingAgent) readFiles(paths []string, pattern string) (string, error) {
+	if pattern != "" {
+		all, err := a.projectFiles()
+		if err != nil {
+			return "", err
+		}
+		var matched []string
+		for _, f := range all {
+			if globMatch(pattern, strings.ReplaceAll(f, "\\", "/")) {
+				matched = append(matched, f)
+			}
+		}
+		if len(matched) == 0 && len(paths) == 0 {
+			return fmt.Sprintf("no project files match %q", pattern), nil
+		}
+		if len(matched) > readFilesMax {
+			return "", fmt.Errorf("%q matches %d files, more than the %d read_files takes; use a narrower pattern or list the paths", pattern, len(matched), readFilesMax)
+		}
+		paths = append(paths, matched...)
+	}
+	if len(paths) == 0 {
+		return "", fmt.Errorf("read_files needs 'paths' or a 'pattern'")
+	}
+
+	var sb strings.Builder
+	var skipped []string
+	seen := map[string]bool{}
+	used, read := 0, 0
+	for _, p := range paths {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		content, err := a.readFile(p)
+		if err != nil {
+			fmt.Fprintf(&sb, "===== %s: %v =====\n\n", p, err)
+			continue
+		}
+		if used+len(content) > readFilesBudget {
+			skipped = append(skipped, fmt.Sprintf("%s (%d bytes)", p, len(content)))
+			continue
+		}
+		used += len(content)
+		read++
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		fmt.Fprintf(&sb, "===== %s (%d lines) =====\n%s\n", p, strings.Count(content, "\n"), content)
+	}
+	fmt.Fprintf(&sb, "===== end: %d of %d file(s) read =====", read, len(seen))
+	if len(skipped) > 0 {
+		fmt.Fprintf(&sb, "\nLeft out to stay within %d characters; read them with read_file: %s", readFilesBudget, strings.Join(skipped, ", "))
+	}
+	return sb.String(), nil
+}
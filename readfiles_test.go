@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadFiles(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		"auth/login.go":  "package auth\n\nfunc Login() {}\n",
+		"auth/token.go":  "package auth",
+		"auth/big.go":    strings.Repeat("// filler\n", readFilesBudget/10),
+		"main.go":        "package main\n",
+		".env":           "SECRET=1\n",
+		"docs/readme.md": "# docs\n",
+	})
+	got, err := a.dispatchTool("call", "read_files", `{"paths": ["main.go", "missing.go", ".env"], "pattern": "auth/*.go"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, got, "===== main.go (1 lines) =====\npackage main\n")
+	assertContains(t, got, "===== missing.go: ")
+	assertContains(t, got, "===== .env: ")
+	assertContains(t, got, "===== auth/login.go (3 lines) =====\npackage auth\n\nfunc Login() {}\n")
+	assertContains(t, got, "===== auth/token.go (1 lines) =====\npackage auth\n")
+	assertContains(t, got, "read them with read_file: auth/big.go (")
+	assertContains(t, got, "===== end: 3 of 6 file(s) read =====")
+	if strings.Contains(got, "SECRET") || strings.Contains(got, "# docs") {
+		t.Errorf("read_files returned files it should not have:\n%s", got)
+	}
+
+	if _, err := a.dispatchTool("call", "read_files", `{}`); err == nil {
+		t.Error("read_files without paths or pattern did not fail")
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Failure classification and recovery
+  ─────────────────────────────*/
+
+// Kinds of turn failures, each with its own recovery.
+const (
+	failBuild           = "compile error"    // the project does not build: fix the compile errors first
+	failTests           = "test failure"     // the tests fail: fix the code against the test output
+	failLint            = "lint violation"   // the linter complains: fix without changing behavior
+	failToolMisuse      = "tool misuse"      // edits that keep failing: switch to line-based edits
+	failContextOverflow = "context overflow" // the request is too large for the model: shrink the context
+	failAPI             = "API error"        // transient provider failure: wait and retry
+	failStepLimit       = "step limit"       // too many tool calls without an answer: take stock, then continue
+	failFatal           = "fatal"            // nothing to recover: end the run
+)
+
+const (
+	maxRecoveries     = 3    // consecutive failed turns recovered from before the run ends
+	recoveryPromptCap = 8000 // characters of the instruction resent after a context overflow
+	editFailureLimit  = 2    // failed edits of one file before line-based edits are suggested
+)
+
+var (
+	errToolSteps   = errors.New("too many tool invocations without a final answer")
+	recoveryBackof = []time.Duration{2 * time.Second, 10 * time.Second, 30 * time.Second} // per consecutive API failure
+)
+
+// classifyFailure tells why a model turn failed.
+func classifyFailure(err error) string {
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	var netErr net.Error
+	msg := strings.ToLower(err.Error())
+	switch {
+	case errors.Is(err, errRepeatedAction), errors.Is(err, errBudgetExceeded), errors.Is(err, errPolicyViolation),
+		errors.Is(err, errInterrupted), errors.Is(err, errTimeout), errors.Is(err, context.DeadlineExceeded):
+		return failFatal
+	case errors.Is(err, errToolSteps):
+		return failStepLimit
+	case errors.As(err, &apiErr) && fmt.Sprint(apiErr.Code) == "context_length_exceeded",
+		strings.Contains(msg, "context_length_exceeded"), strings.Contains(msg, "maximum context length"),
+		strings.Contains(msg, "context window"), strings.Contains(msg, "prompt is too long"):
+		return failContextOverflow
+	case errors.As(err, &apiErr) && transientStatus(apiErr.HTTPStatusCode),
+		errors.As(err, &reqErr) && transientStatus(reqErr.HTTPStatusCode),
+		errors.As(err, &netErr), strings.Contains(msg, "connection reset"), strings.Contains(msg, "unexpected eof"):
+		return failAPI
+	}
+	return failFatal
+}
+
+func transientStatus(code int) bool {
+	return code == 429 || code == 408 || code >= 500
+}
+
+// recoverTurn picks the recovery for a failed model turn. It returns the next instruction
+// and true when the run should go on, after at most maxRecoveries failures in a row.
+func (a *AutonomousCodingAgent) recoverTurn(err error, instruction string, failures *int) (string, bool) {
+	kind := classifyFailure(err)
+	*failures++
+	if kind == failFatal || *failures > maxRecoveries {
+		return "", false
+	}
+	a.noteFailure(kind)
+	log.Printf("[agent] 🩹 Recovering from a %s (%d/%d): %v\n", kind, *failures, maxRecoveries, err)
+	a.dropPendingPrompt(instruction)
+
+	switch kind {
+	case failContextOverflow:
+		a.shrinkContext()
+		if len(instruction) > recoveryPromptCap {
+			instruction = "[... beginning cut to fit the context window]\n" + instruction[len(instruction)-recoveryPromptCap:]
+		}
+		return instruction + "\n\n(The conversation was too long for the model and older messages were dropped. Re-read files you need instead of relying on earlier output.)", true
+	case failAPI:
+		wait := recoveryBackof[min(*failures, len(recoveryBackof))-1]
+		log.Printf("[agent] ⏳ Retrying in %s.\n", wait)
+		time.Sleep(wait)
+		return instruction, true
+	case failStepLimit:
+		return "You used the maximum number of tool calls for one step without finishing. State briefly what is done and what remains, then continue with the next action. Prefer fewer, larger steps (edit_file with several blocks, run the tests once after a batch of edits).", true
+	}
+	return "", false
+}
+
+// noteFailure counts a failed turn (or tool misuse) of kind for the run summary.
+func (a *AutonomousCodingAgent) noteFailure(kind string) {
+	if a.status.failures == nil {
+		a.status.failures = map[string]int{}
+	}
+	a.status.failures[kind]++
+}
+
+// failureSummary lists the failures the run recovered from, most frequent first.
+func (a *AutonomousCodingAgent) failureSummary() string {
+	kinds := make([]string, 0, len(a.status.failures))
+	for k := range a.status.failures {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		fi, fj := a.status.failures[kinds[i]], a.status.failures[kinds[j]]
+		return fi > fj || (fi == fj && kinds[i] < kinds[j])
+	})
+	parts := make([]string, len(kinds))
+	for i, k := range kinds {
+		parts[i] = fmt.Sprintf("%d× %s", a.status.failures[k], k)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// dropPendingPrompt removes instruction from the end of the conversation when the failed
+// turn never got a reply to it, so the retry does not send it twice.
+func (a *AutonomousCodingAgent) dropPendingPrompt(instruction string) {
+	if n := len(a.ctx); n > 0 && a.ctx[n-1].Role == openai.ChatMessageRoleUser && a.ctx[n-1].Content == instruction {
+		a.ctx = a.ctx[:n-1]
+	}
+}
+
+// shrinkContext halves the conversation window for the rest of the run and trims the
+// context to it.
+func (a *AutonomousCodingAgent) shrinkContext() {
+	if a.cfg.Context.Strategy == windowTokens {
+		budget := a.cfg.Context.MaxTokens
+		if budget <= 0 {
+			budget = defaultWindowTokens
+		}
+		a.cfg.Context.MaxTokens = budget / 2
+	}
+	a.maxCtxMessages = max(min(a.maxCtxMessages, len(a.ctx))/2, 4)
+	log.Printf("[agent] ✂️ Shrinking the context window to %d messages.\n", a.maxCtxMessages)
+	a.trimContext()
+}
+
+// editFailureHint counts failed edits per file, including update_file calls whose find
+// pattern matched nothing, and once the same file failed editFailureLimit times in a row,
+// returns advice to append to the tool result: the model is guessing at text it has not
+// read exactly, and line-based edits do not need it to.
+func (a *AutonomousCodingAgent) editFailureHint(tool, jsonArgs, result string, toolErr error) string {
+	switch tool {
+	case "update_file", "edit_file", "replace_symbol", "replace_lines":
+	default:
+		return ""
+	}
+	var p struct {
+		Path string `json:"path"`
+	}
+	if json.Unmarshal([]byte(jsonArgs), &p) != nil || p.Path == "" {
+		return ""
+	}
+	if a.editFailures == nil {
+		a.editFailures = map[string]int{}
+	}
+	if toolErr == nil && !wastedCall(tool, result) {
+		delete(a.editFailures, p.Path)
+		return ""
+	}
+	a.editFailures[p.Path]++
+	if a.editFailures[p.Path] < editFailureLimit {
+		return ""
+	}
+	a.noteFailure(failToolMisuse)
+	log.Printf("[agent] 🩹 Recovering from %s: %d failed edits of %s; suggesting line-based edits.\n", failToolMisuse, a.editFailures[p.Path], p.Path)
+	return fmt.Sprintf("\n\nRECOVERY: this is failed edit number %d of %s in a row. Stop guessing at its text. Call read_file with path %q and line_numbers=true, then change it with replace_lines using those line numbers (or rewrite the whole file with create_file and overwrite=true if it is short).", a.editFailures[p.Path], p.Path, p.Path)
+}
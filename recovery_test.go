@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"zug/provider/mock"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&openai.APIError{Code: "context_length_exceeded", HTTPStatusCode: 400, Message: "too long"}, failContextOverflow},
+		{&openai.APIError{HTTPStatusCode: 503, Message: "overloaded"}, failAPI},
+		{&openai.RequestError{HTTPStatusCode: 429, Err: errors.New("rate limited")}, failAPI},
+		{&openai.APIError{HTTPStatusCode: 401, Message: "bad key"}, failFatal},
+		{fmt.Errorf("turn 2: %w", errToolSteps), failStepLimit},
+		{fmt.Errorf("%w: read_file", errRepeatedAction), failFatal},
+	}
+	for _, tt := range tests {
+		if got := classifyFailure(tt.err); got != tt.want {
+			t.Errorf("classifyFailure(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+// noBackoff makes API error retries immediate for the test.
+func noBackoff(t *testing.T) {
+	saved := recoveryBackof
+	recoveryBackof = []time.Duration{0}
+	t.Cleanup(func() { recoveryBackof = saved })
+}
+
+func TestTransientAPIErrorIsRetried(t *testing.T) {
+	noBackoff(t)
+	a, srv := newTestAgent(t, nil,
+		mock.Error(http.StatusServiceUnavailable, "overloaded"),
+		mock.Text("all done"),
+	)
+	if err := a.feedbackLoop("say hi"); err != nil {
+		t.Fatal(err)
+	}
+	if a.status.failures[failAPI] != 1 {
+		t.Errorf("failures = %v", a.status.failures)
+	}
+	// The retry sends the task once, not twice.
+	msgs := srv.Requests()[1].Messages
+	if n := len(msgs); n != 2 {
+		t.Errorf("retry sent %d messages, want system prompt and task", n)
+	}
+}
+
+func TestRepeatedEditFailuresSuggestLineEdits(t *testing.T) {
+	bad := func(search string) mock.Reply {
+		return mock.Call("edit_file", map[string]any{"path": "a.go", "edits": "<<<<<<< SEARCH\n" + search + "\n=======\nx\n>>>>>>> REPLACE"})
+	}
+	a, srv := newTestAgent(t, map[string]string{"a.go": "package a\n"},
+		bad("nope"), bad("still not there"),
+		mock.Text("done"),
+	)
+	if _, err := a.chat("edit a.go", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	reqs := srv.Requests()
+	first := reqs[1].Messages[len(reqs[1].Messages)-1].Content
+	second := reqs[2].Messages[len(reqs[2].Messages)-1].Content
+	if strings.Contains(first, "RECOVERY:") {
+		t.Errorf("hint after the first failure: %s", first)
+	}
+	assertContains(t, second, "RECOVERY: this is failed edit number 2 of a.go")
+	assertContains(t, second, "replace_lines")
+	if a.status.failures[failToolMisuse] != 1 {
+		t.Errorf("failures = %v", a.status.failures)
+	}
+
+	// An update_file whose find pattern matches nothing returns no error, but is a failed edit too.
+	noMatch := func(find string) mock.Reply {
+		return mock.Call("update_file", map[string]any{"path": "b.go", "find": find, "replace": "x"})
+	}
+	b, srv := newTestAgent(t, map[string]string{"b.go": "package b\n"},
+		noMatch("nope"), noMatch("still not there"),
+		mock.Text("done"),
+	)
+	if _, err := b.chat("edit b.go", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	reqs = srv.Requests()
+	if first := reqs[1].Messages[len(reqs[1].Messages)-1].Content; strings.Contains(first, "RECOVERY:") {
+		t.Errorf("hint after the first failure: %s", first)
+	}
+	assertContains(t, reqs[2].Messages[len(reqs[2].Messages)-1].Content, "RECOVERY: this is failed edit number 2 of b.go")
+}
+
+func TestReplaceLines(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{"a.txt": "one\ntwo\nthree\n"})
+	if _, err := a.replaceLines("a.txt", 2, 2, "TWO\n2b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.replaceLines("a.txt", 1, 0, "zero"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, a.projectDir, "a.txt"); got != "zero\none\nTWO\n2b\nthree\n" {
+		t.Errorf("a.txt = %q", got)
+	}
+	if _, err := a.replaceLines("a.txt", 4, 9, ""); err == nil {
+		t.Error("out-of-range lines were accepted")
+	}
+	if got := numberLines("a\nb\n"); got != "1| a\n2| b\n" {
+		t.Errorf("numberLines = %q", got)
+	}
+}
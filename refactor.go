@@ -0,0 +1,201 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+/*──────────────────────────────
+  Refactor mode (behavior-preserving)
+  ─────────────────────────────*/
+
+// refactorBaseline is the test run recorded before a refactor. The refactor may only
+// finish when the same tests pass again, unchanged.
+type refactorBaseline struct {
+	output string // output of the passing baseline run
+}
+
+// failingTestPatterns find the names of failing tests in the output of common runners.
+var failingTestPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?m)^\s*--- FAIL: (\S+)`),             // go test
+	regexp.MustCompile(`(?m)^FAILED (\S+)`),                   // pytest summary
+	regexp.MustCompile(`(?m)^test (\S+) \.\.\. FAILED`),       // cargo test
+	regexp.MustCompile(`(?m)^\s*● (.+?)\s*$`),                 // jest
+	regexp.MustCompile(`(?m)^\s*(?:×|✗|✕) (.+?)(?: \d+ms)?$`), // vitest, mocha
+}
+
+// failingTests returns the failing tests named in a test run's output, in order.
+func failingTests(output string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, re := range failingTestPatterns {
+		for _, m := range re.FindAllStringSubmatch(output, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				names = append(names, m[1])
+			}
+		}
+	}
+	return names
+}
+
+var testFileName = regexp.MustCompile(`(_test\.go|_test\.py|\.(test|spec)\.[cm]?[jt]sx?)$|^test_.*\.py$`)
+
+// isTestFile reports whether rel is part of a test suite rather than the code under test.
+func isTestFile(rel string) bool {
+	rel = strings.ReplaceAll(rel, "\\", "/")
+	for _, dir := range strings.Split(path.Dir(rel), "/") {
+		if dir == "test" || dir == "tests" || dir == "__tests__" || dir == "spec" {
+			return true
+		}
+	}
+	return testFileName.MatchString(path.Base(rel))
+}
+
+// newFailures returns the failing tests in a test run's output that did not already
+// show up as failing in the baseline (some runners list expected failures).
+func (b *refactorBaseline) newFailures(output string) []string {
+	known := map[string]bool{}
+	for _, n := range failingTests(b.output) {
+		known[n] = true
+	}
+	var names []string
+	for _, n := range failingTests(output) {
+		if !known[n] {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// changedTestFiles lists the test files the run changed, which a behavior-preserving
+// refactor must leave alone.
+func (a *AutonomousCodingAgent) changedTestFiles() []string {
+	var files []string
+	for _, c := range a.netChanges() {
+		if isTestFile(c.path) {
+			files = append(files, c.path)
+		}
+	}
+	return files
+}
+
+// refactorFollowUp keeps a refactor going while it changed the tests it is measured
+// against, even though they pass. It returns the next instruction and true in that case.
+func (a *AutonomousCodingAgent) refactorFollowUp() (string, bool) {
+	if a.refactor == nil {
+		return "", false
+	}
+	files := a.changedTestFiles()
+	if len(files) == 0 {
+		return "", false
+	}
+	log.Printf("[agent] 🧱 The tests pass, but the refactor changed test files: %s\n", strings.Join(files, ", "))
+	return fmt.Sprintf("This is a behavior-preserving refactor, measured against the tests as they were before you started. You changed these test files: %s. Restore them exactly to their original content and make the refactored code pass the original tests instead.", strings.Join(files, ", ")), true
+}
+
+// refactorRegression explains failing tests during a refactor, naming the changed files
+// that appear in the failure output as the likely cause.
+func (a *AutonomousCodingAgent) refactorRegression(testOutput string) string {
+	if a.refactor == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\nThis is a behavior-preserving refactor: all of these tests passed before your changes, so every failure is a regression you introduced.")
+	if names := a.refactor.newFailures(testOutput); len(names) > 0 {
+		fmt.Fprintf(&sb, " New failures: %s.", strings.Join(names, ", "))
+	}
+	if suspects := a.suspectFiles(testOutput); len(suspects) > 0 {
+		fmt.Fprintf(&sb, " Changed files named in the failure output: %s.", strings.Join(suspects, ", "))
+	}
+	sb.WriteString(" Fix the code, not the tests.")
+	return sb.String()
+}
+
+// suspectFiles lists the changed files whose name appears in a failing test run's output.
+func (a *AutonomousCodingAgent) suspectFiles(testOutput string) []string {
+	var suspects []string
+	for _, c := range a.netChanges() {
+		if c.action != "deleted" && strings.Contains(testOutput, path.Base(c.path)) {
+			suspects = append(suspects, c.path)
+		}
+	}
+	return suspects
+}
+
+// refactorReport describes the outcome of a refactor against its baseline.
+func (a *AutonomousCodingAgent) refactorReport() string {
+	var sb strings.Builder
+	sb.WriteString("🧱 Refactor report\n")
+	changes := a.netChanges()
+	sb.WriteString("   Baseline: the test suite passed before the refactor\n")
+	switch {
+	case !a.status.testsRan:
+		sb.WriteString("   Result:   ⚠️ the tests did not run after the refactor\n")
+	case a.status.testsPassed && len(a.changedTestFiles()) == 0:
+		sb.WriteString("   Result:   ✅ behavior preserved: the unchanged test suite still passes\n")
+	case a.status.testsPassed:
+		fmt.Fprintf(&sb, "   Result:   ❌ the tests pass, but only after changing test files: %s\n", strings.Join(a.changedTestFiles(), ", "))
+	default:
+		sb.WriteString("   Result:   ❌ regressions\n")
+		names := a.refactor.newFailures(a.status.testOutput)
+		if len(names) == 0 {
+			names = []string{"(the test output names no individual tests; see it above)"}
+		}
+		for _, n := range names {
+			fmt.Fprintf(&sb, "     - %s\n", n)
+		}
+		if suspects := a.suspectFiles(a.status.testOutput); len(suspects) > 0 {
+			fmt.Fprintf(&sb, "   Likely cause (changed files named in the failures): %s\n", strings.Join(suspects, ", "))
+		}
+	}
+	fmt.Fprintf(&sb, "   Files:    %d changed\n", len(changes))
+	for _, c := range changes {
+		fmt.Fprintf(&sb, "     %s (%s, +%d −%d)\n", c.path, c.action, c.added, c.removed)
+	}
+	return sb.String()
+}
+
+/*──────────────────────────────
+  zug refactor
+  ─────────────────────────────*/
+
+func refactorCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	return func() {
+		goal := strings.TrimSpace(arg(fs.Args(), 0))
+		if goal == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+		agent := cf.newAgent(arg(fs.Args(), 1))
+
+		log.Println("[agent] 🧱 Running the test suite to record the baseline before refactoring.")
+		output, ran, passed := agent.runTests()
+		switch {
+		case !ran:
+			log.Fatalf("❌ Refactor mode needs a test suite to preserve behavior against, and %s has none (configure test.command in %s).", cf.dir, configFileName)
+		case !passed:
+			fmt.Printf("🧪 Baseline test output:\n%s\n\n", output)
+			log.Fatalf("❌ The tests fail before the refactor, so there is no baseline to preserve. Fix them first.")
+		}
+		agent.refactor = &refactorBaseline{output: output}
+
+		task := fmt.Sprintf(`Refactor: %s
+
+This is a behavior-preserving refactor. The test suite passes now and must pass unchanged when you are done: do not edit, delete or skip tests, and do not change observable behavior (outputs, errors, public interfaces) unless the goal explicitly asks for it.`, goal)
+		err := agent.feedbackLoop(task)
+		if err != nil {
+			log.Printf("[agent] ❌ Refactor did not complete: %v\n", err)
+		}
+		fmt.Println(agent.refactorReport())
+		agent.notifyRunEnd(task, err)
+		os.Exit(agent.exitCode(err))
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestFailingTests(t *testing.T) {
+	for _, tc := range []struct {
+		name, output string
+		want         []string
+	}{
+		{"go", "=== RUN   TestA\n--- FAIL: TestA (0.00s)\n    --- FAIL: TestA/sub (0.00s)\nFAIL\n", []string{"TestA", "TestA/sub"}},
+		{"pytest", "FAILED tests/test_x.py::test_one - assert 1 == 2\n", []string{"tests/test_x.py::test_one"}},
+		{"cargo", "test parser::tests::empty ... FAILED\ntest parser::tests::full ... ok\n", []string{"parser::tests::empty"}},
+		{"jest", "  ● Cart › adds an item\n", []string{"Cart › adds an item"}},
+		{"passing", "ok  \tzug\t0.1s\n", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := failingTests(tc.output); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("failingTests = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRefactorMustNotChangeTests(t *testing.T) {
+	a, srv := newTestAgent(t, map[string]string{
+		configFileName:  "test:\n  command: grep -q ok check_test.go\n",
+		"check_test.go": "ok\n",
+		"main.go":       "package main\n",
+	},
+		mock.Call("update_file", map[string]any{"path": "check_test.go", "find": "ok", "replace": "ok // tweaked"}),
+		mock.Text("refactored"),
+		mock.Call("update_file", map[string]any{"path": "check_test.go", "find": "ok // tweaked", "replace": "ok"}),
+		mock.Text("restored the test"),
+	)
+	a.refactor = &refactorBaseline{output: "ok"}
+	if err := a.feedbackLoop("Refactor: simplify main.go"); err != nil {
+		t.Fatal(err)
+	}
+	reqs := srv.Requests()
+	if len(reqs) != 4 {
+		t.Fatalf("%d requests, want a second turn after the test file was changed", len(reqs))
+	}
+	last := reqs[2].Messages[len(reqs[2].Messages)-1]
+	assertContains(t, last.Content, "You changed these test files: check_test.go")
+	assertContains(t, a.refactorReport(), "✅ behavior preserved")
+}
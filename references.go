@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+/*──────────────────────────────
+  find_references (tool)
+  ─────────────────────────────*/
+
+const (
+	lspQueryTimeout = 30 * time.Second // references and rename can need a full workspace load
+	maxReferences   = 100
+)
+
+// errNoLanguageServer means references fall back to a text search.
+var errNoLanguageServer = errors.New("no language server")
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"` // in UTF-16 code units
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+// symbolReference is one use of a symbol: a 1-based line and the byte range of the
+// identifier in it.
+type symbolReference struct {
+	path       string
+	line       int
+	start, end int
+	text       string
+}
+
+// definitionOf finds the one definition of name, narrowed to the file path when given.
+func (a *AutonomousCodingAgent) definitionOf(name, path string) (indexedSymbol, error) {
+	all, err := a.refreshSymbols()
+	if err != nil {
+		return indexedSymbol{}, err
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	var found []indexedSymbol
+	for _, s := range all {
+		if (s.name == name || (!strings.Contains(name, ".") && strings.HasSuffix(s.name, "."+name))) && (path == "." || s.path == path) {
+			found = append(found, s)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return indexedSymbol{}, fmt.Errorf("no definition of %q found; look it up with find_symbol", name)
+	case 1:
+		return found[0], nil
+	}
+	var where []string
+	for _, s := range found {
+		where = append(where, fmt.Sprintf("%s:%d (%s)", s.path, s.line, s.name))
+	}
+	return indexedSymbol{}, fmt.Errorf("%q is defined %d times: %s; pass path, or qualify the name, to pick one", name, len(found), strings.Join(where, ", "))
+}
+
+// identifierPattern matches the last part of a symbol name as a whole word.
+func identifierPattern(name string) *regexp.Regexp {
+	base := name[strings.LastIndex(name, ".")+1:]
+	return regexp.MustCompile(`\b` + regexp.QuoteMeta(base) + `\b`)
+}
+
+// identifierAt is the position of def's name on the line of its definition.
+func identifierAt(src string, def indexedSymbol) (lspPosition, bool) {
+	lines := strings.Split(src, "\n")
+	if def.line < 1 || def.line > len(lines) {
+		return lspPosition{}, false
+	}
+	line := lines[def.line-1]
+	loc := identifierPattern(def.name).FindStringIndex(line)
+	if loc == nil {
+		return lspPosition{}, false
+	}
+	return lspPosition{Line: def.line - 1, Character: utf16Column(line, loc[0])}, true
+}
+
+// utf16Column converts a byte offset in line to the UTF-16 column LSP counts in.
+func utf16Column(line string, byteCol int) int {
+	return len(utf16.Encode([]rune(line[:byteCol])))
+}
+
+// byteColumn converts an LSP UTF-16 column back to a byte offset in line.
+func byteColumn(line string, col int) int {
+	units := 0
+	for i, r := range line {
+		if units >= col {
+			return i
+		}
+		units += utf16.RuneLen(r)
+		if r == utf8.RuneError {
+			units = col // invalid UTF-8: stop here rather than overshoot
+		}
+	}
+	return len(line)
+}
+
+// uriPath turns a file URI from a language server into a path relative to the project,
+// or "" for files outside it, such as the standard library.
+func (a *AutonomousCodingAgent) uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	rel, err := filepath.Rel(a.projectDir, filepath.FromSlash(u.Path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// openInServer starts the language server for def's file and opens the file in it, which
+// servers need before answering queries about a position in it.
+func (a *AutonomousCodingAgent) openInServer(def indexedSymbol) (*lspClient, string, lspPosition, error) {
+	if a.remote != nil {
+		return nil, "", lspPosition{}, errNoLanguageServer
+	}
+	c := a.languageServer(def.path)
+	if c == nil {
+		return nil, "", lspPosition{}, errNoLanguageServer
+	}
+	full := filepath.Join(a.projectDir, filepath.FromSlash(def.path))
+	raw, err := a.fs.ReadFile(full)
+	if err != nil {
+		return nil, "", lspPosition{}, err
+	}
+	pos, ok := identifierAt(string(raw), def)
+	if !ok {
+		return nil, "", lspPosition{}, fmt.Errorf("cannot find %s on line %d of %s", def.name, def.line, def.path)
+	}
+	ext := strings.ToLower(filepath.Ext(def.path))
+	langID := lspLanguageIDs[ext]
+	if langID == "" {
+		langID = strings.TrimPrefix(ext, ".")
+	}
+	if err := c.sync(full, langID, string(raw)); err != nil {
+		return nil, "", lspPosition{}, err
+	}
+	return c, fileURI(full), pos, nil
+}
+
+// lspReferences asks the language server for the uses of def, not counting the
+// definition itself.
+func (a *AutonomousCodingAgent) lspReferences(def indexedSymbol) ([]symbolReference, error) {
+	c, uri, pos, err := a.openInServer(def)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := c.call("textDocument/references", map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     pos,
+		"context":      map[string]bool{"includeDeclaration": false},
+	}, lspQueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	var locs []lspLocation
+	if err := json.Unmarshal(raw, &locs); err != nil {
+		return nil, fmt.Errorf("unexpected references result: %w", err)
+	}
+	files := map[string][]string{}
+	var refs []symbolReference
+	for _, l := range locs {
+		rel := a.uriPath(l.URI)
+		if rel == "" {
+			continue
+		}
+		lines, ok := files[rel]
+		if !ok {
+			content, _ := a.fs.ReadFile(filepath.Join(a.projectDir, filepath.FromSlash(rel)))
+			lines = strings.Split(string(content), "\n")
+			files[rel] = lines
+		}
+		if l.Range.Start.Line >= len(lines) {
+			continue
+		}
+		text := lines[l.Range.Start.Line]
+		ref := symbolReference{path: rel, line: l.Range.Start.Line + 1, start: byteColumn(text, l.Range.Start.Character), text: text}
+		ref.end = ref.start
+		if l.Range.End.Line == l.Range.Start.Line {
+			ref.end = byteColumn(text, l.Range.End.Character)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// textReferences finds whole-word uses of def's name in the project's text files. It
+// cannot tell the symbol from others of the same name, but needs nothing installed.
+func (a *AutonomousCodingAgent) textReferences(def indexedSymbol) ([]symbolReference, error) {
+	list, err := a.projectFiles()
+	if err != nil {
+		return nil, err
+	}
+	re := identifierPattern(def.name)
+	var refs []symbolReference
+	for _, rel := range list {
+		rel = filepath.ToSlash(rel)
+		if _, denied := a.sensitivePath(rel); denied {
+			continue
+		}
+		full := filepath.Join(a.projectDir, filepath.FromSlash(rel))
+		if info, err := a.fs.Stat(full); err != nil || info.Size() > symbolFileLimit {
+			continue
+		}
+		raw, err := a.fs.ReadFile(full)
+		if err != nil || bytes.IndexByte(raw, 0) >= 0 {
+			continue // unreadable or binary
+		}
+		for i, line := range strings.Split(string(raw), "\n") {
+			for _, loc := range re.FindAllStringIndex(line, -1) {
+				if rel == def.path && i+1 == def.line {
+					continue // the definition itself
+				}
+				refs = append(refs, symbolReference{path: rel, line: i + 1, start: loc[0], end: loc[1], text: line})
+			}
+		}
+	}
+	return refs, nil
+}
+
+// findReferences lists the uses of a symbol, from the language server when one is
+// installed for its language and from a whole-word text search otherwise.
+func (a *AutonomousCodingAgent) findReferences(name, path string) (string, error) {
+	def, err := a.definitionOf(name, path)
+	if err != nil {
+		return "", err
+	}
+	source := "language server"
+	refs, err := a.lspReferences(def)
+	if err != nil {
+		if !errors.Is(err, errNoLanguageServer) {
+			log.Printf("[agent] ⚠️ Language server references for %s failed, searching the text instead: %v\n", def.name, err)
+		}
+		source = "text search: whole-word matches, which may include other symbols of the same name"
+		if refs, err = a.textReferences(def); err != nil {
+			return "", err
+		}
+	}
+	header := fmt.Sprintf("%s %s is defined at %s:%d: %s", def.kind, def.name, def.path, def.line, def.signature)
+	if len(refs) == 0 {
+		return fmt.Sprintf("%s\nNo references found (%s).", header, source), nil
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n%d reference(s) (%s):", header, len(refs), source)
+	last := ""
+	for i, r := range refs {
+		if i == maxReferences {
+			fmt.Fprintf(&sb, "\n... %d more", len(refs)-i)
+			break
+		}
+		key := fmt.Sprintf("%s:%d", r.path, r.line)
+		if key == last {
+			continue // one line per line, even with several uses on it
+		}
+		last = key
+		fmt.Fprintf(&sb, "\n%s: %s", key, strings.TrimSpace(r.text))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindReferencesByText(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		"calc/calc.go":      "package calc\n\n// Add sums.\nfunc Add(a, b int) int {\n\treturn a + b\n}\n",
+		"main.go":           "package main\n\nimport \"x/calc\"\n\nfunc main() {\n\tprintln(calc.Add(1, calc.Add(2, 3)))\n\tAddress := 1\n\t_ = Address\n}\n",
+		"calc/calc_test.go": "package calc\n\nfunc TestAdd(t *testing.T) { Add(1, 2) }\n",
+		".env":              "Add=1\n",
+	})
+	a.cfg.LSP.Servers = map[string]string{".go": "zug-test-no-such-server"}
+	got, err := a.dispatchTool("call", "find_references", `{"name": "Add"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, got, "func Add is defined at calc/calc.go:4: func Add(a, b int) int")
+	assertContains(t, got, "4 reference(s) (text search")
+	assertContains(t, got, "calc/calc.go:3: // Add sums.")
+	assertContains(t, got, "main.go:6: println(calc.Add(1, calc.Add(2, 3)))")
+	assertContains(t, got, "calc/calc_test.go:3: func TestAdd")
+	for _, unwanted := range []string{"Address", "calc.go:4", ".env"} {
+		if strings.Contains(got[strings.Index(got, "\n"):], unwanted) {
+			t.Errorf("references include %q:\n%s", unwanted, got)
+		}
+	}
+
+	if _, err := a.findReferences("Nope", ""); err == nil {
+		t.Error("references of an unknown symbol did not fail")
+	}
+}
+
+func TestUTF16Columns(t *testing.T) {
+	line := "s := \"héllo😀\"; Add()"
+	byteCol := strings.Index(line, "Add")
+	col := utf16Column(line, byteCol)
+	if col != byteCol-1-2 {
+		t.Errorf("utf16Column = %d, want %d", col, byteCol-3)
+	}
+	if got := byteColumn(line, col); got != byteCol {
+		t.Errorf("byteColumn(%d) = %d, want %d", col, got, byteCol)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*──────────────────────────────
+  Remote projects over SSH
+  ─────────────────────────────*/
+
+// With -remote the project lives on another host: the file tools and run_shell go over
+// ssh, while zug.yaml and the .zug state stay in the local -dir. Paths are still checked
+// by absPath against the local project dir and only then mapped to the remote one, so
+// the sandbox rules are the same as for a local project.
+
+const exitNotExist = 44 // exit status of the remote scripts when the path does not exist
+
+// sshTarget is a project directory on a host reachable with the ssh command. Connections
+// are multiplexed over one master connection per host, so each operation costs a round
+// trip rather than a handshake.
+type sshTarget struct {
+	host string // [user@]host or an alias from ~/.ssh/config
+	dir  string // absolute project dir on the host
+
+	mu       sync.Mutex
+	binaries map[string]bool // lookPath results
+}
+
+// parseRemote splits "[user@]host:dir". A relative dir (or ~/dir) is relative to the
+// remote home directory.
+func parseRemote(spec string) (*sshTarget, error) {
+	host, dir, ok := strings.Cut(spec, ":")
+	if !ok || host == "" || strings.HasPrefix(host, "-") {
+		return nil, fmt.Errorf("invalid remote %q (use [user@]host:/path/to/project)", spec)
+	}
+	switch {
+	case dir == "" || dir == "~":
+		dir = "."
+	case strings.HasPrefix(dir, "~/"):
+		dir = dir[2:] // the remote commands start in the home directory
+	}
+	return &sshTarget{host: host, dir: path.Clean(dir)}, nil
+}
+
+// dialRemote checks that the host is reachable and the project dir exists, and resolves
+// the dir to an absolute path.
+func dialRemote(spec string) (*sshTarget, error) {
+	t, err := parseRemote(spec)
+	if err != nil {
+		return nil, err
+	}
+	out, err := t.run("cd -- "+shellQuote(t.dir)+" || exit 44; pwd", nil)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("project dir %s does not exist on %s", t.dir, t.host)
+		}
+		return nil, fmt.Errorf("cannot reach %s: %w", t.host, err)
+	}
+	t.dir = strings.TrimSpace(string(out))
+	log.Printf("[agent] 🌐 Working on %s:%s over ssh.\n", t.host, t.dir)
+	return t, nil
+}
+
+// sshArgs are the options of every ssh invocation. BatchMode fails instead of prompting
+// for a password, which the agent could not answer; use keys or an ssh agent.
+func (t *sshTarget) sshArgs() []string {
+	args := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=15"}
+	if dir, err := userZugDir(); err == nil {
+		args = append(args, "-o", "ControlMaster=auto", "-o", "ControlPersist=5m",
+			"-o", "ControlPath="+filepath.Join(dir, "ssh-%C"))
+	}
+	return append(args, "--", t.host)
+}
+
+// run executes a POSIX sh script on the host with stdin as its input and returns its
+// standard output. Exit status 44 is reported as fs.ErrNotExist.
+func (t *sshTarget) run(script string, stdin []byte) ([]byte, error) {
+	c := exec.Command("ssh", append(t.sshArgs(), "sh -c "+shellQuote(script))...)
+	if stdin != nil {
+		c.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	c.Stdout, c.Stderr = &stdout, &stderr
+	err := c.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return stdout.Bytes(), nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == exitNotExist:
+		return nil, fs.ErrNotExist
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 255:
+		return nil, fmt.Errorf("ssh %s: %s", t.host, strings.TrimSpace(stderr.String()))
+	case stderr.Len() > 0:
+		return nil, errors.New(strings.TrimSpace(stderr.String()))
+	}
+	return nil, err
+}
+
+// shell runs cmd with bash in the remote project dir, like execShell does locally.
+func (t *sshTarget) shell(cmd string) (string, error) {
+	c := exec.Command("ssh", append(t.sshArgs(), "cd -- "+shellQuote(t.dir)+" && exec bash -c "+shellQuote(cmd))...)
+	out, err := c.CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// lookPath reports whether bin is installed on the host.
+func (t *sshTarget) lookPath(bin string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if found, ok := t.binaries[bin]; ok {
+		return found
+	}
+	if t.binaries == nil {
+		t.binaries = map[string]bool{}
+	}
+	_, err := t.run("command -v "+shellQuote(bin), nil)
+	t.binaries[bin] = err == nil
+	return err == nil
+}
+
+/*──────────────────────────────
+  Remote filesystem
+  ─────────────────────────────*/
+
+// sshFS is the projectFS of a remote project. Names are local paths below local, as
+// returned by absPath; each is mapped to the same relative path below the remote dir.
+type sshFS struct {
+	target *sshTarget
+	local  string
+}
+
+func (s *sshFS) remotePath(name string) (string, error) {
+	rel, err := filepath.Rel(s.local, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the project dir", name)
+	}
+	return path.Join(s.target.dir, filepath.ToSlash(rel)), nil
+}
+
+// do runs script with the remote path of name substituted for $P (and its parent for
+// $D), and wraps failures in an fs.PathError like the os package does.
+func (s *sshFS) do(op, name, script string, stdin []byte) ([]byte, error) {
+	p, err := s.remotePath(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	out, err := s.target.run("P="+shellQuote(p)+"; D="+shellQuote(path.Dir(p))+"\n"+script, stdin)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return out, nil
+}
+
+func (s *sshFS) ReadFile(name string) ([]byte, error) {
+	return s.do("open", name, `[ -e "$P" ] || exit 44; exec cat -- "$P"`, nil)
+}
+
+func (s *sshFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	_, err := s.do("open", name, s.createScript(perm)+`exec cat > "$P"`, data)
+	return err
+}
+
+func (s *sshFS) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	_, err := s.do("open", name, s.createScript(perm)+`exec cat >> "$P"`, data)
+	return err
+}
+
+// createScript fails like the local disk when the parent is missing, and gives a new file
+// the requested permissions.
+func (s *sshFS) createScript(perm fs.FileMode) string {
+	return fmt.Sprintf(`[ -d "$D" ] || exit 44
+if [ ! -e "$P" ]; then : > "$P" && chmod %o "$P" || exit 1; fi
+`, perm.Perm())
+}
+
+// statScript prints "mode size mtime name" per argument, with GNU or BSD stat.
+const statScript = `if stat --version >/dev/null 2>&1; then stat -L -c '%f %s %Y %n' -- "$@"; else stat -L -f '%Xp %z %m %N' -- "$@"; fi`
+
+func (s *sshFS) Stat(name string) (fs.FileInfo, error) {
+	out, err := s.do("stat", name, `[ -e "$P" ] || exit 44; set -- "$P"; `+statScript, nil)
+	if err != nil {
+		return nil, err
+	}
+	info, err := parseStatLine(strings.TrimSpace(string(out)))
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	info.name = filepath.Base(name)
+	return info, nil
+}
+
+func (s *sshFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	out, err := s.do("readdir", name, `[ -d "$P" ] || exit 44; cd -- "$P" || exit 1
+set --
+for f in * .[!.]* ..?*; do if [ -e "$f" ]; then set -- "$@" "$f"; fi; done
+[ $# -eq 0 ] && exit 0
+`+statScript+` 2>/dev/null; exit 0`, nil)
+	if err != nil {
+		return nil, err
+	}
+	var entries []fs.DirEntry
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		info, err := parseStatLine(line)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (s *sshFS) MkdirAll(name string, perm fs.FileMode) error {
+	_, err := s.do("mkdir", name, `exec mkdir -p -- "$P"`, nil)
+	return err
+}
+
+func (s *sshFS) RemoveAll(name string) error {
+	_, err := s.do("remove", name, `exec rm -rf -- "$P"`, nil)
+	return err
+}
+
+func (s *sshFS) Chmod(name string, mode fs.FileMode) error {
+	_, err := s.do("chmod", name, fmt.Sprintf(`[ -e "$P" ] || exit 44; exec chmod %o -- "$P"`, mode.Perm()), nil)
+	return err
+}
+
+// remoteInfo is the fs.FileInfo parsed from one line of statScript.
+type remoteInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i remoteInfo) Name() string       { return i.name }
+func (i remoteInfo) Size() int64        { return i.size }
+func (i remoteInfo) Mode() fs.FileMode  { return i.mode }
+func (i remoteInfo) ModTime() time.Time { return i.modTime }
+func (i remoteInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i remoteInfo) Sys() any           { return nil }
+
+// parseStatLine parses "<hex st_mode> <size> <unix mtime> <name>".
+func parseStatLine(line string) (remoteInfo, error) {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) != 4 {
+		return remoteInfo{}, fmt.Errorf("unexpected stat output %q", line)
+	}
+	raw, err1 := strconv.ParseUint(fields[0], 16, 32)
+	size, err2 := strconv.ParseInt(fields[1], 10, 64)
+	mtime, err3 := strconv.ParseInt(fields[2], 10, 64)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return remoteInfo{}, fmt.Errorf("unexpected stat output %q: %w", line, err)
+	}
+	mode := fs.FileMode(raw & 0o777)
+	switch raw & 0o170000 {
+	case 0o100000: // regular file
+	case 0o040000:
+		mode |= fs.ModeDir
+	default:
+		mode |= fs.ModeIrregular
+	}
+	return remoteInfo{name: path.Base(fields[3]), size: size, mode: mode, modTime: time.Unix(mtime, 0)}, nil
+}
+
+// useRemote points the file tools and the shell at t.
+func (a *AutonomousCodingAgent) useRemote(t *sshTarget) {
+	a.remote = t
+	a.fs = a.audited(&sshFS{target: t, local: a.projectDir})
+}
+
+// installed reports whether bin is on the PATH of the machine the project lives on.
+func (a *AutonomousCodingAgent) installed(bin string) bool {
+	if a.remote != nil {
+		return a.remote.lookPath(bin)
+	}
+	_, err := exec.LookPath(bin)
+	return err == nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSSH puts an ssh on the PATH that runs the remote command locally, so sshFS and the
+// remote shell can be tested against a temporary directory.
+func fakeSSH(t *testing.T) {
+	t.Helper()
+	bin := t.TempDir()
+	script := "#!/bin/sh\nwhile [ \"$1\" != -- ]; do shift; done\nshift 2\nexec sh -c \"$*\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "ssh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("ZUG_HOME", t.TempDir())
+}
+
+func TestParseRemote(t *testing.T) {
+	for spec, want := range map[string][2]string{
+		"dev@box:/srv/app": {"dev@box", "/srv/app"},
+		"box:~/src/app/":   {"box", "src/app"},
+		"box:":             {"box", "."},
+	} {
+		got, err := parseRemote(spec)
+		if err != nil || got.host != want[0] || got.dir != want[1] {
+			t.Errorf("parseRemote(%q) = %+v, %v", spec, got, err)
+		}
+	}
+	for _, spec := range []string{"box", ":/srv", "-oProxyCommand=x:/srv"} {
+		if _, err := parseRemote(spec); err == nil {
+			t.Errorf("parseRemote(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestRemoteProject(t *testing.T) {
+	fakeSSH(t)
+	remoteDir := t.TempDir()
+	writeTestFile(t, remoteDir, "src/main.txt", "hello\n")
+	target, err := dialRemote("box:" + remoteDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &AutonomousCodingAgent{projectDir: "/local/project", cfg: &config{}, changes: map[string]*fileChange{}}
+	a.useRemote(target)
+
+	if _, err := a.createFile("src/new.txt", "one\n", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.appendFile("src/new.txt", "two\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.updateFile("src/main.txt", "hello", "bye", nil, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, remoteDir, "src/new.txt"); got != "one\ntwo\n" {
+		t.Errorf("src/new.txt = %q", got)
+	}
+	list, err := a.listFiles()
+	if err != nil || list != "src/main.txt\nsrc/new.txt" {
+		t.Errorf("listFiles = %q, %v", list, err)
+	}
+	if out, err := a.execShell("cat src/main.txt && pwd"); err != nil || out != "bye\n"+remoteDir {
+		t.Errorf("execShell = %q, %v", out, err)
+	}
+	if _, err := a.readFile("../outside"); err == nil {
+		t.Error("reading outside the project succeeded")
+	}
+	if _, err := a.fs.Stat("/local/project/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat of a missing file: err = %v, want ErrNotExist", err)
+	}
+	if err := a.fs.WriteFile("/local/project/missing/x", nil, 0o644); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("writing into a missing directory: err = %v, want ErrNotExist", err)
+	}
+}
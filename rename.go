@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/*──────────────────────────────
+  rename_symbol (tool)
+  ─────────────────────────────*/
+
+var identifierName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// languageFamilies are extensions whose files can refer to each other's symbols. Any
+// other extension only refers to files with the same one.
+var languageFamilies = [][]string{
+	{".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx", ".mts", ".cts", ".vue", ".svelte"},
+	{".c", ".h", ".cc", ".cpp", ".cxx", ".hpp", ".hh"},
+	{".java", ".kt", ".scala"},
+}
+
+func sameLanguage(a, b string) bool {
+	a, b = strings.ToLower(filepath.Ext(a)), strings.ToLower(filepath.Ext(b))
+	if a == b {
+		return true
+	}
+	for _, f := range languageFamilies {
+		if slices.Contains(f, a) && slices.Contains(f, b) {
+			return true
+		}
+	}
+	return false
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+// lspWorkspaceEdit is the answer to a rename: edits by document, in either of the two
+// forms servers use.
+type lspWorkspaceEdit struct {
+	Changes         map[string][]lspTextEdit `json:"changes"`
+	DocumentChanges []struct {
+		Kind         string `json:"kind"` // set for file creations, renames and deletions
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+		Edits []lspTextEdit `json:"edits"`
+	} `json:"documentChanges"`
+}
+
+// textEdit replaces the bytes start..end of a file.
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// lspRename asks the language server to rename def, returning the edits by project path.
+func (a *AutonomousCodingAgent) lspRename(def indexedSymbol, newName string) (map[string][]textEdit, error) {
+	c, uri, pos, err := a.openInServer(def)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := c.call("textDocument/rename", map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     pos,
+		"newName":      newName,
+	}, lspQueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	var we lspWorkspaceEdit
+	if err := json.Unmarshal(raw, &we); err != nil {
+		return nil, fmt.Errorf("unexpected rename result: %w", err)
+	}
+	byURI := we.Changes
+	if byURI == nil {
+		byURI = map[string][]lspTextEdit{}
+	}
+	for _, dc := range we.DocumentChanges {
+		if dc.Kind != "" {
+			return nil, fmt.Errorf("the language server wants to %s a file, which rename_symbol does not do", dc.Kind)
+		}
+		byURI[dc.TextDocument.URI] = append(byURI[dc.TextDocument.URI], dc.Edits...)
+	}
+	edits := map[string][]textEdit{}
+	for u, list := range byURI {
+		rel := a.uriPath(u)
+		if rel == "" {
+			return nil, fmt.Errorf("the rename would change %s, outside the project", u)
+		}
+		content, err := a.fs.ReadFile(filepath.Join(a.projectDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.SplitAfter(string(content), "\n")
+		for _, e := range list {
+			start, ok1 := lspOffset(lines, e.Range.Start)
+			end, ok2 := lspOffset(lines, e.Range.End)
+			if !ok1 || !ok2 || end < start {
+				return nil, fmt.Errorf("the language server's edit of %s is out of range", rel)
+			}
+			edits[rel] = append(edits[rel], textEdit{start, end, e.NewText})
+		}
+	}
+	return edits, nil
+}
+
+// lspOffset converts an LSP position to a byte offset in a file split after each newline,
+// whose last element is the text after the final newline.
+func lspOffset(lines []string, pos lspPosition) (int, bool) {
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return 0, false
+	}
+	offset := 0
+	for _, l := range lines[:pos.Line] {
+		offset += len(l)
+	}
+	return offset + byteColumn(strings.TrimRight(lines[pos.Line], "\r\n"), pos.Character), true
+}
+
+// textRename replaces the whole-word uses of def's name in files of its language. It is
+// refused when another definition shares the name, since the text cannot tell them apart.
+func (a *AutonomousCodingAgent) textRename(def indexedSymbol, newName string) (map[string][]textEdit, error) {
+	all, err := a.refreshSymbols()
+	if err != nil {
+		return nil, err
+	}
+	base := def.name[strings.LastIndex(def.name, ".")+1:]
+	for _, s := range all {
+		if s != def && s.name[strings.LastIndex(s.name, ".")+1:] == base && sameLanguage(s.path, def.path) {
+			return nil, fmt.Errorf("no language server is available, and %s is also defined at %s:%d; a text rename cannot tell the two apart. Install the language server, or rename with edit_file", base, s.path, s.line)
+		}
+	}
+	refs, err := a.textReferences(def)
+	if err != nil {
+		return nil, err
+	}
+	full := filepath.Join(a.projectDir, filepath.FromSlash(def.path))
+	src, err := a.fs.ReadFile(full)
+	if err != nil {
+		return nil, err
+	}
+	edits := map[string][]textEdit{}
+	lineStarts := map[string][]int{}
+	add := func(path string, line, start, end int) error {
+		starts, ok := lineStarts[path]
+		if !ok {
+			raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, filepath.FromSlash(path)))
+			if err != nil {
+				return err
+			}
+			starts = []int{0}
+			for i, b := range raw {
+				if b == '\n' {
+					starts = append(starts, i+1)
+				}
+			}
+			lineStarts[path] = starts
+		}
+		off := starts[line-1]
+		edits[path] = append(edits[path], textEdit{off + start, off + end, newName})
+		return nil
+	}
+	// The definition itself, which the references leave out.
+	pos, ok := identifierAt(string(src), def)
+	if !ok {
+		return nil, fmt.Errorf("cannot find %s on line %d of %s", def.name, def.line, def.path)
+	}
+	lineText := strings.Split(string(src), "\n")[def.line-1]
+	start := byteColumn(lineText, pos.Character)
+	if err := add(def.path, def.line, start, start+len(base)); err != nil {
+		return nil, err
+	}
+	for _, r := range refs {
+		if !sameLanguage(r.path, def.path) {
+			continue
+		}
+		if err := add(r.path, r.line, r.start, r.end); err != nil {
+			return nil, err
+		}
+	}
+	return edits, nil
+}
+
+// applyEdits writes the edits of each file, all or nothing: every new content is built
+// and checked before the first file is written.
+func (a *AutonomousCodingAgent) applyEdits(edits map[string][]textEdit) (int, error) {
+	type pending struct{ path, full, content string }
+	var files []pending
+	count := 0
+	for path, list := range edits {
+		full, err := a.absPath(path)
+		if err != nil {
+			return 0, err
+		}
+		raw, err := a.fs.ReadFile(full)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].start > list[j].start })
+		src := string(raw)
+		for i, e := range list {
+			if e.end > len(src) || (i > 0 && e.end > list[i-1].start) {
+				return 0, fmt.Errorf("overlapping or out-of-range edits in %s; nothing was changed", path)
+			}
+			src = src[:e.start] + e.text + src[e.end:]
+		}
+		if err := a.validateWrite(path, full, src); err != nil {
+			return 0, fmt.Errorf("nothing was changed: %w", err)
+		}
+		files = append(files, pending{path, full, src})
+		count += len(list)
+	}
+	for _, f := range files {
+		a.trackChange(f.path, f.full)
+		content, _ := a.formatSource(f.path, f.content)
+		if err := a.fs.WriteFile(f.full, []byte(content), 0o644); err != nil {
+			return 0, fmt.Errorf("failed to write %s: %w", f.path, err)
+		}
+	}
+	return count, nil
+}
+
+// renameSymbol renames a definition and its uses across the project, through the
+// language server when one is installed and by whole-word text replacement otherwise.
+func (a *AutonomousCodingAgent) renameSymbol(oldName, newName, path string) (string, error) {
+	if !identifierName.MatchString(newName) {
+		return "", fmt.Errorf("%q is not a valid identifier", newName)
+	}
+	def, err := a.definitionOf(oldName, path)
+	if err != nil {
+		return "", err
+	}
+	if base := def.name[strings.LastIndex(def.name, ".")+1:]; base == newName {
+		return fmt.Sprintf("%s is already called %s; nothing changed", def.name, newName), nil
+	}
+	source := "language server"
+	edits, err := a.lspRename(def, newName)
+	if err != nil {
+		if !errors.Is(err, errNoLanguageServer) {
+			// The server understood the code and refused, e.g. because of a conflict.
+			return "", fmt.Errorf("the language server could not rename %s: %w", def.name, err)
+		}
+		source = "text replacement of whole words in " + strings.ToLower(filepath.Ext(def.path)) + " files"
+		if edits, err = a.textRename(def, newName); err != nil {
+			return "", err
+		}
+	}
+	count, err := a.applyEdits(edits)
+	if err != nil {
+		return "", err
+	}
+	a.resyncServer(edits)
+	var paths []string
+	for p := range edits {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	log.Printf("[agent] ✏️ Renamed %s to %s in %d file(s).\n", def.name, newName, len(paths))
+	return fmt.Sprintf("renamed %s %s to %s (%s): %d change(s) in %s", def.kind, def.name, newName, source, count, strings.Join(paths, ", ")), nil
+}
+
+// resyncServer sends the renamed files to the language servers that have them open, so
+// later queries see the new names.
+func (a *AutonomousCodingAgent) resyncServer(edits map[string][]textEdit) {
+	for path := range edits {
+		full := filepath.Join(a.projectDir, filepath.FromSlash(path))
+		for _, c := range a.lsps {
+			if c == nil {
+				continue
+			}
+			c.mu.Lock()
+			open := c.versions[fileURI(full)] > 0
+			c.mu.Unlock()
+			if raw, err := a.fs.ReadFile(full); err == nil && open {
+				c.sync(full, lspLanguageIDs[strings.ToLower(filepath.Ext(path))], string(raw))
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenameSymbolByText(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		"calc/calc.go":      "package calc\n\n// Add sums.\nfunc Add(a, b int) int {\n\treturn a + b\n}\n",
+		"main.go":           "package main\n\nimport \"x/calc\"\n\nfunc main() {\n\tprintln(calc.Add(1, calc.Add(2, 3)))\n\tAddress := 1\n\t_ = Address\n}\n",
+		"calc/calc_test.go": "package calc\n\nfunc TestAdd(t *testing.T) { Add(1, 2) }\n",
+		"notes.md":          "Add is the sum.\n",
+	})
+	a.cfg.LSP.Servers = map[string]string{".go": "zug-test-no-such-server"}
+	got, err := a.dispatchTool("call", "rename_symbol", `{"old": "Add", "new": "Sum"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, got, "renamed func Add to Sum (text replacement of whole words in .go files): 5 change(s) in calc/calc.go, calc/calc_test.go, main.go")
+	assertContains(t, readTestFile(t, a.projectDir, "calc/calc.go"), "// Sum sums.\nfunc Sum(a, b int) int {")
+	assertContains(t, readTestFile(t, a.projectDir, "main.go"), "println(calc.Sum(1, calc.Sum(2, 3)))\n\tAddress := 1")
+	assertContains(t, readTestFile(t, a.projectDir, "calc/calc_test.go"), "func TestAdd(t *testing.T) { Sum(1, 2) }")
+	assertContains(t, readTestFile(t, a.projectDir, "notes.md"), "Add is the sum.")
+
+	if _, err := a.renameSymbol("Sum", "2x", ""); err == nil || !strings.Contains(err.Error(), "not a valid identifier") {
+		t.Errorf("invalid new name: err = %v", err)
+	}
+}
+
+func TestRenameSymbolRefusesAmbiguousText(t *testing.T) {
+	files := map[string]string{
+		"a.go": "package main\n\ntype A struct{}\n\nfunc (A) Start() {}\n",
+		"b.go": "package main\n\ntype B struct{}\n\nfunc (B) Start() {}\n\nfunc run() { B{}.Start(); A{}.Start() }\n",
+	}
+	a, _ := newTestAgent(t, files)
+	a.cfg.LSP.Servers = map[string]string{".go": "zug-test-no-such-server"}
+	_, err := a.dispatchTool("call", "rename_symbol", `{"old": "B.Start", "new": "Run"}`)
+	if err == nil || !strings.Contains(err.Error(), "Start is also defined at a.go:5") {
+		t.Fatalf("err = %v, want a refusal naming A.Start", err)
+	}
+	if got := readTestFile(t, a.projectDir, "b.go"); got != files["b.go"] {
+		t.Errorf("b.go changed despite the refusal:\n%s", got)
+	}
+}
+
+func TestLSPOffset(t *testing.T) {
+	lines := strings.SplitAfter("ab\nx😀y\n", "\n")
+	for _, c := range []struct {
+		pos  lspPosition
+		want int
+		ok   bool
+	}{
+		{lspPosition{0, 1}, 1, true},
+		{lspPosition{1, 3}, 3 + 1 + 4, true},
+		{lspPosition{2, 0}, 10, true},
+		{lspPosition{3, 0}, 0, false},
+	} {
+		got, ok := lspOffset(lines, c.pos)
+		if got != c.want || ok != c.ok {
+			t.Errorf("lspOffset(%v) = %d, %v; want %d, %v", c.pos, got, ok, c.want, c.ok)
+		}
+	}
+}
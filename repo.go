@@ -0,0 +1,585 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  Repositories through the GitHub and GitLab APIs (--repo)
+  ─────────────────────────────*/
+
+const (
+	repoGitHub = "github"
+	repoGitLab = "gitlab"
+
+	defaultGitHubAPI   = "https://api.github.com"
+	repoArchiveLimit   = 200 << 20 // bytes of files unpacked from a repository archive
+	repoRequestTimeout = 2 * time.Minute
+)
+
+// apiRepo is a repository the agent works on without a local clone: its files are
+// downloaded as one archive through the host's API, edited in memory, and the changes
+// are submitted as a commit on a new branch with a pull (merge) request.
+type apiRepo struct {
+	kind    string // repoGitHub or repoGitLab
+	host    string // for display and the state dir
+	api     string // API base URL
+	project string // owner/name, or the GitLab project path
+	token   string
+	ref     string // branch the changes are based on; the default branch unless given
+	base    string // commit SHA of ref when the run started
+	tree    string // GitHub: tree SHA of base
+	client  *http.Client
+	files   map[string]repoFile // the files of base, by slash-separated path
+}
+
+type repoFile struct {
+	sum        string // sha256 of the content
+	executable bool
+}
+
+// scpLikeURL matches the ssh remotes git accepts as user@host:path.
+var scpLikeURL = regexp.MustCompile(`^[\w.-]+@([\w.-]+):(.+)$`)
+
+// parseRepoSpec accepts github:owner/name, gitlab:group/project, or a repository URL
+// (https://github.com/owner/name, git@gitlab.com:group/project.git), each with an
+// optional @branch. A URL must be on github.com, gitlab.com or the host of GITHUB_API_URL
+// or GITLAB_API_URL, unless it is written gitlab:https://host/group/project, so a typo or
+// another forge is not taken for GitLab. connect still sends GITLAB_TOKEN to configured
+// hosts only.
+func parseRepoSpec(spec string) (*apiRepo, error) {
+	r := &apiRepo{}
+	rest := spec
+	explicit := ""
+	for _, kind := range []string{repoGitHub, repoGitLab} {
+		if s, ok := strings.CutPrefix(rest, kind+":"); ok {
+			explicit, rest = kind, s
+		}
+	}
+	if m := scpLikeURL.FindStringSubmatch(rest); m != nil {
+		rest = "https://" + m[1] + "/" + m[2]
+	}
+	switch {
+	case explicit == repoGitHub && !strings.Contains(rest, "://"):
+		r.kind, r.host, r.project = repoGitHub, "github.com", rest
+	case explicit == repoGitLab && !strings.Contains(rest, "://"):
+		r.kind, r.host, r.project = repoGitLab, "gitlab.com", rest
+	default:
+		if !strings.Contains(rest, "://") {
+			rest = "https://" + rest
+		}
+		u, err := url.Parse(rest)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid repository %q: use github:owner/name, gitlab:group/project or the repository's URL", spec)
+		}
+		r.host, r.project = u.Host, u.Path
+		switch {
+		case u.Host == "github.com" || u.Host == "www.github.com":
+			r.kind, r.host = repoGitHub, "github.com"
+		case u.Host == apiHost("GITHUB_API_URL"):
+			r.kind = repoGitHub // GitHub Enterprise
+		case explicit == repoGitLab || u.Host == "gitlab.com" || u.Host == apiHost("GITLAB_API_URL"):
+			r.kind = repoGitLab
+		default:
+			return nil, fmt.Errorf("repository %q is not on github.com or gitlab.com: set GITHUB_API_URL for GitHub Enterprise or GITLAB_API_URL for a self-hosted GitLab", spec)
+		}
+	}
+	r.project, r.ref, _ = strings.Cut(r.project, "@")
+	r.project = strings.TrimSuffix(strings.Trim(r.project, "/"), ".git")
+	parts := strings.Split(r.project, "/")
+	if len(parts) < 2 || (r.kind == repoGitHub && len(parts) != 2) || strings.Contains(r.project, "//") {
+		return nil, fmt.Errorf("invalid repository %q: expected owner/name", spec)
+	}
+	return r, nil
+}
+
+// connect sets up the API address and token of the repository's host.
+func (r *apiRepo) connect() error {
+	r.client = &http.Client{Timeout: repoRequestTimeout}
+	switch r.kind {
+	case repoGitHub:
+		r.api = strings.TrimRight(cmp.Or(os.Getenv("GITHUB_API_URL"), defaultGitHubAPI), "/")
+		r.token = cmp.Or(os.Getenv("GITHUB_TOKEN"), os.Getenv("GH_TOKEN"))
+		if r.token == "" {
+			return fmt.Errorf("set GITHUB_TOKEN to a token that can read %s and push branches to it", r.project)
+		}
+	case repoGitLab:
+		// GITLAB_TOKEN goes to gitlab.com or the host of GITLAB_API_URL only.
+		if r.host != "gitlab.com" && r.host != apiHost("GITLAB_API_URL") {
+			return fmt.Errorf("GITLAB_TOKEN is only sent to gitlab.com and the host of GITLAB_API_URL: set GITLAB_API_URL=https://%s/api/v4 to use it with %s", r.host, r.host)
+		}
+		r.api = strings.TrimRight(cmp.Or(os.Getenv("GITLAB_API_URL"), "https://"+r.host+"/api/v4"), "/")
+		r.token = os.Getenv("GITLAB_TOKEN")
+		if r.token == "" {
+			return fmt.Errorf("set GITLAB_TOKEN to a token with the api scope on %s", r.project)
+		}
+	}
+	return nil
+}
+
+// apiHost is the host of the API URL in the environment variable env, without a leading
+// "api.", so https://api.github.com stands for github.com; "" when env is unset.
+func apiHost(env string) string {
+	u, err := url.Parse(os.Getenv(env))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Host, "api.")
+}
+
+// openRepo resolves the repository's API, token, branch and current commit.
+func openRepo(spec string) (*apiRepo, error) {
+	r, err := parseRepoSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.connect(); err != nil {
+		return nil, err
+	}
+	switch r.kind {
+	case repoGitHub:
+		var repo struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		if err := r.call("GET", "/repos/"+r.project, nil, &repo); err != nil {
+			return nil, err
+		}
+		r.ref = cmp.Or(r.ref, repo.DefaultBranch)
+		var commit struct {
+			SHA    string `json:"sha"`
+			Commit struct {
+				Tree struct {
+					SHA string `json:"sha"`
+				} `json:"tree"`
+			} `json:"commit"`
+		}
+		if err := r.call("GET", "/repos/"+r.project+"/commits/"+url.PathEscape(r.ref), nil, &commit); err != nil {
+			return nil, err
+		}
+		r.base, r.tree = commit.SHA, commit.Commit.Tree.SHA
+	case repoGitLab:
+		var project struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		if err := r.call("GET", r.gitlabProject(), nil, &project); err != nil {
+			return nil, err
+		}
+		r.ref = cmp.Or(r.ref, project.DefaultBranch)
+		var commit struct {
+			ID string `json:"id"`
+		}
+		if err := r.call("GET", r.gitlabProject()+"/repository/commits/"+url.PathEscape(r.ref), nil, &commit); err != nil {
+			return nil, err
+		}
+		r.base = commit.ID
+	}
+	if r.base == "" {
+		return nil, fmt.Errorf("%s has no commit on %s", r.project, r.ref)
+	}
+	return r, nil
+}
+
+func (r *apiRepo) gitlabProject() string {
+	return "/projects/" + url.PathEscape(r.project)
+}
+
+// String names the repository and the commit the run started from.
+func (r *apiRepo) String() string {
+	return fmt.Sprintf("%s/%s@%s (%s)", r.host, r.project, r.ref, r.base[:min(len(r.base), 12)])
+}
+
+// request sends an authenticated API request; body, when not nil, is sent as JSON.
+func (r *apiRepo) request(method, p string, body any) (*http.Response, error) {
+	var rd io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rd = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, r.api+p, rd)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch r.kind {
+	case repoGitHub:
+		req.Header.Set("Authorization", "Bearer "+r.token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	case repoGitLab:
+		req.Header.Set("PRIVATE-TOKEN", r.token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, p, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 2000))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s: %s", method, p, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// call sends a JSON request and decodes the JSON reply into out, when not nil.
+func (r *apiRepo) call(method, p string, body, out any) error {
+	resp, err := r.request(method, p, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: invalid reply: %w", method, p, err)
+	}
+	return nil
+}
+
+// download unpacks the files of the base commit into fsys below root and records their
+// hashes, to tell the run's changes apart later.
+func (r *apiRepo) download(fsys projectFS, root string) error {
+	p := "/repos/" + r.project + "/tarball/" + r.base
+	if r.kind == repoGitLab {
+		p = r.gitlabProject() + "/repository/archive.tar.gz?sha=" + url.QueryEscape(r.base)
+	}
+	resp, err := r.request("GET", p, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return fmt.Errorf("archive of %s: %w", r.project, err)
+	}
+	tr := tar.NewReader(gz)
+	r.files = map[string]repoFile{}
+	var total int64
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("archive of %s: %w", r.project, err)
+		}
+		// Every entry is below one top-level directory named after the repository and commit.
+		_, rel, _ := strings.Cut(h.Name, "/")
+		rel = path.Clean(rel)
+		if rel == "." || rel == "" || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		full := filepath.Join(root, filepath.FromSlash(rel))
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err := fsys.MkdirAll(full, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if total += h.Size; total > repoArchiveLimit {
+				return fmt.Errorf("%s has more than %d MB of files; clone it instead", r.project, repoArchiveLimit>>20)
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return fmt.Errorf("archive of %s: %w", r.project, err)
+			}
+			perm := fs.FileMode(0o644)
+			if h.Mode&0o111 != 0 {
+				perm = 0o755
+			}
+			if err := fsys.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+				return err
+			}
+			if err := fsys.WriteFile(full, data, perm); err != nil {
+				return err
+			}
+			r.files[rel] = repoFile{sum: contentSum(data), executable: perm&0o111 != 0}
+		}
+		// Symlinks and submodules are left out; they cannot be edited through the API.
+	}
+}
+
+func contentSum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// repoChange is one file to create, update or delete in the submitted commit.
+type repoChange struct {
+	path       string
+	content    []byte
+	executable bool
+	deleted    bool
+}
+
+// changes compares the files in fsys below root with the base commit. zug's own .zug
+// directory is never submitted.
+func (r *apiRepo) changes(fsys projectFS, root string) ([]repoChange, error) {
+	var out []repoChange
+	seen := map[string]bool{}
+	err := walkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, name)
+		rel = filepath.ToSlash(rel)
+		if rel == zugDirName {
+			return fs.SkipDir
+		}
+		if d.IsDir() {
+			return nil
+		}
+		data, err := fsys.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		seen[rel] = true
+		c := repoChange{path: rel, content: data, executable: info.Mode()&0o111 != 0}
+		if base, ok := r.files[rel]; !ok || base.sum != contentSum(data) || base.executable != c.executable {
+			out = append(out, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for p := range r.files {
+		if !seen[p] {
+			out = append(out, repoChange{path: p, deleted: true})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].path < out[j].path })
+	return out, nil
+}
+
+// submit commits changes on a new branch and opens a pull request (GitHub) or merge
+// request (GitLab) against the base branch. It returns the request's URL.
+func (r *apiRepo) submit(changes []repoChange, branch, message, title, body string) (string, error) {
+	if r.kind == repoGitLab {
+		return r.submitGitLab(changes, branch, message, title, body)
+	}
+	return r.submitGitHub(changes, branch, message, title, body)
+}
+
+func (r *apiRepo) submitGitHub(changes []repoChange, branch, message, title, body string) (string, error) {
+	repo := "/repos/" + r.project
+	var entries []map[string]any
+	for _, c := range changes {
+		mode := "100644"
+		if c.executable {
+			mode = "100755"
+		}
+		entry := map[string]any{"path": c.path, "mode": mode, "type": "blob", "sha": nil}
+		if !c.deleted {
+			var blob struct {
+				SHA string `json:"sha"`
+			}
+			if err := r.call("POST", repo+"/git/blobs", map[string]string{"content": base64.StdEncoding.EncodeToString(c.content), "encoding": "base64"}, &blob); err != nil {
+				return "", err
+			}
+			entry["sha"] = blob.SHA
+		}
+		entries = append(entries, entry)
+	}
+	var tree, commit struct {
+		SHA string `json:"sha"`
+	}
+	if err := r.call("POST", repo+"/git/trees", map[string]any{"base_tree": r.tree, "tree": entries}, &tree); err != nil {
+		return "", err
+	}
+	if err := r.call("POST", repo+"/git/commits", map[string]any{"message": message, "tree": tree.SHA, "parents": []string{r.base}}, &commit); err != nil {
+		return "", err
+	}
+	if err := r.call("POST", repo+"/git/refs", map[string]string{"ref": "refs/heads/" + branch, "sha": commit.SHA}, nil); err != nil {
+		return "", err
+	}
+	return r.pullRequest(branch, title, body)
+}
+
+func (r *apiRepo) submitGitLab(changes []repoChange, branch, message, title, body string) (string, error) {
+	var actions []map[string]any
+	for _, c := range changes {
+		base, existed := r.files[c.path]
+		switch {
+		case c.deleted:
+			actions = append(actions, map[string]any{"action": "delete", "file_path": c.path})
+			continue
+		case !existed:
+			actions = append(actions, map[string]any{"action": "create", "file_path": c.path, "content": base64.StdEncoding.EncodeToString(c.content), "encoding": "base64"})
+		case base.sum != contentSum(c.content):
+			actions = append(actions, map[string]any{"action": "update", "file_path": c.path, "content": base64.StdEncoding.EncodeToString(c.content), "encoding": "base64"})
+		}
+		if c.executable != (existed && base.executable) {
+			actions = append(actions, map[string]any{"action": "chmod", "file_path": c.path, "execute_filemode": c.executable})
+		}
+	}
+	project := r.gitlabProject()
+	if err := r.call("POST", project+"/repository/commits", map[string]any{"branch": branch, "start_sha": r.base, "commit_message": message, "actions": actions}, nil); err != nil {
+		return "", err
+	}
+	return r.pullRequest(branch, title, body)
+}
+
+// pullRequest opens a pull request (GitHub) or merge request (GitLab) from branch into
+// r.ref and returns its URL.
+func (r *apiRepo) pullRequest(branch, title, body string) (string, error) {
+	var resp struct {
+		HTMLURL string `json:"html_url"` // GitHub
+		WebURL  string `json:"web_url"`  // GitLab
+	}
+	var err error
+	if r.kind == repoGitLab {
+		err = r.call("POST", r.gitlabProject()+"/merge_requests", map[string]string{"source_branch": branch, "target_branch": r.ref, "title": title, "description": body}, &resp)
+	} else {
+		err = r.call("POST", "/repos/"+r.project+"/pulls", map[string]string{"title": title, "head": branch, "base": r.ref, "body": body}, &resp)
+	}
+	if err != nil {
+		return "", fmt.Errorf("the changes are on branch %s, but opening the pull request failed: %w", branch, err)
+	}
+	return cmp.Or(resp.HTMLURL, resp.WebURL), nil
+}
+
+/*──────────────────────────────
+  The agent on an API repository
+  ─────────────────────────────*/
+
+// repoStateDir is the local directory of a repository's .zug state (sessions, memory),
+// ~/.zug/repos/<host>/<project>. It stands in for the project dir.
+func (r *apiRepo) stateDir() (string, error) {
+	dir, err := userZugDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "repos", r.host, filepath.FromSlash(r.project)), nil
+}
+
+// repoFS keeps the repository's files in memory. Only zug's own .zug directory is on the
+// local disk, below the state dir, so it outlives the run.
+type repoFS struct {
+	mem   *memFS
+	local string
+}
+
+func (f *repoFS) pick(name string) projectFS {
+	rel, err := filepath.Rel(f.local, name)
+	if err == nil && (rel == zugDirName || strings.HasPrefix(rel, zugDirName+string(filepath.Separator))) {
+		return osFS{}
+	}
+	return f.mem
+}
+
+func (f *repoFS) ReadFile(name string) ([]byte, error) { return f.pick(name).ReadFile(name) }
+func (f *repoFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return f.pick(name).WriteFile(name, data, perm)
+}
+func (f *repoFS) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	return f.pick(name).AppendFile(name, data, perm)
+}
+func (f *repoFS) Stat(name string) (fs.FileInfo, error) { return f.pick(name).Stat(name) }
+func (f *repoFS) MkdirAll(name string, perm fs.FileMode) error {
+	return f.pick(name).MkdirAll(name, perm)
+}
+func (f *repoFS) RemoveAll(name string) error { return f.pick(name).RemoveAll(name) }
+func (f *repoFS) Chmod(name string, mode fs.FileMode) error {
+	return f.pick(name).Chmod(name, mode)
+}
+
+// ReadDir of the project root lists .zug as well, when it exists on disk.
+func (f *repoFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := f.pick(name).ReadDir(name)
+	if err != nil || filepath.Clean(name) != filepath.Clean(f.local) {
+		return entries, err
+	}
+	if info, err := os.Stat(filepath.Join(f.local, zugDirName)); err == nil {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	}
+	return entries, nil
+}
+
+// useRepo downloads r and points the file tools at it. Shell commands, and with them the
+// build, lint and test checks, are not available.
+func (a *AutonomousCodingAgent) useRepo(r *apiRepo) error {
+	mem := newMemFS(a.projectDir)
+	if err := r.download(mem, a.projectDir); err != nil {
+		return err
+	}
+	a.repo = r
+	a.fs = a.audited(&repoFS{mem: mem, local: a.projectDir})
+	log.Printf("[agent] 📦 Working on %s through the API: %d file(s) loaded, no local clone.\n", r, len(r.files))
+	return nil
+}
+
+// repoNote tells the model how the project is reached, for the system prompt.
+func (a *AutonomousCodingAgent) repoNote() string {
+	if a.repo == nil {
+		return ""
+	}
+	return fmt.Sprintf("The project is the repository %s, edited through its API without a local clone. "+
+		"There is no shell: commands cannot be run and nothing is built or tested, so read the surrounding code carefully and keep changes small and safe. "+
+		"When you finish, the changes are submitted for review as a pull request.", a.repo.project)
+}
+
+// submitRepo opens a pull request with the changes of a successful run. The changes of
+// a run that failed are not submitted but saved as a patch in the state dir.
+func (a *AutonomousCodingAgent) submitRepo(task string, runErr error) error {
+	changes, err := a.repo.changes(a.fs, a.projectDir)
+	if err != nil {
+		return fmt.Errorf("cannot collect the changes: %w", err)
+	}
+	if len(changes) == 0 {
+		log.Println("[agent] 📦 No changes to submit.")
+		return runErr
+	}
+	if runErr != nil {
+		patch := filepath.Join(a.projectDir, zugDirName, "unsubmitted.patch")
+		if _, err := a.zugDir(); err == nil && os.WriteFile(patch, []byte(a.changesDiff()), 0o644) == nil {
+			log.Printf("[agent] 📦 Not submitting %d changed file(s) since the task did not complete; they are saved in %s.\n", len(changes), patch)
+		}
+		return runErr
+	}
+
+	title, body := "zug: "+firstLine(task), "Task:\n\n"+task+"\n"
+	if d, err := a.describeChanges(task); err != nil {
+		log.Printf("[agent] ⚠️ Could not describe the changes: %v\n", err)
+	} else {
+		title, body = d.Title, d.markdown(a.netChanges())
+	}
+	body += "\n_The changes were made through the API, without a local clone; no build or tests were run._\n"
+	branch := "zug/" + time.Now().Format("20060102-150405")
+	url, err := a.repo.submit(changes, branch, title, title, body)
+	if err != nil {
+		return fmt.Errorf("cannot submit the changes: %w", err)
+	}
+	log.Printf("[agent] 📦 Submitted %d changed file(s) on %s: %s\n", len(changes), branch, url)
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseRepoSpec(t *testing.T) {
+	t.Setenv("GITHUB_API_URL", "https://ghe.example.com/api/v3")
+	t.Setenv("GITLAB_API_URL", "https://git.example.com/api/v4")
+	for _, tc := range []struct{ spec, kind, host, project, ref string }{
+		{"github:acme/api", repoGitHub, "github.com", "acme/api", ""},
+		{"github:acme/api@release/1.2", repoGitHub, "github.com", "acme/api", "release/1.2"},
+		{"https://github.com/acme/api.git", repoGitHub, "github.com", "acme/api", ""},
+		{"gitlab:group/sub/app@dev", repoGitLab, "gitlab.com", "group/sub/app", "dev"},
+		{"git.example.com/team/app", repoGitLab, "git.example.com", "team/app", ""},
+		{"gitlab:https://lab.example.org/team/app", repoGitLab, "lab.example.org", "team/app", ""},
+		{"gitlab:git@lab.example.org:team/app.git", repoGitLab, "lab.example.org", "team/app", ""},
+		{"https://ghe.example.com/acme/api", repoGitHub, "ghe.example.com", "acme/api", ""},
+		{"git@github.com:acme/api.git", repoGitHub, "github.com", "acme/api", ""},
+	} {
+		r, err := parseRepoSpec(tc.spec)
+		if err != nil {
+			t.Errorf("%s: %v", tc.spec, err)
+			continue
+		}
+		if r.kind != tc.kind || r.host != tc.host || r.project != tc.project || r.ref != tc.ref {
+			t.Errorf("%s = %s %s %s %q", tc.spec, r.kind, r.host, r.project, r.ref)
+		}
+	}
+	for _, bad := range []string{"github:acme", "github:a/b/c", "https://github.com/", "https://bitbucket.org/acme/api", "gitub.com/acme/api"} {
+		if _, err := parseRepoSpec(bad); err == nil {
+			t.Errorf("%s was accepted", bad)
+		}
+	}
+}
+
+func TestGitLabTokenGoesToConfiguredHostsOnly(t *testing.T) {
+	t.Setenv("GITLAB_TOKEN", "glpat-secret")
+	t.Setenv("GITLAB_API_URL", "")
+	r, err := parseRepoSpec("gitlab:https://lab.example.org/team/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.connect(); err == nil || r.token != "" {
+		t.Errorf("the token would go to %s (err = %v)", r.host, err)
+	}
+	t.Setenv("GITLAB_API_URL", "https://lab.example.org/api/v4")
+	if err := r.connect(); err != nil || r.api != "https://lab.example.org/api/v4" {
+		t.Errorf("with GITLAB_API_URL: api = %q, err = %v", r.api, err)
+	}
+	r, _ = parseRepoSpec("gitlab:team/app")
+	t.Setenv("GITLAB_API_URL", "")
+	if err := r.connect(); err != nil || r.api != "https://gitlab.com/api/v4" {
+		t.Errorf("gitlab.com: api = %q, err = %v", r.api, err)
+	}
+}
+
+// fakeGitHub serves the parts of the GitHub API used by --repo and records the writes.
+func fakeGitHub(t *testing.T, files map[string]string) (*httptest.Server, func() map[string][]byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		mode := int64(0o644)
+		if strings.HasSuffix(name, ".sh") {
+			mode = 0o755
+		}
+		tw.WriteHeader(&tar.Header{Name: "acme-api-abc123/" + name, Mode: mode, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+
+	var mu sync.Mutex
+	writes := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gh-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		if r.Method == "POST" {
+			writes[r.URL.Path] = append(writes[r.URL.Path], append(body, '\n')...)
+		}
+		mu.Unlock()
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/acme/api":
+			w.Write([]byte(`{"default_branch": "main"}`))
+		case "GET /repos/acme/api/commits/main":
+			w.Write([]byte(`{"sha": "abc123", "commit": {"tree": {"sha": "tree1"}}}`))
+		case "GET /repos/acme/api/tarball/abc123":
+			w.Write(buf.Bytes())
+		case "POST /repos/acme/api/git/blobs":
+			w.Write([]byte(`{"sha": "blob1"}`))
+		case "POST /repos/acme/api/git/trees":
+			w.Write([]byte(`{"sha": "tree2"}`))
+		case "POST /repos/acme/api/git/commits":
+			w.Write([]byte(`{"sha": "commit2"}`))
+		case "POST /repos/acme/api/git/refs":
+			w.Write([]byte(`{}`))
+		case "POST /repos/acme/api/pulls":
+			w.Write([]byte(`{"html_url": "https://github.com/acme/api/pull/7"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("GITHUB_API_URL", srv.URL)
+	t.Setenv("GITHUB_TOKEN", "gh-token")
+	return srv, func() map[string][]byte {
+		mu.Lock()
+		defer mu.Unlock()
+		return writes
+	}
+}
+
+func TestRepoChangesAreSubmittedAsPullRequest(t *testing.T) {
+	_, writes := fakeGitHub(t, map[string]string{"README.md": "# API\n", "build.sh": "make\n", "old.txt": "old\n"})
+	a, _ := newTestAgent(t, nil)
+	r, err := openRepo("github:acme/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.useRepo(r); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAgentFile(t, a, "README.md"); got != "# API\n" {
+		t.Fatalf("README.md = %q", got)
+	}
+	if a.toolAllowed("run_shell") || len(a.checkTargets()) != 0 {
+		t.Error("shell commands are offered without a local clone")
+	}
+
+	if _, err := a.createFile("README.md", "# API\n\nUsage.\n", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.fs.RemoveAll(filepath.Join(a.projectDir, "old.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.remember("the API is versioned"); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := r.changes(a.fs, a.projectDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].path != "README.md" || !changes[1].deleted {
+		t.Fatalf("changes = %+v, want README.md and the deletion of old.txt", changes)
+	}
+
+	url, err := r.submit(changes, "zug/test", "Document usage", "Document usage", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "https://github.com/acme/api/pull/7" {
+		t.Errorf("url = %q", url)
+	}
+	var tree struct {
+		BaseTree string           `json:"base_tree"`
+		Tree     []map[string]any `json:"tree"`
+	}
+	if err := json.Unmarshal(writes()["/repos/acme/api/git/trees"], &tree); err != nil {
+		t.Fatal(err)
+	}
+	if tree.BaseTree != "tree1" || len(tree.Tree) != 2 || tree.Tree[0]["sha"] != "blob1" || tree.Tree[1]["sha"] != nil {
+		t.Errorf("tree = %+v", tree)
+	}
+	assertContains(t, string(writes()["/repos/acme/api/git/refs"]), `"ref":"refs/heads/zug/test"`)
+	assertContains(t, string(writes()["/repos/acme/api/pulls"]), `"base":"main"`)
+}
+
+func readAgentFile(t *testing.T, a *AutonomousCodingAgent, rel string) string {
+	t.Helper()
+	raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, rel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  Oversized tool results (read_result tool)
+  ─────────────────────────────*/
+
+const (
+	maxToolResult    = 16000 // characters of a tool result put into the conversation
+	resultTailLength = 4000  // of those, taken from the end, where test failures and errors usually are
+	resultRetention  = 7 * 24 * time.Hour
+)
+
+var resultIDPattern = regexp.MustCompile(`^[0-9]{8}-[0-9]{6}-[0-9]+$`)
+
+// capToolResult returns result unchanged when it is small enough. Otherwise the full text is
+// stored under .zug/results/ and the model gets its beginning and end plus a pointer to
+// read_result for the rest.
+func (a *AutonomousCodingAgent) capToolResult(result string) string {
+	if len(result) <= maxToolResult {
+		return result
+	}
+	id, err := a.storeResult(result)
+	if err != nil {
+		fmt.Printf("⚠️ Could not store oversized tool result: %v\n", err)
+		id = ""
+	}
+	headEnd := validCut(result, maxToolResult-resultTailLength)
+	if nl := strings.LastIndexByte(result[:headEnd], '\n'); nl > headEnd/2 {
+		headEnd = nl + 1
+	}
+	tailStart := validCut(result, len(result)-resultTailLength)
+	if nl := strings.IndexByte(result[tailStart:], '\n'); nl >= 0 && nl < resultTailLength/2 {
+		tailStart += nl + 1
+	}
+	note := fmt.Sprintf("\n\n... [%d characters omitted", tailStart-headEnd)
+	if id != "" {
+		note += fmt.Sprintf("; the full result is stored as %q: call read_result with id %q and offset %d to read on from here", id, id, headEnd)
+	}
+	note += "] ...\n\n"
+	return result[:headEnd] + note + result[tailStart:]
+}
+
+// validCut moves i back to the start of a UTF-8 character.
+func validCut(s string, i int) int {
+	for i > 0 && i < len(s) && s[i]&0xC0 == 0x80 {
+		i--
+	}
+	return i
+}
+
+func (a *AutonomousCodingAgent) resultsDir() (string, error) {
+	dir, err := a.zugDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "results")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// storeResult writes result to a new file and returns its id. Results older than
+// resultRetention are removed on the way.
+func (a *AutonomousCodingAgent) storeResult(result string) (string, error) {
+	dir, err := a.resultsDir()
+	if err != nil {
+		return "", err
+	}
+	if a.resultSeq == 0 {
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > resultRetention {
+				os.Remove(filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	a.resultSeq++
+	id := fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), a.resultSeq)
+	if err := os.WriteFile(filepath.Join(dir, id+".txt"), []byte(result), 0o644); err != nil {
+		return "", fmt.Errorf("cannot write result %s: %w", id, err)
+	}
+	return id, nil
+}
+
+// readResult returns up to maxToolResult characters of a stored result starting at offset.
+func (a *AutonomousCodingAgent) readResult(id string, offset int) (string, error) {
+	if !resultIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid result id %q; use the id quoted in the truncated tool result", id)
+	}
+	dir, err := a.resultsDir()
+	if err != nil {
+		return "", err
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, id+".txt"))
+	if err != nil {
+		return "", fmt.Errorf("no stored result %q (results are kept for %s): %w", id, resultRetention, err)
+	}
+	full := string(raw)
+	if offset < 0 || offset >= len(full) {
+		return "", fmt.Errorf("offset %d is outside result %s, which has %d characters", offset, id, len(full))
+	}
+	start := validCut(full, offset)
+	end := validCut(full, min(start+maxToolResult, len(full)))
+	if end <= start {
+		end = len(full)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[result %s, characters %d-%d of %d]\n", id, start, end, len(full))
+	sb.WriteString(full[start:end])
+	if end < len(full) {
+		fmt.Fprintf(&sb, "\n[continue with offset %d]", end)
+	} else {
+		sb.WriteString("\n[end of result]")
+	}
+	return sb.String(), nil
+}
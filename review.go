@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Reviewer / critic pass
+  ─────────────────────────────*/
+
+const reviewApproved = "LGTM"
+
+// reviewPrompt instructs the reviewer model; it never gets tools, only the task and the diff.
+func reviewPrompt() openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: `You are a strict senior code reviewer. You receive a task statement and the unified diff produced to solve it. Check the diff for bugs, unhandled errors, style violations, missing tests, and scope creep (changes the task did not ask for). Reply with a short numbered list of concrete findings, each naming the file and what to change. If there is nothing worth fixing, reply with exactly "` + reviewApproved + `".`,
+	}
+}
+
+// reviewChanges runs a separate review prompt over the diff of the run and returns
+// a follow-up instruction for the agent, or "" when the reviewer approves.
+func (a *AutonomousCodingAgent) reviewChanges(task string) (string, error) {
+	diff := a.changesDiff()
+	if diff == "" {
+		log.Println("[agent] Review skipped: no file changes were made.")
+		return "", nil
+	}
+	log.Printf("[agent] 🔎 Running reviewer pass over %d bytes of diff.\n", len(diff))
+
+	req, err := a.chatRequest([]openai.ChatCompletionMessage{
+		reviewPrompt(),
+		{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf("Task:\n%s\n\nDiff:\n%s", task, diff)},
+	}, phaseReview, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := a.createChatCompletion(req)
+	if err != nil {
+		return "", fmt.Errorf("review request failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("received an empty Choices array from OpenAI during review")
+	}
+	findings := strings.TrimSpace(resp.Choices[0].Message.Content)
+	fmt.Printf("🔎 Review Findings:\n%s\n\n", findings)
+	if findings == "" || strings.HasPrefix(strings.ToUpper(findings), reviewApproved) {
+		return "", nil
+	}
+	return fmt.Sprintf("A code review of your changes raised the following findings. Address them, keeping the changes within the scope of the original task:\n%s", findings), nil
+}
+
+// reviewFollowUp runs the reviewer at most once per feedback loop. It returns the
+// next instruction and true when another fix iteration is warranted.
+func (a *AutonomousCodingAgent) reviewFollowUp(task string, reviewed *bool) (string, bool) {
+	if !a.review || *reviewed {
+		return "", false
+	}
+	*reviewed = true
+	next, err := a.reviewChanges(task)
+	if err != nil {
+		log.Printf("[agent] ⚠️ Reviewer pass failed: %v. Finishing without review.\n", err)
+		return "", false
+	}
+	if next == "" {
+		log.Println("[agent] ✅ Reviewer approved the changes.")
+		return "", false
+	}
+	log.Println("[agent] 🔁 Reviewer raised findings; running one more fix iteration.")
+	return next, true
+}
+
+/*──────────────────────────────
+  zug review
+  ─────────────────────────────*/
+
+const reviewDiffLimit = 60000 // bytes of diff put in the prompt; the rest is read with tools
+
+// Finding severities, most severe first.
+var findingSeverities = []string{"critical", "major", "minor", "nit"}
+
+// reviewFinding is one problem the reviewer found in the diff.
+type reviewFinding struct {
+	Severity   string `json:"severity" enum:"critical,major,minor,nit" description:"critical: breaks behavior or security; major: a bug or missing handling; minor: maintainability; nit: style"`
+	File       string `json:"file" description:"Path relative to the project root"`
+	Line       int    `json:"line" description:"Line in the new version of the file, 0 if the finding is about the whole file"`
+	Message    string `json:"message" description:"What is wrong and why it matters"`
+	Suggestion string `json:"suggestion" description:"Concrete change that fixes it"`
+}
+
+type reviewReport struct {
+	Findings []reviewFinding `json:"findings"`
+}
+
+// diffReviewPrompt is the system prompt of zug review. Unlike the reviewer pass of a
+// run, it can read the project to check the diff against the surrounding code.
+func diffReviewPrompt() openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: `You are a strict senior code reviewer. You receive a unified diff of a branch. Use 'read_file', 'search_files' and 'list_files' to read the changed files in full and the code they call or are called by (paths relative to the project root) before judging; do not report what the surrounding code already handles. Look for bugs, unhandled errors, security problems, race conditions, missing tests, and inconsistencies with the conventions of the codebase. Report only findings about the changed lines or what they break; every finding names the file and the line in the new version of the file. When you are done, reply with the findings as JSON: {"findings": [{"severity": "critical|major|minor|nit", "file": "...", "line": 0, "message": "...", "suggestion": "..."}]}, and an empty list if the diff is fine.`,
+	}
+}
+
+func reviewCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	base := fs.String("base", "main", "branch or commit to review against; the diff runs from its merge base to the working tree")
+	format := fs.String("format", "text", "output format: text, json or sarif")
+	out := fs.String("o", "", "write the findings to this file instead of standard output")
+	failOn := fs.String("fail-on", "", "exit with status 1 if there is a finding of this severity or worse ("+strings.Join(findingSeverities, ", ")+")")
+	return func() {
+		if *format != "text" && *format != "json" && *format != "sarif" {
+			log.Fatalf("❌ unknown -format %q (use text, json or sarif)", *format)
+		}
+		if *failOn != "" && severityRank(*failOn) < 0 {
+			log.Fatalf("❌ unknown -fail-on severity %q (use %s)", *failOn, strings.Join(findingSeverities, ", "))
+		}
+		mergeBase, err := gitCmd(cf.dir, "merge-base", *base, "HEAD")
+		if err != nil {
+			log.Fatalf("❌ Cannot find where HEAD branched off %s: %v", *base, err)
+		}
+		diff, err := gitCmd(cf.dir, "diff", "--no-color", mergeBase)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if diff == "" {
+			log.Printf("[agent] No changes against %s; nothing to review.\n", *base)
+		}
+
+		agent := cf.newAgent(arg(fs.Args(), 0))
+		var findings []reviewFinding
+		if diff != "" {
+			findings, err = agent.reviewDiff(diff)
+			if err != nil {
+				log.Fatalf("❌ Review failed: %v", err)
+			}
+		}
+
+		var rendered string
+		switch *format {
+		case "json":
+			raw, _ := json.MarshalIndent(reviewReport{Findings: findings}, "", "  ")
+			rendered = string(raw) + "\n"
+		case "sarif":
+			raw, _ := json.MarshalIndent(sarifLog(findings), "", "  ")
+			rendered = string(raw) + "\n"
+		default:
+			rendered = findingsText(findings)
+		}
+		if *out == "" {
+			fmt.Print(rendered)
+		} else if err := os.WriteFile(*out, []byte(rendered), 0o644); err != nil {
+			log.Fatalf("❌ %v", err)
+		} else {
+			log.Printf("[agent] 🔎 %d finding(s) written to %s.\n", len(findings), *out)
+		}
+		if *failOn != "" {
+			for _, f := range findings {
+				if r := severityRank(f.Severity); r >= 0 && r <= severityRank(*failOn) {
+					os.Exit(1)
+				}
+			}
+		}
+	}
+}
+
+// reviewDiff lets the model explore the project read-only and report its findings on diff.
+func (a *AutonomousCodingAgent) reviewDiff(diff string) ([]reviewFinding, error) {
+	a.readOnly = true
+	a.prompt = diffReviewPrompt()
+	if capabilitiesFor(a.model).structuredOutputs {
+		format, err := jsonSchemaFormat("review_findings", reviewReport{})
+		if err != nil {
+			return nil, err
+		}
+		a.responseFormat = format
+	}
+	if len(diff) > reviewDiffLimit {
+		diff = diff[:validCut(diff, reviewDiffLimit)] + "\n[... diff truncated; read the remaining changed files with the tools]"
+	}
+	log.Printf("[agent] 🔎 Reviewing %d bytes of diff.\n", len(diff))
+	reply, err := a.chat("Review this diff:\n"+diff, phaseFindings)
+	if err != nil {
+		return nil, err
+	}
+	var report reviewReport
+	if err := json.Unmarshal([]byte(stripCodeFence(reply)), &report); err != nil {
+		return nil, fmt.Errorf("the findings are not valid JSON: %w", err)
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return severityRank(report.Findings[i].Severity) < severityRank(report.Findings[j].Severity)
+	})
+	return report.Findings, nil
+}
+
+// severityRank orders severities from 0 (critical); unknown ones rank -1.
+func severityRank(s string) int {
+	return slices.Index(findingSeverities, strings.ToLower(s))
+}
+
+func findingsText(findings []reviewFinding) string {
+	if len(findings) == 0 {
+		return "✅ No findings.\n"
+	}
+	var sb strings.Builder
+	for _, f := range findings {
+		loc := f.File
+		if f.Line > 0 {
+			loc += ":" + strconv.Itoa(f.Line)
+		}
+		fmt.Fprintf(&sb, "%s [%s] %s\n", loc, f.Severity, f.Message)
+		if f.Suggestion != "" {
+			fmt.Fprintf(&sb, "    ↪ %s\n", strings.ReplaceAll(f.Suggestion, "\n", "\n      "))
+		}
+	}
+	return sb.String()
+}
+
+// sarifLog renders the findings as SARIF 2.1.0, which code scanning dashboards import.
+func sarifLog(findings []reviewFinding) map[string]any {
+	levels := map[string]string{"critical": "error", "major": "error", "minor": "warning", "nit": "note"}
+	results := []map[string]any{}
+	for _, f := range findings {
+		level := levels[strings.ToLower(f.Severity)]
+		if level == "" {
+			level = "warning"
+		}
+		text := f.Message
+		if f.Suggestion != "" {
+			text += "\nSuggestion: " + f.Suggestion
+		}
+		location := map[string]any{"artifactLocation": map[string]any{"uri": filepath.ToSlash(f.File)}}
+		if f.Line > 0 {
+			location["region"] = map[string]any{"startLine": f.Line}
+		}
+		results = append(results, map[string]any{
+			"ruleId":     "zug/" + strings.ToLower(f.Severity),
+			"level":      level,
+			"message":    map[string]any{"text": text},
+			"locations":  []map[string]any{{"physicalLocation": location}},
+			"properties": map[string]any{"severity": f.Severity},
+		})
+	}
+	return map[string]any{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []map[string]any{{
+			"tool":    map[string]any{"driver": map[string]any{"name": "zug"}},
+			"results": results,
+		}},
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+/*──────────────────────────────
+  Sampling parameters
+  ─────────────────────────────*/
+
+// Phases of a run that use different sampling defaults.
+const (
+	phaseTools    = "tools"    // coding turns: deterministic tool use
+	phasePlan     = "plan"     // planning benefits from a little more variety
+	phaseAsk      = "ask"      // answering questions about the code
+	phaseReview   = "review"   // reviewer pass over the diff
+	phaseSummary  = "summary"  // structured run outcome
+	phaseCommit   = "commit"   // commit message for a diff
+	phaseFindings = "findings" // zug review: exploring a diff and reporting findings
+)
+
+type samplingParams struct {
+	temperature float32
+	topP        float32 // 0 = the API default
+	maxTokens   int
+}
+
+// phaseDefaults apply unless overridden with --temperature, --top-p or --max-output-tokens.
+var phaseDefaults = map[string]samplingParams{
+	phaseTools:    {temperature: 0.1, maxTokens: 4096},
+	phasePlan:     {temperature: 0.4, maxTokens: 4096},
+	phaseAsk:      {temperature: 0.2, maxTokens: 2048},
+	phaseReview:   {temperature: 0.1, maxTokens: 1500},
+	phaseSummary:  {temperature: 0, maxTokens: 1000},
+	phaseCommit:   {temperature: 0.2, maxTokens: 500},
+	phaseFindings: {temperature: 0.1, maxTokens: 4096},
+}
+
+// samplingFlags are the command-line overrides; they apply to every phase.
+type samplingFlags struct {
+	temperature     optionalFloat
+	topP            optionalFloat
+	maxOutputTokens int
+}
+
+func (f samplingFlags) validate() error {
+	if f.temperature.set && (f.temperature.value < 0 || f.temperature.value > 2) {
+		return fmt.Errorf("--temperature must be between 0 and 2, got %g", f.temperature.value)
+	}
+	if f.topP.set && (f.topP.value <= 0 || f.topP.value > 1) {
+		return fmt.Errorf("--top-p must be greater than 0 and at most 1, got %g", f.topP.value)
+	}
+	if f.maxOutputTokens < 0 {
+		return fmt.Errorf("--max-output-tokens must not be negative, got %d", f.maxOutputTokens)
+	}
+	return nil
+}
+
+// sampling returns the parameters for phase with the command-line overrides applied.
+func (a *AutonomousCodingAgent) sampling(phase string) samplingParams {
+	p := phaseDefaults[phase]
+	if a.samplingFlags.temperature.set {
+		p.temperature = float32(a.samplingFlags.temperature.value)
+	}
+	if a.samplingFlags.topP.set {
+		p.topP = float32(a.samplingFlags.topP.value)
+	}
+	if a.samplingFlags.maxOutputTokens > 0 {
+		p.maxTokens = a.samplingFlags.maxOutputTokens
+	}
+	return p
+}
+
+// optionalFloat is a float flag that remembers whether it was given, so an explicit 0 can
+// be told apart from the default.
+type optionalFloat struct {
+	value float64
+	set   bool
+}
+
+func (f *optionalFloat) String() string {
+	if !f.set {
+		return ""
+	}
+	return strconv.FormatFloat(f.value, 'g', -1, 64)
+}
+
+func (f *optionalFloat) Set(v string) error {
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return err
+	}
+	f.value, f.set = n, true
+	return nil
+}
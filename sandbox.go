@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+/*──────────────────────────────
+  Shell sandbox
+  ─────────────────────────────*/
+
+// Isolation modes for the commands the agent runs (run_shell and the build, lint and
+// test checks), selected with --sandbox. bubblewrap is the only backend: without bwrap
+// and unprivileged user namespaces, --sandbox ns is refused rather than weakened.
+const (
+	sandboxOff = "off" // commands run with the user's full access (default)
+	sandboxNS  = "ns"  // bubblewrap: Linux namespaces, project dir writable, network per network:
+)
+
+// sensitiveHomeDirs are hidden from sandboxed commands, so a command cannot read
+// credentials even though the rest of the home directory stays readable for toolchains.
+var sensitiveHomeDirs = []string{
+	".ssh", ".gnupg", ".aws", ".azure", ".kube", ".docker", ".netrc",
+	".config/gcloud", ".config/gh", ".password-store", ".zug",
+}
+
+// validSandbox checks a --sandbox value and that its backend can run on this machine.
+func validSandbox(mode string) error {
+	switch mode {
+	case "", sandboxOff:
+		return nil
+	case sandboxNS:
+		if runtime.GOOS != "linux" {
+			return fmt.Errorf("--sandbox %s needs Linux", sandboxNS)
+		}
+		if _, err := exec.LookPath("bwrap"); err != nil {
+			return fmt.Errorf("--sandbox %s needs bubblewrap, its only backend (install the bubblewrap package)", sandboxNS)
+		}
+		if out, err := exec.Command("bwrap", "--ro-bind", "/", "/", "--unshare-all", "true").CombinedOutput(); err != nil {
+			return fmt.Errorf("bubblewrap cannot create namespaces (are unprivileged user namespaces enabled?): %s", strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown sandbox %q (use %s or %s)", mode, sandboxOff, sandboxNS)
+}
+
+// shellCommand returns the command that runs cmd with bash in dir, inside the sandbox
+// when one is enabled.
+func (a *AutonomousCodingAgent) shellCommand(dir, cmd string) (*exec.Cmd, error) {
+	if a.sandbox != sandboxNS {
+		c := exec.Command("bash", "-c", cmd)
+		c.Dir = dir
+		return c, nil
+	}
+	policy := a.networkPolicy()
+	args := bwrapArgs(dir, os.Getenv("HOME"), policy == netAllow)
+	if policy != netAllowlist {
+		return exec.Command("bwrap", append(args, "--", "bash", "-c", cmd)...), nil
+	}
+	p, err := a.allowlistProxy()
+	if err != nil {
+		return nil, err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("cannot locate the zug binary for the network bridge: %w", err)
+	}
+	args = append(args, "--ro-bind", exe, exe, "--bind", p.dir, p.dir, "--", exe, netBridgeCommand, p.socket, cmd)
+	return exec.Command("bwrap", args...), nil
+}
+
+// bwrapArgs mounts the whole filesystem read-only with only dir writable, gives the
+// command private /tmp and cache directories and hides credentials. Unless shareNet is
+// set, the command gets a network namespace of its own with nothing but loopback.
+func bwrapArgs(dir, home string, shareNet bool) []string {
+	args := []string{
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+	}
+	if home != "" {
+		// Build tools need a writable cache; this one is thrown away after each command.
+		if info, err := os.Stat(filepath.Join(home, ".cache")); err == nil && info.IsDir() {
+			args = append(args, "--tmpfs", filepath.Join(home, ".cache"))
+		}
+		for _, d := range sensitiveHomeDirs {
+			p := filepath.Join(home, d)
+			if info, err := os.Stat(p); err == nil && info.IsDir() {
+				args = append(args, "--tmpfs", p)
+			} else if err == nil {
+				args = append(args, "--ro-bind", "/dev/null", p)
+			}
+		}
+	}
+	args = append(args,
+		"--bind", dir, dir,
+		"--unshare-all",
+		"--die-with-parent",
+		"--new-session",
+		"--chdir", dir,
+	)
+	if shareNet {
+		args = append(args, "--share-net")
+	}
+	return args
+}
+
+// logSandbox tells the user how commands are isolated.
+func (a *AutonomousCodingAgent) logSandbox() {
+	if a.sandbox != sandboxNS {
+		return
+	}
+	network := map[string]string{
+		netAllow:     "network allowed",
+		netDeny:      "no network",
+		netAllowlist: "network limited to network.allow",
+	}[a.networkPolicy()]
+	log.Printf("[agent] 🔒 Shell commands run in a bubblewrap sandbox: only %s is writable, %s.\n", a.projectDir, network)
+}
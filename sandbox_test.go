@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBwrapArgs(t *testing.T) {
+	home := t.TempDir()
+	os.Mkdir(filepath.Join(home, ".ssh"), 0o700)
+	os.WriteFile(filepath.Join(home, ".netrc"), nil, 0o600)
+
+	args := strings.Join(bwrapArgs("/work/project", home, false), " ")
+	for _, want := range []string{
+		"--ro-bind / /",
+		"--tmpfs " + filepath.Join(home, ".ssh"),
+		"--ro-bind /dev/null " + filepath.Join(home, ".netrc"),
+		"--bind /work/project /work/project",
+		"--unshare-all",
+		"--chdir /work/project",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("bwrap arguments lack %q: %s", want, args)
+		}
+	}
+	if strings.Contains(args, "--share-net") {
+		t.Errorf("bwrap arguments keep the network: %s", args)
+	}
+	if strings.Contains(args, ".aws") {
+		t.Errorf("bwrap arguments mask a directory that does not exist: %s", args)
+	}
+}
+
+func TestSandboxConfinesShell(t *testing.T) {
+	if err := validSandbox(sandboxNS); err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	a := &AutonomousCodingAgent{projectDir: dir, sandbox: sandboxNS}
+	if _, err := a.execShell("echo ok > inside.txt"); err != nil {
+		t.Errorf("writing inside the project failed: %v", err)
+	}
+	wd, _ := os.Getwd() // not below /tmp, which the sandbox replaces with its own
+	outside := filepath.Join(wd, "zug-sandbox-escape")
+	if _, err := a.execShell("echo no > " + shellQuote(outside)); err == nil {
+		os.Remove(outside)
+		t.Error("writing outside the project succeeded")
+	}
+	if _, err := exec.LookPath("curl"); err == nil {
+		if _, err := a.execShell("curl -sS --max-time 5 https://example.com"); err == nil {
+			t.Error("network access succeeded")
+		}
+	}
+}
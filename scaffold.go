@@ -0,0 +1,355 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  zug new (project scaffolding)
+  ─────────────────────────────*/
+
+const templatesDirName = "templates" // in ~/.zug, one directory per user template
+
+// projectTemplate is the starting structure of a new project. Paths and contents may use
+// the placeholders {{name}} (the project name), {{package}} (the name as an identifier)
+// and {{year}}.
+type projectTemplate struct {
+	summary string
+	files   map[string]string
+}
+
+var validProjectName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]*$`)
+
+// builtinTemplates are the templates shipped with zug. Each one builds and passes its
+// tests as generated, so the agent starts from a working project.
+var builtinTemplates = map[string]projectTemplate{
+	"go-cli": {
+		summary: "Go command-line tool: flag parsing, run() separated from main for testing",
+		files: map[string]string{
+			"go.mod": "module {{name}}\n\ngo 1.22\n",
+			"main.go": `package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "{{name}}:", err)
+		os.Exit(1)
+	}
+}
+
+// run is the whole program, apart from the process exit, so tests can call it.
+func run(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("{{name}}", flag.ContinueOnError)
+	name := fs.String("name", "world", "who to greet")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(stdout, "Hello, %s!\n", *name)
+	return err
+}
+`,
+			"main_test.go": `package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"-name", "test"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "Hello, test!\n" {
+		t.Errorf("output = %q", got)
+	}
+}
+`,
+			"README.md":  "# {{name}}\n\n```bash\ngo build\n./{{name}} -help\n```\n",
+			".gitignore": "/{{name}}\n",
+		},
+	},
+	"go-lib": {
+		summary: "Go library package with a test and an example",
+		files: map[string]string{
+			"go.mod": "module {{name}}\n\ngo 1.22\n",
+			"{{package}}.go": `// Package {{package}} ...
+package {{package}}
+
+// Greet returns a greeting for name.
+func Greet(name string) string {
+	return "Hello, " + name + "!"
+}
+`,
+			"{{package}}_test.go": `package {{package}}
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGreet(t *testing.T) {
+	if got := Greet("test"); got != "Hello, test!" {
+		t.Errorf("Greet = %q", got)
+	}
+}
+
+func ExampleGreet() {
+	fmt.Println(Greet("world"))
+	// Output: Hello, world!
+}
+`,
+			"README.md": "# {{name}}\n\n```bash\ngo get {{name}}\n```\n",
+		},
+	},
+	"python-cli": {
+		summary: "Python command-line tool: src layout, pyproject.toml with a console script, pytest",
+		files: map[string]string{
+			"pyproject.toml": `[build-system]
+requires = ["setuptools>=61"]
+build-backend = "setuptools.build_meta"
+
+[project]
+name = "{{name}}"
+version = "0.1.0"
+requires-python = ">=3.9"
+
+[project.scripts]
+{{name}} = "{{package}}.cli:main"
+
+[tool.pytest.ini_options]
+pythonpath = ["src"]
+`,
+			"src/{{package}}/__init__.py": "",
+			"src/{{package}}/__main__.py": "from .cli import main\n\nraise SystemExit(main())\n",
+			"src/{{package}}/cli.py": `import argparse
+
+
+def main(argv=None):
+    parser = argparse.ArgumentParser(prog="{{name}}")
+    parser.add_argument("--name", default="world", help="who to greet")
+    args = parser.parse_args(argv)
+    print(f"Hello, {args.name}!")
+    return 0
+`,
+			"tests/test_cli.py": `from {{package}}.cli import main
+
+
+def test_main(capsys):
+    assert main(["--name", "test"]) == 0
+    assert capsys.readouterr().out == "Hello, test!\n"
+`,
+			"README.md":  "# {{name}}\n\n```bash\npip install -e .\n{{name}} --help\n```\n",
+			".gitignore": "__pycache__/\n*.egg-info/\n.venv/\n",
+		},
+	},
+	"node-cli": {
+		summary: "Node.js command-line tool: ES modules, no dependencies, node --test",
+		files: map[string]string{
+			"package.json": `{
+  "name": "{{name}}",
+  "version": "0.1.0",
+  "type": "module",
+  "bin": { "{{name}}": "bin/{{name}}.js" },
+  "scripts": { "test": "node --test" },
+  "engines": { "node": ">=18" }
+}
+`,
+			"bin/{{name}}.js": `#!/usr/bin/env node
+import { run } from "../src/cli.js";
+
+process.exitCode = run(process.argv.slice(2), process.stdout);
+`,
+			"src/cli.js": `import { parseArgs } from "node:util";
+
+export function run(argv, stdout) {
+  const { values } = parseArgs({ args: argv, options: { name: { type: "string", default: "world" } } });
+  stdout.write(` + "`Hello, ${values.name}!\\n`" + `);
+  return 0;
+}
+`,
+			"test/cli.test.js": `import { test } from "node:test";
+import assert from "node:assert/strict";
+import { run } from "../src/cli.js";
+
+test("run greets", () => {
+  let out = "";
+  assert.equal(run(["--name", "test"], { write: (s) => (out += s) }), 0);
+  assert.equal(out, "Hello, test!\n");
+});
+`,
+			"README.md":  "# {{name}}\n\n```bash\nnpm link\n{{name}} --name you\n```\n",
+			".gitignore": "node_modules/\n",
+		},
+	},
+}
+
+// userTemplate reads ~/.zug/templates/<name>, a directory whose files are copied with
+// the same placeholders as the built-in templates. A user template shadows a built-in
+// one of the same name.
+func userTemplate(name string) (projectTemplate, bool, error) {
+	home, err := userZugDir()
+	if err != nil {
+		return projectTemplate{}, false, err
+	}
+	root := filepath.Join(home, templatesDirName, name)
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return projectTemplate{}, false, nil
+	}
+	t := projectTemplate{summary: "user template in " + root, files: map[string]string{}}
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		t.files[filepath.ToSlash(rel)] = string(raw)
+		return nil
+	})
+	if err != nil {
+		return projectTemplate{}, false, fmt.Errorf("cannot read template %s: %w", root, err)
+	}
+	return t, true, nil
+}
+
+// lookupTemplate finds a user or built-in template by name.
+func lookupTemplate(name string) (projectTemplate, error) {
+	if !validProjectName.MatchString(name) {
+		return projectTemplate{}, fmt.Errorf("invalid template name %q", name)
+	}
+	if t, ok, err := userTemplate(name); err != nil || ok {
+		return t, err
+	}
+	if t, ok := builtinTemplates[name]; ok {
+		return t, nil
+	}
+	return projectTemplate{}, fmt.Errorf("unknown template %q; available: %s", name, strings.Join(templateNames(), ", "))
+}
+
+// templateNames lists the user and built-in templates, sorted.
+func templateNames() []string {
+	var names []string
+	for name := range builtinTemplates {
+		names = append(names, name)
+	}
+	if home, err := userZugDir(); err == nil {
+		entries, _ := os.ReadDir(filepath.Join(home, templatesDirName))
+		for _, e := range entries {
+			if e.IsDir() && !slices.Contains(names, e.Name()) {
+				names = append(names, e.Name())
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// scaffold writes the template's files into dir, which must not exist or be empty, and
+// returns the paths written, sorted.
+func (t projectTemplate) scaffold(dir, name string) ([]string, error) {
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("%s already exists and is not empty", dir)
+	}
+	pkg := strings.ToLower(regexp.MustCompile(`[^A-Za-z0-9_]`).ReplaceAllString(strings.ReplaceAll(name, "-", "_"), ""))
+	r := strings.NewReplacer("{{name}}", name, "{{package}}", pkg, "{{year}}", time.Now().Format("2006"))
+	var written []string
+	for path, content := range t.files {
+		rel := filepath.FromSlash(r.Replace(path))
+		full := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			return written, err
+		}
+		mode := os.FileMode(0o644)
+		if strings.HasPrefix(content, "#!") {
+			mode = 0o755
+		}
+		if err := os.WriteFile(full, []byte(r.Replace(content)), mode); err != nil {
+			return written, err
+		}
+		written = append(written, filepath.ToSlash(rel))
+	}
+	slices.Sort(written)
+	return written, nil
+}
+
+func newCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	tmplName := fs.String("template", "", "template to start from (see -list)")
+	list := fs.Bool("list", false, "list the available templates and exit")
+	return func() {
+		if *list {
+			for _, name := range templateNames() {
+				t, err := lookupTemplate(name)
+				if err != nil {
+					log.Fatalf("❌ %v", err)
+				}
+				fmt.Printf("%-12s %s\n", name, t.summary)
+			}
+			return
+		}
+		dir, description := arg(fs.Args(), 0), strings.TrimSpace(arg(fs.Args(), 1))
+		if *tmplName == "" || dir == "" || description == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+		tmpl, err := lookupTemplate(*tmplName)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		name := filepath.Base(filepath.Clean(dir))
+		if !validProjectName.MatchString(name) {
+			log.Fatalf("❌ %q is not usable as a project name: start with a letter and use letters, digits, '.', '_' and '-'", name)
+		}
+		files, err := tmpl.scaffold(dir, name)
+		if err != nil {
+			log.Fatalf("❌ Could not scaffold %s: %v", dir, err)
+		}
+		log.Printf("[agent] 🏗️ Scaffolded %s from the %s template (%d files).\n", dir, *tmplName, len(files))
+		// A project created inside another repository stays part of it.
+		if _, err := gitCmd(dir, "rev-parse", "--show-toplevel"); err != nil {
+			if _, err := gitCmd(dir, "init", "-q"); err != nil {
+				log.Printf("[agent] ⚠️ Could not initialize a git repository: %v\n", err)
+			}
+		}
+
+		cf.dir = dir
+		agent := cf.newAgent(arg(fs.Args(), 2))
+		task := fmt.Sprintf(`Turn this freshly scaffolded project into: %s
+
+The project %q was just created from the %s template (%s) with these files:
+- %s
+
+Keep the template's layout, build setup and testing approach: replace the placeholder greeting code and the README with the real thing rather than building a parallel structure next to them, and keep tests passing. Add dependencies only when the description needs them.`,
+			description, name, *tmplName, tmpl.summary, strings.Join(files, "\n- "))
+		err = agent.feedbackLoop(task)
+		if err != nil {
+			log.Printf("[agent] ❌ Customization did not complete: %v\n[agent] The scaffolded project is in %s.\n", err, dir)
+		}
+		agent.notifyRunEnd(task, err)
+		os.Exit(agent.exitCode(err))
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestScaffoldGoTemplatesBuildAndPass(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	t.Setenv("ZUG_HOME", t.TempDir())
+	for _, name := range []string{"go-cli", "go-lib"} {
+		tmpl, err := lookupTemplate(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := filepath.Join(t.TempDir(), "my-tool")
+		if _, err := tmpl.scaffold(dir, "my-tool"); err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command("go", "test", "./...")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOFLAGS=", "GOTOOLCHAIN=local")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("%s: go test: %v\n%s", name, err, out)
+		}
+	}
+}
+
+func TestScaffoldUserTemplate(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("ZUG_HOME", home)
+	writeTestFile(t, filepath.Join(home, templatesDirName, "go-cli"), "cmd/{{name}}/main.go", "// {{name}} ({{package}})\n")
+
+	if names := templateNames(); !slices.Equal(names, []string{"go-cli", "go-lib", "node-cli", "python-cli"}) {
+		t.Errorf("templates = %v", names)
+	}
+	tmpl, err := lookupTemplate("go-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	files, err := tmpl.scaffold(dir, "web-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(files, []string{"cmd/web-app/main.go"}) {
+		t.Fatalf("files = %v, want only the user template's", files)
+	}
+	if got := readTestFile(t, dir, "cmd/web-app/main.go"); got != "// web-app (web_app)\n" {
+		t.Errorf("main.go = %q", got)
+	}
+	if _, err := tmpl.scaffold(dir, "web-app"); err == nil {
+		t.Error("scaffolded into a non-empty directory")
+	}
+	if _, err := lookupTemplate("cobol-cli"); err == nil {
+		t.Error("unknown template was accepted")
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*──────────────────────────────
+  Daemon configuration (daemon.yaml)
+  ─────────────────────────────*/
+
+// daemonConfig is the optional ~/.zug/daemon.yaml (or -config file).
+type daemonConfig struct {
+	Notify    notifyConfig     `yaml:"notify"`
+	Schedules []scheduleConfig `yaml:"schedules"`
+}
+
+// scheduleConfig is a task submitted to the queue whenever its cron expression matches.
+type scheduleConfig struct {
+	Name   string `yaml:"name"`
+	Cron   string `yaml:"cron"` // "min hour day-of-month month day-of-week", or @hourly, @daily, @nightly, @weekly, @monthly
+	Dir    string `yaml:"dir"`  // absolute path to a git repository
+	Task   string `yaml:"task"`
+	Model  string `yaml:"model"`
+	Review bool   `yaml:"review"`
+
+	spec *cronSpec
+}
+
+// loadDaemonConfig reads path. A missing file is only an error when it was named explicitly.
+func loadDaemonConfig(path string, explicit bool) (*daemonConfig, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return &daemonConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	var cfg daemonConfig
+	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	for i := range cfg.Schedules {
+		s := &cfg.Schedules[i]
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("schedule-%d", i+1)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("invalid %s: duplicate schedule name %q", path, s.Name)
+		}
+		seen[s.Name] = true
+		if strings.TrimSpace(s.Task) == "" || !filepath.IsAbs(s.Dir) {
+			return nil, fmt.Errorf("invalid %s: schedule %q needs a task and an absolute dir", path, s.Name)
+		}
+		if s.spec, err = parseCron(s.Cron); err != nil {
+			return nil, fmt.Errorf("invalid %s: schedule %q: %w", path, s.Name, err)
+		}
+	}
+	return &cfg, nil
+}
+
+/*──────────────────────────────
+  Cron expressions
+  ─────────────────────────────*/
+
+var cronAliases = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@nightly":  "0 2 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// cronSpec holds the allowed values of each field, indexed by value.
+type cronSpec struct {
+	minute, hour, dom, month, dow []bool
+	domAny, dowAny                bool
+}
+
+func parseCron(expr string) (*cronSpec, error) {
+	expr = strings.TrimSpace(expr)
+	if alias, ok := cronAliases[expr]; ok {
+		expr = alias
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields (minute hour day-of-month month day-of-week) or be one of @hourly, @daily, @nightly, @weekly, @monthly", expr)
+	}
+	spec := &cronSpec{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	var err error
+	for _, f := range []struct {
+		dst         *[]bool
+		text        string
+		first, last int
+	}{
+		{&spec.minute, fields[0], 0, 59},
+		{&spec.hour, fields[1], 0, 23},
+		{&spec.dom, fields[2], 1, 31},
+		{&spec.month, fields[3], 1, 12},
+		{&spec.dow, fields[4], 0, 7},
+	} {
+		if *f.dst, err = parseCronField(f.text, f.first, f.last); err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+	}
+	spec.dow[0] = spec.dow[0] || spec.dow[7] // 7 is also Sunday
+	return spec, nil
+}
+
+// parseCronField accepts comma-separated "*", "n", "a-b", each optionally followed by "/step".
+func parseCronField(text string, first, last int) ([]bool, error) {
+	allowed := make([]bool, last+1)
+	for _, part := range strings.Split(text, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+		}
+		lo, hi := first, last
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(a)
+			hi, err2 = lo, nil
+			if isRange {
+				hi, err2 = strconv.Atoi(b)
+			} else if hasStep {
+				hi = last // "5/15" means from 5 to the end in steps of 15
+			}
+			if err1 != nil || err2 != nil || lo < first || hi > last || lo > hi {
+				return nil, fmt.Errorf("%q is not a value or range within %d-%d", part, first, last)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			allowed[v] = true
+		}
+	}
+	return allowed, nil
+}
+
+// matches reports whether t (truncated to the minute) is a firing time. Like cron, when
+// both day fields are restricted a day matching either one fires.
+func (s *cronSpec) matches(t time.Time) bool {
+	if !s.minute[t.Minute()] || !s.hour[t.Hour()] || !s.month[int(t.Month())] {
+		return false
+	}
+	dom, dow := s.dom[t.Day()], s.dow[int(t.Weekday())]
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+/*──────────────────────────────
+  Scheduler
+  ─────────────────────────────*/
+
+// schedule wakes up at the start of every minute and queues the schedules that fire.
+func (d *daemon) schedule(schedules []scheduleConfig) {
+	for _, s := range schedules {
+		log.Printf("[daemon] ⏰ Schedule %q (%s) for %s\n", s.Name, s.Cron, s.Dir)
+	}
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		time.Sleep(next.Sub(now))
+		for _, s := range schedules {
+			if s.spec.matches(next) {
+				d.fire(s)
+			}
+		}
+	}
+}
+
+// fire queues one run of s, unless the previous one has not finished yet.
+func (d *daemon) fire(s scheduleConfig) {
+	d.mu.Lock()
+	for _, t := range d.tasks {
+		if t.Schedule == s.Name && (t.Status == taskQueued || t.Status == taskRunning) {
+			d.mu.Unlock()
+			log.Printf("[daemon] ⏭️ Skipping schedule %q: task %s from the previous run is still %s\n", s.Name, t.ID, t.Status)
+			return
+		}
+	}
+	d.mu.Unlock()
+	if _, err := d.submit(daemonTask{Task: s.Task, Dir: s.Dir, Model: s.Model, Review: s.Review, Schedule: s.Name}); err != nil {
+		log.Printf("[daemon] ❌ Schedule %q could not be queued: %v\n", s.Name, err)
+		d.notify.send(notification{
+			Event:   eventFailed,
+			Title:   fmt.Sprintf("zug: schedule %q could not start", s.Name),
+			Message: err.Error(),
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*──────────────────────────────
+  Scratch workspace (tools)
+  ─────────────────────────────*/
+
+var scratchDir = zugDirName + "/scratch" // relative to the project root
+
+// writeScratch creates a throwaway file under .zug/scratch. Scratch files are not
+// tracked as project changes, so they never show up in the diff or the review.
+func (a *AutonomousCodingAgent) writeScratch(name, content string) (string, error) {
+	rel := filepath.Join(scratchDir, filepath.Clean(name))
+	if !strings.HasPrefix(rel, scratchDir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid scratch file name %q (must stay inside %s)", name, scratchDir)
+	}
+	full, err := a.absPath(rel)
+	if err != nil {
+		return "", err
+	}
+	if _, err := a.zugDir(); err != nil {
+		return "", err
+	}
+	if err := a.fs.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory for %s: %w", rel, err)
+	}
+	if err := a.fs.WriteFile(full, []byte(content), 0o755); err != nil {
+		return "", fmt.Errorf("failed to write scratch file %s: %w", rel, err)
+	}
+	return fmt.Sprintf("scratch file written to %s (run it with run_shell from the project root)", filepath.ToSlash(rel)), nil
+}
+
+// cleanScratch deletes the whole scratch area.
+func (a *AutonomousCodingAgent) cleanScratch() (string, error) {
+	full := filepath.Join(a.projectDir, scratchDir)
+	entries, err := a.fs.ReadDir(full)
+	if os.IsNotExist(err) || (err == nil && len(entries) == 0) {
+		return "scratch area is already empty", nil
+	}
+	if err := a.fs.RemoveAll(full); err != nil {
+		return "", fmt.Errorf("failed to clean %s: %w", scratchDir, err)
+	}
+	return fmt.Sprintf("removed %s (%d entries)", scratchDir, len(entries)), nil
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/*──────────────────────────────
+  Security scanner feedback
+  ─────────────────────────────*/
+
+const (
+	securityMaxRounds = 3    // fix iterations for new findings before the run finishes anyway
+	maxSecurityOutput = 8000 // characters of findings fed back to the model
+)
+
+// Security severities, most severe first.
+var securitySeverities = []string{"critical", "high", "medium", "low"}
+
+// securityConfig is the `security:` section of zug.yaml.
+type securityConfig struct {
+	Enabled  bool     `yaml:"enabled"`  // scan after every change (also --security)
+	Severity string   `yaml:"severity"` // lowest severity fed back: critical, high (default), medium or low
+	Scanners []string `yaml:"scanners"` // only these of gosec, bandit, npm-audit and trivy
+}
+
+func (c securityConfig) validate() error {
+	if c.Severity != "" && !slices.Contains(securitySeverities, c.Severity) {
+		return fmt.Errorf("security.severity %q must be one of %s", c.Severity, strings.Join(securitySeverities, ", "))
+	}
+	for _, s := range c.Scanners {
+		if !slices.ContainsFunc(securityScanners, func(sc securityScanner) bool { return sc.name == s }) {
+			return fmt.Errorf("unknown security scanner %q", s)
+		}
+	}
+	return nil
+}
+
+// securityFinding is one issue reported by a scanner, with the path relative to the
+// project root.
+type securityFinding struct {
+	Scanner  string `json:"scanner"`
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message"`
+}
+
+// key identifies a finding across scans. The line is left out, since edits above a
+// known finding move it.
+func (f securityFinding) key() string {
+	return f.Scanner + "\x00" + f.Rule + "\x00" + f.File + "\x00" + f.Message
+}
+
+func (f securityFinding) String() string {
+	loc := f.File
+	if f.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+	return fmt.Sprintf("[%s] %s %s %s: %s", f.Severity, f.Scanner, f.Rule, loc, f.Message)
+}
+
+// securitySeverity maps the severity names of the scanners onto securitySeverities.
+func securitySeverity(s string) string {
+	switch s = strings.ToLower(s); s {
+	case "critical", "high", "medium", "low":
+		return s
+	case "moderate":
+		return "medium"
+	}
+	return "low" // info, unknown
+}
+
+func severityAtLeast(severity, threshold string) bool {
+	return slices.Index(securitySeverities, severity) <= slices.Index(securitySeverities, threshold)
+}
+
+// securityScanner runs one scanner and parses its JSON report.
+type securityScanner struct {
+	name    string
+	bin     string
+	applies func(has func(string) bool) bool
+	cmd     string
+	parse   func(raw []byte) ([]securityFinding, error)
+}
+
+var securityScanners = []securityScanner{
+	{
+		name: "gosec", bin: "gosec",
+		applies: func(has func(string) bool) bool { return has("go.mod") },
+		cmd:     "gosec -quiet -fmt=json ./... 2>/dev/null",
+		parse:   parseGosec,
+	},
+	{
+		name: "bandit", bin: "bandit",
+		applies: func(has func(string) bool) bool {
+			return has("pyproject.toml") || has("setup.py") || has("requirements.txt")
+		},
+		cmd:   "bandit -r . -f json -q -x ./.venv,./venv,./node_modules,./.zug 2>/dev/null",
+		parse: parseBandit,
+	},
+	{
+		name: "npm-audit", bin: "npm",
+		applies: func(has func(string) bool) bool { return has("package-lock.json") },
+		cmd:     "npm audit --json 2>/dev/null",
+		parse:   parseNpmAudit,
+	},
+	{
+		name: "trivy", bin: "trivy",
+		applies: func(func(string) bool) bool { return true },
+		cmd:     "trivy fs --quiet --format json --scanners vuln,secret,misconfig --skip-dirs .zug --skip-dirs node_modules . 2>/dev/null",
+		parse:   parseTrivy,
+	},
+}
+
+// jsonReport cuts the JSON document out of a scanner's output.
+func jsonReport(out string) ([]byte, error) {
+	start := strings.IndexAny(out, "{[")
+	if start < 0 {
+		return nil, fmt.Errorf("no JSON report in the output: %s", strings.TrimSpace(out))
+	}
+	return []byte(out[start:]), nil
+}
+
+func parseGosec(raw []byte) ([]securityFinding, error) {
+	var report struct {
+		Issues []struct {
+			Severity string `json:"severity"`
+			RuleID   string `json:"rule_id"`
+			Details  string `json:"details"`
+			File     string `json:"file"`
+			Line     string `json:"line"` // "12" or "12-14"
+		} `json:"Issues"`
+	}
+	if err := json.Unmarshal(raw, &report); err != nil {
+		return nil, err
+	}
+	var findings []securityFinding
+	for _, i := range report.Issues {
+		line, _ := strconv.Atoi(strings.SplitN(i.Line, "-", 2)[0])
+		findings = append(findings, securityFinding{"gosec", securitySeverity(i.Severity), i.RuleID, i.File, line, i.Details})
+	}
+	return findings, nil
+}
+
+func parseBandit(raw []byte) ([]securityFinding, error) {
+	var report struct {
+		Results []struct {
+			Filename string `json:"filename"`
+			Severity string `json:"issue_severity"`
+			Text     string `json:"issue_text"`
+			Line     int    `json:"line_number"`
+			TestID   string `json:"test_id"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(raw, &report); err != nil {
+		return nil, err
+	}
+	var findings []securityFinding
+	for _, r := range report.Results {
+		findings = append(findings, securityFinding{"bandit", securitySeverity(r.Severity), r.TestID, r.Filename, r.Line, r.Text})
+	}
+	return findings, nil
+}
+
+func parseNpmAudit(raw []byte) ([]securityFinding, error) {
+	var report struct {
+		Vulnerabilities map[string]struct {
+			Severity string            `json:"severity"`
+			Via      []json.RawMessage `json:"via"` // advisories, or names of vulnerable dependencies
+			Range    string            `json:"range"`
+		} `json:"vulnerabilities"`
+	}
+	if err := json.Unmarshal(raw, &report); err != nil {
+		return nil, err
+	}
+	var findings []securityFinding
+	for name, v := range report.Vulnerabilities {
+		msg := fmt.Sprintf("%s %s is vulnerable", name, v.Range)
+		var titles []string
+		for _, via := range v.Via {
+			var adv struct {
+				Title string `json:"title"`
+			}
+			if json.Unmarshal(via, &adv) == nil && adv.Title != "" {
+				titles = append(titles, adv.Title)
+			}
+		}
+		if len(titles) > 0 {
+			msg += ": " + strings.Join(titles, "; ")
+		} else {
+			msg += " through a vulnerable dependency"
+		}
+		findings = append(findings, securityFinding{"npm-audit", securitySeverity(v.Severity), name, "package-lock.json", 0, msg})
+	}
+	slices.SortFunc(findings, func(a, b securityFinding) int { return strings.Compare(a.Rule, b.Rule) })
+	return findings, nil
+}
+
+func parseTrivy(raw []byte) ([]securityFinding, error) {
+	var report struct {
+		Results []struct {
+			Target          string `json:"Target"`
+			Vulnerabilities []struct {
+				VulnerabilityID, PkgName, InstalledVersion, FixedVersion, Severity, Title string
+			} `json:"Vulnerabilities"`
+			Misconfigurations []struct {
+				ID, Title, Message, Severity string
+			} `json:"Misconfigurations"`
+			Secrets []struct {
+				RuleID, Title, Severity string
+				StartLine               int
+			} `json:"Secrets"`
+		} `json:"Results"`
+	}
+	if err := json.Unmarshal(raw, &report); err != nil {
+		return nil, err
+	}
+	var findings []securityFinding
+	for _, r := range report.Results {
+		for _, v := range r.Vulnerabilities {
+			msg := fmt.Sprintf("%s %s: %s", v.PkgName, v.InstalledVersion, v.Title)
+			if v.FixedVersion != "" {
+				msg += " (fixed in " + v.FixedVersion + ")"
+			}
+			findings = append(findings, securityFinding{"trivy", securitySeverity(v.Severity), v.VulnerabilityID, r.Target, 0, msg})
+		}
+		for _, m := range r.Misconfigurations {
+			findings = append(findings, securityFinding{"trivy", securitySeverity(m.Severity), m.ID, r.Target, 0, m.Title + ": " + m.Message})
+		}
+		for _, s := range r.Secrets {
+			findings = append(findings, securityFinding{"trivy", securitySeverity(s.Severity), s.RuleID, r.Target, s.StartLine, s.Title})
+		}
+	}
+	return findings, nil
+}
+
+// securityScan runs every applicable, installed scanner over the project. A scanner that
+// fails is logged and skipped; err is set when none could run.
+func (a *AutonomousCodingAgent) securityScan() (findings []securityFinding, err error) {
+	has := func(name string) bool {
+		_, err := a.fs.Stat(filepath.Join(a.projectDir, name))
+		return err == nil
+	}
+	ran := 0
+	for _, s := range securityScanners {
+		if len(a.cfg.Security.Scanners) > 0 && !slices.Contains(a.cfg.Security.Scanners, s.name) {
+			continue
+		}
+		if !s.applies(has) || !a.installed(s.bin) {
+			continue
+		}
+		log.Printf("[agent] 🛡️ Running security scanner: %s\n", s.name)
+		out, _ := a.execShell(s.cmd) // scanners exit non-zero when they find something
+		raw, err := jsonReport(out)
+		var found []securityFinding
+		if err == nil {
+			found, err = s.parse(raw)
+		}
+		if err != nil {
+			log.Printf("[agent] ⚠️ %s failed: %v\n", s.name, err)
+			continue
+		}
+		ran++
+		for _, f := range found {
+			if filepath.IsAbs(f.File) {
+				if rel, err := filepath.Rel(a.projectDir, f.File); err == nil {
+					f.File = rel
+				}
+			}
+			f.File = filepath.ToSlash(strings.TrimPrefix(f.File, "./"))
+			findings = append(findings, f)
+		}
+	}
+	if ran == 0 {
+		return nil, fmt.Errorf("no security scanner is installed for this project (gosec, bandit, npm, trivy)")
+	}
+	slices.SortStableFunc(findings, func(x, y securityFinding) int {
+		return slices.Index(securitySeverities, x.Severity) - slices.Index(securitySeverities, y.Severity)
+	})
+	return findings, nil
+}
+
+// securityRun holds what the scanners reported before a run, so the loop only holds the
+// agent to findings it introduced. A nil baseline makes every finding count, for runs
+// whose task is to fix them.
+type securityRun struct {
+	minSeverity string
+	baseline    map[string]int // finding key -> count before the run
+	rounds      int            // fix iterations so far
+	scanned     bool
+	remaining   []securityFinding // new findings at the last scan
+}
+
+func (a *AutonomousCodingAgent) securityMinSeverity() string {
+	if a.cfg.Security.Severity != "" {
+		return a.cfg.Security.Severity
+	}
+	return "high"
+}
+
+// startSecurity records the baseline scan when security scanning is enabled.
+func (a *AutonomousCodingAgent) startSecurity() {
+	if a.security != nil || !a.cfg.Security.Enabled {
+		return
+	}
+	findings, err := a.securityScan()
+	if err != nil {
+		log.Printf("[agent] ⚠️ Security scanning is enabled, but %v. Continuing without it.\n", err)
+		return
+	}
+	s := &securityRun{minSeverity: a.securityMinSeverity(), baseline: map[string]int{}}
+	for _, f := range findings {
+		s.baseline[f.key()]++
+	}
+	log.Printf("[agent] 🛡️ Security baseline: %d existing findings, which the run is not held to.\n", len(findings))
+	a.security = s
+}
+
+// newFindings returns the findings at or above the severity threshold that were not in
+// the baseline (each known finding excuses one occurrence).
+func (s *securityRun) newFindings(findings []securityFinding) []securityFinding {
+	known := map[string]int{}
+	for k, n := range s.baseline {
+		known[k] = n
+	}
+	var fresh []securityFinding
+	for _, f := range findings {
+		if known[f.key()] > 0 {
+			known[f.key()]--
+			continue
+		}
+		if severityAtLeast(f.Severity, s.minSeverity) {
+			fresh = append(fresh, f)
+		}
+	}
+	return fresh
+}
+
+// securityFollowUp scans the changed project and keeps the run going while it has new
+// findings, for at most securityMaxRounds iterations. It returns the next instruction
+// and true in that case.
+func (a *AutonomousCodingAgent) securityFollowUp() (string, bool) {
+	if a.security == nil || len(a.changes) == 0 {
+		return "", false
+	}
+	findings, err := a.securityScan()
+	if err != nil {
+		log.Printf("[agent] ⚠️ Security scan failed: %v\n", err)
+		return "", false
+	}
+	s := a.security
+	s.scanned, s.remaining = true, s.newFindings(findings)
+	if len(s.remaining) == 0 {
+		log.Printf("[agent] 🛡️ No new security findings at or above %s severity.\n", s.minSeverity)
+		return "", false
+	}
+	if s.rounds >= securityMaxRounds {
+		log.Printf("[agent] ⚠️ %d security findings remain after %d fix iterations.\n", len(s.remaining), s.rounds)
+		return "", false
+	}
+	s.rounds++
+	log.Printf("[agent] 🛡️ %d security findings at or above %s severity; asking for a fix.\n", len(s.remaining), s.minSeverity)
+	lines := make([]string, len(s.remaining))
+	for i, f := range s.remaining {
+		lines[i] = "- " + f.String()
+	}
+	list := strings.Join(lines, "\n")
+	if len(list) > maxSecurityOutput {
+		list = list[:validCut(list, maxSecurityOutput)] + "\n... (findings truncated)"
+	}
+	what := "Security scanners reported new findings in your changes"
+	if s.baseline == nil {
+		what = "Security scanners still report these findings"
+	}
+	return fmt.Sprintf("%s. Fix the underlying problems (validate input, avoid shell and SQL injection, use safe APIs, upgrade vulnerable dependencies with add_dependency) rather than suppressing the warnings, and keep the tests passing. Findings:\n%s", what, list), true
+}
+
+// securityStatus is the Security line of the run summary, or "" when nothing was scanned.
+func (a *AutonomousCodingAgent) securityStatus() string {
+	if a.security == nil || !a.security.scanned {
+		return ""
+	}
+	if n := len(a.security.remaining); n > 0 {
+		return fmt.Sprintf("   Security: ⚠️ %d findings at or above %s\n", n, a.security.minSeverity)
+	}
+	return fmt.Sprintf("   Security: ✅ no new findings at or above %s\n", a.security.minSeverity)
+}
+
+/*──────────────────────────────
+  zug security
+  ─────────────────────────────*/
+
+func securityCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	severity := fs.String("severity", "", "lowest severity to report or fix: critical, high, medium or low (default: security.severity in zug.yaml, else high)")
+	fix := fs.Bool("fix", false, "have the agent fix the findings, then scan again")
+	asJSON := fs.Bool("json", false, "print the findings as JSON")
+	return func() {
+		agent := cf.newAgent(arg(fs.Args(), 0))
+		if *severity != "" {
+			agent.cfg.Security.Severity = *severity
+		}
+		if err := agent.cfg.Security.validate(); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		minSeverity := agent.securityMinSeverity()
+		scan := func() []securityFinding {
+			findings, err := agent.securityScan()
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			return slices.DeleteFunc(findings, func(f securityFinding) bool { return !severityAtLeast(f.Severity, minSeverity) })
+		}
+		findings := scan()
+
+		if *fix && len(findings) > 0 {
+			agent.security = &securityRun{minSeverity: minSeverity}
+			lines := make([]string, len(findings))
+			for i, f := range findings {
+				lines[i] = "- " + f.String()
+			}
+			task := fmt.Sprintf("Fix all %s-or-higher severity security findings reported by the scanners. Fix the underlying problems rather than suppressing the warnings, and keep the build and tests passing. Findings:\n%s", minSeverity, strings.Join(lines, "\n"))
+			err := agent.feedbackLoop(task)
+			if err != nil {
+				log.Printf("[agent] ❌ Fixing did not complete: %v\n", err)
+			}
+			agent.notifyRunEnd(task, err)
+			findings = scan()
+		}
+
+		if *asJSON {
+			out, _ := json.MarshalIndent(findings, "", "  ")
+			fmt.Println(string(out))
+		} else if len(findings) == 0 {
+			fmt.Printf("🛡️ No security findings at or above %s severity.\n", minSeverity)
+		} else {
+			fmt.Printf("🛡️ %d security findings at or above %s severity:\n", len(findings), minSeverity)
+			for _, f := range findings {
+				fmt.Println("  " + f.String())
+			}
+		}
+		if len(findings) > 0 {
+			os.Exit(1)
+		}
+	}
+}
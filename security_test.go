@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestParseSecurityReports(t *testing.T) {
+	gosec, err := parseGosec([]byte(`{"Issues":[{"severity":"HIGH","rule_id":"G204","details":"Subprocess launched with variable","file":"/p/main.go","line":"12-14"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	npm, err := parseNpmAudit([]byte(`{"vulnerabilities":{"minimist":{"severity":"moderate","range":"<1.2.6","via":[{"title":"Prototype Pollution"}]},"mkdirp":{"severity":"low","range":"0.5.1","via":["minimist"]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trivy, err := parseTrivy([]byte(`{"Results":[{"Target":"go.mod","Vulnerabilities":[{"VulnerabilityID":"CVE-2024-1","PkgName":"golang.org/x/net","InstalledVersion":"0.1.0","FixedVersion":"0.23.0","Severity":"CRITICAL","Title":"HTTP/2 flood"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range append(append(gosec, npm...), trivy...) {
+		got = append(got, f.String())
+	}
+	want := []string{
+		"[high] gosec G204 /p/main.go:12: Subprocess launched with variable",
+		"[medium] npm-audit minimist package-lock.json: minimist <1.2.6 is vulnerable: Prototype Pollution",
+		"[low] npm-audit mkdirp package-lock.json: mkdirp 0.5.1 is vulnerable through a vulnerable dependency",
+		"[critical] trivy CVE-2024-1 go.mod: golang.org/x/net 0.1.0: HTTP/2 flood (fixed in 0.23.0)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("findings =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSecurityFindingsFedBack(t *testing.T) {
+	saved := securityScanners
+	t.Cleanup(func() { securityScanners = saved })
+	securityScanners = []securityScanner{{
+		name: "fake", bin: "sh", applies: func(func(string) bool) bool { return true },
+		cmd: "cat report.json", parse: parseBandit,
+	}}
+	existing := `{"filename":"./old.py","issue_severity":"HIGH","issue_text":"Use of assert","line_number":3,"test_id":"B101"}`
+	introduced := `{"filename":"./app.py","issue_severity":"HIGH","issue_text":"subprocess call with shell=True","line_number":9,"test_id":"B602"}`
+	a, srv := newTestAgent(t, map[string]string{
+		configFileName: "security:\n  enabled: true\n",
+		"report.json":  `{"results":[` + existing + `]}`,
+	},
+		mock.Call("create_file", map[string]any{"path": "report.json", "content": `{"results":[` + existing + "," + introduced + `]}`, "overwrite": true}),
+		mock.Text("added the feature"),
+		mock.Call("create_file", map[string]any{"path": "report.json", "content": `{"results":[` + existing + `]}`, "overwrite": true}),
+		mock.Text("removed shell=True"),
+		mock.Text(verifyApproved),
+	)
+	if err := a.feedbackLoop("add the feature"); err != nil {
+		t.Fatal(err)
+	}
+	reqs := srv.Requests()
+	if len(reqs) != 5 {
+		t.Fatalf("got %d requests, want 5", len(reqs))
+	}
+	followUp := reqs[2].Messages[len(reqs[2].Messages)-1].Content
+	assertContains(t, followUp, "[high] bandit B602 app.py:9: subprocess call with shell=True")
+	if strings.Contains(followUp, "B101") {
+		t.Errorf("the follow-up lists a finding from the baseline:\n%s", followUp)
+	}
+	assertContains(t, a.runSummary(), "Security: ✅ no new findings at or above high")
+}
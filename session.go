@@ -0,0 +1,151 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Sessions (saved conversations)
+  ─────────────────────────────*/
+
+// A session is a run's conversation, stored in its runs row. Forking copies a prefix of
+// it into a new row (status runForked) that a later `zug run --session` continues, so
+// the original branch is never modified.
+
+const runForked = "forked"
+
+// loadSession returns the stored conversation of run id and its status.
+func loadSession(st *stateStore, id int64) ([]openai.ChatCompletionMessage, string, error) {
+	var raw, status string
+	err := st.db.QueryRow(`SELECT messages, status FROM runs WHERE id = ?`, id).Scan(&raw, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", fmt.Errorf("no session #%d (see zug history)", id)
+	} else if err != nil {
+		return nil, "", err
+	}
+	var msgs []openai.ChatCompletionMessage
+	if err := json.Unmarshal([]byte(raw), &msgs); err != nil {
+		return nil, "", fmt.Errorf("session #%d has an unreadable conversation: %w", id, err)
+	}
+	return msgs, status, nil
+}
+
+// resumeSession makes the agent continue the conversation of session id. A fresh fork
+// becomes the run itself; any other session is branched first, so it stays as it was.
+func (a *AutonomousCodingAgent) resumeSession(id int64) error {
+	st, err := a.stateDB()
+	if err != nil {
+		return err
+	}
+	msgs, status, err := loadSession(st, id)
+	if err != nil {
+		return err
+	}
+	if status != runForked {
+		if id, err = forkSession(st, id, len(msgs)); err != nil {
+			return err
+		}
+		if msgs, _, err = loadSession(st, id); err != nil {
+			return err
+		}
+	}
+	a.ctx, a.runID = msgs, id
+	log.Printf("[agent] 🔀 Continuing session #%d with %d earlier message(s).\n", id, len(msgs))
+	return nil
+}
+
+// forkSession copies the first at messages of session id into a new session and returns
+// the new id.
+func forkSession(st *stateStore, id int64, at int) (int64, error) {
+	msgs, _, err := loadSession(st, id)
+	if err != nil {
+		return 0, err
+	}
+	if at < 0 || at > len(msgs) {
+		return 0, fmt.Errorf("session #%d has %d messages; -at must be between 0 and %d", id, len(msgs), len(msgs))
+	}
+	// A tool call must be followed by its results, so never cut between them.
+	for at > 0 && at < len(msgs) && msgs[at].Role == openai.ChatMessageRoleTool {
+		at--
+	}
+	if at > 0 && len(msgs[at-1].ToolCalls) > 0 {
+		at--
+	}
+	raw, err := json.Marshal(msgs[:at])
+	if err != nil {
+		return 0, err
+	}
+	res, err := st.db.Exec(`INSERT INTO runs (task, model, started, status, messages, parent, fork_at)
+		SELECT task, model, ?, ?, ?, id, ? FROM runs WHERE id = ?`,
+		stateTime(time.Now()), runForked, string(raw), at, id)
+	if err != nil {
+		return 0, fmt.Errorf("cannot fork session #%d: %w", id, err)
+	}
+	return res.LastInsertId()
+}
+
+/*──────────────────────────────
+  zug fork
+  ─────────────────────────────*/
+
+func forkCommand(fs *flag.FlagSet) func() {
+	dir := fs.String("dir", "ai_coder_project", "project directory")
+	at := fs.Int("at", -1, "keep only the first N messages (default: the whole conversation)")
+	show := fs.Bool("show", false, "list the session's messages with their numbers instead of forking")
+	return func() {
+		id, err := strconv.ParseInt(strings.TrimPrefix(arg(fs.Args(), 0), "#"), 10, 64)
+		if err != nil {
+			fs.Usage()
+			os.Exit(1)
+		}
+		dbDir := filepath.Join(*dir, zugDirName)
+		if !fileExists(filepath.Join(dbDir, stateFileName)) {
+			log.Fatalf("❌ No sessions recorded for %s yet.", *dir)
+		}
+		st, err := openState(dbDir)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		defer st.db.Close()
+
+		msgs, _, err := loadSession(st, id)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if *show {
+			for i, m := range msgs {
+				text := m.Content
+				for _, tc := range m.ToolCalls {
+					text += " → " + tc.Function.Name + "(" + tc.Function.Arguments + ")"
+				}
+				text = strings.Join(strings.Fields(text), " ")
+				if len(text) > 100 {
+					text = text[:validCut(text, 100)] + "…"
+				}
+				fmt.Printf("%3d  %-9s %s\n", i+1, m.Role, text)
+			}
+			return
+		}
+		if *at < 0 {
+			*at = len(msgs)
+		}
+		newID, err := forkSession(st, id, *at)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		fmt.Printf("🔀 Forked session #%d as #%d. Continue it with:\n  %s run --dir %s --session %d \"<what to try instead>\"\n", id, newID, os.Args[0], *dir, newID)
+		fmt.Println("Only the conversation is branched; project files are as the last run left them (use git to keep both attempts apart).")
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go driver, registers "sqlite"
+)
+
+/*──────────────────────────────
+  State store (.zug/state.db)
+  ─────────────────────────────*/
+
+const stateFileName = "state.db"
+
+// stateTimeFormat is fixed-width UTC so timestamps compare correctly as strings in SQL.
+const stateTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// stateMigrations upgrade the schema in order; PRAGMA user_version counts the ones applied.
+// Append new entries, never edit released ones.
+var stateMigrations = []string{`
+CREATE TABLE IF NOT EXISTS runs (
+	id                INTEGER PRIMARY KEY,
+	task              TEXT NOT NULL,
+	model             TEXT NOT NULL,
+	started           TEXT NOT NULL,
+	finished          TEXT,
+	status            TEXT NOT NULL,
+	error             TEXT NOT NULL DEFAULT '',
+	summary           TEXT NOT NULL DEFAULT '',
+	prompt_tokens     INTEGER NOT NULL DEFAULT 0,
+	completion_tokens INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS changes (
+	run_id INTEGER NOT NULL REFERENCES runs(id),
+	path   TEXT NOT NULL,
+	action TEXT NOT NULL,
+	time   TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS changes_time ON changes(time);
+CREATE TABLE IF NOT EXISTS checkpoints (
+	id      INTEGER PRIMARY KEY,
+	run_id  INTEGER,
+	path    TEXT NOT NULL,
+	content BLOB NOT NULL,
+	created TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS tasks (
+	id       TEXT PRIMARY KEY,
+	status   TEXT NOT NULL,
+	schedule TEXT NOT NULL DEFAULT '',
+	created  TEXT NOT NULL,
+	data     TEXT NOT NULL
+);
+`,
+	`ALTER TABLE runs ADD COLUMN outcome TEXT NOT NULL DEFAULT '';`,
+	`CREATE TABLE IF NOT EXISTS llm_cache (
+	key      TEXT PRIMARY KEY,
+	model    TEXT NOT NULL,
+	response TEXT NOT NULL,
+	created  TEXT NOT NULL
+);`,
+	`ALTER TABLE runs ADD COLUMN messages TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE runs ADD COLUMN parent INTEGER REFERENCES runs(id);
+ALTER TABLE runs ADD COLUMN fork_at INTEGER;`,
+	`CREATE TABLE IF NOT EXISTS key_usage (
+	key_id            TEXT NOT NULL,
+	label             TEXT NOT NULL,
+	day               TEXT NOT NULL,
+	requests          INTEGER NOT NULL DEFAULT 0,
+	prompt_tokens     INTEGER NOT NULL DEFAULT 0,
+	completion_tokens INTEGER NOT NULL DEFAULT 0,
+	rate_limited      INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (key_id, day)
+);`,
+	`CREATE TABLE IF NOT EXISTS approvals (
+	id         INTEGER PRIMARY KEY,
+	run_id     INTEGER,
+	time       TEXT NOT NULL,
+	call_id    TEXT NOT NULL,
+	tool       TEXT NOT NULL,
+	decision   TEXT NOT NULL,
+	decided_by TEXT NOT NULL,
+	reason     TEXT NOT NULL DEFAULT '',
+	subject    TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS approvals_time ON approvals(time);`,
+	`CREATE TABLE IF NOT EXISTS file_audit (
+	id          INTEGER PRIMARY KEY,
+	run_id      INTEGER,
+	time        TEXT NOT NULL,
+	call_id     TEXT NOT NULL DEFAULT '',
+	tool        TEXT NOT NULL DEFAULT '',
+	action      TEXT NOT NULL,
+	path        TEXT NOT NULL,
+	before_hash TEXT NOT NULL DEFAULT '',
+	after_hash  TEXT NOT NULL DEFAULT '',
+	before_size INTEGER,
+	after_size  INTEGER
+);
+CREATE INDEX IF NOT EXISTS file_audit_time ON file_audit(time);
+CREATE TRIGGER IF NOT EXISTS file_audit_append_only_update BEFORE UPDATE ON file_audit
+	BEGIN SELECT RAISE(ABORT, 'the audit log is append-only'); END;
+CREATE TRIGGER IF NOT EXISTS file_audit_append_only_delete BEFORE DELETE ON file_audit
+	BEGIN SELECT RAISE(ABORT, 'the audit log is append-only'); END;
+CREATE TRIGGER IF NOT EXISTS approvals_append_only_update BEFORE UPDATE ON approvals
+	BEGIN SELECT RAISE(ABORT, 'the audit log is append-only'); END;
+CREATE TRIGGER IF NOT EXISTS approvals_append_only_delete BEFORE DELETE ON approvals
+	BEGIN SELECT RAISE(ABORT, 'the audit log is append-only'); END;`,
+	`CREATE TABLE IF NOT EXISTS knowledge (
+	id        INTEGER PRIMARY KEY,
+	kind      TEXT NOT NULL,
+	project   TEXT NOT NULL DEFAULT '',
+	text      TEXT NOT NULL,
+	model     TEXT NOT NULL,
+	embedding BLOB NOT NULL,
+	created   TEXT NOT NULL
+);`,
+	`ALTER TABLE runs ADD COLUMN resume TEXT NOT NULL DEFAULT '';
+ALTER TABLE runs ADD COLUMN run_changes TEXT NOT NULL DEFAULT '';`,
+	`CREATE TABLE IF NOT EXISTS telemetry (
+	id    INTEGER PRIMARY KEY,
+	time  TEXT NOT NULL,
+	event TEXT NOT NULL,
+	sent  INTEGER NOT NULL DEFAULT 0
+);`,
+}
+
+const (
+	runRunning = "running"
+	runDone    = "done"
+	runFailed  = "failed"
+)
+
+// stateStore is the SQLite database holding runs, their file changes, checkpoints, daemon
+// tasks, the response cache, per-key API usage, the audit log, the knowledge base and
+// opt-in telemetry events. Open it with sqlite3
+// for ad-hoc queries.
+type stateStore struct {
+	db *sql.DB
+}
+
+// openState opens (creating and migrating) dir/state.db.
+func openState(dir string) (*stateStore, error) {
+	path := filepath.Join(dir, stateFileName)
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s: %w", path, err)
+	}
+	if err := migrateState(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cannot initialize %s: %w", path, err)
+	}
+	return &stateStore{db: db}, nil
+}
+
+func migrateState(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < len(stateMigrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(stateMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func stateTime(t time.Time) string {
+	return t.UTC().Format(stateTimeFormat)
+}
+
+// stateDB returns the project's state store, opening it on first use.
+func (a *AutonomousCodingAgent) stateDB() (*stateStore, error) {
+	if a.state != nil {
+		return a.state, nil
+	}
+	dir, err := a.zugDir()
+	if err != nil {
+		return nil, err
+	}
+	if a.state, err = openState(dir); err != nil {
+		return nil, err
+	}
+	return a.state, nil
+}
+
+/*──────────────────────────────
+  Run records
+  ─────────────────────────────*/
+
+// beginRun records the start of a feedback loop. Failing to record is not fatal to the run.
+// Subtask agents are not recorded; their work shows up in the parent's run. A run resuming
+// a forked session takes over that session's row.
+func (a *AutonomousCodingAgent) beginRun(task string) {
+	if a.child {
+		return
+	}
+	if a.runID != 0 {
+		_, err := a.state.db.Exec(`UPDATE runs SET task = ?, model = ?, started = ?, status = ? WHERE id = ?`,
+			task, a.model, stateTime(time.Now()), runRunning, a.runID)
+		if err != nil {
+			fmt.Printf("⚠️ Run history will not be recorded: %v\n", err)
+			a.runID = 0
+		}
+		return
+	}
+	st, err := a.stateDB()
+	if err == nil {
+		var res sql.Result
+		res, err = st.db.Exec(`INSERT INTO runs (task, model, started, status) VALUES (?, ?, ?, ?)`,
+			task, a.model, stateTime(time.Now()), runRunning)
+		if err == nil {
+			a.runID, err = res.LastInsertId()
+		}
+	}
+	if err != nil {
+		fmt.Printf("⚠️ Run history will not be recorded: %v\n", err)
+	}
+}
+
+// finishRun stores the outcome, token usage, conversation and changed files of the current run.
+func (a *AutonomousCodingAgent) finishRun(runErr error) {
+	if a.runID == 0 {
+		return
+	}
+	now := stateTime(time.Now())
+	status, errText := runDone, ""
+	if runErr != nil {
+		status, errText = runFailed, runErr.Error()
+	}
+	summary, outcome := a.lastReply, ""
+	if a.outcome != nil {
+		summary, outcome = a.outcome.Summary, a.outcome.Status
+	}
+	messages, err := json.Marshal(a.ctx)
+	if err != nil {
+		fmt.Printf("⚠️ Could not record the run: %v\n", err)
+		return
+	}
+	tx, err := a.state.db.Begin()
+	if err != nil {
+		fmt.Printf("⚠️ Could not record the run: %v\n", err)
+		return
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`UPDATE runs SET finished = ?, status = ?, error = ?, summary = ?, outcome = ?, prompt_tokens = ?, completion_tokens = ?, messages = ?, resume = '', run_changes = '' WHERE id = ?`,
+		now, status, errText, summary, outcome, a.usage.PromptTokens, a.usage.CompletionTokens, string(messages), a.runID)
+	for path, c := range a.changes {
+		if err != nil {
+			break
+		}
+		action := "modified"
+		if _, statErr := a.fs.Stat(filepath.Join(a.projectDir, path)); statErr != nil {
+			action = "deleted"
+		} else if !c.existed {
+			action = "created"
+		}
+		_, err = tx.Exec(`INSERT INTO changes (run_id, path, action, time) VALUES (?, ?, ?, ?)`, a.runID, path, action, now)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		fmt.Printf("⚠️ Could not record the run: %v\n", err)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  Status line
+  ─────────────────────────────*/
+
+// runStatus is what the status line reports about the current feedback loop.
+type runStatus struct {
+	started  time.Time
+	turn     int
+	lastTool string
+	toolErr  bool // the last tool call failed
+
+	policyBlocks int  // tool calls blocked by a pre_tool hook or the sensitive-path policy
+	buildPassed  bool // the last build check ran at least one command and passed
+	verified     bool // the run ended with passing tests or build
+
+	commands    int    // run_shell calls
+	testsRan    bool   // the test command ran at least once
+	testsPassed bool   // ...and passed the last time
+	testOutput  string // output of the last test run
+
+	failures map[string]int       // failed turns and tool misuse by kind (recovery.go)
+	tools    map[string]*toolStat // executed calls per tool (toolstats.go)
+}
+
+// statusLine summarizes progress: turn, tokens and cost so far, files changed, last tool
+// and elapsed time.
+func (a *AutonomousCodingAgent) statusLine() string {
+	parts := []string{fmt.Sprintf("turn %d/10", a.status.turn)}
+	tokens := fmt.Sprintf("%s tokens", compactCount(a.usage.PromptTokens+a.usage.CompletionTokens))
+	if price, ok := priceFor(a.model); ok {
+		tokens += fmt.Sprintf(" ($%.2f)", price.cost(a.usage.PromptTokens, a.usage.CompletionTokens))
+	}
+	parts = append(parts, tokens, fmt.Sprintf("%d file(s) changed", len(a.changes)))
+	if a.status.lastTool != "" {
+		last := "last: " + a.status.lastTool
+		if a.status.toolErr {
+			last += " (failed)"
+		}
+		parts = append(parts, last)
+	}
+	if !a.status.started.IsZero() {
+		parts = append(parts, time.Since(a.status.started).Round(time.Second).String())
+	}
+	return "📊 " + strings.Join(parts, " · ")
+}
+
+// printStatus writes the status line. Subtask agents stay quiet since they run in parallel
+// and would interleave.
+func (a *AutonomousCodingAgent) printStatus() {
+	if a.child || a.status.started.IsZero() {
+		return
+	}
+	fmt.Println(a.statusLine())
+}
+
+// compactCount renders 12345 as "12.3k".
+func compactCount(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	}
+	return fmt.Sprint(n)
+}
+
+/*──────────────────────────────
+  Run summary
+  ─────────────────────────────*/
+
+const summaryMaxFiles = 20 // files listed by name in the run summary
+
+// pathChange is the net effect of a run on one file.
+type pathChange struct {
+	path           string
+	action         string // created, modified or deleted
+	added, removed int    // lines
+}
+
+// netChanges compares every file the run touched with its original state, sorted by
+// path. Files changed back to their original content are left out.
+func (a *AutonomousCodingAgent) netChanges() []pathChange {
+	paths := make([]string, 0, len(a.changes))
+	for p := range a.changes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var out []pathChange
+	for _, p := range paths {
+		c := a.changes[p]
+		raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, p))
+		after, exists := string(raw), err == nil
+		pc := pathChange{path: p}
+		switch {
+		case !c.existed && exists:
+			pc.action = "created"
+		case c.existed && !exists:
+			pc.action = "deleted"
+		case c.existed && c.before != after:
+			pc.action = "modified"
+		default:
+			continue // changed back, or created and deleted again
+		}
+		for _, op := range diffLines(splitLines(c.before), splitLines(after)) {
+			switch op.kind {
+			case '+':
+				pc.added++
+			case '-':
+				pc.removed++
+			}
+		}
+		out = append(out, pc)
+	}
+	return out
+}
+
+// runSummary describes what a finished run did: the files it created, modified and
+// deleted with the lines added and removed, the commands it ran, the test status, and
+// the tokens and cost.
+func (a *AutonomousCodingAgent) runSummary() string {
+	var added, removed int
+	count := map[string]int{}
+	var files []string
+	marks := map[string]string{"created": "+", "modified": "~", "deleted": "-"}
+	for _, c := range a.netChanges() {
+		count[c.action]++
+		added, removed = added+c.added, removed+c.removed
+		files = append(files, fmt.Sprintf("     %s %s (+%d −%d)", marks[c.action], c.path, c.added, c.removed))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📋 Run summary")
+	if !a.status.started.IsZero() {
+		fmt.Fprintf(&sb, " (%s)", time.Since(a.status.started).Round(time.Second))
+	}
+	sb.WriteString(":\n")
+	if len(files) == 0 {
+		sb.WriteString("   Files:    no changes\n")
+	} else {
+		fmt.Fprintf(&sb, "   Files:    %d created, %d modified, %d deleted (+%d −%d lines)\n",
+			count["created"], count["modified"], count["deleted"], added, removed)
+		if len(files) > summaryMaxFiles {
+			files = append(files[:summaryMaxFiles], fmt.Sprintf("     … and %d more", len(files)-summaryMaxFiles))
+		}
+		sb.WriteString(strings.Join(files, "\n") + "\n")
+	}
+	fmt.Fprintf(&sb, "   Commands: %d run\n", a.status.commands)
+	switch {
+	case a.status.testsRan && a.status.testsPassed:
+		sb.WriteString("   Tests:    ✅ passing\n")
+	case a.status.testsRan:
+		sb.WriteString("   Tests:    ❌ failing\n")
+	case a.status.buildPassed:
+		sb.WriteString("   Tests:    not run (build passes)\n")
+	default:
+		sb.WriteString("   Tests:    not run\n")
+	}
+	sb.WriteString(a.securityStatus())
+	sb.WriteString(a.mutationStatus())
+	sb.WriteString(a.dockerStatus())
+	sb.WriteString(a.verifyStatus())
+	if len(a.status.failures) > 0 {
+		fmt.Fprintf(&sb, "   Failures: %s\n", a.failureSummary())
+	}
+	sb.WriteString(a.toolSummary())
+	fmt.Fprintf(&sb, "   Tokens:   %s (%s prompt, %s completion)", compactCount(a.usage.PromptTokens+a.usage.CompletionTokens),
+		compactCount(a.usage.PromptTokens), compactCount(a.usage.CompletionTokens))
+	if price, ok := priceFor(a.model); ok {
+		fmt.Fprintf(&sb, " · $%.2f", price.cost(a.usage.PromptTokens, a.usage.CompletionTokens))
+	}
+	return sb.String()
+}
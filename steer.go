@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Terminal input and mid-run steering
+  ─────────────────────────────*/
+
+// terminalInput reads standard input in one goroutine and hands out its lines: to a
+// prompt waiting for an answer (approvals, the steering pause), or else to the queue of
+// steering requests typed while the agent works.
+type terminalInput struct {
+	mu     sync.Mutex
+	waiter chan string // the prompt waiting for the next line; closed at end of input
+	queued []string    // lines typed while nobody asked
+	eof    bool
+}
+
+func newTerminalInput(r io.Reader) *terminalInput {
+	t := &terminalInput{}
+	go t.read(bufio.NewReader(r))
+	return t
+}
+
+var (
+	stdinOnce  sync.Once
+	stdinInput *terminalInput
+)
+
+// terminalInputs returns the reader of os.Stdin shared by the whole process.
+func terminalInputs() *terminalInput {
+	stdinOnce.Do(func() { stdinInput = newTerminalInput(os.Stdin) })
+	return stdinInput
+}
+
+func (t *terminalInput) read(r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		t.mu.Lock()
+		if err != nil && line == "" {
+			t.eof = true
+			if t.waiter != nil {
+				close(t.waiter)
+				t.waiter = nil
+			}
+			t.mu.Unlock()
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if t.waiter != nil {
+			t.waiter <- line
+			t.waiter = nil
+		} else {
+			t.queued = append(t.queued, line)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// readLine waits for the next line; ok is false at the end of input.
+func (t *terminalInput) readLine() (line string, ok bool) {
+	t.mu.Lock()
+	if t.eof {
+		t.mu.Unlock()
+		return "", false
+	}
+	w := make(chan string, 1)
+	t.waiter = w
+	t.mu.Unlock()
+	line, ok = <-w
+	return line, ok
+}
+
+// pending reports whether lines were typed that nobody has taken yet.
+func (t *terminalInput) pending() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.queued) > 0
+}
+
+// take removes and returns the queued lines.
+func (t *terminalInput) take() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := t.queued
+	t.queued = nil
+	return lines
+}
+
+// enableSteering lets the user interrupt the run from the terminal: a line typed while
+// the agent works is added to the conversation after the current tool call, and an empty
+// line (just Enter) pauses there to ask for one.
+func (a *AutonomousCodingAgent) enableSteering() {
+	if !stdinIsTerminal() {
+		return
+	}
+	a.input = terminalInputs()
+	fmt.Println("⌨️  Type an instruction and press Enter at any time to steer the agent, or press Enter to pause after the current step.")
+}
+
+// steerPending reports whether the user has typed something the agent has not seen yet.
+func (a *AutonomousCodingAgent) steerPending() bool {
+	return a.input != nil && a.input.pending()
+}
+
+// steering returns the user's new instruction, pausing for it when the user only pressed
+// Enter, or "" when there is none.
+func (a *AutonomousCodingAgent) steering() string {
+	if !a.steerPending() {
+		return ""
+	}
+	var lines []string
+	for _, l := range a.input.take() {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	if len(lines) == 0 {
+		approvalMu.Lock()
+		fmt.Print("\n⏸️  Paused. Type an instruction for the agent, or press Enter to continue: ")
+		line, _ := a.input.readLine()
+		approvalMu.Unlock()
+		if line = strings.TrimSpace(line); line == "" {
+			log.Println("[agent] ▶️ Resuming without a new instruction.")
+			return ""
+		}
+		lines = []string{line}
+	}
+	instruction := strings.Join(lines, "\n")
+	log.Printf("[agent] 🧭 New instruction from the user: %s\n", instruction)
+	return "Instruction from the user, typed while you were working. It takes precedence over earlier instructions where they conflict; adjust your approach and continue:\n" + instruction
+}
+
+// interruptedNote is the result of a tool call skipped because the user interrupted.
+func (a *AutonomousCodingAgent) interruptedNote() string {
+	if !a.steerPending() {
+		return ""
+	}
+	return "NOT RUN: the user interrupted with a new instruction, which follows. Decide again whether this call is still needed."
+}
+
+// addSteering appends the user's new instruction, if any, to the conversation and to
+// messages, the request being built from it.
+func (a *AutonomousCodingAgent) addSteering(messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	steer := a.steering()
+	if steer == "" {
+		return messages
+	}
+	msg := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: steer}
+	a.ctx = append(a.ctx, msg)
+	return append(messages, msg)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"zug/provider/mock"
+)
+
+func TestSteeringInstructionIsAdded(t *testing.T) {
+	a, srv := newTestAgent(t, nil, mock.Text("ok, v2"))
+	r, w := io.Pipe()
+	defer w.Close()
+	a.input = newTerminalInput(r)
+	go w.Write([]byte("use the v2 API instead\n"))
+	waitFor(t, a.input.pending)
+
+	if _, err := a.chat("port the client", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	msgs := srv.Requests()[0].Messages
+	last := msgs[len(msgs)-1]
+	if last.Role != "user" || !strings.Contains(last.Content, "use the v2 API instead") {
+		t.Errorf("last message = %+v, want the user's instruction", last)
+	}
+}
+
+func TestSteeringSkipsRemainingCalls(t *testing.T) {
+	a, srv := newTestAgent(t, map[string]string{"a.txt": "v1"},
+		mock.Calls(mock.Tool("run_shell", map[string]any{"command": "sleep 0.5"}), mock.Tool("read_file", map[string]any{"path": "a.txt"})),
+		mock.Text("switched to v2"),
+	)
+	r, w := io.Pipe()
+	defer w.Close()
+	a.input = newTerminalInput(r)
+	go func() {
+		// The instruction is typed while the first tool call runs.
+		waitFor(t, func() bool { return len(srv.Requests()) > 0 })
+		w.Write([]byte("stop, use the v2 API instead\n"))
+	}()
+	if _, err := a.chat("port the client", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	msgs := srv.Requests()[1].Messages
+	n := len(msgs)
+	assertContains(t, msgs[n-2].Content, "NOT RUN: the user interrupted")
+	assertContains(t, msgs[n-1].Content, "stop, use the v2 API instead")
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Error("timed out waiting")
+			return
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Streaming completions (--stream)
+  ─────────────────────────────*/
+
+const (
+	streamBuffer        = 4096     // chunks read ahead while the user answers an early approval
+	streamProgressBytes = 32 << 10 // log a tool call's progress each time this many argument bytes arrive
+)
+
+// streamAssembler builds a complete response from the chunks of a stream, so the rest of
+// the agent sees the same response as without streaming.
+type streamAssembler struct {
+	resp    openai.ChatCompletionResponse
+	content strings.Builder
+	calls   []openai.ToolCall // by the index the API gives each call
+}
+
+// add merges one chunk. Tool calls arrive as fragments: the first one of a call carries
+// its ID and name, the following ones pieces of its JSON arguments.
+func (s *streamAssembler) add(chunk openai.ChatCompletionStreamResponse) {
+	if s.resp.ID == "" {
+		s.resp.ID, s.resp.Object, s.resp.Created, s.resp.Model = chunk.ID, "chat.completion", chunk.Created, chunk.Model
+	}
+	if chunk.Usage != nil {
+		s.resp.Usage = *chunk.Usage
+	}
+	for _, c := range chunk.Choices {
+		if c.Index != 0 {
+			continue // only one choice is ever requested
+		}
+		s.content.WriteString(c.Delta.Content)
+		if c.FinishReason != "" {
+			if len(s.resp.Choices) == 0 {
+				s.resp.Choices = []openai.ChatCompletionChoice{{}}
+			}
+			s.resp.Choices[0].FinishReason = c.FinishReason
+		}
+		for _, tc := range c.Delta.ToolCalls {
+			i := len(s.calls)
+			if tc.Index != nil {
+				i = *tc.Index
+			} else if tc.ID == "" && i > 0 {
+				i-- // a fragment without index continues the last call
+			}
+			for len(s.calls) <= i {
+				s.calls = append(s.calls, openai.ToolCall{Type: openai.ToolTypeFunction})
+			}
+			call := &s.calls[i]
+			if tc.ID != "" {
+				call.ID = tc.ID
+			}
+			if tc.Type != "" {
+				call.Type = tc.Type
+			}
+			call.Function.Name += tc.Function.Name
+			call.Function.Arguments += tc.Function.Arguments
+		}
+	}
+}
+
+// response is the assembled response.
+func (s *streamAssembler) response() openai.ChatCompletionResponse {
+	resp := s.resp
+	if len(resp.Choices) == 0 {
+		resp.Choices = []openai.ChatCompletionChoice{{}}
+	}
+	resp.Choices = slices.Clone(resp.Choices)
+	resp.Choices[0].Message = openai.ChatCompletionMessage{
+		Role:      openai.ChatMessageRoleAssistant,
+		Content:   s.content.String(),
+		ToolCalls: slices.Clone(s.calls),
+	}
+	return resp
+}
+
+// partialJSONString returns the value of the string field key of a JSON object whose
+// text may still be incomplete, once the value has been read to its closing quote. Quotes
+// inside string values are escaped, so a match is always a field name.
+func partialJSONString(args, key string) (string, bool) {
+	re := regexp.MustCompile(`"` + regexp.QuoteMeta(key) + `"\s*:\s*"`)
+	loc := re.FindStringIndex(args)
+	if loc == nil {
+		return "", false
+	}
+	start := loc[1] - 1
+	for i := loc[1]; i < len(args); i++ {
+		switch args[i] {
+		case '\\':
+			i++
+		case '"':
+			var v string
+			if json.Unmarshal([]byte(args[start:i+1]), &v) != nil {
+				return "", false
+			}
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// streamedCall is what the agent has checked so far of a tool call whose arguments are
+// still arriving.
+type streamedCall struct {
+	named, pathChecked bool
+	path               string
+	reported           int // argument bytes at the last progress message
+}
+
+// earlyAnswer is the user's answer to an approval asked while a call was streaming.
+type earlyAnswer struct {
+	ok              bool
+	decidedBy, note string
+}
+
+// streamCompletion sends req as a streaming request and assembles the reply. While a tool
+// call's arguments arrive, its tool and path are checked as soon as they are known, so a
+// call that will be refused shows up before a large write has finished streaming; with
+// --stream-confirm, "ask" calls are put to the user at that point too.
+func (a *AutonomousCodingAgent) streamCompletion(client *openai.Client, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	req.Stream = true
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, err := client.CreateChatCompletionStream(a.context(), req)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	defer stream.Close()
+
+	// The stream is read ahead in the background, so it keeps coming while the user
+	// answers an early approval.
+	type received struct {
+		chunk openai.ChatCompletionStreamResponse
+		err   error
+	}
+	chunks := make(chan received, streamBuffer)
+	go func() {
+		defer close(chunks)
+		for {
+			chunk, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			chunks <- received{chunk, err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var asm streamAssembler
+	var seen []*streamedCall
+	for r := range chunks {
+		if r.err != nil {
+			return openai.ChatCompletionResponse{}, fmt.Errorf("stream interrupted: %w", r.err)
+		}
+		asm.add(r.chunk)
+		for len(seen) < len(asm.calls) {
+			seen = append(seen, &streamedCall{})
+		}
+		for i, call := range asm.calls {
+			a.watchStreamedCall(call, seen[i])
+		}
+	}
+	return asm.response(), nil
+}
+
+// watchStreamedCall runs the checks that the known part of a call allows: its tool once
+// the name is complete, its path once the path is, and logs progress on large arguments.
+func (a *AutonomousCodingAgent) watchStreamedCall(call openai.ToolCall, seen *streamedCall) {
+	args := call.Function.Arguments
+	// The name is complete once the arguments start arriving.
+	if !seen.named && call.Function.Name != "" && args != "" {
+		seen.named = true
+		if reason := a.earlyRefusal(call.Function.Name, ""); reason != "" {
+			log.Printf("[agent] ⚠️ The model is calling %s, which will be refused: %s.\n", call.Function.Name, reason)
+			seen.pathChecked = true
+		}
+	}
+	if seen.named && !seen.pathChecked {
+		if path, ok := partialJSONString(args, "path"); ok {
+			seen.pathChecked, seen.path = true, path
+			if reason := a.earlyRefusal(call.Function.Name, path); reason != "" {
+				log.Printf("[agent] ⚠️ The model is calling %s on %s, which will be refused: %s.\n", call.Function.Name, path, reason)
+			} else {
+				a.askEarly(call, path)
+			}
+		}
+	}
+	if len(args)-seen.reported >= streamProgressBytes {
+		seen.reported = len(args)
+		target := call.Function.Name
+		if seen.path != "" {
+			target += " " + seen.path
+		}
+		log.Printf("[agent] ⏬ %s: %d KB of arguments received so far.\n", target, len(args)>>10)
+	}
+}
+
+// earlyRefusal is why a call to tool, on path if it is not empty, will be refused, or ""
+// if nothing known so far stands in the way.
+func (a *AutonomousCodingAgent) earlyRefusal(tool, path string) string {
+	if path == "" {
+		switch {
+		case !slices.ContainsFunc(allToolDefs(), func(t openai.Tool) bool { return t.Function.Name == tool }):
+			return "there is no such tool"
+		case !a.toolAllowed(tool):
+			return "the tool is not available in this mode"
+		case a.permission(tool) == permDeny:
+			return "the permissions in " + configFileName + " deny it"
+		}
+		return ""
+	}
+	if _, err := a.absPath(path); err != nil {
+		return err.Error()
+	}
+	if pattern, blocked := a.sensitivePath(path); blocked {
+		return fmt.Sprintf("the path matches the sensitive pattern %q", pattern)
+	}
+	return ""
+}
+
+// askEarly asks for approval of an "ask" call with --stream-confirm as soon as its path
+// is known, while the rest of it streams in. The answer is used when the call runs.
+func (a *AutonomousCodingAgent) askEarly(call openai.ToolCall, path string) {
+	tool := call.Function.Name
+	if !a.streamConfirm || call.ID == "" || a.permission(tool) != permAsk || a.approvedTools[tool] || a.autoApprove {
+		return
+	}
+	ok, by, note := a.askApproval(tool, fmt.Sprintf(`{"path":%q}`, path))
+	if a.earlyAnswers == nil {
+		a.earlyAnswers = map[string]earlyAnswer{}
+	}
+	a.earlyAnswers[call.ID] = earlyAnswer{ok, by, note}
+}
+
+// takeEarlyAnswer returns and forgets the early answer for a call, if there was one.
+func (a *AutonomousCodingAgent) takeEarlyAnswer(callID string) (earlyAnswer, bool) {
+	ans, ok := a.earlyAnswers[callID]
+	delete(a.earlyAnswers, callID)
+	return ans, ok
+}
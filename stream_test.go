@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"zug/provider/mock"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestStreamedToolCallsAreAssembled(t *testing.T) {
+	content := strings.Repeat("line ünïcode\n", 50)
+	a, srv := newTestAgent(t, nil,
+		mock.Calls(mock.Tool("create_file", map[string]string{"path": "big.txt", "content": content}), mock.Tool("list_files", map[string]string{})),
+		mock.Text("Wrote big.txt."),
+	)
+	a.stream = true
+	reply, err := a.chat("write big.txt", phaseTools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "Wrote big.txt." {
+		t.Errorf("reply = %q", reply)
+	}
+	if got := readTestFile(t, a.projectDir, "big.txt"); got != content {
+		t.Errorf("big.txt has %d bytes, want %d", len(got), len(content))
+	}
+	req := srv.Requests()[0]
+	if !req.Stream || req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+		t.Errorf("request was not streamed with usage: stream=%v options=%+v", req.Stream, req.StreamOptions)
+	}
+	if a.usage.TotalTokens == 0 {
+		t.Error("usage of the streamed replies was not recorded")
+	}
+}
+
+func TestStreamAssemblerInterleavedCalls(t *testing.T) {
+	zero, one := 0, 1
+	var s streamAssembler
+	for _, d := range []openai.ChatCompletionStreamChoiceDelta{
+		{Role: "assistant", Content: "Doing "},
+		{Content: "both."},
+		{ToolCalls: []openai.ToolCall{{Index: &zero, ID: "a", Function: openai.FunctionCall{Name: "read_file"}}}},
+		{ToolCalls: []openai.ToolCall{{Index: &one, ID: "b", Function: openai.FunctionCall{Name: "list_files", Arguments: "{}"}}}},
+		{ToolCalls: []openai.ToolCall{{Index: &zero, Function: openai.FunctionCall{Arguments: `{"path":`}}}},
+		{ToolCalls: []openai.ToolCall{{Index: &zero, Function: openai.FunctionCall{Arguments: `"x.go"}`}}}},
+	} {
+		s.add(openai.ChatCompletionStreamResponse{ID: "r", Choices: []openai.ChatCompletionStreamChoice{{Delta: d}}})
+	}
+	s.add(openai.ChatCompletionStreamResponse{ID: "r", Choices: []openai.ChatCompletionStreamChoice{{FinishReason: openai.FinishReasonToolCalls}}})
+	resp := s.response()
+	msg := resp.Choices[0].Message
+	if msg.Content != "Doing both." || resp.Choices[0].FinishReason != openai.FinishReasonToolCalls {
+		t.Errorf("message = %+v, finish = %q", msg, resp.Choices[0].FinishReason)
+	}
+	if len(msg.ToolCalls) != 2 || msg.ToolCalls[0].Function.Arguments != `{"path":"x.go"}` || msg.ToolCalls[1].ID != "b" {
+		t.Errorf("calls = %+v", msg.ToolCalls)
+	}
+}
+
+func TestPartialJSONString(t *testing.T) {
+	for _, c := range []struct {
+		args, want string
+		ok         bool
+	}{
+		{`{"path": "src/ma`, "", false},
+		{`{"path": "src/main.go", "content": "pack`, "src/main.go", true},
+		{`{"content": "say \"path\": \"no\"", "path":"a\"b.go"`, `a"b.go`, true},
+		{`{"content": "x`, "", false},
+	} {
+		got, ok := partialJSONString(c.args, "path")
+		if got != c.want || ok != c.ok {
+			t.Errorf("partialJSONString(%s) = %q, %v; want %q, %v", c.args, got, ok, c.want, c.ok)
+		}
+	}
+}
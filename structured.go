@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+/*──────────────────────────────
+  Structured outputs
+  ─────────────────────────────*/
+
+// jsonSchemaFormat builds a strict response_format for the Go type of v. Fields without
+// omitempty are required, as strict mode demands.
+func jsonSchemaFormat(name string, v interface{}) (*openai.ChatCompletionResponseFormat, error) {
+	schema, err := jsonschema.GenerateSchemaForType(v)
+	if err != nil {
+		return nil, fmt.Errorf("cannot build JSON schema for %s: %w", name, err)
+	}
+	return &openai.ChatCompletionResponseFormat{
+		Type:       openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{Name: name, Schema: schema, Strict: true},
+	}, nil
+}
+
+// structuredPlan is the plan command's output when the model supports structured outputs.
+type structuredPlan struct {
+	Summary      string     `json:"summary" description:"What the change does and why, in a few sentences"`
+	Files        []planFile `json:"files" description:"Every file to create or change"`
+	Steps        []string   `json:"steps" description:"Implementation steps in order, each one concrete"`
+	Risks        []string   `json:"risks"`
+	TestStrategy string     `json:"test_strategy" description:"How the change will be verified"`
+}
+
+type planFile struct {
+	Path   string `json:"path" description:"Path relative to the project root"`
+	Change string `json:"change" description:"What changes in this file and why"`
+}
+
+// markdown renders the plan in the same layout the free-text planner is asked to use.
+func (p structuredPlan) markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## Summary\n\n%s\n\n## Files to change\n\n", p.Summary)
+	for _, f := range p.Files {
+		fmt.Fprintf(&sb, "- `%s`: %s\n", f.Path, f.Change)
+	}
+	sb.WriteString("\n## Steps\n\n")
+	for i, s := range p.Steps {
+		fmt.Fprintf(&sb, "%d. %s\n", i+1, s)
+	}
+	sb.WriteString("\n## Risks\n\n")
+	for _, r := range p.Risks {
+		fmt.Fprintf(&sb, "- %s\n", r)
+	}
+	fmt.Fprintf(&sb, "\n## Test strategy\n\n%s\n", p.TestStrategy)
+	return sb.String()
+}
+
+/*──────────────────────────────
+  Run outcome (final summary)
+  ─────────────────────────────*/
+
+// runOutcome is the model's structured account of a finished run.
+type runOutcome struct {
+	Status       string        `json:"status" enum:"complete,partial,blocked" description:"complete: the task is done; partial: some of it is done; blocked: no progress is possible without help"`
+	Summary      string        `json:"summary" description:"What was done, in a few sentences"`
+	ChangedFiles []changedFile `json:"changed_files"`
+	FollowUps    []string      `json:"follow_ups" description:"Remaining work or things the user should check; empty if none"`
+}
+
+type changedFile struct {
+	Path        string `json:"path"`
+	Description string `json:"description"`
+}
+
+// summarizeOutcome asks the model for a structured outcome of the run so far and stores it
+// in a.outcome. Models without structured outputs keep their free-text reply instead.
+func (a *AutonomousCodingAgent) summarizeOutcome(task string, runErr error) {
+	if !capabilitiesFor(a.model).structuredOutputs {
+		return
+	}
+	format, err := jsonSchemaFormat("run_outcome", runOutcome{})
+	if err != nil {
+		log.Printf("[agent] ⚠️ %v\n", err)
+		return
+	}
+	ending := "The work on the task has ended."
+	if runErr != nil {
+		ending = fmt.Sprintf("The work on the task was stopped: %v.", runErr)
+	}
+	messages := append([]openai.ChatCompletionMessage{a.systemMessage()}, a.ctx...)
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: fmt.Sprintf("%s Report the outcome of the original task honestly, based on the conversation above. Original task:\n%s", ending, task),
+	})
+	req, err := a.chatRequest(messages, phaseSummary, nil)
+	if err != nil {
+		log.Printf("[agent] ⚠️ Could not summarize the run: %v\n", err)
+		return
+	}
+	req.ResponseFormat = format
+	resp, err := a.createChatCompletion(req)
+	if err == nil && len(resp.Choices) == 0 {
+		err = fmt.Errorf("empty Choices array")
+	}
+	if err != nil {
+		log.Printf("[agent] ⚠️ Could not summarize the run: %v\n", err)
+		return
+	}
+	var out runOutcome
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &out); err != nil {
+		log.Printf("[agent] ⚠️ Run summary is not valid JSON: %v\n", err)
+		return
+	}
+	a.outcome = &out
+	fmt.Printf("📋 Outcome: %s\n%s\n", out.Status, out.Summary)
+	for _, f := range out.ChangedFiles {
+		fmt.Printf("  • %s: %s\n", f.Path, f.Description)
+	}
+	for _, f := range out.FollowUps {
+		fmt.Printf("  ↪ %s\n", f)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*──────────────────────────────
+  Git helper
+  ─────────────────────────────*/
+
+// gitCmd runs git in dir and returns its trimmed combined output.
+func gitCmd(dir string, args ...string) (string, error) {
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	out, err := c.CombinedOutput()
+	outputStr := strings.TrimSpace(string(out))
+	if err != nil {
+		return outputStr, fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, outputStr)
+	}
+	return outputStr, nil
+}
+
+/*──────────────────────────────
+  Parallel subtask agents (tool)
+  ─────────────────────────────*/
+
+const maxParallelSubtasks = 4
+
+// subtaskResult is what a child agent reports back once its worktree has been merged.
+type subtaskResult struct {
+	task    string
+	branch  string
+	summary string
+	files   []string
+	merge   string // "merged", "no changes" or a description of the conflict
+	err     error
+}
+
+// newChild builds an agent sharing the parent's client and model but working in dir.
+func (a *AutonomousCodingAgent) newChild(dir string) *AutonomousCodingAgent {
+	return &AutonomousCodingAgent{
+		keys:           a.keys,
+		fs:             osFS{},
+		projectDir:     dir,
+		maxCtxMessages: a.maxCtxMessages,
+		model:          a.model,
+		prompt:         systemPrompt(),
+		cfg:            a.cfg,
+		changes:        map[string]*fileChange{},
+		child:          true,
+		cacheMode:      a.cacheMode,
+		samplingFlags:  a.samplingFlags,
+		textTools:      a.textTools,
+		serialTools:    a.serialTools,
+		stream:         a.stream,
+		streamConfirm:  a.streamConfirm,
+		sandbox:        a.sandbox,
+		autoApprove:    a.autoApprove,
+		runCtx:         a.runCtx,
+		timeout:        a.timeout,
+	}
+}
+
+// runSubtasks runs each line of tasks as an independent child agent in its own git
+// worktree, then applies every child's diff to the parent project.
+func (a *AutonomousCodingAgent) runSubtasks(tasks string) (string, error) {
+	var list []string
+	for _, line := range strings.Split(tasks, "\n") {
+		if t := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*")); t != "" {
+			list = append(list, t)
+		}
+	}
+	if len(list) == 0 {
+		return "", fmt.Errorf("argument 'tasks' for run_subtasks must contain at least one subtask")
+	}
+	if a.remote != nil {
+		return "", fmt.Errorf("run_subtasks is not available for a remote project; do the subtasks one after another")
+	}
+	top, err := gitCmd(a.projectDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("run_subtasks requires the project to be a git repository (run 'git init' and commit first): %w", err)
+	}
+	if resolved, _ := filepath.EvalSymlinks(a.projectDir); filepath.Clean(top) != filepath.Clean(resolved) {
+		return "", fmt.Errorf("run_subtasks requires the project dir to be the root of its git repository (root is %s)", top)
+	}
+
+	// Children start from the parent's working tree, including uncommitted edits to tracked files.
+	base, err := gitCmd(a.projectDir, "stash", "create")
+	if err != nil {
+		return "", err
+	}
+	if base == "" {
+		if base, err = gitCmd(a.projectDir, "rev-parse", "HEAD"); err != nil {
+			return "", fmt.Errorf("cannot determine base commit (does the repository have a commit yet?): %w", err)
+		}
+	}
+
+	log.Printf("[agent] 🌳 Spawning %d subtask agent(s) from %s.\n", len(list), base[:min(len(base), 12)])
+	results := make([]subtaskResult, len(list))
+	sem := make(chan struct{}, maxParallelSubtasks)
+	var wg sync.WaitGroup
+	stamp := time.Now().Format("20060102-150405")
+	for i, task := range list {
+		wg.Add(1)
+		go func(i int, task string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = a.runSubtask(base, fmt.Sprintf("zug/subtask-%s-%d", stamp, i+1), task)
+		}(i, task)
+	}
+	wg.Wait()
+
+	// Merge sequentially so conflicts between siblings are detected against each other.
+	var sb strings.Builder
+	for i := range results {
+		r := &results[i]
+		if r.err == nil {
+			r.merge = a.mergeSubtask(base, r)
+		}
+		fmt.Fprintf(&sb, "Subtask %d: %s\n", i+1, r.task)
+		if r.err != nil {
+			fmt.Fprintf(&sb, "  FAILED: %v\n", r.err)
+			continue
+		}
+		fmt.Fprintf(&sb, "  Branch: %s\n  Files: %s\n  Merge: %s\n", r.branch, strings.Join(r.files, ", "), r.merge)
+		if r.summary != "" {
+			fmt.Fprintf(&sb, "  Summary: %s\n", r.summary)
+		}
+	}
+	return sb.String(), nil
+}
+
+// runSubtask executes one child agent in a fresh worktree and commits its work on branch.
+// The branch is deleted again when the subtask failed or changed nothing; mergeSubtask
+// deletes it once merged.
+func (a *AutonomousCodingAgent) runSubtask(base, branch, task string) (res subtaskResult) {
+	res = subtaskResult{task: task, branch: branch}
+	dir, err := os.MkdirTemp("", "zug-subtask-*")
+	if err != nil {
+		res.err = fmt.Errorf("cannot create worktree dir: %w", err)
+		return res
+	}
+	if _, err := gitCmd(a.projectDir, "worktree", "add", "-b", branch, dir, base); err != nil {
+		os.RemoveAll(dir)
+		res.err = err
+		return res
+	}
+	defer func() {
+		if _, err := gitCmd(a.projectDir, "worktree", "remove", "--force", dir); err != nil {
+			log.Printf("[agent] Warning: could not remove worktree %s: %v\n", dir, err)
+		}
+		if res.err != nil || len(res.files) == 0 {
+			gitCmd(a.projectDir, "branch", "-D", branch)
+		}
+	}()
+
+	log.Printf("[agent] 🌿 Subtask on %s started: %s\n", branch, task)
+	child := a.newChild(dir)
+	if err := child.feedbackLoop(task); err != nil {
+		res.summary = fmt.Sprintf("(incomplete: %v) ", err)
+	}
+	res.summary += strings.TrimSpace(child.lastReply)
+
+	if _, err := gitCmd(dir, "add", "-A"); err != nil {
+		res.err = err
+		return res
+	}
+	names, err := gitCmd(dir, "diff", "--cached", "--name-only", base)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	if names == "" {
+		return res
+	}
+	res.files = strings.Split(names, "\n")
+	if _, err := gitCmd(dir, "-c", "user.name=zug", "-c", "user.email=zug@localhost", "commit", "-q", "-m", "zug subtask: "+task); err != nil {
+		res.err = err
+	}
+	log.Printf("[agent] 🌿 Subtask on %s finished with %d changed file(s).\n", branch, len(res.files))
+	return res
+}
+
+// mergeSubtask applies the child's branch diff to the parent working tree. Hunks that do not
+// apply are left as *.rej files and reported so the parent can resolve them.
+func (a *AutonomousCodingAgent) mergeSubtask(base string, r *subtaskResult) string {
+	if len(r.files) == 0 {
+		return "no changes"
+	}
+	patch, err := gitCmd(a.projectDir, "diff", "--binary", base, r.branch)
+	if err != nil {
+		return fmt.Sprintf("could not diff branch: %v", err)
+	}
+	for _, f := range r.files {
+		a.trackChange(f, filepath.Join(a.projectDir, f))
+	}
+	defer a.auditApplied(r.files, a.snapshotFiles(r.files))
+
+	patchFile, err := os.CreateTemp("", "zug-subtask-*.patch")
+	if err != nil {
+		return fmt.Sprintf("could not write patch: %v", err)
+	}
+	defer os.Remove(patchFile.Name())
+	_, err = patchFile.WriteString(patch + "\n")
+	patchFile.Close()
+	if err != nil {
+		return fmt.Sprintf("could not write patch: %v", err)
+	}
+
+	if _, err := gitCmd(a.projectDir, "apply", "--whitespace=nowarn", patchFile.Name()); err == nil {
+		gitCmd(a.projectDir, "branch", "-D", r.branch)
+		return "merged"
+	}
+	out, err := gitCmd(a.projectDir, "apply", "--reject", "--whitespace=nowarn", patchFile.Name())
+	if err == nil {
+		gitCmd(a.projectDir, "branch", "-D", r.branch)
+		return "merged"
+	}
+	var conflicts []string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.Contains(line, "Rejected hunk") || strings.HasPrefix(line, "error:") {
+			conflicts = append(conflicts, strings.TrimSpace(line))
+		}
+	}
+	log.Printf("[agent] ⚠️ Subtask branch %s merged with conflicts.\n", r.branch)
+	return fmt.Sprintf("CONFLICTS (rejected hunks saved as *.rej next to the files, branch %s kept for reference):\n    %s",
+		r.branch, strings.Join(conflicts, "\n    "))
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*──────────────────────────────
+  Symbol location (Go AST, brace and indent structure)
+  ─────────────────────────────*/
+
+// Go definitions are found with go/parser. Python and the brace languages are scanned with
+// regular expressions and brace matching, which is good enough to list and find symbols
+// but can misjudge where a definition ends, so only Go symbols are rewritten in place.
+
+// symbolRange is the byte range of a definition inside a file, excluding its doc comment.
+type symbolRange struct {
+	name       string // qualified name, e.g. "Server.Start"
+	kind       string // func, method, type, class, ...
+	start, end int
+	line       int // 1-based line of start
+}
+
+var braceExts = map[string]bool{
+	".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".mjs": true, ".java": true, ".kt": true,
+	".c": true, ".h": true, ".cc": true, ".cpp": true, ".hpp": true, ".cs": true, ".rs": true,
+	".swift": true, ".php": true, ".scala": true, ".dart": true,
+}
+
+// hasSymbols reports whether the definitions in path can be listed.
+func hasSymbols(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".go" || ext == ".py" || braceExts[ext]
+}
+
+// fileSymbols lists the definitions in src, a file for which hasSymbols is true.
+func fileSymbols(path, src string) ([]symbolRange, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); {
+	case ext == ".go":
+		return goSymbols(path, src)
+	case ext == ".py":
+		return pySymbols(src), nil
+	case braceExts[ext]:
+		return braceSymbols(src), nil
+	}
+	return nil, nil
+}
+
+// locateSymbol finds the definition of symbol ("Name" or "Recv.Name") in src, a Go file.
+func locateSymbol(path, src, symbol string) (symbolRange, error) {
+	if ext := strings.ToLower(filepath.Ext(path)); ext != ".go" {
+		return symbolRange{}, fmt.Errorf("replace_symbol only supports Go files, not %s; use replace_lines (read_file with line_numbers first) or update_file", cmp.Or(ext, path))
+	}
+	all, err := fileSymbols(path, src)
+	if err != nil {
+		return symbolRange{}, err
+	}
+
+	var matches []symbolRange
+	for _, s := range all {
+		if s.name == symbol || (!strings.Contains(symbol, ".") && strings.HasSuffix(s.name, "."+symbol)) {
+			matches = append(matches, s)
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		var names []string
+		for _, s := range all {
+			names = append(names, s.name)
+		}
+		return symbolRange{}, fmt.Errorf("symbol %q not found in %s. Symbols found: %s", symbol, path, strings.Join(names, ", "))
+	default:
+		var names []string
+		for _, s := range matches {
+			names = append(names, fmt.Sprintf("%s (%s, line %d)", s.name, s.kind, s.line))
+		}
+		return symbolRange{}, fmt.Errorf("symbol %q is ambiguous in %s, qualify it: %s", symbol, path, strings.Join(names, ", "))
+	}
+}
+
+// goSymbols lists top-level functions, methods (Recv.Name) and types using go/parser.
+func goSymbols(path, src string) ([]symbolRange, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	rng := func(name, kind string, from, to token.Pos) symbolRange {
+		start := fset.Position(from)
+		return symbolRange{name: name, kind: kind, start: start.Offset, end: fset.Position(to).Offset, line: start.Line}
+	}
+	var out []symbolRange
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				out = append(out, rng(receiverName(d.Recv.List[0].Type)+"."+d.Name.Name, "method", d.Pos(), d.End()))
+			} else {
+				out = append(out, rng(d.Name.Name, "func", d.Pos(), d.End()))
+			}
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if len(d.Specs) == 1 {
+					out = append(out, rng(ts.Name.Name, "type", d.Pos(), d.End()))
+				} else {
+					out = append(out, rng(ts.Name.Name, "type", ts.Pos(), ts.End()))
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "?"
+}
+
+var pyDef = regexp.MustCompile(`^([ \t]*)(?:async[ \t]+)?(def|class)[ \t]+([A-Za-z_]\w*)`)
+
+// pySymbols lists classes, functions and methods by indentation.
+func pySymbols(src string) []symbolRange {
+	lines := strings.SplitAfter(src, "\n")
+	offsets := make([]int, len(lines)+1)
+	for i, l := range lines {
+		offsets[i+1] = offsets[i] + len(l)
+	}
+	type scope struct {
+		indent int
+		name   string
+	}
+	var stack []scope
+	var out []symbolRange
+	for i, l := range lines {
+		m := pyDef.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+		indent := len(m[1])
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		name := m[3]
+		kind := m[2]
+		if len(stack) > 0 {
+			name = stack[len(stack)-1].name + "." + name
+			if kind == "def" {
+				kind = "method"
+			}
+		}
+		end := i + 1
+		for j := i + 1; j < len(lines); j++ {
+			t := strings.TrimRight(lines[j], "\r\n")
+			if strings.TrimSpace(t) == "" {
+				continue
+			}
+			if len(t)-len(strings.TrimLeft(t, " \t")) <= indent {
+				break
+			}
+			end = j + 1
+		}
+		out = append(out, symbolRange{name: name, kind: kind, start: offsets[i], end: offsets[end], line: i + 1})
+		stack = append(stack, scope{indent: indent, name: name})
+	}
+	for i := range out {
+		out[i].end = trimTrailingNewline(src, out[i].start, out[i].end)
+	}
+	return out
+}
+
+func trimTrailingNewline(src string, start, end int) int {
+	for end > start && (src[end-1] == '\n' || src[end-1] == '\r') {
+		end--
+	}
+	return end
+}
+
+var (
+	braceContainer = regexp.MustCompile(`(?m)^[ \t]*(?:(?:export|default|public|private|protected|internal|abstract|final|sealed|static|unsafe|pub(?:\([^)]*\))?|data|open)[ \t]+)*(class|interface|struct|enum|trait|impl|object|namespace)\b([^{;\n]*)\{`)
+	braceFunc      = regexp.MustCompile(`(?m)^[ \t]*(?:(?:export|default|public|private|protected|internal|static|async|override|virtual|final|abstract|inline|const|unsafe|extern|pub(?:\([^)]*\))?|suspend|open)[ \t]+)*(?:(function\*?|func|fn|fun|def)[ \t]+([A-Za-z_]\w*)|(?:[\w<>\[\],:*&? ]+[ \t]+)?([A-Za-z_]\w*)[ \t]*(?:<[^>\n]*>)?[ \t]*\([^;{}]*?\)[^;{=\n]*\{|(?:const|let|var)[ \t]+([A-Za-z_]\w*)[ \t]*=[ \t]*(?:async[ \t]*)?(?:function\b|\([^)]*\)[ \t]*=>|[A-Za-z_]\w*[ \t]*=>))`)
+	braceKeywords  = map[string]bool{"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true, "else": true, "do": true, "try": true, "new": true, "sizeof": true}
+)
+
+// braceSymbols lists containers and functions in C-like languages by matching braces.
+func braceSymbols(src string) []symbolRange {
+	var out []symbolRange
+	var containers []symbolRange
+	for _, m := range braceContainer.FindAllStringSubmatchIndex(src, -1) {
+		open := m[1] - 1
+		end := matchBrace(src, open)
+		if end < 0 {
+			continue
+		}
+		name := containerName(src[m[4]:m[5]])
+		if name == "" {
+			continue
+		}
+		r := symbolRange{name: name, kind: src[m[2]:m[3]], start: lineStart(src, m[0]), end: end + 1}
+		containers = append(containers, r)
+	}
+	for _, m := range braceFunc.FindAllStringSubmatchIndex(src, -1) {
+		var name string
+		for g := 2; g+1 < len(m); g += 2 {
+			if g != 2 && m[g] >= 0 {
+				name = src[m[g]:m[g+1]]
+			}
+		}
+		if name == "" || braceKeywords[name] {
+			continue
+		}
+		open := strings.IndexByte(src[m[1]-1:], '{')
+		if open < 0 {
+			continue
+		}
+		end := matchBrace(src, m[1]-1+open)
+		if end < 0 {
+			continue
+		}
+		if src[m[1]-1] != '{' { // arrow function or function expression: `{` follows later
+			if nl := strings.IndexByte(src[m[1]:], '\n'); nl >= 0 && m[1]+nl < m[1]-1+open {
+				end = strings.IndexByte(src[m[1]:], '\n') + m[1] - 1 // single-line expression body
+			}
+		}
+		r := symbolRange{name: name, kind: "func", start: lineStart(src, m[0]), end: end + 1}
+		for i := len(containers) - 1; i >= 0; i-- {
+			c := containers[i]
+			if r.start > c.start && r.end <= c.end {
+				r.name = c.name + "." + name
+				r.kind = "method"
+				break
+			}
+		}
+		out = append(out, r)
+	}
+	out = append(containers, out...)
+	for i := range out {
+		out[i].line = strings.Count(src[:out[i].start], "\n") + 1
+		if out[i].end < len(src) && src[out[i].end] == ';' {
+			out[i].end++
+		}
+	}
+	return out
+}
+
+// containerName picks the declared name out of a class/impl header such as
+// "<'a> Display for Parser<'a>" or " Foo extends Bar implements Baz".
+func containerName(header string) string {
+	var sb strings.Builder
+	depth := 0
+	for _, r := range header {
+		switch {
+		case r == '<':
+			depth++
+		case r == '>':
+			depth--
+		case depth == 0:
+			sb.WriteRune(r)
+		}
+	}
+	h := " " + sb.String() + " "
+	if i := strings.Index(h, " for "); i >= 0 {
+		h = h[i+5:]
+	}
+	for _, sep := range []string{" extends ", " implements ", " where ", ":", "("} {
+		if i := strings.Index(h, sep); i >= 0 {
+			h = h[:i]
+		}
+	}
+	return identRe.FindString(h)
+}
+
+var identRe = regexp.MustCompile(`[A-Za-z_]\w*`)
+
+// reindent strips the common leading whitespace of code and prefixes every line with indent,
+// so a method written at column zero lands at the nesting level of the one it replaces.
+func reindent(code, indent string) string {
+	lines := strings.Split(code, "\n")
+	common, seen := "", false
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		lead := l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+		if !seen {
+			common, seen = lead, true
+		}
+		for !strings.HasPrefix(lead, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			lines[i] = ""
+			continue
+		}
+		lines[i] = indent + strings.TrimPrefix(l, common)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func lineStart(src string, i int) int {
+	for i < len(src) && (src[i] == '\n' || src[i] == '\r') {
+		i++
+	}
+	return strings.LastIndexByte(src[:i], '\n') + 1
+}
+
+// matchBrace returns the index of the brace closing the one at open, skipping strings and comments.
+func matchBrace(src string, open int) int {
+	depth := 0
+	for i := open; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case '"', '`':
+			for i++; i < len(src) && src[i] != c; i++ {
+				if src[i] == '\\' && c != '`' {
+					i++
+				}
+			}
+		case '\'':
+			// Only treat short quoted runs as char/string literals so Rust lifetimes don't break matching.
+			if j := strings.IndexByte(src[i+1:min(i+5, len(src))], '\''); j >= 0 {
+				i += j + 1
+			}
+		case '/':
+			if i+1 < len(src) && src[i+1] == '/' {
+				for i < len(src) && src[i] != '\n' {
+					i++
+				}
+			} else if i+1 < len(src) && src[i+1] == '*' {
+				if j := strings.Index(src[i+2:], "*/"); j >= 0 {
+					i += j + 3
+				} else {
+					return -1
+				}
+			}
+		}
+	}
+	return -1
+}
+
+/*──────────────────────────────
+  replace_symbol (tool)
+  ─────────────────────────────*/
+
+// replaceSymbol swaps the whole definition of symbol in a Go file for newCode. The doc
+// comment above the definition is kept; newCode should contain the complete definition.
+func (a *AutonomousCodingAgent) replaceSymbol(path, symbol, newCode string) (string, error) {
+	full, err := a.absPath(path)
+	if err != nil {
+		return "", err
+	}
+	raw, err := a.fs.ReadFile(full)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s for replace_symbol: %w", path, err)
+	}
+	src := string(raw)
+	r, err := locateSymbol(path, src, symbol)
+	if err != nil {
+		return "", err
+	}
+	indent := src[r.start:]
+	indent = indent[:len(indent)-len(strings.TrimLeft(indent, " \t"))]
+	dst := src[:r.start] + reindent(strings.TrimRight(newCode, "\n"), indent) + src[r.end:]
+
+	if err := a.validateWrite(path, full, dst); err != nil {
+		return "", fmt.Errorf("replacement for %s: %w", symbol, err)
+	}
+	a.trackChange(path, full)
+	dst, formatter := a.formatSource(path, dst)
+	if err := a.fs.WriteFile(full, []byte(dst), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write updated content to %s: %w", path, err)
+	}
+	return fmt.Sprintf("replaced %s %s (line %d) in %s%s%s", r.kind, r.name, r.line, path, formattedNote(formatter), a.diagnosticsNote(path, full)), nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// symbolList renders symbols as "kind name line: first line … last line" for comparison.
+func symbolList(src string, symbols []symbolRange) string {
+	var sb strings.Builder
+	for _, s := range symbols {
+		body := src[s.start:s.end]
+		first, _, _ := strings.Cut(body, "\n")
+		last := body[strings.LastIndexByte(body, '\n')+1:]
+		fmt.Fprintf(&sb, "%s %s %d: %s … %s\n", s.kind, s.name, s.line, strings.TrimSpace(first), strings.TrimSpace(last))
+	}
+	return sb.String()
+}
+
+func TestPythonSymbols(t *testing.T) {
+	src := `import os
+
+
+class Worker:
+    """Runs jobs."""
+
+    @staticmethod
+    def start(jobs):
+        for j in jobs:
+            run(j)
+
+    async def stop(self):
+
+        return None
+
+
+def main():
+    Worker.start([])
+`
+	got := symbolList(src, pySymbols(src))
+	want := `class Worker 4: class Worker: … return None
+method Worker.start 8: def start(jobs): … run(j)
+method Worker.stop 12: async def stop(self): … return None
+def main 17: def main(): … Worker.start([])
+`
+	if got != want {
+		t.Errorf("pySymbols:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestBraceSymbols(t *testing.T) {
+	src := "export class Parser {\n" +
+		"  parse(input: string): Node {\n" +
+		"    const close = \"}\"; // a brace in a string\n" +
+		"    return build(`${input}}`);\n" +
+		"  }\n" +
+		"}\n" +
+		"\n" +
+		"/* } in a comment */\n" +
+		"export function main() {\n" +
+		"  return new Parser();\n" +
+		"}\n" +
+		"\n" +
+		"const handler = async (req) => {\n" +
+		"  return respond(req);\n" +
+		"};\n"
+	got := symbolList(src, braceSymbols(src))
+	want := `class Parser 1: export class Parser { … }
+method Parser.parse 2: parse(input: string): Node { … }
+func main 9: export function main() { … }
+func handler 13: const handler = async (req) => { … };
+`
+	if got != want {
+		t.Errorf("braceSymbols (TypeScript):\n%s\nwant:\n%s", got, want)
+	}
+
+	src = "impl<'a> Display for Parser<'a> {\n" +
+		"    fn fmt(&self, f: &mut Formatter) -> Result {\n" +
+		"        write!(f, \"{{}}\")\n" +
+		"    }\n" +
+		"}\n"
+	got = symbolList(src, braceSymbols(src))
+	want = `impl Parser 1: impl<'a> Display for Parser<'a> { … }
+method Parser.fmt 2: fn fmt(&self, f: &mut Formatter) -> Result { … }
+`
+	if got != want {
+		t.Errorf("braceSymbols (Rust):\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestReplaceSymbol(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		"server.go": "package main\n\ntype Server struct{}\n\n// Start starts.\nfunc (s *Server) Start() error {\n\treturn nil\n}\n\nfunc main() {}\n",
+		"worker.py": "def start():\n    pass\n",
+	})
+	got, err := a.dispatchTool("call", "replace_symbol", `{"path": "server.go", "symbol": "Start", "new_code": "func (s *Server) Start() error {\n\treturn errStopped\n}"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, got, "replaced method Server.Start (line 6) in server.go")
+	assertContains(t, readTestFile(t, a.projectDir, "server.go"), "// Start starts.\nfunc (s *Server) Start() error {\n\treturn errStopped\n}\n\nfunc main() {}\n")
+
+	if _, err := a.dispatchTool("call", "replace_symbol", `{"path": "worker.py", "symbol": "start", "new_code": "def start():\n    run()"}`); err == nil || !strings.Contains(err.Error(), "only supports Go files") {
+		t.Errorf("replace_symbol on a Python file: %v", err)
+	}
+	if src := readTestFile(t, a.projectDir, "worker.py"); src != "def start():\n    pass\n" {
+		t.Errorf("worker.py was changed: %q", src)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*──────────────────────────────
+  Symbol index (find_symbol tool)
+  ─────────────────────────────*/
+
+const (
+	symbolFileLimit   = 1 << 20 // bytes; larger files are generated or data and are not indexed
+	symbolResultLimit = 30      // definitions listed per query
+	signatureLength   = 160     // characters of a definition's first line shown
+)
+
+// indexedSymbol is one definition in the project.
+type indexedSymbol struct {
+	path, name, kind, signature string
+	line                        int
+}
+
+// indexedFile holds the definitions of a file as of its size and modification time.
+type indexedFile struct {
+	modTime time.Time
+	size    int64
+	symbols []indexedSymbol
+}
+
+// symbolIndex maps the definitions of the project's files. It is refreshed before each
+// query, re-parsing only files that changed since, so it follows the agent's own edits.
+type symbolIndex struct {
+	mu    sync.Mutex
+	files map[string]indexedFile // by path relative to the project root
+}
+
+// refreshSymbols brings the index up to date and returns all definitions, ordered by
+// path and line.
+func (a *AutonomousCodingAgent) refreshSymbols() ([]indexedSymbol, error) {
+	idx := &a.symbols
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.files == nil {
+		idx.files = map[string]indexedFile{}
+	}
+	list, err := a.projectFiles()
+	if err != nil {
+		return nil, err
+	}
+	present := map[string]bool{}
+	var all []indexedSymbol
+	for _, rel := range list {
+		if !hasSymbols(rel) {
+			continue
+		}
+		full := filepath.Join(a.projectDir, rel)
+		info, err := a.fs.Stat(full)
+		if err != nil || info.Size() > symbolFileLimit {
+			continue
+		}
+		present[rel] = true
+		f, ok := idx.files[rel]
+		if !ok || f.size != info.Size() || !f.modTime.Equal(info.ModTime()) {
+			f = indexedFile{modTime: info.ModTime(), size: info.Size()}
+			if raw, err := a.fs.ReadFile(full); err == nil {
+				f.symbols = indexFile(filepath.ToSlash(rel), string(raw))
+			}
+			idx.files[rel] = f
+		}
+		all = append(all, f.symbols...)
+	}
+	for rel := range idx.files {
+		if !present[rel] {
+			delete(idx.files, rel)
+		}
+	}
+	return all, nil
+}
+
+// indexFile lists the definitions of a file with their signatures. A file that does not
+// parse has none until it is fixed.
+func indexFile(rel, src string) []indexedSymbol {
+	ranges, err := fileSymbols(rel, src)
+	if err != nil {
+		return nil
+	}
+	out := make([]indexedSymbol, 0, len(ranges))
+	for _, r := range ranges {
+		sig := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(strings.SplitN(src[r.start:r.end], "\n", 2)[0]), "{"))
+		if len(sig) > signatureLength {
+			sig = sig[:validCut(sig, signatureLength)] + "…"
+		}
+		out = append(out, indexedSymbol{path: rel, name: r.name, kind: r.kind, signature: sig, line: r.line})
+	}
+	return out
+}
+
+// warmSymbols builds the index in the background at the start of a run, so the first
+// find_symbol does not wait for the whole project to be parsed. Remote projects are left
+// to the first query, since their file system is not safe to share between goroutines.
+func (a *AutonomousCodingAgent) warmSymbols() {
+	if a.remote != nil || a.repo != nil {
+		return
+	}
+	go func() {
+		start := time.Now()
+		all, err := a.refreshSymbols()
+		if err != nil {
+			log.Printf("[agent] ⚠️ Could not index symbols: %v\n", err)
+			return
+		}
+		log.Printf("[agent] 🔖 Indexed %d symbols in %s.\n", len(all), time.Since(start).Round(time.Millisecond))
+	}()
+}
+
+// findSymbol lists the definitions named name: "Start" matches functions, types and
+// methods called Start, "Server.Start" only the method of Server. Without a match, it
+// suggests names that contain name.
+func (a *AutonomousCodingAgent) findSymbol(name string) (string, error) {
+	all, err := a.refreshSymbols()
+	if err != nil {
+		return "", err
+	}
+	var found []indexedSymbol
+	for _, s := range all {
+		if s.name == name || (!strings.Contains(name, ".") && strings.HasSuffix(s.name, "."+name)) {
+			found = append(found, s)
+		}
+	}
+	if len(found) == 0 {
+		lower := strings.ToLower(name)
+		var similar []string
+		for _, s := range all {
+			if strings.Contains(strings.ToLower(s.name), lower) && !slices.Contains(similar, s.name) {
+				similar = append(similar, s.name)
+			}
+		}
+		if len(similar) == 0 {
+			return fmt.Sprintf("no definition of %q found among %d indexed symbols; try search_files", name, len(all)), nil
+		}
+		if len(similar) > symbolResultLimit {
+			similar = similar[:symbolResultLimit]
+		}
+		return fmt.Sprintf("no definition of %q found; similar names: %s", name, strings.Join(similar, ", ")), nil
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d definition(s) of %q:", len(found), name)
+	for i, s := range found {
+		if i == symbolResultLimit {
+			fmt.Fprintf(&sb, "\n... %d more; qualify the name (e.g. Type.%s)", len(found)-i, name)
+			break
+		}
+		fmt.Fprintf(&sb, "\n%s:%d: %s %s: %s", s.path, s.line, s.kind, s.name, s.signature)
+	}
+	return sb.String(), nil
+}
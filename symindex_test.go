@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestFindSymbol(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		"server/server.go": "package server\n\n// Server serves.\ntype Server struct{}\n\nfunc (s *Server) Start(port int) error {\n\treturn nil\n}\n",
+		"worker.py":        "class Worker:\n    def start(self, jobs):\n        pass\n\ndef Start():\n    pass\n",
+		"README.md":        "# Start here\n",
+	})
+	a.warmSymbols()
+	got, err := a.dispatchTool("call", "find_symbol", `{"name": "Start"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, got, `2 definition(s) of "Start":`)
+	assertContains(t, got, "server/server.go:6: method Server.Start: func (s *Server) Start(port int) error")
+	assertContains(t, got, "worker.py:5: def Start: def Start():")
+
+	got, _ = a.findSymbol("Server.Start")
+	assertContains(t, got, `1 definition(s) of "Server.Start"`)
+	got, _ = a.findSymbol("work")
+	assertContains(t, got, "similar names: Worker, Worker.start")
+
+	// The index follows edits.
+	if _, err := a.createFile("server/stop.go", "package server\n\nfunc (s *Server) Stop() {}\n", false); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = a.findSymbol("Stop")
+	assertContains(t, got, "server/stop.go:3: method Server.Stop")
+}
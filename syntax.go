@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*──────────────────────────────
+  Syntax checks before writes
+  ─────────────────────────────*/
+
+const syntaxTimeout = 10 * time.Second
+
+// syntaxConfig is the `syntax:` section of zug.yaml.
+type syntaxConfig struct {
+	Disabled bool              `yaml:"disabled"`
+	Commands map[string]string `yaml:"commands"` // extension -> command reading stdin, failing with the error on bad syntax
+}
+
+// errNoSyntaxChecker means no parser is available for a file; it is written unchecked.
+var errNoSyntaxChecker = errors.New("no syntax checker")
+
+// pySyntaxScript parses stdin with Python's own parser and prints the first error briefly.
+const pySyntaxScript = `import ast, sys
+try:
+    ast.parse(sys.stdin.read(), sys.argv[1])
+except SyntaxError as e:
+    print(f"line {e.lineno}, column {e.offset}: {e.msg}")
+    sys.exit(1)`
+
+// tsSyntaxScript parses stdin with the project's own TypeScript, which also reads JSX,
+// and exits 3 when the project has none.
+const tsSyntaxScript = `let ts;
+try { ts = require(require.resolve("typescript", { paths: [process.cwd()] })); } catch { process.exit(3); }
+const name = process.argv[1];
+const kind = /x$/.test(name) ? ts.ScriptKind.TSX : /\.[cm]?ts$/.test(name) ? ts.ScriptKind.TS : ts.ScriptKind.JS;
+const f = ts.createSourceFile(name, require("fs").readFileSync(0, "utf8"), ts.ScriptTarget.Latest, true, kind);
+if (f.parseDiagnostics.length) {
+  const d = f.parseDiagnostics[0], p = f.getLineAndCharacterOfPosition(d.start);
+  console.log("line " + (p.line + 1) + ", column " + (p.character + 1) + ": " + ts.flattenDiagnosticMessageText(d.messageText, "\n"));
+  process.exit(1);
+}`
+
+// checkSyntax parses content as the language of path. It returns errNoSyntaxChecker when
+// there is no parser for it: Go, JSON and YAML are parsed in-process, Python, JavaScript
+// and TypeScript with python3, node and the project's typescript package when installed
+// locally, since only the content is checked, also for a remote project.
+func (a *AutonomousCodingAgent) checkSyntax(path, content string) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	if cmd, ok := a.cfg.Syntax.Commands[ext]; ok {
+		return a.runSyntaxCheck("bash", []string{"-c", cmd}, content)
+	}
+	switch ext {
+	case ".go":
+		_, err := parser.ParseFile(token.NewFileSet(), path, content, parser.AllErrors)
+		return err
+	case ".json":
+		return jsonSyntax(content)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(strings.NewReader(content))
+		for {
+			var doc yaml.Node
+			if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+	case ".py":
+		if _, err := exec.LookPath("python3"); err != nil {
+			return errNoSyntaxChecker
+		}
+		return a.runSyntaxCheck("python3", []string{"-c", pySyntaxScript, path}, content)
+	case ".ts", ".tsx", ".mts", ".cts", ".jsx", ".js", ".mjs", ".cjs":
+		if _, err := exec.LookPath("node"); err != nil {
+			return errNoSyntaxChecker
+		}
+		err := a.runSyntaxCheck("node", []string{"-e", tsSyntaxScript, path}, content)
+		var exit *exec.ExitError
+		if !errors.As(err, &exit) || exit.ExitCode() != 3 {
+			return err
+		}
+		// Without typescript, node itself checks plain JavaScript.
+		switch ext {
+		case ".cjs":
+			return a.runSyntaxCheck("node", []string{"--check"}, content)
+		case ".mjs":
+			return a.runSyntaxCheck("node", []string{"--check", "--input-type=module"}, content)
+		case ".js":
+			// A .js file may be a CommonJS script or an ES module; either will do.
+			if err := a.runSyntaxCheck("node", []string{"--check"}, content); err == nil {
+				return nil
+			}
+			return a.runSyntaxCheck("node", []string{"--check", "--input-type=module"}, content)
+		}
+	}
+	return errNoSyntaxChecker
+}
+
+// syntaxError is a parser's report on bad syntax, as opposed to a checker that failed
+// to run.
+type syntaxError struct {
+	msg string
+	err *exec.ExitError
+}
+
+func (e *syntaxError) Error() string { return e.msg }
+func (e *syntaxError) Unwrap() error { return e.err }
+
+// runSyntaxCheck pipes content into a checker in the project dir. A checker that exits
+// non-zero reports a syntax error; one that cannot run at all counts as no checker.
+func (a *AutonomousCodingAgent) runSyntaxCheck(name string, args []string, content string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), syntaxTimeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, name, args...)
+	c.Dir = a.projectDir
+	c.Stdin = strings.NewReader(content)
+	var out bytes.Buffer
+	c.Stdout, c.Stderr = &out, &out
+	err := c.Run()
+	var exit *exec.ExitError
+	if err == nil || !errors.As(err, &exit) || ctx.Err() != nil {
+		if err != nil {
+			return errNoSyntaxChecker
+		}
+		return nil
+	}
+	// Keep the message, not the checker's stack trace.
+	var lines []string
+	for _, l := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if strings.HasPrefix(l, "    at ") || strings.HasPrefix(l, "Node.js v") {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(l, " "))
+	}
+	msg := strings.TrimSpace(strings.Join(lines, "\n"))
+	if msg == "" {
+		msg = err.Error()
+	}
+	return &syntaxError{msg, exit}
+}
+
+// jsonSyntax reports the line and column of the first JSON syntax error.
+func jsonSyntax(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return nil // an empty file is left to the program reading it
+	}
+	var v any
+	err := json.Unmarshal([]byte(content), &v)
+	var se *json.SyntaxError
+	if !errors.As(err, &se) {
+		return err
+	}
+	// Offset counts the bytes read, up to and including the offending one.
+	before := content[:max(min(int(se.Offset), len(content))-1, 0)]
+	line := strings.Count(before, "\n") + 1
+	col := len(before) - strings.LastIndex(before, "\n")
+	return fmt.Errorf("line %d, column %d: %v", line, col, se)
+}
+
+// validateWrite refuses new content for path that does not parse, so broken code never
+// reaches the disk and the model gets the parser's error to correct instead. Only errors
+// the write introduces count: a file that did not parse before may stay broken while the
+// model works on it, and a file without a checker is written as it is.
+func (a *AutonomousCodingAgent) validateWrite(path, full, content string) error {
+	if a.cfg.Syntax.Disabled {
+		return nil
+	}
+	err := a.checkSyntax(path, content)
+	if err == nil || errors.Is(err, errNoSyntaxChecker) {
+		return nil
+	}
+	if old, readErr := a.fs.ReadFile(full); readErr == nil && a.checkSyntax(path, string(old)) != nil {
+		return nil
+	}
+	return fmt.Errorf("%s would not parse, so the file was left unchanged: %w. Fix the syntax and retry", path, err)
+}
+
+/*──────────────────────────────
+  validate_syntax (tool)
+  ─────────────────────────────*/
+
+// validateSyntax checks that path, or content when given, parses. Bad syntax is the
+// answer to the question, so it is a result rather than a tool error.
+func (a *AutonomousCodingAgent) validateSyntax(path string, content *string) (string, error) {
+	full, err := a.absPath(path)
+	if err != nil {
+		return "", err
+	}
+	src := ""
+	what := path
+	if content != nil {
+		src, what = *content, "the content for "+path
+	} else {
+		if a.ignoreMatcher().ignored(path, false) {
+			return "", fmt.Errorf("%s is excluded by the project's ignore patterns (%s or 'ignore' in %s) and is not shown to you", path, ignoreFileName, configFileName)
+		}
+		raw, err := a.fs.ReadFile(full)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+		src = string(raw)
+	}
+	switch err := a.checkSyntax(path, src); {
+	case errors.Is(err, errNoSyntaxChecker):
+		return fmt.Sprintf("cannot check %s: there is no syntax checker for %s files here; build or test instead", what, strings.ToLower(filepath.Ext(path))), nil
+	case err != nil:
+		return fmt.Sprintf("SYNTAX ERROR in %s: %v", what, err), nil
+	}
+	return fmt.Sprintf("%s parses without syntax errors", what), nil
+}
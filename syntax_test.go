@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBrokenWritesAreRefused(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{"main.go": "package main\n\nfunc main() {}\n", "broken.go": "package main\n\nfunc f( {\n"})
+	_, err := a.createFile("new.go", "package main\n\nfunc g() {\n", false)
+	if err == nil {
+		t.Fatal("a Go file that does not parse was written")
+	}
+	assertContains(t, err.Error(), "new.go would not parse")
+	if _, statErr := os.Stat(filepath.Join(a.projectDir, "new.go")); !errors.Is(statErr, os.ErrNotExist) {
+		t.Errorf("new.go exists after the refused write: %v", statErr)
+	}
+	if _, err := a.updateFile("main.go", "func main\\(\\) \\{\\}", "func main() {", nil, false); err == nil {
+		t.Error("an update breaking main.go was written")
+	}
+	if _, err := a.createFile("main.go", "package main\n\nfunc main() {\n", true); err == nil {
+		t.Error("an overwrite breaking main.go was written")
+	}
+	if got := readTestFile(t, a.projectDir, "main.go"); got != "package main\n\nfunc main() {}\n" {
+		t.Errorf("main.go = %q", got)
+	}
+	// Refused writes leave no checkpoint to undo.
+	st, err := a.stateDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var checkpoints int
+	if err := st.db.QueryRow(`SELECT COUNT(*) FROM checkpoints`).Scan(&checkpoints); err != nil || checkpoints != 0 {
+		t.Errorf("%d checkpoint(s) after refused writes (%v)", checkpoints, err)
+	}
+	// A file that is broken already may be edited step by step.
+	if _, err := a.appendFile("broken.go", "// more\n"); err != nil {
+		t.Errorf("edit of an already broken file refused: %v", err)
+	}
+
+	a.cfg.Syntax.Disabled = true
+	if _, err := a.createFile("new.go", "package main\n\nfunc g() {\n", false); err != nil {
+		t.Errorf("write refused with syntax checks disabled: %v", err)
+	}
+}
+
+func TestCheckSyntax(t *testing.T) {
+	a, _ := newTestAgent(t, nil)
+	cases := []struct {
+		path, content, want string // want: part of the error, "" for valid
+		needs               string // program the checker needs
+	}{
+		{"a.json", "{\n  \"a\": 1,\n}\n", "line 3, column 1", ""},
+		{"a.json", "", "", ""},
+		{"a.yaml", "a: [1, 2\n", "yaml", ""},
+		{"a.yaml", "a: 1\n---\nb: 2\n", "", ""},
+		{"a.py", "def f(:\n    pass\n", "line 1", "python3"},
+		{"a.py", "def f():\n    pass\n", "", "python3"},
+		{"a.js", "import x from 'y';\nexport default x;\n", "", "node"},
+		{"a.js", "let x = (;\n", "SyntaxError", "node"},
+	}
+	for _, c := range cases {
+		if c.needs != "" {
+			if _, err := exec.LookPath(c.needs); err != nil {
+				continue
+			}
+		}
+		err := a.checkSyntax(c.path, c.content)
+		switch {
+		case c.want == "" && err != nil:
+			t.Errorf("%s %q: %v", c.path, c.content, err)
+		case c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)):
+			t.Errorf("%s %q: error %v, want %q", c.path, c.content, err, c.want)
+		}
+	}
+	if err := a.checkSyntax("notes.txt", "anything"); !errors.Is(err, errNoSyntaxChecker) {
+		t.Errorf("notes.txt: %v, want no checker", err)
+	}
+}
+
+func TestValidateSyntaxTool(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{"main.go": "package main\n\nfunc main() {\n", "notes.txt": "hi\n"})
+	for _, c := range []struct{ args, want string }{
+		{`{"path": "main.go"}`, "SYNTAX ERROR in main.go: main.go:3:15"},
+		{`{"path": "main.go", "content": "package main\n"}`, "the content for main.go parses without syntax errors"},
+		{`{"path": "notes.txt"}`, "no syntax checker for .txt files"},
+	} {
+		got, err := a.dispatchTool("call", "validate_syntax", c.args)
+		if err != nil {
+			t.Fatalf("%s: %v", c.args, err)
+		}
+		assertContains(t, got, c.want)
+	}
+	if got := readTestFile(t, a.projectDir, "main.go"); got != "package main\n\nfunc main() {\n" {
+		t.Errorf("validate_syntax changed main.go: %q", got)
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  Opt-in usage telemetry
+  ─────────────────────────────*/
+
+// Telemetry is off unless the user turns it on with zug telemetry. It describes how a
+// run went: outcome, turns, model, tokens and the kinds of failures it recovered from.
+// It never contains the task, prompts, code, file names or command output. "local"
+// keeps the events in ~/.zug/state.db for zug telemetry show; "on" also sends them to
+// an endpoint the user chose. ZUG_TELEMETRY overrides the setting, and DO_NOT_TRACK
+// turns it off.
+
+const (
+	telemetryOff   = "off"
+	telemetryLocal = "local"
+	telemetryOn    = "on"
+
+	telemetryFileName = "telemetry.json"
+	telemetryTimeout  = 3 * time.Second // per upload, so a slow endpoint does not hold up the exit
+	telemetryBatch    = 50              // events sent per upload; the rest wait for the next run
+)
+
+// telemetrySettings is ~/.zug/telemetry.json.
+type telemetrySettings struct {
+	Mode      string `json:"mode"`
+	Endpoint  string `json:"endpoint,omitempty"`   // where "on" sends events
+	InstallID string `json:"install_id,omitempty"` // random, identifies no one; reset with zug telemetry reset
+}
+
+// telemetryEvent is everything recorded about one run.
+type telemetryEvent struct {
+	InstallID        string         `json:"install_id"`
+	Hour             string         `json:"hour"` // start of the hour the run ended in, UTC
+	OS               string         `json:"os"`
+	Arch             string         `json:"arch"`
+	Model            string         `json:"model"`
+	Success          bool           `json:"success"`
+	Outcome          string         `json:"outcome"`
+	ErrorClass       string         `json:"error_class,omitempty"`
+	Turns            int            `json:"turns"`
+	Failures         map[string]int `json:"failures,omitempty"` // failed turns by kind, see recovery.go
+	TestsRan         bool           `json:"tests_ran"`
+	Verified         bool           `json:"verified"`
+	DurationSeconds  int            `json:"duration_seconds"`
+	PromptTokens     int            `json:"prompt_tokens"`
+	CompletionTokens int            `json:"completion_tokens"`
+}
+
+// exitOutcomes names the exit codes in events.
+var exitOutcomes = map[int]string{
+	exitVerified: "verified", exitFailed: "failed", exitUnverified: "unverified", exitMaxTurns: "max_turns",
+	exitBudget: "budget", exitPolicy: "policy", exitProvider: "provider", exitTimeout: "timeout", exitInterrupted: "interrupted",
+}
+
+func telemetryPath() (string, error) {
+	dir, err := userZugDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, telemetryFileName), nil
+}
+
+// readTelemetry reads the settings as saved, without the environment. A missing file means off.
+func readTelemetry() (telemetrySettings, error) {
+	s := telemetrySettings{Mode: telemetryOff}
+	path, err := telemetryPath()
+	if err != nil {
+		return s, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return s, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return telemetrySettings{Mode: telemetryOff}, fmt.Errorf("invalid %s: %w", path, err)
+		}
+	}
+	return s, nil
+}
+
+// loadTelemetry reads the settings, with the environment applied.
+func loadTelemetry() (telemetrySettings, error) {
+	s, err := readTelemetry()
+	if err != nil {
+		return s, err
+	}
+	if env := os.Getenv("ZUG_TELEMETRY"); env != "" {
+		s.Mode = env
+	}
+	if v := os.Getenv("DO_NOT_TRACK"); v != "" && v != "0" {
+		s.Mode = telemetryOff
+	}
+	if s.Mode != telemetryLocal && s.Mode != telemetryOn {
+		s.Mode = telemetryOff
+	}
+	if s.Mode == telemetryOn && s.Endpoint == "" {
+		s.Mode = telemetryLocal // nowhere to send to
+	}
+	return s, nil
+}
+
+func saveTelemetry(s telemetrySettings) error {
+	path, err := telemetryPath()
+	if err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(raw, '\n'), 0o600)
+}
+
+func newInstallID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// telemetryModel is the model name without the organization and suffix of fine-tuned
+// models, e.g. ft:gpt-4o-mini:acme::abc123 becomes ft:gpt-4o-mini.
+func telemetryModel(model string) string {
+	if rest, ok := strings.CutPrefix(model, "ft:"); ok {
+		return "ft:" + strings.SplitN(rest, ":", 2)[0]
+	}
+	return model
+}
+
+// telemetryEvent describes the run that just ended with runErr.
+func (a *AutonomousCodingAgent) telemetryEvent(installID string, runErr error) telemetryEvent {
+	code := a.exitCode(runErr)
+	ev := telemetryEvent{
+		InstallID:        installID,
+		Hour:             time.Now().UTC().Truncate(time.Hour).Format(time.RFC3339),
+		OS:               runtime.GOOS,
+		Arch:             runtime.GOARCH,
+		Model:            telemetryModel(a.model),
+		Success:          code == exitVerified,
+		Outcome:          exitOutcomes[code],
+		Turns:            a.status.turn,
+		Failures:         a.status.failures,
+		TestsRan:         a.status.testsRan,
+		Verified:         a.status.verified,
+		PromptTokens:     a.usage.PromptTokens,
+		CompletionTokens: a.usage.CompletionTokens,
+	}
+	if !a.status.started.IsZero() {
+		ev.DurationSeconds = int(time.Since(a.status.started).Seconds())
+	}
+	if runErr != nil {
+		ev.ErrorClass = exitOutcomes[code] // the error class behind the exit code, see exitcode.go
+	}
+	return ev
+}
+
+// recordTelemetry stores an event about the run when telemetry is on or local, and with
+// "on" uploads the events not sent yet. Nothing about it can fail the run.
+func (a *AutonomousCodingAgent) recordTelemetry(runErr error) {
+	s, err := loadTelemetry()
+	if err != nil || s.Mode == telemetryOff {
+		return
+	}
+	if s.InstallID == "" {
+		// Turned on by ZUG_TELEMETRY rather than zug telemetry: keep the id for the next
+		// runs, but leave the saved mode alone.
+		saved, err := readTelemetry()
+		if err != nil {
+			return
+		}
+		s.InstallID = newInstallID()
+		saved.InstallID = s.InstallID
+		if err := saveTelemetry(saved); err != nil {
+			return
+		}
+	}
+	raw, err := json.Marshal(a.telemetryEvent(s.InstallID, runErr))
+	if err != nil {
+		return
+	}
+	dir, err := userZugDir()
+	if err != nil {
+		return
+	}
+	st, err := openState(dir)
+	if err != nil {
+		return
+	}
+	defer st.db.Close()
+	if _, err := st.db.Exec(`INSERT INTO telemetry (time, event) VALUES (?, ?)`, stateTime(time.Now()), string(raw)); err != nil {
+		return
+	}
+	if s.Mode == telemetryOn {
+		if err := uploadTelemetry(st, s.Endpoint); err != nil {
+			log.Printf("[agent] Telemetry upload failed, will retry after the next run: %v\n", err)
+		}
+	}
+}
+
+// uploadTelemetry posts the unsent events to endpoint as a JSON array and marks them sent.
+func uploadTelemetry(st *stateStore, endpoint string) error {
+	rows, err := st.db.Query(`SELECT id, event FROM telemetry WHERE sent = 0 ORDER BY id LIMIT ?`, telemetryBatch)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	var events []json.RawMessage
+	for rows.Next() {
+		var id int64
+		var event string
+		if err := rows.Scan(&id, &event); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+		events = append(events, json.RawMessage(event))
+	}
+	rows.Close()
+	if len(events) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: telemetryTimeout}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s answered %s", endpoint, resp.Status)
+	}
+	for _, id := range ids {
+		if _, err := st.db.Exec(`UPDATE telemetry SET sent = 1 WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*──────────────────────────────
+  zug telemetry
+  ─────────────────────────────*/
+
+func telemetryCommand(fs *flag.FlagSet) func() {
+	endpoint := fs.String("endpoint", "", "with on: URL the events are posted to, as a JSON array")
+	return func() {
+		s, err := readTelemetry()
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		switch action := arg(fs.Args(), 0); action {
+		case "", "status":
+			effective, err := loadTelemetry()
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			fmt.Printf("Telemetry: %s\n", effective.Mode)
+			if effective.Mode == telemetryOn {
+				fmt.Printf("Endpoint:  %s\n", effective.Endpoint)
+			}
+			if effective.Mode != cmp.Or(s.Mode, telemetryOff) {
+				fmt.Println("(set by ZUG_TELEMETRY or DO_NOT_TRACK)")
+			}
+			fmt.Println("\nAn event per run looks like this; it never holds the task, prompts, code, file names or output:")
+			a := &AutonomousCodingAgent{model: "gpt-4o", status: runStatus{started: time.Now().Add(-95 * time.Second), turn: 3, testsRan: true, verified: true, failures: map[string]int{failTests: 1}}}
+			sample, _ := json.MarshalIndent(a.telemetryEvent(cmp.Or(s.InstallID, "(random id)"), nil), "", "  ")
+			fmt.Println(string(sample))
+		case telemetryOn, telemetryLocal:
+			if *endpoint != "" {
+				u, err := url.Parse(*endpoint)
+				if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+					log.Fatalf("❌ -endpoint must be an http(s) URL")
+				}
+				s.Endpoint = *endpoint
+			}
+			if action == telemetryOn && s.Endpoint == "" {
+				log.Fatalf("❌ zug telemetry on needs -endpoint, the URL to send the events to. To only keep them on this machine, use zug telemetry local.")
+			}
+			if s.InstallID == "" {
+				s.InstallID = newInstallID()
+			}
+			s.Mode = action
+			if err := saveTelemetry(s); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			if action == telemetryOn {
+				fmt.Printf("Telemetry is on: an event about each run is kept in ~/.zug/state.db and sent to %s.\n", s.Endpoint)
+			} else {
+				fmt.Println("Telemetry is local: an event about each run is kept in ~/.zug/state.db and never sent. See them with zug telemetry show.")
+			}
+		case telemetryOff:
+			s.Mode = telemetryOff
+			if err := saveTelemetry(s); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			fmt.Println("Telemetry is off. Events recorded so far stay until zug telemetry reset.")
+		case "reset":
+			st := openTelemetryStore()
+			defer st.db.Close()
+			if _, err := st.db.Exec(`DELETE FROM telemetry`); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			if s.InstallID != "" {
+				s.InstallID = newInstallID()
+				if err := saveTelemetry(s); err != nil {
+					log.Fatalf("❌ %v", err)
+				}
+			}
+			fmt.Println("Deleted the recorded events and started a new install id.")
+		case "show":
+			st := openTelemetryStore()
+			defer st.db.Close()
+			showTelemetry(st)
+		default:
+			fs.Usage()
+			os.Exit(1)
+		}
+	}
+}
+
+func openTelemetryStore() *stateStore {
+	dir, err := userZugDir()
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	st, err := openState(dir)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	return st
+}
+
+// showTelemetry summarizes the recorded events: success rate and turns overall and per
+// model, and the most common error classes and failures.
+func showTelemetry(st *stateStore) {
+	rows, err := st.db.Query(`SELECT event FROM telemetry ORDER BY id`)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	defer rows.Close()
+	type tally struct{ runs, successes, turns int }
+	total, byModel := tally{}, map[string]*tally{}
+	counts := map[string]int{}
+	for rows.Next() {
+		var raw string
+		var ev telemetryEvent
+		if rows.Scan(&raw) != nil || json.Unmarshal([]byte(raw), &ev) != nil {
+			continue
+		}
+		m := byModel[ev.Model]
+		if m == nil {
+			m = &tally{}
+			byModel[ev.Model] = m
+		}
+		for _, t := range []*tally{&total, m} {
+			t.runs++
+			t.turns += ev.Turns
+			if ev.Success {
+				t.successes++
+			}
+		}
+		if ev.ErrorClass != "" {
+			counts["ended by "+ev.ErrorClass]++
+		}
+		for kind, n := range ev.Failures {
+			counts["recovered from "+kind] += n
+		}
+	}
+	if total.runs == 0 {
+		fmt.Println("No events recorded. Turn on local telemetry with: zug telemetry local")
+		return
+	}
+	line := func(name string, t tally) {
+		fmt.Printf("%-24s %5d runs  %3.0f%% verified  %.1f turns on average\n", name, t.runs, 100*float64(t.successes)/float64(t.runs), float64(t.turns)/float64(t.runs))
+	}
+	line("all models", total)
+	models := make([]string, 0, len(byModel))
+	for m := range byModel {
+		models = append(models, m)
+	}
+	sort.Strings(models)
+	for _, m := range models {
+		line("  "+m, *byModel[m])
+	}
+	if len(counts) == 0 {
+		return
+	}
+	kinds := make([]string, 0, len(counts))
+	for k := range counts {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		return counts[kinds[i]] > counts[kinds[j]] || counts[kinds[i]] == counts[kinds[j]] && kinds[i] < kinds[j]
+	})
+	fmt.Println("\nFailures:")
+	for _, k := range kinds {
+		fmt.Printf("  %4d  %s\n", counts[k], k)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestTelemetrySendsOnlyRunOutcome(t *testing.T) {
+	a, _ := newTestAgent(t, nil, mock.Text("Done."))
+	t.Setenv("ZUG_TELEMETRY", "")
+	t.Setenv("DO_NOT_TRACK", "")
+	var bodies []string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer endpoint.Close()
+	if err := saveTelemetry(telemetrySettings{Mode: telemetryOn, Endpoint: endpoint.URL, InstallID: "abc"}); err != nil {
+		t.Fatal(err)
+	}
+
+	task := "rename the secret-project-codename module"
+	if err := a.feedbackLoop(task); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 1 {
+		t.Fatalf("%d uploads, want 1", len(bodies))
+	}
+	if strings.Contains(bodies[0], "secret-project-codename") || strings.Contains(bodies[0], a.projectDir) {
+		t.Errorf("the event leaks the task or project: %s", bodies[0])
+	}
+	var events []telemetryEvent
+	if err := json.Unmarshal([]byte(bodies[0]), &events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].InstallID != "abc" || events[0].Model != "mock-model" || events[0].Turns != 1 || events[0].Outcome == "" {
+		t.Errorf("events = %+v", events)
+	}
+
+	// Sent events are not sent again.
+	st := openTelemetryStore()
+	defer st.db.Close()
+	if err := uploadTelemetry(st, endpoint.URL); err != nil || len(bodies) != 1 {
+		t.Errorf("second upload: err = %v, %d uploads", err, len(bodies))
+	}
+}
+
+func TestTelemetrySettings(t *testing.T) {
+	t.Setenv("ZUG_HOME", t.TempDir())
+	t.Setenv("ZUG_TELEMETRY", "")
+	t.Setenv("DO_NOT_TRACK", "")
+	if s, err := loadTelemetry(); err != nil || s.Mode != telemetryOff {
+		t.Errorf("default mode = %q, %v", s.Mode, err)
+	}
+	if err := saveTelemetry(telemetrySettings{Mode: telemetryOn}); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := loadTelemetry(); s.Mode != telemetryLocal {
+		t.Errorf("on without an endpoint = %q, want local", s.Mode)
+	}
+	t.Setenv("DO_NOT_TRACK", "1")
+	if s, _ := loadTelemetry(); s.Mode != telemetryOff {
+		t.Errorf("with DO_NOT_TRACK = %q, want off", s.Mode)
+	}
+	if got := telemetryModel("ft:gpt-4o-mini:acme::abc123"); got != "ft:gpt-4o-mini" {
+		t.Errorf("telemetryModel = %q", got)
+	}
+}
+
+func TestTelemetryFromEnvironmentKeepsInstallID(t *testing.T) {
+	a, _ := newTestAgent(t, nil)
+	t.Setenv("ZUG_TELEMETRY", telemetryLocal)
+	t.Setenv("DO_NOT_TRACK", "")
+
+	a.recordTelemetry(errProvider)
+	saved, err := readTelemetry()
+	if err != nil || saved.InstallID == "" || saved.Mode != telemetryOff {
+		t.Fatalf("saved settings = %+v, %v; want an install id and the mode left off", saved, err)
+	}
+	a.recordTelemetry(errMaxTurns)
+
+	st := openTelemetryStore()
+	defer st.db.Close()
+	rows, err := st.db.Query(`SELECT event FROM telemetry ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var classes []string
+	for rows.Next() {
+		var raw string
+		var ev telemetryEvent
+		if err := rows.Scan(&raw); err != nil || json.Unmarshal([]byte(raw), &ev) != nil {
+			t.Fatalf("bad event %q: %v", raw, err)
+		}
+		if ev.InstallID != saved.InstallID {
+			t.Errorf("install id = %q, want %q", ev.InstallID, saved.InstallID)
+		}
+		classes = append(classes, ev.ErrorClass)
+	}
+	if strings.Join(classes, ",") != "provider,max_turns" {
+		t.Errorf("error classes = %v, want provider,max_turns", classes)
+	}
+}
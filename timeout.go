@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*──────────────────────────────
+  Overall time limit (--timeout)
+  ─────────────────────────────*/
+
+var errTimeout = errors.New("time limit reached")
+
+// setTimeout bounds the run to d of wall-clock time: model requests and shell commands
+// in flight when it runs out are cancelled, and the run stops as it does on Ctrl-C.
+func (a *AutonomousCodingAgent) setTimeout(d time.Duration) context.CancelFunc {
+	a.timeout = d
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	a.runCtx = ctx
+	return cancel
+}
+
+// context is the context of the run's model requests and shell commands.
+func (a *AutonomousCodingAgent) context() context.Context {
+	if a.runCtx == nil {
+		return context.Background()
+	}
+	return a.runCtx
+}
+
+// execShellWithin runs cmd like execShell, but cancels it after d. timedOut tells
+// whether d ran out, as opposed to the whole run stopping.
+func (a *AutonomousCodingAgent) execShellWithin(cmd string, d time.Duration) (out string, timedOut bool, err error) {
+	parent := a.runCtx
+	ctx, cancel := context.WithTimeout(a.context(), d)
+	defer cancel()
+	a.runCtx = ctx
+	out, err = a.execShell(cmd)
+	a.runCtx = parent
+	return out, errors.Is(ctx.Err(), context.DeadlineExceeded) && a.stopped() == nil, err
+}
+
+// stopped tells why the run must stop now: errInterrupted after Ctrl-C, errTimeout once
+// the time limit ran out, or nil to go on.
+func (a *AutonomousCodingAgent) stopped() error {
+	switch {
+	case interruptRequested():
+		return errInterrupted
+	case a.context().Err() != nil:
+		return fmt.Errorf("%w (--timeout %s)", errTimeout, a.timeout)
+	}
+	return nil
+}
+
+// stoppedCallNote is the result of a tool call skipped because the run stopped, so the
+// conversation stays valid for resuming.
+func stoppedCallNote(reason error) string {
+	return fmt.Sprintf("NOT RUN: the run was stopped before this call: %v.", reason)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"zug/provider/mock"
+)
+
+func TestTimeoutKillsCommandAndStops(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sleep")
+	}
+	a, srv := newTestAgent(t, nil,
+		mock.Calls(mock.Tool("run_shell", map[string]any{"command": "sleep 30"})),
+	)
+	cancel := a.setTimeout(300 * time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := a.chat("wait", phaseTools)
+	if !errors.Is(err, errTimeout) {
+		t.Fatalf("err = %v, want errTimeout", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("took %s; the command was not killed", d)
+	}
+	if n := len(srv.Requests()); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+	assertContains(t, a.ctx[len(a.ctx)-1].Content, "killed")
+}
+
+func TestTimedOutRunReportsProgress(t *testing.T) {
+	a, _ := newTestAgent(t, nil)
+	cancel := a.setTimeout(time.Nanosecond)
+	defer cancel()
+	time.Sleep(time.Millisecond)
+
+	err := a.feedbackLoop("anything")
+	if !errors.Is(err, errTimeout) {
+		t.Fatalf("err = %v, want errTimeout", err)
+	}
+	if code := a.exitCode(err); code != exitTimeout {
+		t.Errorf("exit code = %d, want %d", code, exitTimeout)
+	}
+	assertContains(t, readTestFile(t, a.projectDir, progressFileName), "Resume the run")
+}
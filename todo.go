@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*──────────────────────────────
+  Task plan / TODO tracking (tools)
+  ─────────────────────────────*/
+
+type stepStatus string
+
+const (
+	stepPending    stepStatus = "pending"
+	stepInProgress stepStatus = "in_progress"
+	stepDone       stepStatus = "done"
+)
+
+// planStep is one entry of the checklist the model maintains through update_plan.
+type planStep struct {
+	Title  string
+	Status stepStatus
+}
+
+// parseChecklist reads a Markdown checklist: "- [ ] todo", "- [~] in progress", "- [x] done".
+// Lines without a checkbox are treated as pending steps.
+func parseChecklist(text string) ([]planStep, error) {
+	var steps []planStep
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		for _, marker := range []string{"- ", "* "} {
+			if rest, ok := strings.CutPrefix(line, marker); ok {
+				line = strings.TrimSpace(rest)
+				break
+			}
+		}
+		if line == "" || line == "-" || line == "*" {
+			continue
+		}
+		step := planStep{Title: line, Status: stepPending}
+		if len(line) >= 3 && line[0] == '[' && line[2] == ']' {
+			switch line[1] {
+			case ' ':
+				step.Status = stepPending
+			case '~', '>':
+				step.Status = stepInProgress
+			case 'x', 'X':
+				step.Status = stepDone
+			default:
+				return nil, fmt.Errorf("unknown checkbox %q in line %q (use [ ], [~] or [x])", line[:3], line)
+			}
+			step.Title = strings.TrimSpace(line[3:])
+		}
+		if step.Title == "" {
+			continue
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("plan contains no steps")
+	}
+	return steps, nil
+}
+
+// renderPlan formats the checklist for both the user and the model.
+func renderPlan(steps []planStep) string {
+	if len(steps) == 0 {
+		return "No plan recorded yet. Use update_plan to create one."
+	}
+	done := 0
+	var sb strings.Builder
+	for _, s := range steps {
+		icon := "⬜"
+		switch s.Status {
+		case stepInProgress:
+			icon = "🔄"
+		case stepDone:
+			icon = "✅"
+			done++
+		}
+		fmt.Fprintf(&sb, "  %s %s\n", icon, s.Title)
+	}
+	return fmt.Sprintf("Task plan (%d/%d done):\n%s", done, len(steps), sb.String())
+}
+
+// updatePlan replaces the tracked checklist and shows the progress to the user.
+func (a *AutonomousCodingAgent) updatePlan(checklist string) (string, error) {
+	steps, err := parseChecklist(checklist)
+	if err != nil {
+		return "", err
+	}
+	a.todo = steps
+	rendered := renderPlan(steps)
+	fmt.Printf("📋 %s\n", rendered)
+	return rendered, nil
+}
+
+func (a *AutonomousCodingAgent) readPlan() (string, error) {
+	return renderPlan(a.todo), nil
+}
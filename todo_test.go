@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestParseChecklist(t *testing.T) {
+	steps, err := parseChecklist("- [x] **Fix** the parser\n* [~] Add tests\n-\n  - [ ] *Update* docs\nRelease\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []planStep{
+		{"**Fix** the parser", stepDone},
+		{"Add tests", stepInProgress},
+		{"*Update* docs", stepPending},
+		{"Release", stepPending},
+	}
+	if len(steps) != len(want) {
+		t.Fatalf("steps = %+v, want %+v", steps, want)
+	}
+	for i := range want {
+		if steps[i] != want[i] {
+			t.Errorf("step %d = %+v, want %+v", i, steps[i], want[i])
+		}
+	}
+	if _, err := parseChecklist("- [?] unknown"); err == nil {
+		t.Error("an unknown checkbox was accepted")
+	}
+}
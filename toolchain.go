@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+/*──────────────────────────────
+  Language / toolchain detection
+  ─────────────────────────────*/
+
+// toolchain describes one language ecosystem found in the project and its default commands.
+type toolchain struct {
+	Language      string
+	BuildTool     string
+	TestFramework string
+	Manifest      string
+	BuildCmd      string
+	TestCmd       string
+	LintCmd       string
+}
+
+// detectToolchains inspects manifest files in dir. The first entry is the primary toolchain.
+func (a *AutonomousCodingAgent) detectToolchains(dir string) []toolchain {
+	has := func(name string) bool {
+		_, err := a.fs.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	read := func(name string) string {
+		raw, _ := a.fs.ReadFile(filepath.Join(dir, name))
+		return string(raw)
+	}
+	installed := a.installed
+
+	var found []toolchain
+	if has("go.mod") {
+		tc := toolchain{Language: "Go", BuildTool: "go modules", TestFramework: "go test", Manifest: "go.mod",
+			BuildCmd: "go build ./...", TestCmd: "go test ./..."}
+		if installed("golangci-lint") {
+			tc.LintCmd = "golangci-lint run ./..."
+		} else {
+			tc.LintCmd = "go vet ./..."
+		}
+		found = append(found, tc)
+	}
+	if has("Cargo.toml") {
+		found = append(found, toolchain{Language: "Rust", BuildTool: "cargo", TestFramework: "cargo test", Manifest: "Cargo.toml",
+			BuildCmd: "cargo build --quiet", TestCmd: "cargo test --quiet", LintCmd: "cargo clippy --quiet -- -D warnings"})
+	}
+	if has("package.json") {
+		found = append(found, detectNode(has, read))
+	}
+	if has("pyproject.toml") || has("setup.py") || has("requirements.txt") {
+		tc := toolchain{Language: "Python", BuildTool: "pip", BuildCmd: "python -m compileall -q ."}
+		pyproject := read("pyproject.toml")
+		switch {
+		case has("uv.lock"):
+			tc.BuildTool = "uv"
+		case strings.Contains(pyproject, "[tool.poetry]"):
+			tc.BuildTool = "poetry"
+		case strings.Contains(pyproject, "[tool.hatch"):
+			tc.BuildTool = "hatch"
+		case has("setup.py"):
+			tc.BuildTool = "setuptools"
+		}
+		for _, m := range []string{"pyproject.toml", "setup.py", "requirements.txt"} {
+			if has(m) {
+				tc.Manifest = m
+				break
+			}
+		}
+		if strings.Contains(pyproject+read("requirements.txt")+read("requirements-dev.txt"), "pytest") || has("tests") || has("pytest.ini") {
+			tc.TestFramework = "pytest"
+			tc.TestCmd = "python -m pytest -q --maxfail=1 --disable-warnings"
+		}
+		if installed("ruff") {
+			tc.LintCmd = "ruff check ."
+		} else if installed("flake8") {
+			tc.LintCmd = "flake8 ."
+		}
+		found = append(found, tc)
+	}
+	return found
+}
+
+// detectNode reads package.json scripts and dependencies.
+func detectNode(has func(string) bool, read func(string) string) toolchain {
+	tc := toolchain{Language: "JavaScript", BuildTool: "npm", Manifest: "package.json"}
+	if has("tsconfig.json") {
+		tc.Language = "TypeScript"
+	}
+	run := "npm run"
+	switch {
+	case has("pnpm-lock.yaml"):
+		tc.BuildTool, run = "pnpm", "pnpm run"
+	case has("yarn.lock"):
+		tc.BuildTool, run = "yarn", "yarn run"
+	}
+
+	var pkg struct {
+		Scripts         map[string]string `json:"scripts"`
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	_ = json.Unmarshal([]byte(read("package.json")), &pkg) // a broken package.json just yields no scripts
+	for _, fw := range []string{"vitest", "jest", "mocha", "ava"} {
+		if _, ok := pkg.DevDependencies[fw]; ok {
+			tc.TestFramework = fw
+			break
+		}
+		if _, ok := pkg.Dependencies[fw]; ok {
+			tc.TestFramework = fw
+			break
+		}
+	}
+	if test := pkg.Scripts["test"]; test != "" && !strings.Contains(test, "no test specified") {
+		tc.TestCmd = run + " test"
+	}
+	if pkg.Scripts["build"] != "" {
+		tc.BuildCmd = run + " build"
+	} else if tc.Language == "TypeScript" {
+		tc.BuildCmd = "npx --no-install tsc --noEmit"
+	}
+	if pkg.Scripts["lint"] != "" {
+		tc.LintCmd = run + " lint"
+	} else if has(filepath.Join("node_modules", ".bin", "eslint")) {
+		tc.LintCmd = "npx --no-install eslint ."
+	}
+	return tc
+}
+
+// toolchainNames lists the languages and manifests for a log line, e.g. "Go (go.mod)".
+func toolchainNames(tcs []toolchain) string {
+	names := make([]string, len(tcs))
+	for i, tc := range tcs {
+		names[i] = fmt.Sprintf("%s (%s)", tc.Language, tc.Manifest)
+	}
+	return strings.Join(names, ", ")
+}
+
+// describeToolchains renders the detection result for the system prompt.
+func describeToolchains(tcs []toolchain) string {
+	if len(tcs) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("Detected project toolchain:")
+	for _, tc := range tcs {
+		fmt.Fprintf(&sb, "\n- %s (%s, from %s)", tc.Language, tc.BuildTool, tc.Manifest)
+		if tc.TestFramework != "" {
+			fmt.Fprintf(&sb, "; tests: %s", tc.TestFramework)
+		}
+		for _, c := range []struct{ label, cmd string }{{"build", tc.BuildCmd}, {"test", tc.TestCmd}, {"lint", tc.LintCmd}} {
+			if c.cmd != "" {
+				fmt.Fprintf(&sb, "; %s: `%s`", c.label, c.cmd)
+			}
+		}
+	}
+	sb.WriteString("\nFollow the conventions of this toolchain and use these commands to verify your work.")
+	return sb.String()
+}
+
+// toolchainCommand returns the first non-empty command picked by pick across detected toolchains.
+func toolchainCommand(tcs []toolchain, pick func(toolchain) string) string {
+	for _, tc := range tcs {
+		if cmd := pick(tc); cmd != "" {
+			return cmd
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Tool choice
+  ─────────────────────────────*/
+
+// turnTools constrains the tools of the first request of the next chat turn, so a
+// structured step such as "read the failing test first" does not depend on the model
+// choosing to. Later requests of the turn are left free, or the model could never answer.
+type turnTools struct {
+	tool     string // the model must call this tool
+	required bool   // the model must call some tool rather than answer
+}
+
+// constrainTools applies c to req: through tool_choice with native function calling, or as
+// an instruction at the end of the last message with the text protocol. A tool that is
+// not offered in this mode only requires some tool call.
+func (a *AutonomousCodingAgent) constrainTools(req *openai.ChatCompletionRequest, c turnTools) {
+	if c.tool == "" && !c.required {
+		return
+	}
+	if a.usesTextTools() {
+		note := "\n\nCall at least one tool in this reply; do not answer yet."
+		if c.tool != "" && a.toolAllowed(c.tool) {
+			note = fmt.Sprintf("\n\nCall the %s tool in this reply; do not answer yet.", c.tool)
+		}
+		req.Messages = slices.Clone(req.Messages)
+		req.Messages[len(req.Messages)-1].Content += note
+		return
+	}
+	if len(req.Tools) == 0 {
+		return
+	}
+	offered := slices.ContainsFunc(req.Tools, func(t openai.Tool) bool { return t.Function != nil && t.Function.Name == c.tool })
+	if c.tool != "" && offered {
+		req.ToolChoice = openai.ToolChoice{Type: openai.ToolTypeFunction, Function: openai.ToolFunction{Name: c.tool}}
+		log.Printf("[agent] 🎯 This step must start with %s.\n", c.tool)
+		return
+	}
+	req.ToolChoice = "required"
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestToolChoiceAppliesToFirstRequestOfTurn(t *testing.T) {
+	a, srv := newTestAgent(t, map[string]string{"main_test.go": "package main\n"},
+		mock.Call("read_file", map[string]string{"path": "main_test.go"}),
+		mock.Text("Read it."),
+	)
+	a.serialTools = true
+	a.nextTurn = turnTools{tool: "read_file"}
+	if _, err := a.chat("the tests fail", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	reqs := srv.Requests()
+	choice, ok := reqs[0].ToolChoice.(map[string]any)
+	if !ok || choice["function"].(map[string]any)["name"] != "read_file" {
+		t.Errorf("first tool_choice = %#v, want read_file", reqs[0].ToolChoice)
+	}
+	if reqs[1].ToolChoice != "auto" {
+		t.Errorf("second tool_choice = %#v, want auto", reqs[1].ToolChoice)
+	}
+	for i, r := range reqs {
+		if r.ParallelToolCalls != false {
+			t.Errorf("request %d: parallel_tool_calls = %#v, want false", i+1, r.ParallelToolCalls)
+		}
+	}
+	if a.nextTurn != (turnTools{}) {
+		t.Errorf("constraint not cleared: %+v", a.nextTurn)
+	}
+}
+
+func TestToolChoiceWithTextProtocol(t *testing.T) {
+	a, srv := newTestAgent(t, nil, mock.Text("Nothing to do."))
+	a.textTools = true
+	a.nextTurn = turnTools{required: true}
+	if _, err := a.chat("fix the build", phaseTools); err != nil {
+		t.Fatal(err)
+	}
+	msgs := srv.Requests()[0].Messages
+	assertContains(t, msgs[len(msgs)-1].Content, "Call at least one tool in this reply")
+	if last := a.ctx[len(a.ctx)-2]; last.Content != "fix the build" {
+		t.Errorf("the instruction leaked into the conversation: %q", last.Content)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  Tool usage statistics
+  ─────────────────────────────*/
+
+const summaryMaxTools = 8 // tools listed by name in the run summary
+
+const nothingReplaced = "nothing replaced in " // update_file's answer when find matched nothing
+
+// toolStat counts the executed calls of one tool in the current run. Calls answered
+// without running the tool (repeats, interrupts) are not counted; calls that did nothing
+// (see wastedCall) count as failures.
+type toolStat struct {
+	calls       int
+	failures    int
+	resultBytes int           // size of the results sent to the model
+	latency     time.Duration // time spent executing
+}
+
+// toolUsage is a toolStat in JSON output: eval results and the on_complete hook event.
+type toolUsage struct {
+	Tool           string  `json:"tool"`
+	Calls          int     `json:"calls"`
+	Failures       int     `json:"failures"`
+	FailureRate    float64 `json:"failure_rate"` // failures / calls
+	AvgResultBytes int     `json:"avg_result_bytes"`
+	AvgLatencyMS   float64 `json:"avg_latency_ms"`
+}
+
+// recordToolCall adds one executed call of tool to the run's statistics.
+func (a *AutonomousCodingAgent) recordToolCall(tool string, took time.Duration, resultBytes int, failed bool) {
+	if a.status.tools == nil {
+		a.status.tools = map[string]*toolStat{}
+	}
+	s := a.status.tools[tool]
+	if s == nil {
+		s = &toolStat{}
+		a.status.tools[tool] = s
+	}
+	s.calls++
+	s.resultBytes += resultBytes
+	s.latency += took
+	if failed {
+		s.failures++
+	}
+}
+
+// wastedCall reports whether a call that did not fail still did nothing, like an
+// update_file whose find pattern matched nothing. It counts as a failure in the statistics.
+func wastedCall(tool, result string) bool {
+	return tool == "update_file" && strings.HasPrefix(result, nothingReplaced)
+}
+
+// toolUsage lists the statistics per tool, most called first.
+func (a *AutonomousCodingAgent) toolUsage() []toolUsage {
+	usage := make([]toolUsage, 0, len(a.status.tools))
+	for name, s := range a.status.tools {
+		usage = append(usage, toolUsage{
+			Tool:           name,
+			Calls:          s.calls,
+			Failures:       s.failures,
+			FailureRate:    float64(s.failures) / float64(s.calls),
+			AvgResultBytes: s.resultBytes / s.calls,
+			AvgLatencyMS:   float64(s.latency.Microseconds()) / 1000 / float64(s.calls),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Calls > usage[j].Calls || (usage[i].Calls == usage[j].Calls && usage[i].Tool < usage[j].Tool)
+	})
+	return usage
+}
+
+// toolSummary is the Tools section of the run summary: the totals, then one line per
+// tool with its share of the calls, failure rate, average result size and latency.
+func (a *AutonomousCodingAgent) toolSummary() string {
+	usage := a.toolUsage()
+	if len(usage) == 0 {
+		return ""
+	}
+	var calls, failures int
+	for _, u := range usage {
+		calls, failures = calls+u.Calls, failures+u.Failures
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "   Tools:    %d call(s), %d failed (%.0f%%)\n", calls, failures, 100*float64(failures)/float64(calls))
+	for i, u := range usage {
+		if i == summaryMaxTools {
+			fmt.Fprintf(&sb, "     … and %d more\n", len(usage)-summaryMaxTools)
+			break
+		}
+		fmt.Fprintf(&sb, "     %-20s %3d (%3.0f%% of calls)  %3.0f%% failed  avg %s, %s\n", u.Tool, u.Calls,
+			100*float64(u.Calls)/float64(calls), 100*u.FailureRate, compactBytes(u.AvgResultBytes), formatLatency(u.AvgLatencyMS))
+	}
+	return sb.String()
+}
+
+func compactBytes(n int) string {
+	if n >= 1024 {
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+func formatLatency(ms float64) string {
+	if ms >= 1000 {
+		return fmt.Sprintf("%.1fs", ms/1000)
+	}
+	return fmt.Sprintf("%.0fms", ms)
+}
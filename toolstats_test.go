@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestToolUsageStatistics(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{"main.txt": "one\n"},
+		mock.Call("update_file", map[string]any{"path": "main.txt", "find": "two", "replace": "2"}),
+		mock.Call("update_file", map[string]any{"path": "main.txt", "find": "three", "replace": "3"}),
+		mock.Call("update_file", map[string]any{"path": "main.txt", "find": "one", "replace": "1"}),
+		mock.Call("read_file", map[string]any{"path": "main.txt"}),
+		mock.Text("done"),
+		mock.Text(verifyApproved),
+	)
+	if err := a.feedbackLoop("number the lines"); err != nil {
+		t.Fatal(err)
+	}
+	usage := a.toolUsage()
+	if len(usage) != 2 || usage[0].Tool != "update_file" || usage[0].Calls != 3 || usage[0].Failures != 2 || usage[1].Failures != 0 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	if usage[1].AvgResultBytes == 0 {
+		t.Errorf("read_file result size not counted: %+v", usage[1])
+	}
+	summary := a.runSummary()
+	assertContains(t, summary, "Tools:    4 call(s), 2 failed (50%)")
+	assertContains(t, summary, "update_file            3 ( 75% of calls)   67% failed")
+}
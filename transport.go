@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Provider HTTP client
+  ─────────────────────────────*/
+
+// Environment variables that shape the connection to the model API, for corporate proxies
+// and self-hosted gateways. HTTPS_PROXY, HTTP_PROXY and NO_PROXY work as usual.
+const (
+	envBaseURL    = "OPENAI_BASE_URL" // e.g. https://llm-gateway.corp.example/v1
+	envCABundle   = "ZUG_CA_BUNDLE"   // PEM file of extra CA certificates to trust
+	envClientCert = "ZUG_CLIENT_CERT" // PEM client certificate for mutual TLS
+	envClientKey  = "ZUG_CLIENT_KEY"  // its private key
+)
+
+// providerConfig builds the go-openai client configuration from the environment.
+func providerConfig(apiKey string) (openai.ClientConfig, error) {
+	cfg := openai.DefaultConfig(apiKey)
+	if base := os.Getenv(envBaseURL); base != "" {
+		if u, err := url.Parse(base); err != nil || u.Scheme == "" || u.Host == "" {
+			return cfg, fmt.Errorf("%s=%q is not an absolute URL", envBaseURL, base)
+		}
+		cfg.BaseURL = base
+		log.Printf("[agent] Using API base URL %s\n", base)
+	}
+	client, err := providerHTTPClient(cfg.BaseURL)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.HTTPClient = client
+	return cfg, nil
+}
+
+// providerHTTPClient returns a client that honours the proxy environment variables and
+// the custom TLS settings above.
+func providerHTTPClient(baseURL string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if req, err := http.NewRequest(http.MethodGet, baseURL, nil); err == nil {
+		if proxy, err := http.ProxyFromEnvironment(req); err != nil {
+			return nil, fmt.Errorf("invalid proxy setting: %w", err)
+		} else if proxy != nil {
+			log.Printf("[agent] Using proxy %s\n", proxy.Redacted())
+		}
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if path := os.Getenv(envCABundle); path != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", envCABundle, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s (%s) contains no PEM certificates", envCABundle, path)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	certFile, keyFile := os.Getenv(envClientCert), os.Getenv(envClientKey)
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("mutual TLS needs both %s and %s", envClientCert, envClientKey)
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*──────────────────────────────
+  Dependency upgrades
+  ─────────────────────────────*/
+
+// depUpdate is one dependency with a newer version available.
+type depUpdate struct {
+	name, from, to string
+}
+
+// depGroup is a set of dependencies upgraded, verified and rolled back together, e.g.
+// all golang.org/x modules or all @babel packages.
+type depGroup struct {
+	eco  *depEcosystem
+	name string
+	deps []depUpdate
+}
+
+func (g depGroup) String() string {
+	var parts []string
+	for _, d := range g.deps {
+		parts = append(parts, fmt.Sprintf("%s %s → %s", d.name, d.from, d.to))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// depEcosystem knows how to find and apply upgrades for one package manager.
+type depEcosystem struct {
+	name      string
+	detect    string   // file whose presence enables the ecosystem
+	manifests []string // files the upgrade changes, restored on rollback
+	tool      string   // binary that must be installed
+	outdated  string   // command listing the available upgrades
+	parse     func(out string, major bool) ([]depUpdate, error)
+	bump      func(deps []depUpdate) string // command applying an upgrade
+	sync      string                        // command reinstalling after a rollback
+	group     func(name string) string      // upgrade group of a dependency
+}
+
+var depEcosystems = []*depEcosystem{
+	{
+		name: "go", detect: "go.mod", manifests: []string{"go.mod", "go.sum"}, tool: "go",
+		outdated: "go list -m -u -json all 2>/dev/null",
+		parse:    parseGoOutdated,
+		bump: func(deps []depUpdate) string {
+			var args []string
+			for _, d := range deps {
+				args = append(args, shellQuote(d.name+"@"+d.to))
+			}
+			return "go get " + strings.Join(args, " ") + " && go mod tidy"
+		},
+		group: func(name string) string {
+			if strings.HasPrefix(name, "golang.org/x/") {
+				return "golang.org/x"
+			}
+			if parts := strings.Split(name, "/"); len(parts) > 3 && strings.Contains(parts[0], ".") {
+				return strings.Join(parts[:3], "/") // github.com/aws/aws-sdk-go-v2/service/s3 → github.com/aws/aws-sdk-go-v2
+			}
+			return name
+		},
+	},
+	{
+		name: "npm", detect: "package.json", manifests: []string{"package.json", "package-lock.json"}, tool: "npm",
+		outdated: "npm outdated --json 2>/dev/null",
+		parse:    parseNpmOutdated,
+		bump: func(deps []depUpdate) string {
+			var args []string
+			for _, d := range deps {
+				args = append(args, shellQuote(d.name+"@"+d.to))
+			}
+			return "npm install --no-audit --no-fund " + strings.Join(args, " ")
+		},
+		sync: "npm install --no-audit --no-fund",
+		group: func(name string) string {
+			if scope, _, ok := strings.Cut(name, "/"); ok && strings.HasPrefix(scope, "@") {
+				return scope
+			}
+			return name
+		},
+	},
+	{
+		name: "pip-compile", detect: "requirements.in", manifests: []string{"requirements.in", "requirements.txt"}, tool: "pip-compile",
+		outdated: "python -m pip list --outdated --format=json 2>/dev/null",
+		parse:    parsePipOutdated,
+		bump: func(deps []depUpdate) string {
+			var args []string
+			for _, d := range deps {
+				args = append(args, "--upgrade-package "+shellQuote(d.name+"=="+d.to))
+			}
+			return "pip-compile --quiet " + strings.Join(args, " ") + " && python -m pip install -q -r requirements.txt"
+		},
+		sync:  "python -m pip install -q -r requirements.txt",
+		group: func(name string) string { return name },
+	},
+}
+
+// parseGoOutdated reads the JSON stream of `go list -m -u -json all`. Only direct
+// dependencies are upgraded; go mod tidy takes care of the indirect ones.
+func parseGoOutdated(out string, _ bool) ([]depUpdate, error) {
+	var updates []depUpdate
+	dec := json.NewDecoder(strings.NewReader(out))
+	for {
+		var m struct {
+			Path, Version  string
+			Main, Indirect bool
+			Update         *struct{ Version string }
+		}
+		if err := dec.Decode(&m); errors.Is(err, io.EOF) {
+			return updates, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("cannot parse go list output: %w", err)
+		}
+		if !m.Main && !m.Indirect && m.Update != nil {
+			updates = append(updates, depUpdate{m.Path, m.Version, m.Update.Version})
+		}
+	}
+}
+
+// parseNpmOutdated reads `npm outdated --json`: the newest version within the range in
+// package.json ("wanted"), or the latest release when major upgrades are allowed.
+func parseNpmOutdated(out string, major bool) ([]depUpdate, error) {
+	if strings.TrimSpace(out) == "" {
+		return nil, nil
+	}
+	var pkgs map[string]struct{ Current, Wanted, Latest string }
+	if err := json.Unmarshal([]byte(out), &pkgs); err != nil {
+		return nil, fmt.Errorf("cannot parse npm outdated output: %w", err)
+	}
+	var updates []depUpdate
+	for name, p := range pkgs {
+		to := p.Wanted
+		if major {
+			to = p.Latest
+		}
+		if p.Current != "" && to != "" && to != p.Current {
+			updates = append(updates, depUpdate{name, p.Current, to})
+		}
+	}
+	return updates, nil
+}
+
+// parsePipOutdated reads `pip list --outdated --format=json`; the caller keeps only the
+// packages pinned in requirements.txt.
+func parsePipOutdated(out string, _ bool) ([]depUpdate, error) {
+	var pkgs []struct {
+		Name          string `json:"name"`
+		Version       string `json:"version"`
+		LatestVersion string `json:"latest_version"`
+	}
+	if err := json.Unmarshal([]byte(out), &pkgs); err != nil {
+		return nil, fmt.Errorf("cannot parse pip list output: %w", err)
+	}
+	var updates []depUpdate
+	for _, p := range pkgs {
+		updates = append(updates, depUpdate{p.Name, p.Version, p.LatestVersion})
+	}
+	return updates, nil
+}
+
+// pinnedRequirements returns the lowercased package names pinned in a requirements file.
+func pinnedRequirements(content string) map[string]bool {
+	names := map[string]bool{}
+	sc := bufio.NewScanner(strings.NewReader(content))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if name, _, ok := strings.Cut(line, "=="); ok && !strings.HasPrefix(line, "#") {
+			name, _, _ = strings.Cut(name, "[")
+			names[strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "_", "-"))] = true
+		}
+	}
+	return names
+}
+
+// outdatedGroups lists the available upgrades of every ecosystem in the project, grouped.
+func (a *AutonomousCodingAgent) outdatedGroups(major bool, only string) ([]depGroup, error) {
+	var groups []depGroup
+	for _, eco := range depEcosystems {
+		if _, err := a.fs.Stat(filepath.Join(a.projectDir, eco.detect)); err != nil {
+			continue
+		}
+		if !a.installed(eco.tool) {
+			log.Printf("[agent] ⚠️ %s found, but %s is not installed; skipping its dependencies.\n", eco.detect, eco.tool)
+			continue
+		}
+		log.Printf("[agent] 📦 Looking for %s upgrades: %s\n", eco.name, eco.outdated)
+		out, err := a.execShell(eco.outdated)
+		if err != nil && !strings.HasPrefix(strings.TrimSpace(out), "{") {
+			return nil, fmt.Errorf("%s: %w\n%s", eco.outdated, err, out) // npm outdated exits 1 when something is outdated
+		}
+		updates, err := eco.parse(out, major)
+		if err != nil {
+			return nil, err
+		}
+		if eco.name == "pip-compile" {
+			raw, _ := a.fs.ReadFile(filepath.Join(a.projectDir, "requirements.txt"))
+			pinned := pinnedRequirements(string(raw))
+			updates = slices.DeleteFunc(updates, func(d depUpdate) bool {
+				return !pinned[strings.ToLower(strings.ReplaceAll(d.name, "_", "-"))]
+			})
+		}
+		byGroup := map[string]*depGroup{}
+		var order []string
+		for _, d := range updates {
+			if only != "" && !strings.Contains(d.name, only) {
+				continue
+			}
+			key := eco.group(d.name)
+			if byGroup[key] == nil {
+				byGroup[key] = &depGroup{eco: eco, name: key}
+				order = append(order, key)
+			}
+			byGroup[key].deps = append(byGroup[key].deps, d)
+		}
+		sort.Strings(order)
+		for _, key := range order {
+			g := byGroup[key]
+			sort.Slice(g.deps, func(i, j int) bool { return g.deps[i].name < g.deps[j].name })
+			groups = append(groups, *g)
+		}
+	}
+	return groups, nil
+}
+
+// Outcomes of upgrading one dependency group.
+const (
+	upgradeApplied    = "upgraded"
+	upgradeFixed      = "upgraded, code fixed"
+	upgradeRolledBack = "rolled back"
+	upgradeFailed     = "could not be installed"
+)
+
+type upgradeResult struct {
+	group  depGroup
+	result string
+	detail string // why it was rolled back, or the files changed to fix it
+}
+
+// upgradeGroup applies one group's upgrade, verifies build and tests, lets the agent fix
+// any breakage, and rolls everything back if that does not work.
+func (a *AutonomousCodingAgent) upgradeGroup(g depGroup) upgradeResult {
+	res := upgradeResult{group: g}
+	a.changes = map[string]*fileChange{}
+	a.ctx = nil
+	for _, m := range g.eco.manifests {
+		a.trackChange(m, filepath.Join(a.projectDir, m))
+	}
+	log.Printf("[agent] 📦 Upgrading %s\n", g)
+	if out, err := a.execShell(g.eco.bump(g.deps)); err != nil {
+		a.rollBackUpgrade(g)
+		res.result, res.detail = upgradeFailed, firstLine(out+" "+err.Error())
+		return res
+	}
+
+	buildOutput, buildOK := a.runBuild()
+	testOutput, testsRan, testsPassed := "", false, true
+	if buildOK {
+		testOutput, testsRan, testsPassed = a.runTests()
+	}
+	if buildOK && testsPassed {
+		res.result = upgradeApplied
+		return res
+	}
+
+	var breakage string
+	if !buildOK {
+		breakage = "The project no longer builds. Build output:\n" + buildOutput
+	} else if testsRan {
+		breakage = "The tests fail. Test output:\n" + testOutput
+	}
+	task := fmt.Sprintf(`The dependencies were upgraded: %s. %s
+
+Adapt the code to the new versions so that the project builds and the tests pass. Do not downgrade, pin or remove the upgraded dependencies, and do not weaken the tests.`, g, breakage)
+	if err := a.feedbackLoop(task); err != nil || !a.status.verified {
+		reason := "the agent could not make the build and tests pass"
+		if err != nil {
+			reason = err.Error()
+		}
+		a.rollBackUpgrade(g)
+		res.result, res.detail = upgradeRolledBack, reason
+		return res
+	}
+	var fixed []string
+	for _, c := range a.netChanges() {
+		if !slices.Contains(g.eco.manifests, c.path) {
+			fixed = append(fixed, c.path)
+		}
+	}
+	res.result, res.detail = upgradeFixed, strings.Join(fixed, ", ")
+	return res
+}
+
+// rollBackUpgrade restores the manifests and every file the agent changed, and
+// reinstalls the previous versions.
+func (a *AutonomousCodingAgent) rollBackUpgrade(g depGroup) {
+	log.Printf("[agent] ↩️ Rolling back %s\n", g)
+	if err := a.revertChanges(); err != nil {
+		log.Printf("[agent] ⚠️ Rollback incomplete: %v\n", err)
+	}
+	if g.eco.sync != "" {
+		if out, err := a.execShell(g.eco.sync); err != nil {
+			log.Printf("[agent] ⚠️ %s failed after the rollback: %v\n%s\n", g.eco.sync, err, out)
+		}
+	}
+}
+
+func upgradeReport(results []upgradeResult) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Dependency upgrade report\n\n_%s_\n\n", time.Now().Format("2006-01-02 15:04"))
+	if len(results) == 0 {
+		sb.WriteString("All dependencies are up to date.\n")
+		return sb.String()
+	}
+	sb.WriteString("| Dependency | From | To | Result | Details |\n|---|---|---|---|---|\n")
+	for _, r := range results {
+		for _, d := range r.group.deps {
+			fmt.Fprintf(&sb, "| `%s` | %s | %s | %s | %s |\n", d.name, d.from, d.to, r.result,
+				strings.ReplaceAll(r.detail, "|", "\\|"))
+		}
+	}
+	return sb.String()
+}
+
+/*──────────────────────────────
+  zug upgrade-deps
+  ─────────────────────────────*/
+
+func upgradeDepsCommand(fs *flag.FlagSet) func() {
+	var cf commonFlags
+	cf.register(fs)
+	major := fs.Bool("major", false, "npm and pip: go to the latest release, not just the newest version the declared range allows")
+	only := fs.String("only", "", "only upgrade dependencies whose name contains this text")
+	report := fs.String("report", "zug-upgrade-report.md", "file to write the per-dependency report to")
+	dryRun := fs.Bool("dry-run", false, "list the available upgrades without applying them")
+	return func() {
+		agent := cf.newAgent(arg(fs.Args(), 0))
+		groups, err := agent.outdatedGroups(*major, *only)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if *dryRun || len(groups) == 0 {
+			for _, g := range groups {
+				fmt.Printf("%s: %s\n", g.name, g)
+			}
+			if len(groups) == 0 {
+				fmt.Println("All dependencies are up to date.")
+			}
+			return
+		}
+		if err := agent.upgradeBaseline(groups); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+
+		var results []upgradeResult
+		for i, g := range groups {
+			log.Printf("[agent] 📦 Group %d/%d: %s\n", i+1, len(groups), g.name)
+			r := agent.upgradeGroup(g)
+			log.Printf("[agent] 📦 %s: %s\n", g.name, r.result)
+			results = append(results, r)
+		}
+		content := upgradeReport(results)
+		if err := os.WriteFile(*report, []byte(content), 0o644); err != nil {
+			log.Fatalf("❌ Could not write the report: %v", err)
+		}
+		fmt.Print(content)
+		fmt.Printf("\nReport written to %s.\n", *report)
+		for _, r := range results {
+			if r.result == upgradeRolledBack || r.result == upgradeFailed {
+				os.Exit(exitFailed)
+			}
+		}
+	}
+}
+
+// upgradeBaseline makes sure build and tests pass before anything is upgraded, so that
+// breakage can be attributed to the upgrade.
+func (a *AutonomousCodingAgent) upgradeBaseline(groups []depGroup) error {
+	for _, g := range groups {
+		for _, m := range g.eco.manifests {
+			a.trackChange(m, filepath.Join(a.projectDir, m)) // runBuild only builds after changes
+		}
+	}
+	defer func() { a.changes = map[string]*fileChange{} }()
+	if out, ok := a.runBuild(); !ok {
+		return fmt.Errorf("the project does not build before the upgrade; fix it first:\n%s", out)
+	}
+	if out, ran, ok := a.runTests(); ran && !ok {
+		return fmt.Errorf("the tests fail before the upgrade; fix them first:\n%s", out)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestParseOutdated(t *testing.T) {
+	goOut := `{"Path": "example.com/app", "Main": true}
+{"Path": "golang.org/x/net", "Version": "v0.20.0", "Update": {"Version": "v0.25.0"}}
+{"Path": "golang.org/x/sys", "Version": "v0.16.0", "Indirect": true, "Update": {"Version": "v0.20.0"}}
+{"Path": "gopkg.in/yaml.v3", "Version": "v3.0.1"}`
+	got, err := parseGoOutdated(goOut, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []depUpdate{{"golang.org/x/net", "v0.20.0", "v0.25.0"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("go: %v, want %v", got, want)
+	}
+
+	npmOut := `{"react": {"current": "18.2.0", "wanted": "18.3.1", "latest": "19.0.0"}}`
+	for major, want := range map[bool]string{false: "18.3.1", true: "19.0.0"} {
+		got, err := parseNpmOutdated(npmOut, major)
+		if err != nil || len(got) != 1 || got[0].to != want {
+			t.Errorf("npm (major %t): %v, %v, want %s", major, got, err, want)
+		}
+	}
+
+	if got := pinnedRequirements("# via x\nRequests[socks]==2.31.0\nflask>=2\nmy_pkg==1.0\n"); !reflect.DeepEqual(got, map[string]bool{"requests": true, "my-pkg": true}) {
+		t.Errorf("pinned = %v", got)
+	}
+}
+
+func TestDependencyGroups(t *testing.T) {
+	goEco, npmEco := depEcosystems[0], depEcosystems[1]
+	for name, want := range map[string]string{
+		"golang.org/x/net":                        "golang.org/x",
+		"github.com/aws/aws-sdk-go-v2/service/s3": "github.com/aws/aws-sdk-go-v2",
+		"github.com/sashabaranov/go-openai":       "github.com/sashabaranov/go-openai",
+	} {
+		if got := goEco.group(name); got != want {
+			t.Errorf("go group(%s) = %s, want %s", name, got, want)
+		}
+	}
+	if got := npmEco.group("@babel/core"); got != "@babel" {
+		t.Errorf("npm group = %s, want @babel", got)
+	}
+}
+
+// fakeEcosystem upgrades deps.txt from v1 to v2 with shell commands.
+func fakeEcosystem(bump string) *depEcosystem {
+	return &depEcosystem{
+		name: "fake", detect: "deps.txt", manifests: []string{"deps.txt"}, tool: "sh",
+		outdated: "echo '{}'",
+		parse: func(string, bool) ([]depUpdate, error) {
+			return []depUpdate{{"lib", "v1", "v2"}}, nil
+		},
+		bump:  func([]depUpdate) string { return bump },
+		group: func(name string) string { return name },
+	}
+}
+
+func TestUpgradeGroupFixesBreakage(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{
+		configFileName: "test:\n  command: grep -q v1 deps.txt || test -f adapted.txt\n",
+		"deps.txt":     "v1\n",
+	},
+		mock.Call("create_file", map[string]any{"path": "adapted.txt", "content": "v2 API\n"}),
+		mock.Text("adapted to v2"),
+	)
+	defer func(old []*depEcosystem) { depEcosystems = old }(depEcosystems)
+	depEcosystems = []*depEcosystem{fakeEcosystem("echo v2 > deps.txt")}
+
+	groups, err := a.outdatedGroups(false, "")
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("groups = %v, %v", groups, err)
+	}
+	r := a.upgradeGroup(groups[0])
+	if r.result != upgradeFixed || r.detail != "adapted.txt" {
+		t.Errorf("result = %q (%s), want %q (adapted.txt)", r.result, r.detail, upgradeFixed)
+	}
+}
+
+func TestUpgradeGroupRollsBack(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]string{"deps.txt": "v1\n"})
+	defer func(old []*depEcosystem) { depEcosystems = old }(depEcosystems)
+	depEcosystems = []*depEcosystem{fakeEcosystem("echo v2 > deps.txt && exit 3")}
+
+	groups, _ := a.outdatedGroups(false, "")
+	r := a.upgradeGroup(groups[0])
+	if r.result != upgradeFailed {
+		t.Errorf("result = %q, want %q", r.result, upgradeFailed)
+	}
+	raw, _ := os.ReadFile(filepath.Join(a.projectDir, "deps.txt"))
+	if string(raw) != "v1\n" {
+		t.Errorf("deps.txt = %q after the rollback, want v1", raw)
+	}
+	assertContains(t, upgradeReport([]upgradeResult{r}), "| `lib` | v1 | v2 | could not be installed |")
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Verification without tests
+  ─────────────────────────────*/
+
+// When a run changed files in a project without tests, verifyFollowUp checks the change
+// before the run ends: it runs the entry point with the smoke command from zug.yaml, and
+// a verifier prompt checks the diff against the task. Their failures go back to the
+// agent like test failures, for at most verifyMaxRounds rounds.
+
+const (
+	verifyMaxRounds      = 2               // fix iterations for a failing smoke test or verifier
+	verifyDefaultTimeout = 2 * time.Minute // the smoke command
+	verifyApproved       = "VERIFIED"
+)
+
+// verifyConfig is the `verify:` section of zug.yaml.
+type verifyConfig struct {
+	Disabled      bool   `yaml:"disabled"`        // end runs without tests on the build check alone
+	Smoke         string `yaml:"smoke"`           // runs the entry point and must exit 0, e.g. "go run . --help"
+	Timeout       string `yaml:"timeout"`         // for the smoke command, e.g. 5m (default 2m)
+	SkipDiffCheck bool   `yaml:"skip_diff_check"` // do not ask the model to check the diff against the task
+}
+
+func (c verifyConfig) validate() error {
+	if c.Timeout != "" {
+		if d, err := time.ParseDuration(c.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("verify.timeout %q must be a positive duration such as 5m", c.Timeout)
+		}
+	}
+	return nil
+}
+
+func (c verifyConfig) timeout() time.Duration {
+	if d, err := time.ParseDuration(c.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return verifyDefaultTimeout
+}
+
+// verifyRun is the state of the verification pass in a run.
+type verifyRun struct {
+	rounds      int
+	checked     bool
+	failure     string // why the last verification failed, "" when it passed
+	smoked      bool   // the smoke command ran and passed
+	diffChecked bool   // the verifier approved the diff
+}
+
+// verifierPrompt instructs the verifier; like the reviewer it gets no tools, only the
+// task, the diff and the smoke test output.
+func verifierPrompt() openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: `You verify that a change does what its task asked for. The project has no tests, so you are the check. You receive the task statement, the unified diff produced for it and, when there is one, the output of running the program. Check that every part of the task is implemented, that nothing is left as a stub, placeholder or TODO, and that the output of the program fits the task. Do not judge style. Reply with exactly "` + verifyApproved + `" if the change completes the task. Otherwise reply with a short numbered list of what is missing or wrong, each naming the file.`,
+	}
+}
+
+// verifyFollowUp runs the verification pass once the build passes and there are no
+// tests. It returns the next instruction and true while there is something to fix.
+func (a *AutonomousCodingAgent) verifyFollowUp(task string) (string, bool) {
+	if a.cfg.Verify.Disabled || a.child || len(a.changes) == 0 {
+		return "", false
+	}
+	if a.verify == nil {
+		a.verify = &verifyRun{}
+	}
+	v := a.verify
+	v.checked, v.failure, v.smoked, v.diffChecked = true, "", false, false
+	if !a.status.buildPassed {
+		log.Println("[agent] 🔎 No build command applies to this project; verifying with the smoke test and the diff only.")
+	}
+	smokeOutput := ""
+	if smoke := a.cfg.Verify.Smoke; smoke != "" {
+		log.Printf("[agent] 🔎 Smoke test: %s\n", smoke)
+		out, timedOut, err := a.execShellWithin(smoke, a.cfg.Verify.timeout())
+		smokeOutput = dockerTail(out)
+		switch {
+		case timedOut:
+			v.failure = fmt.Sprintf("The smoke test `%s` did not finish within %s. Output:\n%s", smoke, a.cfg.Verify.timeout(), smokeOutput)
+		case err != nil:
+			v.failure = fmt.Sprintf("The smoke test `%s` failed: %v. Output:\n%s", smoke, err, smokeOutput)
+		}
+		v.smoked = v.failure == ""
+	}
+	if v.failure == "" && !a.cfg.Verify.SkipDiffCheck {
+		findings, err := a.checkDiff(task, smokeOutput)
+		switch {
+		case err != nil:
+			// Without an answer the run is not verified, but there is nothing to fix either.
+			log.Printf("[agent] ⚠️ Verifier failed: %v. Finishing unverified.\n", err)
+			v.failure = "the verifier could not be asked"
+			return "", false
+		case findings != "":
+			v.failure = "The verifier found that the change does not complete the task yet:\n" + findings
+		default:
+			v.diffChecked = true
+		}
+	}
+	if v.failure == "" {
+		log.Println("[agent] 🔎 The verification passed.")
+		return "", false
+	}
+	if v.rounds >= verifyMaxRounds {
+		log.Printf("[agent] ⚠️ The verification still fails after %d fix iterations.\n", v.rounds)
+		return "", false
+	}
+	v.rounds++
+	log.Printf("[agent] 🔎 Verification failed; asking for a fix (round %d of %d).\n", v.rounds, verifyMaxRounds)
+	return v.failure + "\n\nFix this within the scope of the original task.", true
+}
+
+// checkDiff asks the verifier whether the run's diff completes task. It returns the
+// verifier's findings, or "" when it approves.
+func (a *AutonomousCodingAgent) checkDiff(task, smokeOutput string) (string, error) {
+	diff := a.changesDiff()
+	if len(diff) > reviewDiffLimit {
+		diff = diff[:reviewDiffLimit] + "\n... (diff truncated)"
+	}
+	log.Printf("[agent] 🔎 Asking the verifier to check %d bytes of diff against the task.\n", len(diff))
+	content := fmt.Sprintf("Task:\n%s\n\nDiff:\n%s", task, diff)
+	if smokeOutput != "" {
+		content += fmt.Sprintf("\n\nOutput of `%s`:\n%s", a.cfg.Verify.Smoke, smokeOutput)
+	}
+	req, err := a.chatRequest([]openai.ChatCompletionMessage{
+		verifierPrompt(),
+		{Role: openai.ChatMessageRoleUser, Content: content},
+	}, phaseReview, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := a.createChatCompletion(req)
+	if err != nil {
+		return "", fmt.Errorf("verifier request failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("received an empty Choices array from OpenAI during verification")
+	}
+	findings := strings.TrimSpace(resp.Choices[0].Message.Content)
+	fmt.Printf("🔎 Verifier:\n%s\n\n", findings)
+	if findings == "" || strings.HasPrefix(strings.ToUpper(findings), verifyApproved) {
+		return "", nil
+	}
+	return findings, nil
+}
+
+// verifyOK tells whether a run without tests counts as verified: the build passed, or
+// the verification pass ran something that passed, and the pass found nothing wrong.
+func (a *AutonomousCodingAgent) verifyOK() bool {
+	v := a.verify
+	if v == nil || !v.checked {
+		return a.status.buildPassed
+	}
+	return v.failure == "" && (a.status.buildPassed || v.smoked)
+}
+
+// verifyStatus is the Verify line of the run summary, or "" when the pass did not run.
+func (a *AutonomousCodingAgent) verifyStatus() string {
+	v := a.verify
+	if v == nil || !v.checked {
+		return ""
+	}
+	if v.failure != "" {
+		first, _, _ := strings.Cut(v.failure, "\n")
+		return fmt.Sprintf("   Verify:   ❌ %s\n", strings.TrimSuffix(first, ":"))
+	}
+	var passed []string
+	if a.status.buildPassed {
+		passed = append(passed, "build passes")
+	}
+	if v.smoked {
+		passed = append(passed, "smoke test passes")
+	}
+	if v.diffChecked {
+		passed = append(passed, "the verifier approved the diff")
+	}
+	if len(passed) == 0 {
+		return "   Verify:   ⚠️ nothing to run: no build command, smoke test or diff check\n"
+	}
+	return "   Verify:   ✅ " + strings.Join(passed, ", ") + "\n"
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"zug/provider/mock"
+)
+
+func TestVerificationWithoutTests(t *testing.T) {
+	a, srv := newTestAgent(t, map[string]string{configFileName: "verify:\n  smoke: cat out.txt\n"},
+		mock.Call("create_file", map[string]any{"path": "greet.txt", "content": "hello\n"}),
+		mock.Text("done"),
+		mock.Call("create_file", map[string]any{"path": "out.txt", "content": "hello\n"}),
+		mock.Text("added the output"),
+		mock.Text("1. greet.txt: the task asks for a greeting by name"),
+		mock.Call("create_file", map[string]any{"path": "greet.txt", "content": "hello, Ada\n", "overwrite": true}),
+		mock.Text("greets by name"),
+		mock.Text(verifyApproved),
+	)
+	if err := a.feedbackLoop("greet Ada by name"); err != nil {
+		t.Fatal(err)
+	}
+	reqs := srv.Requests()
+	if len(reqs) != 8 {
+		t.Fatalf("got %d requests, want 8", len(reqs))
+	}
+	smokeFailure := reqs[2].Messages[len(reqs[2].Messages)-1].Content
+	assertContains(t, smokeFailure, "The smoke test `cat out.txt` failed")
+	verifier := reqs[4].Messages
+	if len(verifier) != 2 || verifier[0].Content != verifierPrompt().Content {
+		t.Fatalf("verifier request = %+v", verifier)
+	}
+	assertContains(t, verifier[1].Content, "Task:\ngreet Ada by name")
+	assertContains(t, verifier[1].Content, "+hello")
+	assertContains(t, verifier[1].Content, "Output of `cat out.txt`:\nhello")
+	assertContains(t, reqs[5].Messages[len(reqs[5].Messages)-1].Content, "the task asks for a greeting by name")
+	if !a.status.verified {
+		t.Error("the run is not verified")
+	}
+	assertContains(t, a.runSummary(), "Verify:   ✅ smoke test passes, the verifier approved the diff")
+}
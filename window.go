@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*──────────────────────────────
+  Context window
+  ─────────────────────────────*/
+
+// Sliding-window strategies for the conversation sent to the model.
+const (
+	windowRecent    = "recent"     // keep the last max_messages messages
+	windowKeepFirst = "keep-first" // like recent, but the task statement always stays
+	windowSummarize = "summarize"  // like keep-first, and dropped messages are summarized
+	windowTokens    = "tokens"     // keep-first, windowed by estimated tokens instead of messages
+)
+
+const (
+	defaultWindowMessages = 40
+	defaultWindowTokens   = 60000
+	summaryPrefix         = "Summary of the earlier conversation, which is no longer shown:\n"
+)
+
+// contextConfig is the `context:` section of zug.yaml.
+type contextConfig struct {
+	Strategy    string `yaml:"strategy"`     // recent, keep-first (default), summarize or tokens
+	MaxMessages int    `yaml:"max_messages"` // window size for recent, keep-first and summarize (default 40)
+	MaxTokens   int    `yaml:"max_tokens"`   // window size for tokens (default 60000)
+}
+
+func (c contextConfig) validate() error {
+	switch c.Strategy {
+	case "", windowRecent, windowKeepFirst, windowSummarize, windowTokens:
+		return nil
+	}
+	return fmt.Errorf("unknown context strategy %q (use %s, %s, %s or %s)", c.Strategy, windowRecent, windowKeepFirst, windowSummarize, windowTokens)
+}
+
+// messageTokens estimates the tokens of one message, including tool calls and images.
+func messageTokens(m openai.ChatCompletionMessage) int {
+	n := estimateTokens(m.Content)
+	for _, tc := range m.ToolCalls {
+		n += estimateTokens(tc.Function.Name + tc.Function.Arguments)
+	}
+	for _, p := range m.MultiContent {
+		if p.Type == openai.ChatMessagePartTypeImageURL {
+			n += imageTokenEstimate
+		} else {
+			n += estimateTokens(p.Text)
+		}
+	}
+	return n
+}
+
+// trimContext applies the configured window to a.ctx and reports whether anything was
+// dropped.
+func (a *AutonomousCodingAgent) trimContext() bool {
+	if len(a.ctx) == 0 {
+		return false
+	}
+	strategy := a.cfg.Context.Strategy
+	if strategy == "" {
+		strategy = windowKeepFirst
+	}
+
+	// Leading messages that are never dropped: the task statement and an earlier summary.
+	pinned := 0
+	if strategy != windowRecent && len(a.ctx) > 0 && a.ctx[0].Role == openai.ChatMessageRoleUser {
+		pinned = 1
+		if len(a.ctx) > 1 && strings.HasPrefix(a.ctx[1].Content, summaryPrefix) {
+			pinned = 2
+		}
+	}
+
+	cut := pinned // a.ctx[pinned:cut] is dropped
+	if strategy == windowTokens {
+		budget := a.cfg.Context.MaxTokens
+		if budget <= 0 {
+			budget = defaultWindowTokens
+		}
+		for _, m := range a.ctx[:pinned] {
+			budget -= messageTokens(m)
+		}
+		cut = len(a.ctx) - 1 // the newest message always stays
+		budget -= messageTokens(a.ctx[cut])
+		for cut > pinned && messageTokens(a.ctx[cut-1]) <= budget {
+			cut--
+			budget -= messageTokens(a.ctx[cut])
+		}
+	} else if limit := max(a.maxCtxMessages, pinned+1); len(a.ctx) > limit {
+		keep := limit - pinned
+		if strategy == windowSummarize {
+			// Summarize in larger chunks rather than one model call per new message.
+			keep = max(keep/2, 1)
+		}
+		cut = len(a.ctx) - keep
+	}
+	// Tool results must follow the assistant message that requested them, so keep it too.
+	for cut > pinned && a.ctx[cut].Role == openai.ChatMessageRoleTool {
+		cut--
+	}
+	if cut <= pinned {
+		return false
+	}
+
+	dropped := a.ctx[pinned:cut]
+	kept := append([]openai.ChatCompletionMessage{}, a.ctx[:min(pinned, 1)]...)
+	if strategy == windowSummarize {
+		previous := ""
+		if pinned == 2 {
+			previous = strings.TrimPrefix(a.ctx[1].Content, summaryPrefix)
+		}
+		if summary, err := a.summarizeMessages(previous, dropped); err != nil {
+			log.Printf("[agent] ⚠️ Could not summarize %d dropped messages, dropping them unsummarized: %v\n", len(dropped), err)
+		} else {
+			kept = append(kept, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: summaryPrefix + summary})
+		}
+	} else if pinned == 2 {
+		kept = append(kept, a.ctx[1])
+	}
+	a.ctx = append(kept, a.ctx[cut:]...)
+	log.Printf("[agent] Context trimmed (%s): dropped %d message(s), %d left.\n", strategy, len(dropped), len(a.ctx))
+	return true
+}
+
+// summarizeMessages asks the model to fold msgs into the running summary of the conversation.
+func (a *AutonomousCodingAgent) summarizeMessages(previous string, msgs []openai.ChatCompletionMessage) (string, error) {
+	var sb strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&sb, "Summary so far:\n%s\n\n", previous)
+	}
+	sb.WriteString("Messages to add to the summary:\n")
+	for _, m := range msgs {
+		text := m.Content
+		for _, tc := range m.ToolCalls {
+			text += fmt.Sprintf("\n→ %s(%s)", tc.Function.Name, tc.Function.Arguments)
+		}
+		if len(text) > 2000 {
+			text = text[:validCut(text, 2000)] + "…"
+		}
+		fmt.Fprintf(&sb, "[%s] %s\n", m.Role, text)
+	}
+	req, err := a.chatRequest([]openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "You condense the history of a coding agent's conversation. Write a concise summary that keeps what later work depends on: decisions made, files created or changed, commands run and their results, open problems and unfinished steps. Omit file contents and long outputs."},
+		{Role: openai.ChatMessageRoleUser, Content: sb.String()},
+	}, phaseSummary, nil)
+	if err != nil {
+		return "", err
+	}
+	req.ResponseFormat = nil
+	resp, err := a.createChatCompletion(req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*──────────────────────────────
+  Multi-root workspaces (monorepos)
+  ─────────────────────────────*/
+
+// rootConfig is one entry of `roots:` in zug.yaml. Commands default to the toolchain
+// detected inside the root.
+type rootConfig struct {
+	Path  string `yaml:"path"` // relative to the project dir
+	Build string `yaml:"build"`
+	Test  string `yaml:"test"`
+	Lint  string `yaml:"lint"`
+}
+
+// checkTarget is a directory the build, lint and test checks run in: the project itself,
+// or one workspace root.
+type checkTarget struct {
+	root *rootConfig // nil for a single-root project
+	dir  string
+}
+
+// label prefixes command output so the model knows which root it came from.
+func (t checkTarget) label() string {
+	if t.root == nil {
+		return ""
+	}
+	return "(in " + filepath.Clean(t.root.Path) + ") "
+}
+
+// rootFor returns the configured root containing rel (the most specific one), or nil.
+func (a *AutonomousCodingAgent) rootFor(rel string) *rootConfig {
+	rel = filepath.Clean(rel)
+	var best *rootConfig
+	for i := range a.cfg.Roots {
+		r := &a.cfg.Roots[i]
+		p := filepath.Clean(r.Path)
+		if (rel == p || strings.HasPrefix(rel, p+string(os.PathSeparator))) && (best == nil || len(p) > len(filepath.Clean(best.Path))) {
+			best = r
+		}
+	}
+	return best
+}
+
+// checkWorkspacePath rejects paths outside every configured root. zug's own .zug
+// directory is always reachable.
+func (a *AutonomousCodingAgent) checkWorkspacePath(rel string) error {
+	if len(a.cfg.Roots) == 0 || a.rootFor(rel) != nil {
+		return nil
+	}
+	if clean := filepath.Clean(rel); clean == zugDirName || strings.HasPrefix(clean, zugDirName+string(os.PathSeparator)) {
+		return nil
+	}
+	return fmt.Errorf("path %q is outside the workspace roots (%s); paths must start with one of them", rel, strings.Join(a.rootPaths(), ", "))
+}
+
+func (a *AutonomousCodingAgent) rootPaths() []string {
+	var paths []string
+	for _, r := range a.cfg.Roots {
+		paths = append(paths, filepath.Clean(r.Path))
+	}
+	return paths
+}
+
+// checkTargets lists where to run checks: the project dir, or every root touched by the
+// agent's changes (all roots when nothing has been changed yet). There are none for a
+// repository edited through its API.
+func (a *AutonomousCodingAgent) checkTargets() []checkTarget {
+	if a.repo != nil {
+		return nil // nothing can be run without a local clone
+	}
+	if len(a.cfg.Roots) == 0 {
+		return []checkTarget{{dir: a.projectDir}}
+	}
+	touched := map[*rootConfig]bool{}
+	for rel := range a.changes {
+		if r := a.rootFor(rel); r != nil {
+			touched[r] = true
+		}
+	}
+	var targets []checkTarget
+	for i := range a.cfg.Roots {
+		r := &a.cfg.Roots[i]
+		if len(touched) == 0 || touched[r] {
+			targets = append(targets, checkTarget{root: r, dir: filepath.Join(a.projectDir, r.Path)})
+		}
+	}
+	return targets
+}
+
+// execShellIn runs cmd in the target's directory.
+func (a *AutonomousCodingAgent) execShellIn(t checkTarget, cmd string) (string, error) {
+	if t.root == nil {
+		return a.execShell(cmd)
+	}
+	return a.execShell("cd " + shellQuote(filepath.Clean(t.root.Path)) + " && " + cmd)
+}
+
+// groupByRoot renders project-relative paths under one heading per workspace root.
+func (a *AutonomousCodingAgent) groupByRoot(paths []string) string {
+	groups := map[string][]string{}
+	for _, p := range paths {
+		if r := a.rootFor(p); r != nil {
+			key := filepath.Clean(r.Path)
+			groups[key] = append(groups[key], p)
+		}
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "[root %s]\n%s\n", k, strings.Join(groups[k], "\n"))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// describeWorkspace renders the roots and their toolchains for the system prompt.
+func (a *AutonomousCodingAgent) describeWorkspace() string {
+	if len(a.cfg.Roots) == 0 {
+		return describeToolchains(a.detectToolchains(a.projectDir))
+	}
+	var sb strings.Builder
+	sb.WriteString("This is a multi-root workspace. File paths must lie inside one of these roots (relative to the project dir); build, lint and test checks run per root:")
+	for _, r := range a.cfg.Roots {
+		fmt.Fprintf(&sb, "\n\n## Root %s\n", filepath.Clean(r.Path))
+		if desc := describeToolchains(a.detectToolchains(filepath.Join(a.projectDir, r.Path))); desc != "" {
+			sb.WriteString(desc)
+		} else {
+			sb.WriteString("No toolchain detected.")
+		}
+	}
+	return sb.String()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -8,9 +9,9 @@ import (
 	"io/fs"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -24,14 +25,79 @@ import (
 // ────────────────────────────────────────────────
 
 type AutonomousCodingAgent struct {
-	client         *openai.Client
+	keys           *apiKeyRing
+	fs             projectFS  // all file tools go through it
+	remote         *sshTarget // -remote: the project and run_shell live on another host
+	repo           *apiRepo   // --repo: the project is in memory, read from and submitted to a GitHub or GitLab API
+	sandbox        string     // -sandbox: isolation of shell commands, see sandbox.go
+	netProxy       *netProxy  // allowlist proxy for sandboxed commands, started on first use
 	projectDir     string
 	ctx            []openai.ChatCompletionMessage
-	maxCtxMessages int    // sliding-window for conversation history
-	model          string // Stores the chosen OpenAI model
+	maxCtxMessages int               // sliding-window for conversation history
+	model          string            // Stores the chosen OpenAI model
+	review         bool              // run a reviewer pass over the final diff before finishing
+	prBody         string            // --pr-body: write a pull request description to this file on success
+	changelog      bool              // --changelog: add an entry to the project's CHANGELOG.md on success
+	refactor       *refactorBaseline // zug refactor: the passing test run the changes are held to
+	security       *securityRun      // security scanning: the findings the run is not held to
+	mutation       *mutationRun      // mutation testing after the tests pass, when enabled
+	document       *documentRun      // zug document: the files to document and their code, which must not change
+	docker         *dockerRun        // container build verification, when enabled
+	verify         *verifyRun        // verification of runs in projects without tests
+	readOnly       bool              // only offer tools that cannot modify the project
+	prompt         openai.ChatCompletionMessage
+	todo           []planStep               // checklist maintained by the model via update_plan
+	child          bool                     // spawned by run_subtasks; may not spawn further subtasks
+	lastReply      string                   // most recent assistant summary from the feedback loop
+	images         []openai.ChatMessagePart // attached to the next user message, then cleared
+	cfg            *config                  // per-project zug.yaml
+
+	changes       map[string]*fileChange    // original state of every file the agent touched
+	approvedTools map[string]bool           // "ask" tools the user allowed for the rest of the run
+	earlyAnswers  map[string]earlyAnswer    // approvals given while a call was streaming, by call ID
+	autoApprove   bool                      // --yes: approve every call, recording it in the audit log
+	call          struct{ id, tool string } // tool call in progress, for the audit log
+	loop          loopDetector              // identical consecutive tool calls
+	editFailures  map[string]int            // consecutive failed edits per file (recovery.go)
+	knowledge     string                    // related knowledge base entries for the system prompt
+	input         *terminalInput            // steering from the terminal (steer.go); nil when off
+	runCtx        context.Context           // ends at the --timeout deadline; nil for no limit
+	timeout       time.Duration             // --timeout, for messages
+	lsps          map[string]*lspClient     // language servers by command, started lazily when lsp is enabled
+	symbols       symbolIndex               // definitions for find_symbol (symindex.go)
+	state         *stateStore               // .zug/state.db, opened on first use
+	runID         int64                     // row in the runs table for the current feedback loop
+	usage         openai.Usage              // tokens spent by this agent so far
+
+	progress       runProgress  // where the feedback loop stands, saved after every step (persist.go)
+	resumeFrom     *runProgress // zug resume: the saved progress the next turn continues from
+	savedChanges   int          // files whose original content is saved with the progress
+	progressWarned bool         // a failure to save the progress has been reported
+
+	resultSeq int       // oversized tool results stored so far
+	status    runStatus // progress shown in the status line
+
+	samplingFlags samplingFlags // --temperature, --top-p, --max-output-tokens
+	textTools     bool          // --tool-protocol text: tools are called in THOUGHT/ACTION/ARGS text (react.go)
+	serialTools   bool          // --parallel-tool-calls=false: at most one tool call per reply
+	nextTurn      turnTools     // tool choice for the first request of the next chat turn (toolchoice.go)
+	stream        bool          // --stream: completions are streamed and tool calls checked as they arrive (stream.go)
+	streamConfirm bool          // --stream-confirm: "ask" calls are put to the user while they stream
+
+	responseFormat *openai.ChatCompletionResponseFormat // structured output schema for chat replies, if any
+	outcome        *runOutcome                          // structured summary of the finished run
+
+	cacheMode string      // LLM response cache: off, on or replay (cache.go)
+	cache     *stateStore // ~/.zug/state.db, opened on first cached request
+}
+
+// fileChange remembers what a file looked like before the agent first modified it.
+type fileChange struct {
+	existed bool
+	before  string
 }
 
-func NewAgent(apiKey, projectDir, modelName string) *AutonomousCodingAgent {
+func NewAgent(keys *apiKeyRing, projectDir, modelName string) *AutonomousCodingAgent {
 	if err := os.MkdirAll(projectDir, 0o755); err != nil {
 		log.Fatalf("cannot create project dir %s: %v", projectDir, err)
 	}
@@ -39,12 +105,30 @@ func NewAgent(apiKey, projectDir, modelName string) *AutonomousCodingAgent {
 		modelName = openai.GPT4o // Default model if not specified
 		log.Printf("[agent] No model specified, defaulting to %s\n", modelName)
 	}
-	return &AutonomousCodingAgent{
-		client:         openai.NewClient(apiKey),
+	cfg, err := loadConfig(projectDir)
+	if err != nil {
+		log.Fatalf("cannot load project config: %v", err)
+	}
+	window := defaultWindowMessages
+	if cfg.Context.MaxMessages > 0 {
+		window = cfg.Context.MaxMessages
+	}
+	cacheMode := os.Getenv("ZUG_CACHE")
+	if err := validCacheMode(cacheMode); err != nil {
+		log.Fatalf("invalid ZUG_CACHE: %v", err)
+	}
+	a := &AutonomousCodingAgent{
+		keys:           keys,
 		projectDir:     projectDir,
-		maxCtxMessages: 40, // keep the last N messages to stay within budget
+		maxCtxMessages: window, // sliding window, see trimContext
 		model:          modelName,
+		prompt:         systemPrompt(),
+		cfg:            cfg,
+		changes:        map[string]*fileChange{},
+		cacheMode:      cacheMode,
 	}
+	a.fs = a.audited(osFS{})
+	return a
 }
 
 /*──────────────────────────────
@@ -65,25 +149,113 @@ func (a *AutonomousCodingAgent) absPath(rel string) (string, error) {
 	if !strings.HasPrefix(full, cleanProjectDir+string(os.PathSeparator)) && full != cleanProjectDir {
 		return "", fmt.Errorf("invalid path %q (escapes project dir)", rel)
 	}
+	if err := a.checkWorkspacePath(clean); err != nil {
+		return "", err
+	}
+	if pattern, denied := a.sensitivePath(clean); denied {
+		return "", fmt.Errorf("%w: %s matches the sensitive path pattern %q and may not be read or written. Do not try to access it another way; ask the user to provide what you need instead", errPolicyViolation, rel, pattern)
+	}
 	return full, nil
 }
 
+// trackChange snapshots a file the first time the agent is about to modify it,
+// so the overall diff of the run can be reconstructed later.
+func (a *AutonomousCodingAgent) trackChange(rel, full string) {
+	key := filepath.Clean(rel)
+	if _, seen := a.changes[key]; seen {
+		return
+	}
+	raw, err := a.fs.ReadFile(full)
+	a.changes[key] = &fileChange{existed: err == nil, before: string(raw)}
+}
+
+// revertChanges restores every file the agent touched to its original state and forgets
+// the changes.
+func (a *AutonomousCodingAgent) revertChanges() error {
+	var errs []error
+	for rel, c := range a.changes {
+		full := filepath.Join(a.projectDir, rel)
+		var err error
+		if c.existed {
+			err = a.fs.WriteFile(full, []byte(c.before), 0o644)
+		} else {
+			err = a.fs.RemoveAll(full)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot restore %s: %w", rel, err))
+		}
+	}
+	a.changes = map[string]*fileChange{}
+	return errors.Join(errs...)
+}
+
+// changesDiff returns a unified diff of everything the file tools changed during the run.
+func (a *AutonomousCodingAgent) changesDiff() string {
+	paths := make([]string, 0, len(a.changes))
+	for p := range a.changes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var sb strings.Builder
+	for _, p := range paths {
+		c := a.changes[p]
+		after, to := "", "/dev/null"
+		if raw, err := a.fs.ReadFile(filepath.Join(a.projectDir, p)); err == nil {
+			after, to = string(raw), "b/"+p
+		}
+		from := "a/" + p
+		if !c.existed {
+			from = "/dev/null"
+		}
+		sb.WriteString(unifiedDiff(from, to, c.before, after))
+	}
+	return sb.String()
+}
+
 /*──────────────────────────────
   File operations (tools)
   ─────────────────────────────*/
 
-func (a *AutonomousCodingAgent) createFile(path, content string) (string, error) {
+func (a *AutonomousCodingAgent) createFile(path, content string, overwrite bool) (string, error) {
 	full, err := a.absPath(path)
 	if err != nil {
 		return "", err
 	}
-	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+	var old []byte
+	exists := false
+	if info, err := a.fs.Stat(full); err == nil {
+		if info.IsDir() {
+			return "", fmt.Errorf("cannot create file %s: a directory with that name exists", path)
+		}
+		if !overwrite {
+			return "", fmt.Errorf("file %s already exists; use update_file to change it, or pass overwrite=true to replace it entirely", path)
+		}
+		if old, err = a.fs.ReadFile(full); err != nil {
+			return "", fmt.Errorf("failed to read existing %s before overwriting: %w", path, err)
+		}
+		exists = true
+	}
+	if err := a.validateWrite(path, full, content); err != nil {
+		return "", err
+	}
+	outcome := "created"
+	if exists {
+		saved, err := a.checkpointFile(path, old)
+		if err != nil {
+			return "", err
+		}
+		outcome = fmt.Sprintf("overwritten (previous version saved as %s)", saved)
+	}
+	if err := a.fs.MkdirAll(filepath.Dir(full), 0o755); err != nil {
 		return "", fmt.Errorf("failed to create directory for %s: %w", path, err)
 	}
-	if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+	a.trackChange(path, full)
+	content, formatter := a.formatSource(path, content)
+	if err := a.fs.WriteFile(full, []byte(content), 0o644); err != nil {
 		return "", fmt.Errorf("failed to write file %s: %w", path, err)
 	}
-	return fmt.Sprintf("file %s created", path), nil
+	return fmt.Sprintf("file %s %s%s%s", path, outcome, formattedNote(formatter), a.diagnosticsNote(path, full)), nil
 }
 
 func (a *AutonomousCodingAgent) appendFile(path, content string) (string, error) {
@@ -91,48 +263,83 @@ func (a *AutonomousCodingAgent) appendFile(path, content string) (string, error)
 	if err != nil {
 		return "", err
 	}
+	old, _ := a.fs.ReadFile(full)
+	if err := a.validateWrite(path, full, string(old)+content); err != nil {
+		return "", err
+	}
 	// Ensure directory exists before trying to open/create the file
-	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+	if err := a.fs.MkdirAll(filepath.Dir(full), 0o755); err != nil {
 		return "", fmt.Errorf("failed to create directory for %s: %w", path, err)
 	}
-	f, err := os.OpenFile(full, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
-	if err != nil {
-		return "", fmt.Errorf("failed to open/append to file %s: %w", path, err)
+	a.trackChange(path, full)
+	if err := a.fs.AppendFile(full, []byte(content), 0o644); err != nil {
+		return "", fmt.Errorf("failed to append to file %s: %w", path, err)
 	}
-	defer f.Close()
-	if _, err := f.WriteString(content); err != nil {
-		return "", fmt.Errorf("failed to write content to %s: %w", path, err)
+	// Formatting needs the whole file, so reformat it after the append.
+	formatter := ""
+	if raw, err := a.fs.ReadFile(full); err == nil {
+		var formatted string
+		if formatted, formatter = a.formatSource(path, string(raw)); formatted != string(raw) {
+			if err := a.fs.WriteFile(full, []byte(formatted), 0o644); err != nil {
+				return "", fmt.Errorf("failed to write formatted content to %s: %w", path, err)
+			}
+		}
 	}
-	return fmt.Sprintf("content appended to %s", path), nil
+	return fmt.Sprintf("content appended to %s%s%s", path, formattedNote(formatter), a.diagnosticsNote(path, full)), nil
 }
 
-func (a *AutonomousCodingAgent) updateFile(path, find, replace string) (string, error) {
+func (a *AutonomousCodingAgent) updateFile(path, find, replace string, expectedCount *int, showDiff bool) (string, error) {
 	full, err := a.absPath(path)
 	if err != nil {
 		return "", err
 	}
-	raw, err := os.ReadFile(full)
+	raw, err := a.fs.ReadFile(full)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file %s for update: %w", path, err)
 	}
 	src := string(raw)
 	var dst string
+	var count int
 	// Attempt to compile the 'find' string as a regular expression.
 	// If 'find' is not a valid regex, it will fall back to plain string replacement.
 	if re, errRe := regexp.Compile(find); errRe == nil {
+		count = len(re.FindAllStringIndex(src, -1))
 		dst = re.ReplaceAllString(src, replace)
 	} else {
 		log.Printf("[agent] Info: 'find' string \"%s\" is not a valid regex (%v). Performing plain text replacement for updateFile on %s.", find, errRe, path)
+		count = strings.Count(src, find)
 		dst = strings.ReplaceAll(src, find, replace)
 	}
 
+	// A multi-line literal that drifted only in whitespace or trivially is applied fuzzily
+	// rather than reported as "nothing replaced", which tends to send the model into retries.
+	fuzzyNote := ""
+	if count == 0 && strings.Contains(strings.TrimSpace(find), "\n") && (expectedCount == nil || *expectedCount == 1) {
+		if fuzzy, note, ok := fuzzyReplace(src, find, replace); ok {
+			dst, count, fuzzyNote = fuzzy, 1, "; "+note
+			log.Printf("[agent] update_file on %s: %s\n", path, note)
+		}
+	}
+
+	if expectedCount != nil && count != *expectedCount {
+		return "", fmt.Errorf("'find' matched %d place(s) in %s but expected_count is %d; nothing was changed. Make the pattern more specific (or anchor it with surrounding lines) and retry", count, path, *expectedCount)
+	}
 	if dst == src {
-		return fmt.Sprintf("nothing replaced in %s (content was identical or find pattern did not match)", path), nil
+		return fmt.Sprintf("%s%s (content was identical or find pattern did not match)", nothingReplaced, path), nil
+	}
+	if err := a.validateWrite(path, full, dst); err != nil {
+		return "", err
 	}
-	if err := os.WriteFile(full, []byte(dst), 0o644); err != nil {
+	a.trackChange(path, full)
+	dst, formatter := a.formatSource(path, dst)
+	if err := a.fs.WriteFile(full, []byte(dst), 0o644); err != nil {
 		return "", fmt.Errorf("failed to write updated content to %s: %w", path, err)
 	}
-	return fmt.Sprintf("updated %s", path), nil
+	result := fmt.Sprintf("updated %s: replaced %d occurrence(s)%s%s", path, count, fuzzyNote, formattedNote(formatter))
+	if showDiff {
+		result += "\n" + unifiedDiff("a/"+path, "b/"+path, src, dst)
+	}
+	return result + a.diagnosticsNote(path, full), nil
 }
 
 func (a *AutonomousCodingAgent) readFile(path string) (string, error) {
@@ -140,7 +347,10 @@ func (a *AutonomousCodingAgent) readFile(path string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	raw, err := os.ReadFile(full)
+	if a.ignoreMatcher().ignored(path, false) {
+		return "", fmt.Errorf("%s is excluded by the project's ignore patterns (%s or 'ignore' in %s) and is not shown to you", path, ignoreFileName, configFileName)
+	}
+	raw, err := a.fs.ReadFile(full)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file %s: %w", path, err)
 	}
@@ -148,9 +358,25 @@ func (a *AutonomousCodingAgent) readFile(path string) (string, error) {
 }
 
 func (a *AutonomousCodingAgent) listFiles() (string, error) {
+	list, err := a.projectFiles()
+	if err != nil {
+		return "", err
+	}
+	if len(a.cfg.Roots) > 0 {
+		list = strings.Split(a.groupByRoot(list), "\n")
+	}
+	if len(list) == 0 || list[0] == "" {
+		return "No files found in the project.", nil
+	}
+	return strings.Join(list, "\n"), nil
+}
+
+// projectFiles lists the files the tools can see, relative to the project root.
+func (a *AutonomousCodingAgent) projectFiles() ([]string, error) {
 	var list []string
 	projectRoot := filepath.Clean(a.projectDir)
-	err := filepath.WalkDir(projectRoot, func(p string, d fs.DirEntry, err error) error {
+	ignore := a.ignoreMatcher()
+	err := walkDir(a.fs, projectRoot, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			// Log permission errors but try to continue if possible
 			log.Printf("Warning: error accessing %s: %v. Skipping.", p, err)
@@ -162,26 +388,86 @@ func (a *AutonomousCodingAgent) listFiles() (string, error) {
 			}
 			return err // Propagate other critical errors
 		}
-		if d.IsDir() {
-			// Optionally skip common VCS or project-specific directories
-			// e.g. if d.Name() == ".git" || d.Name() == ".idea" { return fs.SkipDir }
-			return nil
-		}
 		rel, errRel := filepath.Rel(projectRoot, p)
 		if errRel != nil {
 			log.Printf("Warning: could not make path relative %s: %v", p, errRel)
 			return errRel // Should not happen if p starts with a.projectDir
 		}
+		if rel == "." {
+			return nil
+		}
+		if ignore.ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
 		list = append(list, rel)
 		return nil
 	})
 	if err != nil {
-		return "", fmt.Errorf("error listing files in %s: %w", projectRoot, err)
+		return nil, fmt.Errorf("error listing files in %s: %w", projectRoot, err)
 	}
-	if len(list) == 0 {
-		return "No files found in the project.", nil
+	return list, nil
+}
+
+const maxSearchMatches = 200 // cap on search_files results returned to the model
+
+// searchFiles greps every text file in the project for pattern (regex, or plain text if invalid).
+func (a *AutonomousCodingAgent) searchFiles(pattern string) (string, error) {
+	re, errRe := regexp.Compile(pattern)
+	if errRe != nil {
+		log.Printf("[agent] Info: search pattern \"%s\" is not a valid regex (%v). Searching for plain text.", pattern, errRe)
+		re = regexp.MustCompile(regexp.QuoteMeta(pattern))
 	}
-	return strings.Join(list, "\n"), nil
+	var matches []string
+	projectRoot := filepath.Clean(a.projectDir)
+	ignore := a.ignoreMatcher()
+	err := walkDir(a.fs, projectRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("Warning: error accessing %s: %v. Skipping.", p, err)
+			return nil
+		}
+		rel, _ := filepath.Rel(projectRoot, p)
+		if rel != "." && ignore.ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if _, denied := a.sensitivePath(rel); denied || a.checkWorkspacePath(rel) != nil {
+			return nil
+		}
+		raw, errRead := a.fs.ReadFile(p)
+		if errRead != nil || strings.IndexByte(string(raw[:min(len(raw), 8000)]), 0) >= 0 {
+			return nil // unreadable or binary
+		}
+		for i, line := range strings.Split(string(raw), "\n") {
+			if re.MatchString(line) {
+				matches = append(matches, fmt.Sprintf("%s:%d: %s", rel, i+1, strings.TrimSpace(line)))
+				if len(matches) >= maxSearchMatches {
+					return fs.SkipAll
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error searching files in %s: %w", projectRoot, err)
+	}
+	if len(matches) == 0 {
+		return fmt.Sprintf("No matches for %q.", pattern), nil
+	}
+	if len(matches) >= maxSearchMatches {
+		matches = append(matches, fmt.Sprintf("... (stopped after %d matches, refine the pattern)", maxSearchMatches))
+	}
+	return strings.Join(matches, "\n"), nil
 }
 
 /*──────────────────────────────
@@ -193,11 +479,8 @@ func (a *AutonomousCodingAgent) runShell(cmd string) (string, error) {
 	// could be exposed to untrusted input for the 'cmd' string.
 	// For now, it executes what it's told within its projectDir.
 	log.Printf("[agent] executing shell command: %s in %s\n", cmd, a.projectDir)
-	c := exec.Command("bash", "-c", cmd)
-	c.Dir = a.projectDir
-	out, err := c.CombinedOutput() // Captures both stdout and stderr
-
-	outputStr := strings.TrimSpace(string(out))
+	a.status.commands++
+	outputStr, err := a.execShell(cmd)
 
 	if err != nil {
 		// Return both output and error so the model can diagnose.
@@ -209,36 +492,126 @@ func (a *AutonomousCodingAgent) runShell(cmd string) (string, error) {
 	return outputStr, nil
 }
 
+// execShell runs cmd with bash in the project dir and returns its trimmed combined
+// output together with the exit error, for callers that need the status.
+func (a *AutonomousCodingAgent) execShell(cmd string) (string, error) {
+	if a.remote != nil {
+		return a.remote.shell(cmd)
+	}
+	if a.repo != nil {
+		return "", fmt.Errorf("shell commands are not available for a repository edited through its API (--repo)")
+	}
+	c, err := a.shellCommand(a.projectDir, cmd)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer // Captures both stdout and stderr
+	c.Stdout, c.Stderr = &out, &out
+	err = runChild(a.context(), c)
+	return strings.TrimSpace(out.String()), err
+}
+
 /*──────────────────────────────
   OpenAI interaction helpers
   ─────────────────────────────*/
 
 // toolParams builds a minimal JSON schema {string:string, ...}.
 func toolParams(keys ...string) map[string]interface{} {
+	params := make([]toolParam, len(keys))
+	for i, k := range keys {
+		params[i] = stringParam(k, "")
+	}
+	return toolSchema(params...)
+}
+
+// toolParam is one argument of a tool, as a JSON schema property.
+type toolParam struct {
+	name   string
+	schema map[string]interface{}
+	opt    bool
+}
+
+func newParam(name, typ, desc string) toolParam {
+	schema := map[string]interface{}{"type": typ}
+	if desc != "" {
+		schema["description"] = desc
+	}
+	return toolParam{name: name, schema: schema}
+}
+
+func stringParam(name, desc string) toolParam { return newParam(name, "string", desc) }
+func intParam(name, desc string) toolParam    { return newParam(name, "integer", desc) }
+func boolParam(name, desc string) toolParam   { return newParam(name, "boolean", desc) }
+
+// stringListParam is an array of strings, e.g. several paths.
+func stringListParam(name, desc string) toolParam {
+	p := newParam(name, "array", desc)
+	p.schema["items"] = map[string]interface{}{"type": "string"}
+	return p
+}
+
+// enumParam is a string argument restricted to values, e.g. a mode switch.
+func enumParam(name, desc string, values ...string) toolParam {
+	p := newParam(name, "string", desc)
+	p.schema["enum"] = values
+	return p
+}
+
+// optional marks the argument as not required; the tool must handle its zero value.
+func (p toolParam) optional() toolParam {
+	p.opt = true
+	return p
+}
+
+// toolSchema builds the parameters object of a tool definition. Arguments are required
+// unless marked optional.
+func toolSchema(params ...toolParam) map[string]interface{} {
 	props := map[string]interface{}{}
-	for _, k := range keys {
-		props[k] = map[string]string{"type": "string"}
+	var required []string
+	for _, p := range params {
+		props[p.name] = p.schema
+		if !p.opt {
+			required = append(required, p.name)
+		}
 	}
 	m := map[string]interface{}{
 		"type":       "object",
 		"properties": props,
 	}
-	// "required" should only be set if there are keys.
-	if len(keys) > 0 {
-		m["required"] = keys
+	// "required" should only be set if there are required parameters.
+	if len(required) > 0 {
+		m["required"] = required
 	}
 	return m
 }
 
-// toolDefs defines the tools available to the OpenAI model.
+// shellTools run commands in the project, so they need it on a disk.
+var shellTools = map[string]bool{"run_shell": true, "run_subtasks": true, "add_dependency": true, "remove_dependency": true, "profile": true, "write_scratch": true, "clean_scratch": true}
+
+// readOnlyTools are the only tools offered when the agent must not modify anything.
+var readOnlyTools = map[string]bool{"read_file": true, "list_files": true, "search_files": true, "read_result": true, "recall": true, "validate_syntax": true, "read_files": true, "find_symbol": true, "find_references": true, "import_graph": true, "env_vars": true}
+
+// toolDefs returns the tools offered to the model in the agent's mode.
 func (a *AutonomousCodingAgent) toolDefs() []openai.Tool {
+	var allowed []openai.Tool
+	for _, t := range allToolDefs() {
+		if a.toolAllowed(t.Function.Name) && a.permission(t.Function.Name) != permDeny {
+			allowed = append(allowed, t)
+		}
+	}
+	return allowed
+}
+
+// allToolDefs defines every tool the agent knows.
+func allToolDefs() []openai.Tool {
 	return []openai.Tool{
 		{
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
 				Name:        "create_file",
-				Description: "Create a new file with given content. Path should be relative to project root. Ensures parent directories exist.",
-				Parameters:  toolParams("path", "content"),
+				Description: "Create a new file with given content. Path should be relative to project root. Ensures parent directories exist. Fails if the file already exists unless overwrite is true; the previous version is then backed up.",
+				Parameters: toolSchema(stringParam("path", ""), stringParam("content", ""),
+					boolParam("overwrite", "replace an existing file (default false)").optional()),
 			},
 		},
 		{
@@ -253,18 +626,181 @@ func (a *AutonomousCodingAgent) toolDefs() []openai.Tool {
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
 				Name:        "update_file",
-				Description: "Search (regex or plain text) & replace text in an existing file. 'find' can be a regex. Path should be relative to project root.",
-				Parameters:  toolParams("path", "find", "replace"),
+				Description: "Search (regex or plain text) & replace text in an existing file. 'find' can be a regex. Path should be relative to project root. Every match is replaced and the number of replacements is reported; set expected_count to have the edit rejected when the pattern matches a different number of places.",
+				Parameters: toolSchema(stringParam("path", ""), stringParam("find", ""), stringParam("replace", ""),
+					intParam("expected_count", "number of matches the pattern must have, usually 1").optional(),
+					boolParam("diff", "include a unified diff of the change in the result").optional()),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "edit_file",
+				Description: "Preferred way to make targeted edits. 'edits' holds one or more blocks, each:\n<<<<<<< SEARCH\n<exact lines currently in the file, with enough context to be unique>\n=======\n<replacement lines>\n>>>>>>> REPLACE\nBlocks apply in order and all must match or nothing is written. An empty SEARCH creates a new file.",
+				Parameters:  toolParams("path", "edits"),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "replace_lines",
+				Description: "Replace lines start_line..end_line (1-based, inclusive) of a file with 'content', by line number from read_file with line_numbers=true. Robust fallback when SEARCH blocks or find patterns do not match. end_line = start_line-1 inserts before start_line; empty content deletes the lines. Line numbers shift after each edit, so re-read before the next one.",
+				Parameters: toolSchema(stringParam("path", ""), intParam("start_line", "first line to replace"), intParam("end_line", "last line to replace"),
+					stringParam("content", "the new lines")),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "replace_symbol",
+				Description: "Replace the complete definition of a function, method or type in a Go file. 'symbol' is a name like 'parseConfig' or 'Server.Start' (qualified by the receiver type). 'new_code' must contain the whole new definition including its signature; the doc comment above it is kept. Prefer this over update_file for rewriting whole Go functions. Only .go files are supported; in other languages use replace_lines or update_file.",
+				Parameters:  toolParams("path", "symbol", "new_code"),
 			},
 		},
 		{
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
 				Name:        "read_file",
-				Description: "Read the contents of an existing file. Path should be relative to project root.",
+				Description: "Read the contents of an existing file. Path should be relative to project root. Set line_numbers to prefix every line with its number, for replace_lines.",
+				Parameters:  toolSchema(stringParam("path", ""), boolParam("line_numbers", "prefix lines with their numbers (default false)").optional()),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "read_files",
+				Description: "Read several files in one call, each under a '===== path =====' header: list them in 'paths', select them with a glob 'pattern' (e.g. 'internal/auth/*.go', '**/*_test.py'), or both. Files are included in order until about 15000 characters; the rest are listed so you can read them with read_file. Prefer this over several read_file calls for small related files.",
+				Parameters: toolSchema(stringListParam("paths", "paths relative to the project root").optional(),
+					stringParam("pattern", "gitignore-style glob over the project files").optional()),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "find_symbol",
+				Description: "Find where a function, method, class or type is defined, by name: 'parseConfig', or 'Server.Start' for a method. Returns 'path:line: kind name: signature' for each definition, or similar names. Use it to navigate by symbol instead of guessing file names.",
+				Parameters:  toolSchema(stringParam("name", "symbol name, optionally qualified with its type or class")),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "find_references",
+				Description: "List every place a function, method, class or type is used, as 'path:line: code'. Uses the language server (gopls, pyright, typescript-language-server) when installed, otherwise a whole-word text search. Call it before changing a signature, so no caller is missed.",
+				Parameters: toolSchema(stringParam("name", "symbol name, optionally qualified with its type or class"),
+					stringParam("path", "file defining the symbol, when the name is defined more than once").optional()),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "import_graph",
+				Description: "Show what a file or directory imports from the project and from outside it, and which project files import it, directly and indirectly. Use it before an edit to see what else it may break, and which tests to run.",
+				Parameters:  toolSchema(stringParam("path", "file, or directory for a whole package")),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "env_vars",
+				Description: "List the environment variables the code reads, where, and which are missing from or no longer used in the example .env file (.env.example). Real .env files are never read. Use it after adding configuration, to document the new variables in the example file.",
+				Parameters:  toolSchema(stringParam("dir", "directory whose code and example file to check (default: the project root)").optional()),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "rename_symbol",
+				Description: "Rename a function, method, class, type or variable and every use of it across the project, through the language server when installed, otherwise by replacing whole words in files of the same language. Use it instead of editing each file or replacing text with a pattern, which also hits longer names and other symbols that contain it.",
+				Parameters: toolSchema(stringParam("old", "current name, optionally qualified with its type or class"),
+					stringParam("new", "new name, unqualified"),
+					stringParam("path", "file defining the symbol, when the name is defined more than once").optional()),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "validate_syntax",
+				Description: "Check that a file parses, without building or running anything: a cheap check after an edit, before spending a test run on it. Pass content to check text as if it were the file at path, without writing it.",
+				Parameters:  toolSchema(stringParam("path", ""), stringParam("content", "text to check instead of the file's current content").optional()),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "make_dir",
+				Description: "Create a directory (and missing parents) relative to the project root, e.g. to scaffold a package layout without placeholder files.",
+				Parameters:  toolParams("path"),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "remove_dir",
+				Description: "Remove a directory relative to the project root. Fails on non-empty directories unless recursive is true.",
+				Parameters: toolSchema(stringParam("path", ""),
+					boolParam("recursive", "also delete everything inside the directory (default false)").optional()),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "set_executable",
+				Description: "Make a file executable (chmod +x), e.g. a generated script or git hook. Path should be relative to project root.",
 				Parameters:  toolParams("path"),
 			},
 		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "copy_file",
+				Description: "Copy a file to a new path within the project, e.g. to use an existing handler as a template, then edit the copy. Cheaper and safer than reading and re-creating it. Fails if the destination exists unless overwrite is true.",
+				Parameters: toolSchema(stringParam("from", ""), stringParam("to", ""),
+					boolParam("overwrite", "replace an existing destination (default false)").optional()),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "write_scratch",
+				Description: "Write a throwaway file (exploratory script, sample input) to .zug/scratch/<name>. Scratch files are not part of the project's changes; use them for experiments and run them with run_shell.",
+				Parameters:  toolParams("name", "content"),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "clean_scratch",
+				Description: "Delete everything in the .zug/scratch/ experiment area.",
+				Parameters:  toolParams(),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "remember",
+				Description: "Save one lasting fact about this project for future runs, e.g. \"tests need POSTGRES_URL\" or \"do not touch legacy/\". Notes are kept in .zug/memory.md and shown in the system prompt of later runs. Only facts that took effort to find out and will still hold; not task progress.",
+				Parameters: toolSchema(stringParam("note", ""),
+					boolParam("global", "a convention beyond this project, e.g. \"this org always uses zap for logging\": save it to the cross-project knowledge base instead (when enabled)").optional()),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "recall",
+				Description: "List all notes saved with remember, including older ones left out of the system prompt.",
+				Parameters:  toolParams(),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "read_result",
+				Description: "Page through a tool result that was too long to show in full (long test logs, huge listings). The truncated result names its id and the offset to continue from.",
+				Parameters: toolSchema(stringParam("id", "the stored result's id"),
+					intParam("offset", "character offset to start reading at (default 0)").optional()),
+			},
+		},
 		{
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
@@ -276,54 +812,172 @@ func (a *AutonomousCodingAgent) toolDefs() []openai.Tool {
 		{
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
-				Name:        "run_shell",
-				Description: "Execute a shell command (bash) in the project dir and return its combined stdout/stderr. Errors are included in the output.",
-				Parameters:  toolParams("command"),
+				Name:        "search_files",
+				Description: "Search all project files for a regex (or plain text) pattern. Returns matching lines as 'path:line: text'.",
+				Parameters:  toolParams("pattern"),
 			},
 		},
-	}
-}
-
-// systemPrompt defines the initial system message for the AI.
-func systemPrompt() openai.ChatCompletionMessage {
-	return openai.ChatCompletionMessage{
-		Role: openai.ChatMessageRoleSystem,
-		Content: `You are AutonomousCoder, a senior software engineer. Work step-by-step: decide which file to read or modify, or which shell command to run, using the provided tools. File paths should always be relative to the project root. Iterate until the tests pass or the goal is reached. Respond concisely. If a tool fails, analyze the error and try to fix the issue in your next step. If a shell command produces an error, that error will be part of its output. If a file operation results in 'nothing changed', consider if the 'find' pattern was correct or if the file already has the desired content. Be precise with file paths. Always use 'list_files' if unsure about file existence or names before attempting to read or write.`,
-	}
-}
-
-// chat handles an entire cycle of user prompt → potential tool calls → assistant reply.
-func (a *AutonomousCodingAgent) chat(userPrompt string, temperature float32) (string, error) {
-	// Add current user prompt to the agent's context
-	a.ctx = append(a.ctx, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: userPrompt})
-
-	// Maintain sliding window for a.ctx before making any API call
-	if len(a.ctx) > a.maxCtxMessages {
-		cutoff := len(a.ctx) - a.maxCtxMessages
-		// Keep the system prompt (if we were to always prepend it here) + the most recent messages
-		// However, system prompt is added separately below, so just trim a.ctx
-		a.ctx = a.ctx[cutoff:]
-		log.Printf("[agent] Context trimmed to %d messages.\n", len(a.ctx))
-	}
-
-	// Prepare messages for the current API call, including the system prompt
-	messagesForAPI := append([]openai.ChatCompletionMessage{systemPrompt()}, a.ctx...)
-
-	// Loop for potential multiple tool calls within a single user turn
-	for step := 0; step < 10; step++ { // Safety: max 10 tool hops per user turn
-		log.Printf("[agent] Chat step %d. Sending %d messages to API (incl. system prompt) using model %s.\n", step+1, len(messagesForAPI), a.model)
-
-		req := openai.ChatCompletionRequest{
-			Model:       a.model, // Use the agent's configured model
-			Messages:    messagesForAPI,
-			Temperature: temperature,
-			Tools:       a.toolDefs(),
-			ToolChoice:  "auto", // Use string "auto"
-			MaxTokens:   1500,   // Increased for potentially complex responses or tool args
-		}
-
-		resp, err := a.client.CreateChatCompletion(context.Background(), req)
-		if err != nil {
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "update_plan",
+				Description: "Record or update your task checklist, shown to the user. 'plan' is a Markdown checklist with one step per line: '- [ ] todo', '- [~] in progress', '- [x] done'. Always send the full list.",
+				Parameters:  toolParams("plan"),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "read_plan",
+				Description: "Return the current task checklist with the status of every step.",
+				Parameters:  toolParams(),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "run_subtasks",
+				Description: "Delegate independent subtasks to parallel child agents, each working in its own git worktree. 'tasks' holds one self-contained subtask per line. Their changes are merged back into the project and conflicts are reported. Requires the project to be a git repository.",
+				Parameters:  toolParams("tasks"),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "fetch_url",
+				Description: "Fetch a web page (library docs, changelogs, error explanations) and return its main content as Markdown, truncated to a fixed size. Only http/https URLs.",
+				Parameters:  toolParams("url"),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "add_dependency",
+				Description: "Add a dependency (or change its version) with the project's package manager (go get, npm/pnpm/yarn/bun, cargo, uv, poetry, pip), which updates the manifest and lockfile consistently. Use this instead of editing go.mod, package.json, Cargo.toml, pyproject.toml, requirements.txt or lockfiles by hand. Returns the manifest diff.",
+				Parameters: toolSchema(stringParam("name", "package, module or crate name"),
+					stringParam("version", "version or range (default: latest)").optional(),
+					boolParam("dev", "development-only dependency (default false)").optional(),
+					stringParam("dir", "package directory relative to the project root, for monorepos (default: the root)").optional()),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "remove_dependency",
+				Description: "Remove a dependency with the project's package manager, updating the manifest and lockfile. Returns the manifest diff.",
+				Parameters: toolSchema(stringParam("name", "package, module or crate name"),
+					boolParam("dev", "it is a development-only dependency (default false)").optional(),
+					stringParam("dir", "package directory relative to the project root, for monorepos (default: the root)").optional()),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "profile",
+				Description: "Run code under a CPU profiler and return the hottest functions and call paths. Use it before and after optimizing, so performance work is based on measurements instead of guesses. Go modules: profiles `go test` of 'package', optionally only the tests matching 'run' or the benchmarks matching 'bench'. Python: runs 'command' (e.g. \"python bench.py\" or \"pytest tests/test_parser.py\") under py-spy, or cProfile when py-spy is not installed.",
+				Parameters: toolSchema(stringParam("command", "Python: the command to profile").optional(),
+					stringParam("package", "Go: the package to profile, e.g. ./internal/parser (default: the root package)").optional(),
+					stringParam("run", "Go: only run the tests matching this regexp").optional(),
+					stringParam("bench", "Go: run the benchmarks matching this regexp instead of the tests").optional()),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "run_shell",
+				Description: "Execute a shell command (bash) in the project dir and return its combined stdout/stderr. Errors are included in the output.",
+				Parameters:  toolParams("command"),
+			},
+		},
+	}
+}
+
+// toolAllowed reports whether the agent's mode permits offering and running a tool.
+func (a *AutonomousCodingAgent) toolAllowed(name string) bool {
+	if a.readOnly && !readOnlyTools[name] {
+		return false
+	}
+	if a.child && name == "run_subtasks" {
+		return false
+	}
+	if a.repo != nil && shellTools[name] {
+		return false
+	}
+	return true
+}
+
+// systemPrompt defines the initial system message for the AI.
+func systemPrompt() openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleSystem,
+		Content: `You are AutonomousCoder, a senior software engineer. Work step-by-step: decide which file to read or modify, or which shell command to run, using the provided tools. File paths should always be relative to the project root. Iterate until the tests pass or the goal is reached. Respond concisely. If a tool fails, analyze the error and try to fix the issue in your next step. If a shell command produces an error, that error will be part of its output. If a file operation results in 'nothing changed', consider if the 'find' pattern was correct or if the file already has the desired content. Be precise with file paths. Always use 'list_files' if unsure about file existence or names before attempting to read or write. For tasks with several steps, record a checklist with 'update_plan' first and keep it current as you complete steps. Large tasks made of independent parts (e.g. backend, tests, docs) can be delegated in parallel with 'run_subtasks'. For throwaway experiments (trying an API, reproducing a bug), write scripts with 'write_scratch' and run them from .zug/scratch/ instead of adding files to the project; remove them with 'clean_scratch' when done. Add, upgrade and remove dependencies with 'add_dependency' and 'remove_dependency', never by editing manifests or lockfiles by hand. For performance work, measure with 'profile' before and after changing code. When you find out a lasting fact about the project that a later run would have to rediscover (a required environment variable, a folder not to touch, a non-obvious test command), save it with 'remember'.`,
+	}
+}
+
+// systemMessage returns the mode's system prompt enriched with the detected project toolchain.
+func (a *AutonomousCodingAgent) systemMessage() openai.ChatCompletionMessage {
+	msg := a.prompt
+	if desc := a.describeWorkspace(); desc != "" {
+		msg.Content += "\n\n" + desc
+	}
+	if guide := a.describeGuide(); guide != "" {
+		msg.Content += "\n\n" + guide
+	}
+	if note := a.repoNote(); note != "" {
+		msg.Content += "\n\n" + note
+	}
+	if notes := a.describeMemory(); notes != "" {
+		msg.Content += "\n\n" + notes
+	}
+	if a.knowledge != "" {
+		msg.Content += "\n\n" + a.knowledge
+	}
+	return msg
+}
+
+// chat handles an entire cycle of user prompt → potential tool calls → assistant reply.
+func (a *AutonomousCodingAgent) chat(userPrompt string, phase string) (string, error) {
+	// Add current user prompt to the agent's context, unless the turn is one a resumed
+	// run stopped in: it is in the context already, maybe with tool calls to finish.
+	if r := a.resumeFrom; r != nil {
+		a.resumeFrom = nil
+		if reply, done, err := a.finishPendingCalls(r.InFlight); err != nil || done {
+			return reply, err
+		}
+	} else {
+		a.ctx = append(a.ctx, userMessage(userPrompt, a.images))
+		a.images = nil
+		a.saveProgress("")
+	}
+
+	// Maintain sliding window for a.ctx before making any API call
+	a.trimContext()
+
+	// Prepare messages for the current API call, including the system prompt
+	messagesForAPI := append([]openai.ChatCompletionMessage{a.systemMessage()}, a.ctx...)
+
+	// A constraint set for this turn applies to its first request only.
+	constraint := a.nextTurn
+	a.nextTurn = turnTools{}
+
+	// Loop for potential multiple tool calls within a single user turn
+	for step := 0; step < 10; step++ { // Safety: max 10 tool hops per user turn
+		if err := a.stopped(); err != nil {
+			return "", err
+		}
+		messagesForAPI = a.addSteering(messagesForAPI)
+		log.Printf("[agent] Chat step %d. Sending %d messages to API (incl. system prompt) using model %s.\n", step+1, len(messagesForAPI), a.model)
+
+		req, err := a.chatRequest(messagesForAPI, phase, a.toolDefs())
+		if err != nil {
+			return "", err
+		}
+		if step == 0 {
+			a.constrainTools(&req, constraint)
+		}
+
+		resp, err := a.createChatCompletion(req)
+		if err != nil {
 			// If API call fails, the last user message and any subsequent optimistic additions to a.ctx might need rollback
 			// For now, just return error. The caller (feedbackLoop) might retry or fail.
 			return "", fmt.Errorf("CreateChatCompletion failed on step %d: %w", step+1, err)
@@ -333,14 +987,26 @@ func (a *AutonomousCodingAgent) chat(userPrompt string, temperature float32) (st
 			return "", errors.New("received an empty Choices array from OpenAI")
 		}
 		msg := resp.Choices[0].Message
+		if len(req.Tools) == 0 && a.usesTextTools() {
+			msg = parseTextToolCalls(msg, fmt.Sprintf("text-%d", len(a.ctx)))
+		}
+		if resp.Choices[0].FinishReason == openai.FinishReasonLength {
+			log.Printf("[agent] ⚠️ The reply was cut off at the output token limit (%d); raise it with --max-output-tokens if files come out truncated.\n", max(req.MaxTokens, req.MaxCompletionTokens))
+		}
 
 		// Add assistant's response (which might be a content response or a tool call request) to agent's context
 		a.ctx = append(a.ctx, msg)
+		a.saveProgress("")
 		// Also add it to messagesForAPI for the *next* iteration of this tool-use loop, if any
 		messagesForAPI = append(messagesForAPI, msg)
 
-		// If no tool calls, assistant provided a direct content response. This turn is over.
+		// If no tool calls, assistant provided a direct content response. This turn is over,
+		// unless the user has typed a new instruction meanwhile.
 		if len(msg.ToolCalls) == 0 {
+			if steered := a.addSteering(messagesForAPI); len(steered) > len(messagesForAPI) {
+				messagesForAPI = steered
+				continue
+			}
 			if msg.Content == "" {
 				log.Println("[agent] Warning: Assistant response has no tool calls and no content.")
 				return "", errors.New("assistant provided no content and no tool calls")
@@ -352,65 +1018,101 @@ func (a *AutonomousCodingAgent) chat(userPrompt string, temperature float32) (st
 		// If there are tool calls, process them.
 		log.Printf("[agent] Assistant requests %d tool call(s).\n", len(msg.ToolCalls))
 		for _, toolCall := range msg.ToolCalls {
-			if toolCall.Type == openai.ToolTypeFunction {
-				toolName := toolCall.Function.Name
-				toolArgs := toolCall.Function.Arguments
-				log.Printf("[agent] Tool call requested: %s(%s)\n", toolName, toolArgs)
-
-				toolResult, toolErr := a.execTool(toolName, toolArgs)
-				if toolErr != nil {
-					log.Printf("[agent] Tool %s execution error: %v\n", toolName, toolErr)
-					// Format error message for the LLM to understand
-					toolResult = fmt.Sprintf("TOOL_EXECUTION_ERROR for %s: %s", toolName, toolErr.Error())
-				} else {
-					log.Printf("[agent] Tool %s result: %s\n", toolName, toolResult)
-				}
-
-				toolResponseMessage := openai.ChatCompletionMessage{
-					Role:       openai.ChatMessageRoleTool,
-					ToolCallID: toolCall.ID, // Crucial: Link the result to the specific call
-					Name:       toolName,
-					Content:    toolResult,
-				}
-				// Add tool response to agent's context
-				a.ctx = append(a.ctx, toolResponseMessage)
-				// Also add it to messagesForAPI for the next iteration of this tool-use loop
-				messagesForAPI = append(messagesForAPI, toolResponseMessage)
-			} else {
-				log.Printf("[agent] Warning: Received unhandled tool type: %s\n", toolCall.Type)
-				// Add a placeholder message to context if necessary, or handle appropriately
-				errorMsg := openai.ChatCompletionMessage{
-					Role:       openai.ChatMessageRoleTool,
-					ToolCallID: toolCall.ID,
-					Name:       toolCall.Function.Name, // Assuming it might have a name
-					Content:    fmt.Sprintf("Error: Tool type '%s' is not supported by the agent.", toolCall.Type),
-				}
-				a.ctx = append(a.ctx, errorMsg)
-				messagesForAPI = append(messagesForAPI, errorMsg)
+			toolResponseMessage, err := a.answerToolCall(toolCall)
+			if err != nil {
+				return "", err
 			}
+			// Add tool response to agent's context
+			a.ctx = append(a.ctx, toolResponseMessage)
+			// Also add it to messagesForAPI for the next iteration of this tool-use loop
+			messagesForAPI = append(messagesForAPI, toolResponseMessage)
+			a.saveProgress("")
 		}
 		// After processing all tool calls for this step, trim context again for the next API call in this loop
-		if len(a.ctx) > a.maxCtxMessages {
-			cutoff := len(a.ctx) - a.maxCtxMessages
-			a.ctx = a.ctx[cutoff:]
-			log.Printf("[agent] Context trimmed to %d messages during tool loop.\n", len(a.ctx))
+		if a.trimContext() {
 			// Rebuild messagesForAPI based on the newly trimmed a.ctx for the next step
-			messagesForAPI = append([]openai.ChatCompletionMessage{systemPrompt()}, a.ctx...)
+			messagesForAPI = append([]openai.ChatCompletionMessage{a.systemMessage()}, a.ctx...)
 		}
+		a.printStatus()
 		// Continue the loop to let the model react to the tool result(s).
 	}
 	log.Println("[agent] Error: Exceeded maximum tool invocations for this turn.")
-	return "", errors.New("too many tool invocations without a final answer")
+	return "", errToolSteps
+}
+
+// answerToolCall executes one tool call of a model reply and returns the tool message
+// with its result. The error is set only when the run must stop.
+func (a *AutonomousCodingAgent) answerToolCall(toolCall openai.ToolCall) (openai.ChatCompletionMessage, error) {
+	if toolCall.Type != openai.ToolTypeFunction {
+		log.Printf("[agent] Warning: Received unhandled tool type: %s\n", toolCall.Type)
+		return openai.ChatCompletionMessage{
+			Role:       openai.ChatMessageRoleTool,
+			ToolCallID: toolCall.ID,
+			Name:       toolCall.Function.Name, // Assuming it might have a name
+			Content:    fmt.Sprintf("Error: Tool type '%s' is not supported by the agent.", toolCall.Type),
+		}, nil
+	}
+	toolName := toolCall.Function.Name
+	toolArgs := toolCall.Function.Arguments
+	log.Printf("[agent] Tool call requested: %s(%s)\n", toolName, toolArgs)
+
+	note, err := a.interruptedNote(), error(nil)
+	if stop := a.stopped(); stop != nil {
+		note = stoppedCallNote(stop)
+	}
+	if note == "" {
+		note, err = a.checkRepeat(toolName, toolArgs)
+	}
+	if err != nil {
+		return openai.ChatCompletionMessage{}, err
+	}
+	toolResult, toolErr := note, error(nil)
+	var took time.Duration
+	if note == "" {
+		a.saveProgress(toolCall.ID)
+		start := time.Now()
+		toolResult, toolErr = a.dispatchTool(toolCall.ID, toolName, toolArgs)
+		took = time.Since(start)
+	}
+	a.status.lastTool, a.status.toolErr = toolName, toolErr != nil
+	if errors.Is(toolErr, errPolicyViolation) {
+		a.status.policyBlocks++
+	}
+	if toolErr != nil {
+		log.Printf("[agent] Tool %s execution error: %v\n", toolName, toolErr)
+		// Format error message for the LLM to understand
+		toolResult = fmt.Sprintf("TOOL_EXECUTION_ERROR for %s: %s", toolName, toolErr.Error())
+	} else {
+		log.Printf("[agent] Tool %s result: %s\n", toolName, toolResult)
+	}
+	if toolName != "read_result" {
+		toolResult = a.capToolResult(toolResult)
+	}
+	toolResult += a.editFailureHint(toolName, toolArgs, toolResult, toolErr)
+	if note == "" {
+		a.recordToolCall(toolName, took, len(toolResult), toolErr != nil || wastedCall(toolName, toolResult))
+	}
+
+	return openai.ChatCompletionMessage{
+		Role:       openai.ChatMessageRoleTool,
+		ToolCallID: toolCall.ID, // Crucial: Link the result to the specific call
+		Name:       toolName,
+		Content:    toolResult,
+	}, nil
 }
 
 // execTool deserialises args and dispatches to the matching Go helper.
 func (a *AutonomousCodingAgent) execTool(name, jsonArgs string) (string, error) {
 	log.Printf("[agent] execTool: %s, Args: %s\n", name, jsonArgs)
+	if !a.toolAllowed(name) {
+		return "", fmt.Errorf("tool %q is not available in this mode", name)
+	}
 	switch name {
 	case "create_file", "append_file":
 		var p struct {
-			Path    string `json:"path"`
-			Content string `json:"content"` // Content can be empty for create_file (empty file)
+			Path      string `json:"path"`
+			Content   string `json:"content"` // Content can be empty for create_file (empty file)
+			Overwrite bool   `json:"overwrite"`
 		}
 		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
 			return "", fmt.Errorf("invalid JSON arguments for %s: %w. Raw args: %s", name, err, jsonArgs)
@@ -419,15 +1121,30 @@ func (a *AutonomousCodingAgent) execTool(name, jsonArgs string) (string, error)
 			return "", fmt.Errorf("argument 'path' for %s cannot be empty. Raw args: %s", name, jsonArgs)
 		}
 		if name == "create_file" {
-			return a.createFile(p.Path, p.Content)
+			return a.createFile(p.Path, p.Content, p.Overwrite)
 		}
 		return a.appendFile(p.Path, p.Content)
 
 	case "update_file":
 		var p struct {
-			Path    string `json:"path"`
-			Find    string `json:"find"`    // Find can be empty, meaning replace entire content if replace is not empty
-			Replace string `json:"replace"` // Replace can be empty, meaning delete found content
+			Path          string `json:"path"`
+			Find          string `json:"find"`    // Find can be empty, meaning replace entire content if replace is not empty
+			Replace       string `json:"replace"` // Replace can be empty, meaning delete found content
+			ExpectedCount *int   `json:"expected_count"`
+			Diff          bool   `json:"diff"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for %s: %w. Raw args: %s", name, err, jsonArgs)
+		}
+		if strings.TrimSpace(p.Path) == "" {
+			return "", fmt.Errorf("argument 'path' for %s cannot be empty. Raw args: %s", name, jsonArgs)
+		}
+		return a.updateFile(p.Path, p.Find, p.Replace, p.ExpectedCount, p.Diff)
+
+	case "edit_file":
+		var p struct {
+			Path  string `json:"path"`
+			Edits string `json:"edits"`
 		}
 		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
 			return "", fmt.Errorf("invalid JSON arguments for %s: %w. Raw args: %s", name, err, jsonArgs)
@@ -435,17 +1152,220 @@ func (a *AutonomousCodingAgent) execTool(name, jsonArgs string) (string, error)
 		if strings.TrimSpace(p.Path) == "" {
 			return "", fmt.Errorf("argument 'path' for %s cannot be empty. Raw args: %s", name, jsonArgs)
 		}
-		return a.updateFile(p.Path, p.Find, p.Replace)
+		return a.editFile(p.Path, p.Edits)
+
+	case "replace_symbol":
+		var p struct {
+			Path    string `json:"path"`
+			Symbol  string `json:"symbol"`
+			NewCode string `json:"new_code"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for %s: %w. Raw args: %s", name, err, jsonArgs)
+		}
+		if strings.TrimSpace(p.Path) == "" || strings.TrimSpace(p.Symbol) == "" || strings.TrimSpace(p.NewCode) == "" {
+			return "", fmt.Errorf("arguments 'path', 'symbol' and 'new_code' for %s cannot be empty. Raw args: %s", name, jsonArgs)
+		}
+		return a.replaceSymbol(p.Path, strings.TrimSpace(p.Symbol), p.NewCode)
 
 	case "read_file":
-		var p struct{ Path string `json:"path"` }
+		var p struct {
+			Path        string `json:"path"`
+			LineNumbers bool   `json:"line_numbers"`
+		}
 		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
 			return "", fmt.Errorf("invalid JSON arguments for read_file: %w. Raw args: %s", err, jsonArgs)
 		}
 		if strings.TrimSpace(p.Path) == "" {
 			return "", fmt.Errorf("argument 'path' for read_file cannot be empty. Raw args: %s", jsonArgs)
 		}
-		return a.readFile(p.Path)
+		content, err := a.readFile(p.Path)
+		if err != nil || !p.LineNumbers {
+			return content, err
+		}
+		return numberLines(content), nil
+
+	case "read_files":
+		var p struct {
+			Paths   []string `json:"paths"`
+			Pattern string   `json:"pattern"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for read_files: %w. Raw args: %s", err, jsonArgs)
+		}
+		return a.readFiles(p.Paths, strings.TrimSpace(p.Pattern))
+
+	case "find_symbol":
+		var p struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for find_symbol: %w. Raw args: %s", err, jsonArgs)
+		}
+		if strings.TrimSpace(p.Name) == "" {
+			return "", fmt.Errorf("argument 'name' for find_symbol cannot be empty. Raw args: %s", jsonArgs)
+		}
+		return a.findSymbol(strings.TrimSpace(p.Name))
+
+	case "find_references":
+		var p struct {
+			Name string `json:"name"`
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for find_references: %w. Raw args: %s", err, jsonArgs)
+		}
+		if strings.TrimSpace(p.Name) == "" {
+			return "", fmt.Errorf("argument 'name' for find_references cannot be empty. Raw args: %s", jsonArgs)
+		}
+		return a.findReferences(strings.TrimSpace(p.Name), p.Path)
+
+	case "import_graph":
+		var p struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for import_graph: %w. Raw args: %s", err, jsonArgs)
+		}
+		if strings.TrimSpace(p.Path) == "" {
+			return "", fmt.Errorf("argument 'path' for import_graph cannot be empty. Raw args: %s", jsonArgs)
+		}
+		return a.importGraphOf(p.Path)
+
+	case "env_vars":
+		var p struct {
+			Dir string `json:"dir"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for env_vars: %w. Raw args: %s", err, jsonArgs)
+		}
+		return a.envVarsReport(strings.TrimSpace(p.Dir))
+
+	case "rename_symbol":
+		var p struct {
+			Old  string `json:"old"`
+			New  string `json:"new"`
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for rename_symbol: %w. Raw args: %s", err, jsonArgs)
+		}
+		if strings.TrimSpace(p.Old) == "" || strings.TrimSpace(p.New) == "" {
+			return "", fmt.Errorf("arguments 'old' and 'new' for rename_symbol cannot be empty. Raw args: %s", jsonArgs)
+		}
+		return a.renameSymbol(strings.TrimSpace(p.Old), strings.TrimSpace(p.New), p.Path)
+
+	case "validate_syntax":
+		var p struct {
+			Path    string  `json:"path"`
+			Content *string `json:"content"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for validate_syntax: %w. Raw args: %s", err, jsonArgs)
+		}
+		if strings.TrimSpace(p.Path) == "" {
+			return "", fmt.Errorf("argument 'path' for validate_syntax cannot be empty. Raw args: %s", jsonArgs)
+		}
+		return a.validateSyntax(p.Path, p.Content)
+
+	case "replace_lines":
+		var p struct {
+			Path      string `json:"path"`
+			StartLine int    `json:"start_line"`
+			EndLine   int    `json:"end_line"`
+			Content   string `json:"content"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for replace_lines: %w. Raw args: %s", err, jsonArgs)
+		}
+		if strings.TrimSpace(p.Path) == "" {
+			return "", fmt.Errorf("argument 'path' for replace_lines cannot be empty. Raw args: %s", jsonArgs)
+		}
+		return a.replaceLines(p.Path, p.StartLine, p.EndLine, p.Content)
+
+	case "make_dir", "remove_dir":
+		var p struct {
+			Path      string `json:"path"`
+			Recursive bool   `json:"recursive"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for %s: %w. Raw args: %s", name, err, jsonArgs)
+		}
+		if strings.TrimSpace(p.Path) == "" {
+			return "", fmt.Errorf("argument 'path' for %s cannot be empty. Raw args: %s", name, jsonArgs)
+		}
+		if name == "make_dir" {
+			return a.makeDir(p.Path)
+		}
+		return a.removeDir(p.Path, p.Recursive)
+
+	case "set_executable":
+		var p struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for %s: %w. Raw args: %s", name, err, jsonArgs)
+		}
+		if strings.TrimSpace(p.Path) == "" {
+			return "", fmt.Errorf("argument 'path' for %s cannot be empty. Raw args: %s", name, jsonArgs)
+		}
+		return a.setExecutable(p.Path)
+
+	case "copy_file":
+		var p struct {
+			From      string `json:"from"`
+			To        string `json:"to"`
+			Overwrite bool   `json:"overwrite"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for %s: %w. Raw args: %s", name, err, jsonArgs)
+		}
+		if strings.TrimSpace(p.From) == "" || strings.TrimSpace(p.To) == "" {
+			return "", fmt.Errorf("arguments 'from' and 'to' for %s cannot be empty. Raw args: %s", name, jsonArgs)
+		}
+		return a.copyFile(p.From, p.To, p.Overwrite)
+
+	case "write_scratch":
+		var p struct {
+			Name    string `json:"name"`
+			Content string `json:"content"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for %s: %w. Raw args: %s", name, err, jsonArgs)
+		}
+		if strings.TrimSpace(p.Name) == "" {
+			return "", fmt.Errorf("argument 'name' for %s cannot be empty. Raw args: %s", name, jsonArgs)
+		}
+		return a.writeScratch(p.Name, p.Content)
+
+	case "clean_scratch":
+		return a.cleanScratch()
+
+	case "remember":
+		var p struct {
+			Note   string `json:"note"`
+			Global bool   `json:"global"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for %s: %w. Raw args: %s", name, err, jsonArgs)
+		}
+		if p.Global {
+			return a.learnConvention(strings.Join(strings.Fields(p.Note), " "))
+		}
+		return a.remember(p.Note)
+
+	case "recall":
+		return a.recall()
+
+	case "read_result":
+		var p struct {
+			ID     string `json:"id"`
+			Offset int    `json:"offset"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for %s: %w. Raw args: %s", name, err, jsonArgs)
+		}
+		return a.readResult(p.ID, p.Offset)
 
 	case "list_files":
 		// No arguments expected, jsonArgs might be "{}" or empty.
@@ -459,6 +1379,76 @@ func (a *AutonomousCodingAgent) execTool(name, jsonArgs string) (string, error)
 		}
 		return a.listFiles()
 
+	case "search_files":
+		var p struct {
+			Pattern string `json:"pattern"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for search_files: %w. Raw args: %s", err, jsonArgs)
+		}
+		if p.Pattern == "" {
+			return "", fmt.Errorf("argument 'pattern' for search_files cannot be empty. Raw args: %s", jsonArgs)
+		}
+		return a.searchFiles(p.Pattern)
+
+	case "update_plan":
+		var p struct {
+			Plan string `json:"plan"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for update_plan: %w. Raw args: %s", err, jsonArgs)
+		}
+		return a.updatePlan(p.Plan)
+
+	case "read_plan":
+		return a.readPlan()
+
+	case "run_subtasks":
+		var p struct {
+			Tasks string `json:"tasks"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for run_subtasks: %w. Raw args: %s", err, jsonArgs)
+		}
+		return a.runSubtasks(p.Tasks)
+
+	case "fetch_url":
+		var p struct {
+			URL string `json:"url"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for fetch_url: %w. Raw args: %s", err, jsonArgs)
+		}
+		if strings.TrimSpace(p.URL) == "" {
+			return "", fmt.Errorf("argument 'url' for fetch_url cannot be empty. Raw args: %s", jsonArgs)
+		}
+		return a.fetchURL(strings.TrimSpace(p.URL))
+
+	case "add_dependency", "remove_dependency":
+		var p struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+			Dev     bool   `json:"dev"`
+			Dir     string `json:"dir"`
+		}
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for %s: %w. Raw args: %s", name, err, jsonArgs)
+		}
+		if strings.TrimSpace(p.Name) == "" {
+			return "", fmt.Errorf("argument 'name' for %s cannot be empty. Raw args: %s", name, jsonArgs)
+		}
+		if p.Dir == "" {
+			p.Dir = "."
+		}
+		return a.changeDependency(p.Dir, p.Name, p.Version, p.Dev, name == "remove_dependency")
+
+	case "profile":
+		var p profileRequest
+		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
+			return "", fmt.Errorf("invalid JSON arguments for profile: %w. Raw args: %s", err, jsonArgs)
+		}
+		return a.profile(p)
+
 	case "run_shell":
 		var p struct{ Command string `json:"command"` }
 		if err := json.Unmarshal([]byte(jsonArgs), &p); err != nil {
@@ -478,67 +1468,200 @@ func (a *AutonomousCodingAgent) execTool(name, jsonArgs string) (string, error)
   Feedback-driven loop
   ─────────────────────────────*/
 
-func (a *AutonomousCodingAgent) feedbackLoop(initialTask string) {
+// errMaxTurns is returned by feedbackLoop when the turn budget runs out before the task is verified.
+var errMaxTurns = errors.New("reached maximum turns in feedback loop")
+
+// feedbackLoop drives the task to completion. It returns nil once the task is considered done.
+func (a *AutonomousCodingAgent) feedbackLoop(initialTask string) (err error) {
 	log.Printf("[agent] 🏁 Starting main task: %s (Using model: %s)\n", initialTask, a.model)
+	if !a.child {
+		if err := a.preflight(initialTask); err != nil {
+			return err
+		}
+	}
+	defer a.closeLanguageServers()
+	defer a.closeNetProxy()
+	a.beginRun(initialTask)
+	a.status = runStatus{started: time.Now()}
+	a.loop = loopDetector{}
+	if !a.child {
+		a.startSecurity()
+		a.consultKnowledge(initialTask)
+		a.warmSymbols()
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			a.crashed(p)
+		}
+		if !a.child && (err == nil || errors.Is(err, errMaxTurns)) {
+			a.summarizeOutcome(initialTask, err)
+		}
+		if !a.child && err != nil {
+			a.writeProgressReport(initialTask, err)
+		}
+		a.recordKnowledge(initialTask, err)
+		if !a.child && err == nil {
+			a.writePRDescription(initialTask)
+		}
+		a.finishRun(err)
+		if a.child {
+			return
+		}
+		fmt.Println(a.runSummary())
+		a.recordTelemetry(err)
+		success := err == nil
+		ev := hookEvent{Event: hookOnComplete, Task: initialTask, Success: &success, Tools: a.toolUsage()}
+		if err != nil {
+			ev.Error = err.Error()
+		}
+		if hookErr := a.runHooks(ev); hookErr != nil {
+			log.Printf("[agent] ⚠️ %v\n", hookErr)
+		}
+	}()
 	currentTaskInstruction := initialTask
+	reviewed := false
+	failures := 0 // consecutive failed model turns, see recoverTurn
+	firstTurn := 0
+	if r := a.resumeFrom; r != nil {
+		firstTurn, currentTaskInstruction = max(r.Turn-1, 0), r.Instruction
+	}
 
 	// Overall loop for iterative refinement based on tests or other feedback
-	for turn := 0; turn < 10; turn++ { // Max 10 overall turns for the task
+	for turn := firstTurn; turn < 10; turn++ { // Max 10 overall turns for the task
 		log.Printf("[agent] >>> Feedback Loop Turn %d/%d. Current instruction: %s\n", turn+1, 10, currentTaskInstruction)
+		a.progress = runProgress{Turn: turn + 1, Instruction: currentTaskInstruction}
+		a.status.turn = turn + 1
+		a.printStatus()
+		if err := a.stopped(); err != nil {
+			return err
+		}
+		if err := a.runHooks(hookEvent{Event: hookPreTurn, Turn: turn + 1, Instruction: currentTaskInstruction}); err != nil {
+			return fmt.Errorf("%w: aborted by pre_turn hook: %w", errPolicyViolation, err)
+		}
 
 		// The 'chat' function itself has an inner loop for tool usage.
 		// This outer loop is for broader feedback, like test results.
-		assistantReply, err := a.chat(currentTaskInstruction, 0.1) // Lower temp for more deterministic tool use
+		assistantReply, err := a.chat(currentTaskInstruction, phaseTools)
 		if err != nil {
-			// If chat fails (e.g. too many tool steps, API error), decide how to proceed.
-			// Maybe retry once, or modify the task, or give up.
+			// A request cut off by Ctrl-C or the time limit is not a model failure.
+			if stop := a.stopped(); stop != nil {
+				return stop
+			}
+			// Context overflows, transient API errors and exhausted tool steps have a
+			// recovery of their own; anything else ends the run.
+			if next, ok := a.recoverTurn(err, currentTaskInstruction, &failures); ok {
+				currentTaskInstruction = next
+				continue
+			}
 			log.Printf("❌ Model interaction (chat function) failed on turn %d: %v. Aborting this task.", turn+1, err)
 			// Potentially add the error to context for a final attempt, or just exit.
 			// For now, we exit the feedback loop.
-			return
+			return fmt.Errorf("model interaction failed on turn %d: %w", turn+1, err)
 		}
+		failures = 0
 		fmt.Printf("🤖 Assistant's Plan/Summary:\n%s\n\n", assistantReply)
+		a.lastReply = assistantReply
+
+		// A project that does not compile cannot pass tests, so iterate on build errors first.
+		if buildOutput, buildOK := a.runBuild(); !buildOK {
+			log.Println("[agent] 🔨 Build failed.")
+			a.noteFailure(failBuild)
+			currentTaskInstruction = fmt.Sprintf("The project does not build. Fix the compile errors below before anything else. Build output:\n%s", buildOutput)
+			a.nextTurn = turnTools{required: true}
+			continue
+		}
 
 		// Check for tests after the assistant believes it has made progress or completed a step.
-		testsDir := filepath.Join(a.projectDir, "tests")
-		if info, statErr := os.Stat(testsDir); statErr == nil && info.IsDir() {
-			log.Println("[agent] Running tests in 'tests/' directory...")
-			// Standardize test command or make it configurable.
-			// Assuming pytest for Python projects.
-			testCommand := "pytest -q --maxfail=1 --disable-warnings tests/"
-			testOutput, _ := a.runShell(testCommand) // runShell already handles errors by including them in output
-
-			fmt.Printf("🐍 Test Execution Output:\n%s\n\n", testOutput)
-
-			// Basic check for "failed" or "error" in output. Could be more sophisticated.
-			// Also check for "passed" to confirm success.
-			// Note: Pytest exit codes are more reliable if runShell could return them.
-			// 0: All tests passed
-			// 1: Tests were collected and run but some failed/errored
-			// 2: Test execution was interrupted by the user
-			// 3: Internal error in pytest
-			// 4: pytest command line usage error
-			// 5: No tests were collected
-			// For now, relying on string parsing of output.
-			if !strings.Contains(strings.ToLower(testOutput), "fail") &&
-				!strings.Contains(strings.ToLower(testOutput), "error") &&
-				(strings.Contains(strings.ToLower(testOutput), "pass") || strings.Contains(strings.ToLower(testOutput), "no tests ran")) {
-
-				if strings.Contains(strings.ToLower(testOutput), "no tests ran") && !strings.Contains(strings.ToLower(testOutput), "collected 0 items") {
-					log.Println("[agent] 🤔 Tests reported 'no tests ran' but it wasn't 'collected 0 items'. This might indicate a test discovery issue. Assuming success for now but please verify.")
+		lintOutput, lintOK := a.runLinter()
+
+		testOutput, testsRan, testsPassed := a.runTests()
+		if testsRan {
+			a.status.testsRan, a.status.testsPassed, a.status.testOutput = true, testsPassed, testOutput
+			fmt.Printf("🧪 Test Execution Output:\n%s\n\n", testOutput)
+			if testsPassed {
+				if !lintOK {
+					log.Println("[agent] 🧹 Tests passed but the linter reported violations.")
+					a.noteFailure(failLint)
+					currentTaskInstruction = fmt.Sprintf("The tests pass, but the linter reported violations. Fix them without changing behavior. Linter output:\n%s", lintOutput)
+					a.nextTurn = turnTools{required: true}
+					continue
+				}
+				log.Println("[agent] ✅ All tests passed. Task considered complete.")
+				if next, again := a.securityFollowUp(); again {
+					currentTaskInstruction = next
+					continue
+				}
+				if next, again := a.refactorFollowUp(); again {
+					currentTaskInstruction = next
+					continue
+				}
+				if next, again := a.documentFollowUp(); again {
+					currentTaskInstruction = next
+					continue
+				}
+				if next, again := a.dockerFollowUp(); again {
+					currentTaskInstruction = next
+					continue
 				}
-				log.Println("[agent] ✅ All tests passed (or no tests failed/errored). Task considered complete.")
-				return // Successfully exit feedbackLoop
+				if next, again := a.mutationFollowUp(); again {
+					currentTaskInstruction = next
+					continue
+				}
+				if next, again := a.reviewFollowUp(initialTask, &reviewed); again {
+					currentTaskInstruction = next
+					continue
+				}
+				a.status.verified = a.dockerOK()
+				return nil // Successfully exit feedbackLoop
 			}
 			log.Println("[agent] 🔬 Tests failed or encountered errors.")
+			a.noteFailure(failTests)
 			currentTaskInstruction = fmt.Sprintf("The previous operations led to test failures. Please analyze the following test output and fix the code. Test output:\n%s", testOutput)
+			a.nextTurn = turnTools{required: true}
+			if names := failingTests(testOutput); len(names) > 0 {
+				// Reading the failing test first beats guessing at the fix from its output.
+				currentTaskInstruction += fmt.Sprintf("\n\nFailing tests: %s. Start by reading the file of the first one.", strings.Join(names[:min(len(names), 5)], ", "))
+				a.nextTurn = turnTools{tool: "read_file"}
+			}
+			currentTaskInstruction += a.refactorRegression(testOutput)
+			if !lintOK {
+				currentTaskInstruction += fmt.Sprintf("\n\nThe linter also reported violations. Linter output:\n%s", lintOutput)
+			}
 			time.Sleep(1 * time.Second) // Brief pause before formulating the next request to the LLM
+		} else if !lintOK {
+			log.Println("[agent] 🧹 Linter reported violations.")
+			a.noteFailure(failLint)
+			currentTaskInstruction = fmt.Sprintf("The linter reported violations in the code. Fix them without changing behavior. Linter output:\n%s", lintOutput)
+			a.nextTurn = turnTools{required: true}
 		} else {
-			log.Printf("[agent] 🎉 Task processing by assistant is complete. No 'tests' directory found at '%s' or it's not a directory. Manual verification recommended.\n", testsDir)
-			return // Successfully exit feedbackLoop, assuming task is done if no tests.
+			log.Printf("[agent] 🎉 Task processing by assistant is complete. No test command found for '%s'. Manual verification recommended.\n", a.projectDir)
+			if next, again := a.securityFollowUp(); again {
+				currentTaskInstruction = next
+				continue
+			}
+			if next, again := a.documentFollowUp(); again {
+				currentTaskInstruction = next
+				continue
+			}
+			if next, again := a.dockerFollowUp(); again {
+				currentTaskInstruction = next
+				continue
+			}
+			if next, again := a.verifyFollowUp(initialTask); again {
+				a.nextTurn = turnTools{required: true}
+				currentTaskInstruction = next
+				continue
+			}
+			if next, again := a.reviewFollowUp(initialTask, &reviewed); again {
+				currentTaskInstruction = next
+				continue
+			}
+			a.status.verified = a.verifyOK() && a.dockerOK()
+			return nil // Successfully exit feedbackLoop, assuming task is done if no tests.
 		}
 	}
 	log.Println("[agent] ⚠️ Reached maximum turns in feedback loop. Task may not be fully complete or tests might still be failing.")
+	return errMaxTurns
 }
 
 /*──────────────────────────────
@@ -548,47 +1671,22 @@ func (a *AutonomousCodingAgent) feedbackLoop(initialTask string) {
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile) // Add file/line number to logs for easier debugging
 
-	if len(os.Args) < 2 || strings.TrimSpace(os.Args[1]) == "" {
-		fmt.Printf("Usage: %s \"<describe your coding task>\" [model_name]\n", os.Args[0])
-		fmt.Println("Example: go run . \"Create a Python script...\"")
-		fmt.Println("Example with model: go run . \"Create a Python script...\" gpt-4-turbo")
-		fmt.Println("You can also set the OPENAI_MODEL environment variable.")
-		os.Exit(1)
-	}
-	initialTask := os.Args[1]
-	var modelName string
-	if len(os.Args) > 2 {
-		modelName = strings.TrimSpace(os.Args[2])
-	}
-
-	// Prioritize environment variable for model selection
-	envModel := os.Getenv("OPENAI_MODEL")
-	if envModel != "" {
-		modelName = envModel
-		log.Printf("[agent] Using model from OPENAI_MODEL environment variable: %s\n", modelName)
-	} else if modelName != "" {
-		log.Printf("[agent] Using model from command line argument: %s\n", modelName)
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == netBridgeCommand {
+		runNetBridge(args[1:])
+		return
 	}
-	// If modelName is still empty here, NewAgent will use the default (e.g., openai.GPT4o)
-
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("FATAL: OPENAI_API_KEY environment variable is not set.")
-	}
-
-	projectDirName := "ai_coder_project"
-	wd, err := os.Getwd()
-	if err != nil {
-		log.Fatalf("FATAL: Could not get current working directory: %v", err)
+	if len(args) > 0 {
+		if c, ok := findCommand(args[0]); ok {
+			c.run(args[1:])
+			return
+		}
+		if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			printUsage()
+			return
+		}
 	}
-	projectFullPath := filepath.Join(wd, projectDirName)
-
-	log.Printf("[agent] Project directory will be: %s\n", projectFullPath)
-	log.Printf("[agent] Initial task from command line: %s\n", initialTask)
-
-	agent := NewAgent(apiKey, projectFullPath, modelName)
-
-	agent.feedbackLoop(initialTask)
-
-	log.Println("[agent] 🏁 Autonomous Coding Agent finished.")
+	// No subcommand: `zug [flags] "<task>" [model]` behaves like `zug run`.
+	c, _ := findCommand("run")
+	c.run(args)
 }